@@ -62,6 +62,7 @@ func newCommands() []cli.Command {
 		helpers.NewCacheArchiverCommand(),
 		helpers.NewCacheExtractorCommand(),
 		helpers.NewCacheInitCommand(),
+		helpers.NewEgressProxyCommand(),
 		helpers.NewHealthCheckCommand(),
 		helpers.NewProxyExecCommand(),
 		helpers.NewReadLogsCommand(),
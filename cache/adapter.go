@@ -35,6 +35,45 @@ type Adapter interface {
 	GetGoCloudURL(ctx context.Context, upload bool) (GoCloudURL, error)
 }
 
+// ObjectInfo describes an object held by a cache backend.
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectManager is implemented by adapters that can enumerate and delete the
+// objects stored in their backend. It's used to prune the cache, which
+// otherwise grows indefinitely.
+type ObjectManager interface {
+	// ListObjects returns all objects whose name starts with prefix.
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// DeleteObject deletes the named object. Deleting an object that doesn't
+	// exist is not an error.
+	DeleteObject(ctx context.Context, name string) error
+}
+
+// ObjectReader is implemented by adapters that can read the objects stored in
+// their backend. Prune uses it to read the manifests of the chunked cache
+// archives, and delete the chunks none of them references.
+type ObjectReader interface {
+	// ReadObjectRange returns up to length bytes of the named object,
+	// starting at offset.
+	ReadObjectRange(ctx context.Context, name string, offset, length int64) ([]byte, error)
+}
+
+// ChunkStoreAdapter is implemented by adapters that can give jobs access to
+// the chunk store of a project along with a cache object. It's required to
+// transfer cache archives in the chunked format, whose cache object only
+// holds a manifest listing chunks stored under a prefix shared by all the
+// cache keys of the project.
+type ChunkStoreAdapter interface {
+	// GetChunkStoreGoCloudURL returns the Go Cloud URL of the cache object,
+	// like GetGoCloudURL, but with an environment that grants access to the
+	// objects under chunkPrefix too.
+	GetChunkStoreGoCloudURL(ctx context.Context, chunkPrefix string, upload bool) (GoCloudURL, error)
+}
+
 type Factory func(config *cacheconfig.Config, timeout time.Duration, objectName string) (Adapter, error)
 
 type FactoriesMap struct {
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/sirupsen/logrus"
 
 	"gitlab.com/gitlab-org/gitlab-runner/cache"
@@ -135,6 +137,68 @@ func (a *azureAdapter) getSigner() sasSigner {
 	return signer
 }
 
+func (a *azureAdapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	client, err := a.containerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []cache.ObjectInfo
+	pager := client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: new(strings.TrimLeft(prefix, "/"))})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing Azure blobs: %w", err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			obj := cache.ObjectInfo{Name: *item.Name}
+			if props := item.Properties; props != nil {
+				if props.ContentLength != nil {
+					obj.Size = *props.ContentLength
+				}
+				if props.LastModified != nil {
+					obj.LastModified = *props.LastModified
+				}
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+func (a *azureAdapter) DeleteObject(ctx context.Context, name string) error {
+	client, err := a.containerClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.NewBlobClient(name).Delete(ctx, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("deleting Azure blob: %w", err)
+	}
+
+	return nil
+}
+
+func (a *azureAdapter) containerClient() (*container.Client, error) {
+	if a.config.ContainerName == "" {
+		return nil, fmt.Errorf("ContainerName can't be empty")
+	}
+
+	if err := a.credentialsResolver.Resolve(); err != nil {
+		return nil, fmt.Errorf("error resolving Azure credentials: %w", err)
+	}
+
+	client, err := newContainerClient(a.config)
+	if err != nil {
+		return nil, fmt.Errorf("creating Azure container client: %w", err)
+	}
+
+	return client, nil
+}
+
 func New(config *cacheconfig.Config, timeout time.Duration, objectName string) (cache.Adapter, error) {
 	azure := config.Azure
 	if azure == nil {
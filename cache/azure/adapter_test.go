@@ -7,10 +7,14 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -308,3 +312,70 @@ func TestAdapterOperation(t *testing.T) {
 		})
 	}
 }
+
+func TestListAndDeleteObjects(t *testing.T) {
+	blobs := map[string]string{
+		"runner/a/project/1/key": "content",
+		"runner/b/project/1/key": "other content",
+	}
+	lastModified := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/"+containerName && r.URL.Query().Get("comp") == "list":
+			prefix := r.URL.Query().Get("prefix")
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="%s"><Prefix>%s</Prefix><Blobs>`, containerName, prefix)
+			for name, content := range blobs {
+				if strings.HasPrefix(name, prefix) {
+					_, _ = fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length></Properties></Blob>`,
+						name, lastModified.Format(http.TimeFormat), len(content))
+				}
+			}
+			_, _ = fmt.Fprint(w, `</Blobs><NextMarker /></EnumerationResults>`)
+		case r.Method == http.MethodDelete:
+			name := strings.TrimPrefix(r.URL.Path, "/"+containerName+"/")
+			if _, ok := blobs[name]; !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	oldNewContainerClient := newContainerClient
+	newContainerClient = func(config *cacheconfig.CacheAzureConfig) (*container.Client, error) {
+		return container.NewClientWithNoCredential(ts.URL+"/"+config.ContainerName, nil)
+	}
+	t.Cleanup(func() {
+		newContainerClient = oldNewContainerClient
+	})
+
+	adapter, err := New(defaultAzureCache(), defaultTimeout, "")
+	require.NoError(t, err)
+	manager, ok := adapter.(cache.ObjectManager)
+	require.True(t, ok)
+
+	objects, err := manager.ListObjects(t.Context(), "runner/a/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "runner/a/project/1/key", objects[0].Name)
+	assert.Equal(t, int64(7), objects[0].Size)
+	assert.True(t, lastModified.Equal(objects[0].LastModified))
+
+	require.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"))
+	assert.NotContains(t, blobs, "runner/a/project/1/key")
+
+	assert.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"), "deleting a missing blob succeeds")
+
+	config := defaultAzureCache()
+	config.Azure.ContainerName = ""
+	adapter, err = New(config, defaultTimeout, "")
+	require.NoError(t, err)
+	_, err = adapter.(cache.ObjectManager).ListObjects(t.Context(), "")
+	assert.EqualError(t, err, "ContainerName can't be empty")
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
@@ -116,6 +117,29 @@ func getBlobServiceURL(config *cacheconfig.CacheAzureConfig) string {
 	return fmt.Sprintf("https://%s.%s", config.CacheAzureCredentials.AccountName, domain)
 }
 
+// newContainerClient returns a client for the cache container, authenticated
+// with the account key when configured and the default Azure credentials
+// otherwise.
+var newContainerClient = func(config *cacheconfig.CacheAzureConfig) (*container.Client, error) {
+	containerURL := getBlobServiceURL(config) + "/" + config.ContainerName
+
+	if config.AccountKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(config.AccountName, config.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("creating Azure shared key credentials: %w", err)
+		}
+
+		return container.NewClientWithSharedKeyCredential(containerURL, credential, nil)
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure identity credentials: %w", err)
+	}
+
+	return container.NewClient(containerURL, credential, nil)
+}
+
 func newAccountKeySigner(config *cacheconfig.CacheAzureConfig) (sasSigner, error) {
 	credentials := config.CacheAzureCredentials
 	if credentials.AccountName == "" {
@@ -24,6 +24,63 @@ func (nopAdapter) GetGoCloudURL(ctx context.Context, upload bool) (GoCloudURL, e
 
 var createAdapter = getCreateAdapter
 
+// cacheNamespace returns the namespace of a runner's cache objects. Runners
+// get their own namespace, unless they're shared, in which case the namespace
+// is empty.
+func cacheNamespace(config *cacheconfig.Config, shortToken string) string {
+	if config.GetShared() {
+		return ""
+	}
+
+	return path.Join("runner", shortToken)
+}
+
+// ChunkedFormat is the CACHE_COMPRESSION_FORMAT of chunked cache archives,
+// whose chunks are stored under ChunkPrefix.
+const ChunkedFormat = "chunked"
+
+// chunkStoreDir is the directory of the namespace holding the chunk stores.
+// Cache keys are stored under the project directory, so they can't collide
+// with the chunks.
+const chunkStoreDir = "chunks"
+
+// ErrChunkStoreUnsupported is returned when chunked cache archives are
+// requested but the cache can't store their chunks.
+var ErrChunkStoreUnsupported = fmt.Errorf("CACHE_COMPRESSION_FORMAT=%s requires the s3 cache with RoleARN set", ChunkedFormat)
+
+// SupportsChunkStore returns true when the cache can hold the chunk stores of
+// chunked cache archives. Only the s3 cache with RoleARN set can, as jobs are
+// then given credentials covering the chunks of their project. A nil config,
+// for which caches are only kept on the runner, needs no chunk store.
+func SupportsChunkStore(config *cacheconfig.Config) bool {
+	if config == nil {
+		return true
+	}
+
+	return config.Type == "s3" && config.S3 != nil && config.S3.RoleARN != ""
+}
+
+// Chunk stores of the jobs of protected and unprotected refs. Jobs can write
+// to the chunk store they're given credentials for, and reuse the chunks it
+// holds without reading them, so the jobs of unprotected refs must not be able
+// to write the chunks the jobs of protected refs use.
+const (
+	protectedChunkStore   = "protected"
+	unprotectedChunkStore = "unprotected"
+)
+
+// ChunkPrefix returns the prefix under which the chunks of a project's
+// chunked cache archives are stored, for the jobs of protected refs, or of
+// unprotected ones.
+func ChunkPrefix(config *cacheconfig.Config, shortToken, projectId string, protected bool) string {
+	store := unprotectedChunkStore
+	if protected {
+		store = protectedChunkStore
+	}
+
+	return path.Join(config.GetPath(), cacheNamespace(config, shortToken), chunkStoreDir, "project", projectId, store) + "/"
+}
+
 func GetAdapter(config *cacheconfig.Config, timeout time.Duration, shortToken, projectId, key string, sharded bool) Adapter {
 	if config == nil {
 		return nopAdapter{}
@@ -35,13 +92,7 @@ func GetAdapter(config *cacheconfig.Config, timeout time.Duration, shortToken, p
 	}
 
 	// generate object path
-	// runners get their own namespace, unless they're shared, in which case the
-	// namespace is empty.
-	namespace := ""
-	if !config.GetShared() {
-		namespace = path.Join("runner", shortToken)
-	}
-	basePath := path.Join(config.GetPath(), namespace, "project", projectId)
+	basePath := path.Join(config.GetPath(), cacheNamespace(config, shortToken), "project", projectId)
 
 	// When sharded (i.e. FF_HASH_CACHE_KEYS is enabled), insert the first two
 	// hex characters of the key as an intermediate path component. This
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -289,3 +290,18 @@ func TestGenerateObjectName(t *testing.T) {
 		})
 	}
 }
+
+func TestChunkPrefix(t *testing.T) {
+	config := &cacheconfig.Config{Path: "cache"}
+
+	protected := ChunkPrefix(config, "longtoken", "10", true)
+	unprotected := ChunkPrefix(config, "longtoken", "10", false)
+
+	assert.Equal(t, "cache/runner/longtoken/chunks/project/10/protected/", protected)
+	assert.Equal(t, "cache/runner/longtoken/chunks/project/10/unprotected/", unprotected)
+	assert.False(t, strings.HasPrefix(protected, unprotected), "the credentials of a chunk store don't cover the other")
+	assert.False(t, strings.HasPrefix(unprotected, protected), "the credentials of a chunk store don't cover the other")
+
+	config.Shared = true
+	assert.Equal(t, "cache/chunks/project/10/unprotected/", ChunkPrefix(config, "longtoken", "10", false))
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7/pkg/s3utils"
 	"github.com/sirupsen/logrus"
@@ -19,6 +20,9 @@ type Config struct {
 	S3    *CacheS3Config    `toml:"s3,omitempty" json:"s3,omitempty" namespace:"s3"`
 	GCS   *CacheGCSConfig   `toml:"gcs,omitempty" json:"gcs,omitempty" namespace:"gcs"`
 	Azure *CacheAzureConfig `toml:"azure,omitempty" json:"azure,omitempty" namespace:"azure"`
+	Local *CacheLocalConfig `toml:"local,omitempty" json:"local,omitempty" namespace:"local"`
+
+	Prune *CachePruneConfig `toml:"prune,omitempty" json:"prune,omitempty" namespace:"prune"`
 }
 
 func (c *Config) GetPath() string {
@@ -189,3 +193,30 @@ type CacheAzureConfig struct {
 	ContainerName string `toml:"ContainerName,omitempty" long:"container-name" env:"CACHE_AZURE_CONTAINER_NAME" description:"Name of the Azure container where cache will be stored"`
 	StorageDomain string `toml:"StorageDomain,omitempty" long:"storage-domain" env:"CACHE_AZURE_STORAGE_DOMAIN" description:"Domain name of the Azure storage (e.g. blob.core.windows.net)"`
 }
+
+type CacheLocalConfig struct {
+	ServerAddress string `toml:"ServerAddress,omitempty" long:"server-address" env:"CACHE_LOCAL_SERVER_ADDRESS" description:"A host:port of the runner's local cache server ([cache_server] section)"`
+	Insecure      bool   `toml:"Insecure,omitempty" long:"insecure" env:"CACHE_LOCAL_INSECURE" description:"Use insecure mode (without https)"`
+	Secret        string `toml:"Secret,omitempty" long:"secret" env:"CACHE_LOCAL_SECRET" description:"Key used to sign cache URLs, must match the [cache_server] secret"`
+}
+
+func (c *CacheLocalConfig) GetEndpoint() string {
+	if c.ServerAddress == "" {
+		return ""
+	}
+
+	scheme := "https"
+	if c.Insecure {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, c.ServerAddress)
+}
+
+type CachePruneConfig struct {
+	MaxAge          time.Duration `toml:"MaxAge,omitempty" long:"max-age" env:"CACHE_PRUNE_MAX_AGE" description:"Delete cache objects not modified for longer than this duration (e.g. '720h')"`
+	MaxTotalSize    int64         `toml:"MaxTotalSize,omitempty" long:"max-total-size" env:"CACHE_PRUNE_MAX_TOTAL_SIZE" description:"Delete the least recently modified cache objects until the cache uses at most this many bytes"`
+	KeepLast        int           `toml:"KeepLast,omitempty" long:"keep-last" env:"CACHE_PRUNE_KEEP_LAST" description:"Never delete the most recently modified objects of each cache key"`
+	Interval        time.Duration `toml:"Interval,omitempty" long:"interval" env:"CACHE_PRUNE_INTERVAL" description:"How often 'gitlab-runner run' prunes the cache. Periodic pruning is disabled when not set"`
+	AllowBucketRoot bool          `toml:"AllowBucketRoot,omitempty" long:"allow-bucket-root" env:"CACHE_PRUNE_ALLOW_BUCKET_ROOT" description:"Allow pruning a shared cache without Path, which considers every object of the bucket"`
+}
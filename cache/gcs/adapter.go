@@ -2,6 +2,7 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,6 +11,8 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 
 	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
@@ -115,6 +118,75 @@ func (a *gcsAdapter) presignURL(ctx context.Context, method string, contentType
 	return URL
 }
 
+func (a *gcsAdapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	if a.config.BucketName == "" {
+		return nil, fmt.Errorf("BucketName can't be empty")
+	}
+
+	client, err := a.newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		return nil, fmt.Errorf("selecting object attributes: %w", err)
+	}
+
+	var objects []cache.ObjectInfo
+	it := client.Bucket(a.config.BucketName).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing GCS objects: %w", err)
+		}
+
+		objects = append(objects, cache.ObjectInfo{Name: attrs.Name, Size: attrs.Size, LastModified: attrs.Updated})
+	}
+
+	return objects, nil
+}
+
+func (a *gcsAdapter) DeleteObject(ctx context.Context, name string) error {
+	if a.config.BucketName == "" {
+		return fmt.Errorf("BucketName can't be empty")
+	}
+
+	client, err := a.newClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Bucket(a.config.BucketName).Object(name).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("deleting GCS object: %w", err)
+	}
+
+	return nil
+}
+
+// newClient creates an authenticated storage client. Unlike presigning,
+// listing and deleting objects can't be done with just the access ID and
+// private key, so the credentials file or the instance credentials are used.
+func (a *gcsAdapter) newClient(ctx context.Context) (*storage.Client, error) {
+	var options []option.ClientOption
+	if a.config.CredentialsFile != "" {
+		options = append(options, option.WithCredentialsFile(a.config.CredentialsFile)) // nolint:staticcheck
+	}
+
+	client, err := storage.NewClient(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage client: %w", err)
+	}
+
+	return client, nil
+}
+
 func New(config *cacheconfig.Config, timeout time.Duration, objectName string) (cache.Adapter, error) {
 	gcs := config.GCS
 	if gcs == nil {
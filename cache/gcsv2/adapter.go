@@ -2,6 +2,7 @@ package gcsv2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"gitlab.com/gitlab-org/gitlab-runner/cache"
@@ -78,23 +80,9 @@ func (a *gcsAdapter) presignURL(ctx context.Context, method string, contentType
 		return nil, fmt.Errorf("config BucketName cannot be empty")
 	}
 
-	var options []option.ClientOption
-	switch {
-	case a.config.CredentialsFile != "":
-		options = append(options, option.WithCredentialsFile(a.config.CredentialsFile)) // nolint:staticcheck
-	case a.config.AccessID != "" || a.config.PrivateKey != "":
-		// if providing accessID / privateKey for signing, then we don't need the
-		// storage client to authenticate
-		options = append(options, option.WithoutAuthentication())
-	}
-
-	if a.config.UniverseDomain != "" {
-		options = append(options, option.WithUniverseDomain(a.config.UniverseDomain))
-	}
-
-	client, err := storage.NewClient(ctx, options...)
+	client, err := a.newClient(ctx, true)
 	if err != nil {
-		return nil, fmt.Errorf("creating storage client: %w", err)
+		return nil, err
 	}
 	defer client.Close()
 
@@ -131,6 +119,84 @@ func (a *gcsAdapter) presignURL(ctx context.Context, method string, contentType
 	return u, nil
 }
 
+func (a *gcsAdapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	if a.config.BucketName == "" {
+		return nil, fmt.Errorf("config BucketName cannot be empty")
+	}
+
+	client, err := a.newClient(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		return nil, fmt.Errorf("selecting object attributes: %w", err)
+	}
+
+	var objects []cache.ObjectInfo
+	it := client.Bucket(a.config.BucketName).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing GCS objects: %w", err)
+		}
+
+		objects = append(objects, cache.ObjectInfo{Name: attrs.Name, Size: attrs.Size, LastModified: attrs.Updated})
+	}
+
+	return objects, nil
+}
+
+func (a *gcsAdapter) DeleteObject(ctx context.Context, name string) error {
+	if a.config.BucketName == "" {
+		return fmt.Errorf("config BucketName cannot be empty")
+	}
+
+	client, err := a.newClient(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Bucket(a.config.BucketName).Object(name).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("deleting GCS object: %w", err)
+	}
+
+	return nil
+}
+
+// newClient creates a storage client. When signOnly is set, the client is
+// only used to sign URLs and doesn't need to authenticate if the access ID
+// and private key are configured.
+func (a *gcsAdapter) newClient(ctx context.Context, signOnly bool) (*storage.Client, error) {
+	var options []option.ClientOption
+	switch {
+	case a.config.CredentialsFile != "":
+		options = append(options, option.WithCredentialsFile(a.config.CredentialsFile)) // nolint:staticcheck
+	case signOnly && (a.config.AccessID != "" || a.config.PrivateKey != ""):
+		// if providing accessID / privateKey for signing, then we don't need the
+		// storage client to authenticate
+		options = append(options, option.WithoutAuthentication())
+	}
+
+	if a.config.UniverseDomain != "" {
+		options = append(options, option.WithUniverseDomain(a.config.UniverseDomain))
+	}
+
+	client, err := storage.NewClient(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage client: %w", err)
+	}
+
+	return client, nil
+}
+
 func New(config *cacheconfig.Config, timeout time.Duration, objectName string) (cache.Adapter, error) {
 	gcs := config.GCS
 	if gcs == nil {
@@ -3,12 +3,17 @@
 package gcsv2
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
 )
 
@@ -169,3 +174,72 @@ func TestAdapter(t *testing.T) {
 		})
 	}
 }
+
+// newFakeStorageServer emulates the subset of the GCS JSON API used to list
+// and delete objects and points the storage client at it.
+func newFakeStorageServer(t *testing.T, bucket string, objects map[string]string) {
+	t.Helper()
+
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objectsPath := "/storage/v1/b/" + bucket + "/o"
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == objectsPath:
+			var items []map[string]string
+			for name, content := range objects {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{
+						"name":    name,
+						"size":    strconv.Itoa(len(content)),
+						"updated": updated.Format(time.RFC3339),
+					})
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"kind": "storage#objects", "items": items})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, objectsPath+"/"):
+			name := strings.TrimPrefix(r.URL.Path, objectsPath+"/")
+			if _, ok := objects[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": http.StatusNotFound, "message": "No such object"}})
+				return
+			}
+			delete(objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Setenv("STORAGE_EMULATOR_HOST", ts.Listener.Addr().String())
+}
+
+func TestListAndDeleteObjects(t *testing.T) {
+	objects := map[string]string{
+		"runner/a/project/1/key": "content",
+		"runner/b/project/1/key": "other content",
+	}
+	newFakeStorageServer(t, "test", objects)
+
+	adapter, err := New(&cacheconfig.Config{GCS: &cacheconfig.CacheGCSConfig{BucketName: "test"}}, time.Minute, "")
+	require.NoError(t, err)
+	manager, ok := adapter.(cache.ObjectManager)
+	require.True(t, ok)
+
+	listed, err := manager.ListObjects(t.Context(), "runner/a/")
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ObjectInfo{
+		{Name: "runner/a/project/1/key", Size: 7, LastModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, listed)
+
+	require.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"))
+	assert.NotContains(t, objects, "runner/a/project/1/key")
+
+	assert.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"), "deleting a missing object succeeds")
+
+	adapter, err = New(&cacheconfig.Config{GCS: &cacheconfig.CacheGCSConfig{}}, time.Minute, "")
+	require.NoError(t, err)
+	_, err = adapter.(cache.ObjectManager).ListObjects(t.Context(), "")
+	assert.EqualError(t, err, "config BucketName cannot be empty")
+}
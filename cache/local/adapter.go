@@ -0,0 +1,189 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+)
+
+type localAdapter struct {
+	timeout                time.Duration
+	config                 *cacheconfig.CacheLocalConfig
+	objectName             string
+	maxUploadedArchiveSize int64
+	metadata               map[string]string
+}
+
+func (a *localAdapter) GetDownloadURL(_ context.Context) cache.PresignedURL {
+	u, err := a.presignURL(http.MethodGet)
+	if err != nil {
+		logrus.WithError(err).Error("error while generating local cache pre-signed URL")
+		return cache.PresignedURL{}
+	}
+
+	return cache.PresignedURL{URL: u}
+}
+
+func (a *localAdapter) GetHeadURL(_ context.Context) cache.PresignedURL {
+	u, err := a.presignURL(http.MethodHead)
+	if err != nil {
+		logrus.WithError(err).Error("error while generating local cache pre-signed URL")
+		return cache.PresignedURL{}
+	}
+
+	return cache.PresignedURL{URL: u}
+}
+
+func (a *localAdapter) GetUploadURL(_ context.Context) cache.PresignedURL {
+	u, err := a.presignURL(http.MethodPut)
+	if err != nil {
+		logrus.WithError(err).Error("error while generating local cache pre-signed URL")
+		return cache.PresignedURL{}
+	}
+
+	return cache.PresignedURL{URL: u, Headers: a.GetUploadHeaders()}
+}
+
+func (a *localAdapter) GetUploadHeaders() http.Header {
+	if len(a.metadata) == 0 {
+		return nil
+	}
+
+	headers := http.Header{}
+	for k, v := range a.metadata {
+		headers.Set(metadataHeaderPrefix+k, v)
+	}
+
+	return headers
+}
+
+func (a *localAdapter) GetGoCloudURL(_ context.Context, _ bool) (cache.GoCloudURL, error) {
+	return cache.GoCloudURL{}, nil
+}
+
+func (a *localAdapter) WithMetadata(metadata map[string]string) {
+	a.metadata = metadata
+}
+
+// ListObjects asks the cache server for the objects stored under prefix.
+func (a *localAdapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	u, err := a.presignObjectURL(listMethod, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set(listParam, "true")
+	u.RawQuery = q.Encode()
+
+	resp, err := a.do(ctx, http.MethodGet, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var objects []cache.ObjectInfo
+	if err := json.NewDecoder(resp.Body).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("decoding object list: %w", err)
+	}
+
+	return objects, nil
+}
+
+func (a *localAdapter) DeleteObject(ctx context.Context, name string) error {
+	u, err := a.presignObjectURL(http.MethodDelete, name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.do(ctx, http.MethodDelete, u)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (a *localAdapter) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("cache server responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+func (a *localAdapter) presignURL(method string) (*url.URL, error) {
+	return a.presignObjectURL(method, a.objectName)
+}
+
+func (a *localAdapter) presignObjectURL(method, objectName string) (*url.URL, error) {
+	endpoint := a.config.GetEndpoint()
+	if endpoint == "" {
+		return nil, fmt.Errorf("config ServerAddress cannot be empty")
+	}
+
+	if a.config.Secret == "" {
+		return nil, fmt.Errorf("config Secret cannot be empty")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing server address: %w", err)
+	}
+
+	var maxSize int64
+	if method == http.MethodPut {
+		maxSize = a.maxUploadedArchiveSize
+	}
+
+	// the server strips URLPrefix from the path and verifies the signature
+	// against the remainder; path.Join would drop a prefix's trailing "/"
+	objectName = strings.TrimLeft(objectName, "/")
+	u.Path = URLPrefix + objectName
+	u.RawQuery = signedQuery([]byte(a.config.Secret), method, objectName, time.Now().Add(a.timeout), maxSize).Encode()
+
+	return u, nil
+}
+
+func New(config *cacheconfig.Config, timeout time.Duration, objectName string) (cache.Adapter, error) {
+	local := config.Local
+	if local == nil {
+		return nil, fmt.Errorf("missing local configuration")
+	}
+
+	return &localAdapter{
+		config:                 local,
+		timeout:                timeout,
+		objectName:             objectName,
+		maxUploadedArchiveSize: config.MaxUploadedArchiveSize,
+	}, nil
+}
+
+func init() {
+	err := cache.Factories().Register("local", New)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build !integration
+
+package local
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("no config", func(t *testing.T) {
+		adapter, err := New(&cacheconfig.Config{}, time.Second, "key")
+		require.ErrorContains(t, err, "missing local configuration")
+		require.Nil(t, adapter)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		adapter, err := New(&cacheconfig.Config{Local: &cacheconfig.CacheLocalConfig{}}, time.Second, "key")
+		require.NoError(t, err)
+		require.NotNil(t, adapter)
+	})
+}
+
+func TestAdapter(t *testing.T) {
+	tests := map[string]struct {
+		config          *cacheconfig.CacheLocalConfig
+		maxSize         int64
+		metadata        map[string]string
+		expectURL       bool
+		expectedScheme  string
+		expectedHeaders http.Header
+	}{
+		"no server address": {
+			config: &cacheconfig.CacheLocalConfig{Secret: "secret"},
+		},
+		"no secret": {
+			config: &cacheconfig.CacheLocalConfig{ServerAddress: "cache.local:8093"},
+		},
+		"https": {
+			config:         &cacheconfig.CacheLocalConfig{ServerAddress: "cache.local:8093", Secret: "secret"},
+			expectURL:      true,
+			expectedScheme: "https",
+		},
+		"insecure with metadata and max size": {
+			config:         &cacheconfig.CacheLocalConfig{ServerAddress: "cache.local:8093", Secret: "secret", Insecure: true},
+			maxSize:        100,
+			metadata:       map[string]string{"foo": "bar"},
+			expectURL:      true,
+			expectedScheme: "http",
+			expectedHeaders: http.Header{
+				"X-Runner-Meta-Foo": []string{"bar"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			adapter, err := New(&cacheconfig.Config{Local: tc.config, MaxUploadedArchiveSize: tc.maxSize}, time.Minute, "runner/abc/project/1/key")
+			require.NoError(t, err)
+			adapter.WithMetadata(tc.metadata)
+
+			download := adapter.GetDownloadURL(t.Context())
+			head := adapter.GetHeadURL(t.Context())
+			upload := adapter.GetUploadURL(t.Context())
+
+			if !tc.expectURL {
+				assert.Nil(t, download.URL)
+				assert.Nil(t, head.URL)
+				assert.Nil(t, upload.URL)
+				return
+			}
+
+			for method, u := range map[string]*http.Request{
+				http.MethodGet:  {URL: download.URL},
+				http.MethodHead: {URL: head.URL},
+				http.MethodPut:  {URL: upload.URL},
+			} {
+				require.NotNil(t, u.URL, method)
+				assert.Equal(t, tc.expectedScheme, u.URL.Scheme)
+				assert.Equal(t, "cache.local:8093", u.URL.Host)
+				assert.Equal(t, "/cache/runner/abc/project/1/key", u.URL.Path)
+
+				maxSize, err := verifyQuery([]byte(tc.config.Secret), method, "runner/abc/project/1/key", u.URL.Query(), time.Now())
+				require.NoError(t, err, method)
+				if method == http.MethodPut {
+					assert.Equal(t, tc.maxSize, maxSize)
+				} else {
+					assert.Zero(t, maxSize)
+				}
+			}
+
+			assert.Equal(t, tc.expectedHeaders, upload.Headers)
+
+			goCloudURL, err := adapter.GetGoCloudURL(t.Context(), true)
+			assert.NoError(t, err)
+			assert.Nil(t, goCloudURL.URL)
+		})
+	}
+}
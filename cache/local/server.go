@@ -0,0 +1,372 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+)
+
+// metadataDir is the directory, relative to the server's root, in which
+// object metadata is stored. Object names within it are rejected.
+const metadataDir = ".metadata"
+
+// Server serves cache objects stored in a directory to holders of URLs
+// presigned by the local cache adapter.
+type Server struct {
+	dir    string
+	secret []byte
+	log    logrus.FieldLogger
+
+	now func() time.Time
+}
+
+func NewServer(dir, secret string, logger logrus.FieldLogger) (*Server, error) {
+	if dir == "" {
+		return nil, errors.New("cache server dir cannot be empty")
+	}
+
+	if secret == "" {
+		return nil, errors.New("cache server secret cannot be empty")
+	}
+
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating cache server dir: %w", err)
+	}
+
+	return &Server{
+		dir:    dir,
+		secret: []byte(secret),
+		log:    logger,
+		now:    time.Now,
+	}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Query().Has(listParam) {
+		s.serveList(w, r)
+		return
+	}
+
+	objectName, ok := objectNameFromPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	logger := s.log.WithFields(logrus.Fields{"method": r.Method, "object": objectName})
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxSize, err := verifyQuery(s.secret, r.Method, objectName, r.URL.Query(), s.now())
+	if err != nil {
+		logger.WithError(err).Warn("Rejecting cache request")
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.storeObject(w, r, logger, objectName, maxSize)
+	case http.MethodDelete:
+		s.deleteObject(w, logger, objectName)
+	default:
+		s.serveObject(w, r, logger, objectName)
+	}
+}
+
+// objectNameFromPath extracts the object name from a request path, refusing
+// names that would escape the server's directory or address its metadata.
+func objectNameFromPath(p string) (string, bool) {
+	name, ok := strings.CutPrefix(p, URLPrefix)
+	if !ok || name == "" {
+		return "", false
+	}
+
+	if path.Clean("/"+name) != "/"+name {
+		return "", false
+	}
+
+	if name == metadataDir || strings.HasPrefix(name, metadataDir+"/") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// prefixFromPath extracts the listing prefix from a request path. Unlike
+// object names, the prefix may be empty or end with a "/".
+func prefixFromPath(p string) (string, bool) {
+	prefix, ok := strings.CutPrefix(p, URLPrefix)
+	if !ok {
+		return "", false
+	}
+
+	if prefix == "" {
+		return "", true
+	}
+
+	_, ok = objectNameFromPath(URLPrefix + strings.TrimSuffix(prefix, "/"))
+	return prefix, ok
+}
+
+func (s *Server) objectPath(objectName string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(objectName))
+}
+
+func (s *Server) metadataPath(objectName string) string {
+	return filepath.Join(s.dir, metadataDir, filepath.FromSlash(objectName)+".json")
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, logger logrus.FieldLogger, objectName string) {
+	f, err := os.Open(s.objectPath(objectName))
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Opening cache object")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	for k, v := range s.readMetadata(logger, objectName) {
+		w.Header().Set(metadataHeaderPrefix+k, v)
+	}
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(fi.ModTime().UnixNano(), 16)+"-"+strconv.FormatInt(fi.Size(), 16)))
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request) {
+	prefix, ok := prefixFromPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	logger := s.log.WithFields(logrus.Fields{"method": listMethod, "prefix": prefix})
+
+	if _, err := verifyQuery(s.secret, listMethod, prefix, r.URL.Query(), s.now()); err != nil {
+		logger.WithError(err).Warn("Rejecting cache request")
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	objects, err := s.listObjects(prefix)
+	if err != nil {
+		logger.WithError(err).Error("Listing cache objects")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(objects); err != nil {
+		logger.WithError(err).Warn("Writing cache object list")
+	}
+}
+
+// listObjects walks the server's directory for objects whose name starts
+// with prefix, skipping metadata and in-progress uploads.
+func (s *Server) listObjects(prefix string) ([]cache.ObjectInfo, error) {
+	objects := []cache.ObjectInfo{}
+
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if name == "." {
+				return nil
+			}
+			// skip directories that can neither contain nor be contained by the prefix
+			if name == metadataDir || (!strings.HasPrefix(name+"/", prefix) && !strings.HasPrefix(prefix, name+"/")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || !strings.HasPrefix(name, prefix) || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, cache.ObjectInfo{Name: name, Size: fi.Size(), LastModified: fi.ModTime()})
+		return nil
+	})
+
+	return objects, err
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, logger logrus.FieldLogger, objectName string) {
+	err := os.Remove(s.objectPath(objectName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.WithError(err).Error("Deleting cache object")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.Remove(s.metadataPath(objectName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.WithError(err).Warn("Deleting cache object metadata")
+	}
+
+	logger.Debug("Deleted cache object")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) storeObject(w http.ResponseWriter, r *http.Request, logger logrus.FieldLogger, objectName string, maxSize int64) {
+	if maxSize > 0 {
+		if r.ContentLength > maxSize {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+
+	err := s.writeObject(objectName, r.Body, metadataFromHeaders(r.Header))
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+	case err != nil:
+		logger.WithError(err).Error("Storing cache object")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	default:
+		logger.Debug("Stored cache object")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// writeObject writes the object to a temporary file next to its final
+// location and renames it into place, so that concurrent readers, possibly
+// on other hosts sharing the directory, never observe a partial object.
+func (s *Server) writeObject(objectName string, body io.Reader, metadata map[string]string) error {
+	target := s.objectPath(objectName)
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return fmt.Errorf("creating object dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing object: %w", err)
+	}
+
+	if err := s.writeMetadata(objectName, metadata); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("renaming object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) writeMetadata(objectName string, metadata map[string]string) error {
+	p := s.metadataPath(objectName)
+	if len(metadata) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing metadata: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("encoding metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("creating metadata dir: %w", err)
+	}
+
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		return fmt.Errorf("writing metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) readMetadata(logger logrus.FieldLogger, objectName string) map[string]string {
+	data, err := os.ReadFile(s.metadataPath(objectName))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.WithError(err).Warn("Reading cache object metadata")
+		}
+		return nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		logger.WithError(err).Warn("Decoding cache object metadata")
+		return nil
+	}
+
+	return metadata
+}
+
+func metadataFromHeaders(headers http.Header) map[string]string {
+	metadata := map[string]string{}
+	for k := range headers {
+		key, ok := strings.CutPrefix(http.CanonicalHeaderKey(k), metadataHeaderPrefix)
+		if !ok || key == "" {
+			continue
+		}
+		metadata[strings.ToLower(key)] = headers.Get(k)
+	}
+
+	return metadata
+}
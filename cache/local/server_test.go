@@ -0,0 +1,250 @@
+//go:build !integration
+
+package local
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *cacheconfig.Config) {
+	t.Helper()
+
+	srv, err := NewServer(t.TempDir(), "secret", nil)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	config := &cacheconfig.Config{
+		Local: &cacheconfig.CacheLocalConfig{
+			ServerAddress: strings.TrimPrefix(ts.URL, "http://"),
+			Insecure:      true,
+			Secret:        "secret",
+		},
+	}
+
+	return ts, config
+}
+
+func doRequest(t *testing.T, method, u string, body io.Reader, headers http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, u, body)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func TestNewServer(t *testing.T) {
+	_, err := NewServer("", "secret", nil)
+	assert.ErrorContains(t, err, "dir cannot be empty")
+
+	_, err = NewServer(t.TempDir(), "", nil)
+	assert.ErrorContains(t, err, "secret cannot be empty")
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	_, config := newTestServer(t)
+
+	adapter, err := New(config, time.Minute, "project/1/key")
+	require.NoError(t, err)
+	adapter.WithMetadata(map[string]string{"cachekey": "key"})
+
+	head := adapter.GetHeadURL(t.Context())
+	resp := doRequest(t, http.MethodHead, head.URL.String(), nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	upload := adapter.GetUploadURL(t.Context())
+	resp = doRequest(t, http.MethodPut, upload.URL.String(), strings.NewReader("cache content"), upload.Headers)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodHead, head.URL.String(), nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+	assert.Equal(t, "key", resp.Header.Get("X-Runner-Meta-Cachekey"))
+
+	download := adapter.GetDownloadURL(t.Context())
+	resp = doRequest(t, http.MethodGet, download.URL.String(), nil, http.Header{"Range": []string{"bytes=0-4"}})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 0-4/13", resp.Header.Get("Content-Range"))
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "cache", string(data))
+
+	// a URL signed for GET cannot be used to upload
+	resp = doRequest(t, http.MethodPut, download.URL.String(), strings.NewReader("tampered"), nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	ts, config := newTestServer(t)
+
+	adapter, err := New(config, time.Minute, "project/1/key")
+	require.NoError(t, err)
+	download := adapter.GetDownloadURL(t.Context()).URL
+
+	tests := map[string]struct {
+		method         string
+		url            string
+		expectedStatus int
+	}{
+		"unsigned": {
+			method:         http.MethodGet,
+			url:            ts.URL + "/cache/project/1/key",
+			expectedStatus: http.StatusForbidden,
+		},
+		"signed for another object": {
+			method:         http.MethodGet,
+			url:            ts.URL + "/cache/project/2/key?" + download.RawQuery,
+			expectedStatus: http.StatusForbidden,
+		},
+		"unsupported method": {
+			method:         http.MethodPatch,
+			url:            download.String(),
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		"signed for another method": {
+			method:         http.MethodDelete,
+			url:            download.String(),
+			expectedStatus: http.StatusForbidden,
+		},
+		"list signed for an object": {
+			method:         http.MethodGet,
+			url:            download.String() + "&" + listParam + "=true",
+			expectedStatus: http.StatusForbidden,
+		},
+		"outside of prefix": {
+			method:         http.MethodGet,
+			url:            ts.URL + "/other/project/1/key?" + download.RawQuery,
+			expectedStatus: http.StatusNotFound,
+		},
+		"metadata dir": {
+			method:         http.MethodGet,
+			url:            ts.URL + "/cache/.metadata/project/1/key.json?" + download.RawQuery,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := doRequest(t, tc.method, tc.url, nil, nil)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestServerListAndDeleteObjects(t *testing.T) {
+	_, config := newTestServer(t)
+
+	for _, name := range []string{"runner/a/project/1/key", "runner/a/project/2/key", "runner/b/project/1/key"} {
+		adapter, err := New(config, time.Minute, name)
+		require.NoError(t, err)
+		adapter.WithMetadata(map[string]string{"cachekey": "key"})
+
+		upload := adapter.GetUploadURL(t.Context())
+		resp := doRequest(t, http.MethodPut, upload.URL.String(), strings.NewReader(name), upload.Headers)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	adapter, err := New(config, time.Minute, "")
+	require.NoError(t, err)
+	manager, ok := adapter.(cache.ObjectManager)
+	require.True(t, ok)
+
+	listNames := func(prefix string) []string {
+		objects, err := manager.ListObjects(t.Context(), prefix)
+		require.NoError(t, err)
+
+		var names []string
+		for _, obj := range objects {
+			assert.Equal(t, int64(len(obj.Name)), obj.Size)
+			assert.WithinDuration(t, time.Now(), obj.LastModified, time.Minute)
+			names = append(names, obj.Name)
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{"runner/a/project/1/key", "runner/a/project/2/key", "runner/b/project/1/key"}, listNames(""))
+	assert.ElementsMatch(t, []string{"runner/a/project/1/key", "runner/a/project/2/key"}, listNames("runner/a/"))
+	assert.Empty(t, listNames("runner/c/"))
+
+	require.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"))
+	require.NoError(t, manager.DeleteObject(t.Context(), "runner/a/project/1/key"), "deleting a missing object succeeds")
+	assert.ElementsMatch(t, []string{"runner/a/project/2/key"}, listNames("runner/a/"))
+
+	_, err = manager.ListObjects(t.Context(), "../")
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestServerExpiredURL(t *testing.T) {
+	srv, err := NewServer(t.TempDir(), "secret", nil)
+	require.NoError(t, err)
+	srv.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	q := signedQuery([]byte("secret"), http.MethodGet, "key", time.Now().Add(time.Minute), 0)
+	req := httptest.NewRequest(http.MethodGet, "/cache/key?"+q.Encode(), nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "request has expired")
+}
+
+func TestServerMaxUploadSize(t *testing.T) {
+	_, config := newTestServer(t)
+	config.MaxUploadedArchiveSize = 4
+
+	adapter, err := New(config, time.Minute, "key")
+	require.NoError(t, err)
+
+	upload := adapter.GetUploadURL(t.Context())
+	resp := doRequest(t, http.MethodPut, upload.URL.String(), strings.NewReader("too large"), nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodHead, adapter.GetHeadURL(t.Context()).URL.String(), nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestObjectNameFromPath(t *testing.T) {
+	tests := map[string]struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		"valid":            {path: "/cache/runner/x/project/1/key", expected: "runner/x/project/1/key", ok: true},
+		"no prefix":        {path: "/runner/x/key"},
+		"empty":            {path: "/cache/"},
+		"traversal":        {path: "/cache/../../etc/passwd"},
+		"inner traversal":  {path: "/cache/project/../key"},
+		"trailing slash":   {path: "/cache/project/"},
+		"metadata":         {path: "/cache/.metadata"},
+		"metadata subpath": {path: "/cache/.metadata/key.json"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			name, ok := objectNameFromPath(tc.path)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, name)
+		})
+	}
+}
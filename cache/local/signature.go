@@ -0,0 +1,87 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// URLPrefix is the path under which the cache server serves objects.
+	URLPrefix = "/cache/"
+
+	expiresParam   = "X-Runner-Expires"
+	maxSizeParam   = "X-Runner-Max-Size"
+	signatureParam = "X-Runner-Signature"
+	listParam      = "X-Runner-List"
+
+	// listMethod is the method signed into URLs that list the objects under
+	// a prefix. Listing requests are sent as GET with the listParam set.
+	listMethod = "LIST"
+
+	metadataHeaderPrefix = "X-Runner-Meta-"
+)
+
+var (
+	errMissingSignature = fmt.Errorf("missing %s parameter", signatureParam)
+	errInvalidSignature = fmt.Errorf("invalid %s parameter", signatureParam)
+	errExpired          = fmt.Errorf("request has expired")
+)
+
+// signature computes the HMAC-SHA256 of the request properties that a
+// presigned URL grants access to.
+func signature(secret []byte, method, objectName string, expires, maxSize int64) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d\n%d", method, objectName, expires, maxSize)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedQuery returns the query parameters of a presigned URL.
+func signedQuery(secret []byte, method, objectName string, expires time.Time, maxSize int64) url.Values {
+	q := url.Values{}
+	q.Set(expiresParam, strconv.FormatInt(expires.Unix(), 10))
+	if maxSize > 0 {
+		q.Set(maxSizeParam, strconv.FormatInt(maxSize, 10))
+	}
+	q.Set(signatureParam, signature(secret, method, objectName, expires.Unix(), maxSize))
+
+	return q
+}
+
+// verifyQuery checks the signature and expiry of a presigned URL's query
+// parameters and returns the maximum allowed upload size (0 for no limit).
+func verifyQuery(secret []byte, method, objectName string, q url.Values, now time.Time) (int64, error) {
+	sig := q.Get(signatureParam)
+	if sig == "" {
+		return 0, errMissingSignature
+	}
+
+	expires, err := strconv.ParseInt(q.Get(expiresParam), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s parameter: %w", expiresParam, err)
+	}
+
+	var maxSize int64
+	if raw := q.Get(maxSizeParam); raw != "" {
+		maxSize, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing %s parameter: %w", maxSizeParam, err)
+		}
+	}
+
+	expected := signature(secret, method, objectName, expires, maxSize)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return 0, errInvalidSignature
+	}
+
+	if now.Unix() > expires {
+		return 0, errExpired
+	}
+
+	return maxSize, nil
+}
@@ -296,6 +296,105 @@ func (_c *MockAdapter_WithMetadata_Call) RunAndReturn(run func(stringToString ma
 	return _c
 }
 
+// NewMockChunkStoreAdapter creates a new instance of MockChunkStoreAdapter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockChunkStoreAdapter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockChunkStoreAdapter {
+	mock := &MockChunkStoreAdapter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockChunkStoreAdapter is an autogenerated mock type for the ChunkStoreAdapter type
+type MockChunkStoreAdapter struct {
+	mock.Mock
+}
+
+type MockChunkStoreAdapter_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockChunkStoreAdapter) EXPECT() *MockChunkStoreAdapter_Expecter {
+	return &MockChunkStoreAdapter_Expecter{mock: &_m.Mock}
+}
+
+// GetChunkStoreGoCloudURL provides a mock function for the type MockChunkStoreAdapter
+func (_mock *MockChunkStoreAdapter) GetChunkStoreGoCloudURL(ctx context.Context, chunkPrefix string, upload bool) (GoCloudURL, error) {
+	ret := _mock.Called(ctx, chunkPrefix, upload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChunkStoreGoCloudURL")
+	}
+
+	var r0 GoCloudURL
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, bool) (GoCloudURL, error)); ok {
+		return returnFunc(ctx, chunkPrefix, upload)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, bool) GoCloudURL); ok {
+		r0 = returnFunc(ctx, chunkPrefix, upload)
+	} else {
+		r0 = ret.Get(0).(GoCloudURL)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = returnFunc(ctx, chunkPrefix, upload)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetChunkStoreGoCloudURL'
+type MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call struct {
+	*mock.Call
+}
+
+// GetChunkStoreGoCloudURL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - chunkPrefix string
+//   - upload bool
+func (_e *MockChunkStoreAdapter_Expecter) GetChunkStoreGoCloudURL(ctx interface{}, chunkPrefix interface{}, upload interface{}) *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call {
+	return &MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call{Call: _e.mock.On("GetChunkStoreGoCloudURL", ctx, chunkPrefix, upload)}
+}
+
+func (_c *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call) Run(run func(ctx context.Context, chunkPrefix string, upload bool)) *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 bool
+		if args[2] != nil {
+			arg2 = args[2].(bool)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call) Return(goCloudURL GoCloudURL, err error) *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call {
+	_c.Call.Return(goCloudURL, err)
+	return _c
+}
+
+func (_c *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call) RunAndReturn(run func(ctx context.Context, chunkPrefix string, upload bool) (GoCloudURL, error)) *MockChunkStoreAdapter_GetChunkStoreGoCloudURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockCredentialsAdapter creates a new instance of MockCredentialsAdapter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockCredentialsAdapter(t interface {
@@ -368,3 +467,155 @@ func (_c *MockCredentialsAdapter_GetCredentials_Call) RunAndReturn(run func() ma
 	_c.Call.Return(run)
 	return _c
 }
+
+// NewMockObjectManager creates a new instance of MockObjectManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockObjectManager(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockObjectManager {
+	mock := &MockObjectManager{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockObjectManager is an autogenerated mock type for the ObjectManager type
+type MockObjectManager struct {
+	mock.Mock
+}
+
+type MockObjectManager_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockObjectManager) EXPECT() *MockObjectManager_Expecter {
+	return &MockObjectManager_Expecter{mock: &_m.Mock}
+}
+
+// DeleteObject provides a mock function for the type MockObjectManager
+func (_mock *MockObjectManager) DeleteObject(ctx context.Context, name string) error {
+	ret := _mock.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteObject")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockObjectManager_DeleteObject_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteObject'
+type MockObjectManager_DeleteObject_Call struct {
+	*mock.Call
+}
+
+// DeleteObject is a helper method to define mock.On call
+//   - ctx context.Context
+//   - name string
+func (_e *MockObjectManager_Expecter) DeleteObject(ctx interface{}, name interface{}) *MockObjectManager_DeleteObject_Call {
+	return &MockObjectManager_DeleteObject_Call{Call: _e.mock.On("DeleteObject", ctx, name)}
+}
+
+func (_c *MockObjectManager_DeleteObject_Call) Run(run func(ctx context.Context, name string)) *MockObjectManager_DeleteObject_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockObjectManager_DeleteObject_Call) Return(err error) *MockObjectManager_DeleteObject_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockObjectManager_DeleteObject_Call) RunAndReturn(run func(ctx context.Context, name string) error) *MockObjectManager_DeleteObject_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListObjects provides a mock function for the type MockObjectManager
+func (_mock *MockObjectManager) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	ret := _mock.Called(ctx, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListObjects")
+	}
+
+	var r0 []ObjectInfo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) ([]ObjectInfo, error)); ok {
+		return returnFunc(ctx, prefix)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) []ObjectInfo); ok {
+		r0 = returnFunc(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]ObjectInfo)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockObjectManager_ListObjects_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListObjects'
+type MockObjectManager_ListObjects_Call struct {
+	*mock.Call
+}
+
+// ListObjects is a helper method to define mock.On call
+//   - ctx context.Context
+//   - prefix string
+func (_e *MockObjectManager_Expecter) ListObjects(ctx interface{}, prefix interface{}) *MockObjectManager_ListObjects_Call {
+	return &MockObjectManager_ListObjects_Call{Call: _e.mock.On("ListObjects", ctx, prefix)}
+}
+
+func (_c *MockObjectManager_ListObjects_Call) Run(run func(ctx context.Context, prefix string)) *MockObjectManager_ListObjects_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockObjectManager_ListObjects_Call) Return(objectInfos []ObjectInfo, err error) *MockObjectManager_ListObjects_Call {
+	_c.Call.Return(objectInfos, err)
+	return _c
+}
+
+func (_c *MockObjectManager_ListObjects_Call) RunAndReturn(run func(ctx context.Context, prefix string) ([]ObjectInfo, error)) *MockObjectManager_ListObjects_Call {
+	_c.Call.Return(run)
+	return _c
+}
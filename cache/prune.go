@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+)
+
+// ErrPruneBucketRoot is returned when pruning a shared cache without Path,
+// whose objects aren't distinguishable from the other objects of the bucket.
+var ErrPruneBucketRoot = errors.New("refusing to prune the whole bucket: set Path, or AllowBucketRoot to prune every object of the bucket")
+
+// PruneReason tells why an object was selected for deletion.
+type PruneReason string
+
+const (
+	PruneReasonMaxAge       PruneReason = "max-age"
+	PruneReasonMaxTotalSize PruneReason = "max-total-size"
+	PruneReasonUnreferenced PruneReason = "unreferenced"
+)
+
+// PrunePolicy describes which cache objects are deleted by Prune.
+type PrunePolicy struct {
+	// MaxAge is the age after which an object is deleted. Zero disables the
+	// check.
+	MaxAge time.Duration
+	// MaxTotalSize is the total size, in bytes, the objects under the prefix
+	// may use. The least recently modified objects are deleted until the
+	// total fits. Zero disables the check.
+	MaxTotalSize int64
+	// KeepLast is the number of most recently modified objects of every
+	// cache key that are never deleted, regardless of the other settings.
+	// MaxAge and MaxTotalSize apply to all the objects under the prefix.
+	KeepLast int
+	// AllowBucketRoot allows pruning with an empty prefix, that is every
+	// object of the bucket, including those the runner doesn't own.
+	AllowBucketRoot bool
+}
+
+// NewPrunePolicy returns the policy described by a cache prune configuration.
+func NewPrunePolicy(config *cacheconfig.CachePruneConfig) PrunePolicy {
+	if config == nil {
+		return PrunePolicy{}
+	}
+
+	return PrunePolicy{
+		MaxAge:       config.MaxAge,
+		MaxTotalSize: config.MaxTotalSize,
+		KeepLast:     config.KeepLast,
+
+		AllowBucketRoot: config.AllowBucketRoot,
+	}
+}
+
+// IsEmpty returns true when the policy would never delete any object.
+func (p PrunePolicy) IsEmpty() bool {
+	return p.MaxAge <= 0 && p.MaxTotalSize <= 0
+}
+
+// PruneCandidate is an object selected for deletion.
+type PruneCandidate struct {
+	ObjectInfo
+	Reason PruneReason
+	// Deleted is set once the object was successfully deleted.
+	Deleted bool
+}
+
+// PruneReport describes the outcome of a prune.
+type PruneReport struct {
+	Prefix  string
+	DryRun  bool
+	Kept    []ObjectInfo
+	Evicted []PruneCandidate
+}
+
+// KeptSize returns the total size of the objects left in place.
+func (r PruneReport) KeptSize() int64 {
+	var size int64
+	for _, obj := range r.Kept {
+		size += obj.Size
+	}
+
+	return size
+}
+
+// EvictedSize returns the total size of the objects selected for deletion.
+func (r PruneReport) EvictedSize() int64 {
+	var size int64
+	for _, obj := range r.Evicted {
+		size += obj.Size
+	}
+
+	return size
+}
+
+// Plan selects the objects to delete from those stored under prefix.
+//
+// Objects are first protected by KeepLast, then deleted when older than
+// MaxAge and finally, oldest first, until the remaining objects fit into
+// MaxTotalSize. The chunks of the chunked cache archives are left out, as
+// they are shared by the manifests of several cache keys: Prune deletes
+// those no longer referenced by any manifest instead.
+func (p PrunePolicy) Plan(prefix string, objects []ObjectInfo, now time.Time) PruneReport {
+	report := PruneReport{Prefix: prefix}
+
+	// newest first
+	sorted := slices.DeleteFunc(slices.Clone(objects), func(obj ObjectInfo) bool {
+		return isChunk(prefix, obj.Name)
+	})
+	slices.SortStableFunc(sorted, func(a, b ObjectInfo) int {
+		return cmp.Or(b.LastModified.Compare(a.LastModified), strings.Compare(a.Name, b.Name))
+	})
+
+	protected := make([]bool, len(sorted))
+	evicted := make([]PruneReason, len(sorted))
+
+	groups := map[string]int{}
+	var total int64
+	for i, obj := range sorted {
+		total += obj.Size
+
+		group := keyGroup(prefix, obj.Name)
+		if groups[group] < p.KeepLast {
+			protected[i] = true
+		}
+		groups[group]++
+	}
+
+	if p.MaxAge > 0 {
+		for i, obj := range sorted {
+			if !protected[i] && now.Sub(obj.LastModified) > p.MaxAge {
+				evicted[i] = PruneReasonMaxAge
+				total -= obj.Size
+			}
+		}
+	}
+
+	if p.MaxTotalSize > 0 {
+		for i := len(sorted) - 1; i >= 0 && total > p.MaxTotalSize; i-- {
+			if !protected[i] && evicted[i] == "" {
+				evicted[i] = PruneReasonMaxTotalSize
+				total -= sorted[i].Size
+			}
+		}
+	}
+
+	for i, obj := range sorted {
+		if evicted[i] == "" {
+			report.Kept = append(report.Kept, obj)
+			continue
+		}
+
+		report.Evicted = append(report.Evicted, PruneCandidate{ObjectInfo: obj, Reason: evicted[i]})
+	}
+
+	return report
+}
+
+// keyGroup returns the group an object belongs to for the KeepLast policy:
+// the object's cache key, that is its path without the archive filename.
+// The object of a cache key is stored at the key itself, so only the shard
+// directory added by FF_HASH_CACHE_KEYS is dropped, letting the sharded and
+// unsharded objects of the same key share their group.
+func keyGroup(prefix, name string) string {
+	parts := strings.Split(strings.TrimPrefix(name, prefix), "/")
+	if len(parts) >= 4 && parts[0] == "project" && len(parts[2]) == 2 && strings.HasPrefix(parts[3], parts[2]) {
+		parts = slices.Delete(parts, 2, 3)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// isChunk returns true when the object is stored in the chunk store of a
+// project, under ChunkPrefix
+func isChunk(prefix, name string) bool {
+	parts := strings.Split(strings.TrimPrefix(name, prefix), "/")
+
+	return len(parts) >= 4 && parts[0] == chunkStoreDir && parts[1] == "project"
+}
+
+// PrunePrefix returns the prefix under which the cache objects of a runner
+// are stored. Runners sharing their cache share the prefix.
+func PrunePrefix(config *cacheconfig.Config, shortToken string) string {
+	prefix := path.Join(config.GetPath(), cacheNamespace(config, shortToken))
+	if prefix == "" || prefix == "." {
+		return ""
+	}
+
+	return prefix + "/"
+}
+
+// GetObjectManager returns the ObjectManager of the configured cache adapter.
+func GetObjectManager(config *cacheconfig.Config, timeout time.Duration) (ObjectManager, error) {
+	if config == nil {
+		return nil, errors.New("cache is not configured")
+	}
+
+	adapter, err := createAdapter(config, timeout, "")
+	if err != nil {
+		return nil, err
+	}
+
+	manager, ok := adapter.(ObjectManager)
+	if !ok {
+		return nil, fmt.Errorf("cache adapter %q doesn't support listing and deleting objects", config.Type)
+	}
+
+	return manager, nil
+}
+
+// Prune lists the objects stored under prefix, selects those to delete
+// according to policy and, unless dryRun is set, deletes them. When the
+// manager is an ObjectReader, the chunks no longer referenced by the
+// remaining manifests are deleted too. Deletion continues past failures; all
+// of them are returned joined together. An empty prefix is refused with
+// ErrPruneBucketRoot unless the policy allows it.
+func Prune(ctx context.Context, manager ObjectManager, prefix string, policy PrunePolicy, dryRun bool) (PruneReport, error) {
+	if prefix == "" && !policy.AllowBucketRoot {
+		return PruneReport{DryRun: dryRun}, ErrPruneBucketRoot
+	}
+
+	objects, err := manager.ListObjects(ctx, prefix)
+	if err != nil {
+		return PruneReport{Prefix: prefix, DryRun: dryRun}, fmt.Errorf("listing cache objects: %w", err)
+	}
+
+	now := time.Now()
+	report := policy.Plan(prefix, objects, now)
+	report.DryRun = dryRun
+
+	var errs []error
+	if !dryRun {
+		errs = deleteCandidates(ctx, manager, report.Evicted)
+	}
+
+	reader, ok := manager.(ObjectReader)
+	if !ok {
+		return report, errors.Join(errs...)
+	}
+
+	// the manifests whose deletion failed still reference their chunks
+	manifests := slices.Clone(report.Kept)
+	for _, candidate := range report.Evicted {
+		if !dryRun && !candidate.Deleted {
+			manifests = append(manifests, candidate.ObjectInfo)
+		}
+	}
+
+	kept, unreferenced, err := planChunks(ctx, reader, prefix, objects, manifests, now)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	rechecked, unreferenced, err := recheckChunks(ctx, manager, reader, prefix, objects, unreferenced)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	report.Kept = append(report.Kept, kept...)
+	report.Kept = append(report.Kept, rechecked...)
+	if !dryRun {
+		errs = append(errs, deleteCandidates(ctx, manager, unreferenced)...)
+	}
+	report.Evicted = append(report.Evicted, unreferenced...)
+
+	return report, errors.Join(errs...)
+}
+
+// deleteCandidates deletes the candidates, setting Deleted on success. It
+// stops when ctx is done.
+func deleteCandidates(ctx context.Context, manager ObjectManager, candidates []PruneCandidate) []error {
+	var errs []error
+	for i := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		name := candidates[i].Name
+		if err := manager.DeleteObject(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("deleting %q: %w", name, err))
+			continue
+		}
+
+		candidates[i].Deleted = true
+	}
+
+	return errs
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
+)
+
+// planChunks splits the chunks found in objects into those referenced by the
+// manifests, or too recent to be deleted, and the unreferenced ones. The
+// chunks of a project with a manifest that can't be read are all kept, and
+// the failures returned joined together. The manifests don't record whether
+// their job ran for a protected ref, so a chunk referenced by a manifest of
+// the project is kept in both its chunk stores.
+func planChunks(
+	ctx context.Context,
+	reader ObjectReader,
+	prefix string,
+	objects []ObjectInfo,
+	manifests []ObjectInfo,
+	now time.Time,
+) ([]ObjectInfo, []PruneCandidate, error) {
+	referenced := map[string]bool{}
+	unreadable := map[string]bool{}
+
+	var errs []error
+	for _, manifest := range manifests {
+		project, ok := chunkStoreOf(prefix, manifest.Name)
+		if !ok {
+			continue
+		}
+
+		archive, err := chunked.Open(&objectReaderAt{ctx: ctx, reader: reader, name: manifest.Name}, manifest.Size)
+		if errors.Is(err, chunked.ErrInvalidArchive) {
+			// not an archive in the chunked format
+			continue
+		}
+		if err != nil {
+			unreadable[project] = true
+			errs = append(errs, fmt.Errorf("reading manifest %q: %w", manifest.Name, err))
+			continue
+		}
+
+		for _, chunk := range archive.Index.Chunks {
+			referenced[project+chunk.Hash] = true
+		}
+	}
+
+	var kept []ObjectInfo
+	var unreferenced []PruneCandidate
+	for _, obj := range objects {
+		if !isChunk(prefix, obj.Name) {
+			continue
+		}
+
+		project, hash := chunkOf(prefix, obj.Name)
+		if referenced[project+hash] || unreadable[project] || now.Sub(obj.LastModified) < chunked.GracePeriod {
+			kept = append(kept, obj)
+			continue
+		}
+
+		unreferenced = append(unreferenced, PruneCandidate{ObjectInfo: obj, Reason: PruneReasonUnreferenced})
+	}
+
+	return kept, unreferenced, errors.Join(errs...)
+}
+
+// recheckChunks lists the objects again once the manifests were read, and
+// keeps the unreferenced chunks uploaded again since, or referenced by the
+// manifests uploaded since. Jobs reusing stored chunks upload them again when
+// they get old, and then upload their manifest, while the prune runs. When
+// listing fails, all the chunks are kept.
+func recheckChunks(
+	ctx context.Context,
+	manager ObjectManager,
+	reader ObjectReader,
+	prefix string,
+	objects []ObjectInfo,
+	unreferenced []PruneCandidate,
+) ([]ObjectInfo, []PruneCandidate, error) {
+	if len(unreferenced) == 0 {
+		return nil, nil, nil
+	}
+
+	current, err := manager.ListObjects(ctx, prefix)
+	if err != nil {
+		kept := make([]ObjectInfo, 0, len(unreferenced))
+		for _, candidate := range unreferenced {
+			kept = append(kept, candidate.ObjectInfo)
+		}
+
+		return kept, nil, fmt.Errorf("listing cache objects: %w", err)
+	}
+
+	listed := map[string]time.Time{}
+	for _, obj := range objects {
+		listed[obj.Name] = obj.LastModified
+	}
+
+	modified := map[string]time.Time{}
+	var manifests []ObjectInfo
+	for _, obj := range current {
+		if isChunk(prefix, obj.Name) {
+			modified[obj.Name] = obj.LastModified
+			continue
+		}
+
+		if lastModified, ok := listed[obj.Name]; !ok || !lastModified.Equal(obj.LastModified) {
+			manifests = append(manifests, obj)
+		}
+	}
+
+	chunks := make([]ObjectInfo, 0, len(unreferenced))
+	for _, candidate := range unreferenced {
+		chunk := candidate.ObjectInfo
+		if lastModified, ok := modified[chunk.Name]; ok {
+			chunk.LastModified = lastModified
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return planChunks(ctx, reader, prefix, chunks, manifests, time.Now())
+}
+
+// chunkStoreOf returns the chunk store of the project the object belongs to,
+// as the prefix of the chunk objects
+func chunkStoreOf(prefix, name string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(name, prefix), "/")
+	if len(parts) < 3 || parts[0] != "project" {
+		return "", false
+	}
+
+	return prefix + path.Join(chunkStoreDir, parts[0], parts[1]) + "/", true
+}
+
+// chunkOf returns the chunk store of the project the chunk belongs to, as
+// returned by chunkStoreOf, and the hash of the chunk.
+func chunkOf(prefix, name string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(name, prefix), "/")
+
+	return prefix + path.Join(parts[:3]...) + "/", parts[len(parts)-1]
+}
+
+// objectReaderAt reads an object through ranged reads, so that only the
+// header and index of an archive are transferred when opening it
+type objectReaderAt struct {
+	ctx    context.Context
+	reader ObjectReader
+	name   string
+}
+
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	data, err := r.reader.ReadObjectRange(r.ctx, r.name, off, int64(len(p)))
+	n := copy(p, data)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,225 @@
+//go:build !integration
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
+)
+
+// memoryStore is an ObjectManager and ObjectReader holding its objects in
+// memory
+type memoryStore struct {
+	objects map[string][]byte
+	times   map[string]time.Time
+	failing map[string]bool
+
+	// afterList is called once, after the first listing, to store the
+	// objects of a job running concurrently with the prune
+	afterList func(s *memoryStore)
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		objects: map[string][]byte{},
+		times:   map[string]time.Time{},
+		failing: map[string]bool{},
+	}
+}
+
+func (s *memoryStore) put(name string, data []byte, modified time.Time) {
+	s.objects[name] = data
+	s.times[name] = modified
+}
+
+func (s *memoryStore) ListObjects(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for name, data := range s.objects {
+		if bytes.HasPrefix([]byte(name), []byte(prefix)) {
+			objects = append(objects, ObjectInfo{Name: name, Size: int64(len(data)), LastModified: s.times[name]})
+		}
+	}
+
+	if s.afterList != nil {
+		afterList := s.afterList
+		s.afterList = nil
+		afterList(s)
+	}
+
+	return objects, nil
+}
+
+func (s *memoryStore) DeleteObject(_ context.Context, name string) error {
+	delete(s.objects, name)
+	return nil
+}
+
+func (s *memoryStore) ReadObjectRange(_ context.Context, name string, offset, length int64) ([]byte, error) {
+	if s.failing[name] {
+		return nil, errors.New("boom")
+	}
+
+	data := s.objects[name]
+	end := min(offset+length, int64(len(data)))
+
+	return data[offset:end], nil
+}
+
+func manifest(t *testing.T, hashes ...string) []byte {
+	var idx chunked.Index
+	for _, hash := range hashes {
+		idx.Chunks = append(idx.Chunks, chunked.Chunk{Hash: hash, Size: 1, CompressedSize: 1})
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, chunked.WriteManifest(buf, idx))
+
+	return buf.Bytes()
+}
+
+func TestPrune_UnreferencedChunks(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-72 * time.Hour)
+
+	newStore := func() *memoryStore {
+		s := newMemoryStore()
+		// project 1: the stale manifest is pruned, its chunks are left
+		// unreferenced except for the one shared with the recent manifest
+		s.put("runner/project/1/stale-1", manifest(t, "aaaa", "bbbb"), old)
+		s.put("runner/project/1/recent-1", manifest(t, "bbbb"), now)
+		s.put("runner/project/1/zip-1", []byte("PK not a chunked archive"), now)
+		s.put("runner/chunks/project/1/unprotected/aaaa", []byte("a"), old)
+		s.put("runner/chunks/project/1/unprotected/bbbb", []byte("b"), old)
+		s.put("runner/chunks/project/1/unprotected/cccc", []byte("c"), old)
+		// uploaded before its manifest
+		s.put("runner/chunks/project/1/unprotected/dddd", []byte("d"), now)
+		// the chunk store of the protected refs holds its own copy of the
+		// chunks, kept whichever job uploaded the manifest
+		s.put("runner/chunks/project/1/protected/bbbb", []byte("b"), old)
+		s.put("runner/chunks/project/1/protected/cccc", []byte("c"), old)
+		// project 2: the manifest can't be read, so all chunks are kept
+		s.put("runner/project/2/recent-1", manifest(t, "eeee"), now)
+		s.put("runner/chunks/project/2/protected/ffff", []byte("f"), old)
+		s.failing["runner/project/2/recent-1"] = true
+
+		return s
+	}
+
+	policy := PrunePolicy{MaxAge: 24 * time.Hour}
+
+	t.Run("dry run", func(t *testing.T) {
+		s := newStore()
+
+		report, err := Prune(t.Context(), s, "runner/", policy, true)
+		assert.ErrorContains(t, err, `reading manifest "runner/project/2/recent-1": boom`)
+
+		var evicted []string
+		for _, candidate := range report.Evicted {
+			evicted = append(evicted, candidate.Name+" "+string(candidate.Reason))
+			assert.False(t, candidate.Deleted)
+		}
+		assert.ElementsMatch(t, []string{
+			"runner/project/1/stale-1 max-age",
+			"runner/chunks/project/1/unprotected/aaaa unreferenced",
+			"runner/chunks/project/1/unprotected/cccc unreferenced",
+			"runner/chunks/project/1/protected/cccc unreferenced",
+		}, evicted)
+		assert.Len(t, report.Kept, 7)
+		assert.Len(t, s.objects, 11)
+	})
+
+	t.Run("deletes unreferenced chunks", func(t *testing.T) {
+		s := newStore()
+
+		_, err := Prune(t.Context(), s, "runner/", policy, false)
+		assert.ErrorContains(t, err, `reading manifest "runner/project/2/recent-1": boom`)
+
+		var names []string
+		for name := range s.objects {
+			names = append(names, name)
+		}
+		assert.ElementsMatch(t, []string{
+			"runner/project/1/recent-1",
+			"runner/project/1/zip-1",
+			"runner/chunks/project/1/unprotected/bbbb",
+			"runner/chunks/project/1/unprotected/dddd",
+			"runner/chunks/project/1/protected/bbbb",
+			"runner/project/2/recent-1",
+			"runner/chunks/project/2/protected/ffff",
+		}, names)
+	})
+}
+
+func TestPrune_ChunksReusedDuringPrune(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-72 * time.Hour)
+
+	s := newMemoryStore()
+	s.put("runner/project/1/stale-1", manifest(t, "aaaa", "bbbb", "cccc"), old)
+	s.put("runner/chunks/project/1/unprotected/aaaa", []byte("a"), old)
+	s.put("runner/chunks/project/1/unprotected/bbbb", []byte("b"), old)
+	s.put("runner/chunks/project/1/unprotected/cccc", []byte("c"), old)
+	s.afterList = func(s *memoryStore) {
+		// a job reuses the stored chunks: it uploads the old ones again,
+		// then its manifest, while the prune reads the manifests
+		s.put("runner/chunks/project/1/unprotected/aaaa", []byte("a"), time.Now())
+		s.put("runner/project/1/recent-1", manifest(t, "aaaa", "bbbb"), time.Now())
+	}
+
+	report, err := Prune(t.Context(), s, "runner/", PrunePolicy{MaxAge: 24 * time.Hour}, false)
+	require.NoError(t, err)
+
+	var evicted []string
+	for _, candidate := range report.Evicted {
+		evicted = append(evicted, candidate.Name+" "+string(candidate.Reason))
+	}
+	assert.ElementsMatch(t, []string{
+		"runner/project/1/stale-1 max-age",
+		"runner/chunks/project/1/unprotected/cccc unreferenced",
+	}, evicted)
+
+	assert.Contains(t, s.objects, "runner/chunks/project/1/unprotected/aaaa", "the chunk uploaded again is kept")
+	assert.Contains(t, s.objects, "runner/chunks/project/1/unprotected/bbbb", "the chunk of the new manifest is kept")
+	assert.NotContains(t, s.objects, "runner/chunks/project/1/unprotected/cccc")
+}
+
+func TestChunkStoreOf(t *testing.T) {
+	store, ok := chunkStoreOf("runner/", "runner/project/1/ab/abkey")
+	assert.True(t, ok)
+	assert.Equal(t, "runner/chunks/project/1/", store)
+
+	store, ok = chunkStoreOf("runner/", "runner/project/1/chunks/aaaa")
+	assert.True(t, ok)
+	assert.Equal(t, "runner/chunks/project/1/", store, "a cache key named chunks is a manifest")
+
+	_, ok = chunkStoreOf("runner/", "runner/other")
+	assert.False(t, ok)
+}
+
+func TestPrune_CacheKeyNamedChunks(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-72 * time.Hour)
+
+	s := newMemoryStore()
+	s.put("runner/project/1/chunks/key", []byte("PK not a chunked archive"), old)
+	s.put("runner/project/1/recent-1", manifest(t, "aaaa"), now)
+	s.put("runner/chunks/project/1/unprotected/aaaa", []byte("a"), old)
+
+	report, err := Prune(t.Context(), s, "runner/", PrunePolicy{MaxAge: 24 * time.Hour}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []PruneCandidate{{
+		ObjectInfo: ObjectInfo{Name: "runner/project/1/chunks/key", Size: 24, LastModified: old},
+		Reason:     PruneReasonMaxAge,
+		Deleted:    true,
+	}}, report.Evicted, "the cache key is subject to the prune policies")
+	assert.Contains(t, s.objects, "runner/chunks/project/1/unprotected/aaaa")
+}
@@ -0,0 +1,224 @@
+//go:build !integration
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+)
+
+func TestPrunePolicyPlan(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return now.Add(-time.Duration(days) * 24 * time.Hour) }
+
+	objects := []ObjectInfo{
+		{Name: "cache/project/1/old", Size: 10, LastModified: daysAgo(30)},
+		{Name: "cache/project/1/recent", Size: 20, LastModified: daysAgo(1)},
+		{Name: "cache/project/1/deps/aaa", Size: 30, LastModified: daysAgo(20)},
+		{Name: "cache/project/1/deps/bbb", Size: 40, LastModified: daysAgo(10)},
+		{Name: "cache/project/2/ab/abcdef", Size: 50, LastModified: daysAgo(15)},
+		{Name: "cache/project/2/other", Size: 60, LastModified: daysAgo(5)},
+	}
+
+	evicted := func(candidates []PruneCandidate) map[string]PruneReason {
+		reasons := map[string]PruneReason{}
+		for _, c := range candidates {
+			reasons[c.Name] = c.Reason
+		}
+		return reasons
+	}
+
+	tests := map[string]struct {
+		policy          PrunePolicy
+		expectedEvicted map[string]PruneReason
+	}{
+		"empty policy": {
+			policy:          PrunePolicy{},
+			expectedEvicted: map[string]PruneReason{},
+		},
+		"max age": {
+			policy: PrunePolicy{MaxAge: 14 * 24 * time.Hour},
+			expectedEvicted: map[string]PruneReason{
+				"cache/project/1/old":       PruneReasonMaxAge,
+				"cache/project/1/deps/aaa":  PruneReasonMaxAge,
+				"cache/project/2/ab/abcdef": PruneReasonMaxAge,
+			},
+		},
+		"max total size": {
+			policy: PrunePolicy{MaxTotalSize: 130},
+			expectedEvicted: map[string]PruneReason{
+				"cache/project/1/old":       PruneReasonMaxTotalSize,
+				"cache/project/1/deps/aaa":  PruneReasonMaxTotalSize,
+				"cache/project/2/ab/abcdef": PruneReasonMaxTotalSize,
+			},
+		},
+		"max age and max total size": {
+			policy: PrunePolicy{MaxAge: 25 * 24 * time.Hour, MaxTotalSize: 100},
+			expectedEvicted: map[string]PruneReason{
+				"cache/project/1/old":       PruneReasonMaxAge,
+				"cache/project/1/deps/aaa":  PruneReasonMaxTotalSize,
+				"cache/project/2/ab/abcdef": PruneReasonMaxTotalSize,
+				"cache/project/1/deps/bbb":  PruneReasonMaxTotalSize,
+			},
+		},
+		"keep last protects the newest object of each key": {
+			policy:          PrunePolicy{MaxAge: time.Hour, KeepLast: 1},
+			expectedEvicted: map[string]PruneReason{},
+		},
+		"keep last and max total size": {
+			policy:          PrunePolicy{MaxTotalSize: 50, KeepLast: 1},
+			expectedEvicted: map[string]PruneReason{},
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			report := tc.policy.Plan("cache/", objects, now)
+
+			assert.Equal(t, "cache/", report.Prefix)
+			assert.Equal(t, tc.expectedEvicted, evicted(report.Evicted))
+			assert.Len(t, report.Kept, len(objects)-len(tc.expectedEvicted))
+			assert.Equal(t, int64(210), report.KeptSize()+report.EvictedSize())
+		})
+	}
+}
+
+func TestPrunePolicyPlan_KeepLastAndChunks(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	objects := []ObjectInfo{
+		{Name: "cache/project/1/abcdef", Size: 10, LastModified: now.Add(-3 * time.Hour)},
+		{Name: "cache/project/1/ab/abcdef", Size: 10, LastModified: now.Add(-2 * time.Hour)},
+		{Name: "cache/project/1/deps-protected", Size: 10, LastModified: now.Add(-4 * time.Hour)},
+		{Name: "cache/chunks/project/1/0a/0a1b2c", Size: 10, LastModified: now.Add(-48 * time.Hour)},
+		{Name: "cache/chunks/project/2/ff/ff0011", Size: 10, LastModified: now.Add(-48 * time.Hour)},
+		{Name: "cache/project/2/deps-non_protected", Size: 10, LastModified: now.Add(-5 * time.Hour)},
+	}
+
+	report := PrunePolicy{MaxAge: time.Hour, KeepLast: 1}.Plan("cache/", objects, now)
+
+	assert.Equal(t, []PruneCandidate{
+		{ObjectInfo: objects[0], Reason: PruneReasonMaxAge},
+	}, report.Evicted, "the unsharded copy of a sharded key is evicted")
+	assert.Equal(t, []ObjectInfo{objects[1], objects[2], objects[5]}, report.Kept)
+}
+
+func TestPrunePolicyPlan_KeepLastPerKey(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	var objects []ObjectInfo
+	for i := range 10 {
+		objects = append(objects, ObjectInfo{
+			Name:         fmt.Sprintf("cache/project/1/key-%d", i),
+			Size:         10,
+			LastModified: now.Add(-time.Duration(i+1) * 24 * time.Hour),
+		})
+	}
+
+	report := PrunePolicy{MaxAge: time.Hour, MaxTotalSize: 10, KeepLast: 3}.Plan("cache/", objects, now)
+
+	assert.Empty(t, report.Evicted, "every key of the project keeps its newest objects")
+	assert.Len(t, report.Kept, 10)
+
+	report = PrunePolicy{MaxAge: 5 * 24 * time.Hour, MaxTotalSize: 30}.Plan("cache/", objects, now)
+
+	assert.Len(t, report.Evicted, 7, "max age and max total size apply across keys")
+	assert.Equal(t, objects[:3], report.Kept)
+}
+
+func TestKeyGroup(t *testing.T) {
+	tests := map[string]string{
+		"cache/project/1/key":           "project/1/key",
+		"cache/project/1/ke/key":        "project/1/key",
+		"cache/project/1/deps/hash":     "project/1/deps/hash",
+		"cache/project/1/ab/deps/hash":  "project/1/ab/deps/hash",
+		"cache/project/1/de/deps/hash2": "project/1/deps/hash2",
+		"cache/project/1/deps/hash2":    "project/1/deps/hash2",
+	}
+
+	for name, expected := range tests {
+		assert.Equal(t, expected, keyGroup("cache/", name), name)
+	}
+}
+
+func TestPrunePrefix(t *testing.T) {
+	assert.Equal(t, "runner/token/", PrunePrefix(&cacheconfig.Config{}, "token"))
+	assert.Equal(t, "path/runner/token/", PrunePrefix(&cacheconfig.Config{Path: "path"}, "token"))
+	assert.Equal(t, "path/", PrunePrefix(&cacheconfig.Config{Path: "path", Shared: true}, "token"))
+	assert.Equal(t, "", PrunePrefix(&cacheconfig.Config{Shared: true}, "token"))
+}
+
+func TestGetObjectManager(t *testing.T) {
+	_, err := GetObjectManager(nil, time.Minute)
+	assert.EqualError(t, err, "cache is not configured")
+
+	prepareFakeCreateAdapter(t, "", cacheOperationTest{adapterExists: true})
+	_, err = GetObjectManager(&cacheconfig.Config{Type: "test"}, time.Minute)
+	assert.EqualError(t, err, `cache adapter "test" doesn't support listing and deleting objects`)
+}
+
+func TestPrune(t *testing.T) {
+	old := ObjectInfo{Name: "project/1/old", Size: 1, LastModified: time.Now().Add(-48 * time.Hour)}
+	older := ObjectInfo{Name: "project/1/older", Size: 1, LastModified: time.Now().Add(-72 * time.Hour)}
+	recent := ObjectInfo{Name: "project/1/recent", Size: 1, LastModified: time.Now()}
+	policy := PrunePolicy{MaxAge: 24 * time.Hour}
+
+	t.Run("dry run", func(t *testing.T) {
+		manager := NewMockObjectManager(t)
+		manager.EXPECT().ListObjects(mock.Anything, "project/").Return([]ObjectInfo{old, recent}, nil).Once()
+
+		report, err := Prune(t.Context(), manager, "project/", policy, true)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, []ObjectInfo{recent}, report.Kept)
+		assert.Equal(t, []PruneCandidate{{ObjectInfo: old, Reason: PruneReasonMaxAge}}, report.Evicted)
+	})
+
+	t.Run("deletes evicted objects", func(t *testing.T) {
+		manager := NewMockObjectManager(t)
+		manager.EXPECT().ListObjects(mock.Anything, "project/").Return([]ObjectInfo{older, old, recent}, nil).Once()
+		manager.EXPECT().DeleteObject(mock.Anything, old.Name).Return(errors.New("boom")).Once()
+		manager.EXPECT().DeleteObject(mock.Anything, older.Name).Return(nil).Once()
+
+		report, err := Prune(t.Context(), manager, "project/", policy, false)
+		assert.EqualError(t, err, `deleting "project/1/old": boom`)
+		assert.Equal(t, []PruneCandidate{
+			{ObjectInfo: old, Reason: PruneReasonMaxAge},
+			{ObjectInfo: older, Reason: PruneReasonMaxAge, Deleted: true},
+		}, report.Evicted)
+	})
+
+	t.Run("listing fails", func(t *testing.T) {
+		manager := NewMockObjectManager(t)
+		manager.EXPECT().ListObjects(mock.Anything, "project/").Return(nil, errors.New("boom")).Once()
+
+		_, err := Prune(t.Context(), manager, "project/", policy, false)
+		assert.EqualError(t, err, "listing cache objects: boom")
+	})
+
+	t.Run("refuses the bucket root", func(t *testing.T) {
+		manager := NewMockObjectManager(t)
+
+		report, err := Prune(t.Context(), manager, "", policy, false)
+		assert.ErrorIs(t, err, ErrPruneBucketRoot)
+		assert.Empty(t, report.Evicted)
+	})
+
+	t.Run("prunes the bucket root when allowed", func(t *testing.T) {
+		manager := NewMockObjectManager(t)
+		manager.EXPECT().ListObjects(mock.Anything, "").Return([]ObjectInfo{old, recent}, nil).Once()
+		manager.EXPECT().DeleteObject(mock.Anything, old.Name).Return(nil).Once()
+
+		report, err := Prune(t.Context(), manager, "", PrunePolicy{MaxAge: 24 * time.Hour, AllowBucketRoot: true}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []PruneCandidate{{ObjectInfo: old, Reason: PruneReasonMaxAge, Deleted: true}}, report.Evicted)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/sirupsen/logrus"
 
@@ -114,6 +115,28 @@ func (a *s3Adapter) WithMetadata(metadata map[string]string) {
 	a.metadata = metadata
 }
 
+func (a *s3Adapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	var objects []cache.ObjectInfo
+	for obj := range a.client.ListObjects(ctx, a.config.BucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("listing S3 objects: %w", obj.Err)
+		}
+
+		objects = append(objects, cache.ObjectInfo{Name: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+	}
+
+	return objects, nil
+}
+
+func (a *s3Adapter) DeleteObject(ctx context.Context, name string) error {
+	err := a.client.RemoveObject(ctx, a.config.BucketName, name, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("deleting S3 object: %w", err)
+	}
+
+	return nil
+}
+
 func New(config *cacheconfig.Config, timeout time.Duration, objectName string) (cache.Adapter, error) {
 	s3 := config.S3
 	if s3 == nil {
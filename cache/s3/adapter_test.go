@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -327,3 +328,53 @@ func TestNoConfiguration(t *testing.T) {
 
 	assert.EqualError(t, err, "missing S3 configuration")
 }
+
+func TestListAndDeleteObjects(t *testing.T) {
+	client := newMockMinioClient(t)
+
+	oldNewMinioClient := newMinioClient
+	newMinioClient = func(s3 *cacheconfig.CacheS3Config) (minioClient, error) {
+		return client, nil
+	}
+	t.Cleanup(func() {
+		newMinioClient = oldNewMinioClient
+	})
+
+	adapter, err := New(defaultCacheFactory(), defaultTimeout, "")
+	require.NoError(t, err)
+	manager, ok := adapter.(cache.ObjectManager)
+	require.True(t, ok)
+
+	lastModified := time.Now()
+	listObjects := func(objects ...minio.ObjectInfo) <-chan minio.ObjectInfo {
+		ch := make(chan minio.ObjectInfo, len(objects))
+		for _, obj := range objects {
+			ch <- obj
+		}
+		close(ch)
+		return ch
+	}
+
+	client.EXPECT().
+		ListObjects(mock.Anything, bucketName, minio.ListObjectsOptions{Prefix: "runner/", Recursive: true}).
+		Return(listObjects(minio.ObjectInfo{Key: "runner/key", Size: 10, LastModified: lastModified})).
+		Once()
+
+	objects, err := manager.ListObjects(t.Context(), "runner/")
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ObjectInfo{{Name: "runner/key", Size: 10, LastModified: lastModified}}, objects)
+
+	client.EXPECT().
+		ListObjects(mock.Anything, bucketName, mock.Anything).
+		Return(listObjects(minio.ObjectInfo{Err: errors.New("test error")})).
+		Once()
+
+	_, err = manager.ListObjects(t.Context(), "runner/")
+	assert.EqualError(t, err, "listing S3 objects: test error")
+
+	client.EXPECT().RemoveObject(mock.Anything, bucketName, "runner/key", minio.RemoveObjectOptions{}).Return(nil).Once()
+	assert.NoError(t, manager.DeleteObject(t.Context(), "runner/key"))
+
+	client.EXPECT().RemoveObject(mock.Anything, bucketName, "runner/key", minio.RemoveObjectOptions{}).Return(errors.New("test error")).Once()
+	assert.EqualError(t, manager.DeleteObject(t.Context(), "runner/key"), "deleting S3 object: test error")
+}
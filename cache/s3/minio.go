@@ -29,6 +29,8 @@ type minioClient interface {
 		reqParams url.Values,
 		extraHeaders http.Header,
 	) (*url.URL, error)
+	ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
+	RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error
 }
 
 var newMinio = minio.New
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/minio/minio-go/v7"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -40,6 +41,71 @@ func (_m *mockMinioClient) EXPECT() *mockMinioClient_Expecter {
 	return &mockMinioClient_Expecter{mock: &_m.Mock}
 }
 
+// ListObjects provides a mock function for the type mockMinioClient
+func (_mock *mockMinioClient) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	ret := _mock.Called(ctx, bucketName, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListObjects")
+	}
+
+	var r0 <-chan minio.ObjectInfo
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, minio.ListObjectsOptions) <-chan minio.ObjectInfo); ok {
+		r0 = returnFunc(ctx, bucketName, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan minio.ObjectInfo)
+		}
+	}
+	return r0
+}
+
+// mockMinioClient_ListObjects_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListObjects'
+type mockMinioClient_ListObjects_Call struct {
+	*mock.Call
+}
+
+// ListObjects is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bucketName string
+//   - opts minio.ListObjectsOptions
+func (_e *mockMinioClient_Expecter) ListObjects(ctx interface{}, bucketName interface{}, opts interface{}) *mockMinioClient_ListObjects_Call {
+	return &mockMinioClient_ListObjects_Call{Call: _e.mock.On("ListObjects", ctx, bucketName, opts)}
+}
+
+func (_c *mockMinioClient_ListObjects_Call) Run(run func(ctx context.Context, bucketName string, opts minio.ListObjectsOptions)) *mockMinioClient_ListObjects_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 minio.ListObjectsOptions
+		if args[2] != nil {
+			arg2 = args[2].(minio.ListObjectsOptions)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *mockMinioClient_ListObjects_Call) Return(objectInfoCh <-chan minio.ObjectInfo) *mockMinioClient_ListObjects_Call {
+	_c.Call.Return(objectInfoCh)
+	return _c
+}
+
+func (_c *mockMinioClient_ListObjects_Call) RunAndReturn(run func(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo) *mockMinioClient_ListObjects_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // PresignHeader provides a mock function for the type mockMinioClient
 func (_mock *mockMinioClient) PresignHeader(ctx context.Context, method string, bucketName string, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error) {
 	ret := _mock.Called(ctx, method, bucketName, objectName, expires, reqParams, extraHeaders)
@@ -137,3 +203,72 @@ func (_c *mockMinioClient_PresignHeader_Call) RunAndReturn(run func(ctx context.
 	_c.Call.Return(run)
 	return _c
 }
+
+// RemoveObject provides a mock function for the type mockMinioClient
+func (_mock *mockMinioClient) RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error {
+	ret := _mock.Called(ctx, bucketName, objectName, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveObject")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, minio.RemoveObjectOptions) error); ok {
+		r0 = returnFunc(ctx, bucketName, objectName, opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockMinioClient_RemoveObject_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RemoveObject'
+type mockMinioClient_RemoveObject_Call struct {
+	*mock.Call
+}
+
+// RemoveObject is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bucketName string
+//   - objectName string
+//   - opts minio.RemoveObjectOptions
+func (_e *mockMinioClient_Expecter) RemoveObject(ctx interface{}, bucketName interface{}, objectName interface{}, opts interface{}) *mockMinioClient_RemoveObject_Call {
+	return &mockMinioClient_RemoveObject_Call{Call: _e.mock.On("RemoveObject", ctx, bucketName, objectName, opts)}
+}
+
+func (_c *mockMinioClient_RemoveObject_Call) Run(run func(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions)) *mockMinioClient_RemoveObject_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 minio.RemoveObjectOptions
+		if args[3] != nil {
+			arg3 = args[3].(minio.RemoveObjectOptions)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *mockMinioClient_RemoveObject_Call) Return(err error) *mockMinioClient_RemoveObject_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockMinioClient_RemoveObject_Call) RunAndReturn(run func(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error) *mockMinioClient_RemoveObject_Call {
+	_c.Call.Return(run)
+	return _c
+}
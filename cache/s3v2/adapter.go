@@ -78,6 +78,12 @@ func (a *s3Adapter) getARNForGoCloud(upload bool) string {
 }
 
 func (a *s3Adapter) GetGoCloudURL(ctx context.Context, upload bool) (cache.GoCloudURL, error) {
+	return a.GetChunkStoreGoCloudURL(ctx, "", upload)
+}
+
+// GetChunkStoreGoCloudURL returns the Go Cloud URL of the cache object, with
+// credentials that also grant access to the chunks stored under chunkPrefix.
+func (a *s3Adapter) GetChunkStoreGoCloudURL(ctx context.Context, chunkPrefix string, upload bool) (cache.GoCloudURL, error) {
 	goCloudURL := cache.GoCloudURL{}
 
 	roleARN := a.getARNForGoCloud(upload)
@@ -132,13 +138,26 @@ func (a *s3Adapter) GetGoCloudURL(ctx context.Context, upload bool) (cache.GoClo
 	u.RawQuery = q.Encode()
 	goCloudURL.URL = &u
 
-	credentials, err := a.client.FetchCredentialsForRole(
-		ctx,
-		roleARN,
-		a.config.BucketName,
-		a.objectName,
-		upload,
-		a.timeout)
+	var credentials map[string]string
+	var err error
+	if chunkPrefix == "" {
+		credentials, err = a.client.FetchCredentialsForRole(
+			ctx,
+			roleARN,
+			a.config.BucketName,
+			a.objectName,
+			upload,
+			a.timeout)
+	} else {
+		credentials, err = a.client.FetchChunkStoreCredentialsForRole(
+			ctx,
+			roleARN,
+			a.config.BucketName,
+			a.objectName,
+			chunkPrefix,
+			upload,
+			a.timeout)
+	}
 	if err != nil {
 		return goCloudURL, err
 	}
@@ -148,6 +167,44 @@ func (a *s3Adapter) GetGoCloudURL(ctx context.Context, upload bool) (cache.GoClo
 	return goCloudURL, nil
 }
 
+func (a *s3Adapter) ListObjects(ctx context.Context, prefix string) ([]cache.ObjectInfo, error) {
+	if a.config.BucketName == "" {
+		return nil, fmt.Errorf("config BucketName cannot be empty")
+	}
+
+	objects, err := a.client.ListObjects(ctx, a.config.BucketName, strings.TrimLeft(prefix, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("listing S3 objects: %w", err)
+	}
+
+	return objects, nil
+}
+
+func (a *s3Adapter) DeleteObject(ctx context.Context, name string) error {
+	if a.config.BucketName == "" {
+		return fmt.Errorf("config BucketName cannot be empty")
+	}
+
+	if err := a.client.DeleteObject(ctx, a.config.BucketName, name); err != nil {
+		return fmt.Errorf("deleting S3 object: %w", err)
+	}
+
+	return nil
+}
+
+func (a *s3Adapter) ReadObjectRange(ctx context.Context, name string, offset, length int64) ([]byte, error) {
+	if a.config.BucketName == "" {
+		return nil, fmt.Errorf("config BucketName cannot be empty")
+	}
+
+	data, err := a.client.ReadObjectRange(ctx, a.config.BucketName, name, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("reading S3 object: %w", err)
+	}
+
+	return data, nil
+}
+
 func (a *s3Adapter) presignURL(ctx context.Context, method string) (cache.PresignedURL, error) {
 	if a.config.BucketName == "" {
 		return cache.PresignedURL{}, fmt.Errorf("config BucketName cannot be empty")
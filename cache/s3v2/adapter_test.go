@@ -191,6 +191,52 @@ func TestNoConfiguration(t *testing.T) {
 	assert.EqualError(t, err, "missing S3 configuration")
 }
 
+func TestListAndDeleteObjects(t *testing.T) {
+	client := newMockS3Presigner(t)
+
+	oldS3URLGenerator := newS3Client
+	newS3Client = func(s3 *cacheconfig.CacheS3Config, opts ...s3ClientOption) (s3Presigner, error) {
+		return client, nil
+	}
+	t.Cleanup(func() {
+		newS3Client = oldS3URLGenerator
+	})
+
+	adapter, err := New(defaultCacheFactory(), defaultTimeout, "")
+	require.NoError(t, err)
+	manager, ok := adapter.(cache.ObjectManager)
+	require.True(t, ok)
+
+	expected := []cache.ObjectInfo{{Name: "runner/key", Size: 10, LastModified: time.Now()}}
+	client.EXPECT().ListObjects(mock.Anything, bucketName, "runner/").Return(expected, nil).Once()
+
+	objects, err := manager.ListObjects(t.Context(), "/runner/")
+	require.NoError(t, err)
+	assert.Equal(t, expected, objects)
+
+	client.EXPECT().ListObjects(mock.Anything, bucketName, "runner/").Return(nil, errors.New("test error")).Once()
+	_, err = manager.ListObjects(t.Context(), "runner/")
+	assert.EqualError(t, err, "listing S3 objects: test error")
+
+	client.EXPECT().DeleteObject(mock.Anything, bucketName, "runner/key").Return(nil).Once()
+	assert.NoError(t, manager.DeleteObject(t.Context(), "runner/key"))
+
+	client.EXPECT().DeleteObject(mock.Anything, bucketName, "runner/key").Return(errors.New("test error")).Once()
+	assert.EqualError(t, manager.DeleteObject(t.Context(), "runner/key"), "deleting S3 object: test error")
+
+	reader, ok := adapter.(cache.ObjectReader)
+	require.True(t, ok)
+
+	client.EXPECT().ReadObjectRange(mock.Anything, bucketName, "runner/key", int64(2), int64(4)).Return([]byte("data"), nil).Once()
+	data, err := reader.ReadObjectRange(t.Context(), "runner/key", 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+
+	client.EXPECT().ReadObjectRange(mock.Anything, bucketName, "runner/key", int64(2), int64(4)).Return(nil, errors.New("test error")).Once()
+	_, err = reader.ReadObjectRange(t.Context(), "runner/key", 2, 4)
+	assert.EqualError(t, err, "reading S3 object: test error")
+}
+
 func TestGoCloudURLWithRoleARN(t *testing.T) {
 	enabled := true
 	disabled := false
@@ -387,6 +433,30 @@ func TestGoCloudURLWithRoleARN(t *testing.T) {
 	}
 }
 
+func TestGetChunkStoreGoCloudURL(t *testing.T) {
+	expectedCredentials := map[string]string{"AWS_ACCESS_KEY_ID": "mock-access-key"}
+
+	onFakeS3URLGenerator(t, cacheOperationTest{})
+
+	s3Cache := defaultCacheFactory()
+	s3Cache.S3.BucketName = "role-bucket"
+	s3Cache.S3.RoleARN = "aws:arn:role:1234"
+
+	adapter, err := New(s3Cache, defaultTimeout, objectName)
+	require.NoError(t, err)
+
+	mockClient := adapter.(*s3Adapter).client.(*mockS3Presigner)
+	mockClient.On("ServerSideEncryptionType").Return("").Maybe()
+	mockClient.On("FetchChunkStoreCredentialsForRole", mock.Anything, "aws:arn:role:1234", "role-bucket", objectName, "chunks/project/1/", true, defaultTimeout).
+		Return(expectedCredentials, nil).Once()
+
+	u, err := adapter.(cache.ChunkStoreAdapter).GetChunkStoreGoCloudURL(t.Context(), "chunks/project/1/", true)
+	require.NoError(t, err)
+	assert.Equal(t, "role-bucket", u.URL.Host)
+	assert.Equal(t, objectName, u.URL.Path)
+	assert.Equal(t, expectedCredentials, u.Environment)
+}
+
 func TestGoCloudURLWithUploadRoleARN(t *testing.T) {
 	enabled := true
 	disabled := false
@@ -39,6 +39,167 @@ func (_m *mockS3Presigner) EXPECT() *mockS3Presigner_Expecter {
 	return &mockS3Presigner_Expecter{mock: &_m.Mock}
 }
 
+// DeleteObject provides a mock function for the type mockS3Presigner
+func (_mock *mockS3Presigner) DeleteObject(ctx context.Context, bucketName string, objectName string) error {
+	ret := _mock.Called(ctx, bucketName, objectName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteObject")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, bucketName, objectName)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockS3Presigner_DeleteObject_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteObject'
+type mockS3Presigner_DeleteObject_Call struct {
+	*mock.Call
+}
+
+// DeleteObject is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bucketName string
+//   - objectName string
+func (_e *mockS3Presigner_Expecter) DeleteObject(ctx interface{}, bucketName interface{}, objectName interface{}) *mockS3Presigner_DeleteObject_Call {
+	return &mockS3Presigner_DeleteObject_Call{Call: _e.mock.On("DeleteObject", ctx, bucketName, objectName)}
+}
+
+func (_c *mockS3Presigner_DeleteObject_Call) Run(run func(ctx context.Context, bucketName string, objectName string)) *mockS3Presigner_DeleteObject_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *mockS3Presigner_DeleteObject_Call) Return(err error) *mockS3Presigner_DeleteObject_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *mockS3Presigner_DeleteObject_Call) RunAndReturn(run func(ctx context.Context, bucketName string, objectName string) error) *mockS3Presigner_DeleteObject_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FetchChunkStoreCredentialsForRole provides a mock function for the type mockS3Presigner
+func (_mock *mockS3Presigner) FetchChunkStoreCredentialsForRole(ctx context.Context, roleARN string, bucketName string, objectName string, chunkPrefix string, upload bool, timeout time.Duration) (map[string]string, error) {
+	ret := _mock.Called(ctx, roleARN, bucketName, objectName, chunkPrefix, upload, timeout)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FetchChunkStoreCredentialsForRole")
+	}
+
+	var r0 map[string]string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, string, string, bool, time.Duration) (map[string]string, error)); ok {
+		return returnFunc(ctx, roleARN, bucketName, objectName, chunkPrefix, upload, timeout)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, string, string, bool, time.Duration) map[string]string); ok {
+		r0 = returnFunc(ctx, roleARN, bucketName, objectName, chunkPrefix, upload, timeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, string, string, bool, time.Duration) error); ok {
+		r1 = returnFunc(ctx, roleARN, bucketName, objectName, chunkPrefix, upload, timeout)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockS3Presigner_FetchChunkStoreCredentialsForRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FetchChunkStoreCredentialsForRole'
+type mockS3Presigner_FetchChunkStoreCredentialsForRole_Call struct {
+	*mock.Call
+}
+
+// FetchChunkStoreCredentialsForRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - roleARN string
+//   - bucketName string
+//   - objectName string
+//   - chunkPrefix string
+//   - upload bool
+//   - timeout time.Duration
+func (_e *mockS3Presigner_Expecter) FetchChunkStoreCredentialsForRole(ctx interface{}, roleARN interface{}, bucketName interface{}, objectName interface{}, chunkPrefix interface{}, upload interface{}, timeout interface{}) *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call {
+	return &mockS3Presigner_FetchChunkStoreCredentialsForRole_Call{Call: _e.mock.On("FetchChunkStoreCredentialsForRole", ctx, roleARN, bucketName, objectName, chunkPrefix, upload, timeout)}
+}
+
+func (_c *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call) Run(run func(ctx context.Context, roleARN string, bucketName string, objectName string, chunkPrefix string, upload bool, timeout time.Duration)) *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		var arg4 string
+		if args[4] != nil {
+			arg4 = args[4].(string)
+		}
+		var arg5 bool
+		if args[5] != nil {
+			arg5 = args[5].(bool)
+		}
+		var arg6 time.Duration
+		if args[6] != nil {
+			arg6 = args[6].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+			arg6,
+		)
+	})
+	return _c
+}
+
+func (_c *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call) Return(stringToString map[string]string, err error) *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call {
+	_c.Call.Return(stringToString, err)
+	return _c
+}
+
+func (_c *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call) RunAndReturn(run func(ctx context.Context, roleARN string, bucketName string, objectName string, chunkPrefix string, upload bool, timeout time.Duration) (map[string]string, error)) *mockS3Presigner_FetchChunkStoreCredentialsForRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // FetchCredentialsForRole provides a mock function for the type mockS3Presigner
 func (_mock *mockS3Presigner) FetchCredentialsForRole(ctx context.Context, roleARN string, bucketName string, objectName string, upload bool, timeout time.Duration) (map[string]string, error) {
 	ret := _mock.Called(ctx, roleARN, bucketName, objectName, upload, timeout)
@@ -131,6 +292,80 @@ func (_c *mockS3Presigner_FetchCredentialsForRole_Call) RunAndReturn(run func(ct
 	return _c
 }
 
+// ListObjects provides a mock function for the type mockS3Presigner
+func (_mock *mockS3Presigner) ListObjects(ctx context.Context, bucketName string, prefix string) ([]cache.ObjectInfo, error) {
+	ret := _mock.Called(ctx, bucketName, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListObjects")
+	}
+
+	var r0 []cache.ObjectInfo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) ([]cache.ObjectInfo, error)); ok {
+		return returnFunc(ctx, bucketName, prefix)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) []cache.ObjectInfo); ok {
+		r0 = returnFunc(ctx, bucketName, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.ObjectInfo)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, bucketName, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockS3Presigner_ListObjects_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListObjects'
+type mockS3Presigner_ListObjects_Call struct {
+	*mock.Call
+}
+
+// ListObjects is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bucketName string
+//   - prefix string
+func (_e *mockS3Presigner_Expecter) ListObjects(ctx interface{}, bucketName interface{}, prefix interface{}) *mockS3Presigner_ListObjects_Call {
+	return &mockS3Presigner_ListObjects_Call{Call: _e.mock.On("ListObjects", ctx, bucketName, prefix)}
+}
+
+func (_c *mockS3Presigner_ListObjects_Call) Run(run func(ctx context.Context, bucketName string, prefix string)) *mockS3Presigner_ListObjects_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *mockS3Presigner_ListObjects_Call) Return(objectInfos []cache.ObjectInfo, err error) *mockS3Presigner_ListObjects_Call {
+	_c.Call.Return(objectInfos, err)
+	return _c
+}
+
+func (_c *mockS3Presigner_ListObjects_Call) RunAndReturn(run func(ctx context.Context, bucketName string, prefix string) ([]cache.ObjectInfo, error)) *mockS3Presigner_ListObjects_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // PresignURL provides a mock function for the type mockS3Presigner
 func (_mock *mockS3Presigner) PresignURL(ctx context.Context, method string, bucketName string, objectName string, metadata map[string]string, expires time.Duration) (cache.PresignedURL, error) {
 	ret := _mock.Called(ctx, method, bucketName, objectName, metadata, expires)
@@ -221,6 +456,92 @@ func (_c *mockS3Presigner_PresignURL_Call) RunAndReturn(run func(ctx context.Con
 	return _c
 }
 
+// ReadObjectRange provides a mock function for the type mockS3Presigner
+func (_mock *mockS3Presigner) ReadObjectRange(ctx context.Context, bucketName string, objectName string, offset int64, length int64) ([]byte, error) {
+	ret := _mock.Called(ctx, bucketName, objectName, offset, length)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadObjectRange")
+	}
+
+	var r0 []byte
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, int64, int64) ([]byte, error)); ok {
+		return returnFunc(ctx, bucketName, objectName, offset, length)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, int64, int64) []byte); ok {
+		r0 = returnFunc(ctx, bucketName, objectName, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, int64, int64) error); ok {
+		r1 = returnFunc(ctx, bucketName, objectName, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockS3Presigner_ReadObjectRange_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadObjectRange'
+type mockS3Presigner_ReadObjectRange_Call struct {
+	*mock.Call
+}
+
+// ReadObjectRange is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bucketName string
+//   - objectName string
+//   - offset int64
+//   - length int64
+func (_e *mockS3Presigner_Expecter) ReadObjectRange(ctx interface{}, bucketName interface{}, objectName interface{}, offset interface{}, length interface{}) *mockS3Presigner_ReadObjectRange_Call {
+	return &mockS3Presigner_ReadObjectRange_Call{Call: _e.mock.On("ReadObjectRange", ctx, bucketName, objectName, offset, length)}
+}
+
+func (_c *mockS3Presigner_ReadObjectRange_Call) Run(run func(ctx context.Context, bucketName string, objectName string, offset int64, length int64)) *mockS3Presigner_ReadObjectRange_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 int64
+		if args[3] != nil {
+			arg3 = args[3].(int64)
+		}
+		var arg4 int64
+		if args[4] != nil {
+			arg4 = args[4].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *mockS3Presigner_ReadObjectRange_Call) Return(bytes []byte, err error) *mockS3Presigner_ReadObjectRange_Call {
+	_c.Call.Return(bytes, err)
+	return _c
+}
+
+func (_c *mockS3Presigner_ReadObjectRange_Call) RunAndReturn(run func(ctx context.Context, bucketName string, objectName string, offset int64, length int64) ([]byte, error)) *mockS3Presigner_ReadObjectRange_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ServerSideEncryptionType provides a mock function for the type mockS3Presigner
 func (_mock *mockS3Presigner) ServerSideEncryptionType() string {
 	ret := _mock.Called()
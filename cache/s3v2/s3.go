@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -127,7 +128,11 @@ type s3Presigner interface {
 		expires time.Duration,
 	) (cache.PresignedURL, error)
 	FetchCredentialsForRole(ctx context.Context, roleARN, bucketName, objectName string, upload bool, timeout time.Duration) (map[string]string, error)
+	FetchChunkStoreCredentialsForRole(ctx context.Context, roleARN, bucketName, objectName, chunkPrefix string, upload bool, timeout time.Duration) (map[string]string, error)
 	ServerSideEncryptionType() string
+	ListObjects(ctx context.Context, bucketName, prefix string) ([]cache.ObjectInfo, error)
+	DeleteObject(ctx context.Context, bucketName, objectName string) error
+	ReadObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) ([]byte, error)
 }
 
 type s3Client struct {
@@ -213,11 +218,60 @@ func (c *s3Client) PresignURL(ctx context.Context,
 	return cache.PresignedURL{URL: u, Headers: presignedReq.SignedHeader}, nil
 }
 
+func (c *s3Client) ListObjects(ctx context.Context, bucketName, prefix string) ([]cache.ObjectInfo, error) {
+	var objects []cache.ObjectInfo
+
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Contents {
+			objects = append(objects, cache.ObjectInfo{
+				Name:         aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (c *s3Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+
+	return err
+}
+
+func (c *s3Client) ReadObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) ([]byte, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(io.LimitReader(out.Body, length))
+}
+
 // policyStatement is one entry in an IAM policy's Statement array.
 type policyStatement struct {
-	Effect   string   `json:"Effect"`
-	Action   []string `json:"Action"`
-	Resource string   `json:"Resource"`
+	Effect    string                       `json:"Effect"`
+	Action    []string                     `json:"Action"`
+	Resource  string                       `json:"Resource"`
+	Condition map[string]map[string]string `json:"Condition,omitempty"`
 }
 
 // policyDocument is the top-level shape of an IAM policy document.
@@ -226,22 +280,36 @@ type policyDocument struct {
 	Statement []policyStatement `json:"Statement"`
 }
 
-func (c *s3Client) generateSessionPolicy(bucketName, objectName string, upload bool) (string, error) {
-	action := "s3:GetObject"
-	if upload {
-		action = "s3:PutObject"
-	}
-
+// s3Partition returns the ARN partition of the configured region.
+func (c *s3Client) s3Partition() string {
 	// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference-arns.html
-	s3Partition := "aws"
 	// https://docs.aws.amazon.com/govcloud-us/latest/UserGuide/using-govcloud-arns.html
 	switch {
 	case strings.HasPrefix(c.awsConfig.Region, "us-gov-"):
-		s3Partition = "aws-us-gov"
+		return "aws-us-gov"
 	case strings.HasPrefix(c.awsConfig.Region, "cn-"):
-		s3Partition = "aws-cn"
+		return "aws-cn"
+	default:
+		return "aws"
+	}
+}
+
+func (c *s3Client) generateSessionPolicy(bucketName, objectName string, upload bool) (string, error) {
+	return c.generateChunkStoreSessionPolicy(bucketName, objectName, "", upload)
+}
+
+// generateChunkStoreSessionPolicy generates the session policy granting
+// access to objectName and, when chunkPrefix isn't empty, to the chunks
+// stored under chunkPrefix. Uploads may read and list the chunks too, as
+// only the missing chunks are uploaded.
+func (c *s3Client) generateChunkStoreSessionPolicy(bucketName, objectName, chunkPrefix string, upload bool) (string, error) {
+	action := "s3:GetObject"
+	if upload {
+		action = "s3:PutObject"
 	}
 
+	s3Partition := c.s3Partition()
+
 	// Build the policy via encoding/json rather than string formatting so that
 	// any special characters in objectName (which originates from the cache
 	// key in .gitlab-ci.yml) are escaped at the value position and cannot
@@ -257,6 +325,28 @@ func (c *s3Client) generateSessionPolicy(bucketName, objectName string, upload b
 		},
 	}
 
+	if chunkPrefix != "" {
+		chunkActions := []string{"s3:GetObject"}
+		if upload {
+			chunkActions = append(chunkActions, "s3:PutObject")
+		}
+
+		doc.Statement = append(doc.Statement, policyStatement{
+			Effect:   "Allow",
+			Action:   chunkActions,
+			Resource: fmt.Sprintf("arn:%s:s3:::%s/%s*", s3Partition, bucketName, chunkPrefix),
+		})
+
+		if upload {
+			doc.Statement = append(doc.Statement, policyStatement{
+				Effect:    "Allow",
+				Action:    []string{"s3:ListBucket"},
+				Resource:  fmt.Sprintf("arn:%s:s3:::%s", s3Partition, bucketName),
+				Condition: map[string]map[string]string{"StringLike": {"s3:prefix": chunkPrefix + "*"}},
+			})
+		}
+	}
+
 	if c.s3Config.EncryptionType() == cacheconfig.S3EncryptionTypeKms || c.s3Config.EncryptionType() == cacheconfig.S3EncryptionTypeDsseKms {
 		// Permissions needed for multipart upload: https://repost.aws/knowledge-center/s3-large-file-encryption-kms-key
 		doc.Statement = append(doc.Statement, policyStatement{
@@ -310,6 +400,12 @@ func (c *s3Client) acquireAssumeRoleSem(ctx context.Context) (func(), error) {
 }
 
 func (c *s3Client) FetchCredentialsForRole(ctx context.Context, roleARN, bucketName, objectName string, upload bool, timeout time.Duration) (map[string]string, error) {
+	return c.FetchChunkStoreCredentialsForRole(ctx, roleARN, bucketName, objectName, "", upload, timeout)
+}
+
+// FetchChunkStoreCredentialsForRole is like FetchCredentialsForRole, but the
+// credentials also grant access to the chunks stored under chunkPrefix.
+func (c *s3Client) FetchChunkStoreCredentialsForRole(ctx context.Context, roleARN, bucketName, objectName, chunkPrefix string, upload bool, timeout time.Duration) (map[string]string, error) {
 	// minValidity is the minimum remaining lifetime a cached credential must
 	// have to be considered usable. We want credentials to remain valid for
 	// the entire transfer (at least `timeout`), but cap at 55 minutes so
@@ -317,13 +413,16 @@ func (c *s3Client) FetchCredentialsForRole(ctx context.Context, roleARN, bucketN
 	// regardless of how large `timeout` is configured.
 	minValidity := min(max(timeout, time.Minute), 55*time.Minute)
 	credKey := assumeRoleCacheKey(roleARN, bucketName, objectName, upload)
+	if chunkPrefix != "" {
+		credKey += "\x00" + chunkPrefix
+	}
 
 	// Fast path: return cached credentials without touching the semaphore.
 	if creds, ok := c.cachedCreds(credKey, minValidity); ok {
 		return creds, nil
 	}
 
-	sessionPolicy, err := c.generateSessionPolicy(bucketName, objectName, upload)
+	sessionPolicy, err := c.generateChunkStoreSessionPolicy(bucketName, objectName, chunkPrefix, upload)
 	if err != nil {
 		return nil, err
 	}
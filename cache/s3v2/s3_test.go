@@ -674,6 +674,50 @@ func TestGenerateSessionPolicy_JSONInjection(t *testing.T) {
 	)
 }
 
+func TestGenerateChunkStoreSessionPolicy(t *testing.T) {
+	cfg := aws.Config{Region: "us-gov-west-1"}
+	c := &s3Client{
+		awsConfig: &cfg,
+		s3Config:  &cacheconfig.CacheS3Config{},
+	}
+
+	tests := map[string]struct {
+		upload             bool
+		expectedStatements []policyStatement
+	}{
+		"download": {
+			expectedStatements: []policyStatement{
+				{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: "arn:aws-us-gov:s3:::test-bucket/project/1/key"},
+				{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: "arn:aws-us-gov:s3:::test-bucket/chunks/project/1/*"},
+			},
+		},
+		"upload": {
+			upload: true,
+			expectedStatements: []policyStatement{
+				{Effect: "Allow", Action: []string{"s3:PutObject"}, Resource: "arn:aws-us-gov:s3:::test-bucket/project/1/key"},
+				{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: "arn:aws-us-gov:s3:::test-bucket/chunks/project/1/*"},
+				{
+					Effect:    "Allow",
+					Action:    []string{"s3:ListBucket"},
+					Resource:  "arn:aws-us-gov:s3:::test-bucket",
+					Condition: map[string]map[string]string{"StringLike": {"s3:prefix": "chunks/project/1/*"}},
+				},
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			policy, err := c.generateChunkStoreSessionPolicy("test-bucket", "project/1/key", "chunks/project/1/", tt.upload)
+			require.NoError(t, err)
+
+			var doc policyDocument
+			require.NoError(t, json.Unmarshal([]byte(policy), &doc))
+			assert.Equal(t, tt.expectedStatements, doc.Statement)
+		})
+	}
+}
+
 func TestS3Client_PresignURL_UnknownMethodError(t *testing.T) {
 	s3Config := setupMockS3Server(t)
 
@@ -689,6 +733,45 @@ func TestS3Client_PresignURL_UnknownMethodError(t *testing.T) {
 	assert.Contains(t, err.Error(), "unsupported method: INVALID")
 }
 
+func TestS3Client_ListAndDeleteObjects(t *testing.T) {
+	s3Config := setupMockS3Server(t)
+
+	_, client, err := newRawS3Client(s3Config)
+	require.NoError(t, err)
+
+	for _, key := range []string{"runner/a/project/1/key", "runner/a/project/2/key", "runner/b/project/1/key"} {
+		_, err := client.PutObject(t.Context(), &s3.PutObjectInput{
+			Bucket: aws.String(s3Config.BucketName),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte(key)),
+		})
+		require.NoError(t, err)
+	}
+
+	s3Client, err := buildS3Client(s3Config)
+	require.NoError(t, err)
+
+	objects, err := s3Client.ListObjects(t.Context(), s3Config.BucketName, "runner/a/")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	for _, obj := range objects {
+		assert.Contains(t, []string{"runner/a/project/1/key", "runner/a/project/2/key"}, obj.Name)
+		assert.Equal(t, int64(len(obj.Name)), obj.Size)
+		assert.False(t, obj.LastModified.IsZero())
+	}
+
+	require.NoError(t, s3Client.DeleteObject(t.Context(), s3Config.BucketName, "runner/a/project/1/key"))
+
+	objects, err = s3Client.ListObjects(t.Context(), s3Config.BucketName, "runner/a/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "runner/a/project/2/key", objects[0].Name)
+
+	data, err := s3Client.ReadObjectRange(t.Context(), s3Config.BucketName, "runner/a/project/2/key", 9, 9)
+	require.NoError(t, err)
+	assert.Equal(t, "project/2", string(data))
+}
+
 func newMockSTSHandler(expectedKms bool, expectedDurationSecs int, s3Partition string) http.Handler {
 	roleARN := "arn:aws:iam::123456789012:role/TestRole"
 	expectedStatements := 1
@@ -356,6 +356,8 @@ func (b *buildsHelper) removeBuild(deleteBuild *common.Build) bool {
 		WithLabelValues(deleteBuild.Runner.ShortDescription(), deleteBuild.Runner.Name, deleteBuild.Runner.GetSystemID(), string(mode)).
 		Observe(deleteBuild.FinalDuration().Seconds())
 
+	b.observeBuildStages(deleteBuild)
+
 	for idx, build := range b.builds {
 		if build == deleteBuild {
 			b.builds = append(b.builds[0:idx], b.builds[idx+1:]...)
@@ -459,6 +461,62 @@ func (b *buildsHelper) handleOnBuildStageEnd(build *common.Build, stage common.B
 		Observe(duration.Seconds())
 }
 
+// builtinBuildStages are the stages reported by name in
+// gitlab_runner_job_stage_duration_seconds. Unless the high cardinality
+// metrics are exported, the other stages, which run the user scripts and
+// steps, are reported as user_script so that the stage label doesn't depend
+// on the jobs.
+var builtinBuildStages = map[common.BuildStage]bool{
+	common.BuildStageResolveSecrets:           true,
+	common.BuildStagePrepareExecutor:          true,
+	common.BuildStagePrepare:                  true,
+	common.BuildStageGetSources:               true,
+	common.BuildStageClearWorktree:            true,
+	common.BuildStageRestoreCache:             true,
+	common.BuildStageDownloadArtifacts:        true,
+	common.BuildStageAfterScript:              true,
+	common.BuildStageArchiveOnSuccessCache:    true,
+	common.BuildStageArchiveOnFailureCache:    true,
+	common.BuildStageUploadOnSuccessArtifacts: true,
+	common.BuildStageUploadOnFailureArtifacts: true,
+	common.BuildStageCleanup:                  true,
+}
+
+const userScriptBuildStage = "user_script"
+
+func buildStageLabel(stage common.BuildStage) string {
+	if builtinBuildStages[stage] {
+		return string(stage)
+	}
+
+	return userScriptBuildStage
+}
+
+// observeBuildStages reports the duration of the stages recorded in the
+// timeline of the build. The user script stages of a job are summed. With the
+// high cardinality metrics, each stage was already reported as it ended.
+func (b *buildsHelper) observeBuildStages(build *common.Build) {
+	if build.IsFeatureFlagOn(featureflags.ExportHighCardinalityMetrics) {
+		return
+	}
+
+	durations := map[string]float64{}
+	for _, stage := range build.Timeline().Stages() {
+		durations[buildStageLabel(common.BuildStage(stage.Name))] += stage.DurationSeconds
+	}
+
+	for stage, duration := range durations {
+		b.jobStagesDurationHistogram.
+			WithLabelValues(
+				build.Runner.ShortDescription(),
+				build.Runner.Name,
+				build.Runner.GetSystemID(),
+				stage,
+			).
+			Observe(duration)
+	}
+}
+
 func (b *buildsHelper) handleOnJobExecutionModeDispatched(mode common.JobExecutionMode, executor string) {
 	if executor == "" {
 		executor = "unknown"
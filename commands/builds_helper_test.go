@@ -662,6 +662,75 @@ func TestPrepareStageMetricsNoFF(t *testing.T) {
 	require.Nil(t, bh.buildStagesStartTimes)
 }
 
+func TestBuildStageMetrics(t *testing.T) {
+	build := &common.Build{
+		Runner: &common.RunnerConfig{
+			RunnerCredentials: common.RunnerCredentials{
+				Token: testToken,
+			},
+		},
+		Job: spec.Job{ID: 1},
+	}
+
+	for _, stage := range []common.BuildStage{
+		common.BuildStageGetSources,
+		"step_script",
+		"step_release",
+		common.BuildStageUploadOnSuccessArtifacts,
+	} {
+		build.Timeline().StartStage(stage)()
+	}
+
+	bh := newBuildsHelper()
+	bh.addBuild(build)
+	bh.removeBuild(build)
+
+	ch := make(chan prometheus.Metric, 10)
+	bh.jobStagesDurationHistogram.Collect(ch)
+	close(ch)
+
+	counts := map[string]uint64{}
+	for m := range ch {
+		var mm dto.Metric
+		require.NoError(t, m.Write(&mm))
+
+		for _, label := range mm.GetLabel() {
+			if label.GetName() == "stage" {
+				counts[label.GetValue()] = mm.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]uint64{
+		"get_sources":                 1,
+		"user_script":                 1,
+		"upload_artifacts_on_success": 1,
+	}, counts)
+}
+
+func TestBuildStageMetricsHighCardinality(t *testing.T) {
+	build := &common.Build{
+		Runner: &common.RunnerConfig{
+			RunnerCredentials: common.RunnerCredentials{
+				Token: testToken,
+			},
+		},
+		Job: spec.Job{ID: 1},
+	}
+	build.Runner.Environment = append(build.Runner.Environment, fmt.Sprintf("%s=true", featureflags.ExportHighCardinalityMetrics))
+	build.Timeline().StartStage("step_script")()
+
+	bh := newBuildsHelper()
+	bh.addBuild(build)
+	bh.removeBuild(build)
+
+	ch := make(chan prometheus.Metric, 10)
+	bh.jobStagesDurationHistogram.Collect(ch)
+	close(ch)
+
+	assert.Empty(t, ch, "each stage is reported as it ends instead")
+}
+
 func TestEnsureJobsTotalIsZero(t *testing.T) {
 	runner := &common.RunnerConfig{
 		Name: testName,
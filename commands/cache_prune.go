@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/internal/configfile"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// cachePruneURLTimeout is the validity of the URLs adapters may presign to
+// list and delete objects.
+const cachePruneURLTimeout = time.Hour
+
+// CachePruneCommand deletes cache objects according to the runners'
+// [runners.cache.prune] policies, optionally overridden by flags.
+type CachePruneCommand struct {
+	ConfigFile   string        `short:"c" long:"config" env:"CONFIG_FILE" description:"Config file"`
+	RunnerName   string        `long:"runner" description:"Only prune the cache of the runner with this name"`
+	MaxAge       time.Duration `long:"max-age" description:"Delete cache objects not modified for longer than this duration, overrides MaxAge"`
+	MaxTotalSize int64         `long:"max-total-size" description:"Delete the least recently modified cache objects until the cache uses at most this many bytes, overrides MaxTotalSize"`
+	KeepLast     int           `long:"keep-last" description:"Never delete the most recently modified objects of each cache key, overrides KeepLast"`
+	DryRun       bool          `long:"dry-run" description:"Only report the cache objects that would be deleted"`
+
+	AllowBucketRoot bool `long:"allow-bucket-root" description:"Allow pruning a shared cache without Path, which considers every object of the bucket, overrides AllowBucketRoot"`
+}
+
+// NewCacheCommand creates the cli.Command grouping the shared cache commands.
+func NewCacheCommand() cli.Command {
+	return common.NewCommandWithSubcommands(
+		"cache",
+		"manage the shared cache",
+		common.CommanderFunc(func(ctx *cli.Context) {
+			_ = cli.ShowCommandHelp(ctx, "cache")
+		}),
+		false,
+		[]cli.Command{
+			common.NewCommand("prune", "delete cache objects according to the configured prune policy", &CachePruneCommand{
+				ConfigFile: GetDefaultConfigFile(),
+			}),
+		},
+	)
+}
+
+// Execute runs the cache prune command.
+func (c *CachePruneCommand) Execute(_ *cli.Context) {
+	cfg := configfile.New(c.ConfigFile)
+	if err := cfg.Load(); err != nil {
+		logrus.Fatalln(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var failed bool
+	pruned := map[string]bool{}
+	for _, runner := range cfg.Config().Runners {
+		if c.RunnerName != "" && runner.Name != c.RunnerName {
+			continue
+		}
+
+		logger := logrus.WithField("runner", runner.ShortDescription())
+		if runner.Cache == nil || runner.Cache.Type == "" {
+			logger.Debugln("Runner has no cache configured, skipping")
+			continue
+		}
+
+		key := cachePruneKey(runner)
+		if pruned[key] {
+			logger.Debugln("Runner shares its cache with an already pruned runner, skipping")
+			continue
+		}
+		pruned[key] = true
+
+		policy := c.policy(runner)
+		if policy.IsEmpty() {
+			logger.Infoln("No cache prune policy configured, skipping")
+			continue
+		}
+
+		report, err := pruneRunnerCache(ctx, runner, policy, c.DryRun)
+		logPruneReport(logger, report)
+		if err != nil {
+			logger.WithError(err).Errorln("Failed to prune cache")
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// policy returns the runner's prune policy with the command's flags applied.
+func (c *CachePruneCommand) policy(runner *common.RunnerConfig) cache.PrunePolicy {
+	policy := cache.NewPrunePolicy(runner.Cache.Prune)
+	if c.MaxAge > 0 {
+		policy.MaxAge = c.MaxAge
+	}
+	if c.MaxTotalSize > 0 {
+		policy.MaxTotalSize = c.MaxTotalSize
+	}
+	if c.KeepLast > 0 {
+		policy.KeepLast = c.KeepLast
+	}
+	if c.AllowBucketRoot {
+		policy.AllowBucketRoot = true
+	}
+
+	return policy
+}
+
+// cachePruneKey identifies the objects a runner's prune would act on, so
+// that a cache shared between several runners is pruned only once.
+func cachePruneKey(runner *common.RunnerConfig) string {
+	config := *runner.Cache
+	config.Prune = nil
+
+	data, _ := json.Marshal(config)
+
+	return cache.PrunePrefix(runner.Cache, runner.ShortDescription()) + "\x00" + string(data)
+}
+
+func pruneRunnerCache(ctx context.Context, runner *common.RunnerConfig, policy cache.PrunePolicy, dryRun bool) (cache.PruneReport, error) {
+	manager, err := cache.GetObjectManager(runner.Cache, cachePruneURLTimeout)
+	if err != nil {
+		return cache.PruneReport{}, err
+	}
+
+	return cache.Prune(ctx, manager, cache.PrunePrefix(runner.Cache, runner.ShortDescription()), policy, dryRun)
+}
+
+func logPruneReport(logger logrus.FieldLogger, report cache.PruneReport) {
+	for _, obj := range report.Evicted {
+		fields := logrus.Fields{
+			"object":        obj.Name,
+			"size":          obj.Size,
+			"last_modified": obj.LastModified.Format(time.RFC3339),
+			"reason":        obj.Reason,
+		}
+
+		switch {
+		case report.DryRun:
+			logger.WithFields(fields).Infoln("Would delete cache object")
+		case obj.Deleted:
+			logger.WithFields(fields).Infoln("Deleted cache object")
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"prefix":       report.Prefix,
+		"dry_run":      report.DryRun,
+		"kept":         len(report.Kept),
+		"kept_size":    report.KeptSize(),
+		"evicted":      len(report.Evicted),
+		"evicted_size": report.EvictedSize(),
+	}).Infoln("Pruned cache")
+}
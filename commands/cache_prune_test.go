@@ -0,0 +1,52 @@
+//go:build !integration
+
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestCachePruneCommandPolicy(t *testing.T) {
+	runner := &common.RunnerConfig{
+		RunnerSettings: common.RunnerSettings{
+			Cache: &cacheconfig.Config{
+				Prune: &cacheconfig.CachePruneConfig{MaxAge: time.Hour, MaxTotalSize: 100, KeepLast: 1},
+			},
+		},
+	}
+
+	cmd := &CachePruneCommand{}
+	assert.Equal(t, cache.PrunePolicy{MaxAge: time.Hour, MaxTotalSize: 100, KeepLast: 1}, cmd.policy(runner))
+
+	cmd = &CachePruneCommand{MaxAge: time.Minute, MaxTotalSize: 10, KeepLast: 2}
+	assert.Equal(t, cache.PrunePolicy{MaxAge: time.Minute, MaxTotalSize: 10, KeepLast: 2}, cmd.policy(runner))
+
+	cmd = &CachePruneCommand{AllowBucketRoot: true}
+	assert.Equal(t, cache.PrunePolicy{MaxAge: time.Hour, MaxTotalSize: 100, KeepLast: 1, AllowBucketRoot: true}, cmd.policy(runner))
+}
+
+func TestCachePruneKey(t *testing.T) {
+	newRunner := func(token string, config cacheconfig.Config) *common.RunnerConfig {
+		return &common.RunnerConfig{
+			RunnerCredentials: common.RunnerCredentials{Token: token},
+			RunnerSettings:    common.RunnerSettings{Cache: &config},
+		}
+	}
+
+	shared := cacheconfig.Config{Type: "s3", Shared: true, S3: &cacheconfig.CacheS3Config{BucketName: "bucket"}}
+	sharedWithPrune := shared
+	sharedWithPrune.Prune = &cacheconfig.CachePruneConfig{MaxAge: time.Hour}
+	otherBucket := cacheconfig.Config{Type: "s3", Shared: true, S3: &cacheconfig.CacheS3Config{BucketName: "other"}}
+	notShared := cacheconfig.Config{Type: "s3", S3: &cacheconfig.CacheS3Config{BucketName: "bucket"}}
+
+	assert.Equal(t, cachePruneKey(newRunner("glrt-token1", shared)), cachePruneKey(newRunner("glrt-token2", sharedWithPrune)))
+	assert.NotEqual(t, cachePruneKey(newRunner("glrt-token1", shared)), cachePruneKey(newRunner("glrt-token1", otherBucket)))
+	assert.NotEqual(t, cachePruneKey(newRunner("glrt-token1", notShared)), cachePruneKey(newRunner("glrt-token2", notShared)))
+}
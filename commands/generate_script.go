@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/internal/configfile"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+)
+
+// GenerateScriptCommand writes the scripts a runner generates for the stages
+// of a job payload, without running the job. The masked variables and the
+// tokens of the job are redacted before the scripts are generated, and the
+// cache is accessed with placeholder URLs.
+type GenerateScriptCommand struct {
+	executorProviders executors.Providers
+
+	ConfigFile string `short:"c" long:"config" env:"CONFIG_FILE" description:"Config file"`
+	RunnerName string `short:"r" long:"runner" description:"Name of the runner from the config file to generate the scripts with"`
+	JobFile    string `long:"job" description:"Job payload to generate the scripts for, like the ones recorded by the run command's --record-jobs-dir option"`
+	OutputDir  string `short:"o" long:"output-dir" description:"Directory to write the script of each stage to"`
+	Shell      string `long:"shell" description:"Generate the scripts for this shell instead of the shell of the runner"`
+}
+
+// scriptCacheType is the type of the cache the scripts are generated with. Its
+// adapter returns placeholder URLs, for the presigned URLs and the credentials
+// of the runner's cache not to be written to the scripts, which would also
+// differ each time they're generated.
+const scriptCacheType = "generate-script"
+
+// scriptCacheURL is the URL the cache objects are downloaded from and uploaded
+// to in the generated scripts. The .invalid domain never resolves.
+var scriptCacheURL = url.URL{Scheme: "https", Host: "cache.invalid"}
+
+type scriptCacheAdapter struct {
+	objectName string
+}
+
+func (a *scriptCacheAdapter) GetDownloadURL(context.Context) cache.PresignedURL {
+	return cache.PresignedURL{URL: a.url()}
+}
+
+func (a *scriptCacheAdapter) GetHeadURL(context.Context) cache.PresignedURL {
+	return cache.PresignedURL{URL: a.url()}
+}
+
+func (a *scriptCacheAdapter) WithMetadata(map[string]string) {}
+
+func (a *scriptCacheAdapter) GetUploadURL(context.Context) cache.PresignedURL {
+	return cache.PresignedURL{URL: a.url()}
+}
+
+func (a *scriptCacheAdapter) GetGoCloudURL(context.Context, bool) (cache.GoCloudURL, error) {
+	return cache.GoCloudURL{}, nil
+}
+
+func (a *scriptCacheAdapter) url() *url.URL {
+	u := scriptCacheURL
+	u.Path = "/" + a.objectName
+
+	return &u
+}
+
+func init() {
+	err := cache.Factories().Register(scriptCacheType, func(_ *cacheconfig.Config, _ time.Duration, objectName string) (cache.Adapter, error) {
+		return &scriptCacheAdapter{objectName: objectName}, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// scriptCacheConfig returns the cache config the scripts are generated with:
+// the settings of the runner's cache the scripts depend on, with the adapter
+// of scriptCacheType.
+func scriptCacheConfig(config *cacheconfig.Config) *cacheconfig.Config {
+	if config == nil {
+		return nil
+	}
+
+	return &cacheconfig.Config{
+		Type:                   scriptCacheType,
+		Path:                   config.Path,
+		Shared:                 config.Shared,
+		MaxUploadedArchiveSize: config.MaxUploadedArchiveSize,
+	}
+}
+
+func NewGenerateScriptCommand(executorProviders executors.Providers) cli.Command {
+	return common.NewCommand("generate-script", "write the scripts generated for the stages of a job payload", &GenerateScriptCommand{
+		executorProviders: executorProviders,
+		ConfigFile:        GetDefaultConfigFile(),
+		OutputDir:         "scripts",
+	})
+}
+
+func (c *GenerateScriptCommand) Execute(_ *cli.Context) {
+	paths, err := c.run()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to generate the scripts")
+	}
+
+	for _, path := range paths {
+		logrus.WithField("path", path).Infoln("Generated script")
+	}
+}
+
+func (c *GenerateScriptCommand) run() ([]string, error) {
+	if c.JobFile == "" {
+		return nil, errors.New("missing job file")
+	}
+	if c.RunnerName == "" {
+		return nil, errors.New("missing runner name")
+	}
+
+	cfg := configfile.New(c.ConfigFile)
+	if err := cfg.Load(); err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	runner, err := cfg.Config().RunnerByName(c.RunnerName)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := loadJob(c.JobFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading job: %w", err)
+	}
+
+	return c.generate(runner, job)
+}
+
+// generate writes the script of each stage of the job that has one, and
+// returns their paths
+func (c *GenerateScriptCommand) generate(runner *common.RunnerConfig, job *spec.Job) ([]string, error) {
+	executorProvider := c.executorProviders.GetByName(runner.Executor)
+	if executorProvider == nil {
+		return nil, fmt.Errorf("unknown executor %q", runner.Executor)
+	}
+
+	redacted, err := redactJob(*job)
+	if err != nil {
+		return nil, err
+	}
+
+	runnerConfig := *runner
+	runnerConfig.Cache = scriptCacheConfig(runner.Cache)
+	if c.Shell != "" {
+		runnerConfig.Shell = c.Shell
+	}
+
+	build, err := common.NewBuild(redacted, &runnerConfig, nil, nil, executorProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = executorProvider.GetFeatures(&build.ExecutorFeatures)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving executor features: %w", err)
+	}
+
+	ctx := context.Background()
+
+	executor := &executors.AbstractExecutor{ExecutorOptions: scriptExecutorOptions(executorProvider)}
+	executor.PrepareConfiguration(common.ExecutorPrepareOptions{
+		Config:      &runnerConfig,
+		Build:       build,
+		BuildLogger: buildlogger.New(nil, logrus.WithField("job", job.ID), buildlogger.Options{}),
+		Context:     ctx,
+	})
+
+	err = executor.PrepareBuildAndShell()
+	if err != nil {
+		return nil, err
+	}
+
+	extension := executor.BuildShell.Extension
+	if extension == "" {
+		extension = "sh"
+	}
+
+	if err := os.MkdirAll(c.OutputDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, stage := range build.BuildStages() {
+		script, err := common.GenerateShellScript(ctx, stage, *executor.Shell())
+		if errors.Is(err, common.ErrSkipBuildStage) {
+			continue
+		}
+		if err != nil {
+			return paths, fmt.Errorf("generating script of stage %s: %w", stage, err)
+		}
+
+		// Nothing to execute
+		if script == "" {
+			continue
+		}
+
+		path := filepath.Join(c.OutputDir, string(stage)+"."+extension)
+		if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// scriptExecutorOptions returns the defaults of the executors of the
+// provider, like their shell and builds directory
+func scriptExecutorOptions(executorProvider common.ExecutorProvider) executors.ExecutorOptions {
+	var options executors.ExecutorOptions
+	if executorProvider.CanCreate() {
+		if e, ok := executorProvider.Create().(executors.ExecutorOptionsGetter); ok {
+			options = e.GetExecutorOptions()
+		}
+	}
+
+	if options.DefaultBuildsDir == "" {
+		options.DefaultBuildsDir = "builds"
+	}
+	if options.DefaultCacheDir == "" {
+		options.DefaultCacheDir = "cache"
+	}
+	if options.Shell.RunnerCommand == "" {
+		options.Shell.RunnerCommand = "gitlab-runner"
+	}
+	if options.Shell.Shell == "" {
+		options.Shell.Shell = executorProvider.GetDefaultShell()
+	}
+
+	return options
+}
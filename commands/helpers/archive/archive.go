@@ -37,6 +37,7 @@ const (
 	Zip     Format = "zip"
 	ZipZstd Format = "zipzstd"
 	TarZstd Format = "tarzstd"
+	Chunked Format = "chunked"
 )
 
 var (
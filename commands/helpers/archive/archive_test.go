@@ -10,6 +10,7 @@ import (
 
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
 
+	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
 	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/fastzip"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/gziplegacy"
 	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/raw"
@@ -26,6 +27,7 @@ func TestDefaultRegistration(t *testing.T) {
 		archive.Zip:     {hasArchiver: true, hasExtractor: true},
 		archive.ZipZstd: {hasArchiver: true, hasExtractor: true},
 		archive.TarZstd: {hasArchiver: true, hasExtractor: true},
+		archive.Chunked: {hasArchiver: true, hasExtractor: true},
 	}
 
 	for tn, tc := range tests {
@@ -0,0 +1,95 @@
+package chunked
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/tarzstd"
+)
+
+func init() {
+	archive.Register(archive.Chunked, NewArchiver, NewExtractor)
+}
+
+var levels = map[archive.CompressionLevel]zstd.EncoderLevel{
+	archive.FastestCompression: zstd.SpeedFastest,
+	archive.FastCompression:    zstd.SpeedFastest,
+	archive.DefaultCompression: zstd.SpeedDefault,
+	archive.SlowCompression:    zstd.SpeedBetterCompression,
+	archive.SlowestCompression: zstd.SpeedBestCompression,
+}
+
+// archiver splits a tar stream of the files into content-defined chunks,
+// compressed individually.
+type archiver struct {
+	w     io.Writer
+	dir   string
+	level archive.CompressionLevel
+}
+
+// NewArchiver returns a new chunked archiver.
+func NewArchiver(w io.Writer, dir string, level archive.CompressionLevel) (archive.Archiver, error) {
+	return &archiver{w: w, dir: dir, level: level}, nil
+}
+
+// Archive archives all files.
+func (a *archiver) Archive(ctx context.Context, files map[string]os.FileInfo) error {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(levels[a.level]), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = pw.CloseWithError(tarzstd.WriteTar(ctx, pw, a.dir, files))
+	}()
+	defer func() {
+		_ = pr.Close()
+		<-done
+	}()
+
+	if _, err := a.w.Write(Magic); err != nil {
+		return err
+	}
+
+	var idx Index
+	seen := map[string]Chunk{}
+	chunker := newChunker(pr)
+	var compressed []byte
+	for {
+		data, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		chunk, ok := seen[hash]
+		if !ok {
+			compressed = enc.EncodeAll(data, compressed[:0])
+			if _, err := a.w.Write(compressed); err != nil {
+				return err
+			}
+
+			chunk = Chunk{Hash: hash, Size: int64(len(data)), CompressedSize: int64(len(compressed))}
+			seen[hash] = chunk
+		}
+
+		idx.Chunks = append(idx.Chunks, chunk)
+	}
+
+	return WriteIndex(a.w, idx)
+}
@@ -0,0 +1,96 @@
+package chunked
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/tarzstd"
+)
+
+// extractor reassembles the tar stream of a complete chunked archive.
+type extractor struct {
+	r    io.ReaderAt
+	size int64
+	dir  string
+}
+
+// NewExtractor returns a new chunked extractor.
+func NewExtractor(r io.ReaderAt, size int64, dir string) (archive.Extractor, error) {
+	return &extractor{r: r, size: size, dir: dir}, nil
+}
+
+// Extract extracts files from the archive to the directory passed to
+// NewExtractor. Every chunk is verified against its hash.
+func (e *extractor) Extract(ctx context.Context) error {
+	a, err := Open(e.r, e.size)
+	if err != nil {
+		return err
+	}
+
+	if !a.Complete() {
+		return ErrIncompleteArchive
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = pw.CloseWithError(a.writeStream(ctx, pw))
+	}()
+
+	err = tarzstd.ExtractTar(ctx, pr, e.dir)
+	// the tar reader stops at the end-of-archive marker, ignoring padding
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
+	return err
+}
+
+// writeStream writes the decompressed chunks of the archive to w.
+func (a *Archive) writeStream(ctx context.Context, w io.Writer) error {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	var compressed, data []byte
+	for _, c := range a.Index.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r, ok := a.ChunkReader(c)
+		if !ok {
+			return fmt.Errorf("chunk %s: %w", c.Hash, ErrIncompleteArchive)
+		}
+
+		compressed = slices.Grow(compressed[:0], int(c.CompressedSize))[:c.CompressedSize]
+		if _, err := io.ReadFull(r, compressed); err != nil {
+			return fmt.Errorf("reading chunk %s: %w", c.Hash, err)
+		}
+
+		data, err = dec.DecodeAll(compressed, data[:0])
+		if err != nil {
+			return fmt.Errorf("decompressing chunk %s: %w", c.Hash, err)
+		}
+
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != c.Size || hex.EncodeToString(sum[:]) != c.Hash {
+			return fmt.Errorf("chunk %s is corrupted", c.Hash)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+//go:build !integration
+
+package chunked
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
+)
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunks(t *testing.T, data []byte) [][]byte {
+	var out [][]byte
+
+	c := newChunker(bytes.NewReader(data))
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, bytes.Clone(chunk))
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := randomData(1, 16<<20)
+
+	original := chunks(t, data)
+	assert.Equal(t, data, bytes.Join(original, nil))
+	for i, chunk := range original {
+		assert.LessOrEqual(t, len(chunk), maxChunkSize)
+		if i < len(original)-1 {
+			assert.GreaterOrEqual(t, len(chunk), minChunkSize)
+		}
+	}
+
+	// inserting data only changes the chunks around the insertion
+	modified := bytes.Clone(data[:8<<20])
+	modified = append(modified, []byte("inserted")...)
+	modified = append(modified, data[8<<20:]...)
+
+	known := map[string]bool{}
+	for _, chunk := range original {
+		known[string(chunk)] = true
+	}
+
+	var changed int
+	for _, chunk := range chunks(t, modified) {
+		if !known[string(chunk)] {
+			changed++
+		}
+	}
+	assert.LessOrEqual(t, changed, 2)
+}
+
+func writeFiles(t *testing.T, dir string, contents map[string][]byte) map[string]os.FileInfo {
+	files := map[string]os.FileInfo{}
+	for name, data := range contents {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+
+	_ = filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if !info.IsDir() {
+			files[path] = info
+		}
+		return nil
+	})
+
+	return files
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, map[string][]byte{
+		"small":  []byte("12345678"),
+		"large":  randomData(2, 6<<20),
+		"repeat": bytes.Repeat([]byte("a"), 12<<20),
+	})
+
+	buf := new(bytes.Buffer)
+	archiver, err := NewArchiver(buf, dir, archive.DefaultCompression)
+	require.NoError(t, err)
+	require.NoError(t, archiver.Archive(t.Context(), files))
+
+	a, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.True(t, a.Complete())
+	assert.Less(t, len(a.Index.Unique()), len(a.Index.Chunks), "repeated chunks are stored once")
+
+	out := t.TempDir()
+	extractor, err := NewExtractor(bytes.NewReader(buf.Bytes()), int64(buf.Len()), out)
+	require.NoError(t, err)
+	require.NoError(t, extractor.Extract(t.Context()))
+
+	for _, name := range []string{"small", "large", "repeat"} {
+		expected, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(out, name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, name)
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		manifest := new(bytes.Buffer)
+		require.NoError(t, WriteManifest(manifest, a.Index))
+
+		m, err := Open(bytes.NewReader(manifest.Bytes()), int64(manifest.Len()))
+		require.NoError(t, err)
+		assert.False(t, m.Complete())
+		assert.Equal(t, a.Index, m.Index)
+
+		_, ok := m.ChunkReader(m.Index.Chunks[0])
+		assert.False(t, ok)
+
+		extractor, err := NewExtractor(bytes.NewReader(manifest.Bytes()), int64(manifest.Len()), t.TempDir())
+		require.NoError(t, err)
+		assert.ErrorIs(t, extractor.Extract(t.Context()), ErrIncompleteArchive)
+	})
+
+	t.Run("corrupted chunk", func(t *testing.T) {
+		corrupted := bytes.Clone(buf.Bytes())
+		// zero the data of the first chunk, keeping the layout valid
+		unique := a.Index.Unique()
+		require.NotEmpty(t, unique)
+		offsets := a.Index.Offsets()
+		copy(corrupted[offsets[unique[0].Hash]:], bytes.Repeat([]byte{0}, int(unique[0].CompressedSize)))
+
+		extractor, err := NewExtractor(bytes.NewReader(corrupted), int64(len(corrupted)), t.TempDir())
+		require.NoError(t, err)
+		assert.Error(t, extractor.Extract(t.Context()))
+	})
+}
+
+func TestOpenInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":         nil,
+		"not chunked":   []byte("PK\x03\x04 some zip archive"),
+		"bad trailer":   append(bytes.Clone(Magic), 0, 0, 0, 0, 0, 0, 1, 0),
+		"invalid index": append(append(bytes.Clone(Magic), []byte("{]")...), 0, 0, 0, 0, 0, 0, 0, 2),
+		"data size mismatch": append(append(bytes.Clone(Magic), []byte(`x{"chunks":[]}`)...),
+			0, 0, 0, 0, 0, 0, 0, 13),
+	}
+
+	for tn, data := range tests {
+		t.Run(tn, func(t *testing.T) {
+			_, err := Open(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+		})
+	}
+}
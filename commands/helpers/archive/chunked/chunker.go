@@ -0,0 +1,103 @@
+package chunked
+
+import (
+	"io"
+)
+
+// Chunk boundaries are content-defined: a boundary is placed where the gear
+// hash of the preceding bytes matches a mask, so that inserting or removing
+// data only changes the chunks around the modification. The masks are
+// normalized (FastCDC): a harder mask before the average size and an easier
+// one after it narrow the distribution of chunk sizes.
+const (
+	minChunkSize = 256 << 10
+	avgChunkSize = 1 << 20
+	maxChunkSize = 4 << 20
+
+	// the gear hash shifts left, so its high bits depend on the most bytes
+	maskHard = uint64(1<<22-1) << (64 - 22)
+	maskEasy = uint64(1<<18-1) << (64 - 18)
+)
+
+// gear maps bytes to random values. It's part of the archive format: any
+// change would move all chunk boundaries, defeating deduplication against
+// previously stored chunks.
+var gear = func() (table [256]uint64) {
+	// splitmix64
+	state := uint64(0x6769746c61622d72) // "gitlab-r"
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into content-defined chunks.
+type chunker struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	eof bool
+
+	last int
+}
+
+func newChunker(r io.Reader) *chunker {
+	return &chunker{r: r, buf: make([]byte, maxChunkSize)}
+}
+
+// Next returns the next chunk of the stream, or io.EOF once the stream is
+// exhausted. The returned slice is only valid until the next call.
+func (c *chunker) Next() ([]byte, error) {
+	copy(c.buf, c.buf[c.last:c.n])
+	c.n -= c.last
+	c.last = 0
+
+	for !c.eof && c.n < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.n:])
+		c.n += n
+		if err == io.EOF {
+			c.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+
+	c.last = cutPoint(c.buf[:c.n])
+	return c.buf[:c.last], nil
+}
+
+// cutPoint returns the length of the chunk starting data.
+func cutPoint(data []byte) int {
+	n := len(data)
+	if n <= minChunkSize {
+		return n
+	}
+	n = min(n, maxChunkSize)
+
+	var hash uint64
+	i := minChunkSize
+	for normal := min(n, avgChunkSize); i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&maskHard == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&maskEasy == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,197 @@
+package chunked
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Magic starts every chunked archive.
+//
+// An archive is laid out as:
+//
+//	Magic | chunk data | index (JSON) | index length (uint64, big endian)
+//
+// The chunk data holds the zstd compressed data of every distinct chunk
+// of the index, in order of first appearance. An archive without chunk
+// data is a manifest: it describes an archive whose chunks are stored
+// elsewhere.
+var Magic = []byte("GLCHUNK1")
+
+const trailerSize = 8
+
+// maxIndexSize bounds the index read from an archive, protecting against
+// corrupted trailers.
+const maxIndexSize = 512 << 20
+
+// GracePeriod is how long a chunk store keeps the chunks no manifest
+// references. Archives are stored by uploading their chunks before their
+// manifest, and the stored chunks they reuse are uploaded again once older
+// than half of it, so that they aren't deleted before the manifest is stored.
+const GracePeriod = 24 * time.Hour
+
+var (
+	// ErrInvalidArchive is returned when opening data that isn't a chunked
+	// archive.
+	ErrInvalidArchive = errors.New("invalid chunked archive")
+
+	// ErrIncompleteArchive is returned when extracting a manifest, which
+	// holds no chunk data.
+	ErrIncompleteArchive = errors.New("chunked archive holds no chunk data")
+)
+
+// Chunk describes a chunk of the tar stream of an archive.
+type Chunk struct {
+	// Hash is the hex encoded SHA-256 of the uncompressed chunk.
+	Hash string `json:"hash"`
+	// Size is the size of the uncompressed chunk.
+	Size int64 `json:"size"`
+	// CompressedSize is the size of the zstd compressed chunk.
+	CompressedSize int64 `json:"compressed_size"`
+}
+
+// Index lists the chunks that, concatenated, make up the tar stream of an
+// archive.
+type Index struct {
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Unique returns the distinct chunks of the index, in order of first
+// appearance. It's the order in which their data is stored in an archive.
+func (idx Index) Unique() []Chunk {
+	seen := make(map[string]bool, len(idx.Chunks))
+	unique := make([]Chunk, 0, len(idx.Chunks))
+	for _, c := range idx.Chunks {
+		if seen[c.Hash] {
+			continue
+		}
+		seen[c.Hash] = true
+		unique = append(unique, c)
+	}
+
+	return unique
+}
+
+// Offsets returns the offset of the data of every distinct chunk in a
+// complete archive.
+func (idx Index) Offsets() map[string]int64 {
+	offsets := make(map[string]int64, len(idx.Chunks))
+	offset := int64(len(Magic))
+	for _, c := range idx.Unique() {
+		offsets[c.Hash] = offset
+		offset += c.CompressedSize
+	}
+
+	return offsets
+}
+
+// DataSize returns the size of the chunk data of a complete archive.
+func (idx Index) DataSize() int64 {
+	var size int64
+	for _, c := range idx.Unique() {
+		size += c.CompressedSize
+	}
+
+	return size
+}
+
+// WriteIndex writes the index and the trailer that end an archive.
+func WriteIndex(w io.Writer, idx Index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("encoding index: %w", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.BigEndian, uint64(len(data)))
+}
+
+// WriteManifest writes the manifest of the index: an archive without chunk
+// data.
+func WriteManifest(w io.Writer, idx Index) error {
+	if _, err := w.Write(Magic); err != nil {
+		return err
+	}
+
+	return WriteIndex(w, idx)
+}
+
+// Archive is an opened chunked archive.
+type Archive struct {
+	Index Index
+
+	r        io.ReaderAt
+	offsets  map[string]int64
+	complete bool
+}
+
+// Open reads the index of the archive held by r.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	if size < int64(len(Magic))+trailerSize {
+		return nil, ErrInvalidArchive
+	}
+
+	magic := make([]byte, len(Magic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, Magic) {
+		return nil, ErrInvalidArchive
+	}
+
+	var trailer [trailerSize]byte
+	if _, err := r.ReadAt(trailer[:], size-trailerSize); err != nil {
+		return nil, err
+	}
+
+	indexSize := binary.BigEndian.Uint64(trailer[:])
+	dataSize := size - int64(len(Magic)) - trailerSize - int64(indexSize)
+	if indexSize > maxIndexSize || dataSize < 0 {
+		return nil, fmt.Errorf("%w: index size %d out of bounds", ErrInvalidArchive, indexSize)
+	}
+
+	data := make([]byte, indexSize)
+	if _, err := r.ReadAt(data, int64(len(Magic))+dataSize); err != nil {
+		return nil, err
+	}
+
+	a := &Archive{r: r}
+	if err := json.Unmarshal(data, &a.Index); err != nil {
+		return nil, fmt.Errorf("%w: decoding index: %w", ErrInvalidArchive, err)
+	}
+
+	switch dataSize {
+	case 0:
+	case a.Index.DataSize():
+		a.complete = true
+		a.offsets = a.Index.Offsets()
+	default:
+		return nil, fmt.Errorf("%w: holds %d bytes of chunk data, index needs %d", ErrInvalidArchive, dataSize, a.Index.DataSize())
+	}
+
+	return a, nil
+}
+
+// Complete reports whether the archive holds the data of its chunks, as
+// opposed to being a manifest.
+func (a *Archive) Complete() bool {
+	return a.complete || len(a.Index.Chunks) == 0
+}
+
+// ChunkReader returns the compressed data of the chunk, if the archive holds
+// it.
+func (a *Archive) ChunkReader(c Chunk) (*io.SectionReader, bool) {
+	offset, ok := a.offsets[c.Hash]
+	if !ok {
+		return nil, false
+	}
+
+	return io.NewSectionReader(a.r, offset, c.CompressedSize), true
+}
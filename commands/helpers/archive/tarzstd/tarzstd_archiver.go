@@ -41,22 +41,33 @@ func NewArchiver(w io.Writer, dir string, level archive.CompressionLevel) (archi
 }
 
 // Archive archives all files.
+func (a *archiver) Archive(ctx context.Context, files map[string]os.FileInfo) error {
+	zw, err := zstd.NewWriter(a.w, zstd.WithEncoderLevel(zstd.EncoderLevel(levels[a.level])))
+	if err != nil {
+		return err
+	}
+	defer zw.Close()
+
+	if err := WriteTar(ctx, zw, a.dir, files); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// WriteTar writes an uncompressed tar stream of the files, which must be
+// children of dir, to w. Files are written in name order, so that archiving
+// the same files twice produces the same stream.
 //
 //nolint:gocognit
-func (a *archiver) Archive(ctx context.Context, files map[string]os.FileInfo) error {
+func WriteTar(ctx context.Context, w io.Writer, dir string, files map[string]os.FileInfo) error {
 	sorted := make([]string, 0, len(files))
 	for filename := range files {
 		sorted = append(sorted, filename)
 	}
 	sort.Strings(sorted)
 
-	zw, err := zstd.NewWriter(a.w, zstd.WithEncoderLevel(zstd.EncoderLevel(levels[a.level])))
-	if err != nil {
-		return err
-	}
-	defer zw.Close()
-
-	tw := tar.NewWriter(zw)
+	tw := tar.NewWriter(w)
 	defer tw.Close()
 
 	for _, name := range sorted {
@@ -69,11 +80,11 @@ func (a *archiver) Archive(ctx context.Context, files map[string]os.FileInfo) er
 		if err != nil {
 			return err
 		}
-		if !strings.HasPrefix(path, a.dir+string(filepath.Separator)) && path != a.dir {
-			return fmt.Errorf("%s cannot be archived from outside of chroot (%s)", name, a.dir)
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) && path != dir {
+			return fmt.Errorf("%s cannot be archived from outside of chroot (%s)", name, dir)
 		}
 
-		rel, err := filepath.Rel(a.dir, path)
+		rel, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
@@ -119,9 +130,5 @@ func (a *archiver) Archive(ctx context.Context, files map[string]os.FileInfo) er
 		f.Close()
 	}
 
-	if err := tw.Close(); err != nil {
-		return err
-	}
-
-	return zw.Close()
+	return tw.Close()
 }
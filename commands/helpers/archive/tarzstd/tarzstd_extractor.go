@@ -28,8 +28,6 @@ func NewExtractor(r io.ReaderAt, size int64, dir string) (archive.Extractor, err
 
 // Extract extracts files from the reader to the directory passed to
 // NewZipExtractor.
-//
-//nolint:gocognit
 func (e *extractor) Extract(ctx context.Context) error {
 	zr, err := zstd.NewReader(io.NewSectionReader(e.r, 0, e.size), zstd.WithDecoderLowmem(true))
 	if err != nil {
@@ -37,7 +35,15 @@ func (e *extractor) Extract(ctx context.Context) error {
 	}
 	defer zr.Close()
 
-	tr := tar.NewReader(zr)
+	return ExtractTar(ctx, zr, e.dir)
+}
+
+// ExtractTar extracts the files of the uncompressed tar stream r to dir,
+// refusing files that would be extracted outside of it.
+//
+//nolint:gocognit
+func ExtractTar(ctx context.Context, r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
 
 	deferred := map[string]*tar.Header{}
 	for {
@@ -55,12 +61,12 @@ func (e *extractor) Extract(ctx context.Context) error {
 		}
 
 		var path string
-		path, err = filepath.Abs(filepath.Join(e.dir, hdr.Name))
+		path, err = filepath.Abs(filepath.Join(dir, hdr.Name))
 		if err != nil {
 			return err
 		}
-		if !strings.HasPrefix(path, e.dir+string(filepath.Separator)) && path != e.dir {
-			return fmt.Errorf("%s cannot be extracted outside of chroot (%s)", path, e.dir)
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) && path != dir {
+			return fmt.Errorf("%s cannot be extracted outside of chroot (%s)", path, dir)
 		}
 
 		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
@@ -98,7 +104,7 @@ func (e *extractor) Extract(ctx context.Context) error {
 				return err
 			}
 
-			if err := e.updateFileMetadata(path, hdr); err != nil {
+			if err := updateFileMetadata(path, hdr); err != nil {
 				return err
 			}
 		}
@@ -116,7 +122,7 @@ func (e *extractor) Extract(ctx context.Context) error {
 			}
 		}
 
-		if err := e.updateFileMetadata(path, hdr); err != nil {
+		if err := updateFileMetadata(path, hdr); err != nil {
 			return err
 		}
 	}
@@ -124,7 +130,7 @@ func (e *extractor) Extract(ctx context.Context) error {
 	return nil
 }
 
-func (e *extractor) updateFileMetadata(path string, hdr *tar.Header) error {
+func updateFileMetadata(path string, hdr *tar.Header) error {
 	fi := hdr.FileInfo()
 
 	if err := lchtimes(path, fi.Mode(), time.Now(), fi.ModTime()); err != nil {
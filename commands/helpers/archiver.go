@@ -6,6 +6,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
 
 	// auto-register default archivers/extractors
+	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
 	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/gziplegacy"
 	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/raw"
 	_ "gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/tarzstd"
@@ -13,6 +13,7 @@ import (
 
 	"gitlab.com/gitlab-org/gitlab-runner/commands"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/meter"
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/log"
@@ -153,7 +154,7 @@ func openArchive(filename string) (*os.File, int64, archive.Format, error) {
 		return nil, 0, format, err
 	}
 
-	var magic [4]byte
+	var magic [8]byte
 	_, _ = f.Read(magic[:])
 	_, _ = f.Seek(0, io.SeekStart)
 	switch {
@@ -161,6 +162,8 @@ func openArchive(filename string) (*os.File, int64, archive.Format, error) {
 		format = archive.TarZstd
 	case bytes.HasPrefix(magic[:], gzipMagic):
 		format = archive.Gzip
+	case bytes.HasPrefix(magic[:], chunked.Magic):
+		format = archive.Chunked
 	}
 
 	fi, err := f.Stat()
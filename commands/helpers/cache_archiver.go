@@ -41,11 +41,12 @@ type CacheArchiverCommand struct {
 	URL                    string   `long:"url" description:"URL of remote cache resource (pre-signed URL)"`
 	CheckURL               string   `long:"check-url" description:"(temporary) Pre-signed HEAD URL to check whether the primary cache object already exists"`
 	GoCloudURL             string   `long:"gocloud-url" description:"Go Cloud URL of remote cache resource (requires credentials)"`
+	ChunkPrefix            string   `long:"chunk-prefix" description:"Object name prefix of the chunk store of chunked cache archives (requires --gocloud-url)"`
 	Timeout                int      `long:"timeout" description:"Overall timeout for cache uploading request (in minutes)"`
 	Headers                []string `long:"header" description:"HTTP headers to send with PUT request (in form of 'key:value')"`
 	Metadata               metadata `long:"metadata" env:"CACHE_METADATA" description:"Metadata for the cache artifact (JSON encoded key-value-pairs, e.g. '{\"foo\":\"bar\",\"blerp\":\"blip\"}')"`
 	CompressionLevel       string   `long:"compression-level" env:"CACHE_COMPRESSION_LEVEL" description:"Compression level (fastest, fast, default, slow, slowest)"`
-	CompressionFormat      string   `long:"compression-format" env:"CACHE_COMPRESSION_FORMAT" description:"Compression format (zip, tarzstd, zipzstd)"`
+	CompressionFormat      string   `long:"compression-format" env:"CACHE_COMPRESSION_FORMAT" description:"Compression format (zip, tarzstd, zipzstd, chunked)"`
 	MaxUploadedArchiveSize int64    `long:"max-uploaded-archive-size" env:"CACHE_MAX_UPLOADED_ARCHIVE_SIZE" description:"Limit the size of the cache archive being uploaded to cloud storage, in bytes."`
 	EnvFile                string   `long:"env-file" description:"Filename containing environment variables to read"`
 
@@ -100,6 +101,13 @@ func (c *CacheArchiverCommand) upload(_ int) error {
 		return err
 	}
 
+	if c.GoCloudURL != "" && c.ChunkPrefix != "" {
+		if a := openChunkedArchive(file, fi.Size()); a != nil && a.Complete() {
+			logrus.Infoln("Using GoCloud URL for chunked cache upload")
+			return c.handleChunkedGoCloudURL(a)
+		}
+	}
+
 	rc := meter.NewReader(
 		file,
 		c.TransferMeterFrequency,
@@ -143,29 +151,10 @@ func (c *CacheArchiverCommand) handlePresignedURL(fi os.FileInfo, file io.ReadCl
 func (c *CacheArchiverCommand) handleGoCloudURL(file io.Reader) error {
 	logrus.Infoln("Uploading", filepath.Base(c.File), "to", url_helpers.CleanURL(c.GoCloudURL))
 
-	if c.mux == nil {
-		c.mux = blob.DefaultURLMux()
-	}
-
 	ctx, cancelWrite := context.WithCancel(context.Background())
 	defer cancelWrite()
 
-	u, err := url.Parse(c.GoCloudURL)
-	if err != nil {
-		return err
-	}
-
-	err = loadEnvFile(c.EnvFile)
-	if err != nil {
-		return err
-	}
-
-	objectName := strings.TrimLeft(u.Path, "/")
-	if objectName == "" {
-		return fmt.Errorf("no object name provided")
-	}
-
-	b, err := c.mux.OpenBucket(ctx, c.GoCloudURL)
+	b, objectName, err := c.openGoCloudBucket(ctx)
 	if err != nil {
 		return err
 	}
@@ -202,6 +191,36 @@ func (c *CacheArchiverCommand) handleGoCloudURL(file io.Reader) error {
 	return nil
 }
 
+// openGoCloudBucket opens the bucket of c.GoCloudURL and returns it with the
+// name of the cache object.
+func (c *CacheArchiverCommand) openGoCloudBucket(ctx context.Context) (*blob.Bucket, string, error) {
+	if c.mux == nil {
+		c.mux = blob.DefaultURLMux()
+	}
+
+	u, err := url.Parse(c.GoCloudURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = loadEnvFile(c.EnvFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	objectName := strings.TrimLeft(u.Path, "/")
+	if objectName == "" {
+		return nil, "", fmt.Errorf("no object name provided")
+	}
+
+	b, err := c.mux.OpenBucket(ctx, c.GoCloudURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return b, objectName, nil
+}
+
 func (c *CacheArchiverCommand) createZipFile(filename string) (int64, error) {
 	err := os.MkdirAll(filepath.Dir(filename), 0o700)
 	if err != nil {
@@ -222,6 +241,8 @@ func (c *CacheArchiverCommand) createZipFile(filename string) (int64, error) {
 		c.CompressionFormat = string(spec.ArtifactFormatTarZstd)
 	case string(spec.ArtifactFormatZipZstd):
 		c.CompressionFormat = string(spec.ArtifactFormatZipZstd)
+	case string(archive.Chunked):
+		c.CompressionFormat = string(archive.Chunked)
 	default:
 		c.CompressionFormat = string(spec.ArtifactFormatZip)
 	}
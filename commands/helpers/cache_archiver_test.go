@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
 )
 
@@ -214,6 +215,7 @@ func TestCacheArchiverCompressionFormatSelection(t *testing.T) {
 		"zip":                  {"zip", spec.ArtifactFormatZip},
 		"empty defaults zip":   {"", spec.ArtifactFormatZip},
 		"unknown defaults zip": {"bogus", spec.ArtifactFormatZip},
+		"chunked":              {"chunked", spec.ArtifactFormat(cache.ChunkedFormat)},
 	}
 
 	for name, tc := range tests {
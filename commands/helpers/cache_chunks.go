@@ -0,0 +1,273 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"golang.org/x/sync/errgroup"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
+	url_helpers "gitlab.com/gitlab-org/gitlab-runner/helpers/url"
+)
+
+// maxChunkedManifestSize bounds the size of the cache objects read in memory
+// to find out whether they're chunked archive manifests. Larger objects are
+// downloaded as usual.
+const maxChunkedManifestSize = 64 << 20
+
+// openChunkedArchive opens the chunked archive held by r, returning nil if r
+// doesn't hold one.
+func openChunkedArchive(r io.ReaderAt, size int64) *chunked.Archive {
+	a, err := chunked.Open(r, size)
+	if err != nil {
+		return nil
+	}
+
+	return a
+}
+
+// writeBlob writes the content of r to the key of the bucket.
+func writeBlob(ctx context.Context, b *blob.Bucket, key string, r io.Reader, opts *blob.WriterOptions) error {
+	ctx, cancelWrite := context.WithCancel(ctx)
+	defer cancelWrite()
+
+	w, err := b.NewWriter(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		cancelWrite()
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// copyChunk copies the compressed data of a chunk from r to w.
+func copyChunk(w io.Writer, r io.Reader, chunk chunked.Chunk) error {
+	n, err := io.Copy(w, io.LimitReader(r, chunk.CompressedSize))
+	if err == nil && n != chunk.CompressedSize {
+		err = fmt.Errorf("chunk %s is truncated", chunk.Hash)
+	}
+
+	return err
+}
+
+// uploadChunks uploads the chunks of the archive missing from the chunk
+// store under prefix. Stored chunks compressed at another level are
+// replaced, as the manifest records the compressed size of every chunk.
+// Stored chunks older than half of chunked.GracePeriod are uploaded again,
+// refreshing their modification time, as the cache prune could otherwise
+// delete them before the manifest referencing them is uploaded.
+// It returns the number and size of uploaded chunks.
+func uploadChunks(ctx context.Context, b *blob.Bucket, prefix string, a *chunked.Archive, concurrency int) (int, int64, error) {
+	stored := map[string]*blob.ListObject{}
+	it := b.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("listing chunks: %w", err)
+		}
+		stored[strings.TrimPrefix(obj.Key, prefix)] = obj
+	}
+
+	var count int
+	var size int64
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(concurrency, 1))
+	for _, chunk := range a.Index.Unique() {
+		obj, ok := stored[chunk.Hash]
+		if ok && obj.Size == chunk.CompressedSize && time.Since(obj.ModTime) < chunked.GracePeriod/2 {
+			continue
+		}
+
+		r, ok := a.ChunkReader(chunk)
+		if !ok {
+			_ = g.Wait()
+			return 0, 0, fmt.Errorf("chunk %s: %w", chunk.Hash, chunked.ErrIncompleteArchive)
+		}
+
+		count++
+		size += chunk.CompressedSize
+		g.Go(func() error {
+			err := writeBlob(ctx, b, prefix+chunk.Hash, r, &blob.WriterOptions{ContentType: "application/zstd"})
+			if err != nil {
+				return fmt.Errorf("uploading chunk %s: %w", chunk.Hash, err)
+			}
+			return nil
+		})
+	}
+
+	return count, size, g.Wait()
+}
+
+// handleChunkedGoCloudURL uploads the chunks of the archive missing from the
+// chunk store, then its manifest as the cache object.
+func (c *CacheArchiverCommand) handleChunkedGoCloudURL(a *chunked.Archive) error {
+	logrus.Infoln("Uploading", filepath.Base(c.File), "chunks to", c.ChunkPrefix)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b, objectName, err := c.openGoCloudBucket(ctx)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	count, size, err := uploadChunks(ctx, b, c.ChunkPrefix, a, c.Concurrency)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"uploaded":      count,
+		"uploaded_size": size,
+		"chunks":        len(a.Index.Unique()),
+	}).Infoln("Uploaded cache chunks")
+
+	manifest := new(bytes.Buffer)
+	if err := chunked.WriteManifest(manifest, a.Index); err != nil {
+		return err
+	}
+
+	logrus.Infoln("Uploading", filepath.Base(c.File), "manifest to", url_helpers.CleanURL(c.GoCloudURL))
+
+	return writeBlob(ctx, b, objectName, manifest, &blob.WriterOptions{
+		Metadata:    c.Metadata,
+		ContentType: "application/octet-stream",
+	})
+}
+
+// downloadChunkedGoCloud downloads the cache object and, when it's the
+// manifest of a chunked archive, the chunks the local cache archive lacks.
+func (c *CacheExtractorCommand) downloadChunkedGoCloud(ctx context.Context, b *blob.Bucket, objectName string, attrs *blob.Attributes, cleanedURL string) error {
+	data, err := b.ReadAll(ctx, objectName)
+	if err != nil {
+		return retryableError{err: err}
+	}
+
+	manifest := openChunkedArchive(bytes.NewReader(data), int64(len(data)))
+	if manifest == nil || manifest.Complete() {
+		return c.downloadAndSaveCache(bytes.NewReader(data), attrs.ModTime, attrs.ETag, cleanedURL, int64(len(data)), attrs.Metadata)
+	}
+
+	return c.hydrateChunkedArchive(ctx, b, manifest.Index, attrs, cleanedURL)
+}
+
+// hydrateChunkedArchive writes the complete archive described by the index
+// to c.File, taking the chunks from the previous local archive when it holds
+// them and from the chunk store otherwise.
+func (c *CacheExtractorCommand) hydrateChunkedArchive(ctx context.Context, b *blob.Bucket, idx chunked.Index, attrs *blob.Attributes, cleanedURL string) error {
+	name := strings.TrimSuffix(filepath.Base(c.File), filepath.Ext(c.File))
+	logrus.Infoln("Downloading", name, "chunks from", cleanedURL)
+
+	file, err := os.CreateTemp(filepath.Dir(c.File), "cache")
+	if err != nil {
+		return err
+	}
+	tmpName := file.Name()
+	defer func() {
+		_ = os.Remove(tmpName)
+	}()
+
+	count, size, err := c.writeChunks(ctx, b, file, idx)
+	if err == nil {
+		_, err = file.Seek(int64(len(chunked.Magic))+idx.DataSize(), io.SeekStart)
+	}
+	if err == nil {
+		err = chunked.WriteIndex(file, idx)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"downloaded":      count,
+		"downloaded_size": size,
+		"chunks":          len(idx.Unique()),
+	}).Infoln("Downloaded cache chunks")
+
+	if err := os.Chtimes(tmpName, time.Now(), attrs.ModTime); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, c.File); err != nil {
+		return fmt.Errorf("renaming: %w", err)
+	}
+
+	return writeCacheMetadataFile(c.File, attrs.Metadata)
+}
+
+// writeChunks writes the magic and the chunk data of the archive described
+// by the index to file. It returns the number and size of the chunks
+// downloaded from the chunk store.
+func (c *CacheExtractorCommand) writeChunks(ctx context.Context, b *blob.Bucket, file *os.File, idx chunked.Index) (int, int64, error) {
+	if _, err := file.WriteAt(chunked.Magic, 0); err != nil {
+		return 0, 0, err
+	}
+
+	// the previous local archive holds the chunks that didn't change
+	var local *chunked.Archive
+	localChunks := map[string]chunked.Chunk{}
+	if f, err := os.Open(c.File); err == nil {
+		defer f.Close()
+
+		if fi, err := f.Stat(); err == nil {
+			local = openChunkedArchive(f, fi.Size())
+		}
+	}
+	if local != nil && local.Complete() {
+		for _, chunk := range local.Index.Unique() {
+			localChunks[chunk.Hash] = chunk
+		}
+	}
+
+	var count int
+	var size int64
+
+	offsets := idx.Offsets()
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.Concurrency, 1))
+	for _, chunk := range idx.Unique() {
+		w := io.NewOffsetWriter(file, offsets[chunk.Hash])
+
+		if localChunks[chunk.Hash] == chunk {
+			if r, ok := local.ChunkReader(chunk); ok {
+				g.Go(func() error { return copyChunk(w, r, chunk) })
+				continue
+			}
+		}
+
+		count++
+		size += chunk.CompressedSize
+		g.Go(func() error {
+			r, err := b.NewReader(ctx, c.ChunkPrefix+chunk.Hash, nil)
+			if err != nil {
+				return fmt.Errorf("downloading chunk %s: %w", chunk.Hash, err)
+			}
+			defer r.Close()
+
+			return copyChunk(w, r, chunk)
+		})
+	}
+
+	return count, size, g.Wait()
+}
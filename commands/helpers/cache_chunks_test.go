@@ -0,0 +1,119 @@
+//go:build !integration
+
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
+)
+
+func openTestChunkedArchive(t *testing.T, filename string) *chunked.Archive {
+	t.Helper()
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+
+	a, err := chunked.Open(f, fi.Size())
+	require.NoError(t, err)
+
+	return a
+}
+
+func TestCacheChunksGoCloudRoundTrip(t *testing.T) {
+	mux, bucketDir := setupGoCloudFileBucket(t, "testblob")
+	const chunkPrefix = "chunks/project/1/"
+
+	archiver, archiveFile := newTestCacheArchiver(t, "chunked")
+	_, err := archiver.createZipFile(archiveFile)
+	require.NoError(t, err)
+
+	archiver.File = archiveFile
+	archiver.GoCloudURL = "testblob://bucket/project/1/cache.zip"
+	archiver.ChunkPrefix = chunkPrefix
+	archiver.Concurrency = 4
+	archiver.TransferBufferSize = defaultCacheTransferBufferSize
+	archiver.mux = mux
+	require.NoError(t, archiver.upload(0))
+
+	original := openTestChunkedArchive(t, archiveFile)
+	manifest := openTestChunkedArchive(t, filepath.Join(bucketDir, "project", "1", "cache.zip"))
+	assert.False(t, manifest.Complete(), "the cache object is a manifest")
+	assert.Equal(t, original.Index, manifest.Index)
+
+	for _, chunk := range original.Index.Unique() {
+		assert.FileExists(t, filepath.Join(bucketDir, chunkPrefix, chunk.Hash))
+	}
+
+	b, err := mux.OpenBucket(t.Context(), archiver.GoCloudURL)
+	require.NoError(t, err)
+	defer b.Close()
+
+	count, _, err := uploadChunks(t.Context(), b, chunkPrefix, original, 1)
+	require.NoError(t, err)
+	assert.Zero(t, count, "stored chunks aren't uploaded again")
+
+	// the chunks reused past half of the grace period are uploaded again
+	stale := original.Index.Unique()[0]
+	stalePath := filepath.Join(bucketDir, chunkPrefix, stale.Hash)
+	staleTime := time.Now().Add(-chunked.GracePeriod)
+	require.NoError(t, os.Chtimes(stalePath, staleTime, staleTime))
+
+	count, _, err = uploadChunks(t.Context(), b, chunkPrefix, original, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "stale stored chunks are uploaded again")
+
+	fi, err := os.Stat(stalePath)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), fi.ModTime(), time.Minute, "the chunk modification time is refreshed")
+
+	extractor := &CacheExtractorCommand{
+		File:               filepath.Join(t.TempDir(), "cache.zip"),
+		GoCloudURL:         archiver.GoCloudURL,
+		ChunkPrefix:        chunkPrefix,
+		Concurrency:        4,
+		TransferBufferSize: defaultCacheTransferBufferSize,
+		mux:                mux,
+	}
+	require.NoError(t, extractor.download(0))
+
+	expected, err := os.ReadFile(archiveFile)
+	require.NoError(t, err)
+	actual, err := os.ReadFile(extractor.File)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual, "the downloaded archive is complete")
+
+	t.Run("reuses local chunks", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(filepath.Join(bucketDir, chunkPrefix)))
+		past := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(extractor.File, past, past))
+
+		require.NoError(t, extractor.download(0))
+
+		actual, err := os.ReadFile(extractor.File)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("missing chunks", func(t *testing.T) {
+		extractor := &CacheExtractorCommand{
+			File:               filepath.Join(t.TempDir(), "cache.zip"),
+			GoCloudURL:         archiver.GoCloudURL,
+			ChunkPrefix:        chunkPrefix,
+			TransferBufferSize: defaultCacheTransferBufferSize,
+			mux:                mux,
+		}
+		assert.Error(t, extractor.download(0))
+		assert.NoFileExists(t, extractor.File)
+	})
+}
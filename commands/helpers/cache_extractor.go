@@ -38,6 +38,7 @@ type CacheExtractorCommand struct {
 	GoCloudURL          string `long:"gocloud-url" description:"Go Cloud URL of remote cache resource (requires credentials)"`
 	AlternateURL        string `long:"alternate-url" description:"(temporary) Alternate pre-signed URL of remote cache resource"`
 	AlternateGoCloudURL string `long:"alternate-gocloud-url" description:"(temporary) Alternate Go Cloud URL of remote cache resource"`
+	ChunkPrefix         string `long:"chunk-prefix" description:"Object name prefix of the chunk store of chunked cache archives (requires --gocloud-url)"`
 	HeadURL             string `long:"head-url" description:"(temporary) HEAD pre-signed URL for primary cache existence check"`
 	AlternateHeadURL    string `long:"alternate-head-url" description:"(temporary) HEAD pre-signed URL for alternate cache existence check"`
 	Timeout             int    `long:"timeout" description:"Overall timeout for cache downloading request (in minutes)"`
@@ -486,6 +487,11 @@ func (c *CacheExtractorCommand) handleGoCloudURL() error {
 
 	cleanedURL := url_helpers.CleanURL(selectedGoCloudURL)
 
+	// Chunked archives are uploaded as a small manifest
+	if c.ChunkPrefix != "" && attrs.Size <= maxChunkedManifestSize {
+		return c.downloadChunkedGoCloud(ctx, selectedBucket, selectedObjectName, attrs, cleanedURL)
+	}
+
 	// Use parallel range reads when FF_USE_PARALLEL_CACHE_TRANSFER is enabled, Concurrency > 1, and backend supports range.
 	logger := logrus.WithField("name", featureflags.UseParallelCacheTransfer)
 	if featureflags.IsOn(logger, os.Getenv(featureflags.UseParallelCacheTransfer)) && c.Concurrency > 1 && attrs.Size > 0 { //nolint:nestif
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/network_egress"
+)
+
+type EgressProxyCommand struct {
+	Listen string `long:"listen" description:"Address to listen on"`
+	// Allow is a single comma-separated flag: destinations can't contain
+	// commas, and repeated flags get an unwanted trailing entry.
+	Allow string `long:"allow" description:"Comma-separated list of the allowed destinations"`
+}
+
+func NewEgressProxyCommand() cli.Command {
+	return common.NewCommand(
+		"egress-proxy",
+		"run the network egress filtering proxy of a job (internal)",
+		&EgressProxyCommand{Listen: ":8080"},
+	)
+}
+
+func (c *EgressProxyCommand) Execute(_ *cli.Context) {
+	var allow []string
+	for _, destination := range strings.Split(c.Allow, ",") {
+		if destination = strings.TrimSpace(destination); destination != "" {
+			allow = append(allow, destination)
+		}
+	}
+
+	policy, err := network_egress.NewPolicy(allow)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           network_egress.NewProxy(policy, os.Stdout),
+		ReadHeaderTimeout: time.Minute,
+	}
+
+	fmt.Printf("egress proxy listening on %s, allowing %s\n", c.Listen, strings.Join(allow, ", "))
+	logrus.Fatalln(server.ListenAndServe())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -15,6 +16,8 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 )
 
+const waitForServiceHTTPPathVariable = "WAIT_FOR_SERVICE_HTTP_PATH"
+
 type HealthCheckCommand struct {
 	ctx context.Context
 
@@ -28,6 +31,7 @@ func NewHealthCheckCommand() cli.Command {
 func (c *HealthCheckCommand) Execute(_ *cli.Context) {
 	var ports []string
 	var addr string
+	var httpPath string
 	var waitAll bool
 
 	if c.ctx == nil {
@@ -47,43 +51,61 @@ func (c *HealthCheckCommand) Execute(_ *cli.Context) {
 		// For kubernetes port checks, wait for all services to respond.
 		waitAll = true
 	} else {
-		for _, e := range os.Environ() {
-			parts := strings.Split(e, "=")
-
-			switch {
-			case len(parts) != 2:
-				continue
-			case strings.HasSuffix(parts[0], "_TCP_ADDR"):
-				addr = parts[1]
-			case strings.HasSuffix(parts[0], "_TCP_PORT"):
-				ports = append(ports, parts[1])
-			}
-		}
+		addr, ports, httpPath = serviceFromEnvironment(os.Environ())
 	}
 
 	if addr == "" || len(ports) == 0 {
 		logrus.Fatalln("No HOST or PORT found")
 	}
 
-	fmt.Printf("waiting for TCP connection to %s on %v...\n", addr, ports)
+	if httpPath != "" {
+		fmt.Printf("waiting for HTTP %s to succeed on %s on %v...\n", httpPath, addr, ports)
+	} else {
+		fmt.Printf("waiting for TCP connection to %s on %v...\n", addr, ports)
+	}
 	wg := sync.WaitGroup{}
 	wg.Add(len(ports))
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
 
 	for _, port := range ports {
-		go checkPort(ctx, addr, port, cancel, wg.Done, waitAll)
+		go checkPort(ctx, addr, port, httpPath, cancel, wg.Done, waitAll)
 	}
 
 	wg.Wait()
 }
 
+// serviceFromEnvironment returns the address, the ports and the HTTP path of
+// the service to check, from the variables the Docker executor sets.
+func serviceFromEnvironment(environ []string) (addr string, ports []string, httpPath string) {
+	for _, e := range environ {
+		// The HTTP path may have a query, with its own '='.
+		if key, value, _ := strings.Cut(e, "="); key == waitForServiceHTTPPathVariable {
+			httpPath = value
+			continue
+		}
+
+		parts := strings.Split(e, "=")
+
+		switch {
+		case len(parts) != 2:
+			continue
+		case strings.HasSuffix(parts[0], "_TCP_ADDR"):
+			addr = parts[1]
+		case strings.HasSuffix(parts[0], "_TCP_PORT"):
+			ports = append(ports, parts[1])
+		}
+	}
+
+	return addr, ports, httpPath
+}
+
 // checkPort will attempt to Dial the specified addr:port until successful. This function is intended to be run as a
 // go-routine and has the following exit criteria:
-//  1. A call to net.Dial is successful (i.e. does not return an error). A successful dial will also result in the
-//     the passed context being cancelled.
+//  1. A call to net.Dial is successful (i.e. does not return an error), and, when an HTTP path is given, a GET of the
+//     path answers with a 2xx or 3xx status. A success will also result in the the passed context being cancelled.
 //  2. The passed context is cancelled.
-func checkPort(parentCtx context.Context, addr, port string, cancel func(), done func(), waitAll bool) {
+func checkPort(parentCtx context.Context, addr, port, httpPath string, cancel func(), done func(), waitAll bool) {
 	defer done()
 
 	// If we're not awaiting all services, arrange to cancel the parent context as soon as
@@ -92,11 +114,18 @@ func checkPort(parentCtx context.Context, addr, port string, cancel func(), done
 		defer cancel()
 	}
 
+	// once the port accepts connections, only the changes of the outcome of
+	// the HTTP check are logged
+	var dialed bool
+	var httpState string
+
 	for {
 		ctx, cancel := context.WithTimeout(parentCtx, 5*time.Minute)
 		defer cancel()
 
-		fmt.Printf("dialing %s:%s...\n", addr, port)
+		if !dialed {
+			fmt.Printf("dialing %s:%s...\n", addr, port)
+		}
 		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(addr, port))
 		if err != nil {
 			if parentCtx.Err() != nil {
@@ -107,7 +136,54 @@ func checkPort(parentCtx context.Context, addr, port string, cancel func(), done
 		}
 
 		_ = conn.Close()
+		dialed = true
+
+		if httpPath != "" {
+			ok, state := checkHTTP(ctx, addr, port, httpPath)
+			if state != httpState {
+				fmt.Printf("GET %s on %s:%s: %s\n", httpPath, addr, port, state)
+				httpState = state
+			}
+
+			if !ok {
+				if parentCtx.Err() != nil {
+					return
+				}
+				time.Sleep(time.Second)
+				continue
+			}
+		}
+
 		fmt.Printf("dial succeeded on %s:%s. Exiting...\n", addr, port)
 		return
 	}
 }
+
+// checkHTTP returns whether a GET of the path on addr:port answers with a 2xx
+// or 3xx status, and the outcome of the request, for the caller to log when it
+// changes. Redirects aren't followed.
+func checkHTTP(ctx context.Context, addr, port, path string) (bool, string) {
+	url := "http://" + net.JoinHostPort(addr, port) + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Sprintf("invalid health check URL: %v", err)
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	ok := resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest
+
+	return ok, "answered with " + resp.Status
+}
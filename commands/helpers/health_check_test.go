@@ -0,0 +1,90 @@
+//go:build !integration
+
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/ready":
+			w.WriteHeader(http.StatusNoContent)
+		case "/ready?verbose=1":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/missing", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	addr, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		path          string
+		expectedOK    bool
+		expectedState string
+	}{
+		"ready": {
+			path:          "/ready",
+			expectedOK:    true,
+			expectedState: "answered with 204 No Content",
+		},
+		"query": {
+			path:          "/ready?verbose=1",
+			expectedOK:    true,
+			expectedState: "answered with 200 OK",
+		},
+		"redirects aren't followed": {
+			path:          "/moved",
+			expectedOK:    true,
+			expectedState: "answered with 302 Found",
+		},
+		"not ready": {
+			path:          "/starting",
+			expectedState: "answered with 503 Service Unavailable",
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			ok, state := checkHTTP(t.Context(), addr, port, tc.path)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedState, state)
+		})
+	}
+
+	srv.Close()
+
+	ok, state := checkHTTP(t.Context(), addr, port, "/ready")
+	assert.False(t, ok)
+	assert.Contains(t, state, "request failed: ")
+}
+
+func TestServiceFromEnvironment(t *testing.T) {
+	addr, ports, httpPath := serviceFromEnvironment([]string{
+		"PATH=/usr/bin",
+		"WAIT_FOR_SERVICE_TCP_ADDR=abcdef123456",
+		"WAIT_FOR_SERVICE_5432_TCP_PORT=5432",
+		"WAIT_FOR_SERVICE_8080_TCP_PORT=8080",
+		"WAIT_FOR_SERVICE_HTTP_PATH=/health?full=1",
+		"PROBE_HTTP_PATH=/other",
+	})
+
+	assert.Equal(t, "abcdef123456", addr)
+	assert.Equal(t, []string{"5432", "8080"}, ports)
+	assert.Equal(t, "/health?full=1", httpPath)
+
+	_, _, httpPath = serviceFromEnvironment([]string{"APP_HTTP_PATH=/other"})
+	assert.Empty(t, httpPath, "only WAIT_FOR_SERVICE_HTTP_PATH sets the HTTP path")
+}
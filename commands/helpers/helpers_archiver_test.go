@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive"
+	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/chunked"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/fastzip"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/tarzstd"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/helpers/archive/ziplegacy"
@@ -23,6 +24,7 @@ func OnEachArchiver(t *testing.T, f func(t *testing.T, format archive.Format)) {
 		"zstd->legacy":     {archive.ZipZstd, fastzip.NewZstdArchiver, ziplegacy.NewExtractor},
 		"zstd->fastzip":    {archive.ZipZstd, fastzip.NewZstdArchiver, fastzip.NewExtractor},
 		"tarzstd":          {archive.TarZstd, tarzstd.NewArchiver, tarzstd.NewExtractor},
+		"chunked":          {archive.Chunked, chunked.NewArchiver, chunked.NewExtractor},
 	}
 
 	for name, a := range archivers {
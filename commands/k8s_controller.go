@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/runnerjob"
+)
+
+// KubernetesControllerCommand runs the controller creating the resources of
+// the RunnerJobs of the kubernetes executor.
+type KubernetesControllerCommand struct {
+	Kubeconfig string        `long:"kubeconfig" env:"KUBECONFIG" description:"Path to the kubeconfig file, the in-cluster configuration is used when empty"`
+	Master     string        `long:"master" description:"Address of the Kubernetes API server, overrides the one of the kubeconfig file"`
+	Namespace  string        `long:"namespace" env:"K8S_CONTROLLER_NAMESPACE" description:"Namespace of the RunnerJobs to reconcile, all the namespaces when empty"`
+	Workers    int           `long:"workers" description:"Number of RunnerJobs reconciled concurrently"`
+	Resync     time.Duration `long:"resync" description:"Interval at which all the RunnerJobs are reconciled again"`
+	PrintCRD   bool          `long:"print-crd" description:"Print the RunnerJob CustomResourceDefinition and exit"`
+
+	AllowedServiceAccounts []string `long:"allowed-service-account" description:"Service account the job pods can run as, besides the default one of the namespace"`
+	AllowedRunners         []string `long:"allowed-runner" description:"Short ID of a runner allowed to create RunnerJobs, any runner when none is set"`
+	AllowedCapabilities    []string `long:"allowed-capability" description:"Linux capability the containers of the job pods can add"`
+	AllowPrivileged        bool     `long:"allow-privileged" description:"Allow privileged containers, and containers allowing privilege escalation, in the job pods"`
+	AllowHostPath          bool     `long:"allow-host-path" description:"Allow hostPath volumes in the job pods"`
+	AllowHostNamespaces    bool     `long:"allow-host-namespaces" description:"Allow job pods using the network, PID or IPC namespace of their node, or host ports"`
+	AllowNodeName          bool     `long:"allow-node-name" description:"Allow job pods setting the node they run on"`
+}
+
+func NewKubernetesControllerCommand() cli.Command {
+	return common.NewCommand(
+		"k8s-controller",
+		"run the controller creating the pods of the RunnerJobs of the kubernetes executor",
+		&KubernetesControllerCommand{
+			Workers: 4,
+			Resync:  10 * time.Minute,
+		},
+	)
+}
+
+func (c *KubernetesControllerCommand) Execute(_ *cli.Context) {
+	if c.PrintCRD {
+		fmt.Print(runnerjob.CustomResourceDefinition)
+		return
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags(c.Master, c.Kubeconfig)
+	if err != nil {
+		logrus.Fatalln("Loading the kubernetes configuration:", err)
+	}
+	config.UserAgent = common.AppVersion.UserAgent()
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logrus.Fatalln("Creating the kubernetes client:", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		logrus.Fatalln("Creating the kubernetes dynamic client:", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	policy := runnerjob.Policy{
+		ServiceAccounts:     c.AllowedServiceAccounts,
+		Runners:             c.AllowedRunners,
+		Capabilities:        c.AllowedCapabilities,
+		AllowPrivileged:     c.AllowPrivileged,
+		AllowHostPath:       c.AllowHostPath,
+		AllowHostNamespaces: c.AllowHostNamespaces,
+		AllowNodeName:       c.AllowNodeName,
+	}
+
+	controller := runnerjob.NewController(kubeClient, dynamicClient, c.Namespace, policy, c.Resync, logrus.StandardLogger())
+	if err := controller.Run(ctx, c.Workers); err != nil {
+		logrus.Fatalln("Running the controller:", err)
+	}
+}
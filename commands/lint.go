@@ -1,12 +1,17 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/BurntSushi/toml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
@@ -14,88 +19,464 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 )
 
-// LintCommand validates a config.toml file for syntax errors, semantic
-// (jsonschema) errors, and unknown/misspelled keys.
+const (
+	lintFormatText  = "text"
+	lintFormatJSON  = "json"
+	lintFormatSARIF = "sarif"
+)
+
+// LintCommand validates a config.toml file, merged with its config.d
+// drop-ins, for syntax errors, semantic (jsonschema) errors,
+// unknown/misspelled keys and risky or conflicting settings.
 type LintCommand struct {
 	ConfigFile string `short:"c" long:"config" env:"CONFIG_FILE" description:"Config file"`
+	Format     string `long:"format" description:"Output format: text, json or sarif"`
+	Strict     bool   `long:"strict" description:"Exit with a non-zero status on warnings too"`
 }
 
 // NewLintCommand creates the cli.Command for linting a config.toml file.
 func NewLintCommand() cli.Command {
 	return common.NewCommand("lint", "validate syntax and keys of a config.toml file", &LintCommand{
 		ConfigFile: GetDefaultConfigFile(),
+		Format:     lintFormatText,
 	})
 }
 
 // Execute runs the lint command.
 func (c *LintCommand) Execute(_ *cli.Context) {
-	if err := LintConfigFile(c.ConfigFile); err != nil {
+	findings, err := lintConfig(c.ConfigFile)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	switch c.Format {
+	case lintFormatText, "":
+		for _, f := range findings {
+			if f.Severity == lintSeverityError {
+				logrus.Errorln(f)
+			} else {
+				logrus.Warningln(f)
+			}
+		}
+	case lintFormatJSON:
+		err = writeLintJSON(os.Stdout, findings)
+	case lintFormatSARIF:
+		err = writeLintSARIF(os.Stdout, findings)
+	default:
+		err = fmt.Errorf("unknown format %q", c.Format)
+	}
+	if err != nil {
 		logrus.Fatalln(err)
 	}
+
+	if lintFailed(findings, c.Strict) {
+		logrus.Fatalln("Configuration file is invalid.")
+	}
 	logrus.Println("Configuration file is valid.")
 }
 
-// LintConfigFile validates the given config file by:
-//  1. requiring the file to exist (lint deviates from Config.LoadConfig here,
-//     which treats a missing file as a soft error - inappropriate for a
-//     validator invoked explicitly by the user);
-//  2. decoding and built-in struct validation via Config.LoadConfig, which
-//     handles TOML syntax errors and structural Validate() checks without the
-//     system-ID side-effect that configfile.Load() carries (configfile.Load()
-//     calls newSystemIDState() and will create a .runner_system_id file next
-//     to the config, which is inappropriate for a read-only validator);
-//  3. jsonschema validation via configfile.Validate(), surfaced as a hard error
-//     (configfile.Load() only logs this as a warning and exits 0);
-//  4. re-decoding metadata only to surface undecoded (unknown / misspelled)
-//     keys. Step 4 duplicates only the metadata extraction because
-//     BurntSushi/toml exposes Undecoded() solely through the return value of
-//     DecodeFile, which LoadConfig discards. Keeping the extra pass local to
-//     lint is a smaller, safer change than threading metadata through the
-//     shared loader.
+type lintSeverity string
+
+const (
+	lintSeverityError   lintSeverity = "error"
+	lintSeverityWarning lintSeverity = "warning"
+)
+
+// lintFinding is a problem found in the configuration. Key is the path of
+// the offending key in the merged configuration, File and Line the place
+// defining it.
+type lintFinding struct {
+	Rule     string       `json:"rule"`
+	Severity lintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+	Key      string       `json:"key,omitempty"`
+	File     string       `json:"file,omitempty"`
+	Line     int          `json:"line,omitempty"`
+
+	path []string
+}
+
+func (f lintFinding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location += ":" + strconv.Itoa(f.Line)
+	}
+
+	return fmt.Sprintf("%s: %s [%s]", location, f.Message, f.Rule)
+}
+
+func lintFailed(findings []lintFinding, strict bool) bool {
+	for _, f := range findings {
+		if f.Severity == lintSeverityError || strict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LintConfigFile validates the given config file and returns an error
+// listing the problems found, if any of them is an error. See lintConfig for
+// the checks done.
 func LintConfigFile(configFile string) error {
-	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("config file not found: %s", configFile)
-	} else if err != nil {
-		return fmt.Errorf("accessing config file: %w", err)
+	findings, err := lintConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, f := range findings {
+		if f.Severity == lintSeverityError {
+			errs = append(errs, f.String())
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(errs[0])
+	default:
+		return fmt.Errorf("%d problem(s) found:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+}
+
+// lintConfig validates the given config file, merged with the drop-in
+// fragments of its config.d directory, by:
+//  1. requiring the file or a drop-in fragment to exist (lint deviates from
+//     Config.LoadConfig here, which treats a missing file as a soft error -
+//     inappropriate for a validator invoked explicitly by the user);
+//  2. decoding, merging and built-in struct validation via Config.LoadConfig,
+//     which handles TOML syntax errors and structural Validate() checks
+//     without the system-ID side-effect that configfile.Load() carries
+//     (configfile.Load() calls newSystemIDState() and will create a
+//     .runner_system_id file next to the config, which is inappropriate for a
+//     read-only validator);
+//  3. jsonschema validation via configfile.Validate() of the merged result,
+//     surfaced as errors (configfile.Load() only logs this as a warning and
+//     exits 0);
+//  4. reporting the undecoded (unknown / misspelled) keys of each file, as
+//     recorded by LoadConfig in Config.Sources;
+//  5. running the policy rules of lintPolicyRules, which report settings
+//     that are valid but risky or conflicting, as warnings.
+//
+// The findings are attributed to the file and line defining the offending
+// key. The returned error is set only when the configuration can't be
+// linted at all.
+func lintConfig(configFile string) ([]lintFinding, error) {
+	dropIns, err := common.ConfigDropIns(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("accessing config drop-ins: %w", err)
+	}
+
+	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) && len(dropIns) == 0 {
+		return nil, fmt.Errorf("config file not found: %s", configFile)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("accessing config file: %w", err)
 	}
 
 	// Primary validation: TOML decode + built-in struct Validate().
 	// LoadConfig treats a missing file as a soft error; we guard against that
-	// above, so by this point the file is known to exist.
+	// above, so by this point the file or a drop-in is known to exist.
 	cfg := common.NewConfig()
 	if err := cfg.LoadConfig(configFile); err != nil {
-		return fmt.Errorf("decoding config: %w", err)
+		return []lintFinding{loadConfigFinding(configFile, err)}, nil
 	}
 
+	var findings []lintFinding
+	locator := newLintLocator(cfg)
+
 	// Jsonschema validation is best-effort in configfile.Load() (it only logs).
-	// For lint we treat any schema violation as a hard error.
-	if err := configfile.Validate(cfg); err != nil {
-		return fmt.Errorf("config schema validation failed: %w", err)
+	// For lint we treat any schema violation as an error.
+	findings = append(findings, schemaFindings(configfile.Validate(cfg))...)
+
+	for _, file := range append([]string{configFile}, dropIns...) {
+		for _, key := range cfg.Sources.Undecoded[file] {
+			findings = append(findings, lintFinding{
+				Rule:     "unknown-key",
+				Severity: lintSeverityError,
+				Message:  fmt.Sprintf("unknown key: %s", key),
+				Key:      key.String(),
+				File:     file,
+				Line:     locator.plainLine(file, key),
+			})
+		}
 	}
 
-	// Diagnostic pass: capture undecoded (unknown / misspelled) keys.
-	var rawCfg common.Config
-	meta, err := toml.DecodeFile(configFile, &rawCfg)
-	if err != nil {
-		// The loader above already succeeded, so a syntax error here would be
-		// unexpected. Surface it rather than silently swallow.
-		return fmt.Errorf("decoding config: %w", err)
+	for _, rule := range lintPolicyRules {
+		for _, f := range rule.check(cfg) {
+			f.Rule = rule.id
+			f.Severity = lintSeverityWarning
+			findings = append(findings, f)
+		}
+	}
+
+	for i, f := range findings {
+		if f.path == nil {
+			continue
+		}
+		findings[i].Key = formatLintKey(f.path)
+		findings[i].File, findings[i].Line = locator.locate(f.path)
+	}
+
+	return findings, nil
+}
+
+func loadConfigFinding(configFile string, err error) lintFinding {
+	f := lintFinding{
+		Rule:     "invalid-config",
+		Severity: lintSeverityError,
+		Message:  fmt.Sprintf("decoding config: %v", err),
+		File:     configFile,
+	}
+
+	var fileErr *common.ConfigFileError
+	if errors.As(err, &fileErr) {
+		f.Rule = "syntax"
+		f.File = fileErr.File
+	}
+
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		f.Line = parseErr.Position.Line
 	}
 
-	undecoded := meta.Undecoded()
-	if len(undecoded) == 0 {
+	return f
+}
+
+// schemaFindings lists the schema violations of the validation error.
+func schemaFindings(err error) []lintFinding {
+	if err == nil {
 		return nil
 	}
 
-	msgs := make([]string, 0, len(undecoded))
-	for _, key := range undecoded {
-		msgs = append(msgs, fmt.Sprintf("  unknown key: %s", key))
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []lintFinding{{
+			Rule:     "schema",
+			Severity: lintSeverityError,
+			Message:  fmt.Sprintf("config schema validation failed: %v", err),
+		}}
+	}
+
+	var findings []lintFinding
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			findings = append(findings, lintFinding{
+				Rule:     "schema",
+				Severity: lintSeverityError,
+				Message:  fmt.Sprintf("config schema validation failed: %s", e.Error()),
+				path:     append([]string{}, e.InstanceLocation...),
+			})
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
 	}
+	walk(validationErr)
 
-	return fmt.Errorf(
-		"%d unknown key(s) found in %s:\n%s",
-		len(undecoded),
-		configFile,
-		strings.Join(msgs, "\n"),
-	)
+	return findings
+}
+
+// formatLintKey formats the path of a key, for example
+// "runners[0].docker.image".
+func formatLintKey(path []string) string {
+	var sb strings.Builder
+	for _, part := range path {
+		if _, err := strconv.Atoi(part); err == nil {
+			sb.WriteString("[" + part + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(part)
+	}
+
+	return sb.String()
+}
+
+// lintLocator finds the file and line defining the keys of the merged
+// configuration.
+type lintLocator struct {
+	cfg   *common.Config
+	files map[string]*tomlKeyLines
+}
+
+func newLintLocator(cfg *common.Config) *lintLocator {
+	return &lintLocator{cfg: cfg, files: map[string]*tomlKeyLines{}}
+}
+
+func (l *lintLocator) keyLines(file string) *tomlKeyLines {
+	kl, ok := l.files[file]
+	if !ok {
+		kl, _ = readTOMLKeyLines(file)
+		if kl == nil {
+			kl = scanTOMLKeyLines(nil)
+		}
+		l.files[file] = kl
+	}
+
+	return kl
+}
+
+func (l *lintLocator) plainLine(file string, key toml.Key) int {
+	return l.keyLines(file).plainLine(key)
+}
+
+// locate returns the file and line defining the key. The keys of a runner
+// are looked up in the files patching the runner first, in reverse order,
+// and in the file defining the runner otherwise.
+func (l *lintLocator) locate(path []string) (string, int) {
+	sources := l.cfg.Sources
+	if len(path) == 0 {
+		return sources.ConfigFile, 0
+	}
+
+	if path[0] == "runners" && len(path) > 1 {
+		if file, line, ok := l.locateRunnerKey(path); ok {
+			return file, line
+		}
+	}
+
+	file, ok := sources.Keys[path[0]]
+	if !ok {
+		file = sources.ConfigFile
+	}
+
+	return file, l.keyLines(file).line(path)
+}
+
+func (l *lintLocator) locateRunnerKey(path []string) (string, int, bool) {
+	i, err := strconv.Atoi(path[1])
+	if err != nil || i < 0 || i >= len(l.cfg.Runners) {
+		return "", 0, false
+	}
+
+	runner := l.cfg.Runners[i]
+	files := l.cfg.Sources.RunnerFiles(runner)
+
+	var defining string
+	var definingLine int
+	for j := len(files) - 1; j >= 0; j-- {
+		kl := l.keyLines(files[j])
+
+		index, ok := kl.runner(runner.Name)
+		if !ok && files[j] == l.cfg.Sources.ConfigFile {
+			index, ok = i, i < len(kl.runners)
+		}
+		if !ok {
+			continue
+		}
+
+		key := append([]string{"runners", strconv.Itoa(index)}, path[2:]...)
+		if line, ok := kl.lines[strings.Join(key, ".")]; ok {
+			return files[j], line, true
+		}
+
+		defining, definingLine = files[j], kl.line(key)
+	}
+
+	return defining, definingLine, defining != ""
+}
+
+func writeLintJSON(w io.Writer, findings []lintFinding) error {
+	report := struct {
+		Findings []lintFinding `json:"findings"`
+	}{Findings: findings}
+	if report.Findings == nil {
+		report.Findings = []lintFinding{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			Version        string      `json:"version,omitempty"`
+			InformationURI string      `json:"informationUri"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level lintSeverity `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     lintSeverity    `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeLintSARIF writes the findings as a SARIF 2.1.0 log, the format
+// consumed by code scanning integrations.
+func writeLintSARIF(w io.Writer, findings []lintFinding) error {
+	var run sarifRun
+	run.Tool.Driver.Name = common.AppVersion.Name
+	run.Tool.Driver.Version = common.AppVersion.Version
+	run.Tool.Driver.InformationURI = "https://docs.gitlab.com/runner/commands/#gitlab-runner-lint"
+
+	for _, r := range lintRules() {
+		rule := sarifRule{ID: r.id, ShortDescription: sarifMessage{Text: r.description}}
+		rule.DefaultConfiguration.Level = r.severity
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+
+	run.Results = []sarifResult{}
+	for _, f := range findings {
+		result := sarifResult{RuleID: f.Rule, Level: f.Severity, Message: sarifMessage{Text: f.Message}}
+		if f.File != "" {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(f.File)
+			if f.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+			}
+			result.Locations = append(result.Locations, loc)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
 }
@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tomlTableHeader = regexp.MustCompile(`^\s*(\[\[?)\s*([^\]]+?)\s*\]\]?\s*(#.*)?$`)
+	tomlKeyValue    = regexp.MustCompile(`^\s*((?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*')(?:\s*\.\s*(?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*'))*)\s*=(.*)$`)
+)
+
+// tomlKeyLines maps the keys of a TOML document to the lines defining them.
+// Keys are joined with dots, with the index of the array of tables entries
+// following the name of the array, for example "runners.0.docker.image".
+//
+// BurntSushi/toml doesn't expose the position of the decoded keys, so the
+// document is scanned line by line. The scanner handles the subset of TOML
+// used by config.toml: tables, arrays of tables, dotted keys and multi-line
+// arrays and strings.
+type tomlKeyLines struct {
+	lines map[string]int
+	// plain maps the keys without the array indices to the first line
+	// defining them, to locate the undecoded keys reported by the decoder.
+	plain map[string]int
+	// runners are the names of the [[runners]] entries, by index.
+	runners []string
+}
+
+func readTOMLKeyLines(path string) (*tomlKeyLines, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTOMLKeyLines(data), nil
+}
+
+func scanTOMLKeyLines(data []byte) *tomlKeyLines {
+	kl := &tomlKeyLines{lines: map[string]int{}, plain: map[string]int{}}
+
+	// arrays maps the arrays of tables, by indexed and by plain path, to
+	// the index of their last entry
+	arrays := map[string]int{}
+	var table []string
+	var multiline string
+	var depth int
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		switch {
+		case multiline != "":
+			if strings.Count(text, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		case depth > 0:
+			depth += bracketDepth(text)
+			continue
+		}
+
+		if m := tomlTableHeader.FindStringSubmatch(text); m != nil {
+			plain := splitTOMLKey(m[2])
+			table = indexTOMLKey(arrays, plain)
+
+			if m[1] == "[[" {
+				name := strings.Join(plain, ".")
+				index, ok := arrays[strings.Join(table, ".")]
+				if ok {
+					index++
+				}
+				arrays[strings.Join(table, ".")] = index
+				arrays[name] = index
+				table = append(table, strconv.Itoa(index))
+
+				if name == "runners" {
+					kl.runners = append(kl.runners, "")
+				}
+			}
+
+			kl.set(table, plain, line)
+			continue
+		}
+
+		m := tomlKeyValue.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+
+		key := append(append([]string(nil), table...), splitTOMLKey(m[1])...)
+		kl.set(key, plainTOMLKey(key), line)
+
+		value := strings.TrimSpace(m[2])
+		switch {
+		case strings.HasPrefix(value, `"""`) && strings.Count(value, `"""`) == 1:
+			multiline = `"""`
+		case strings.HasPrefix(value, `'''`) && strings.Count(value, `'''`) == 1:
+			multiline = `'''`
+		case strings.HasPrefix(value, "["):
+			depth = bracketDepth(value)
+		}
+
+		if len(key) == 3 && key[0] == "runners" && key[2] == "name" {
+			if index, err := strconv.Atoi(key[1]); err == nil && index < len(kl.runners) {
+				kl.runners[index] = unquoteTOML(strings.SplitN(value, "#", 2)[0])
+			}
+		}
+	}
+
+	return kl
+}
+
+func (kl *tomlKeyLines) set(key []string, plain []string, line int) {
+	kl.lines[strings.Join(key, ".")] = line
+	if _, ok := kl.plain[strings.Join(plain, ".")]; !ok {
+		kl.plain[strings.Join(plain, ".")] = line
+	}
+}
+
+// line returns the line defining the key, or the closest parent of the key
+// when it isn't defined by the document.
+func (kl *tomlKeyLines) line(key []string) int {
+	for i := len(key); i > 0; i-- {
+		if line, ok := kl.lines[strings.Join(key[:i], ".")]; ok {
+			return line
+		}
+	}
+
+	return 0
+}
+
+// plainLine returns the first line defining the key given without array
+// indices.
+func (kl *tomlKeyLines) plainLine(key []string) int {
+	return kl.plain[strings.Join(key, ".")]
+}
+
+// runner returns the index of the [[runners]] entry with the name.
+func (kl *tomlKeyLines) runner(name string) (int, bool) {
+	for i, n := range kl.runners {
+		if n == name && name != "" {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// indexTOMLKey inserts the last index of the arrays of tables the key goes
+// through.
+func indexTOMLKey(arrays map[string]int, plain []string) []string {
+	var indexed []string
+	for i, part := range plain {
+		indexed = append(indexed, part)
+		if i == len(plain)-1 {
+			break
+		}
+		if index, ok := arrays[strings.Join(plain[:i+1], ".")]; ok {
+			indexed = append(indexed, strconv.Itoa(index))
+		}
+	}
+
+	return indexed
+}
+
+func plainTOMLKey(key []string) []string {
+	var plain []string
+	for _, part := range key {
+		if _, err := strconv.Atoi(part); err != nil {
+			plain = append(plain, part)
+		}
+	}
+
+	return plain
+}
+
+func splitTOMLKey(key string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	for _, r := range key {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			parts = append(parts, unquoteTOML(part.String()))
+			part.Reset()
+			continue
+		}
+		part.WriteRune(r)
+	}
+
+	return append(parts, unquoteTOML(part.String()))
+}
+
+func unquoteTOML(s string) string {
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// bracketDepth returns the number of brackets opened and not closed by the
+// text, ignoring strings and comments.
+func bracketDepth(text string) int {
+	var depth int
+	var quote rune
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return depth
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		}
+	}
+
+	return depth
+}
@@ -0,0 +1,294 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// lintRule describes a kind of finding reported by lint.
+type lintRule struct {
+	id          string
+	description string
+	severity    lintSeverity
+}
+
+// lintPolicyRule reports settings that are valid but risky or conflicting.
+// The findings it returns need only the message and the path of the
+// offending key.
+type lintPolicyRule struct {
+	lintRule
+	check func(cfg *common.Config) []lintFinding
+}
+
+var lintBuiltinRules = []lintRule{
+	{id: "syntax", description: "The configuration can't be decoded", severity: lintSeverityError},
+	{id: "invalid-config", description: "The configuration is rejected by the runner", severity: lintSeverityError},
+	{id: "schema", description: "The configuration doesn't match the configuration schema", severity: lintSeverityError},
+	{id: "unknown-key", description: "The key is unknown, and ignored by the runner", severity: lintSeverityError},
+}
+
+var lintPolicyRules = []lintPolicyRule{
+	{
+		lintRule: lintRule{
+			id:          "privileged-without-allowed-images",
+			description: "Privileged mode is enabled for any image",
+			severity:    lintSeverityWarning,
+		},
+		check: checkPrivilegedWithoutAllowedImages,
+	},
+	{
+		lintRule: lintRule{
+			id:          "allowed-privileged-images-ignored",
+			description: "Privileged image allowlists are set while privileged mode is disabled",
+			severity:    lintSeverityWarning,
+		},
+		check: checkAllowedPrivilegedImagesIgnored,
+	},
+	{
+		lintRule: lintRule{
+			id:          "concurrent-below-limits",
+			description: "concurrent is smaller than the sum of the runners' limit",
+			severity:    lintSeverityWarning,
+		},
+		check: checkConcurrentBelowLimits,
+	},
+	{
+		lintRule: lintRule{
+			id:          "overwrite-max-allowed-below-default",
+			description: "A Kubernetes resource overwrite maximum is below the default value",
+			severity:    lintSeverityWarning,
+		},
+		check: checkOverwriteMaxAllowedBelowDefault,
+	},
+	{
+		lintRule: lintRule{
+			id:          "unreachable-image-glob",
+			description: "An image allowlist pattern can't match any image",
+			severity:    lintSeverityWarning,
+		},
+		check: checkUnreachableImageGlobs,
+	},
+}
+
+// lintRules returns all the rules lint reports findings for.
+func lintRules() []lintRule {
+	rules := append([]lintRule(nil), lintBuiltinRules...)
+	for _, r := range lintPolicyRules {
+		rules = append(rules, r.lintRule)
+	}
+
+	return rules
+}
+
+func runnerPath(i int, key ...string) []string {
+	return append([]string{"runners", strconv.Itoa(i)}, key...)
+}
+
+func checkPrivilegedWithoutAllowedImages(cfg *common.Config) []lintFinding {
+	var findings []lintFinding
+	for i, r := range cfg.Runners {
+		if r.Docker == nil || !r.Docker.Privileged || len(r.Docker.AllowedPrivilegedImages) > 0 {
+			continue
+		}
+
+		findings = append(findings, lintFinding{
+			Message: fmt.Sprintf(
+				"runner %q runs any job image in privileged mode: set allowed_privileged_images to restrict the images",
+				r.Name,
+			),
+			path: runnerPath(i, "docker", "privileged"),
+		})
+	}
+
+	return findings
+}
+
+func checkAllowedPrivilegedImagesIgnored(cfg *common.Config) []lintFinding {
+	var findings []lintFinding
+	for i, r := range cfg.Runners {
+		if r.Docker == nil {
+			continue
+		}
+
+		if !r.Docker.Privileged && len(r.Docker.AllowedPrivilegedImages) > 0 {
+			findings = append(findings, lintFinding{
+				Message: fmt.Sprintf("runner %q sets allowed_privileged_images, which has no effect without privileged = true", r.Name),
+				path:    runnerPath(i, "docker", "allowed_privileged_images"),
+			})
+		}
+
+		servicesPrivileged := r.Docker.Privileged
+		if r.Docker.ServicesPrivileged != nil {
+			servicesPrivileged = *r.Docker.ServicesPrivileged
+		}
+		if !servicesPrivileged && len(r.Docker.AllowedPrivilegedServices) > 0 {
+			findings = append(findings, lintFinding{
+				Message: fmt.Sprintf("runner %q sets allowed_privileged_services, which has no effect while services aren't privileged", r.Name),
+				path:    runnerPath(i, "docker", "allowed_privileged_services"),
+			})
+		}
+	}
+
+	return findings
+}
+
+func checkConcurrentBelowLimits(cfg *common.Config) []lintFinding {
+	var limits int
+	for _, r := range cfg.Runners {
+		if r.Limit > 0 {
+			limits += r.Limit
+		}
+	}
+
+	if cfg.Concurrent >= limits {
+		return nil
+	}
+
+	return []lintFinding{{
+		Message: fmt.Sprintf(
+			"concurrent (%d) is smaller than the sum of the runners' limit (%d): the runners can't reach their limit at the same time",
+			cfg.Concurrent, limits,
+		),
+		path: []string{"concurrent"},
+	}}
+}
+
+// checkOverwriteMaxAllowedBelowDefault compares each Kubernetes
+// *_overwrite_max_allowed setting with the default it caps, for example
+// cpu_request_overwrite_max_allowed with cpu_request. Below the default,
+// jobs can only lower their resources.
+func checkOverwriteMaxAllowedBelowDefault(cfg *common.Config) []lintFinding {
+	const suffix = "OverwriteMaxAllowed"
+
+	var findings []lintFinding
+	for i, r := range cfg.Runners {
+		if r.Kubernetes == nil {
+			continue
+		}
+
+		v := reflect.ValueOf(r.Kubernetes).Elem()
+		t := v.Type()
+		for j := 0; j < t.NumField(); j++ {
+			maxField := t.Field(j)
+			if !strings.HasSuffix(maxField.Name, suffix) || maxField.Type.Kind() != reflect.String {
+				continue
+			}
+
+			defaultField, ok := t.FieldByName(strings.TrimSuffix(maxField.Name, suffix))
+			if !ok || defaultField.Type.Kind() != reflect.String {
+				continue
+			}
+
+			maxValue, err := resource.ParseQuantity(v.Field(j).String())
+			if err != nil {
+				continue
+			}
+			defaultValue, err := resource.ParseQuantity(v.FieldByIndex(defaultField.Index).String())
+			if err != nil || maxValue.Cmp(defaultValue) >= 0 {
+				continue
+			}
+
+			maxKey, defaultKey := tomlFieldName(maxField), tomlFieldName(defaultField)
+			findings = append(findings, lintFinding{
+				Message: fmt.Sprintf(
+					"runner %q sets %s (%s) below %s (%s): jobs can't request the default",
+					r.Name, maxKey, maxValue.String(), defaultKey, defaultValue.String(),
+				),
+				path: runnerPath(i, "kubernetes", maxKey),
+			})
+		}
+	}
+
+	return findings
+}
+
+func tomlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	return name
+}
+
+type imageAllowlist struct {
+	section  string
+	key      string
+	patterns []string
+}
+
+var imageGlobBrackets = regexp.MustCompile(`\[[^\]]*\]|\{[^}]*\}`)
+
+func checkUnreachableImageGlobs(cfg *common.Config) []lintFinding {
+	var findings []lintFinding
+	for i, r := range cfg.Runners {
+		var allowlists []imageAllowlist
+		if r.Docker != nil {
+			allowlists = append(allowlists,
+				imageAllowlist{"docker", "allowed_images", r.Docker.AllowedImages},
+				imageAllowlist{"docker", "allowed_services", r.Docker.AllowedServices},
+				imageAllowlist{"docker", "allowed_privileged_images", r.Docker.AllowedPrivilegedImages},
+				imageAllowlist{"docker", "allowed_privileged_services", r.Docker.AllowedPrivilegedServices},
+			)
+		}
+		if r.Kubernetes != nil {
+			allowlists = append(allowlists,
+				imageAllowlist{"kubernetes", "allowed_images", r.Kubernetes.AllowedImages},
+				imageAllowlist{"kubernetes", "allowed_services", r.Kubernetes.AllowedServices},
+			)
+		}
+
+		for _, allowlist := range allowlists {
+			for j, pattern := range allowlist.patterns {
+				reason := unreachableImageGlob(pattern)
+				if reason == "" {
+					continue
+				}
+
+				findings = append(findings, lintFinding{
+					Message: fmt.Sprintf("runner %q allows %q, which can't match any image: %s", r.Name, pattern, reason),
+					path:    runnerPath(i, allowlist.section, allowlist.key, strconv.Itoa(j)),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+// unreachableImageGlob returns why the pattern can't match an image
+// reference, or an empty string when it can.
+func unreachableImageGlob(pattern string) string {
+	switch {
+	case !doublestar.ValidatePattern(pattern):
+		return "the pattern is invalid"
+	case strings.TrimSpace(pattern) == "":
+		return "the pattern is empty"
+	case strings.ContainsAny(pattern, " \t\n"):
+		return "image references can't contain whitespace"
+	case strings.Contains(pattern, "://"):
+		return "image references don't have a URL scheme"
+	case strings.HasPrefix(pattern, "/") || strings.HasSuffix(pattern, "/"):
+		return "image references can't start or end with a slash"
+	}
+
+	// the repository path, between the registry and the tag or digest, is
+	// lowercase
+	name := imageGlobBrackets.ReplaceAllString(pattern, "")
+	name, _, _ = strings.Cut(name, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	if registry, path, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		name = path
+	}
+	if strings.ToLower(name) != name {
+		return "image repository names are lowercase"
+	}
+
+	return ""
+}
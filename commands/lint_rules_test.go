@@ -0,0 +1,163 @@
+//go:build !integration
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintConfigPolicyRules(t *testing.T) {
+	findings, err := lintConfig("testdata/lint/policy.toml")
+	require.NoError(t, err)
+
+	for i := range findings {
+		findings[i].Message = ""
+		findings[i].path = nil
+	}
+
+	file := "testdata/lint/policy.toml"
+	assert.ElementsMatch(t, []lintFinding{
+		{Rule: "privileged-without-allowed-images", Severity: lintSeverityWarning, Key: "runners[0].docker.privileged", File: file, Line: 11},
+		{Rule: "concurrent-below-limits", Severity: lintSeverityWarning, Key: "concurrent", File: file, Line: 1},
+		{Rule: "overwrite-max-allowed-below-default", Severity: lintSeverityWarning, Key: "runners[1].kubernetes.cpu_request_overwrite_max_allowed", File: file, Line: 26},
+		{Rule: "unreachable-image-glob", Severity: lintSeverityWarning, Key: "runners[0].docker.allowed_images[1]", File: file, Line: 12},
+		{Rule: "unreachable-image-glob", Severity: lintSeverityWarning, Key: "runners[0].docker.allowed_services[0]", File: file, Line: 16},
+	}, findings)
+
+	assert.NoError(t, LintConfigFile("testdata/lint/policy.toml"), "policy findings are warnings")
+}
+
+func TestCheckAllowedPrivilegedImagesIgnored(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+[[runners]]
+  name = "docker-runner"
+  url = "https://gitlab.com/"
+  token = "TOKEN"
+  executor = "docker"
+  [runners.docker]
+    image = "alpine"
+    services_privileged = true
+    allowed_privileged_images = ["docker:*-dind"]
+    allowed_privileged_services = ["docker:*-dind"]
+`), 0o600))
+
+	findings, err := lintConfig(configFile)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "allowed-privileged-images-ignored", findings[0].Rule)
+	assert.Equal(t, "runners[0].docker.allowed_privileged_images", findings[0].Key)
+	assert.Equal(t, 10, findings[0].Line)
+}
+
+func TestUnreachableImageGlob(t *testing.T) {
+	tests := map[string]bool{
+		"ruby:*":                         true,
+		"*/*":                            true,
+		"registry.example.com/group/**":  true,
+		"Registry.Example.com:5000/app":  true,
+		"localhost/app:Latest":           true,
+		"[A-Za-z]*":                      true,
+		"{Ruby,python}:*":                true,
+		"ruby:[":                         false,
+		"":                               false,
+		"ruby :3":                        false,
+		"https://registry.example.com/*": false,
+		"/ruby":                          false,
+		"group/Ruby:*":                   false,
+	}
+
+	for pattern, reachable := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			assert.Equal(t, reachable, unreachableImageGlob(pattern) == "")
+		})
+	}
+}
+
+func TestScanTOMLKeyLines(t *testing.T) {
+	kl := scanTOMLKeyLines([]byte(`concurrent = 1 # comment
+[session_server]
+  listen_address = "[::]:8093"
+
+[[runners]]
+  name = "first"
+  environment = [
+    "A=1",
+    "[[runners]]",
+  ]
+  [runners.docker]
+    "dotted.key" = 1
+    pre_build_script = """
+name = "not a key"
+"""
+  [[runners.docker.services]]
+    name = "postgres"
+  [[runners.docker.services]]
+    name = "redis"
+
+[[runners]]
+  name = 'second'
+  [[runners.docker.services]]
+    name = "mysql"
+`))
+
+	assert.Equal(t, 1, kl.line([]string{"concurrent"}))
+	assert.Equal(t, 3, kl.line([]string{"session_server", "listen_address"}))
+	assert.Equal(t, 7, kl.line([]string{"runners", "0", "environment", "1"}))
+	assert.Equal(t, 12, kl.line([]string{"runners", "0", "docker", "dotted.key"}))
+	assert.Equal(t, 19, kl.line([]string{"runners", "0", "docker", "services", "1", "name"}))
+	assert.Equal(t, 24, kl.line([]string{"runners", "1", "docker", "services", "0", "name"}))
+	assert.Equal(t, 21, kl.line([]string{"runners", "1", "limit"}))
+	assert.Equal(t, 17, kl.plainLine([]string{"runners", "docker", "services", "name"}))
+	assert.Equal(t, []string{"first", "second"}, kl.runners)
+}
+
+func TestWriteLintReports(t *testing.T) {
+	findings := []lintFinding{
+		{Rule: "unknown-key", Severity: lintSeverityError, Message: "unknown key: foo", Key: "foo", File: "config.toml", Line: 3},
+		{Rule: "concurrent-below-limits", Severity: lintSeverityWarning, Message: "too low", Key: "concurrent", File: "config.toml"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeLintJSON(&buf, findings))
+
+		var report struct {
+			Findings []lintFinding `json:"findings"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+		assert.Equal(t, findings, report.Findings)
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeLintSARIF(&buf, findings))
+
+		var log sarifLog
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+		assert.Equal(t, "2.1.0", log.Version)
+		require.Len(t, log.Runs, 1)
+		assert.Len(t, log.Runs[0].Tool.Driver.Rules, len(lintRules()))
+
+		results := log.Runs[0].Results
+		require.Len(t, results, 2)
+		assert.Equal(t, "unknown-key", results[0].RuleID)
+		assert.Equal(t, lintSeverityError, results[0].Level)
+		assert.Equal(t, "config.toml", results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Equal(t, &sarifRegion{StartLine: 3}, results[0].Locations[0].PhysicalLocation.Region)
+		assert.Nil(t, results[1].Locations[0].PhysicalLocation.Region)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		assert.True(t, lintFailed(findings, false))
+		assert.False(t, lintFailed(findings[1:], false))
+		assert.True(t, lintFailed(findings[1:], true))
+	})
+}
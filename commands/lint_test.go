@@ -4,6 +4,7 @@ package commands_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -61,6 +62,20 @@ func TestLintConfigFile(t *testing.T) {
 			wantErr:     true,
 			errContains: "config schema validation failed",
 		},
+		"valid config with drop-ins": {
+			fixtureFile: "testdata/lint/dropins/config.toml",
+			wantErr:     false,
+		},
+		"invalid shell value in drop-in": {
+			fixtureFile: "testdata/lint/dropins_invalid/config.toml",
+			wantErr:     true,
+			errContains: "testdata/lint/dropins_invalid/config.d/10-shell.toml:3: config schema validation failed: at '/runners/0/shell'",
+		},
+		"misspelled key in drop-in": {
+			fixtureFile: "testdata/lint/dropins_unknown/config.toml",
+			wantErr:     true,
+			errContains: "testdata/lint/dropins_unknown/config.d/20-misspelled.toml:1: unknown key: concurent",
+		},
 	}
 
 	for name, tc := range tests {
@@ -68,7 +83,7 @@ func TestLintConfigFile(t *testing.T) {
 			err := commands.LintConfigFile(tc.fixtureFile)
 			if tc.wantErr {
 				require.Error(t, err)
-				assert.Contains(t, err.Error(), tc.errContains)
+				assert.Contains(t, err.Error(), filepath.FromSlash(tc.errContains))
 				return
 			}
 			require.NoError(t, err)
@@ -28,6 +28,7 @@ import (
 	"gitlab.com/gitlab-org/labkit/v2/fields"
 
 	"gitlab.com/gitlab-org/gitlab-runner/cache"
+	"gitlab.com/gitlab-org/gitlab-runner/cache/local"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/internal/configfile"
 	"gitlab.com/gitlab-org/gitlab-runner/commands/internal/process_state"
 	"gitlab.com/gitlab-org/gitlab-runner/common"
@@ -38,11 +39,14 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
 	prometheus_helper "gitlab.com/gitlab-org/gitlab-runner/helpers/prometheus"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/sentry"
 	service_helpers "gitlab.com/gitlab-org/gitlab-runner/helpers/service"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/logrotate"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/otlp"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/snowplow"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/webhook"
 	"gitlab.com/gitlab-org/gitlab-runner/log"
 	"gitlab.com/gitlab-org/gitlab-runner/network"
 	"gitlab.com/gitlab-org/gitlab-runner/router"
@@ -60,6 +64,10 @@ const (
 	workerProcessingFailureJobFailure     = "job_failure"
 )
 
+// cachePruneCheckInterval is how often RunCommand checks whether a runner's
+// cache prune Interval has elapsed.
+const cachePruneCheckInterval = time.Minute
+
 var (
 	concurrentDesc = prometheus.NewDesc(
 		"gitlab_runner_concurrent",
@@ -111,6 +119,7 @@ type RunCommand struct {
 	WorkingDirectory string `short:"d" long:"working-directory" description:"Specify custom working directory"`
 	User             string `short:"u" long:"user" description:"Use specific user to execute shell scripts"`
 	Syslog           bool   `long:"syslog" description:"Log to system service logger" env:"LOG_SYSLOG"`
+	RecordJobsDir    string `long:"record-jobs-dir" env:"RECORD_JOBS_DIR" description:"Save the payload of received jobs, with tokens redacted, to this directory for the replay command"`
 
 	// sentry.LogHook is a struct, so accesses are not atomic.  Use the sentryLogHookMutex to ensure
 	// mutual exclusion.
@@ -129,6 +138,8 @@ type RunCommand struct {
 
 	sessionServer *session.Server
 
+	cacheServer *http.Server
+
 	usageLogger atomic.Value // stores usageLoggerHolder
 
 	// abortBuilds is used to abort running builds
@@ -550,6 +561,8 @@ func (mr *RunCommand) reloadUsageLogger() {
 	availableWriters := map[string]func(conf common.UsageLogger) usage_log.Storage{
 		"logrotate":        mr.createLogrotateWriter,
 		"snowplow_billing": mr.createSnowplowBillingWriter,
+		"webhook":          mr.createWebhookWriter,
+		"otlp":             mr.createOTLPWriter,
 	}
 
 	var writers []usage_log.Storage
@@ -715,6 +728,92 @@ func (mr *RunCommand) createSnowplowBillingWriter(ulConfig common.UsageLogger) u
 	return writer
 }
 
+func (mr *RunCommand) createWebhookWriter(ulConfig common.UsageLogger) usage_log.Storage {
+	conf := ulConfig.Webhook
+	if conf.URL == "" {
+		mr.log().Error("Webhook url not configured, webhook writer not enabled for usage logger")
+		return nil
+	}
+
+	options, logFields := mr.usageLogDeliveryOptions("webhook", conf.UsageLogDeliveryConfig)
+	options = append(options, webhook.WithHeaders(conf.Headers), webhook.WithLabels(conf.Labels))
+
+	log := mr.log().WithFields(logFields)
+	log.Debug("webhook configuration loaded")
+
+	writer, err := webhook.New(log, conf.URL, options...)
+	if err != nil {
+		mr.log().WithError(err).Error("Failed to create webhook writer, webhook writer not enabled for usage logger")
+		return nil
+	}
+
+	return writer
+}
+
+func (mr *RunCommand) createOTLPWriter(ulConfig common.UsageLogger) usage_log.Storage {
+	conf := ulConfig.OTLP
+	if conf.Endpoint == "" {
+		mr.log().Error("OTLP endpoint not configured, otlp writer not enabled for usage logger")
+		return nil
+	}
+
+	options, logFields := mr.usageLogDeliveryOptions("otlp", conf.UsageLogDeliveryConfig)
+	options = append(options, webhook.WithLabels(conf.Labels))
+
+	log := mr.log().WithFields(logFields)
+	log.Debug("otlp configuration loaded")
+
+	writer, err := otlp.New(log, conf.Endpoint,
+		otlp.WithHeaders(conf.Headers),
+		otlp.WithResourceAttributes(conf.ResourceAttributes),
+		otlp.WithDeliveryOptions(options...),
+	)
+	if err != nil {
+		mr.log().WithError(err).Error("Failed to create otlp writer, otlp writer not enabled for usage logger")
+		return nil
+	}
+
+	return writer
+}
+
+// usageLogDeliveryOptions returns the batching, retry and spooling options
+// shared by the webhook and otlp writers. The spool directory defaults to
+// a directory per writer next to the configuration file.
+func (mr *RunCommand) usageLogDeliveryOptions(name string, conf common.UsageLogDeliveryConfig) ([]webhook.Option, logrus.Fields) {
+	spoolDir := conf.SpoolDir
+	if spoolDir == "" {
+		spoolDir = filepath.Join(filepath.Dir(mr.ConfigFile), "usage-log-spool", name)
+	}
+	if conf.DisableSpool {
+		spoolDir = ""
+	}
+
+	options := []webhook.Option{
+		webhook.WithSpoolDirectory(spoolDir),
+		webhook.WithBatchSize(conf.BatchSize),
+		webhook.WithMaxTries(conf.MaxTries),
+		webhook.WithMaxSpoolFiles(conf.MaxSpoolFiles),
+	}
+
+	logFields := logrus.Fields{
+		"writer":    name,
+		"spool_dir": spoolDir,
+	}
+
+	if conf.FlushInterval != nil {
+		options = append(options, webhook.WithFlushInterval(*conf.FlushInterval))
+		logFields["flush_interval"] = *conf.FlushInterval
+	}
+	if conf.BatchSize > 0 {
+		logFields["batch_size"] = conf.BatchSize
+	}
+	if conf.MaxTries > 0 {
+		logFields["max_tries"] = conf.MaxTries
+	}
+
+	return options, logFields
+}
+
 // run is the main method of RunCommand. It's started asynchronously by services support
 // through `Start` method and is responsible for initializing all goroutines handling
 // concurrent, multi-runner execution of jobs.
@@ -725,8 +824,10 @@ func (mr *RunCommand) createSnowplowBillingWriter(ulConfig common.UsageLogger) u
 func (mr *RunCommand) run() {
 	mr.setupMetricsAndDebugServer()
 	mr.setupSessionServer()
+	mr.setupCacheServer()
 
 	go mr.resetRunnerTokens()
+	go mr.pruneCaches()
 
 	runners := make(chan *common.RunnerConfig)
 	go mr.feedRunners(runners)
@@ -916,6 +1017,9 @@ func (mr *RunCommand) serveMetrics(mux *http.ServeMux) {
 		}
 	}
 
+	// Metrics about image pulls from the registry mirrors
+	mr.prometheusRegistry.MustRegister(registry_mirrors.Collector())
+
 	// Register all cache adapter collectors
 	for _, collector := range cache.Collectors() {
 		mr.prometheusRegistry.MustRegister(collector)
@@ -1025,6 +1129,95 @@ func (mr *RunCommand) setupSessionServer() {
 		Info("Session server listening")
 }
 
+func (mr *RunCommand) setupCacheServer() {
+	config := mr.configfile.Config().CacheServer
+	if config == nil || config.ListenAddress == "" {
+		mr.log().Debug("[cache_server].listen_address not defined, local cache endpoints disabled")
+		return
+	}
+
+	logger := mr.log().WithField("address", config.ListenAddress)
+
+	handler, err := local.NewServer(config.Dir, config.Secret, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to create cache server")
+	}
+
+	listener, err := net.Listen("tcp", config.ListenAddress)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to create listener for cache server")
+	}
+
+	mr.cacheServer = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+			err = mr.cacheServer.ServeTLS(listener, config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			err = mr.cacheServer.Serve(listener)
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Fatal("Cache server terminated")
+		}
+	}()
+
+	logger.Info("Cache server listening")
+}
+
+// pruneCaches prunes the caches of runners that configure a
+// [runners.cache.prune] Interval, until mr.runFinished is closed. Caches
+// shared by several runners are pruned once per interval.
+func (mr *RunCommand) pruneCaches() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-mr.runFinished
+		cancel()
+	}()
+
+	ticker := time.NewTicker(cachePruneCheckInterval)
+	defer ticker.Stop()
+
+	lastPruned := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, runner := range mr.configfile.Config().Runners {
+			if runner.Cache == nil || runner.Cache.Prune == nil || runner.Cache.Prune.Interval <= 0 {
+				continue
+			}
+
+			key := cachePruneKey(runner)
+			if time.Since(lastPruned[key]) < runner.Cache.Prune.Interval {
+				continue
+			}
+			lastPruned[key] = time.Now()
+
+			policy := cache.NewPrunePolicy(runner.Cache.Prune)
+			if policy.IsEmpty() {
+				continue
+			}
+
+			logger := mr.log().WithField("runner", runner.ShortDescription())
+			report, err := pruneRunnerCache(ctx, runner, policy, false)
+			logPruneReport(logger, report)
+			if err != nil {
+				logger.WithError(err).Warningln("Failed to prune cache")
+			}
+		}
+	}
+}
+
 // feedRunners works until a stopSignal was saved.
 // It is responsible for feeding the runners (workers) to channel, which
 // asynchronously ends with job requests being made and jobs being executed
@@ -1388,6 +1581,10 @@ func (mr *RunCommand) requestJob(
 		return nil, nil, retried, nil
 	}
 
+	if mr.RecordJobsDir != "" {
+		mr.recordJob(jobData)
+	}
+
 	// Inject metrics collector into JobInputs
 	jobData.Inputs.SetMetricsCollector(mr.inputsMetricsCollector)
 
@@ -1444,6 +1641,18 @@ func (mr *RunCommand) requestJob(
 	return trace, jobData, retried, nil
 }
 
+func (mr *RunCommand) recordJob(jobData *spec.Job) {
+	logger := mr.log().WithField("job", jobData.ID)
+
+	path, err := recordJob(mr.RecordJobsDir, jobData)
+	if err != nil {
+		logger.WithError(err).Warningln("Failed to record job")
+		return
+	}
+
+	logger.WithField("path", path).Debugln("Recorded job")
+}
+
 func (mr *RunCommand) executorSupportsNativeSteps(runnerConfig *common.RunnerConfig) bool {
 	netCli, ok := mr.network.(*network.GitLabClient)
 	return ok && netCli.ExecutorSupportsNativeSteps(*runnerConfig)
@@ -1565,21 +1774,23 @@ func (mr *RunCommand) updateConfig() os.Signal {
 }
 
 func (mr *RunCommand) checkConfig() (err error) {
-	info, err := os.Stat(mr.ConfigFile)
+	// the config.d directory and its fragments are watched together with
+	// the config file
+	modTime, err := common.ConfigModTime(mr.ConfigFile)
 	if err != nil {
 		return err
 	}
 
 	config := mr.configfile.Config()
-	if !config.ModTime.Before(info.ModTime()) {
+	if !config.ModTime.Before(modTime) {
 		return nil
 	}
 
 	err = mr.reloadConfig()
 	if err != nil {
 		mr.log().Errorln("Failed to load config", err)
-		// don't reload the same file
-		config.ModTime = info.ModTime()
+		// don't reload the same files
+		config.ModTime = modTime
 		return
 	}
 	return nil
@@ -1604,6 +1815,10 @@ func (mr *RunCommand) Stop(_ service.Service) error {
 		if mr.sessionServer != nil {
 			mr.sessionServer.Close()
 		}
+
+		if mr.cacheServer != nil {
+			_ = mr.cacheServer.Close()
+		}
 	}()
 
 	// On Windows, we convert SIGTERM and SIGINT signals into a SIGQUIT.
@@ -0,0 +1,362 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"gitlab.com/gitlab-org/gitlab-runner/commands/internal/configfile"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+	"gitlab.com/gitlab-org/gitlab-runner/network"
+	"gitlab.com/gitlab-org/step-runner/schema/v1"
+)
+
+const (
+	// redactedJobToken replaces the job token in recorded jobs. It's URL
+	// safe, so that the repository URL of the recorded job stays valid.
+	redactedJobToken = "REDACTED_JOB_TOKEN"
+	redactedValue    = "[MASKED]"
+)
+
+// ReplayCommand runs a job payload recorded by the run command, without
+// GitLab. The job log is written to stdout or a file, and the artifacts
+// uploaded by the runner and the job are stored in a local directory.
+type ReplayCommand struct {
+	executorProviders executors.Providers
+
+	ConfigFile   string `short:"c" long:"config" env:"CONFIG_FILE" description:"Config file"`
+	RunnerName   string `short:"r" long:"runner" description:"Name of the runner from the config file to run the job with"`
+	JobFile      string `long:"job" description:"Job payload to replay, as recorded by the run command's --record-jobs-dir option"`
+	TraceFile    string `long:"trace-file" description:"Write the job log to this file instead of stdout"`
+	ArtifactsDir string `long:"artifacts-dir" description:"Directory to store the artifacts uploaded by the runner and the job in"`
+
+	ArtifactsListenAddress string `long:"artifacts-listen-address" description:"Address to serve the artifacts endpoints of the job on"`
+	ArtifactsURL           string `long:"artifacts-url" description:"URL the job reaches the artifacts endpoints with, when it differs from the listen address, for example from containers"`
+	JobToken               string `long:"job-token" env:"REPLAY_JOB_TOKEN" description:"Token used in place of the redacted job token, for example to clone the repository"`
+}
+
+func NewReplayCommand(executorProviders executors.Providers) cli.Command {
+	return common.NewCommand("replay", "run a recorded job without GitLab", &ReplayCommand{
+		executorProviders: executorProviders,
+		ConfigFile:        GetDefaultConfigFile(),
+		ArtifactsDir:      "artifacts",
+
+		ArtifactsListenAddress: "127.0.0.1:0",
+	})
+}
+
+func (c *ReplayCommand) Execute(_ *cli.Context) {
+	err := process.EnsureSubprocessTerminationOnExit()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to wrap process in job object")
+	}
+
+	if err := c.run(); err != nil {
+		logrus.WithError(err).Fatalln("Failed to replay job")
+	}
+
+	logrus.Infoln("Job succeeded")
+}
+
+func (c *ReplayCommand) run() error {
+	if c.JobFile == "" {
+		return errors.New("missing job file")
+	}
+	if c.RunnerName == "" {
+		return errors.New("missing runner name")
+	}
+
+	cfg := configfile.New(c.ConfigFile)
+	if err := cfg.Load(); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	runner, err := cfg.Config().RunnerByName(c.RunnerName)
+	if err != nil {
+		return err
+	}
+
+	job, err := loadReplayJob(c.JobFile, c.JobToken)
+	if err != nil {
+		return fmt.Errorf("loading job: %w", err)
+	}
+
+	trace := io.Writer(os.Stdout)
+	if c.TraceFile != "" {
+		f, err := os.Create(c.TraceFile)
+		if err != nil {
+			return fmt.Errorf("creating trace file: %w", err)
+		}
+		defer f.Close()
+		trace = f
+	}
+
+	client := network.NewReplayClient(trace, c.ArtifactsDir)
+
+	artifactsURL, stop, err := c.serveArtifacts(client)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return c.replay(cfg.Config(), runner, job, client, artifactsURL)
+}
+
+// serveArtifacts serves the artifacts endpoints of the client, so that the
+// job's artifacts are stored locally instead of being sent to GitLab. It
+// returns the URL the job reaches them with, and a function stopping the
+// server.
+func (c *ReplayCommand) serveArtifacts(client *network.ReplayClient) (string, func(), error) {
+	listener, err := net.Listen("tcp", c.ArtifactsListenAddress)
+	if err != nil {
+		return "", nil, fmt.Errorf("listening for artifacts: %w", err)
+	}
+
+	server := &http.Server{
+		Handler:           client.ArtifactsHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Errorln("Artifacts server failed")
+		}
+	}()
+
+	artifactsURL := c.ArtifactsURL
+	if artifactsURL == "" {
+		artifactsURL = "http://" + listener.Addr().String()
+	}
+
+	logrus.WithField("url", artifactsURL).Infoln("Serving job artifacts")
+
+	return artifactsURL, func() { _ = server.Close() }, nil
+}
+
+func (c *ReplayCommand) replay(
+	config *common.Config,
+	runner *common.RunnerConfig,
+	job *spec.Job,
+	client *network.ReplayClient,
+	artifactsURL string,
+) error {
+	executorProvider := c.executorProviders.GetByName(runner.Executor)
+	if executorProvider == nil {
+		return fmt.Errorf("unknown executor %q", runner.Executor)
+	}
+
+	if managedProvider, ok := executorProvider.(common.ManagedExecutorProvider); ok {
+		managedProvider.Init()
+		defer managedProvider.Shutdown(context.Background(), nil)
+	}
+
+	if runner.SystemID == "" {
+		systemID, err := configfile.GenerateUniqueSystemID()
+		if err != nil {
+			return fmt.Errorf("generating system ID: %w", err)
+		}
+		runner.SystemID = systemID
+	}
+
+	abortSignal := make(chan os.Signal, 1)
+	signal.Notify(abortSignal, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(abortSignal)
+
+	data, err := executorProvider.Acquire(runner)
+	if err != nil {
+		logrus.Warningln("Executor update:", err)
+	}
+	defer executorProvider.Release(runner, data)
+
+	build, err := common.NewBuild(*job, runner, abortSignal, data, executorProvider)
+	if err != nil {
+		return err
+	}
+	build.ArtifactUploader = client.UploadRawArtifacts
+	build.ArtifactsURL = artifactsURL
+
+	jobCredentials := &common.JobCredentials{
+		ID:    job.ID,
+		Token: job.Token,
+	}
+
+	trace, err := client.ProcessJob(*runner, jobCredentials)
+	if err != nil {
+		return err
+	}
+	trace.SetDebugModeEnabled(build.IsDebugModeEnabled())
+
+	logrus.WithFields(logrus.Fields{
+		"job":      job.ID,
+		"runner":   runner.Name,
+		"executor": runner.Executor,
+		"repo_url": build.RepoCleanURL(),
+	}).Infoln("Replaying job")
+
+	return build.Run(context.Background(), config, trace)
+}
+
+// loadReplayJob reads a recorded job payload and prepares it to be run the
+// way the run command prepares received jobs. The redacted job token is
+// replaced with token, when set.
+func loadReplayJob(path string, token string) (*spec.Job, error) {
+	job, err := loadJob(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		replaceJobToken(job, redactedJobToken, token)
+	} else if job.Token == redactedJobToken {
+		logrus.Warningln("The job token is redacted, use --job-token to access GitLab from the job")
+	}
+
+	return job, nil
+}
+
+// loadJob reads a job payload, and checks that the runner supports the job
+func loadJob(path string) (*spec.Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var job spec.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	if err := job.UnsupportedOptions(); err != nil {
+		return nil, err
+	}
+	if err := job.ValidateStepsJobRequest(false); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// recordJob saves the payload of the job in dir, with its token, masked
+// variables, registry credentials and sensitive inputs redacted.
+func recordJob(dir string, job *spec.Job) (string, error) {
+	redacted, err := redactJob(*job)
+	if err != nil {
+		return "", err
+	}
+
+	recorded, err := newJobRecording(redacted)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "job-"+strconv.FormatInt(job.ID, 10)+".json")
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// jobRecording is the encoding of a recorded job payload. spec.Job doesn't
+// encode its steps and inputs the way GitLab sends them, so they're encoded
+// here in the format loadJob decodes.
+type jobRecording struct {
+	spec.Job
+
+	Run    string          `json:"run,omitempty"`
+	Inputs []spec.JobInput `json:"inputs"`
+}
+
+func newJobRecording(job spec.Job) (jobRecording, error) {
+	recorded := jobRecording{Job: job}
+
+	if len(job.Run) > 0 {
+		run, err := json.Marshal([]schema.Step(job.Run))
+		if err != nil {
+			return jobRecording{}, fmt.Errorf("encoding run: %w", err)
+		}
+		recorded.Run = string(run)
+	}
+
+	inputs, err := job.Inputs.JobInputs()
+	if err != nil {
+		return jobRecording{}, fmt.Errorf("encoding inputs: %w", err)
+	}
+	recorded.Inputs = inputs
+
+	return recorded, nil
+}
+
+func redactJob(job spec.Job) (spec.Job, error) {
+	job.Variables = append(spec.Variables(nil), job.Variables...)
+	job.Credentials = append([]spec.Credentials(nil), job.Credentials...)
+	job.Dependencies = append(spec.Dependencies(nil), job.Dependencies...)
+
+	if job.Token != "" {
+		replaceJobToken(&job, job.Token, redactedJobToken)
+	}
+
+	for i, v := range job.Variables {
+		if v.Masked && !strings.Contains(v.Value, redactedJobToken) {
+			job.Variables[i].Value = redactedValue
+		}
+	}
+
+	for i, credentials := range job.Credentials {
+		if !strings.Contains(credentials.Password, redactedJobToken) {
+			job.Credentials[i].Password = redactedValue
+		}
+	}
+
+	inputs, err := job.Inputs.MaskSensitive(redactedValue)
+	if err != nil {
+		return spec.Job{}, fmt.Errorf("masking sensitive inputs: %w", err)
+	}
+	job.Inputs = inputs
+
+	return job, nil
+}
+
+// replaceJobToken replaces from with to wherever the job payload holds the
+// job token.
+func replaceJobToken(job *spec.Job, from, to string) {
+	if job.Token == from {
+		job.Token = to
+	}
+
+	job.GitInfo.RepoURL = strings.ReplaceAll(job.GitInfo.RepoURL, from, to)
+
+	for i := range job.Variables {
+		job.Variables[i].Value = strings.ReplaceAll(job.Variables[i].Value, from, to)
+	}
+
+	for i := range job.Credentials {
+		job.Credentials[i].Password = strings.ReplaceAll(job.Credentials[i].Password, from, to)
+	}
+
+	for i := range job.Dependencies {
+		job.Dependencies[i].Token = strings.ReplaceAll(job.Dependencies[i].Token, from, to)
+	}
+}
@@ -197,12 +197,25 @@ type Build struct {
 
 	Referees         []referees.Referee
 	ArtifactUploader func(config JobCredentials, bodyProvider ContentProvider, options ArtifactsOptions) (UploadState, string, error)
+	// ArtifactsURL is where the job uploads and downloads artifacts, in
+	// place of the runner's URL.
+	ArtifactsURL string
 
 	urlHelper *url_helpers.GitAuthHelper
 
 	OnBuildStageStartFn            OnBuildStageFn
 	OnBuildStageEndFn              OnBuildStageFn
 	OnJobExecutionModeDispatchedFn OnJobExecutionModeDispatchedFn
+
+	timeline     *JobTimeline
+	timelineOnce sync.Once
+
+	serviceLogs     *ServiceLogs
+	serviceLogsOnce sync.Once
+
+	// refereeArtifacts are the results of the referees, uploaded once the
+	// executor cleaned up
+	refereeArtifacts []refereeArtifact
 }
 
 func (b *Build) setCurrentStage(stage BuildStage) {
@@ -552,6 +565,7 @@ func (b *Build) executeStepStage(ctx context.Context, connector steps.Connector,
 
 	b.OnBuildStageStartFn.Call(buildStage)
 	defer b.OnBuildStageEndFn.Call(buildStage)
+	defer b.Timeline().StartStage(buildStage)()
 
 	b.setCurrentStage(buildStage)
 	b.Log().WithField("build_stage", buildStage).Debug("Executing build stage")
@@ -692,6 +706,7 @@ func (b *Build) executeStage(ctx context.Context, buildStage BuildStage, executo
 
 	b.OnBuildStageStartFn.Call(buildStage)
 	defer b.OnBuildStageEndFn.Call(buildStage)
+	defer b.Timeline().StartStage(buildStage)()
 
 	b.setCurrentStage(buildStage)
 	b.Log().WithField("build_stage", buildStage).Debug("Executing build stage")
@@ -812,6 +827,8 @@ func (b *Build) executeScript(ctx, prepareCtx context.Context, trace JobTrace, e
 	// track job start and create referees
 	startTime := time.Now()
 	b.createReferees(executor)
+	stopSampling := referees.StartSampling(ctx, b.Referees)
+	defer stopSampling()
 
 	connector, hasStepRunnerConnector := executor.(steps.Connector)
 
@@ -856,9 +873,10 @@ func (b *Build) executeScript(ctx, prepareCtx context.Context, trace JobTrace, e
 		b.executeUploadArtifacts(ctx, err, executor),
 	)
 
-	// track job end and execute referees
-	b.executeUploadReferees(ctx, startTime, time.Now())
+	// track job end and execute referees, once the job timeline is complete
+	endTime := time.Now()
 	b.removeFileBasedVariables(ctx, executor)
+	b.executeReferees(ctx, startTime, endTime)
 
 	return err
 }
@@ -896,7 +914,7 @@ func (b *Build) executeConcreteJob(
 		b.warnTimeoutExceeded(ctx, "Job")
 	}
 
-	b.executeUploadReferees(ctx, startTime, time.Now())
+	b.executeReferees(ctx, startTime, time.Now())
 
 	return err
 }
@@ -1056,19 +1074,27 @@ func (b *Build) removeFileBasedVariables(ctx context.Context, executor Executor)
 	}
 }
 
-func (b *Build) executeUploadReferees(ctx context.Context, startTime, endTime time.Time) {
-	if b.Referees == nil || b.ArtifactUploader == nil {
+// GetArtifactsURL returns the URL the job uploads and downloads artifacts
+// with.
+func (b *Build) GetArtifactsURL() string {
+	if b.ArtifactsURL != "" {
+		return b.ArtifactsURL
+	}
+
+	return b.Runner.URL
+}
+
+// executeReferees executes the referees, and keeps their results for
+// executeUploadReferees to upload.
+func (b *Build) executeReferees(ctx context.Context, startTime, endTime time.Time) {
+	if b.ArtifactUploader == nil {
 		b.Log().Debug("Skipping referees execution")
 		return
 	}
 
-	jobCredentials := JobCredentials{
-		ID:    b.Job.ID,
-		Token: b.Job.Token,
-		URL:   b.Runner.RunnerCredentials.URL,
-	}
+	var artifacts []refereeArtifact
 
-	// execute and upload the results of each referee
+	// execute each referee
 	for _, referee := range b.Referees {
 		if referee == nil {
 			continue
@@ -1080,18 +1106,48 @@ func (b *Build) executeUploadReferees(ctx context.Context, startTime, endTime ti
 			continue
 		}
 
-		bodyProvider := StreamProvider{
-			ReaderFactory: func() (io.ReadCloser, error) {
-				return io.NopCloser(reader), nil
-			},
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			continue
 		}
 
-		// referee ran successfully, upload its results to GitLab as an artifact
-		_, _, _ = b.ArtifactUploader(jobCredentials, bodyProvider, ArtifactsOptions{
-			BaseName: referee.ArtifactBaseName(),
-			Type:     referee.ArtifactType(),
-			Format:   spec.ArtifactFormat(referee.ArtifactFormat()),
+		artifacts = append(artifacts, refereeArtifact{
+			baseName:     referee.ArtifactBaseName(),
+			artifactType: referee.ArtifactType(),
+			format:       spec.ArtifactFormat(referee.ArtifactFormat()),
+			data:         data,
+		})
+	}
+
+	b.refereeArtifacts = artifacts
+}
+
+// executeUploadReferees uploads the results of the referees, each as an
+// artifact of its own. It's run once the executor cleaned up, whatever the
+// outcome of the job.
+func (b *Build) executeUploadReferees() {
+	artifacts := b.refereeArtifacts
+	if len(artifacts) == 0 || b.ArtifactUploader == nil {
+		b.Log().Debug("Skipping referees upload")
+		return
+	}
+
+	jobCredentials := JobCredentials{
+		ID:    b.Job.ID,
+		Token: b.Job.Token,
+		URL:   b.Runner.RunnerCredentials.URL,
+	}
+
+	// upload the results to GitLab as artifacts
+	for _, artifact := range artifacts {
+		_, _, err := b.ArtifactUploader(jobCredentials, BytesProvider{Data: artifact.data}, ArtifactsOptions{
+			BaseName: artifact.baseName,
+			Type:     artifact.artifactType,
+			Format:   artifact.format,
 		})
+		if err != nil {
+			b.Log().WithError(err).WithField("artifact", artifact.baseName).Warning("Failed to upload referee results")
+		}
 	}
 }
 
@@ -1587,6 +1643,12 @@ func (b *Build) Run(ctx context.Context, globalConfig *Config, trace JobTrace) (
 		return fmt.Errorf("retrieving executor features: %w", err)
 	}
 
+	// registered before the executor is prepared, for the logs of the services
+	// and the timeline to be uploaded once its cleanup stopped the services,
+	// even if the job failed
+	defer b.executeUploadJobDiagnostics()
+	defer b.executeUploadReferees()
+
 	executor, err := b.executeBuildSection(options, provider)
 	if err != nil {
 		if cause := context.Cause(prepareCtx); errors.Is(cause, ErrJobPrepareTimeout) {
@@ -1772,7 +1834,13 @@ func (b *Build) executeResolveSecretsStage(trace JobTrace) error {
 			logger := b.getNewLogger(trace, b.Log(), false)
 			defer logger.Close()
 
-			resolver, err := b.secretsResolver(&logger, GetSecretResolverRegistry(), b.IsFeatureFlagOn)
+			registry := &runnerSecretResolverRegistry{
+				SecretResolverRegistry: GetSecretResolverRegistry(),
+				config:                 b.Runner,
+				jobInfo:                b.JobInfo,
+			}
+
+			resolver, err := b.secretsResolver(&logger, registry, b.IsFeatureFlagOn)
 			if err != nil {
 				return fmt.Errorf("creating secrets resolver: %w", err)
 			}
@@ -1798,6 +1866,7 @@ func (b *Build) executeBuildSection(options ExecutorPrepareOptions, provider Exe
 
 	b.OnBuildStageStartFn.Call(BuildStagePrepareExecutor)
 	defer b.OnBuildStageEndFn.Call(BuildStagePrepareExecutor)
+	defer b.Timeline().StartStage(BuildStagePrepareExecutor)()
 
 	section := helpers.BuildSection{
 		Name:        string(BuildStagePrepareExecutor),
@@ -2444,6 +2513,12 @@ func (b *Build) IsCIDebugServiceEnabled() bool {
 	return b.Settings().CIDebugServices
 }
 
+// IsServiceLogsArtifactEnabled returns whether the logs of the service
+// containers are uploaded with the job_diagnostics artifact.
+func (b *Build) IsServiceLogsArtifactEnabled() bool {
+	return b.Settings().CIServiceLogsArtifact
+}
+
 func (b *Build) IsDebugModeEnabled() bool {
 	return b.IsDebugTraceEnabled() || b.IsCIDebugServiceEnabled()
 }
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+const (
+	jobDiagnosticsArtifactName = "job_diagnostics.tar.gz"
+	// jobDiagnosticsArtifactType is the type of the artifact of the files the
+	// runner collects about the job. GitLab accepts one artifact of each type
+	// for a job, and stores network_referee artifacts without processing them,
+	// so it doesn't conflict with the archive artifact of the job.
+	jobDiagnosticsArtifactType = "network_referee"
+)
+
+// jobDiagnosticsFile is a file of the job_diagnostics artifact.
+type jobDiagnosticsFile struct {
+	name string
+	// write writes the file to the archive, once its header is written with
+	// the size it returns.
+	write func(w *tar.Writer, header *tar.Header) error
+}
+
+func bytesDiagnosticsFile(name string, data []byte) jobDiagnosticsFile {
+	return jobDiagnosticsFile{
+		name: name,
+		write: func(w *tar.Writer, header *tar.Header) error {
+			header.Size = int64(len(data))
+			if err := w.WriteHeader(header); err != nil {
+				return err
+			}
+
+			_, err := w.Write(data)
+			return err
+		},
+	}
+}
+
+// executeUploadJobDiagnostics uploads the logs of the service containers,
+// when enabled with CI_SERVICE_LOGS_ARTIFACT, and the timeline of the job,
+// when enabled with upload_job_timeline, as the job_diagnostics artifact. It's
+// run once the executor cleaned up, whatever the outcome of the job, and
+// whether the job uploads artifacts of its own.
+func (b *Build) executeUploadJobDiagnostics() {
+	var files []jobDiagnosticsFile
+
+	if logs := b.ServiceLogs(); logs != nil {
+		defer logs.remove()
+
+		logFiles, errs := logs.close(serviceLogsCloseTimeout)
+		for _, err := range errs {
+			b.logger.Warningln("Service logs:", err.Error())
+		}
+
+		for _, file := range logFiles {
+			files = append(files, file.diagnosticsFile())
+		}
+	}
+
+	if b.IsJobTimelineArtifactEnabled() {
+		data, err := b.encodeTimeline()
+		if err != nil {
+			b.logger.Warningln("Failed to encode the job timeline:", err.Error())
+		} else {
+			files = append(files, bytesDiagnosticsFile(jobTimelineFileName, data))
+		}
+	}
+
+	if len(files) == 0 || b.ArtifactUploader == nil {
+		return
+	}
+
+	b.uploadJobDiagnostics(files)
+}
+
+func (b *Build) uploadJobDiagnostics(files []jobDiagnosticsFile) {
+	f, err := os.CreateTemp("", "job-diagnostics")
+	if err != nil {
+		b.logger.Warningln("Failed to archive the job diagnostics:", err.Error())
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := archiveJobDiagnostics(f, files); err != nil {
+		b.logger.Warningln("Failed to archive the job diagnostics:", err.Error())
+		return
+	}
+
+	jobCredentials := JobCredentials{
+		ID:    b.Job.ID,
+		Token: b.Job.Token,
+		URL:   b.Runner.RunnerCredentials.URL,
+	}
+
+	bodyProvider := StreamProvider{
+		ReaderFactory: func() (io.ReadCloser, error) {
+			return os.Open(f.Name())
+		},
+	}
+
+	b.logger.Println("Uploading the job diagnostics as the job_diagnostics artifact...")
+
+	_, _, err = b.ArtifactUploader(jobCredentials, bodyProvider, ArtifactsOptions{
+		BaseName: jobDiagnosticsArtifactName,
+		Type:     jobDiagnosticsArtifactType,
+		Format:   spec.ArtifactFormatGzip,
+	})
+	if err != nil {
+		b.logger.Warningln("Failed to upload the job_diagnostics artifact:", err.Error())
+	}
+}
+
+// archiveJobDiagnostics writes the files to w as a gzip compressed tar
+// archive.
+func archiveJobDiagnostics(w io.Writer, files []jobDiagnosticsFile) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.name,
+			Mode:     0o644,
+			ModTime:  time.Now(),
+		}
+
+		if err := file.write(archive, header); err != nil {
+			return fmt.Errorf("archiving %s: %w", file.name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,142 @@
+//go:build !integration
+
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+// readJobDiagnostics returns the files of the job_diagnostics artifact, by
+// name.
+func readJobDiagnostics(t *testing.T, bodyProvider ContentProvider) map[string]string {
+	t.Helper()
+
+	body, err := bodyProvider.GetReader()
+	require.NoError(t, err)
+	defer body.Close()
+
+	gz, err := gzip.NewReader(body)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(archive)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+
+	return files
+}
+
+func TestBuild_executeUploadJobDiagnostics_Timeline(t *testing.T) {
+	tests := map[string]struct {
+		uploadJobTimeline   bool
+		artifacts           spec.Artifacts
+		serviceLogsArtifact string
+		expectedUpload      bool
+	}{
+		"disabled": {},
+		"timeline": {
+			uploadJobTimeline: true,
+			expectedUpload:    true,
+		},
+		"timeline of a job with archive artifact": {
+			uploadJobTimeline: true,
+			artifacts:         spec.Artifacts{{Paths: spec.ArtifactPaths{"out/"}}},
+			expectedUpload:    true,
+		},
+		"timeline of a job with archive artifact and service logs": {
+			uploadJobTimeline:   true,
+			artifacts:           spec.Artifacts{{Paths: spec.ArtifactPaths{"out/"}}},
+			serviceLogsArtifact: "true",
+			expectedUpload:      true,
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			build := &Build{
+				Runner: &RunnerConfig{
+					RunnerCredentials: RunnerCredentials{URL: "https://gitlab.example.com"},
+					UploadJobTimeline: tc.uploadJobTimeline,
+				},
+				Job: spec.Job{
+					ID:        42,
+					Token:     "job-token",
+					Artifacts: tc.artifacts,
+					Variables: spec.Variables{{Key: "CI_SERVICE_LOGS_ARTIFACT", Value: tc.serviceLogsArtifact}},
+				},
+				startedAt: time.Now(),
+			}
+			build.logger = buildlogger.New(&Trace{Writer: io.Discard}, logrus.NewEntry(logrus.New()), buildlogger.Options{})
+
+			build.Timeline().StartStage(BuildStageGetSources)()
+			build.Timeline().StartEvent(TimelineEventImagePull, map[string]string{"image": "alpine"})()
+
+			w := build.ServiceLogs().Writer("postgres", nil)
+			_, err := w.Write([]byte("database system is ready"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			uploaded := map[string]ArtifactsOptions{}
+			var files map[string]string
+			build.ArtifactUploader = func(config JobCredentials, bodyProvider ContentProvider, options ArtifactsOptions) (UploadState, string, error) {
+				uploaded[options.Type] = options
+				if options.Type != jobDiagnosticsArtifactType {
+					return UploadSucceeded, "", nil
+				}
+
+				assert.Equal(t, int64(42), config.ID)
+				assert.Equal(t, "job-token", config.Token)
+				files = readJobDiagnostics(t, bodyProvider)
+
+				return UploadSucceeded, "", nil
+			}
+
+			build.executeUploadJobDiagnostics()
+
+			if !tc.expectedUpload {
+				assert.NotContains(t, uploaded, jobDiagnosticsArtifactType)
+				return
+			}
+
+			assert.Equal(t, ArtifactsOptions{
+				BaseName: "job_diagnostics.tar.gz",
+				Type:     "network_referee",
+				Format:   spec.ArtifactFormatGzip,
+			}, uploaded[jobDiagnosticsArtifactType])
+
+			require.Contains(t, files, "timeline.json")
+			if tc.serviceLogsArtifact != "" {
+				assert.Equal(t, "database system is ready", files["service_logs/postgres.log"])
+			}
+
+			var report jobTimelineReport
+			require.NoError(t, json.Unmarshal([]byte(files["timeline.json"]), &report))
+			assert.Equal(t, int64(42), report.JobID)
+			require.Len(t, report.Stages, 1)
+			assert.Equal(t, string(BuildStageGetSources), report.Stages[0].Name)
+			require.Len(t, report.Events, 1)
+			assert.Equal(t, "alpine", report.Events[0].Attributes["image"])
+		})
+	}
+}
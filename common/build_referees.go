@@ -0,0 +1,13 @@
+package common
+
+import (
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+// refereeArtifact is the result of a referee to upload as an artifact.
+type refereeArtifact struct {
+	baseName     string
+	artifactType string
+	format       spec.ArtifactFormat
+	data         []byte
+}
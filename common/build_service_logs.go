@@ -0,0 +1,301 @@
+package common
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// serviceLogsDir is the directory of the logs of the service containers
+	// in the job_diagnostics artifact.
+	serviceLogsDir = "service_logs"
+
+	// serviceLogsCloseTimeout is how long the upload waits for the log
+	// streams of the service containers to end, once the executor cleaned up.
+	serviceLogsCloseTimeout = 10 * time.Second
+)
+
+var serviceLogNameUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ServiceLogs captures the logs of the service containers of a job, each to
+// a file of its own, which are uploaded with the job_diagnostics artifact at
+// the end of the job. The logs are masked like the job log, and a log that
+// exceeds the limit is truncated. It's safe for concurrent use, and a nil
+// ServiceLogs captures nothing.
+type ServiceLogs struct {
+	limit int64
+	mask  func(w io.WriteCloser) io.WriteCloser
+
+	mu     sync.Mutex
+	dir    string
+	files  []*serviceLogFile
+	errs   []error
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newServiceLogs(limit int64, mask func(w io.WriteCloser) io.WriteCloser) *ServiceLogs {
+	return &ServiceLogs{limit: limit, mask: mask}
+}
+
+// Writer returns the writer capturing the log of the service, which also
+// writes it to w, when w isn't nil. Closing the writer closes w.
+func (l *ServiceLogs) Writer(service string, w io.WriteCloser) io.WriteCloser {
+	if w == nil {
+		w = nopWriteCloser{Writer: io.Discard}
+	}
+
+	if l == nil {
+		return w
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return w
+	}
+
+	file, err := l.create(service)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("capturing the log of service %s: %w", service, err))
+		return w
+	}
+
+	l.wg.Add(1)
+
+	return &serviceLogWriter{w: w, file: l.mask(nopWriteCloser{Writer: file}), done: l.wg.Done}
+}
+
+func (l *ServiceLogs) create(service string) (*serviceLogFile, error) {
+	if l.dir == "" {
+		dir, err := os.MkdirTemp("", "service-logs")
+		if err != nil {
+			return nil, err
+		}
+		l.dir = dir
+	}
+
+	name := l.uniqueName(serviceLogNameUnsafeChars.ReplaceAllString(service, "_"))
+
+	f, err := os.Create(filepath.Join(l.dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &serviceLogFile{name: name, path: f.Name(), f: f, limit: l.limit}
+	l.files = append(l.files, file)
+
+	return file, nil
+}
+
+// uniqueName returns the name of the log file of the service, which is
+// numbered when another service already has it, like when the same image is
+// used for several services without aliases.
+func (l *ServiceLogs) uniqueName(service string) string {
+	if service == "" {
+		service = "service"
+	}
+
+	name := service + ".log"
+	for i := 2; l.hasFile(name); i++ {
+		name = service + "-" + strconv.Itoa(i) + ".log"
+	}
+
+	return name
+}
+
+func (l *ServiceLogs) hasFile(name string) bool {
+	for _, file := range l.files {
+		if file.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// close stops capturing the logs, once their writers are closed or the
+// timeout expires, and returns the log files, and the errors capturing them.
+func (l *ServiceLogs) close(timeout time.Duration) ([]*serviceLogFile, []error) {
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	var errs []error
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		errs = append(errs, fmt.Errorf("timed out waiting for the log streams of the services to end"))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+
+	return l.files, append(l.errs, errs...)
+}
+
+func (l *ServiceLogs) remove() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, file := range l.files {
+		_ = file.close()
+	}
+
+	if l.dir != "" {
+		_ = os.RemoveAll(l.dir)
+	}
+}
+
+type serviceLogFile struct {
+	name  string
+	path  string
+	limit int64
+
+	mu        sync.Mutex
+	f         *os.File
+	written   int64
+	truncated bool
+}
+
+// Write writes to the file what's within the limit. Errors writing the file
+// are ignored, for them not to stop the log from being written to the job
+// log.
+func (f *serviceLogFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(p)
+	if f.truncated || f.f == nil {
+		return n, nil
+	}
+
+	if remaining := f.limit - f.written; int64(len(p)) > remaining {
+		p = p[:max(0, remaining)]
+		f.truncated = true
+	}
+
+	written, err := f.f.Write(p)
+	f.written += int64(written)
+	if err != nil {
+		f.truncated = true
+	}
+
+	if f.truncated {
+		_, _ = fmt.Fprintf(f.f, "\n[the log exceeded the limit of %d bytes and was truncated]\n", f.limit)
+	}
+
+	return n, nil
+}
+
+// diagnosticsFile returns the log as a file of the job_diagnostics artifact.
+func (f *serviceLogFile) diagnosticsFile() jobDiagnosticsFile {
+	return jobDiagnosticsFile{
+		name: path.Join(serviceLogsDir, f.name),
+		write: func(w *tar.Writer, header *tar.Header) error {
+			// held for the size not to change while the log is archived
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			r, err := os.Open(f.path)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			info, err := r.Stat()
+			if err != nil {
+				return err
+			}
+
+			header.Size = info.Size()
+			if err := w.WriteHeader(header); err != nil {
+				return err
+			}
+
+			_, err = io.CopyN(w, r, header.Size)
+
+			return err
+		},
+	}
+}
+
+func (f *serviceLogFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.f == nil {
+		return nil
+	}
+
+	err := f.f.Close()
+	f.f = nil
+
+	return err
+}
+
+// serviceLogWriter writes the log of a service to its masked file, and to w.
+type serviceLogWriter struct {
+	w    io.WriteCloser
+	file io.WriteCloser
+
+	once sync.Once
+	done func()
+}
+
+func (w *serviceLogWriter) Write(p []byte) (int, error) {
+	_, _ = w.file.Write(p)
+
+	return w.w.Write(p)
+}
+
+func (w *serviceLogWriter) Close() error {
+	w.once.Do(func() {
+		// flushes what the masking holds back
+		_ = w.file.Close()
+		w.done()
+	})
+
+	return w.w.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// ServiceLogs returns the collector of the logs of the service containers of
+// the job, which is nil unless the job enables the upload of the logs with
+// CI_SERVICE_LOGS_ARTIFACT.
+func (b *Build) ServiceLogs() *ServiceLogs {
+	b.serviceLogsOnce.Do(func() {
+		if !b.IsServiceLogsArtifactEnabled() {
+			return
+		}
+
+		limit := int64(DefaultServiceLogsLimit)
+		if b.Runner != nil && b.Runner.ServiceLogsLimit > 0 {
+			limit = int64(b.Runner.ServiceLogsLimit) * 1024
+		}
+
+		b.serviceLogs = newServiceLogs(limit, func(w io.WriteCloser) io.WriteCloser {
+			return b.logger.Mask(w)
+		})
+	})
+
+	return b.serviceLogs
+}
@@ -41,8 +41,9 @@ const (
 )
 
 type BuildSettings struct {
-	CIDebugServices bool
-	CIDebugTrace    bool
+	CIDebugServices       bool
+	CIDebugTrace          bool
+	CIServiceLogsArtifact bool
 
 	GitClonePath            string
 	GitCheckout             bool
@@ -156,6 +157,7 @@ func validateVariables(variables spec.Variables, b *Build, defaultGitStategy Git
 	return []error{
 		validate(variables, "CI_DEBUG_SERVICES", &b.buildSettings.CIDebugServices, false),
 		validate(variables, "CI_DEBUG_TRACE", &b.buildSettings.CIDebugTrace, false),
+		validate(variables, "CI_SERVICE_LOGS_ARTIFACT", &b.buildSettings.CIServiceLogsArtifact, false),
 
 		validate(variables, "GIT_CLONE_PATH", &b.buildSettings.GitClonePath, ""),
 		validate(variables, "GIT_STRATEGY", &b.buildSettings.GitStrategy, defaultGitStategy),
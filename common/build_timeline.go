@@ -0,0 +1,134 @@
+package common
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// TimelineEventImagePull is recorded while the executor pulls, or looks
+	// up, the image of the job or of a service.
+	TimelineEventImagePull = "image_pull"
+	// TimelineEventServiceWait is recorded while the executor waits for a
+	// service to be up and running.
+	TimelineEventServiceWait = "service_wait"
+
+	jobTimelineFileName = "timeline.json"
+)
+
+// JobTimelineEntry is a stage of the job, or an operation of the executor,
+// that ran between StartedAt and FinishedAt.
+type JobTimelineEntry struct {
+	Name            string            `json:"name"`
+	StartedAt       time.Time         `json:"started_at"`
+	FinishedAt      time.Time         `json:"finished_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+}
+
+// JobTimeline records when the stages of a job ran, and the executor
+// operations that aren't stages of their own, like pulling images or waiting
+// for services. It's safe for concurrent use.
+type JobTimeline struct {
+	mu     sync.Mutex
+	stages []JobTimelineEntry
+	events []JobTimelineEntry
+}
+
+// StartStage records the start of the stage, and returns the function
+// recording its end.
+func (t *JobTimeline) StartStage(stage BuildStage) func() {
+	return t.start(&t.stages, string(stage), nil)
+}
+
+// StartEvent records the start of an executor operation, and returns the
+// function recording its end.
+func (t *JobTimeline) StartEvent(name string, attributes map[string]string) func() {
+	return t.start(&t.events, name, attributes)
+}
+
+func (t *JobTimeline) start(entries *[]JobTimelineEntry, name string, attributes map[string]string) func() {
+	startedAt := time.Now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			finishedAt := time.Now()
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			*entries = append(*entries, JobTimelineEntry{
+				Name:            name,
+				StartedAt:       startedAt,
+				FinishedAt:      finishedAt,
+				DurationSeconds: finishedAt.Sub(startedAt).Seconds(),
+				Attributes:      attributes,
+			})
+		})
+	}
+}
+
+// Stages returns the stages that have ended, in the order they started.
+func (t *JobTimeline) Stages() []JobTimelineEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return sortedTimelineEntries(t.stages)
+}
+
+// Events returns the executor operations that have ended, in the order they
+// started.
+func (t *JobTimeline) Events() []JobTimelineEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return sortedTimelineEntries(t.events)
+}
+
+func sortedTimelineEntries(entries []JobTimelineEntry) []JobTimelineEntry {
+	sorted := slices.Clone(entries)
+	slices.SortStableFunc(sorted, func(a, b JobTimelineEntry) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+
+	return sorted
+}
+
+type jobTimelineReport struct {
+	JobID     int64              `json:"job_id"`
+	StartedAt time.Time          `json:"started_at"`
+	Stages    []JobTimelineEntry `json:"stages"`
+	Events    []JobTimelineEntry `json:"events"`
+}
+
+// Timeline returns the timeline of the job, which the executors can use to
+// record their own operations.
+func (b *Build) Timeline() *JobTimeline {
+	b.timelineOnce.Do(func() {
+		b.timeline = &JobTimeline{}
+	})
+
+	return b.timeline
+}
+
+// IsJobTimelineArtifactEnabled returns whether the timeline of the job is
+// uploaded with the job_diagnostics artifact.
+func (b *Build) IsJobTimelineArtifactEnabled() bool {
+	return b.Runner != nil && b.Runner.UploadJobTimeline
+}
+
+// encodeTimeline returns the timeline of the job, as the content of
+// timeline.json.
+func (b *Build) encodeTimeline() ([]byte, error) {
+	report := jobTimelineReport{
+		JobID:     b.Job.ID,
+		StartedAt: b.startedAt,
+		Stages:    b.Timeline().Stages(),
+		Events:    b.Timeline().Events(),
+	}
+
+	return json.Marshal(report)
+}
@@ -0,0 +1,92 @@
+//go:build !integration
+
+package common
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
+)
+
+func TestJobTimeline(t *testing.T) {
+	timeline := &JobTimeline{}
+
+	endPrepare := timeline.StartStage(BuildStagePrepare)
+	endScript := timeline.StartStage("step_script")
+	endScript()
+	endPrepare()
+	endPrepare()
+
+	var wg sync.WaitGroup
+	for _, service := range []string{"postgres", "redis"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timeline.StartEvent(TimelineEventServiceWait, map[string]string{"service": service})()
+		}()
+	}
+	wg.Wait()
+
+	stages := timeline.Stages()
+	require.Len(t, stages, 2, "ending a stage twice records it once")
+	assert.Equal(t, string(BuildStagePrepare), stages[0].Name, "stages are sorted by start")
+	assert.Equal(t, "step_script", stages[1].Name)
+	assert.False(t, stages[0].FinishedAt.Before(stages[0].StartedAt))
+	assert.Equal(t, stages[0].FinishedAt.Sub(stages[0].StartedAt).Seconds(), stages[0].DurationSeconds)
+
+	events := timeline.Events()
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, TimelineEventServiceWait, event.Name)
+		assert.Contains(t, []string{"postgres", "redis"}, event.Attributes["service"])
+	}
+}
+
+func TestBuild_executeUploadReferees_NoTimeline(t *testing.T) {
+	referee := referees.NewMockReferee(t)
+	referee.EXPECT().Execute(mock.Anything, mock.Anything, mock.Anything).Return(bytes.NewReader([]byte("metrics")), nil)
+	referee.EXPECT().ArtifactBaseName().Return("metrics_referee.json")
+	referee.EXPECT().ArtifactType().Return("metrics_referee")
+	referee.EXPECT().ArtifactFormat().Return("gzip")
+
+	build := &Build{
+		Runner: &RunnerConfig{
+			RunnerCredentials: RunnerCredentials{URL: "https://gitlab.example.com"},
+			UploadJobTimeline: true,
+		},
+		Job:       spec.Job{ID: 42, Token: "job-token"},
+		Referees:  []referees.Referee{referee},
+		startedAt: time.Now(),
+	}
+
+	uploaded := map[string]ArtifactsOptions{}
+	build.ArtifactUploader = func(_ JobCredentials, bodyProvider ContentProvider, options ArtifactsOptions) (UploadState, string, error) {
+		uploaded[options.Type] = options
+
+		body, err := bodyProvider.GetReader()
+		require.NoError(t, err)
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "metrics", string(data), "the results of the referee are uploaded unchanged")
+
+		return UploadSucceeded, "", nil
+	}
+
+	build.executeReferees(t.Context(), time.Now(), time.Now())
+	build.executeUploadReferees()
+
+	assert.Equal(t, map[string]ArtifactsOptions{
+		"metrics_referee": {BaseName: "metrics_referee.json", Type: "metrics_referee", Format: spec.ArtifactFormatGzip},
+	}, uploaded, "the timeline isn't uploaded with the results of the referees")
+}
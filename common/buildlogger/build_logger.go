@@ -215,6 +215,12 @@ func (l *Logger) wrap(w io.WriteCloser, streamID int, streamType StreamType) io.
 		w = timestamper.New(w, timestamper.StreamType(streamType), uint8(streamID), true)
 	}
 
+	return l.Mask(w)
+}
+
+// Mask returns a writer masking the secrets of the job, like the job log
+// does, in what's written to w.
+func (l *Logger) Mask(w io.WriteCloser) io.WriteCloser {
 	w = tokensanitizer.New(w, l.maskTokenPrefixes)
 	w = urlsanitizer.New(w)
 	w = masker.New(w, l.maskPhrases)
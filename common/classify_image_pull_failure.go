@@ -4,12 +4,15 @@ import (
 	"strings"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
 )
 
 // ClassifyImagePullFailure inspects an error or status message from an image
 // pull (Docker daemon, Kubernetes container waiting reason, etc.) and returns
 // the most specific failure reason possible.
 //
+//   - Pulls from all the mirrors of the registry, and from the registry,
+//     failed → RunnerExternalDependencyFailure
 //   - Network-level failures (DNS, TCP, TLS, I/O timeout, HTTP client
 //     timeout) → RunnerExternalDependencyFailure
 //   - Image or tag does not exist → ConfigurationError
@@ -21,6 +24,12 @@ func ClassifyImagePullFailure(msg string) spec.JobFailureReason {
 	lower := strings.ToLower(msg)
 
 	switch {
+	// The pull managers report a missing image as is when all the mirrors
+	// agree on it, so the mirrors failing means they were unavailable. It's
+	// matched first, as the message includes the errors of each mirror.
+	case strings.Contains(lower, registry_mirrors.AllFailedMessage):
+		return RunnerExternalDependencyFailure
+
 	case strings.Contains(lower, "dial tcp"),
 		strings.Contains(lower, "connection refused"),
 		strings.Contains(lower, "no such host"),
@@ -55,6 +55,10 @@ func TestClassifyImagePullFailure(t *testing.T) {
 			msg:            `Get "https://registry.example.com/v2/": write tcp 10.0.0.1:5000->1.2.3.4:443: write: connection reset by peer`,
 			expectedReason: RunnerExternalDependencyFailure,
 		},
+		"all registry mirrors failed": {
+			msg:            `all registry mirrors failed to pull image "alpine:3.20": mirror.example.com/library/alpine:3.20: manifest unknown; alpine:3.20: received unexpected HTTP status: 503 Service Unavailable`,
+			expectedReason: RunnerExternalDependencyFailure,
+		},
 		"manifest not found": {
 			msg:            `manifest for nginx:nonexistent not found: manifest unknown: manifest unknown`,
 			expectedReason: ConfigurationError,
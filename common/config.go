@@ -9,6 +9,7 @@ import (
 	"maps"
 	"math/big"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"slices"
@@ -30,6 +31,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
 	"gitlab.com/gitlab-org/gitlab-runner/common/config/runner"
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
@@ -282,79 +284,172 @@ func (p *StringOrArray) UnmarshalFlag(value string) error {
 
 type DockerConfig struct {
 	docker.Credentials
-	Hostname                   string              `toml:"hostname,omitempty" json:"hostname" long:"hostname" env:"DOCKER_HOSTNAME" description:"Custom container hostname"`
-	Image                      string              `toml:"image" json:"image" long:"image" env:"DOCKER_IMAGE" description:"Docker image to be used"`
-	Runtime                    string              `toml:"runtime,omitempty" json:"runtime" long:"runtime" env:"DOCKER_RUNTIME" description:"Docker runtime to be used"`
-	Memory                     string              `toml:"memory,omitempty" json:"memory" long:"memory" env:"DOCKER_MEMORY" description:"Memory limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g. Minimum is 4M."`
-	MemorySwap                 string              `toml:"memory_swap,omitempty" json:"memory_swap" long:"memory-swap" env:"DOCKER_MEMORY_SWAP" description:"Total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
-	MemoryReservation          string              `toml:"memory_reservation,omitempty" json:"memory_reservation" long:"memory-reservation" env:"DOCKER_MEMORY_RESERVATION" description:"Memory soft limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
-	CgroupParent               string              `toml:"cgroup_parent,omitempty" json:"cgroup_parent" long:"cgroup-parent" env:"DOCKER_CGROUP_PARENT" description:"String value containing the cgroup parent to use"`
-	CPUSetCPUs                 string              `toml:"cpuset_cpus,omitempty" json:"cpuset_cpus" long:"cpuset-cpus" env:"DOCKER_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use"`
-	CPUSetMems                 string              `toml:"cpuset_mems,omitempty" json:"cpuset_mems" long:"cpuset-mems" env:"DOCKER_CPUSET_MEMS" description:"String value containing the cgroups CpusetMems to use"`
-	CPUS                       string              `toml:"cpus,omitempty" json:"cpus" long:"cpus" env:"DOCKER_CPUS" description:"Number of CPUs"`
-	CPUShares                  int64               `toml:"cpu_shares,omitzero" json:"cpu_shares" long:"cpu-shares" env:"DOCKER_CPU_SHARES" description:"Number of CPU shares"`
-	DNS                        []string            `toml:"dns,omitempty" json:"dns,omitempty" long:"dns" env:"DOCKER_DNS" description:"A list of DNS servers for the container to use"`
-	DNSSearch                  []string            `toml:"dns_search,omitempty" json:"dns_search,omitempty" long:"dns-search" env:"DOCKER_DNS_SEARCH" description:"A list of DNS search domains"`
-	Privileged                 bool                `toml:"privileged,omitzero" json:"privileged" long:"privileged" env:"DOCKER_PRIVILEGED" description:"Give extended privileges to container"`
-	ServicesPrivileged         *bool               `toml:"services_privileged,omitempty" json:"services_privileged,omitempty" long:"services_privileged" env:"DOCKER_SERVICES_PRIVILEGED" description:"When set this will give or remove extended privileges to container services"`
-	DisableEntrypointOverwrite bool                `toml:"disable_entrypoint_overwrite,omitzero" json:"disable_entrypoint_overwrite" long:"disable-entrypoint-overwrite" env:"DOCKER_DISABLE_ENTRYPOINT_OVERWRITE" description:"Disable the possibility for a container to overwrite the default image entrypoint"`
-	User                       string              `toml:"user,omitempty" json:"user" long:"user" env:"DOCKER_USER" description:"Run all commands in the container as the specified user."`
-	AllowedUsers               []string            `toml:"allowed_users,omitempty" json:"allowed_users,omitempty" long:"allowed_users" env:"DOCKER_ALLOWED_USERS" description:"List of allowed users under which to run commands in the build container."`
-	GroupAdd                   []string            `toml:"group_add" json:"group_add,omitempty" long:"group-add" env:"DOCKER_GROUP_ADD" description:"Add additional groups to join"`
-	UsernsMode                 string              `toml:"userns_mode,omitempty" json:"userns_mode" long:"userns" env:"DOCKER_USERNS_MODE" description:"User namespace to use"`
-	CapAdd                     []string            `toml:"cap_add" json:"cap_add,omitempty" long:"cap-add" env:"DOCKER_CAP_ADD" description:"Add Linux capabilities"`
-	CapDrop                    []string            `toml:"cap_drop" json:"cap_drop,omitempty" long:"cap-drop" env:"DOCKER_CAP_DROP" description:"Drop Linux capabilities"`
-	OomKillDisable             bool                `toml:"oom_kill_disable,omitzero" json:"oom_kill_disable" long:"oom-kill-disable" env:"DOCKER_OOM_KILL_DISABLE" description:"Do not kill processes in a container if an out-of-memory (OOM) error occurs"`
-	OomScoreAdjust             int                 `toml:"oom_score_adjust,omitzero" json:"oom_score_adjust" long:"oom-score-adjust" env:"DOCKER_OOM_SCORE_ADJUST" description:"Adjust OOM score"`
-	SecurityOpt                []string            `toml:"security_opt" json:"security_opt,omitempty" long:"security-opt" env:"DOCKER_SECURITY_OPT" description:"Security Options"`
-	ServicesSecurityOpt        []string            `toml:"services_security_opt" json:"services_security_opt,omitempty" long:"services-security-opt" env:"DOCKER_SERVICES_SECURITY_OPT" description:"Security Options for container services"`
-	Devices                    []string            `toml:"devices" json:"devices,omitempty" long:"devices" env:"DOCKER_DEVICES" description:"Add a host device to the container"`
-	DeviceCgroupRules          []string            `toml:"device_cgroup_rules,omitempty" json:"device_cgroup_rules,omitempty" long:"device-cgroup-rules" env:"DOCKER_DEVICE_CGROUP_RULES" description:"Add a device cgroup rule to the container"`
-	Gpus                       string              `toml:"gpus,omitempty" json:"gpus" long:"gpus" env:"DOCKER_GPUS" description:"Request GPUs to be used by Docker"`
-	ServicesDevices            map[string][]string `toml:"services_devices,omitempty" json:"services_devices,omitempty" long:"services_devices" env:"DOCKER_SERVICES_DEVICES" description:"A toml table/json object with the format key=values. Expose host devices to services based on image name."`
-	DisableCache               bool                `toml:"disable_cache,omitzero" json:"disable_cache" long:"disable-cache" env:"DOCKER_DISABLE_CACHE" description:"Disable all container caching"`
-	Volumes                    []string            `toml:"volumes,omitempty" json:"volumes,omitempty" long:"volumes" env:"DOCKER_VOLUMES" description:"Bind-mount a volume and create it if it doesn't exist prior to mounting. Can be specified multiple times once per mountpoint, e.g. --docker-volumes 'test0:/test0' --docker-volumes 'test1:/test1'"`
-	VolumeKeep                 bool                `toml:"volume_keep,omitzero" json:"volume_keep" long:"volume-keep" env:"DOCKER_VOLUME_KEEP" description:"Do not delete volumes on container removal. Enabling can lead to increase in storage"`
-	VolumeDriver               string              `toml:"volume_driver,omitempty" json:"volume_driver" long:"volume-driver" env:"DOCKER_VOLUME_DRIVER" description:"Volume driver to be used"`
-	VolumeDriverOps            map[string]string   `toml:"volume_driver_ops,omitempty" json:"volume_driver_ops,omitempty" long:"volume-driver-ops" env:"DOCKER_VOLUME_DRIVER_OPS" description:"A toml table/json object with the format key=values. Volume driver ops to be specified"`
-	CacheDir                   string              `toml:"cache_dir,omitempty" json:"cache_dir" long:"cache-dir" env:"DOCKER_CACHE_DIR" description:"Directory where to store caches"`
-	ExtraHosts                 []string            `toml:"extra_hosts,omitempty" json:"extra_hosts,omitempty" long:"extra-hosts" env:"DOCKER_EXTRA_HOSTS" description:"Add a custom host-to-IP mapping"`
-	VolumesFrom                []string            `toml:"volumes_from,omitempty" json:"volumes_from,omitempty" long:"volumes-from" env:"DOCKER_VOLUMES_FROM" description:"A list of volumes to inherit from another container"`
-	NetworkMode                string              `toml:"network_mode,omitempty" json:"network_mode" long:"network-mode" env:"DOCKER_NETWORK_MODE" description:"Add container to a custom network"`
-	PidMode                    string              `toml:"pid_mode,omitempty" json:"pid_mode" long:"pid-mode" env:"DOCKER_PID_MODE" description:"Run the container in a specific PID space"`
-	IpcMode                    string              `toml:"ipcmode,omitempty" json:"ipcmode" long:"ipcmode" env:"DOCKER_IPC_MODE" description:"Select IPC mode for container"`
-	MacAddress                 string              `toml:"mac_address,omitempty" json:"mac_address" long:"mac-address" env:"DOCKER_MAC_ADDRESS" description:"Container MAC address (e.g., 92:d0:c6:0a:29:33)"`
-	Links                      []string            `toml:"links,omitempty" json:"links,omitempty" long:"links" env:"DOCKER_LINKS" description:"Add link to another container"`
-	Services                   []Service           `toml:"services,omitempty" json:"services,omitempty" description:"Add service that is started with container"`
-	ServicesLimit              *int                `toml:"services_limit,omitempty" json:"services_limit,omitempty" long:"services-limit" env:"DOCKER_SERVICES_LIMIT" description:"The maximum amount of services allowed"`
-	ServiceMemory              string              `toml:"service_memory,omitempty" json:"service_memory" long:"service-memory" env:"DOCKER_SERVICE_MEMORY" description:"Service memory limit (format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g. Minimum is 4M."`
-	ServiceMemorySwap          string              `toml:"service_memory_swap,omitempty" json:"service_memory_swap" long:"service-memory-swap" env:"DOCKER_SERVICE_MEMORY_SWAP" description:"Service total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g."`
-	ServiceMemoryReservation   string              `toml:"service_memory_reservation,omitempty" json:"service_memory_reservation" long:"service-memory-reservation" env:"DOCKER_SERVICE_MEMORY_RESERVATION" description:"Service memory soft limit (format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g."`
-	ServiceCgroupParent        string              `toml:"service_cgroup_parent,omitempty" json:"service_cgroup_parent" long:"service-cgroup-parent" env:"DOCKER_SERVICE_CGROUP_PARENT" description:"String value containing the cgroup parent to use for service"`
-	ServiceSlotCgroupTemplate  string              `toml:"service_slot_cgroup_template,omitempty" json:"service_slot_cgroup_template" long:"service-slot-cgroup-template" env:"DOCKER_SERVICE_SLOT_CGROUP_TEMPLATE" description:"Template for service slot-derived cgroup names (use ${slot} placeholder)"`
-	ServiceCPUSetCPUs          string              `toml:"service_cpuset_cpus,omitempty" json:"service_cpuset_cpus" long:"service-cpuset-cpus" env:"DOCKER_SERVICE_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use for service"`
-	ServiceCPUS                string              `toml:"service_cpus,omitempty" json:"service_cpus" long:"service-cpus" env:"DOCKER_SERVICE_CPUS" description:"Number of CPUs for service"`
-	ServiceCPUShares           int64               `toml:"service_cpu_shares,omitzero" json:"service_cpu_shares" long:"service-cpu-shares" env:"DOCKER_SERVICE_CPU_SHARES" description:"Number of CPU shares for service"`
-	ServiceGpus                string              `toml:"service_gpus,omitempty" json:"service_gpus" long:"service_gpus" env:"DOCKER_SERVICE_GPUS" description:"Request GPUs to be used by Docker for services"`
-	WaitForServicesTimeout     int                 `toml:"wait_for_services_timeout,omitzero" json:"wait_for_services_timeout" long:"wait-for-services-timeout" env:"DOCKER_WAIT_FOR_SERVICES_TIMEOUT" description:"How long to wait for service startup"`
-	AllowedImages              []string            `toml:"allowed_images,omitempty" json:"allowed_images,omitempty" long:"allowed-images" env:"DOCKER_ALLOWED_IMAGES" description:"Image allowlist"`
-	AllowedPrivilegedImages    []string            `toml:"allowed_privileged_images,omitempty" json:"allowed_privileged_images,omitempty" long:"allowed-privileged-images" env:"DOCKER_ALLOWED_PRIVILEGED_IMAGES" description:"Privileged image allowlist"`
-	AllowedPrivilegedServices  []string            `toml:"allowed_privileged_services,omitempty" json:"allowed_privileged_services,omitempty" long:"allowed-privileged-services" env:"DOCKER_ALLOWED_PRIVILEGED_SERVICES" description:"Privileged Service allowlist"`
-	AllowedPullPolicies        []DockerPullPolicy  `toml:"allowed_pull_policies,omitempty" json:"allowed_pull_policies,omitempty" long:"allowed-pull-policies" env:"DOCKER_ALLOWED_PULL_POLICIES" description:"Pull policy allowlist"`
-	AllowedServices            []string            `toml:"allowed_services,omitempty" json:"allowed_services,omitempty" long:"allowed-services" env:"DOCKER_ALLOWED_SERVICES" description:"Service allowlist"`
-	PullPolicy                 StringOrArray       `toml:"pull_policy,omitempty" json:"pull_policy,omitempty" long:"pull-policy" env:"DOCKER_PULL_POLICY" description:"Image pull policy: never, if-not-present, always"`
-	Isolation                  string              `toml:"isolation,omitempty" json:"isolation" long:"isolation" env:"DOCKER_ISOLATION" description:"Container isolation technology. Windows only"`
-	ShmSize                    int64               `toml:"shm_size,omitempty" json:"shm_size" long:"shm-size" env:"DOCKER_SHM_SIZE" description:"Shared memory size for docker images (in bytes)"`
-	Tmpfs                      map[string]string   `toml:"tmpfs,omitempty" json:"tmpfs,omitempty" long:"tmpfs" env:"DOCKER_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in the main container, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
-	ServicesTmpfs              map[string]string   `toml:"services_tmpfs,omitempty" json:"services_tmpfs,omitempty" long:"services-tmpfs" env:"DOCKER_SERVICES_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in all the service containers, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
-	SysCtls                    DockerSysCtls       `toml:"sysctls,omitempty" json:"sysctls,omitempty" long:"sysctls" env:"DOCKER_SYSCTLS" description:"Sysctl options, a toml table/json object of key=value. Value is expected to be a string."`
-	HelperImage                string              `toml:"helper_image,omitempty" json:"helper_image" long:"helper-image" env:"DOCKER_HELPER_IMAGE" description:"[ADVANCED] Override the default helper image used to clone repos and upload artifacts"`
-	HelperImageFlavor          string              `toml:"helper_image_flavor,omitempty" json:"helper_image_flavor" long:"helper-image-flavor" env:"DOCKER_HELPER_IMAGE_FLAVOR" description:"Set helper image flavor (alpine, ubuntu), defaults to alpine"`
-	ContainerLabels            map[string]string   `toml:"container_labels,omitempty" json:"container_labels,omitempty" long:"container-labels" description:"A toml table/json object of key-value. Value is expected to be a string. When set, this will create containers with the given container labels. Environment variables will be substituted for values here."`
-	EnableIPv6                 bool                `toml:"enable_ipv6,omitempty" json:"enable_ipv6" long:"enable-ipv6" description:"Enable IPv6 for automatically created networks. This is only takes affect when the feature flag FF_NETWORK_PER_BUILD is enabled."`
-	Ulimit                     map[string]string   `toml:"ulimit,omitempty" json:"ulimit,omitempty" long:"ulimit" env:"DOCKER_ULIMIT" description:"Ulimit options for container"`
-	NetworkMTU                 int                 `toml:"network_mtu,omitempty" json:"network_mtu" long:"network-mtu" description:"MTU of the Docker network created for the job IFF the FF_NETWORK_PER_BUILD feature-flag was specified."`
-	LogOptions                 map[string]string   `toml:"log_options,omitempty" json:"log_options,omitempty" long:"log-options" env:"DOCKER_LOG_OPTIONS" description:"Log driver options for json-file logging"`
+	Hostname                   string                     `toml:"hostname,omitempty" json:"hostname" long:"hostname" env:"DOCKER_HOSTNAME" description:"Custom container hostname"`
+	Image                      string                     `toml:"image" json:"image" long:"image" env:"DOCKER_IMAGE" description:"Docker image to be used"`
+	Runtime                    string                     `toml:"runtime,omitempty" json:"runtime" long:"runtime" env:"DOCKER_RUNTIME" description:"Docker runtime to be used"`
+	Memory                     string                     `toml:"memory,omitempty" json:"memory" long:"memory" env:"DOCKER_MEMORY" description:"Memory limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g. Minimum is 4M."`
+	MemorySwap                 string                     `toml:"memory_swap,omitempty" json:"memory_swap" long:"memory-swap" env:"DOCKER_MEMORY_SWAP" description:"Total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
+	MemoryReservation          string                     `toml:"memory_reservation,omitempty" json:"memory_reservation" long:"memory-reservation" env:"DOCKER_MEMORY_RESERVATION" description:"Memory soft limit (format: <number>[<unit>]). Unit can be one of b, k, m, or g."`
+	CgroupParent               string                     `toml:"cgroup_parent,omitempty" json:"cgroup_parent" long:"cgroup-parent" env:"DOCKER_CGROUP_PARENT" description:"String value containing the cgroup parent to use"`
+	CPUSetCPUs                 string                     `toml:"cpuset_cpus,omitempty" json:"cpuset_cpus" long:"cpuset-cpus" env:"DOCKER_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use"`
+	CPUSetMems                 string                     `toml:"cpuset_mems,omitempty" json:"cpuset_mems" long:"cpuset-mems" env:"DOCKER_CPUSET_MEMS" description:"String value containing the cgroups CpusetMems to use"`
+	CPUS                       string                     `toml:"cpus,omitempty" json:"cpus" long:"cpus" env:"DOCKER_CPUS" description:"Number of CPUs"`
+	CPUShares                  int64                      `toml:"cpu_shares,omitzero" json:"cpu_shares" long:"cpu-shares" env:"DOCKER_CPU_SHARES" description:"Number of CPU shares"`
+	DNS                        []string                   `toml:"dns,omitempty" json:"dns,omitempty" long:"dns" env:"DOCKER_DNS" description:"A list of DNS servers for the container to use"`
+	DNSSearch                  []string                   `toml:"dns_search,omitempty" json:"dns_search,omitempty" long:"dns-search" env:"DOCKER_DNS_SEARCH" description:"A list of DNS search domains"`
+	Privileged                 bool                       `toml:"privileged,omitzero" json:"privileged" long:"privileged" env:"DOCKER_PRIVILEGED" description:"Give extended privileges to container"`
+	ServicesPrivileged         *bool                      `toml:"services_privileged,omitempty" json:"services_privileged,omitempty" long:"services_privileged" env:"DOCKER_SERVICES_PRIVILEGED" description:"When set this will give or remove extended privileges to container services"`
+	DisableEntrypointOverwrite bool                       `toml:"disable_entrypoint_overwrite,omitzero" json:"disable_entrypoint_overwrite" long:"disable-entrypoint-overwrite" env:"DOCKER_DISABLE_ENTRYPOINT_OVERWRITE" description:"Disable the possibility for a container to overwrite the default image entrypoint"`
+	User                       string                     `toml:"user,omitempty" json:"user" long:"user" env:"DOCKER_USER" description:"Run all commands in the container as the specified user."`
+	AllowedUsers               []string                   `toml:"allowed_users,omitempty" json:"allowed_users,omitempty" long:"allowed_users" env:"DOCKER_ALLOWED_USERS" description:"List of allowed users under which to run commands in the build container."`
+	GroupAdd                   []string                   `toml:"group_add" json:"group_add,omitempty" long:"group-add" env:"DOCKER_GROUP_ADD" description:"Add additional groups to join"`
+	UsernsMode                 string                     `toml:"userns_mode,omitempty" json:"userns_mode" long:"userns" env:"DOCKER_USERNS_MODE" description:"User namespace to use"`
+	CapAdd                     []string                   `toml:"cap_add" json:"cap_add,omitempty" long:"cap-add" env:"DOCKER_CAP_ADD" description:"Add Linux capabilities"`
+	CapDrop                    []string                   `toml:"cap_drop" json:"cap_drop,omitempty" long:"cap-drop" env:"DOCKER_CAP_DROP" description:"Drop Linux capabilities"`
+	OomKillDisable             bool                       `toml:"oom_kill_disable,omitzero" json:"oom_kill_disable" long:"oom-kill-disable" env:"DOCKER_OOM_KILL_DISABLE" description:"Do not kill processes in a container if an out-of-memory (OOM) error occurs"`
+	OomScoreAdjust             int                        `toml:"oom_score_adjust,omitzero" json:"oom_score_adjust" long:"oom-score-adjust" env:"DOCKER_OOM_SCORE_ADJUST" description:"Adjust OOM score"`
+	SecurityOpt                []string                   `toml:"security_opt" json:"security_opt,omitempty" long:"security-opt" env:"DOCKER_SECURITY_OPT" description:"Security Options"`
+	ServicesSecurityOpt        []string                   `toml:"services_security_opt" json:"services_security_opt,omitempty" long:"services-security-opt" env:"DOCKER_SERVICES_SECURITY_OPT" description:"Security Options for container services"`
+	Devices                    []string                   `toml:"devices" json:"devices,omitempty" long:"devices" env:"DOCKER_DEVICES" description:"Add a host device to the container"`
+	DeviceCgroupRules          []string                   `toml:"device_cgroup_rules,omitempty" json:"device_cgroup_rules,omitempty" long:"device-cgroup-rules" env:"DOCKER_DEVICE_CGROUP_RULES" description:"Add a device cgroup rule to the container"`
+	Gpus                       string                     `toml:"gpus,omitempty" json:"gpus" long:"gpus" env:"DOCKER_GPUS" description:"Request GPUs to be used by Docker"`
+	ServicesDevices            map[string][]string        `toml:"services_devices,omitempty" json:"services_devices,omitempty" long:"services_devices" env:"DOCKER_SERVICES_DEVICES" description:"A toml table/json object with the format key=values. Expose host devices to services based on image name."`
+	DisableCache               bool                       `toml:"disable_cache,omitzero" json:"disable_cache" long:"disable-cache" env:"DOCKER_DISABLE_CACHE" description:"Disable all container caching"`
+	Volumes                    []string                   `toml:"volumes,omitempty" json:"volumes,omitempty" long:"volumes" env:"DOCKER_VOLUMES" description:"Bind-mount a volume and create it if it doesn't exist prior to mounting. Can be specified multiple times once per mountpoint, e.g. --docker-volumes 'test0:/test0' --docker-volumes 'test1:/test1'"`
+	VolumeKeep                 bool                       `toml:"volume_keep,omitzero" json:"volume_keep" long:"volume-keep" env:"DOCKER_VOLUME_KEEP" description:"Do not delete volumes on container removal. Enabling can lead to increase in storage"`
+	VolumeDriver               string                     `toml:"volume_driver,omitempty" json:"volume_driver" long:"volume-driver" env:"DOCKER_VOLUME_DRIVER" description:"Volume driver to be used"`
+	VolumeDriverOps            map[string]string          `toml:"volume_driver_ops,omitempty" json:"volume_driver_ops,omitempty" long:"volume-driver-ops" env:"DOCKER_VOLUME_DRIVER_OPS" description:"A toml table/json object with the format key=values. Volume driver ops to be specified"`
+	CacheDir                   string                     `toml:"cache_dir,omitempty" json:"cache_dir" long:"cache-dir" env:"DOCKER_CACHE_DIR" description:"Directory where to store caches"`
+	ExtraHosts                 []string                   `toml:"extra_hosts,omitempty" json:"extra_hosts,omitempty" long:"extra-hosts" env:"DOCKER_EXTRA_HOSTS" description:"Add a custom host-to-IP mapping"`
+	VolumesFrom                []string                   `toml:"volumes_from,omitempty" json:"volumes_from,omitempty" long:"volumes-from" env:"DOCKER_VOLUMES_FROM" description:"A list of volumes to inherit from another container"`
+	NetworkMode                string                     `toml:"network_mode,omitempty" json:"network_mode" long:"network-mode" env:"DOCKER_NETWORK_MODE" description:"Add container to a custom network"`
+	PidMode                    string                     `toml:"pid_mode,omitempty" json:"pid_mode" long:"pid-mode" env:"DOCKER_PID_MODE" description:"Run the container in a specific PID space"`
+	IpcMode                    string                     `toml:"ipcmode,omitempty" json:"ipcmode" long:"ipcmode" env:"DOCKER_IPC_MODE" description:"Select IPC mode for container"`
+	MacAddress                 string                     `toml:"mac_address,omitempty" json:"mac_address" long:"mac-address" env:"DOCKER_MAC_ADDRESS" description:"Container MAC address (e.g., 92:d0:c6:0a:29:33)"`
+	Links                      []string                   `toml:"links,omitempty" json:"links,omitempty" long:"links" env:"DOCKER_LINKS" description:"Add link to another container"`
+	Services                   []Service                  `toml:"services,omitempty" json:"services,omitempty" description:"Add service that is started with container"`
+	ServicesLimit              *int                       `toml:"services_limit,omitempty" json:"services_limit,omitempty" long:"services-limit" env:"DOCKER_SERVICES_LIMIT" description:"The maximum amount of services allowed"`
+	ServiceMemory              string                     `toml:"service_memory,omitempty" json:"service_memory" long:"service-memory" env:"DOCKER_SERVICE_MEMORY" description:"Service memory limit (format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g. Minimum is 4M."`
+	ServiceMemorySwap          string                     `toml:"service_memory_swap,omitempty" json:"service_memory_swap" long:"service-memory-swap" env:"DOCKER_SERVICE_MEMORY_SWAP" description:"Service total memory limit (memory + swap, format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g."`
+	ServiceMemoryReservation   string                     `toml:"service_memory_reservation,omitempty" json:"service_memory_reservation" long:"service-memory-reservation" env:"DOCKER_SERVICE_MEMORY_RESERVATION" description:"Service memory soft limit (format: <number>[<unit>]). Unit can be one of b (if omitted), k, m, or g."`
+	ServiceCgroupParent        string                     `toml:"service_cgroup_parent,omitempty" json:"service_cgroup_parent" long:"service-cgroup-parent" env:"DOCKER_SERVICE_CGROUP_PARENT" description:"String value containing the cgroup parent to use for service"`
+	ServiceSlotCgroupTemplate  string                     `toml:"service_slot_cgroup_template,omitempty" json:"service_slot_cgroup_template" long:"service-slot-cgroup-template" env:"DOCKER_SERVICE_SLOT_CGROUP_TEMPLATE" description:"Template for service slot-derived cgroup names (use ${slot} placeholder)"`
+	ServiceCPUSetCPUs          string                     `toml:"service_cpuset_cpus,omitempty" json:"service_cpuset_cpus" long:"service-cpuset-cpus" env:"DOCKER_SERVICE_CPUSET_CPUS" description:"String value containing the cgroups CpusetCpus to use for service"`
+	ServiceCPUS                string                     `toml:"service_cpus,omitempty" json:"service_cpus" long:"service-cpus" env:"DOCKER_SERVICE_CPUS" description:"Number of CPUs for service"`
+	ServiceCPUShares           int64                      `toml:"service_cpu_shares,omitzero" json:"service_cpu_shares" long:"service-cpu-shares" env:"DOCKER_SERVICE_CPU_SHARES" description:"Number of CPU shares for service"`
+	ServiceGpus                string                     `toml:"service_gpus,omitempty" json:"service_gpus" long:"service_gpus" env:"DOCKER_SERVICE_GPUS" description:"Request GPUs to be used by Docker for services"`
+	WaitForServicesTimeout     int                        `toml:"wait_for_services_timeout,omitzero" json:"wait_for_services_timeout" long:"wait-for-services-timeout" env:"DOCKER_WAIT_FOR_SERVICES_TIMEOUT" description:"How long to wait for service startup"`
+	AllowedImages              []string                   `toml:"allowed_images,omitempty" json:"allowed_images,omitempty" long:"allowed-images" env:"DOCKER_ALLOWED_IMAGES" description:"Image allowlist"`
+	AllowedPrivilegedImages    []string                   `toml:"allowed_privileged_images,omitempty" json:"allowed_privileged_images,omitempty" long:"allowed-privileged-images" env:"DOCKER_ALLOWED_PRIVILEGED_IMAGES" description:"Privileged image allowlist"`
+	AllowedPrivilegedServices  []string                   `toml:"allowed_privileged_services,omitempty" json:"allowed_privileged_services,omitempty" long:"allowed-privileged-services" env:"DOCKER_ALLOWED_PRIVILEGED_SERVICES" description:"Privileged Service allowlist"`
+	AllowedPullPolicies        []DockerPullPolicy         `toml:"allowed_pull_policies,omitempty" json:"allowed_pull_policies,omitempty" long:"allowed-pull-policies" env:"DOCKER_ALLOWED_PULL_POLICIES" description:"Pull policy allowlist"`
+	AllowedServices            []string                   `toml:"allowed_services,omitempty" json:"allowed_services,omitempty" long:"allowed-services" env:"DOCKER_ALLOWED_SERVICES" description:"Service allowlist"`
+	PullPolicy                 StringOrArray              `toml:"pull_policy,omitempty" json:"pull_policy,omitempty" long:"pull-policy" env:"DOCKER_PULL_POLICY" description:"Image pull policy: never, if-not-present, always"`
+	Isolation                  string                     `toml:"isolation,omitempty" json:"isolation" long:"isolation" env:"DOCKER_ISOLATION" description:"Container isolation technology. Windows only"`
+	ShmSize                    int64                      `toml:"shm_size,omitempty" json:"shm_size" long:"shm-size" env:"DOCKER_SHM_SIZE" description:"Shared memory size for docker images (in bytes)"`
+	Tmpfs                      map[string]string          `toml:"tmpfs,omitempty" json:"tmpfs,omitempty" long:"tmpfs" env:"DOCKER_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in the main container, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
+	ServicesTmpfs              map[string]string          `toml:"services_tmpfs,omitempty" json:"services_tmpfs,omitempty" long:"services-tmpfs" env:"DOCKER_SERVICES_TMPFS" description:"A toml table/json object with the format key=values. When set this will mount the specified path in the key as a tmpfs volume in all the service containers, using the options specified as key. For the supported options, see the documentation for the unix 'mount' command"`
+	SysCtls                    DockerSysCtls              `toml:"sysctls,omitempty" json:"sysctls,omitempty" long:"sysctls" env:"DOCKER_SYSCTLS" description:"Sysctl options, a toml table/json object of key=value. Value is expected to be a string."`
+	HelperImage                string                     `toml:"helper_image,omitempty" json:"helper_image" long:"helper-image" env:"DOCKER_HELPER_IMAGE" description:"[ADVANCED] Override the default helper image used to clone repos and upload artifacts"`
+	HelperImageFlavor          string                     `toml:"helper_image_flavor,omitempty" json:"helper_image_flavor" long:"helper-image-flavor" env:"DOCKER_HELPER_IMAGE_FLAVOR" description:"Set helper image flavor (alpine, ubuntu), defaults to alpine"`
+	ContainerLabels            map[string]string          `toml:"container_labels,omitempty" json:"container_labels,omitempty" long:"container-labels" description:"A toml table/json object of key-value. Value is expected to be a string. When set, this will create containers with the given container labels. Environment variables will be substituted for values here."`
+	EnableIPv6                 bool                       `toml:"enable_ipv6,omitempty" json:"enable_ipv6" long:"enable-ipv6" description:"Enable IPv6 for automatically created networks. This is only takes affect when the feature flag FF_NETWORK_PER_BUILD is enabled."`
+	Ulimit                     map[string]string          `toml:"ulimit,omitempty" json:"ulimit,omitempty" long:"ulimit" env:"DOCKER_ULIMIT" description:"Ulimit options for container"`
+	NetworkMTU                 int                        `toml:"network_mtu,omitempty" json:"network_mtu" long:"network-mtu" description:"MTU of the Docker network created for the job IFF the FF_NETWORK_PER_BUILD feature-flag was specified."`
+	LogOptions                 map[string]string          `toml:"log_options,omitempty" json:"log_options,omitempty" long:"log-options" env:"DOCKER_LOG_OPTIONS" description:"Log driver options for json-file logging"`
+	ImageVerification          *ImageVerificationConfig   `toml:"image_verification,omitempty" json:"image_verification,omitempty" description:"Policy verifying the signatures and attestations of the job, service and helper images before their containers start"`
+	NetworkEgress              *DockerNetworkEgressConfig `toml:"network_egress,omitempty" json:"network_egress,omitempty" description:"Destinations the build and service containers can connect to, enforced with an internal build network and a filtering proxy. Requires FF_NETWORK_PER_BUILD"`
+	WarmPool                   *DockerWarmPoolConfig      `toml:"warm_pool,omitempty" json:"warm_pool,omitempty" description:"Pre-created build and helper containers handed to the jobs of the pooled images, to shorten the job startup. The containers are created without the job variables and labels, so jobs running an image entrypoint don't use them"`
+	RegistryMirrors            map[string][]string        `toml:"registry_mirrors,omitempty" json:"registry_mirrors,omitempty" description:"A toml table/json object mapping registry hosts to the ordered list of mirrors the images of the registry are pulled from. The registry itself is tried after its mirrors, unless it's in the list"`
+}
+
+const (
+	DefaultDockerWarmPoolSize        = 1
+	DefaultDockerWarmPoolIdleTimeout = 30 * time.Minute
+)
+
+// DockerWarmPoolConfig is the pool of pre-created, not started, build and
+// helper containers of the jobs.
+type DockerWarmPoolConfig struct {
+	Images      []string      `toml:"images,omitempty" json:"images,omitempty" description:"Wildcard list of the job images to keep pre-created containers for, like in allowed_images"`
+	Size        int           `toml:"size,omitempty" json:"size,omitempty" description:"Number of pre-created container sets kept for each image and configuration. Default is 1"`
+	IdleTimeout time.Duration `toml:"idle_timeout,omitempty" json:"idle_timeout,omitempty" description:"Time after which the containers of an image and configuration no job has used are removed. Default is 30m"`
+}
+
+func (c *DockerWarmPoolConfig) GetSize() int {
+	if c.Size <= 0 {
+		return DefaultDockerWarmPoolSize
+	}
+
+	return c.Size
+}
+
+func (c *DockerWarmPoolConfig) GetIdleTimeout() time.Duration {
+	if c.IdleTimeout <= 0 {
+		return DefaultDockerWarmPoolIdleTimeout
+	}
+
+	return c.IdleTimeout
+}
+
+// DockerNetworkEgressConfig is the egress allowlist of the build and service
+// containers of the jobs.
+type DockerNetworkEgressConfig struct {
+	Allow            []string `toml:"allow,omitempty" json:"allow,omitempty" description:"Allowed destinations: hostnames, which can start with a *. wildcard, IP addresses or CIDRs, with an optional :port or :port-range"`
+	AllowedOverrides []string `toml:"allowed_overrides,omitempty" json:"allowed_overrides,omitempty" description:"Wildcard list of the destinations that jobs can add with the DOCKER_NETWORK_EGRESS_ALLOW variable, like in allowed_images. Jobs can't add destinations when empty"`
+}
+
+type ImageVerificationMode string
+
+const (
+	// ImageVerificationModeEnforce fails the job when an image doesn't
+	// satisfy its verification rule.
+	ImageVerificationModeEnforce ImageVerificationMode = "enforce"
+	// ImageVerificationModeWarn only logs a warning when an image doesn't
+	// satisfy its verification rule.
+	ImageVerificationModeWarn ImageVerificationMode = "warn"
+)
+
+// ImageVerificationConfig is the policy verifying the cosign signatures and
+// attestations of the images stored in their registry.
+type ImageVerificationConfig struct {
+	Mode            ImageVerificationMode   `toml:"mode,omitempty" json:"mode,omitempty" description:"Default verification mode of the rules: enforce (default) or warn"`
+	FulcioRoots     []string                `toml:"fulcio_roots,omitempty" json:"fulcio_roots,omitempty" description:"Paths to, or PEM contents of, the CA certificates issuing the signing certificates of the keyless signatures"`
+	RekorPublicKeys []string                `toml:"rekor_public_keys,omitempty" json:"rekor_public_keys,omitempty" description:"Paths to, or PEM contents of, the public keys of the transparency logs that keyless signatures are recorded in"`
+	RekorURL        string                  `toml:"rekor_url,omitempty" json:"rekor_url,omitempty" description:"URL of the transparency log to look up the inclusion proofs of keyless signatures in. Without it, only the signed entry timestamps of the signature bundles are verified"`
+	Rules           []ImageVerificationRule `toml:"rules,omitempty" json:"rules,omitempty" description:"Verification rules; the first rule matching an image applies, and images matching no rule aren't verified"`
+}
+
+// ImageVerificationRule is the verification rule of the images matching one
+// of its globs. An image satisfies the rule when it has a signature from one
+// of the keys or identities of the rule, and an attestation of each of the
+// required predicate types.
+type ImageVerificationRule struct {
+	Images       []string                    `toml:"images" json:"images" description:"Globs of the images the rule applies to, like in allowed_images"`
+	Mode         ImageVerificationMode       `toml:"mode,omitempty" json:"mode,omitempty" description:"Verification mode of the rule: enforce or warn. Defaults to the mode of the policy"`
+	PublicKeys   []string                    `toml:"public_keys,omitempty" json:"public_keys,omitempty" description:"Paths to, or PEM contents of, the trusted public keys"`
+	Identities   []ImageVerificationIdentity `toml:"identities,omitempty" json:"identities,omitempty" description:"Trusted certificate identities of keyless signatures"`
+	Attestations []string                    `toml:"attestations,omitempty" json:"attestations,omitempty" description:"Predicate types of the attestations the image must have, like https://slsa.dev/provenance/v1"`
+}
+
+// ImageVerificationIdentity is the identity of a signing certificate: the
+// OIDC issuer that authenticated the signer, and the signer itself.
+type ImageVerificationIdentity struct {
+	Issuer        string `toml:"issuer" json:"issuer" description:"OIDC issuer of the signing certificate"`
+	Subject       string `toml:"subject,omitempty" json:"subject,omitempty" description:"Subject of the signing certificate: an email address or URI"`
+	SubjectRegexp string `toml:"subject_regexp,omitempty" json:"subject_regexp,omitempty" description:"Regular expression matching the subject of the signing certificate"`
+}
+
+// GetMode returns the verification mode of the rule.
+func (r ImageVerificationRule) GetMode(c *ImageVerificationConfig) ImageVerificationMode {
+	if r.Mode != "" {
+		return r.Mode
+	}
+	if c.Mode != "" {
+		return c.Mode
+	}
+
+	return ImageVerificationModeEnforce
 }
 
 type InstanceConfig struct {
@@ -391,6 +486,8 @@ type AutoscalerConfig struct {
 
 	StateStorage AutoscalerStateStorage `toml:"state_storage,omitempty"`
 
+	Predictive *AutoscalerPredictiveConfig `toml:"predictive,omitempty" json:",omitempty"`
+
 	// instance_operation_time_buckets was introduced some time ago, so we can't just delete it.
 	// Someone can already depend on that setting.
 	// Instead, it's now used as a way to define "default" buckets for the different operation
@@ -490,6 +587,46 @@ func (policy *AutoscalerPolicyConfig) PreemptiveModeEnabled() bool {
 	return *policy.PreemptiveMode
 }
 
+const (
+	DefaultAutoscalerPredictiveInterval     = 15 * time.Minute
+	DefaultAutoscalerPredictiveHistoryWeeks = 4
+)
+
+// AutoscalerPredictiveConfig configures the idle count of the autoscaler from
+// the concurrent jobs forecast from the history of the runner.
+type AutoscalerPredictiveConfig struct {
+	MinIdleCount int           `toml:"min_idle_count,omitempty" json:",omitempty"`
+	MaxIdleCount int           `toml:"max_idle_count,omitempty" json:",omitempty"`
+	Interval     time.Duration `toml:"interval,omitempty" json:",omitempty"`
+	HistoryWeeks int           `toml:"history_weeks,omitempty" json:",omitempty"`
+}
+
+func (c *AutoscalerPredictiveConfig) GetInterval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultAutoscalerPredictiveInterval
+	}
+
+	return c.Interval
+}
+
+func (c *AutoscalerPredictiveConfig) GetHistoryWeeks() int {
+	if c.HistoryWeeks <= 0 {
+		return DefaultAutoscalerPredictiveHistoryWeeks
+	}
+
+	return c.HistoryWeeks
+}
+
+// ClampIdleCount bounds the idle count to the minimum and maximum idle
+// counts. A maximum of 0 doesn't bound the idle count.
+func (c *AutoscalerPredictiveConfig) ClampIdleCount(idleCount int) int {
+	if c.MaxIdleCount > 0 && idleCount > c.MaxIdleCount {
+		idleCount = c.MaxIdleCount
+	}
+
+	return max(idleCount, c.MinIdleCount)
+}
+
 type DockerMachine struct {
 	MaxGrowthRate int `toml:"MaxGrowthRate,omitzero" long:"max-growth-rate" env:"MACHINE_MAX_GROWTH_RATE" description:"Maximum machines being provisioned concurrently, set to 0 for unlimited"`
 
@@ -549,6 +686,10 @@ type VirtualBoxConfig struct {
 }
 
 type CustomConfig struct {
+	Protocol   string   `toml:"protocol,omitempty" json:"protocol,omitempty" long:"protocol" env:"CUSTOM_PROTOCOL" description:"Protocol used to talk to the driver: v1 (default) runs an executable per stage, v2 runs driver_exec once per job and exchanges JSON-lines messages with it"`
+	DriverExec string   `toml:"driver_exec,omitempty" json:"driver_exec,omitempty" long:"driver-exec" env:"CUSTOM_DRIVER_EXEC" description:"Executable of the driver started once per job with the v2 protocol"`
+	DriverArgs []string `toml:"driver_args,omitempty" json:"driver_args,omitempty" long:"driver-args" description:"Arguments for the driver executable"`
+
 	ConfigExec        string   `toml:"config_exec,omitempty" json:"config_exec" long:"config-exec" env:"CUSTOM_CONFIG_EXEC" description:"Executable that allows to inject configuration values to the executor"`
 	ConfigArgs        []string `toml:"config_args,omitempty" json:"config_args,omitempty" long:"config-args" description:"Arguments for the config executable"`
 	ConfigExecTimeout *int     `toml:"config_exec_timeout,omitempty" json:"config_exec_timeout,omitempty" long:"config-exec-timeout" env:"CUSTOM_CONFIG_EXEC_TIMEOUT" description:"Timeout for the config executable (in seconds)"`
@@ -557,6 +698,10 @@ type CustomConfig struct {
 	PrepareArgs        []string `toml:"prepare_args,omitempty" json:"prepare_args,omitempty" long:"prepare-args" description:"Arguments for the prepare executable"`
 	PrepareExecTimeout *int     `toml:"prepare_exec_timeout,omitempty" json:"prepare_exec_timeout,omitempty" long:"prepare-exec-timeout" env:"CUSTOM_PREPARE_EXEC_TIMEOUT" description:"Timeout for the prepare executable (in seconds)"`
 
+	ServicesExec        string   `toml:"services_exec,omitempty" json:"services_exec,omitempty" long:"services-exec" env:"CUSTOM_SERVICES_EXEC" description:"Executable that starts the services of the job and reports their endpoints"`
+	ServicesArgs        []string `toml:"services_args,omitempty" json:"services_args,omitempty" long:"services-args" description:"Arguments for the services executable"`
+	ServicesExecTimeout *int     `toml:"services_exec_timeout,omitempty" json:"services_exec_timeout,omitempty" long:"services-exec-timeout" env:"CUSTOM_SERVICES_EXEC_TIMEOUT" description:"Timeout for the services executable (in seconds)"`
+
 	RunExec string   `toml:"run_exec" json:"run_exec" long:"run-exec" env:"CUSTOM_RUN_EXEC" description:"Executable that runs the job script in executor"`
 	RunArgs []string `toml:"run_args,omitempty" json:"run_args,omitempty" long:"run-args" description:"Arguments for the run executable"`
 
@@ -786,6 +931,20 @@ type KubernetesConfig struct {
 	PrintPodWarningEvents                             *bool                              `toml:"print_pod_warning_events,omitempty" json:"print_pod_warning_events,omitempty" long:"print-pod-warning-events" env:"KUBERNETES_PRINT_POD_WARNING_EVENTS" description:"When enabled, all warning events associated with the pod are retrieved when the job fails, and pod warning conditions are printed while waiting for the pod to start. Enabled by default."`
 	PodDisruptionBudget                               *bool                              `toml:"pod_disruption_budget,omitzero" json:"pod_disruption_budget,omitempty" long:"pod-disruption-budget" env:"KUBERNETES_POD_DISRUPTION_BUDGET" description:"When enabled, a PodDisruptionBudget is created for each job pod to prevent eviction during node drains. Disabled by default."`
 	Autoscaler                                        *KubernetesAutoscalerConfig        `toml:"autoscaler,omitempty" json:"autoscaler,omitempty" description:"Autoscaler configuration for pause pods"`
+	ImageVerification                                 *ImageVerificationConfig           `toml:"image_verification,omitempty" json:"image_verification,omitempty" description:"Policy verifying the signatures and attestations of the job, service and helper images before the build pod is created"`
+	RegistryMirrors                                   map[string][]string                `toml:"registry_mirrors,omitempty" json:"registry_mirrors,omitempty" description:"A toml table/json object mapping registry hosts to the ordered list of mirrors the images of the registry are pulled from. The registry itself is tried after its mirrors, unless it's in the list"`
+	RunnerJobResource                                 bool                               `toml:"runner_job_resource,omitzero" json:"runner_job_resource,omitempty" long:"runner-job-resource" env:"KUBERNETES_RUNNER_JOB_RESOURCE" description:"Create a RunnerJob custom resource for each job, which the gitlab-runner k8s-controller command turns into the pod and services of the job, instead of creating them directly"`
+	TerminalDebugImage                                string                             `toml:"terminal_debug_image,omitempty" json:"terminal_debug_image,omitempty" long:"terminal-debug-image" env:"KUBERNETES_TERMINAL_DEBUG_IMAGE" description:"Image of the ephemeral container the interactive web terminal attaches to, sharing the process namespace of the build container, instead of executing a shell in the build container"`
+	Admission                                         *KubernetesAdmissionConfig         `toml:"admission,omitempty" json:"admission,omitempty" description:"Queue that admits the job pods before they're scheduled"`
+}
+
+// KubernetesAdmissionConfig configures the queue that admits the job pods of
+// the Kubernetes executor. An admitted pod has no scheduling gates left.
+type KubernetesAdmissionConfig struct {
+	QueueName                 string   `toml:"queue_name,omitempty" json:"queue_name,omitempty" description:"Kueue LocalQueue the job pods are submitted to. Kueue keeps a pod from being scheduled until the queue has quota for all of its containers"`
+	QueueNameOverwriteAllowed string   `toml:"queue_name_overwrite_allowed,omitempty" json:"queue_name_overwrite_allowed,omitempty" description:"Regex to validate 'KUBERNETES_QUEUE_NAME_OVERWRITE' value"`
+	SchedulingGates           []string `toml:"scheduling_gates,omitempty" json:"scheduling_gates,omitempty" description:"Scheduling gates added to the job pods, which a controller removes when it admits the pods"`
+	QueuedTimeout             int      `toml:"queued_timeout,omitzero" json:"queued_timeout,omitempty" description:"The total amount of time, in seconds, a job pod can wait to be admitted. The poll_timeout starts once the pod is admitted. Defaults to 3600"`
 }
 
 // KubernetesAutoscalerConfig defines autoscaling configuration for pause pods in the Kubernetes executor.
@@ -1410,6 +1569,29 @@ type RunnerCredentials struct {
 	Logger logrus.FieldLogger `toml:"-" json:",omitempty"`
 }
 
+// EncryptedSecretsConfig configures the age or SOPS encrypted file that the
+// encrypted_file secrets of the jobs are read from.
+type EncryptedSecretsConfig struct {
+	Path    string `toml:"path" json:"path" long:"path" env:"RUNNER_ENCRYPTED_SECRETS_PATH" description:"Path to the age or SOPS encrypted file holding the secrets. Relative paths are relative to the configuration file directory"`
+	KeyFile string `toml:"key_file,omitempty" json:"key_file,omitempty" long:"key-file" env:"RUNNER_ENCRYPTED_SECRETS_KEY_FILE" description:"Path to the age identity file that decrypts the secrets file"`
+	KeyEnv  string `toml:"key_env,omitempty" json:"key_env,omitempty" long:"key-env" env:"RUNNER_ENCRYPTED_SECRETS_KEY_ENV" description:"Name of the environment variable of the runner process holding the age identities that decrypt the secrets file"`
+	// AllowedKeys maps the full paths of the projects to the patterns of
+	// the keys their jobs can read. Projects that aren't listed can't read
+	// any key.
+	AllowedKeys map[string][]string `toml:"allowed_keys,omitempty" json:"allowed_keys,omitempty"`
+}
+
+// IsKeyAllowed returns whether the jobs of the project can read the key.
+func (c *EncryptedSecretsConfig) IsKeyAllowed(projectPath, key string) bool {
+	for _, pattern := range c.AllowedKeys[projectPath] {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
 type ArtifactConfig struct {
 	UploadTimeout         *time.Duration `toml:"upload_timeout,omitempty" json:"upload_timeout,omitempty"`
 	ResponseHeaderTimeout *time.Duration `toml:"response_header_timeout,omitempty" json:"response_header_timeout,omitempty"`
@@ -1463,6 +1645,8 @@ type RunnerSettings struct {
 	Cache          *cacheconfig.Config `toml:"cache,omitempty" json:"cache,omitempty" group:"cache configuration" namespace:"cache"`
 	Artifact       ArtifactConfig      `toml:"artifact,omitempty" json:"artifact"`
 
+	EncryptedSecrets *EncryptedSecretsConfig `toml:"encrypted_secrets,omitempty" json:"encrypted_secrets,omitempty" group:"encrypted secrets configuration" namespace:"encrypted_secrets"`
+
 	// GracefulKillTimeout and ForceKillTimeout aren't exposed to the users yet
 	// because not every executor supports it. We also have to keep in mind that
 	// the CustomConfig has its configuration fields for termination so when
@@ -1502,6 +1686,8 @@ type RunnerConfig struct {
 	Name                string `toml:"name" json:"name" short:"name" long:"description" env:"RUNNER_NAME" description:"Runner name"`
 	Limit               int    `toml:"limit,omitzero" json:"limit" long:"limit" env:"RUNNER_LIMIT" description:"Maximum number of builds processed by this runner"`
 	OutputLimit         int    `toml:"output_limit,omitzero" long:"output-limit" env:"RUNNER_OUTPUT_LIMIT" description:"Maximum build trace size in kilobytes"`
+	ServiceLogsLimit    int    `toml:"service_logs_limit,omitzero" long:"service-logs-limit" env:"RUNNER_SERVICE_LOGS_LIMIT" description:"Maximum size in kilobytes of the log of each service container uploaded with the job_diagnostics artifact"`
+	UploadJobTimeline   bool   `toml:"upload_job_timeline,omitzero" long:"upload-job-timeline" env:"RUNNER_UPLOAD_JOB_TIMELINE" description:"Upload the timeline of the stages of each job, and of the executor operations, in the job_diagnostics artifact"`
 	RequestConcurrency  int    `toml:"request_concurrency,omitzero" long:"request-concurrency" env:"RUNNER_REQUEST_CONCURRENCY" description:"Maximum concurrency for job requests" jsonschema:"min=1"`
 	StrictCheckInterval *bool  `toml:"strict_check_interval,omitzero" json:",omitempty" long:"strict-check-interval" env:"RUNNER_STRICT_CHECK_INTERVAL" description:"When you set StrictCheckInterval to true, the runner disables the faster-than-check_interval re-polling loop that occurs when a runner receives a job. Instead, the runner waits <check_interval> seconds before it polls again, even if additional jobs are available."`
 
@@ -1578,9 +1764,20 @@ type SessionServer struct {
 	SessionTimeout   int    `toml:"session_timeout,omitempty" json:"session_timeout" description:"How long a terminal session can be active after a build completes, in seconds"`
 }
 
+// CacheServer configures the HTTP server that stores and serves objects of
+// the "local" cache type.
+type CacheServer struct {
+	ListenAddress string `toml:"listen_address,omitempty" json:"listen_address" description:"Address the cache server listens on"`
+	Dir           string `toml:"dir,omitempty" json:"dir" description:"Directory (local disk or NFS mount) where cache objects are stored"`
+	Secret        string `toml:"secret,omitempty" json:"secret" description:"Key used to verify the signature of cache URLs"`
+	TLSCertFile   string `toml:"tls_cert_file,omitempty" json:"tls_cert_file" description:"File containing the certificate to serve cache objects over TLS"`
+	TLSKeyFile    string `toml:"tls_key_file,omitempty" json:"tls_key_file" description:"File containing the private key to serve cache objects over TLS"`
+}
+
 type Config struct {
 	ListenAddress string        `toml:"listen_address,omitempty" json:"listen_address"`
 	SessionServer SessionServer `toml:"session_server,omitempty" json:"session_server"`
+	CacheServer   *CacheServer  `toml:"cache_server,omitempty" json:"cache_server,omitempty"`
 
 	Labels Labels `toml:"labels,omitempty" json:"labels,omitempty" description:"Default custom labels for all runners."`
 
@@ -1602,6 +1799,9 @@ type Config struct {
 	ShutdownTimeout int `toml:"shutdown_timeout,omitempty" json:"shutdown_timeout" description:"Number of seconds until the forceful shutdown operation times out and exits the process"`
 
 	ConfigSaver ConfigSaver `toml:"-"`
+
+	// Sources records the files the configuration was loaded from.
+	Sources *ConfigSources `toml:"-" json:"-"`
 }
 
 // MachineConfig contains global configuration for the docker+machine executor provider.
@@ -1615,9 +1815,11 @@ type Experimental struct {
 
 type UsageLogger struct {
 	Enabled   bool            `toml:"enabled" json:"enabled"`
-	Writers   []string        `toml:"writers,omitempty" json:"writers,omitempty"` // logrotate, snowplow_billing
+	Writers   []string        `toml:"writers,omitempty" json:"writers,omitempty"` // logrotate, snowplow_billing, webhook, otlp
 	Logrotate LogrotateConfig `toml:"logrotate,omitempty" json:"logrotate"`
 	Snowplow  SnowplowConfig  `toml:"snowplow_billing,omitempty" json:"snowplow_billing"`
+	Webhook   WebhookConfig   `toml:"webhook,omitempty" json:"webhook"`
+	OTLP      OTLPConfig      `toml:"otlp,omitempty" json:"otlp"`
 
 	// Deprecated: Use Logrotate.LogDir instead
 	LogDir string `toml:"log_dir,omitempty" json:"log_dir,omitempty"`
@@ -1645,6 +1847,39 @@ type LogrotateConfig struct {
 	Labels         map[string]string `toml:"labels,omitempty" json:"labels,omitempty"`
 }
 
+// UsageLogDeliveryConfig configures how the webhook and otlp usage logger
+// writers batch, retry and spool the records.
+type UsageLogDeliveryConfig struct {
+	BatchSize     int            `toml:"batch_size,omitempty" json:"batch_size,omitempty"`
+	FlushInterval *time.Duration `toml:"flush_interval,omitempty" json:"flush_interval,omitempty"`
+	MaxTries      int            `toml:"max_tries,omitempty" json:"max_tries,omitempty"`
+	// SpoolDir stores the batches that couldn't be delivered until the
+	// endpoint is reachable again. Defaults to a directory next to the
+	// configuration file.
+	SpoolDir      string `toml:"spool_dir,omitempty" json:"spool_dir,omitempty"`
+	MaxSpoolFiles int    `toml:"max_spool_files,omitempty" json:"max_spool_files,omitempty"`
+	DisableSpool  bool   `toml:"disable_spool,omitempty" json:"disable_spool,omitempty"`
+}
+
+type WebhookConfig struct {
+	URL     string            `toml:"url,omitempty" json:"url,omitempty"`
+	Headers map[string]string `toml:"headers,omitempty" json:"headers,omitempty"`
+	Labels  map[string]string `toml:"labels,omitempty" json:"labels,omitempty"`
+
+	UsageLogDeliveryConfig
+}
+
+type OTLPConfig struct {
+	// Endpoint is the OTLP/HTTP endpoint of the collector. /v1/logs is
+	// appended when it has no path.
+	Endpoint           string            `toml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Headers            map[string]string `toml:"headers,omitempty" json:"headers,omitempty"`
+	ResourceAttributes map[string]string `toml:"resource_attributes,omitempty" json:"resource_attributes,omitempty"`
+	Labels             map[string]string `toml:"labels,omitempty" json:"labels,omitempty"`
+
+	UsageLogDeliveryConfig
+}
+
 type SnowplowConfig struct {
 	CollectorURI   string            `toml:"collector_uri,omitempty" json:"collector_uri,omitempty"`
 	AppID          string            `toml:"app_id,omitempty" json:"app_id,omitempty"`
@@ -1770,6 +2005,60 @@ type SshConfig struct {
 	IdentityFile                 string `toml:"identity_file,omitempty" json:"identity_file,omitempty" long:"identity-file" env:"SSH_IDENTITY_FILE" description:"Identity file to be used"`
 	DisableStrictHostKeyChecking *bool  `toml:"disable_strict_host_key_checking,omitempty" json:"disable_strict_host_key_checking,omitempty" long:"disable-strict-host-key-checking" env:"DISABLE_STRICT_HOST_KEY_CHECKING" description:"Disable SSH strict host key checking"`
 	KnownHostsFile               string `toml:"known_hosts_file,omitempty" json:"known_hosts_file,omitempty" long:"known-hosts-file" env:"KNOWN_HOSTS_FILE" description:"Location of known_hosts file. Defaults to ~/.ssh/known_hosts"`
+	CertificateFile              string `toml:"certificate_file,omitempty" json:"certificate_file,omitempty" long:"certificate-file" env:"SSH_CERTIFICATE_FILE" description:"Location of the CA-signed certificate of the identity file key"`
+	UseAgent                     bool   `toml:"use_agent,omitzero" json:"use_agent,omitempty" long:"use-agent" env:"SSH_USE_AGENT" description:"Authenticate with the keys of the SSH agent listening on SSH_AUTH_SOCK"`
+
+	JumpHosts []SshJumpHost `toml:"jump_hosts,omitempty" json:"jump_hosts,omitempty" description:"Chain of bastion hosts the connection to the remote host goes through, like ProxyJump"`
+
+	Hosts               []SshHost     `toml:"hosts,omitempty" json:"hosts,omitempty" description:"Pool of remote hosts the jobs are distributed to, instead of the single host"`
+	HostSelection       string        `toml:"host_selection,omitempty" json:"host_selection,omitempty" long:"host-selection" env:"SSH_HOST_SELECTION" description:"How the host of a job is selected from the pool: least_loaded (default) or round_robin"`
+	HealthCheckInterval time.Duration `toml:"health_check_interval,omitempty" json:"health_check_interval,omitempty" long:"health-check-interval" env:"SSH_HEALTH_CHECK_INTERVAL" description:"Interval of the health checks of the hosts of the pool. Unreachable hosts don't get jobs until they pass a check. Default is 30s"`
+}
+
+const (
+	SshHostSelectionLeastLoaded = "least_loaded"
+	SshHostSelectionRoundRobin  = "round_robin"
+
+	DefaultSshHealthCheckInterval = 30 * time.Second
+)
+
+// SshHost is a host of the pool of the SSH executor.
+type SshHost struct {
+	Host        string `toml:"host" json:"host" description:"Remote host"`
+	Port        string `toml:"port,omitempty" json:"port,omitempty" description:"Remote host port. Defaults to the port of the SSH configuration"`
+	Concurrency int    `toml:"concurrency,omitempty" json:"concurrency,omitempty" description:"Maximum number of jobs running on the host at the same time. Default is 1"`
+}
+
+func (h *SshHost) GetConcurrency() int {
+	if h.Concurrency <= 0 {
+		return 1
+	}
+
+	return h.Concurrency
+}
+
+// SshJumpHost is a bastion host the SSH connection goes through.
+type SshJumpHost struct {
+	Host         string `toml:"host" json:"host" description:"Bastion host"`
+	Port         string `toml:"port,omitempty" json:"port,omitempty" description:"Bastion host port. Default is 22"`
+	User         string `toml:"user,omitempty" json:"user,omitempty" description:"User name on the bastion host. Defaults to the user of the SSH configuration"`
+	IdentityFile string `toml:"identity_file,omitempty" json:"identity_file,omitempty" description:"Identity file used for the bastion host. When not set, the bastion host is authenticated with the certificate of the SSH configuration or the SSH agent, never its password"`
+}
+
+func (c *SshConfig) GetHostSelection() string {
+	if c.HostSelection == "" {
+		return SshHostSelectionLeastLoaded
+	}
+
+	return c.HostSelection
+}
+
+func (c *SshConfig) GetHealthCheckInterval() time.Duration {
+	if c.HealthCheckInterval <= 0 {
+		return DefaultSshHealthCheckInterval
+	}
+
+	return c.HealthCheckInterval
 }
 
 func (c *SshConfig) ShouldDisableStrictHostKeyChecking() bool {
@@ -1913,6 +2202,26 @@ func (c *KubernetesConfig) GetPollAttempts() int {
 	return c.GetPollTimeout() / c.GetPollInterval()
 }
 
+// GetAdmissionQueuedTimeout returns how long a job pod can wait to be
+// admitted by its queue.
+func (c *KubernetesConfig) GetAdmissionQueuedTimeout() time.Duration {
+	if c.Admission == nil || c.Admission.QueuedTimeout <= 0 {
+		return KubernetesAdmissionQueuedTimeout
+	}
+
+	return time.Duration(c.Admission.QueuedTimeout) * time.Second
+}
+
+// GetAdmissionSchedulingGates returns the scheduling gates added to the job
+// pods.
+func (c *KubernetesConfig) GetAdmissionSchedulingGates() []string {
+	if c.Admission == nil {
+		return nil
+	}
+
+	return c.Admission.SchedulingGates
+}
+
 func (c *KubernetesConfig) GetCleanupResourcesTimeout() time.Duration {
 	if c.CleanupResourcesTimeout == nil || c.CleanupResourcesTimeout.Seconds() <= 0 {
 		return KubernetesCleanupResourcesTimeout
@@ -2585,20 +2894,14 @@ func (c *Config) StatConfig(configFile string) error {
 	return nil
 }
 
+// LoadConfig loads the configuration file, merged with the drop-in fragments
+// of its config.d directory.
 func (c *Config) LoadConfig(configFile string) error {
-	info, err := os.Stat(configFile)
-
-	// permission denied is soft error
-	if os.IsNotExist(err) {
-		return nil
-	} else if err != nil {
+	found, err := c.decodeConfigFiles(configFile)
+	if err != nil || !found {
 		return err
 	}
 
-	if _, err = toml.DecodeFile(configFile, c); err != nil {
-		return fmt.Errorf("decoding configuration file: %w", err)
-	}
-
 	// Fall back to the SENTRY_DSN environment variable when sentry_dsn is not
 	// set in the config file. The config file value takes precedence.
 	if c.SentryDSN == nil {
@@ -2610,7 +2913,7 @@ func (c *Config) LoadConfig(configFile string) error {
 	for _, r := range c.Runners {
 		err := r.loadConfig(c)
 		if err != nil {
-			return fmt.Errorf("loading configuration for %s runner: %w", r.Name, err)
+			return fmt.Errorf("loading configuration for %s runner%s: %w", r.Name, c.Sources.describeRunner(r), err)
 		}
 	}
 
@@ -2620,7 +2923,10 @@ func (c *Config) LoadConfig(configFile string) error {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	c.ModTime = info.ModTime()
+	c.ModTime, err = ConfigModTime(configFile)
+	if err != nil {
+		return err
+	}
 
 	if c.ConnectionMaxAge == nil {
 		defaultValue := DefaultConnectionMaxAge
@@ -2661,7 +2967,12 @@ func (c *Config) SaveConfig(configFile string) error {
 	var newConfig bytes.Buffer
 	newBuffer := bufio.NewWriter(&newConfig)
 
-	if err := toml.NewEncoder(newBuffer).Encode(c); err != nil {
+	saved, err := c.savedConfig()
+	if err != nil {
+		return fmt.Errorf("preparing configuration to save: %w", err)
+	}
+
+	if err := toml.NewEncoder(newBuffer).Encode(saved); err != nil {
 		logrus.Fatalf("Error encoding TOML: %s", err)
 		return err
 	}
@@ -2678,6 +2989,10 @@ func (c *Config) SaveConfig(configFile string) error {
 		return err
 	}
 
+	if err := c.saveRotatedTokens(c.ConfigSaver); err != nil {
+		return fmt.Errorf("saving rotated tokens: %w", err)
+	}
+
 	c.ModTime = time.Now()
 	c.Loaded = true
 
@@ -2760,7 +3075,7 @@ func (c *Config) Validate() error {
 	for _, r := range c.Runners {
 		err := r.Validate()
 		if err != nil {
-			return fmt.Errorf("validating runner %s: %w", r.Name, err)
+			return fmt.Errorf("validating runner %s%s: %w", r.Name, c.Sources.describeRunner(r), err)
 		}
 	}
 
@@ -2777,6 +3092,7 @@ func (c *RunnerConfig) Validate() error {
 		"computed labels":           c.validateComputedLabels,
 		"slot cgroups":              c.validateSlotCgroups,
 		"machine options with name": c.validateMachineOptionsWithName,
+		"cache compression format":  c.validateCacheCompressionFormat,
 	} {
 		err := v()
 		if err != nil {
@@ -2795,6 +3111,23 @@ func (c *RunnerConfig) validateComputedLabels() error {
 	return c.labels.validateCount()
 }
 
+// validateCacheCompressionFormat rejects the chunked cache format set in the
+// runner's environment when its cache can't store the chunks.
+func (c *RunnerConfig) validateCacheCompressionFormat() error {
+	if cache.SupportsChunkStore(c.Cache) {
+		return nil
+	}
+
+	for _, env := range c.Environment {
+		key, value, _ := strings.Cut(env, "=")
+		if key == "CACHE_COMPRESSION_FORMAT" && strings.EqualFold(value, cache.ChunkedFormat) {
+			return cache.ErrChunkStoreUnsupported
+		}
+	}
+
+	return nil
+}
+
 func (c *RunnerConfig) validateSlotCgroups() error {
 	if !c.UseSlotCgroups {
 		return nil
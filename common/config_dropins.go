@@ -0,0 +1,589 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configDropInDirName is the name of the directory, next to the
+// configuration file, holding the drop-in fragments merged into it.
+const configDropInDirName = "config.d"
+
+// ConfigFileError is returned when a file of the configuration can't be
+// decoded.
+type ConfigFileError struct {
+	File string
+	Err  error
+}
+
+func (e *ConfigFileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigFileError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigSources records the files a configuration was merged from.
+type ConfigSources struct {
+	// ConfigFile is the configuration file.
+	ConfigFile string
+	// DropIns are the drop-in fragments merged into the configuration
+	// file, in the order they were merged.
+	DropIns []string
+	// Keys maps the global keys to the last file setting them.
+	Keys map[string]string
+	// Runners maps the runners to the files defining and patching them.
+	Runners map[*RunnerConfig][]string
+	// Undecoded maps the files to their unknown keys.
+	Undecoded map[string][]toml.Key
+
+	// base holds the values of the configuration file alone, and loaded the
+	// values merged with the drop-in fragments, so that saving writes back
+	// only what changed since loading. They are only set when there are
+	// drop-in fragments.
+	base   *configSnapshot
+	loaded *configSnapshot
+
+	// dropInTokens maps the runners whose token is set by the drop-in
+	// fragments to that token. Their rotated tokens are saved to the
+	// rotated tokens file, as the fragments are never written to. Runners
+	// that aren't mapped have their token saved to the configuration file.
+	dropInTokens map[*RunnerConfig]string
+}
+
+// rotatedTokensFileName is the name of the file, next to the configuration
+// file, holding the tokens rotated for the runners whose token is set by the
+// drop-in fragments. The runner owns the file, and its tokens override those
+// of the fragments.
+const rotatedTokensFileName = ".runner_rotated_tokens.toml"
+
+// rotatedTokens is the content of the rotated tokens file.
+type rotatedTokens struct {
+	Runners []rotatedToken `toml:"runners"`
+}
+
+// rotatedToken is the rotated token of a runner. It replaces the token the
+// drop-in fragments set, identified by its SHA-256, so that it's ignored
+// once the fragments set another token, for example after registering the
+// runner again.
+type rotatedToken struct {
+	DropInTokenSHA256 string    `toml:"drop_in_token_sha256"`
+	Token             string    `toml:"token"`
+	TokenObtainedAt   time.Time `toml:"token_obtained_at"`
+	TokenExpiresAt    time.Time `toml:"token_expires_at"`
+}
+
+func tokenSHA256(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// rotatedTokensFile returns the file holding the tokens rotated for the
+// runners whose token is set by the drop-in fragments.
+func rotatedTokensFile(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), rotatedTokensFileName)
+}
+
+// runnerTokenKeys are the keys of the runner token, which the runner updates
+// when it rotates the token.
+var runnerTokenKeys = []string{"token", "token_obtained_at", "token_expires_at"}
+
+// configSnapshot holds the TOML values of a configuration's global keys and
+// of each of its runners.
+type configSnapshot struct {
+	global  map[string]any
+	runners map[*RunnerConfig]map[string]any
+}
+
+func newConfigSnapshot(c *Config) (*configSnapshot, error) {
+	global := *c
+	global.Runners = nil
+
+	values, err := tomlValues(&global)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &configSnapshot{global: values, runners: map[*RunnerConfig]map[string]any{}}
+	for _, runner := range c.Runners {
+		values, err := tomlValues(runner)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.runners[runner] = values
+	}
+
+	return snapshot, nil
+}
+
+// tomlValues returns the values v is encoded to in TOML.
+func tomlValues(v any) (map[string]any, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	_, err := toml.NewDecoder(&buf).Decode(&values)
+
+	return values, err
+}
+
+// mergeChanges returns the base values, with the changes from loaded to
+// current applied.
+func mergeChanges(base, loaded, current map[string]any) map[string]any {
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+
+	keys := slices.Concat(slices.Collect(maps.Keys(loaded)), slices.Collect(maps.Keys(current)))
+	for _, key := range keys {
+		loadedValue, inLoaded := loaded[key]
+		currentValue, inCurrent := current[key]
+
+		switch {
+		case inLoaded == inCurrent && reflect.DeepEqual(loadedValue, currentValue):
+			continue
+		case !inCurrent:
+			delete(merged, key)
+			continue
+		}
+
+		loadedTable, loadedIsTable := loadedValue.(map[string]any)
+		currentTable, currentIsTable := currentValue.(map[string]any)
+		if loadedIsTable && currentIsTable {
+			baseTable, _ := base[key].(map[string]any)
+			merged[key] = mergeChanges(baseTable, loadedTable, currentTable)
+			continue
+		}
+
+		merged[key] = currentValue
+	}
+
+	return merged
+}
+
+// RunnerFiles returns the files defining and patching the runner.
+func (s *ConfigSources) RunnerFiles(runner *RunnerConfig) []string {
+	if s == nil {
+		return nil
+	}
+
+	return s.Runners[runner]
+}
+
+// IsDropIn returns whether the runner was defined by a drop-in fragment,
+// rather than by the configuration file.
+func (s *ConfigSources) IsDropIn(runner *RunnerConfig) bool {
+	files := s.RunnerFiles(runner)
+	return len(files) > 0 && files[0] != s.ConfigFile
+}
+
+func (s *ConfigSources) describeRunner(runner *RunnerConfig) string {
+	files := s.RunnerFiles(runner)
+	if len(files) == 0 || (len(files) == 1 && files[0] == s.ConfigFile) {
+		return ""
+	}
+
+	return " (from " + strings.Join(files, ", ") + ")"
+}
+
+func (s *ConfigSources) record(path string, md toml.MetaData) {
+	for _, key := range md.Keys() {
+		if len(key) == 1 && key[0] != "runners" {
+			s.Keys[key[0]] = path
+		}
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		s.Undecoded[path] = undecoded
+	}
+}
+
+// ConfigDropInDir returns the directory holding the drop-in fragments merged
+// into the configuration file.
+func ConfigDropInDir(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), configDropInDirName)
+}
+
+// ConfigDropIns returns the drop-in fragments merged into the configuration
+// file, in lexical order.
+func ConfigDropIns(configFile string) ([]string, error) {
+	entries, err := os.ReadDir(ConfigDropInDir(configFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading drop-in directory: %w", err)
+	}
+
+	var dropIns []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+		dropIns = append(dropIns, filepath.Join(ConfigDropInDir(configFile), entry.Name()))
+	}
+	slices.Sort(dropIns)
+
+	return dropIns, nil
+}
+
+// ConfigModTime returns the latest modification time of the configuration
+// file, its drop-in directory and its drop-in fragments, so that adding,
+// changing and removing any of them is noticed.
+func ConfigModTime(configFile string) (time.Time, error) {
+	var modTime time.Time
+	var found bool
+
+	paths := []string{configFile, ConfigDropInDir(configFile)}
+	dropIns, err := ConfigDropIns(configFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, path := range append(paths, dropIns...) {
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return time.Time{}, err
+		}
+
+		found = true
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	if !found {
+		return time.Time{}, fmt.Errorf("stat %s: %w", configFile, os.ErrNotExist)
+	}
+
+	return modTime, nil
+}
+
+// decodeConfigFiles decodes the configuration file, then merges its drop-in
+// fragments in lexical order. It returns false if none of them exists.
+func (c *Config) decodeConfigFiles(configFile string) (bool, error) {
+	c.Sources = &ConfigSources{
+		ConfigFile: configFile,
+		Keys:       map[string]string{},
+		Runners:    map[*RunnerConfig][]string{},
+		Undecoded:  map[string][]toml.Key{},
+
+		dropInTokens: map[*RunnerConfig]string{},
+	}
+
+	_, err := os.Stat(configFile)
+	exists := err == nil
+	// permission denied is soft error
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	dropIns, err := ConfigDropIns(configFile)
+	if err != nil {
+		return false, err
+	}
+
+	if exists {
+		md, err := toml.DecodeFile(configFile, c)
+		if err != nil {
+			return false, &ConfigFileError{File: configFile, Err: fmt.Errorf("decoding configuration file: %w", err)}
+		}
+
+		c.Sources.record(configFile, md)
+		for _, runner := range c.Runners {
+			c.Sources.Runners[runner] = []string{configFile}
+		}
+	}
+
+	if len(dropIns) == 0 {
+		return exists, nil
+	}
+
+	if c.Sources.base, err = newConfigSnapshot(c); err != nil {
+		return false, fmt.Errorf("recording configuration file values: %w", err)
+	}
+
+	for _, dropIn := range dropIns {
+		if err := c.mergeDropIn(dropIn); err != nil {
+			return false, &ConfigFileError{File: dropIn, Err: fmt.Errorf("decoding drop-in %s: %w", dropIn, err)}
+		}
+	}
+	c.Sources.DropIns = dropIns
+
+	if err := c.applyRotatedTokens(); err != nil {
+		return false, &ConfigFileError{File: rotatedTokensFile(configFile), Err: err}
+	}
+
+	if c.Sources.loaded, err = newConfigSnapshot(c); err != nil {
+		return false, fmt.Errorf("recording configuration values: %w", err)
+	}
+
+	return true, nil
+}
+
+// mergeDropIn merges a drop-in fragment into the configuration. Its global
+// keys override the configuration's. Its [[runners]] patch the runners
+// with the same name, and are appended otherwise.
+func (c *Config) mergeDropIn(path string) error {
+	fragment := struct {
+		*Config
+		Runners []toml.Primitive `toml:"runners"`
+	}{Config: c}
+
+	md, err := toml.DecodeFile(path, &fragment)
+	if err != nil {
+		return err
+	}
+
+	for _, primitive := range fragment.Runners {
+		var id struct {
+			Name  string  `toml:"name"`
+			Token *string `toml:"token"`
+		}
+		if err := md.PrimitiveDecode(primitive, &id); err != nil {
+			return err
+		}
+
+		runner := c.runnerByName(id.Name)
+		if runner == nil {
+			runner = &RunnerConfig{}
+			c.Runners = append(c.Runners, runner)
+		}
+
+		if err := md.PrimitiveDecode(primitive, runner); err != nil {
+			return fmt.Errorf("runner %s: %w", id.Name, err)
+		}
+		c.Sources.Runners[runner] = append(c.Sources.Runners[runner], path)
+
+		if _, defined := c.Sources.dropInTokens[runner]; defined || id.Token != nil || c.Sources.IsDropIn(runner) {
+			c.Sources.dropInTokens[runner] = runner.Token
+		}
+	}
+
+	c.Sources.record(path, md)
+
+	return nil
+}
+
+func (c *Config) runnerByName(name string) *RunnerConfig {
+	if name == "" {
+		return nil
+	}
+
+	for _, runner := range c.Runners {
+		if runner.Name == name {
+			return runner
+		}
+	}
+
+	return nil
+}
+
+// savedConfig returns the configuration to save to the configuration file.
+// With drop-in fragments, it holds the values of the configuration file with
+// the changes made since loading applied, so that the values set by the
+// drop-in fragments aren't written to the configuration file, and it leaves
+// out the runners they define. The rotated tokens of the runners whose token
+// the drop-in fragments set are saved by saveRotatedTokens instead.
+func (c *Config) savedConfig() (*Config, error) {
+	if c.Sources == nil || c.Sources.base == nil || c.Sources.loaded == nil {
+		return c, nil
+	}
+
+	current, err := newConfigSnapshot(c)
+	if err != nil {
+		return nil, err
+	}
+
+	values := mergeChanges(c.Sources.base.global, c.Sources.loaded.global, current.global)
+
+	var runners []map[string]any
+	for _, runner := range c.Runners {
+		if c.Sources.IsDropIn(runner) {
+			continue
+		}
+
+		base, fromConfigFile := c.Sources.base.runners[runner]
+		if !fromConfigFile {
+			runners = append(runners, current.runners[runner])
+			continue
+		}
+
+		loaded := c.Sources.loaded.runners[runner]
+		changed := current.runners[runner]
+		if _, ok := c.Sources.dropInTokens[runner]; ok {
+			changed = maps.Clone(changed)
+			for _, key := range runnerTokenKeys {
+				restoreValue(changed, loaded, key)
+			}
+		}
+
+		runners = append(runners, mergeChanges(base, loaded, changed))
+	}
+	if len(runners) > 0 {
+		values["runners"] = runners
+	}
+
+	// Decode the values back into a configuration, so that it is encoded
+	// in the order of its fields.
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+
+	saved := &Config{}
+	if _, err := toml.NewDecoder(&buf).Decode(saved); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// restoreValue sets the value of key in values to its value in from.
+func restoreValue(values, from map[string]any, key string) {
+	if value, ok := from[key]; ok {
+		values[key] = value
+	} else {
+		delete(values, key)
+	}
+}
+
+// applyRotatedTokens overrides the tokens the drop-in fragments set with
+// those rotated since, read from the rotated tokens file.
+func (c *Config) applyRotatedTokens() error {
+	if len(c.Sources.dropInTokens) == 0 {
+		return nil
+	}
+
+	tokens, err := readRotatedTokens(rotatedTokensFile(c.Sources.ConfigFile))
+	if err != nil {
+		return err
+	}
+
+	rotated := map[string]rotatedToken{}
+	for _, token := range tokens.Runners {
+		rotated[token.DropInTokenSHA256] = token
+	}
+
+	for runner, dropInToken := range c.Sources.dropInTokens {
+		token, ok := rotated[tokenSHA256(dropInToken)]
+		if !ok {
+			continue
+		}
+
+		runner.Token = token.Token
+		runner.TokenObtainedAt = token.TokenObtainedAt
+		runner.TokenExpiresAt = token.TokenExpiresAt
+	}
+
+	return nil
+}
+
+func readRotatedTokens(path string) (rotatedTokens, error) {
+	var tokens rotatedTokens
+
+	_, err := toml.DecodeFile(path, &tokens)
+	if errors.Is(err, os.ErrNotExist) {
+		return rotatedTokens{}, nil
+	} else if err != nil {
+		return rotatedTokens{}, fmt.Errorf("decoding rotated tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// saveRotatedTokens saves the tokens rotated since loading, of the runners
+// whose token the drop-in fragments set, to the rotated tokens file, for
+// the fragments not to override them when the configuration is loaded
+// again. The fragments themselves are left untouched. The tokens replacing
+// a token no fragment sets anymore are dropped.
+func (c *Config) saveRotatedTokens(saver ConfigSaver) error {
+	if c.Sources == nil || c.Sources.loaded == nil || len(c.Sources.dropInTokens) == 0 {
+		return nil
+	}
+
+	current, err := newConfigSnapshot(c)
+	if err != nil {
+		return err
+	}
+
+	var rotated []*RunnerConfig
+	for _, runner := range c.Runners {
+		if _, ok := c.Sources.dropInTokens[runner]; !ok {
+			continue
+		}
+
+		loaded := c.Sources.loaded.runners[runner]
+		for _, key := range runnerTokenKeys {
+			if !reflect.DeepEqual(loaded[key], current.runners[runner][key]) {
+				rotated = append(rotated, runner)
+				break
+			}
+		}
+	}
+
+	if len(rotated) == 0 {
+		return nil
+	}
+
+	path := rotatedTokensFile(c.Sources.ConfigFile)
+	tokens, err := readRotatedTokens(path)
+	if err != nil {
+		return err
+	}
+
+	dropInTokens := map[string]bool{}
+	for _, token := range c.Sources.dropInTokens {
+		dropInTokens[tokenSHA256(token)] = true
+	}
+
+	tokens.Runners = slices.DeleteFunc(tokens.Runners, func(token rotatedToken) bool {
+		return !dropInTokens[token.DropInTokenSHA256]
+	})
+
+	for _, runner := range rotated {
+		token := rotatedToken{
+			DropInTokenSHA256: tokenSHA256(c.Sources.dropInTokens[runner]),
+			Token:             runner.Token,
+			TokenObtainedAt:   runner.TokenObtainedAt,
+			TokenExpiresAt:    runner.TokenExpiresAt,
+		}
+
+		i := slices.IndexFunc(tokens.Runners, func(t rotatedToken) bool {
+			return t.DropInTokenSHA256 == token.DropInTokenSHA256
+		})
+		if i < 0 {
+			tokens.Runners = append(tokens.Runners, token)
+		} else {
+			tokens.Runners[i] = token
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(tokens); err != nil {
+		return fmt.Errorf("encoding rotated tokens: %w", err)
+	}
+
+	if err := saver.Save(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("saving rotated tokens: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+//go:build !integration
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	return filepath.Join(dir, "config.toml")
+}
+
+func TestLoadConfig_DropIns(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.toml": `
+concurrent = 1
+check_interval = 3
+
+[[runners]]
+  name = "base"
+  url = "https://gitlab.example.com"
+  token = "glrt-base"
+  executor = "docker"
+  [runners.docker]
+    image = "alpine"
+    privileged = true
+`,
+		"config.d/10-global.toml": `
+concurrent = 10
+`,
+		"config.d/20-runners.toml": `
+concurrent = 20
+
+[[runners]]
+  name = "base"
+  limit = 5
+  [runners.docker]
+    image = "debian"
+
+[[runners]]
+  name = "extra"
+  url = "https://gitlab.example.com"
+  token = "glrt-extra"
+  executor = "shell"
+`,
+		"config.d/ignored.txt": `concurrent = 30`,
+	})
+	dropInDir := ConfigDropInDir(configFile)
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadConfig(configFile))
+
+	assert.Equal(t, 20, cfg.Concurrent)
+	assert.Equal(t, 3, cfg.CheckInterval)
+
+	require.Len(t, cfg.Runners, 2)
+	base, extra := cfg.Runners[0], cfg.Runners[1]
+
+	assert.Equal(t, "base", base.Name)
+	assert.Equal(t, "glrt-base", base.Token)
+	assert.Equal(t, 5, base.Limit)
+	require.NotNil(t, base.Docker)
+	assert.Equal(t, "debian", base.Docker.Image)
+	assert.True(t, base.Docker.Privileged)
+
+	assert.Equal(t, "extra", extra.Name)
+	assert.Equal(t, "shell", extra.Executor)
+
+	assert.Equal(t, []string{
+		filepath.Join(dropInDir, "10-global.toml"),
+		filepath.Join(dropInDir, "20-runners.toml"),
+	}, cfg.Sources.DropIns)
+	assert.Equal(t, filepath.Join(dropInDir, "20-runners.toml"), cfg.Sources.Keys["concurrent"])
+	assert.Equal(t, configFile, cfg.Sources.Keys["check_interval"])
+	assert.Equal(t, []string{configFile, filepath.Join(dropInDir, "20-runners.toml")}, cfg.Sources.RunnerFiles(base))
+	assert.False(t, cfg.Sources.IsDropIn(base))
+	assert.True(t, cfg.Sources.IsDropIn(extra))
+}
+
+func TestLoadConfig_DropInsOnly(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.d/runner.toml": `
+[[runners]]
+  name = "only"
+  executor = "shell"
+`,
+	})
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadConfig(configFile))
+
+	assert.True(t, cfg.Loaded)
+	require.Len(t, cfg.Runners, 1)
+	assert.Equal(t, "only", cfg.Runners[0].Name)
+}
+
+func TestLoadConfig_DropInErrors(t *testing.T) {
+	t.Run("syntax error", func(t *testing.T) {
+		configFile := writeConfigFiles(t, map[string]string{
+			"config.toml":          "concurrent = 1\n",
+			"config.d/broken.toml": "concurrent = \n",
+		})
+
+		err := NewConfig().LoadConfig(configFile)
+		assert.ErrorContains(t, err, filepath.Join(ConfigDropInDir(configFile), "broken.toml"))
+	})
+
+	t.Run("invalid runner", func(t *testing.T) {
+		configFile := writeConfigFiles(t, map[string]string{
+			"config.toml": "concurrent = 1\n",
+			"config.d/runner.toml": `
+[[runners]]
+  name = "invalid"
+  [runners.machine]
+    MachineOptionsWithName = ["name=foo"]
+`,
+		})
+
+		err := NewConfig().LoadConfig(configFile)
+		assert.ErrorContains(t, err, "validating runner invalid (from "+filepath.Join(ConfigDropInDir(configFile), "runner.toml")+")")
+	})
+}
+
+func TestSaveConfig_DropIns(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.toml": `
+[[runners]]
+  name = "base"
+`,
+		"config.d/runner.toml": `
+[[runners]]
+  name = "base"
+  limit = 2
+
+[[runners]]
+  name = "extra"
+`,
+	})
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadConfig(configFile))
+	cfg.Runners = append(cfg.Runners, &RunnerConfig{Name: "registered"})
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	saved := NewConfig()
+	_, err := saved.decodeConfigFiles(configFile)
+	require.NoError(t, err)
+
+	var names []string
+	for _, runner := range saved.Runners {
+		names = append(names, runner.Name)
+	}
+	assert.Equal(t, []string{"base", "registered", "extra"}, names)
+}
+
+func TestSaveConfig_DropInValues(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.toml": `
+concurrent = 4
+check_interval = 3
+
+[[runners]]
+  name = "base"
+  token = "glrt-old"
+  limit = 1
+`,
+		"config.d/override.toml": `
+concurrent = 10
+log_level = "debug"
+
+[[runners]]
+  name = "base"
+  limit = 2
+  output_limit = 8192
+`,
+	})
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadConfig(configFile))
+	cfg.CheckInterval = 5
+	cfg.Runners[0].Token = "glrt-new"
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	saved := NewConfig()
+	_, err := toml.DecodeFile(configFile, saved)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, saved.Concurrent)
+	assert.Equal(t, 5, saved.CheckInterval)
+	assert.Nil(t, saved.LogLevel)
+	require.Len(t, saved.Runners, 1)
+	assert.Equal(t, "glrt-new", saved.Runners[0].Token)
+	assert.Equal(t, 1, saved.Runners[0].Limit)
+	assert.Zero(t, saved.Runners[0].OutputLimit)
+
+	reloaded := NewConfig()
+	require.NoError(t, reloaded.LoadConfig(configFile))
+	assert.Equal(t, 10, reloaded.Concurrent)
+	assert.Equal(t, 2, reloaded.Runners[0].Limit)
+}
+
+func TestConfigModTime(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.toml":          "concurrent = 1\n",
+		"config.d/runner.toml": "concurrent = 2\n",
+	})
+
+	modTime := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(ConfigDropInDir(configFile), "runner.toml"), modTime, modTime))
+
+	got, err := ConfigModTime(configFile)
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(got))
+
+	_, err = ConfigModTime(filepath.Join(t.TempDir(), "config.toml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSaveConfig_DropInTokens(t *testing.T) {
+	configFile := writeConfigFiles(t, map[string]string{
+		"config.toml": `
+[[runners]]
+  name = "base"
+  token = "glrt-base"
+  limit = 1
+
+[[runners]]
+  name = "patched"
+  token = "glrt-patched-old"
+`,
+		"config.d/runners.toml": `
+[[runners]]
+  name = "patched"
+  token = "glrt-patched"
+
+[[runners]]
+  name = "extra"
+  url = "https://gitlab.example.com"
+  token = "glrt-extra"
+  limit = 3
+`,
+	})
+
+	dropIn := filepath.Join(filepath.Dir(configFile), "config.d", "runners.toml")
+	dropInContent, err := os.ReadFile(dropIn)
+	require.NoError(t, err)
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadConfig(configFile))
+	require.Len(t, cfg.Runners, 3)
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, runner := range cfg.Runners {
+		runner.Token += "-rotated"
+		runner.TokenExpiresAt = expiresAt
+	}
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	saved := NewConfig()
+	_, err = toml.DecodeFile(configFile, saved)
+	require.NoError(t, err)
+	require.Len(t, saved.Runners, 2)
+	assert.Equal(t, "glrt-base-rotated", saved.Runners[0].Token)
+	assert.Equal(t, "glrt-patched-old", saved.Runners[1].Token, "the token set by the drop-in isn't saved to config.toml")
+
+	reloaded := NewConfig()
+	require.NoError(t, reloaded.LoadConfig(configFile))
+
+	tokens := map[string]string{}
+	for _, runner := range reloaded.Runners {
+		tokens[runner.Name] = runner.Token
+		assert.Equal(t, expiresAt, runner.TokenExpiresAt.UTC(), runner.Name)
+	}
+	assert.Equal(t, map[string]string{
+		"base":    "glrt-base-rotated",
+		"patched": "glrt-patched-rotated",
+		"extra":   "glrt-extra-rotated",
+	}, tokens)
+	assert.Equal(t, 3, reloaded.Runners[2].Limit, "the other values of the drop-in are kept")
+	assert.Equal(t, []string{dropIn}, reloaded.Sources.RunnerFiles(reloaded.Runners[2]))
+
+	content, err := os.ReadFile(dropIn)
+	require.NoError(t, err)
+	assert.Equal(t, string(dropInContent), string(content), "the drop-in is never written to")
+
+	t.Run("rotated again", func(t *testing.T) {
+		reloaded.Runners[2].Token = "glrt-extra-rotated-twice"
+		require.NoError(t, reloaded.SaveConfig(configFile))
+
+		again := NewConfig()
+		require.NoError(t, again.LoadConfig(configFile))
+		assert.Equal(t, "glrt-extra-rotated-twice", again.Runners[2].Token)
+		assert.Equal(t, "glrt-patched-rotated", again.Runners[1].Token)
+	})
+
+	t.Run("drop-in sets another token", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(dropIn, []byte(strings.Replace(string(dropInContent), "glrt-extra", "glrt-extra-registered", 1)), 0o600))
+
+		again := NewConfig()
+		require.NoError(t, again.LoadConfig(configFile))
+		assert.Equal(t, "glrt-extra-registered", again.Runners[2].Token, "the rotated token of the previous token is ignored")
+		assert.Equal(t, "glrt-patched-rotated", again.Runners[1].Token)
+	})
+}
@@ -17,6 +17,7 @@ import (
 
 	clihelpers "gitlab.com/gitlab-org/golang-cli-helpers"
 
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
@@ -1602,6 +1603,24 @@ func TestAutoscalerPolicyConfig_PreemptiveModeEnabled(t *testing.T) {
 	}
 }
 
+func TestAutoscalerPredictiveConfig_ClampIdleCount(t *testing.T) {
+	tests := map[string]struct {
+		config   AutoscalerPredictiveConfig
+		expected int
+	}{
+		"within bounds":   {config: AutoscalerPredictiveConfig{MinIdleCount: 1, MaxIdleCount: 10}, expected: 5},
+		"below minimum":   {config: AutoscalerPredictiveConfig{MinIdleCount: 8, MaxIdleCount: 10}, expected: 8},
+		"above maximum":   {config: AutoscalerPredictiveConfig{MinIdleCount: 1, MaxIdleCount: 3}, expected: 3},
+		"without maximum": {config: AutoscalerPredictiveConfig{MinIdleCount: 1}, expected: 5},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.config.ClampIdleCount(5))
+		})
+	}
+}
+
 func TestRunnerSettings_IsFeatureFlagOn(t *testing.T) {
 	tests := map[string]struct {
 		featureFlags  map[string]bool
@@ -4016,6 +4035,50 @@ func TestRunnerConfig_ValidateMachineOptionsWithName(t *testing.T) {
 	}
 }
 
+func TestRunnerConfig_ValidateCacheCompressionFormat(t *testing.T) {
+	tests := map[string]struct {
+		environment []string
+		cache       *cacheconfig.Config
+		expectError bool
+	}{
+		"chunked format not set": {
+			environment: []string{"CACHE_COMPRESSION_FORMAT=tarzstd"},
+			cache:       &cacheconfig.Config{Type: "gcs"},
+		},
+		"chunked format without a distributed cache": {
+			environment: []string{"CACHE_COMPRESSION_FORMAT=chunked"},
+		},
+		"chunked format with the s3 cache and RoleARN": {
+			environment: []string{"CACHE_COMPRESSION_FORMAT=chunked"},
+			cache:       &cacheconfig.Config{Type: "s3", S3: &cacheconfig.CacheS3Config{RoleARN: "arn"}},
+		},
+		"chunked format with the local cache": {
+			environment: []string{"CACHE_COMPRESSION_FORMAT=Chunked"},
+			cache:       &cacheconfig.Config{Type: "local", Local: &cacheconfig.CacheLocalConfig{}},
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := &RunnerConfig{
+				RunnerSettings: RunnerSettings{
+					Environment: tc.environment,
+					Cache:       tc.cache,
+				},
+			}
+
+			err := config.Validate()
+
+			if tc.expectError {
+				assert.ErrorIs(t, err, cache.ErrChunkStoreUnsupported)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestParseVariable(t *testing.T) {
 	v, err := parseVariable("key=value=value2")
 	assert.NoError(t, err)
@@ -28,6 +28,7 @@ const DefaultAfterScriptIgnoreErrors = true
 const KubernetesPollInterval = 3
 const KubernetesPollTimeout = 180
 const KubernetesCleanupResourcesTimeout = 5 * time.Minute
+const KubernetesAdmissionQueuedTimeout = time.Hour
 const KubernetesResourceAvailabilityCheckMaxAttempts = 5
 const AfterScriptTimeout = 5 * time.Minute
 const DefaultMetricsServerPort = 9252
@@ -49,6 +50,8 @@ const (
 	DefaultTraceOutputLimit = 4 * 1024 * 1024 // in bytes
 	DefaultTracePatchLimit  = 1024 * 1024     // in bytes
 
+	DefaultServiceLogsLimit = 1024 * 1024 // in bytes
+
 	DefaultUpdateInterval = 3 * time.Second
 	MaxUpdateInterval     = 15 * time.Minute
 
@@ -96,6 +96,24 @@ const (
 	// falls through to UnknownFailure.
 	RunnerInterrupted spec.JobFailureReason = "runner_interrupted"
 
+	// ImageVerificationFailure indicates that an image of the job didn't satisfy the image verification
+	// policy of the runner, for example because it isn't signed by a trusted key. Since this failure
+	// reason doesn't exist in Rails yet, we map it to ImagePullFailure below.
+	ImageVerificationFailure spec.JobFailureReason = "image_verification_failure"
+
+	// OutOfMemoryFailure indicates that a container of the job was killed because it exceeded its memory
+	// limit. Since this failure reason doesn't exist in Rails yet, we map it to ScriptFailure below.
+	OutOfMemoryFailure spec.JobFailureReason = "out_of_memory_failure"
+
+	// StorageLimitFailure indicates that the job was evicted because a container exceeded its ephemeral
+	// storage limit. Since this failure reason doesn't exist in Rails yet, we map it to ScriptFailure below.
+	StorageLimitFailure spec.JobFailureReason = "storage_limit_failure"
+
+	// EvictionFailure indicates that the job was evicted from its node, for example because the node was
+	// low on memory or disk. Since this failure reason doesn't exist in Rails yet, we map it to
+	// RunnerSystemFailure below.
+	EvictionFailure spec.JobFailureReason = "eviction_failure"
+
 	// When defining new job failure reasons, consider if its meaning is
 	// extracted from the scope of already existing one. If yes - update
 	// the failureReasonsCompatibilityMap variable below.
@@ -117,6 +135,10 @@ var (
 		ConfigurationError,
 		RunnerExternalDependencyFailure,
 		RunnerInterrupted,
+		ImageVerificationFailure,
+		OutOfMemoryFailure,
+		StorageLimitFailure,
+		EvictionFailure,
 		JobCanceled,
 	}
 
@@ -129,6 +151,10 @@ var (
 		ImagePullFailure:                RunnerSystemFailure,
 		ConfigurationError:              ScriptFailure,
 		RunnerExternalDependencyFailure: RunnerSystemFailure,
+		ImageVerificationFailure:        ImagePullFailure,
+		OutOfMemoryFailure:              ScriptFailure,
+		StorageLimitFailure:             ScriptFailure,
+		EvictionFailure:                 RunnerSystemFailure,
 	}
 
 	// A small list of failure reasons that are supported by all
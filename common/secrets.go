@@ -31,6 +31,20 @@ type SecretResolver interface {
 	Resolve() (string, error)
 }
 
+// RunnerConfigSecretResolver is implemented by the resolvers reading their
+// settings from the configuration of the runner instead of the job payload.
+type RunnerConfigSecretResolver interface {
+	SecretResolver
+	SetRunnerConfig(config *RunnerConfig)
+}
+
+// JobInfoSecretResolver is implemented by the resolvers scoping the secrets
+// to the job's project.
+type JobInfoSecretResolver interface {
+	SecretResolver
+	SetJobInfo(info spec.JobInfo)
+}
+
 var (
 	secretResolverRegistry = new(defaultSecretResolverRegistry)
 
@@ -64,6 +78,26 @@ func (r *defaultSecretResolverRegistry) GetFor(secret spec.Secret) (SecretResolv
 	return nil, ErrMissingSecretResolver
 }
 
+// runnerSecretResolverRegistry passes the configuration of the runner, and
+// the information of the job, to the resolvers that need them.
+type runnerSecretResolverRegistry struct {
+	SecretResolverRegistry
+	config  *RunnerConfig
+	jobInfo spec.JobInfo
+}
+
+func (r *runnerSecretResolverRegistry) GetFor(secret spec.Secret) (SecretResolver, error) {
+	sr, err := r.SecretResolverRegistry.GetFor(secret)
+	if configurable, ok := sr.(RunnerConfigSecretResolver); ok {
+		configurable.SetRunnerConfig(r.config)
+	}
+	if scoped, ok := sr.(JobInfoSecretResolver); ok {
+		scoped.SetJobInfo(r.jobInfo)
+	}
+
+	return sr, err
+}
+
 func newSecretsResolver(l logger, registry SecretResolverRegistry, featureFlagOn func(string) bool) (SecretsResolver, error) {
 	if l == nil {
 		return nil, ErrMissingLogger
@@ -150,6 +150,69 @@ func (i *Inputs) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MaskSensitive returns a copy of the inputs in which the value of the
+// sensitive inputs is replaced with the mask string.
+func (i Inputs) MaskSensitive(mask string) (Inputs, error) {
+	inputs, err := i.JobInputs()
+	if err != nil {
+		return Inputs{}, err
+	}
+
+	for idx, input := range inputs {
+		if input.Value.Sensitive {
+			inputs[idx].Value.Type = JobInputContentTypeNameString
+			inputs[idx].Value.Content = value.String(mask)
+		}
+	}
+
+	masked, err := NewJobInputs(inputs)
+	if err != nil {
+		return Inputs{}, err
+	}
+	masked.metricsCollector = i.metricsCollector
+
+	return masked, nil
+}
+
+// JobInputs returns the inputs the way GitLab sends them.
+func (i Inputs) JobInputs() ([]JobInput, error) {
+	inputs := make([]JobInput, 0, len(i.inputs))
+	for _, input := range i.inputs {
+		typ, err := jobInputContentTypeName(input.Value.Kind())
+		if err != nil {
+			return nil, fmt.Errorf("input %q: %w", input.Key, err)
+		}
+
+		inputs = append(inputs, JobInput{
+			Key: input.Key,
+			Value: JobInputValue{
+				Type:      typ,
+				Content:   input.Value,
+				Sensitive: input.Value.HasMarks(expression.Sensitive),
+			},
+		})
+	}
+
+	return inputs, nil
+}
+
+func jobInputContentTypeName(kind value.Kind) (JobInputContentTypeName, error) {
+	switch kind {
+	case value.StringKind:
+		return JobInputContentTypeNameString, nil
+	case value.NumberKind:
+		return JobInputContentTypeNameNumber, nil
+	case value.BoolKind:
+		return JobInputContentTypeNameBoolean, nil
+	case value.ArrayKind:
+		return JobInputContentTypeNameArray, nil
+	case value.ObjectKind:
+		return JobInputContentTypeNameStruct, nil
+	default:
+		return "", fmt.Errorf("unsupported kind %v", kind)
+	}
+}
+
 func NewJobInputs(inputs []JobInput) (Inputs, error) {
 	i := Inputs{}
 
@@ -144,6 +144,44 @@ func TestJobInputs_Unmarshalling_Sensitive(t *testing.T) {
 	assert.True(t, inputs.inputs[2].Sensitive())
 }
 
+func TestJobInputs_JobInputs(t *testing.T) {
+	t.Parallel()
+
+	inputs := Inputs{}
+	require.NoError(t, json.Unmarshal([]byte(complexExampleInputs), &inputs))
+
+	jobInputs, err := inputs.JobInputs()
+	require.NoError(t, err)
+
+	data, err := json.Marshal(jobInputs)
+	require.NoError(t, err)
+	assert.JSONEq(t, complexExampleInputs, string(data))
+}
+
+func TestJobInputs_MaskSensitive(t *testing.T) {
+	t.Parallel()
+
+	inputs := Inputs{}
+	require.NoError(t, json.Unmarshal([]byte(complexExampleInputs), &inputs))
+
+	masked, err := inputs.MaskSensitive("[MASKED]")
+	require.NoError(t, err)
+	require.Equal(t, inputs.Len(), masked.Len())
+
+	password, err := masked.Attr("password")
+	require.NoError(t, err)
+	assert.Equal(t, "[MASKED]", password.String())
+	assert.True(t, masked.inputs[2].Sensitive())
+
+	username, err := masked.Attr("username")
+	require.NoError(t, err)
+	assert.Equal(t, "fred", username.String())
+
+	original, err := inputs.Attr("password")
+	require.NoError(t, err)
+	assert.Equal(t, "123456", original.String())
+}
+
 func TestJobInputs_Unmarshalling_Error(t *testing.T) {
 	t.Parallel()
 
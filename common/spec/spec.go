@@ -644,6 +644,7 @@ type Secret struct {
 	AzureKeyVault        *AzureKeyVaultSecret        `json:"azure_key_vault,omitempty"`
 	AWSSecretsManager    *AWSSecret                  `json:"aws_secrets_manager,omitempty"`
 	GitLabSecretsManager *GitLabSecretsManagerSecret `json:"gitlab_secrets_manager,omitempty"`
+	EncryptedFile        *EncryptedFileSecret        `json:"encrypted_file,omitempty"`
 	File                 *bool                       `json:"file,omitempty"`
 }
 
@@ -666,6 +667,9 @@ func (s Secret) ExpandVariables(vars Variables) {
 	if s.AWSSecretsManager != nil {
 		s.AWSSecretsManager.expandVariables(vars)
 	}
+	if s.EncryptedFile != nil {
+		s.EncryptedFile.expandVariables(vars)
+	}
 	// NOTE: GitLab Secrets Manager doesn't support variable expansion
 	// The only user input from the CI config is the secret name which Rails
 	// transforms into the path. Everything else is generated internally by Rails.
@@ -903,6 +907,18 @@ type GitLabSecretsManagerEngine struct {
 	Path string `json:"path"`
 }
 
+// EncryptedFileSecret references a value of the age or SOPS encrypted file
+// configured for the runner in [runners.encrypted_secrets].
+type EncryptedFileSecret struct {
+	// Key is a top-level key of the file, or a path of keys separated by
+	// dots, like database.password.
+	Key string `json:"key"`
+}
+
+func (s *EncryptedFileSecret) expandVariables(vars Variables) {
+	s.Key = vars.ExpandValue(s.Key)
+}
+
 func (j *Job) RepoCleanURL() string {
 	return url_helpers.CleanURL(j.GitInfo.RepoURL)
 }
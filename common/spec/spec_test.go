@@ -3,9 +3,12 @@
 package spec
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/step-runner/schema/v1"
 )
 
 func TestStepWhen_OnSuccessOnFailure(t *testing.T) {
@@ -85,3 +88,27 @@ func Test_Image_ExecutorOptions_GetUIDGID(t *testing.T) {
 		})
 	}
 }
+
+func TestJob_JSONWireFormat(t *testing.T) {
+	payload := `{
+		"id": 1,
+		"run": "[{\"name\":\"hello\",\"script\":\"echo hello\"}]",
+		"inputs": [{"key": "username", "value": {"type": "string", "content": "fred"}}]
+	}`
+
+	var job Job
+	require.NoError(t, json.Unmarshal([]byte(payload), &job))
+	require.Len(t, job.Run, 1)
+	require.Equal(t, 1, job.Inputs.Len())
+
+	data, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	var encoded map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &encoded))
+	require.JSONEq(t, `{}`, string(encoded["inputs"]), "inputs aren't encoded")
+
+	var decoded []schema.Step
+	require.NoError(t, json.Unmarshal(encoded["run"], &decoded), "steps are encoded as an array")
+	require.Equal(t, []schema.Step(job.Run), decoded)
+}
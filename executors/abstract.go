@@ -20,6 +20,12 @@ type ExecutorOptions struct {
 	ShowHostname                  bool
 }
 
+// ExecutorOptionsGetter is implemented by the executors embedding
+// AbstractExecutor, to expose the defaults they were created with.
+type ExecutorOptionsGetter interface {
+	GetExecutorOptions() ExecutorOptions
+}
+
 type AbstractExecutor struct {
 	ExecutorOptions
 	BuildLogger  buildlogger.Logger
@@ -108,6 +114,10 @@ func (e *AbstractExecutor) SafeDirectoryCheckout() bool {
 	return e.DefaultSafeDirectoryCheckout
 }
 
+func (e *AbstractExecutor) GetExecutorOptions() ExecutorOptions {
+	return e.ExecutorOptions
+}
+
 func (e *AbstractExecutor) Shell() *common.ShellScriptInfo {
 	return &e.ExecutorOptions.Shell
 }
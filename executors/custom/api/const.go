@@ -16,4 +16,8 @@ const (
 	// The name of the variable used to pass the value of path to the file that
 	// contains JSON encoded content of job API received from GitLab's API
 	JobResponseFileVariable = "JOB_RESPONSE_FILE"
+
+	// The name of the variable used to pass the value of path to the file that
+	// contains JSON encoded definitions of the services of the job
+	JobServicesFileVariable = "JOB_SERVICES_FILE"
 )
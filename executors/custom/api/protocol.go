@@ -0,0 +1,164 @@
+package api
+
+// Protocol versions the Runner can use to talk to the Custom Executor driver.
+const (
+	// ProtocolV1 runs config_exec, prepare_exec, one run_exec per stage and
+	// cleanup_exec, and reads the result from their exit codes.
+	ProtocolV1 = "v1"
+
+	// ProtocolV2 starts driver_exec once per job, and exchanges JSON-lines
+	// messages with it: the Runner writes requests to the standard input of
+	// the driver, and the driver writes messages to its standard output.
+	ProtocolV2 = "v2"
+)
+
+// RequestType is the type of the request sent by the Runner to the driver
+type RequestType string
+
+const (
+	// RequestConfig asks the driver for the configuration it injects into the
+	// executor. The result carries the same values as the config_exec output.
+	RequestConfig RequestType = "config"
+	// RequestPrepare asks the driver to prepare the environment of the job.
+	RequestPrepare RequestType = "prepare"
+	// RequestResume asks the driver to restore the environment of a suspended
+	// job instead of preparing a new one.
+	RequestResume RequestType = "resume"
+	// RequestServices asks the driver to start the services of the job,
+	// listed in the JOB_SERVICES_FILE file, and to expose their endpoints.
+	// It's sent after prepare and resume when the job has services.
+	RequestServices RequestType = "services"
+	// RequestRun asks the driver to run the script of a stage.
+	RequestRun RequestType = "run"
+	// RequestSuspend asks the driver to persist the environment of the job
+	// so that a later job can resume it.
+	RequestSuspend RequestType = "suspend"
+	// RequestCleanup asks the driver to clean up the environment of the job.
+	// The driver should exit once its standard input is closed.
+	RequestCleanup RequestType = "cleanup"
+	// RequestCancel asks the driver to stop handling the request with the
+	// same ID, which was canceled or timed out. The driver still sends the
+	// result of the canceled request.
+	RequestCancel RequestType = "cancel"
+)
+
+// Request is a message sent by the Runner to the driver. The Runner sends
+// one request at a time, and waits for its result before sending the next
+// one, except for cancel requests.
+type Request struct {
+	ID   int         `json:"id"`
+	Type RequestType `json:"type"`
+
+	// Run is set for run requests
+	Run *RunRequest `json:"run,omitempty"`
+
+	// Fields is set for resume requests, with the fields returned by the
+	// suspend request of the suspended job
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// RunRequest defines the script the driver runs
+type RunRequest struct {
+	// ScriptFile is the path of the script generated by the Runner
+	ScriptFile string `json:"script_file"`
+	// Stage is the name of the stage the script belongs to
+	Stage string `json:"stage"`
+}
+
+// MessageType is the type of the message sent by the driver to the Runner
+type MessageType string
+
+const (
+	// MessageLog carries output written to the job log.
+	MessageLog MessageType = "log"
+	// MessageProgress carries a progress update printed to the job log.
+	MessageProgress MessageType = "progress"
+	// MessageEndpoint exposes an endpoint of the job environment.
+	MessageEndpoint MessageType = "endpoint"
+	// MessageResult ends the request with the same ID.
+	MessageResult MessageType = "result"
+)
+
+// Message is a message sent by the driver to the Runner. Log, progress and
+// result messages carry the ID of the request they belong to.
+type Message struct {
+	ID   int         `json:"id,omitempty"`
+	Type MessageType `json:"type"`
+
+	Log      *Log      `json:"log,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+	Endpoint *Endpoint `json:"endpoint,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
+}
+
+// LogStream is the stream of the job log a log chunk is written to
+type LogStream string
+
+const (
+	LogStreamStdout LogStream = "stdout"
+	LogStreamStderr LogStream = "stderr"
+)
+
+// Log is a chunk of output of the request
+type Log struct {
+	Stream LogStream `json:"stream,omitempty"`
+	Data   string    `json:"data"`
+}
+
+// Progress is a progress update of a long-running request, like the state of
+// the virtual machine being provisioned
+type Progress struct {
+	Message string `json:"message"`
+}
+
+// EndpointKind is the kind of endpoint the driver exposes
+type EndpointKind string
+
+const (
+	// EndpointTerminal is a websocket the interactive web terminal of the
+	// job attaches to.
+	EndpointTerminal EndpointKind = "terminal"
+	// EndpointService is the host of a service of the job, named by the
+	// alias of the service.
+	EndpointService EndpointKind = "service"
+)
+
+// Endpoint is an endpoint of the job environment, reachable by the Runner
+type Endpoint struct {
+	Kind EndpointKind `json:"kind"`
+	// Name identifies the endpoint, like the alias of a service
+	Name string `json:"name,omitempty"`
+	// URL is the address of a terminal endpoint
+	URL string `json:"url,omitempty"`
+	// Host is the host name or IP address of a service endpoint, reachable
+	// by the Runner and the job
+	Host string `json:"host,omitempty"`
+
+	// Header is sent with the requests to the endpoint, like an
+	// Authorization header
+	Header map[string][]string `json:"header,omitempty"`
+	// CAPem is the PEM-encoded CA used to verify the TLS certificate of the
+	// endpoint
+	CAPem string `json:"ca_pem,omitempty"`
+	// Subprotocols are the websocket subprotocols of a terminal endpoint.
+	// They default to terminal.gitlab.com.
+	Subprotocols []string `json:"subprotocols,omitempty"`
+}
+
+// Result ends a request. A result without an error, failure reason or exit
+// code is successful.
+type Result struct {
+	// Error is the reason the request failed
+	Error string `json:"error,omitempty"`
+	// FailureReason is the failure reason reported to GitLab, like
+	// script_failure or runner_system_failure. It defaults to script_failure
+	// when ExitCode is set, and to runner_system_failure otherwise.
+	FailureReason string `json:"failure_reason,omitempty"`
+	// ExitCode is the exit code of the script of a run request
+	ExitCode int `json:"exit_code,omitempty"`
+
+	// Config is set by the result of config requests
+	Config *ConfigExecOutput `json:"config,omitempty"`
+	// Fields is set by the result of suspend requests
+	Fields map[string][]string `json:"fields,omitempty"`
+}
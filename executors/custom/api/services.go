@@ -0,0 +1,34 @@
+package api
+
+// Service defines a service of the job. The services of the job are written
+// to the file passed in the JOB_SERVICES_FILE variable.
+type Service struct {
+	// Name is the image of the service
+	Name string `json:"name"`
+	// Alias is the first alias of the service, and Aliases all of them
+	Alias   string   `json:"alias,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Command    []string `json:"command,omitempty"`
+
+	// Variables are the expanded variables of the service
+	Variables map[string]string `json:"variables,omitempty"`
+
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+// ServicePort is a port the service exposes
+type ServicePort struct {
+	Number   int    `json:"number"`
+	Protocol string `json:"protocol,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// ServicesExecOutput defines the output structure of the services_exec call.
+//
+// The driver lists the endpoints of the services it started, so that the
+// Runner can expose them to the job and to the session proxy.
+type ServicesExecOutput struct {
+	Services []Endpoint `json:"services,omitempty"`
+}
@@ -29,6 +29,7 @@ var newCommander = process.NewOSCmd
 
 type Options struct {
 	JobResponseFile   string
+	JobServicesFile   string
 	BuildExitCodeFile string
 }
 
@@ -59,6 +60,7 @@ func New(
 		api.SystemFailureExitCodeVariable: strconv.Itoa(SystemFailureExitCode),
 		api.BuildCodeFileVariable:         options.BuildExitCodeFile,
 		api.JobResponseFileVariable:       options.JobResponseFile,
+		api.JobServicesFileVariable:       options.JobServicesFile,
 	}
 
 	env := os.Environ()
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
 )
 
@@ -11,6 +12,14 @@ type config struct {
 	*common.CustomConfig
 }
 
+func (c *config) GetProtocol() string {
+	if c.Protocol == "" {
+		return api.ProtocolV1
+	}
+
+	return c.Protocol
+}
+
 func (c *config) GetConfigExecTimeout() time.Duration {
 	return getDuration(c.ConfigExecTimeout, defaultConfigExecTimeout)
 }
@@ -19,6 +28,10 @@ func (c *config) GetPrepareExecTimeout() time.Duration {
 	return getDuration(c.PrepareExecTimeout, defaultPrepareExecTimeout)
 }
 
+func (c *config) GetServicesExecTimeout() time.Duration {
+	return getDuration(c.ServicesExecTimeout, defaultServicesExecTimeout)
+}
+
 func (c *config) GetCleanupScriptTimeout() time.Duration {
 	return getDuration(c.CleanupExecTimeout, defaultCleanupExecTimeout)
 }
@@ -4,4 +4,9 @@ import "time"
 
 const defaultConfigExecTimeout = time.Hour
 const defaultPrepareExecTimeout = time.Hour
+const defaultServicesExecTimeout = time.Hour
 const defaultCleanupExecTimeout = time.Hour
+
+// driverExitTimeout is the time the v2 driver is given to exit once its
+// standard input is closed, before it's terminated
+const driverExitTimeout = time.Minute
@@ -83,10 +83,15 @@ type executor struct {
 	config            *config
 	tempDir           string
 	jobResponseFile   string
+	jobServicesFile   string
 	buildExitCodeFile string
 
 	driverInfo *api.DriverInfo
 
+	// driver is the connection to the driver process of the v2 protocol
+	driver       *driver
+	driverStderr io.WriteCloser
+
 	jobEnv map[string]string
 }
 
@@ -108,8 +113,20 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	e.jobServicesFile, err = e.createJobServicesFile()
+	if err != nil {
+		return err
+	}
+
 	e.buildExitCodeFile = filepath.Join(e.tempDir, "build_exit_code")
 
+	if e.config.GetProtocol() == api.ProtocolV2 {
+		err = e.startDriver()
+		if err != nil {
+			return err
+		}
+	}
+
 	err = e.dynamicConfig()
 	if err != nil {
 		return err
@@ -122,6 +139,19 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		return err
 	}
 
+	if e.driver != nil {
+		err = e.driverPrepare(options)
+	} else {
+		err = e.prepareExec()
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.startServices()
+}
+
+func (e *executor) prepareExec() error {
 	// nothing to do, as there's no prepare_script
 	if e.config.PrepareExec == "" {
 		return nil
@@ -152,8 +182,20 @@ func (e *executor) prepareConfig() error {
 		CustomConfig: e.Config.Custom,
 	}
 
-	if e.config.RunExec == "" {
-		return &common.BuildError{Inner: fmt.Errorf("custom executor is missing RunExec"), FailureReason: common.ConfigurationError}
+	switch e.config.GetProtocol() {
+	case api.ProtocolV1:
+		if e.config.RunExec == "" {
+			return &common.BuildError{Inner: fmt.Errorf("custom executor is missing RunExec"), FailureReason: common.ConfigurationError}
+		}
+	case api.ProtocolV2:
+		if e.config.DriverExec == "" {
+			return &common.BuildError{Inner: fmt.Errorf("custom executor is missing DriverExec"), FailureReason: common.ConfigurationError}
+		}
+	default:
+		return &common.BuildError{
+			Inner:         fmt.Errorf("custom executor protocol %q is not supported", e.config.Protocol),
+			FailureReason: common.ConfigurationError,
+		}
 	}
 
 	return nil
@@ -177,6 +219,10 @@ func (e *executor) createJobResponseFile() (string, error) {
 }
 
 func (e *executor) dynamicConfig() error {
+	if e.driver != nil {
+		return e.driverConfig()
+	}
+
 	if e.config.ConfigExec == "" {
 		return nil
 	}
@@ -242,13 +288,23 @@ func (e *executor) logStartupMessage() {
 var commandFactory = command.New
 
 func (e *executor) prepareCommand(ctx context.Context, opts prepareCommandOpts) command.Command {
+	options := command.Options{
+		JobResponseFile:   e.jobResponseFile,
+		JobServicesFile:   e.jobServicesFile,
+		BuildExitCodeFile: e.buildExitCodeFile,
+	}
+
+	return commandFactory(ctx, opts.executable, opts.args, e.commandOptions(opts.out), options)
+}
+
+func (e *executor) commandOptions(out commandOutputs) process.CommandOptions {
 	logger := common.NewProcessLoggerAdapter(e.BuildLogger)
 
 	cmdOpts := process.CommandOptions{
 		Dir:                             e.tempDir,
 		Env:                             make([]string, 0),
-		Stdout:                          opts.out.stdout,
-		Stderr:                          opts.out.stderr,
+		Stdout:                          out.stdout,
+		Stderr:                          out.stderr,
 		Logger:                          logger,
 		GracefulKillTimeout:             e.config.GetGracefulKillTimeout(),
 		ForceKillTimeout:                e.config.GetForceKillTimeout(),
@@ -266,12 +322,7 @@ func (e *executor) prepareCommand(ctx context.Context, opts prepareCommandOpts)
 		cmdOpts.Env = append(cmdOpts.Env, fmt.Sprintf("CUSTOM_ENV_%s=%s", variable.Key, variable.Value))
 	}
 
-	options := command.Options{
-		JobResponseFile:   e.jobResponseFile,
-		BuildExitCodeFile: e.buildExitCodeFile,
-	}
-
-	return commandFactory(ctx, opts.executable, opts.args, cmdOpts, options)
+	return cmdOpts
 }
 
 func (e *executor) getCIJobServicesEnv() spec.Variable {
@@ -340,6 +391,10 @@ func (e *executor) Run(cmd common.ExecutorCommand) error {
 		stage = "build_script"
 	}
 
+	if e.driver != nil {
+		return e.driverRun(cmd.Context, scriptFile, string(stage))
+	}
+
 	args := append(e.config.RunArgs, scriptFile, string(stage)) //nolint:gocritic
 
 	opts := prepareCommandOpts{
@@ -368,6 +423,11 @@ func (e *executor) Cleanup() {
 
 	defer func() { _ = os.RemoveAll(e.tempDir) }()
 
+	if e.driver != nil {
+		e.driverCleanup()
+		return
+	}
+
 	// nothing to do, as there's no cleanup_script
 	if e.config.CleanupExec == "" {
 		return
@@ -419,6 +479,8 @@ func NewProvider(runnerCommandPath string) common.ExecutorProvider {
 		features.Variables = true
 		features.Shared = true
 		features.ServiceVariables = true
+		features.Services = true
+		features.Proxy = true
 	}
 
 	return executors.DefaultExecutorProvider{
@@ -0,0 +1,341 @@
+package custom
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+)
+
+// maxDriverMessageSize is the size of the largest message the driver can send
+const maxDriverMessageSize = 4 * 1024 * 1024
+
+var errDriverExited = errors.New("driver exited")
+
+// driverProcess is the connection to the process of the v2 driver
+type driverProcess struct {
+	stdin  io.WriteCloser
+	stdout io.Reader
+
+	// stop closes the standard input of the driver, which asks it to exit,
+	// and waits for it to exit. The driver is terminated when it doesn't.
+	stop func() error
+}
+
+var driverFactory = startDriverProcess
+
+func startDriverProcess(executable string, args []string, cmdOpts process.CommandOptions, options driverOptions) (*driverProcess, error) {
+	env := append(os.Environ(),
+		"TMPDIR="+cmdOpts.Dir,
+		api.JobResponseFileVariable+"="+options.jobResponseFile,
+		api.JobServicesFileVariable+"="+options.jobServicesFile,
+	)
+	cmdOpts.Env = append(env, cmdOpts.Env...)
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating driver stdin: %w", err)
+	}
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		_ = stdinReader.Close()
+		_ = stdinWriter.Close()
+		return nil, fmt.Errorf("creating driver stdout: %w", err)
+	}
+
+	cmdOpts.Stdin = stdinReader
+	cmdOpts.Stdout = stdoutWriter
+
+	cmd := process.NewOSCmd(executable, args, cmdOpts)
+	err = cmd.Start()
+
+	// the driver holds its own copies of these
+	_ = stdinReader.Close()
+	_ = stdoutWriter.Close()
+
+	if err != nil {
+		_ = stdinWriter.Close()
+		_ = stdoutReader.Close()
+		return nil, fmt.Errorf("failed to start driver: %w", err)
+	}
+
+	waitCh := make(chan error, 1)
+	go func() { waitCh <- cmd.Wait() }()
+
+	stop := func() error {
+		defer func() { _ = stdoutReader.Close() }()
+
+		_ = stdinWriter.Close()
+
+		select {
+		case err := <-waitCh:
+			return err
+		case <-time.After(options.exitTimeout):
+			return process.NewOSKillWait(cmdOpts.Logger, cmdOpts.GracefulKillTimeout, cmdOpts.ForceKillTimeout).
+				KillAndWait(cmd, waitCh)
+		}
+	}
+
+	return &driverProcess{stdin: stdinWriter, stdout: stdoutReader, stop: stop}, nil
+}
+
+type driverOptions struct {
+	jobResponseFile string
+	jobServicesFile string
+	exitTimeout     time.Duration
+}
+
+// driver sends the requests of the v2 protocol to the driver process, and
+// dispatches the messages it sends back. Requests are sent one at a time.
+type driver struct {
+	process       *driverProcess
+	logger        *buildlogger.Logger
+	cancelTimeout time.Duration
+
+	requestLock sync.Mutex
+	encoder     *json.Encoder
+	lastID      int
+
+	lock      sync.Mutex
+	inflight  *driverRequest
+	endpoints []api.Endpoint
+
+	done    chan struct{}
+	doneErr error
+}
+
+type driverRequest struct {
+	id     int
+	out    commandOutputs
+	result chan api.Result
+}
+
+func newDriver(process *driverProcess, logger *buildlogger.Logger, cancelTimeout time.Duration) *driver {
+	d := &driver{
+		process:       process,
+		logger:        logger,
+		cancelTimeout: cancelTimeout,
+		encoder:       json.NewEncoder(process.stdin),
+		done:          make(chan struct{}),
+	}
+
+	go d.read()
+
+	return d
+}
+
+func (d *driver) read() {
+	defer close(d.done)
+
+	scanner := bufio.NewScanner(d.process.stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDriverMessageSize)
+
+	for scanner.Scan() {
+		var msg api.Message
+		err := json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			d.logger.Warningln("Invalid message from driver:", err)
+			continue
+		}
+
+		d.handle(msg)
+	}
+
+	d.doneErr = errDriverExited
+	if err := scanner.Err(); err != nil {
+		d.doneErr = fmt.Errorf("%w: reading messages: %w", errDriverExited, err)
+	}
+}
+
+func (d *driver) handle(msg api.Message) {
+	switch {
+	case msg.Type == api.MessageLog && msg.Log != nil:
+		d.writeLog(msg.ID, msg.Log)
+
+	case msg.Type == api.MessageProgress && msg.Progress != nil:
+		d.logger.Println(msg.Progress.Message)
+
+	case msg.Type == api.MessageEndpoint && msg.Endpoint != nil:
+		d.lock.Lock()
+		d.endpoints = append(d.endpoints, *msg.Endpoint)
+		d.lock.Unlock()
+
+		d.logger.Debugln("Driver exposed", msg.Endpoint.Kind, "endpoint", msg.Endpoint.Name)
+
+	case msg.Type == api.MessageResult && msg.Result != nil:
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		if d.inflight == nil || d.inflight.id != msg.ID {
+			d.logger.Warningln("Driver sent the result of unknown request", msg.ID)
+			return
+		}
+
+		d.inflight.result <- *msg.Result
+		d.inflight = nil
+
+	default:
+		d.logger.Debugln("Ignoring unknown message from driver:", msg.Type)
+	}
+}
+
+// writeLog writes the chunk to the output of the request it belongs to. The
+// lock is held while writing so that the output isn't written to once the
+// request has ended and its output is closed.
+func (d *driver) writeLog(id int, log *api.Log) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.inflight == nil || d.inflight.id != id {
+		d.logger.Debugln("Driver output outside of a request:", log.Data)
+		return
+	}
+
+	w := d.inflight.out.stdout
+	if log.Stream == api.LogStreamStderr {
+		w = d.inflight.out.stderr
+	}
+
+	_, _ = io.WriteString(w, log.Data)
+}
+
+// do sends the request to the driver and waits for its result. When ctx is
+// done, the request is canceled, and the driver is given cancelTimeout to
+// end it.
+func (d *driver) do(ctx context.Context, req api.Request, out commandOutputs) (api.Result, error) {
+	d.requestLock.Lock()
+	defer d.requestLock.Unlock()
+
+	d.lastID++
+	req.ID = d.lastID
+
+	inflight := &driverRequest{id: req.ID, out: out, result: make(chan api.Result, 1)}
+
+	d.lock.Lock()
+	d.inflight = inflight
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		if d.inflight == inflight {
+			d.inflight = nil
+		}
+		d.lock.Unlock()
+	}()
+
+	err := d.encoder.Encode(req)
+	if err != nil {
+		return api.Result{}, fmt.Errorf("sending %s request to driver: %w", req.Type, err)
+	}
+
+	select {
+	case result := <-inflight.result:
+		return result, nil
+	case <-d.done:
+		// the result may have been read right before the driver exited
+		select {
+		case result := <-inflight.result:
+			return result, nil
+		default:
+			return api.Result{}, fmt.Errorf("%s request: %w", req.Type, d.doneErr)
+		}
+	case <-ctx.Done():
+	}
+
+	canceled := fmt.Errorf("%s request: %w", req.Type, context.Cause(ctx))
+
+	err = d.encoder.Encode(api.Request{ID: req.ID, Type: api.RequestCancel})
+	if err != nil {
+		return api.Result{}, canceled
+	}
+
+	select {
+	case result := <-inflight.result:
+		if resultError(result) != nil {
+			return result, nil
+		}
+	case <-d.done:
+	case <-time.After(d.cancelTimeout):
+		d.logger.Warningln("Driver didn't end the canceled", req.Type, "request in", d.cancelTimeout)
+	}
+
+	return api.Result{}, canceled
+}
+
+// endpoint returns the last endpoint of the kind and name the driver exposed
+func (d *driver) endpoint(kind api.EndpointKind, name string) *api.Endpoint {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for i := len(d.endpoints) - 1; i >= 0; i-- {
+		if d.endpoints[i].Kind == kind && d.endpoints[i].Name == name {
+			endpoint := d.endpoints[i]
+			return &endpoint
+		}
+	}
+
+	return nil
+}
+
+// endpointsOf returns the last endpoint of each name the driver exposed of
+// the kind
+func (d *driver) endpointsOf(kind api.EndpointKind) []api.Endpoint {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var endpoints []api.Endpoint
+	for i := len(d.endpoints) - 1; i >= 0; i-- {
+		endpoint := d.endpoints[i]
+		if endpoint.Kind != kind {
+			continue
+		}
+
+		if !slices.ContainsFunc(endpoints, func(e api.Endpoint) bool { return e.Name == endpoint.Name }) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	slices.Reverse(endpoints)
+
+	return endpoints
+}
+
+func (d *driver) stop() error {
+	return d.process.stop()
+}
+
+// resultError returns the error of a failed request, with the failure reason
+// reported by the driver
+func resultError(result api.Result) error {
+	if result.Error == "" && result.FailureReason == "" && result.ExitCode == 0 {
+		return nil
+	}
+
+	reason := spec.JobFailureReason(result.FailureReason)
+	if reason == "" {
+		reason = common.RunnerSystemFailure
+		if result.ExitCode != 0 {
+			reason = common.ScriptFailure
+		}
+	}
+
+	message := result.Error
+	if message == "" {
+		message = fmt.Sprintf("exit status %d", result.ExitCode)
+	}
+
+	return &common.BuildError{Inner: errors.New(message), ExitCode: result.ExitCode, FailureReason: reason}
+}
@@ -0,0 +1,188 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+)
+
+var errSuspendNotSupported = errors.New("suspending the job environment requires the v2 protocol")
+
+// startDriver starts the driver process of the v2 protocol, which handles
+// all the requests of the job
+func (e *executor) startDriver() error {
+	e.driverStderr = e.BuildLogger.Stream(buildlogger.StreamExecutorLevel, buildlogger.Stderr)
+
+	process, err := driverFactory(
+		e.config.DriverExec,
+		e.config.DriverArgs,
+		e.commandOptions(commandOutputs{stderr: e.driverStderr}),
+		driverOptions{
+			jobResponseFile: e.jobResponseFile,
+			jobServicesFile: e.jobServicesFile,
+			exitTimeout:     driverExitTimeout,
+		},
+	)
+	if err != nil {
+		_ = e.driverStderr.Close()
+		return err
+	}
+
+	e.driver = newDriver(process, &e.BuildLogger, e.config.GetGracefulKillTimeout())
+
+	return nil
+}
+
+func (e *executor) driverRequest(ctx context.Context, req api.Request, out commandOutputs) (api.Result, error) {
+	result, err := e.driver.do(ctx, req, out)
+	if err != nil {
+		return result, err
+	}
+
+	return result, resultError(result)
+}
+
+func (e *executor) executorLevelOutputs() commandOutputs {
+	return commandOutputs{
+		stdout: e.BuildLogger.Stream(buildlogger.StreamExecutorLevel, buildlogger.Stdout),
+		stderr: e.BuildLogger.Stream(buildlogger.StreamExecutorLevel, buildlogger.Stderr),
+	}
+}
+
+func (e *executor) driverConfig() error {
+	ctx, cancelFunc := context.WithTimeout(e.Context, e.config.GetConfigExecTimeout())
+	defer cancelFunc()
+
+	out := e.executorLevelOutputs()
+	defer out.Close()
+
+	result, err := e.driverRequest(ctx, api.Request{Type: api.RequestConfig}, out)
+	if err != nil {
+		return err
+	}
+
+	if result.Config != nil {
+		(&ConfigExecOutput{ConfigExecOutput: *result.Config}).InjectInto(e)
+	}
+
+	return nil
+}
+
+// driverPrepare prepares the environment of the job, or resumes the
+// environment of the suspended job the job continues
+func (e *executor) driverPrepare(options common.ExecutorPrepareOptions) error {
+	if options.Build.RuntimeEnvironmentKey() != "" {
+		return e.prepareResume(options)
+	}
+
+	ctx, cancelFunc := context.WithTimeout(e.Context, e.config.GetPrepareExecTimeout())
+	defer cancelFunc()
+
+	out := e.executorLevelOutputs()
+	defer out.Close()
+
+	_, err := e.driverRequest(ctx, api.Request{Type: api.RequestPrepare}, out)
+	return err
+}
+
+func (e *executor) prepareResume(options common.ExecutorPrepareOptions) error {
+	envKey, err := common.ParseRuntimeEnvironmentKey(options.Build.RuntimeEnvironmentKey())
+	if err != nil {
+		return fmt.Errorf("prepareResume: parse environment key: %w", err)
+	}
+
+	if envKey.RunnerID != e.Build.Runner.ID {
+		return fmt.Errorf("prepareResume: environment key runner ID %d does not match this runner ID %d",
+			envKey.RunnerID, e.Build.Runner.ID)
+	}
+	if envKey.SystemID != e.Build.Runner.GetSystemID() {
+		return fmt.Errorf("prepareResume: environment key system ID %q does not match this system ID %q",
+			envKey.SystemID, e.Build.Runner.GetSystemID())
+	}
+
+	return e.Resume(e.Context, envKey.Fields)
+}
+
+func (e *executor) driverRun(ctx context.Context, scriptFile string, stage string) error {
+	out := commandOutputs{
+		stdout: e.BuildLogger.Stream(buildlogger.StreamWorkLevel, buildlogger.Stdout),
+		stderr: e.BuildLogger.Stream(buildlogger.StreamWorkLevel, buildlogger.Stderr),
+	}
+	defer out.Close()
+
+	_, err := e.driverRequest(ctx, api.Request{
+		Type: api.RequestRun,
+		Run:  &api.RunRequest{ScriptFile: scriptFile, Stage: stage},
+	}, out)
+
+	return err
+}
+
+// driverCleanup cleans up the environment of the job, and stops the driver
+func (e *executor) driverCleanup() {
+	defer func() { _ = e.driverStderr.Close() }()
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), e.config.GetCleanupScriptTimeout())
+	defer cancelFunc()
+
+	stdoutLogger := e.BuildLogger.WithFields(logrus.Fields{"cleanup_std": "out"})
+	stderrLogger := e.BuildLogger.WithFields(logrus.Fields{"cleanup_std": "err"})
+
+	out := commandOutputs{
+		stdout: stdoutLogger.WriterLevel(logrus.DebugLevel),
+		stderr: stderrLogger.WriterLevel(logrus.WarnLevel),
+	}
+	defer out.Close()
+
+	_, err := e.driverRequest(ctx, api.Request{Type: api.RequestCleanup}, out)
+	if err != nil {
+		e.BuildLogger.Warningln("Cleanup request failed:", err)
+	}
+
+	err = e.driver.stop()
+	if err != nil {
+		e.BuildLogger.Warningln("Driver exited:", err)
+	}
+}
+
+// Suspend asks the driver to persist the environment of the job, and returns
+// the fields it needs to resume it
+func (e *executor) Suspend(ctx context.Context) (url.Values, error) {
+	if e.driver == nil {
+		return nil, errSuspendNotSupported
+	}
+
+	out := e.executorLevelOutputs()
+	defer out.Close()
+
+	result, err := e.driverRequest(ctx, api.Request{Type: api.RequestSuspend}, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Fields, nil
+}
+
+// Resume asks the driver to restore the environment of a suspended job
+// instead of preparing a new one
+func (e *executor) Resume(ctx context.Context, fields url.Values) error {
+	if e.driver == nil {
+		return errSuspendNotSupported
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, e.config.GetPrepareExecTimeout())
+	defer cancelFunc()
+
+	out := e.executorLevelOutputs()
+	defer out.Close()
+
+	_, err := e.driverRequest(ctx, api.Request{Type: api.RequestResume, Fields: fields}, out)
+	return err
+}
@@ -0,0 +1,363 @@
+//go:build !integration
+
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+)
+
+// fakeDriver handles the requests of the driver the way a driver process
+// would, until handle returns an error
+type fakeDriver struct {
+	lock     sync.Mutex
+	requests []api.Request
+}
+
+func (f *fakeDriver) start(handle func(req api.Request, reply *json.Encoder) error) *driverProcess {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		defer stdoutWriter.Close()
+
+		decoder := json.NewDecoder(stdinReader)
+		encoder := json.NewEncoder(stdoutWriter)
+
+		for {
+			var req api.Request
+			if decoder.Decode(&req) != nil {
+				return
+			}
+
+			f.lock.Lock()
+			f.requests = append(f.requests, req)
+			f.lock.Unlock()
+
+			if handle(req, encoder) != nil {
+				return
+			}
+		}
+	}()
+
+	return &driverProcess{
+		stdin:  stdinWriter,
+		stdout: stdoutReader,
+		stop: func() error {
+			_ = stdinWriter.Close()
+			<-exited
+			return nil
+		},
+	}
+}
+
+func (f *fakeDriver) requestTypes() []api.RequestType {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var types []api.RequestType
+	for _, req := range f.requests {
+		types = append(types, req.Type)
+	}
+
+	return types
+}
+
+func newTestDriverLogger(logs *bytes.Buffer) buildlogger.Logger {
+	return buildlogger.New(&common.Trace{Writer: logs}, logrus.NewEntry(logrus.New()), buildlogger.Options{})
+}
+
+func newTestOutputs() (commandOutputs, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	return commandOutputs{stdout: buildlogger.NewNopCloser(stdout), stderr: buildlogger.NewNopCloser(stderr)}, stdout, stderr
+}
+
+func TestDriver_Do(t *testing.T) {
+	t.Run("result", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := newTestDriverLogger(&logs)
+
+		fake := new(fakeDriver)
+		d := newDriver(fake.start(func(req api.Request, reply *json.Encoder) error {
+			_ = reply.Encode(api.Message{ID: req.ID, Type: api.MessageLog, Log: &api.Log{Stream: api.LogStreamStdout, Data: "out\n"}})
+			_ = reply.Encode(api.Message{ID: req.ID, Type: api.MessageLog, Log: &api.Log{Stream: api.LogStreamStderr, Data: "err\n"}})
+			_ = reply.Encode(api.Message{ID: req.ID, Type: api.MessageProgress, Progress: &api.Progress{Message: "Booting VM"}})
+			_ = reply.Encode(api.Message{Type: api.MessageEndpoint, Endpoint: &api.Endpoint{Kind: api.EndpointTerminal, URL: "wss://vm/terminal"}})
+			return reply.Encode(api.Message{ID: req.ID, Type: api.MessageResult, Result: &api.Result{ExitCode: 3, Error: "exit status 3"}})
+		}), &logger, time.Second)
+		defer d.stop()
+
+		out, stdout, stderr := newTestOutputs()
+		result, err := d.do(t.Context(), api.Request{Type: api.RequestRun, Run: &api.RunRequest{ScriptFile: "script", Stage: "build_script"}}, out)
+		require.NoError(t, err)
+
+		assert.Equal(t, api.Result{ExitCode: 3, Error: "exit status 3"}, result)
+		assert.Equal(t, "out\n", stdout.String())
+		assert.Equal(t, "err\n", stderr.String())
+		assert.Contains(t, logs.String(), "Booting VM")
+		assert.Equal(t, &api.Endpoint{Kind: api.EndpointTerminal, URL: "wss://vm/terminal"}, d.endpoint(api.EndpointTerminal, ""))
+		assert.Nil(t, d.endpoint(api.EndpointService, "db"))
+		assert.Equal(t, []api.Request{{ID: 1, Type: api.RequestRun, Run: &api.RunRequest{ScriptFile: "script", Stage: "build_script"}}}, fake.requests)
+	})
+
+	t.Run("driver exits", func(t *testing.T) {
+		logger := newTestDriverLogger(new(bytes.Buffer))
+
+		d := newDriver(new(fakeDriver).start(func(api.Request, *json.Encoder) error {
+			return errors.New("crashed")
+		}), &logger, time.Second)
+		defer d.stop()
+
+		out, _, _ := newTestOutputs()
+		_, err := d.do(t.Context(), api.Request{Type: api.RequestPrepare}, out)
+		assert.ErrorIs(t, err, errDriverExited)
+		assert.ErrorContains(t, err, "prepare request")
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		logger := newTestDriverLogger(new(bytes.Buffer))
+
+		fake := new(fakeDriver)
+		d := newDriver(fake.start(func(req api.Request, reply *json.Encoder) error {
+			if req.Type != api.RequestCancel {
+				return nil
+			}
+
+			return reply.Encode(api.Message{ID: req.ID, Type: api.MessageResult, Result: &api.Result{Error: "script killed", FailureReason: "job_execution_timeout"}})
+		}), &logger, time.Second)
+		defer d.stop()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		out, _, _ := newTestOutputs()
+		result, err := d.do(ctx, api.Request{Type: api.RequestRun}, out)
+		require.NoError(t, err)
+
+		assert.Equal(t, "script killed", result.Error)
+		assert.Equal(t, []api.RequestType{api.RequestRun, api.RequestCancel}, fake.requestTypes())
+		assert.Equal(t, fake.requests[0].ID, fake.requests[1].ID)
+	})
+
+	t.Run("cancel timeout", func(t *testing.T) {
+		logger := newTestDriverLogger(new(bytes.Buffer))
+
+		d := newDriver(new(fakeDriver).start(func(api.Request, *json.Encoder) error {
+			return nil
+		}), &logger, 10*time.Millisecond)
+		defer d.stop()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		out, _, _ := newTestOutputs()
+		_, err := d.do(ctx, api.Request{Type: api.RequestRun}, out)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStartDriverProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the driver is a shell script")
+	}
+
+	script := `while read -r request; do echo '{"id":1,"type":"result","result":{}}'; done`
+
+	proc, err := startDriverProcess("sh", []string{"-c", script}, process.CommandOptions{
+		Dir:    t.TempDir(),
+		Logger: common.NewProcessLoggerAdapter(newTestDriverLogger(new(bytes.Buffer))),
+	}, driverOptions{exitTimeout: time.Minute})
+	require.NoError(t, err)
+
+	logger := newTestDriverLogger(new(bytes.Buffer))
+	d := newDriver(proc, &logger, time.Second)
+
+	out, _, _ := newTestOutputs()
+	result, err := d.do(t.Context(), api.Request{Type: api.RequestPrepare}, out)
+	require.NoError(t, err)
+	assert.Equal(t, api.Result{}, result)
+
+	// closing the standard input ends the read loop of the driver
+	assert.NoError(t, d.stop())
+	<-d.done
+}
+
+func TestResultError(t *testing.T) {
+	tests := map[string]struct {
+		result         api.Result
+		expectedError  string
+		expectedReason spec.JobFailureReason
+		expectedCode   int
+	}{
+		"success": {},
+		"script failure": {
+			result:         api.Result{ExitCode: 42},
+			expectedError:  "exit status 42",
+			expectedReason: common.ScriptFailure,
+			expectedCode:   42,
+		},
+		"system failure": {
+			result:         api.Result{Error: "VM quota exceeded"},
+			expectedError:  "VM quota exceeded",
+			expectedReason: common.RunnerSystemFailure,
+		},
+		"failure reason": {
+			result:         api.Result{Error: "VM ran out of memory", FailureReason: "out_of_memory_failure", ExitCode: 137},
+			expectedError:  "VM ran out of memory",
+			expectedReason: common.OutOfMemoryFailure,
+			expectedCode:   137,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := resultError(tt.result)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.EqualError(t, err, tt.expectedError)
+			assert.Equal(t, tt.expectedReason, buildErr.FailureReason)
+			assert.Equal(t, tt.expectedCode, buildErr.ExitCode)
+		})
+	}
+}
+
+func TestExecutor_DriverProtocol(t *testing.T) {
+	fake := new(fakeDriver)
+
+	oldFactory := driverFactory
+	driverFactory = func(executable string, args []string, cmdOpts process.CommandOptions, options driverOptions) (*driverProcess, error) {
+		assert.Equal(t, "/usr/local/bin/vm-driver", executable)
+		assert.Equal(t, []string{"--pool", "ci"}, args)
+		assert.NotEmpty(t, options.jobResponseFile)
+
+		return fake.start(func(req api.Request, reply *json.Encoder) error {
+			result := api.Result{}
+
+			switch req.Type {
+			case api.RequestConfig:
+				hostname := "vm-1"
+				result.Config = &api.ConfigExecOutput{Hostname: &hostname}
+			case api.RequestRun:
+				_ = reply.Encode(api.Message{ID: req.ID, Type: api.MessageLog, Log: &api.Log{Data: "running " + req.Run.Stage + "\n"}})
+			case api.RequestSuspend:
+				result.Fields = map[string][]string{"vm": {"vm-1"}}
+			}
+
+			return reply.Encode(api.Message{ID: req.ID, Type: api.MessageResult, Result: &result})
+		}), nil
+	}
+	t.Cleanup(func() { driverFactory = oldFactory })
+
+	config := getRunnerConfig(&common.CustomConfig{
+		Protocol:   api.ProtocolV2,
+		DriverExec: "/usr/local/bin/vm-driver",
+		DriverArgs: []string{"--pool", "ci"},
+	})
+
+	var logs bytes.Buffer
+	e := new(executor)
+	err := e.Prepare(common.ExecutorPrepareOptions{
+		Config:      &config,
+		Build:       &common.Build{Job: spec.Job{ID: jobID()}, Runner: &config},
+		Context:     t.Context(),
+		BuildLogger: newTestDriverLogger(&logs),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "vm-1", e.Build.Hostname)
+
+	err = e.Run(common.ExecutorCommand{Context: t.Context(), Script: "echo", Stage: "step_script"})
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "running build_script")
+
+	fields, err := e.Suspend(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"vm": {"vm-1"}}, fields)
+
+	e.Cleanup()
+
+	assert.Equal(t, []api.RequestType{
+		api.RequestConfig,
+		api.RequestPrepare,
+		api.RequestRun,
+		api.RequestSuspend,
+		api.RequestCleanup,
+	}, fake.requestTypes())
+}
+
+func TestExecutor_PrepareConfigProtocol(t *testing.T) {
+	tests := map[string]struct {
+		config        common.CustomConfig
+		expectedError string
+	}{
+		"v1": {
+			config: common.CustomConfig{RunExec: "run"},
+		},
+		"v1 missing RunExec": {
+			config:        common.CustomConfig{DriverExec: "driver"},
+			expectedError: "custom executor is missing RunExec",
+		},
+		"v2": {
+			config: common.CustomConfig{Protocol: api.ProtocolV2, DriverExec: "driver"},
+		},
+		"v2 missing DriverExec": {
+			config:        common.CustomConfig{Protocol: api.ProtocolV2, RunExec: "run"},
+			expectedError: "custom executor is missing DriverExec",
+		},
+		"unknown protocol": {
+			config:        common.CustomConfig{Protocol: "v3"},
+			expectedError: `custom executor protocol "v3" is not supported`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := new(executor)
+			e.Config = getRunnerConfig(&tt.config)
+
+			err := e.prepareConfig()
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.EqualError(t, err, tt.expectedError)
+			assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+		})
+	}
+}
+
+func TestExecutor_SuspendRequiresDriver(t *testing.T) {
+	e := new(executor)
+
+	_, err := e.Suspend(t.Context())
+	assert.ErrorIs(t, err, errSuspendNotSupported)
+	assert.ErrorIs(t, e.Resume(t.Context(), nil), errSuspendNotSupported)
+}
@@ -0,0 +1,273 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
+)
+
+func (e *executor) createJobServicesFile() (string, error) {
+	servicesFile := filepath.Join(e.tempDir, "services.json")
+	file, err := os.OpenFile(servicesFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("creating job services file %q: %w", servicesFile, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	err = json.NewEncoder(file).Encode(e.jobServices())
+	if err != nil {
+		return "", fmt.Errorf("encoding job services file: %w", err)
+	}
+
+	return servicesFile, nil
+}
+
+// jobServices returns the resolved definitions of the services of the job
+func (e *executor) jobServices() []api.Service {
+	buildVars := e.Build.GetAllVariables()
+
+	services := make([]api.Service, 0, len(e.Build.Services))
+	for _, service := range e.Build.Services {
+		var variables map[string]string
+		if len(service.Variables) > 0 {
+			expanded := append(slices.Clone(buildVars), service.Variables...).Expand()
+
+			variables = make(map[string]string, len(service.Variables))
+			for _, v := range service.Variables {
+				variables[v.Key] = expanded.Value(v.Key)
+			}
+		}
+
+		var ports []api.ServicePort
+		for _, port := range service.Ports {
+			ports = append(ports, api.ServicePort{Number: port.Number, Protocol: port.Protocol, Name: port.Name})
+		}
+
+		aliases := service.Aliases()
+		services = append(services, api.Service{
+			Name:       service.Name,
+			Alias:      append(aliases, "")[0],
+			Aliases:    aliases,
+			Entrypoint: service.Entrypoint,
+			Command:    service.Command,
+			Variables:  variables,
+			Ports:      ports,
+		})
+	}
+
+	return services
+}
+
+// startServices asks the driver to start the services of the job, and
+// exposes the endpoints it reports
+func (e *executor) startServices() error {
+	if len(e.Build.Services) == 0 {
+		return nil
+	}
+
+	var endpoints []api.Endpoint
+	var err error
+	if e.driver != nil {
+		endpoints, err = e.driverServices()
+	} else {
+		endpoints, err = e.servicesExec()
+	}
+	if err != nil {
+		return err
+	}
+
+	e.exposeServices(endpoints)
+
+	return nil
+}
+
+func (e *executor) servicesExec() ([]api.Endpoint, error) {
+	// nothing to do, as there's no services_exec
+	if e.config.ServicesExec == "" {
+		return nil, nil
+	}
+
+	ctx, cancelFunc := context.WithTimeout(e.Context, e.config.GetServicesExecTimeout())
+	defer cancelFunc()
+
+	buf := bytes.NewBuffer(nil)
+
+	opts := prepareCommandOpts{
+		executable: e.config.ServicesExec,
+		args:       e.config.ServicesArgs,
+		out: commandOutputs{
+			stdout: buildlogger.NewNopCloser(buf),
+			stderr: e.BuildLogger.Stream(buildlogger.StreamExecutorLevel, buildlogger.Stderr),
+		},
+	}
+	defer opts.out.Close()
+
+	err := e.prepareCommand(ctx, opts).Run()
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.Len() < 1 {
+		return nil, nil
+	}
+
+	var output api.ServicesExecOutput
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing services JSON output: %w", err)
+	}
+
+	return output.Services, nil
+}
+
+func (e *executor) driverServices() ([]api.Endpoint, error) {
+	ctx, cancelFunc := context.WithTimeout(e.Context, e.config.GetServicesExecTimeout())
+	defer cancelFunc()
+
+	out := e.executorLevelOutputs()
+	defer out.Close()
+
+	_, err := e.driverRequest(ctx, api.Request{Type: api.RequestServices}, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.driver.endpointsOf(api.EndpointService), nil
+}
+
+// exposeServices adds a variable with the host of each service, and a proxy
+// for the services with ports to the session server
+func (e *executor) exposeServices(endpoints []api.Endpoint) {
+	if len(endpoints) == 0 {
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Name == "" || endpoint.Host == "" {
+			e.BuildLogger.Warningln("Ignoring service endpoint without alias or host:", endpoint.Name, endpoint.Host)
+			continue
+		}
+
+		e.Build.Variables = append(e.Build.Variables, spec.Variable{
+			Key:      serviceHostVariable(endpoint.Name),
+			Value:    endpoint.Host,
+			Public:   true,
+			Internal: true,
+		})
+
+		if ports := e.servicePorts(endpoint.Name); len(ports) > 0 {
+			e.ProxyPool[endpoint.Name] = &proxy.Proxy{
+				Settings:          proxy.NewProxySettings(endpoint.Name, ports),
+				ConnectionHandler: &serviceProxy{host: endpoint.Host},
+			}
+		}
+
+		e.BuildLogger.Println(fmt.Sprintf("Service %s is reachable at %s", endpoint.Name, endpoint.Host))
+	}
+
+	e.Build.RefreshAllVariables()
+}
+
+// servicePorts returns the ports of the service with the alias
+func (e *executor) servicePorts(alias string) []proxy.Port {
+	for _, service := range e.Build.Services {
+		if !slices.Contains(service.Aliases(), alias) {
+			continue
+		}
+
+		ports := make([]proxy.Port, 0, len(service.Ports))
+		for _, port := range service.Ports {
+			ports = append(ports, proxy.Port{Number: port.Number, Protocol: port.Protocol, Name: port.Name})
+		}
+
+		return ports
+	}
+
+	return nil
+}
+
+// serviceHostVariable returns the name of the variable with the host of the
+// service, like CI_SERVICE_POSTGRES_DB_HOST for the postgres-db alias
+func serviceHostVariable(alias string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, alias)
+
+	return "CI_SERVICE_" + strings.ToUpper(name) + "_HOST"
+}
+
+func (e *executor) Pool() proxy.Pool {
+	return e.ProxyPool
+}
+
+// serviceProxy proxies the session server requests to a service the driver
+// exposes
+type serviceProxy struct {
+	host string
+}
+
+func (p *serviceProxy) ProxyRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	requestedURI string,
+	port string,
+	settings *proxy.Settings,
+) {
+	logger := logrus.WithFields(logrus.Fields{
+		"uri":      r.RequestURI,
+		"method":   r.Method,
+		"port":     port,
+		"settings": settings,
+	})
+
+	portSettings, err := settings.PortByNameOrNumber(port)
+	if err != nil {
+		logger.WithError(err).Errorf("port proxy %q not found", port)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	scheme, err := portSettings.Scheme()
+	if err != nil {
+		logger.WithError(err).Errorf("service proxy: error proxying request")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	target := &url.URL{Scheme: scheme, Host: net.JoinHostPort(p.host, strconv.Itoa(portSettings.Number))}
+
+	// websocket upgrades are proxied by the reverse proxy too
+	reverseProxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = "/" + requestedURI
+			pr.Out.URL.RawPath = ""
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			logger.WithError(err).Errorf("service proxy: error proxying request")
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		},
+	}
+
+	reverseProxy.ServeHTTP(w, r)
+}
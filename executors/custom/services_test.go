@@ -0,0 +1,236 @@
+//go:build !integration
+
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/command"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/process"
+	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
+)
+
+var testJobServices = spec.Services{
+	{
+		Name:       "postgres:16",
+		Alias:      "db,postgres-db",
+		Entrypoint: []string{"docker-entrypoint.sh"},
+		Command:    []string{"postgres"},
+		Variables: spec.Variables{
+			{Key: "POSTGRES_DB", Value: "$DB_NAME"},
+		},
+		Ports: []spec.Port{{Number: 5432}},
+	},
+	{
+		Name:  "registry.example.com/app:latest",
+		Alias: "app",
+		Ports: []spec.Port{{Number: 8080, Protocol: "http", Name: "web"}},
+	},
+}
+
+func newServicesTestExecutor(t *testing.T, custom *common.CustomConfig) (*executor, *bytes.Buffer) {
+	runnerConfig := getRunnerConfig(custom)
+
+	var logs bytes.Buffer
+	e := new(executor)
+	e.Config = runnerConfig
+	e.config = &config{CustomConfig: custom}
+	e.Context = t.Context()
+	e.tempDir = t.TempDir()
+	e.BuildLogger = newTestDriverLogger(&logs)
+	e.ProxyPool = proxy.NewPool()
+	e.Build = &common.Build{
+		Job: spec.Job{
+			ID:        jobID(),
+			Services:  testJobServices,
+			Variables: spec.Variables{{Key: "DB_NAME", Value: "ci"}},
+		},
+		Runner: &runnerConfig,
+	}
+
+	return e, &logs
+}
+
+func TestExecutor_CreateJobServicesFile(t *testing.T) {
+	e, _ := newServicesTestExecutor(t, &common.CustomConfig{RunExec: "run"})
+
+	servicesFile, err := e.createJobServicesFile()
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(servicesFile)
+	require.NoError(t, err)
+
+	var services []api.Service
+	require.NoError(t, json.Unmarshal(content, &services))
+
+	assert.Equal(t, []api.Service{
+		{
+			Name:       "postgres:16",
+			Alias:      "db",
+			Aliases:    []string{"db", "postgres-db"},
+			Entrypoint: []string{"docker-entrypoint.sh"},
+			Command:    []string{"postgres"},
+			Variables:  map[string]string{"POSTGRES_DB": "ci"},
+			Ports:      []api.ServicePort{{Number: 5432}},
+		},
+		{
+			Name:    "registry.example.com/app:latest",
+			Alias:   "app",
+			Aliases: []string{"app"},
+			Ports:   []api.ServicePort{{Number: 8080, Protocol: "http", Name: "web"}},
+		},
+	}, services)
+}
+
+func TestExecutor_ServicesExec(t *testing.T) {
+	e, logs := newServicesTestExecutor(t, &common.CustomConfig{
+		RunExec:      "run",
+		ServicesExec: "/usr/local/bin/start-services",
+		ServicesArgs: []string{"--network", "ci"},
+	})
+	e.jobServicesFile = "/tmp/services.json"
+
+	oldFactory := commandFactory
+	commandFactory = func(
+		ctx context.Context,
+		executable string,
+		args []string,
+		cmdOpts process.CommandOptions,
+		options command.Options,
+	) command.Command {
+		assert.Equal(t, "/usr/local/bin/start-services", executable)
+		assert.Equal(t, []string{"--network", "ci"}, args)
+		assert.Equal(t, "/tmp/services.json", options.JobServicesFile)
+
+		cmd := command.NewMockCommand(t)
+		cmd.On("Run").Run(func(mock.Arguments) {
+			_, _ = io.WriteString(cmdOpts.Stdout, `{"services":[{"name":"db","host":"10.0.0.5"},{"name":"app","host":"10.0.0.6"}]}`)
+		}).Return(nil)
+
+		return cmd
+	}
+	t.Cleanup(func() { commandFactory = oldFactory })
+
+	require.NoError(t, e.startServices())
+
+	variables := e.Build.GetAllVariables()
+	assert.Equal(t, "10.0.0.5", variables.Value("CI_SERVICE_DB_HOST"))
+	assert.Equal(t, "10.0.0.6", variables.Value("CI_SERVICE_APP_HOST"))
+	assert.Contains(t, logs.String(), "Service db is reachable at 10.0.0.5")
+
+	require.Contains(t, e.Pool(), "app")
+	assert.Equal(t, []proxy.Port{{Number: 8080, Protocol: "http", Name: "web"}}, e.Pool()["app"].Settings.Ports)
+}
+
+func TestExecutor_DriverServices(t *testing.T) {
+	e, _ := newServicesTestExecutor(t, &common.CustomConfig{Protocol: api.ProtocolV2, DriverExec: "driver"})
+
+	fake := new(fakeDriver)
+	e.driver = newDriver(fake.start(func(req api.Request, reply *json.Encoder) error {
+		if req.Type == api.RequestServices {
+			_ = reply.Encode(api.Message{Type: api.MessageEndpoint, Endpoint: &api.Endpoint{Kind: api.EndpointService, Name: "postgres-db", Host: "10.0.0.5"}})
+		}
+		return reply.Encode(api.Message{ID: req.ID, Type: api.MessageResult, Result: &api.Result{}})
+	}), &e.BuildLogger, time.Second)
+	defer e.driver.stop()
+
+	require.NoError(t, e.startServices())
+
+	assert.Equal(t, []api.RequestType{api.RequestServices}, fake.requestTypes())
+	assert.Equal(t, "10.0.0.5", e.Build.GetAllVariables().Value("CI_SERVICE_POSTGRES_DB_HOST"))
+	assert.Contains(t, e.Pool(), "postgres-db")
+}
+
+func TestExecutor_StartServicesWithoutServices(t *testing.T) {
+	e, _ := newServicesTestExecutor(t, &common.CustomConfig{RunExec: "run", ServicesExec: "/usr/local/bin/start-services"})
+	e.Build.Services = nil
+
+	// the command factory isn't mocked, as services_exec must not run
+	require.NoError(t, e.startServices())
+	assert.Empty(t, e.Pool())
+}
+
+func TestServiceHostVariable(t *testing.T) {
+	assert.Equal(t, "CI_SERVICE_DB_HOST", serviceHostVariable("db"))
+	assert.Equal(t, "CI_SERVICE_POSTGRES_DB_HOST", serviceHostVariable("postgres-db"))
+	assert.Equal(t, "CI_SERVICE_REGISTRY_EXAMPLE_COM_HOST", serviceHostVariable("registry.example.com"))
+}
+
+func TestServiceProxy_ProxyRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s", r.URL.Path, r.URL.RawQuery)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	host, port, err := net.SplitHostPort(serverURL.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	settings := proxy.NewProxySettings("app", []proxy.Port{
+		{Number: portNumber, Protocol: "http", Name: "web"},
+		{Number: 5432},
+	})
+	p := &serviceProxy{host: host}
+
+	tests := map[string]struct {
+		port         string
+		expectedCode int
+		expectedBody string
+	}{
+		"port name": {
+			port:         "web",
+			expectedCode: http.StatusOK,
+			expectedBody: "/api/status verbose=1",
+		},
+		"port number": {
+			port:         port,
+			expectedCode: http.StatusOK,
+			expectedBody: "/api/status verbose=1",
+		},
+		"unknown port": {
+			port:         "8888",
+			expectedCode: http.StatusNotFound,
+		},
+		"port without http protocol": {
+			port:         "5432",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/session/token/proxy/app/"+tt.port+"/api/status?verbose=1", nil)
+
+			p.ProxyRequest(w, r, "api/status", tt.port, settings)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
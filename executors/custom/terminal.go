@@ -4,10 +4,56 @@ package custom
 
 import (
 	"errors"
+	"net/http"
 
+	terminal "gitlab.com/gitlab-org/gitlab-terminal"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
 	terminalsession "gitlab.com/gitlab-org/gitlab-runner/session/terminal"
 )
 
 func (e *executor) TerminalConnect() (terminalsession.Conn, error) {
-	return nil, errors.New("not yet supported")
+	if e.driver == nil {
+		return nil, errors.New("not yet supported")
+	}
+
+	endpoint := e.driver.endpoint(api.EndpointTerminal, "")
+	if endpoint == nil {
+		return nil, errors.New("driver didn't expose a terminal endpoint")
+	}
+
+	subprotocols := endpoint.Subprotocols
+	if len(subprotocols) == 0 {
+		subprotocols = []string{"terminal.gitlab.com"}
+	}
+
+	settings := &terminal.TerminalSettings{
+		Subprotocols: subprotocols,
+		Url:          endpoint.URL,
+		Header:       http.Header(endpoint.Header),
+		CAPem:        endpoint.CAPem,
+	}
+
+	return terminalConn{settings: settings}, nil
+}
+
+type terminalConn struct {
+	settings *terminal.TerminalSettings
+}
+
+func (t terminalConn) Start(w http.ResponseWriter, r *http.Request, timeoutCh, disconnectCh chan error) {
+	wsProxy := terminal.NewWebSocketProxy(1) // one stopper: terminal exit handler
+
+	terminalsession.ProxyTerminal(
+		timeoutCh,
+		disconnectCh,
+		wsProxy.StopCh,
+		func() {
+			terminal.ProxyWebSocket(w, r, t.settings, wsProxy)
+		},
+	)
+}
+
+func (t terminalConn) Close() error {
+	return nil
 }
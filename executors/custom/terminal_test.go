@@ -3,9 +3,15 @@
 package custom
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/custom/api"
 )
 
 func TestExecutor_Connect(t *testing.T) {
@@ -15,3 +21,33 @@ func TestExecutor_Connect(t *testing.T) {
 	assert.Nil(t, connection)
 	assert.EqualError(t, err, "not yet supported")
 }
+
+func TestExecutor_ConnectDriverTerminal(t *testing.T) {
+	logger := newTestDriverLogger(new(bytes.Buffer))
+
+	e := new(executor)
+	e.driver = newDriver(new(fakeDriver).start(func(req api.Request, reply *json.Encoder) error {
+		_ = reply.Encode(api.Message{Type: api.MessageEndpoint, Endpoint: &api.Endpoint{
+			Kind:   api.EndpointTerminal,
+			URL:    "wss://vm-1:8443/terminal",
+			Header: map[string][]string{"Authorization": {"Bearer token"}},
+		}})
+		return reply.Encode(api.Message{ID: req.ID, Type: api.MessageResult, Result: &api.Result{}})
+	}), &logger, time.Second)
+	defer e.driver.stop()
+
+	_, err := e.TerminalConnect()
+	assert.EqualError(t, err, "driver didn't expose a terminal endpoint")
+
+	out, _, _ := newTestOutputs()
+	_, err = e.driver.do(t.Context(), api.Request{Type: api.RequestPrepare}, out)
+	require.NoError(t, err)
+
+	connection, err := e.TerminalConnect()
+	require.NoError(t, err)
+
+	settings := connection.(terminalConn).settings
+	assert.Equal(t, "wss://vm-1:8443/terminal", settings.Url)
+	assert.Equal(t, []string{"terminal.gitlab.com"}, settings.Subprotocols)
+	assert.Equal(t, "Bearer token", settings.Header.Get("Authorization"))
+}
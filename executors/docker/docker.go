@@ -63,6 +63,7 @@ const (
 	ExecutorStageCreatingServices     common.ExecutorStage = "docker_creating_services"
 	ExecutorStageCreatingUserVolumes  common.ExecutorStage = "docker_creating_user_volumes"
 	ExecutorStagePullingImage         common.ExecutorStage = "docker_pulling_image"
+	ExecutorStageTakingWarmContainers common.ExecutorStage = "docker_taking_warm_containers"
 
 	ServiceLogOutputLimit = 64 * 1024
 
@@ -124,6 +125,17 @@ type executor struct {
 
 	networkMode container.NetworkMode
 
+	// egressProxyID is the ID of the egress proxy container, when the runner
+	// has an egress policy
+	egressProxyID string
+
+	// usesWarmContainers is true when the build and helper containers, and
+	// their volumes, were taken from the warm pool
+	usesWarmContainers bool
+	// warmContainerIDs are the IDs of the containers taken from the warm
+	// pool, which don't have the job labels
+	warmContainerIDs []string
+
 	projectUniqRandomizedName string
 
 	dockerConn      *dockerConnection
@@ -284,7 +296,7 @@ func (e *executor) expandAndGetDockerImage(
 
 	dockerOptions = dockerOptions.Expand(e.Build.GetAllVariables())
 
-	image, err := e.pullManager.GetDockerImage(imageName, dockerOptions, imagePullPolicies)
+	image, err := e.getDockerImage(imageName, dockerOptions, imagePullPolicies)
 	if err != nil {
 		return nil, err
 	}
@@ -292,6 +304,18 @@ func (e *executor) expandAndGetDockerImage(
 	return image, nil
 }
 
+// getDockerImage gets the image from the pull manager, and records the time
+// it took in the job timeline.
+func (e *executor) getDockerImage(
+	imageName string,
+	dockerOptions spec.ImageDockerOptions,
+	imagePullPolicies []common.DockerPullPolicy,
+) (*image.InspectResponse, error) {
+	defer e.Build.Timeline().StartEvent(common.TimelineEventImagePull, map[string]string{"image": imageName})()
+
+	return e.pullManager.GetDockerImage(imageName, dockerOptions, imagePullPolicies)
+}
+
 func (e *executor) getHelperImage() (*image.InspectResponse, error) {
 	if imageNameFromConfig := e.ExpandValue(e.Config.Docker.HelperImage); imageNameFromConfig != "" {
 		e.BuildLogger.Debugln(
@@ -302,7 +326,7 @@ func (e *executor) getHelperImage() (*image.InspectResponse, error) {
 
 		e.BuildLogger.Println("Using helper image: ", imageNameFromConfig, " (overridden, default would be ", e.helperImageInfo, ")")
 
-		return e.pullManager.GetDockerImage(imageNameFromConfig, spec.ImageDockerOptions{}, nil)
+		return e.getDockerImage(imageNameFromConfig, spec.ImageDockerOptions{}, nil)
 	}
 
 	e.BuildLogger.Debugln(fmt.Sprintf("Looking for prebuilt image %s...", e.helperImageInfo))
@@ -321,7 +345,7 @@ func (e *executor) getHelperImage() (*image.InspectResponse, error) {
 
 	// Fall back to getting image from registry
 	e.BuildLogger.Debugln(fmt.Sprintf("Loading image form registry: %s", e.helperImageInfo))
-	return e.pullManager.GetDockerImage(e.helperImageInfo.String(), spec.ImageDockerOptions{}, nil)
+	return e.getDockerImage(e.helperImageInfo.String(), spec.ImageDockerOptions{}, nil)
 }
 
 func (e *executor) getLocalHelperImage() *image.InspectResponse {
@@ -347,7 +371,7 @@ func (e *executor) getBuildImage() (*image.InspectResponse, error) {
 	imagePullPolicies := e.Build.Image.PullPolicies
 
 	// Fetch image
-	image, err := e.pullManager.GetDockerImage(imageName, dockerOptions, imagePullPolicies)
+	image, err := e.getDockerImage(imageName, dockerOptions, imagePullPolicies)
 	if err != nil {
 		return nil, err
 	}
@@ -468,7 +492,7 @@ func (e *executor) createService(
 	dockerOptions := definition.ExecutorOptions.Docker.Expand(e.Build.GetAllVariables())
 
 	e.BuildLogger.Println("Starting service", serviceName)
-	serviceImage, err := e.pullManager.GetDockerImage(image, dockerOptions, definition.PullPolicies)
+	serviceImage, err := e.getDockerImage(image, dockerOptions, definition.PullPolicies)
 	if err != nil {
 		return nil, err
 	}
@@ -1258,9 +1282,10 @@ func (e *executor) startAndWatchContainer(ctx context.Context, id string, input
 	// from.
 	if ctx.Err() != nil {
 		_ = e.removeContainer(e.Context, id)
+		return err
 	}
 
-	return err
+	return e.diagnoseOOMKill(id, err)
 }
 
 func (e *executor) removeContainer(ctx context.Context, id string) error {
@@ -1495,9 +1520,11 @@ func (e *executor) createDependencies() error {
 		e.createNetworksManager,
 		e.createBuildNetwork,
 		e.createPullManager,
+		e.createEgressProxy,
 		e.bindDevices,
 		e.bindDeviceRequests,
 		e.createVolumesManager,
+		e.takeWarmContainers,
 		e.createVolumes,
 		e.createBuildVolume,
 		e.bootstrap,
@@ -1560,6 +1587,10 @@ func (e *executor) resumeDependencies() error {
 	}
 	e.networkMode = buildInspect.HostConfig.NetworkMode
 
+	if err := e.resumeEgressProxy(fields.egressProxyID); err != nil {
+		return err
+	}
+
 	if err := e.createVolumesManager(); err != nil {
 		return err
 	}
@@ -1574,6 +1605,9 @@ func (e *executor) resumeDependencies() error {
 	// Register containers for cleanup only after all steps succeed.
 	// On failure, the environment stays intact on the VM for retry.
 	e.temporary = append(e.temporary, buildInspect.ID, fields.helperContainerID)
+	if e.egressProxyID != "" {
+		e.temporary = append(e.temporary, e.egressProxyID)
+	}
 
 	return nil
 }
@@ -1586,6 +1620,11 @@ func (e *executor) createVolumes() error {
 		return errVolumesManagerUndefined
 	}
 
+	// the volumes of the warm pool containers were created with them
+	if e.usesWarmContainers {
+		return nil
+	}
+
 	for _, volume := range e.Config.Docker.Volumes {
 		err := e.volumesManager.Create(e.Context, volume)
 		if err != nil {
@@ -1604,6 +1643,10 @@ func (e *executor) createBuildVolume() error {
 		return errVolumesManagerUndefined
 	}
 
+	if e.usesWarmContainers {
+		return nil
+	}
+
 	jobsDir := e.Build.RootDir
 
 	var err error
@@ -1964,6 +2007,10 @@ func (e *executor) addServiceHealthCheckEnvironment(service *serviceInfo) ([]str
 		environment = append(environment, fmt.Sprintf("WAIT_FOR_SERVICE_%d_TCP_PORT=%d", port, port))
 	}
 
+	if service.Readiness.HTTPPath != "" {
+		environment = append(environment, "WAIT_FOR_SERVICE_HTTP_PATH="+service.Readiness.HTTPPath)
+	}
+
 	return environment, nil
 }
 
@@ -290,6 +290,7 @@ func (s *commandExecutor) Suspend(ctx context.Context) (url.Values, error) {
 		buildContainerID:    s.buildContainerID,
 		helperContainerID:   s.helperContainer.ID,
 		serviceContainerIDs: serviceIDs,
+		egressProxyID:       s.egressProxyID,
 	}.toValues(), nil
 }
 
@@ -474,6 +475,7 @@ func NewProvider() common.ExecutorProvider {
 			ConfigUpdater:    configUpdater,
 			DefaultShellName: options.Shell.Shell,
 		},
+		warmPools: newWarmPools(),
 	}
 }
 
@@ -0,0 +1,320 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/network_egress"
+)
+
+const (
+	labelEgressProxyType = "egress-proxy"
+
+	// egressProxyAlias is the hostname of the egress proxy on the build
+	// network
+	egressProxyAlias = "egress-proxy"
+	egressProxyPort  = 8080
+
+	// networkEgressAllowVariable is the job variable with the destinations
+	// the job adds to the egress policy, from the allowed_overrides
+	networkEgressAllowVariable = "DOCKER_NETWORK_EGRESS_ALLOW"
+)
+
+var proxyVariables = []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
+
+var noProxyVariables = []string{"NO_PROXY", "no_proxy"}
+
+// createEgressProxy starts the egress proxy of the job, when the runner has an
+// egress policy. The build network is internal, so the proxy, which is also
+// attached to the default bridge network, is the only way out of it, and only
+// connects to the destinations the policy allows.
+func (e *executor) createEgressProxy() error {
+	if e.Config.Docker.NetworkEgress == nil {
+		return nil
+	}
+
+	if err := e.verifyEgressPolicySupported(); err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+
+	allow, err := e.egressAllowlist()
+	if err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+
+	helperImage, err := e.getHelperImage()
+	if err != nil {
+		return fmt.Errorf("getting the egress proxy image: %w", err)
+	}
+
+	config := &container.Config{
+		Image: helperImage.ID,
+		Cmd: []string{
+			"gitlab-runner-helper", "egress-proxy",
+			"--listen", fmt.Sprintf(":%d", egressProxyPort),
+			"--allow", strings.Join(allow, ","),
+		},
+		Labels: e.prepareContainerLabels(map[string]string{"type": labelEgressProxyType}),
+	}
+
+	// the proxy is created on the default bridge network, which gives it
+	// the access to the outside that the build network doesn't have
+	hostConfig := &container.HostConfig{
+		RestartPolicy: neverRestartPolicy,
+		LogConfig:     e.logConfig,
+		NetworkMode:   network.NetworkDefault,
+	}
+
+	containerName := e.makeContainerName(labelEgressProxyType)
+
+	e.BuildLogger.Debugln(fmt.Sprintf("Creating egress proxy container %s...", containerName))
+	resp, err := e.dockerConn.ContainerCreate(e.Context, config, hostConfig, nil, nil, containerName)
+	if err != nil {
+		return fmt.Errorf("create egress proxy container: %w", err)
+	}
+	e.temporary = append(e.temporary, resp.ID)
+	e.egressProxyID = resp.ID
+
+	err = e.dockerConn.NetworkConnect(
+		e.Context,
+		e.networkMode.NetworkName(),
+		resp.ID,
+		&network.EndpointSettings{Aliases: []string{egressProxyAlias}},
+	)
+	if err != nil {
+		return fmt.Errorf("connect egress proxy container to the build network: %w", err)
+	}
+
+	return e.startEgressProxy(allow)
+}
+
+// resumeEgressProxy restarts the egress proxy of a resumed job.
+func (e *executor) resumeEgressProxy(id string) error {
+	if id == "" {
+		return nil
+	}
+
+	allow, err := e.egressAllowlist()
+	if err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+
+	inspect, err := e.dockerConn.ContainerInspect(e.Context, id)
+	if err != nil {
+		return fmt.Errorf("egress proxy container %s not found: %w", id, err)
+	}
+	e.egressProxyID = inspect.ID
+
+	return e.startEgressProxy(allow)
+}
+
+func (e *executor) startEgressProxy(allow []string) error {
+	e.BuildLogger.Debugln(fmt.Sprintf("Starting egress proxy container %s...", e.egressProxyID))
+	err := e.dockerConn.ContainerStart(e.Context, e.egressProxyID, client.ContainerStartOptions{})
+	if err != nil {
+		return fmt.Errorf("start egress proxy container: %w", err)
+	}
+
+	sink := &egressProxyLogWriter{logger: &e.BuildLogger}
+	if err := e.captureContainerLogs(e.Context, e.egressProxyID, labelEgressProxyType, sink); err != nil {
+		e.BuildLogger.Warningln(err.Error())
+	}
+
+	e.BuildLogger.Println("Restricting network egress to:", strings.Join(allow, ", "))
+	e.setEgressProxyVariables()
+
+	return nil
+}
+
+func (e *executor) verifyEgressPolicySupported() error {
+	if e.info.OSType == osTypeWindows {
+		return errors.New("network_egress isn't supported on Windows")
+	}
+
+	if !e.Build.IsFeatureFlagOn(featureflags.NetworkPerBuild) || e.Config.Docker.NetworkMode != "" {
+		return fmt.Errorf("network_egress requires the %s feature flag, and no network_mode", featureflags.NetworkPerBuild)
+	}
+
+	if !e.networkMode.IsUserDefined() {
+		return errors.New("network_egress requires a build network")
+	}
+
+	return nil
+}
+
+// egressAllowlist returns the destinations of the egress policy, the GitLab
+// instance and the destinations the job adds with the
+// DOCKER_NETWORK_EGRESS_ALLOW variable.
+func (e *executor) egressAllowlist() ([]string, error) {
+	policy := e.Config.Docker.NetworkEgress
+
+	allow := slices.Clone(policy.Allow)
+	for _, destination := range allow {
+		if err := network_egress.ValidateDestination(destination); err != nil {
+			return nil, fmt.Errorf("network_egress: %w", err)
+		}
+	}
+
+	// the helper clones the repository, and downloads and uploads the
+	// artifacts, from the GitLab instance
+	allow = append(allow, e.gitLabEgressDestinations()...)
+
+	overrides := strings.FieldsFunc(e.Build.GetAllVariables().Value(networkEgressAllowVariable), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+	for _, destination := range overrides {
+		if err := network_egress.ValidateDestination(destination); err != nil {
+			return nil, fmt.Errorf("%s: %w", networkEgressAllowVariable, err)
+		}
+
+		if !isAllowedEgressOverride(destination, policy.AllowedOverrides) {
+			return nil, fmt.Errorf(
+				"%s: the %q destination is not present on list of allowed network_egress overrides: %s",
+				networkEgressAllowVariable, destination, strings.Join(policy.AllowedOverrides, ", "),
+			)
+		}
+
+		allow = append(allow, destination)
+	}
+
+	return allow, nil
+}
+
+func isAllowedEgressOverride(destination string, allowedOverrides []string) bool {
+	for _, allowed := range allowedOverrides {
+		if ok, _ := doublestar.Match(allowed, destination); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gitLabEgressDestinations returns the destinations of the GitLab instance,
+// from the runner configuration only: the variables of the job, like
+// CI_SERVER_URL, can be set by the job to widen the allowlist.
+func (e *executor) gitLabEgressDestinations() []string {
+	var destinations []string
+
+	for _, rawURL := range []string{e.Config.URL, e.Config.CloneURL} {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+
+		destination := net.JoinHostPort(u.Hostname(), port)
+		if !slices.Contains(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+
+	return destinations
+}
+
+// setEgressProxyVariables adds the variables making the containers use the
+// egress proxy, except for the services, which are on the build network.
+func (e *executor) setEgressProxyVariables() {
+	proxyURL := fmt.Sprintf("http://%s:%d", egressProxyAlias, egressProxyPort)
+	noProxy := strings.Join(append([]string{"localhost", "127.0.0.1", "::1"}, e.serviceAliases()...), ",")
+
+	for _, key := range proxyVariables {
+		e.Build.Variables = append(e.Build.Variables, spec.Variable{Key: key, Value: proxyURL, Public: true, Internal: true})
+	}
+	for _, key := range noProxyVariables {
+		e.Build.Variables = append(e.Build.Variables, spec.Variable{Key: key, Value: noProxy, Public: true, Internal: true})
+	}
+
+	e.Build.RefreshAllVariables()
+}
+
+func (e *executor) serviceAliases() []string {
+	var definitions spec.Services
+	for _, service := range e.Config.Docker.GetExpandedServices(e.Build.GetAllVariables()) {
+		definitions = append(definitions, service.ToImageDefinition())
+	}
+	definitions = append(definitions, e.Build.Services...)
+
+	var aliases []string
+	for _, definition := range definitions {
+		for _, alias := range append(services.SplitNameAndVersion(definition.Name).Aliases, definition.Aliases()...) {
+			if !slices.Contains(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+
+	return aliases
+}
+
+// egressProxyLogWriter reports the connections the egress proxy denied in
+// the job log.
+type egressProxyLogWriter struct {
+	logger *buildlogger.Logger
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *egressProxyLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.logLine(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (w *egressProxyLogWriter) logLine(line string) {
+	// the lines start with the timestamp of the container logs
+	if _, denied, ok := strings.Cut(line, network_egress.DeniedLogPrefix); ok {
+		w.logger.Warningln("Network egress denied by the policy:", denied)
+		return
+	}
+
+	w.logger.Debugln("egress proxy:", line)
+}
+
+func (w *egressProxyLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.logLine(string(w.buf))
+		w.buf = nil
+	}
+
+	return nil
+}
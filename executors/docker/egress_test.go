@@ -0,0 +1,196 @@
+//go:build !integration
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+)
+
+func newEgressTestExecutor(t *testing.T, c *docker.MockClient, egress *common.DockerNetworkEgressConfig) *executor {
+	e := newTestExecutor(t, c)
+	e.Config.URL = "https://gitlab.example.com"
+	e.Config.Docker.NetworkEgress = egress
+	e.Build.Variables = append(e.Build.Variables, spec.Variable{Key: featureflags.NetworkPerBuild, Value: "true"})
+	e.networkMode = "runner-abcd-build-network"
+
+	return e
+}
+
+func TestCreateEgressProxy(t *testing.T) {
+	c := docker.NewMockClient(t)
+	e := newEgressTestExecutor(t, c, &common.DockerNetworkEgressConfig{
+		Allow: []string{"registry.npmjs.org:443", "10.0.0.0/8"},
+	})
+	e.Build.Services = spec.Services{{Name: "postgres:16", Alias: "db"}}
+
+	c.EXPECT().ImageInspectWithRaw(mock.Anything, e.helperImageInfo.String()).
+		Return(image.InspectResponse{ID: "helper-image"}, nil, nil).Once()
+	c.EXPECT().ContainerCreate(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(_ context.Context, config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ *v1.Platform, _ string) {
+			assert.Equal(t, "helper-image", config.Image)
+			assert.Equal(t, []string{
+				"gitlab-runner-helper", "egress-proxy",
+				"--listen", ":8080",
+				"--allow", "registry.npmjs.org:443,10.0.0.0/8,gitlab.example.com:443",
+			}, config.Cmd)
+			assert.Equal(t, labelEgressProxyType, config.Labels["com.gitlab.gitlab-runner.type"])
+			assert.Equal(t, container.NetworkMode(network.NetworkDefault), hostConfig.NetworkMode)
+		}).
+		Return(container.CreateResponse{ID: "proxy-id"}, nil).Once()
+	c.EXPECT().NetworkConnect(mock.Anything, "runner-abcd-build-network", "proxy-id", &network.EndpointSettings{Aliases: []string{"egress-proxy"}}).
+		Return(nil).Once()
+	c.EXPECT().ContainerStart(mock.Anything, "proxy-id", client.ContainerStartOptions{}).
+		Return(nil).Once()
+	c.EXPECT().ContainerLogs(mock.Anything, "proxy-id", mock.Anything).
+		Return(io.NopCloser(new(bytes.Buffer)), nil).Once()
+
+	require.NoError(t, e.createEgressProxy())
+
+	assert.Equal(t, "proxy-id", e.egressProxyID)
+	assert.Contains(t, e.temporary, "proxy-id")
+
+	variables := e.Build.GetAllVariables()
+	assert.Equal(t, "http://egress-proxy:8080", variables.Value("HTTPS_PROXY"))
+	assert.Equal(t, "http://egress-proxy:8080", variables.Value("http_proxy"))
+	assert.Equal(t, "localhost,127.0.0.1,::1,postgres,db", variables.Value("NO_PROXY"))
+}
+
+func TestCreateEgressProxy_WithoutPolicy(t *testing.T) {
+	e := newTestExecutor(t, docker.NewMockClient(t))
+
+	require.NoError(t, e.createEgressProxy())
+	assert.Empty(t, e.egressProxyID)
+}
+
+func TestCreateEgressProxy_Unsupported(t *testing.T) {
+	tests := map[string]func(e *executor){
+		"without network per build": func(e *executor) {
+			e.Build.Variables = nil
+		},
+		"with network_mode": func(e *executor) {
+			e.Config.Docker.NetworkMode = "host"
+		},
+		"on Windows": func(e *executor) {
+			e.info.OSType = osTypeWindows
+		},
+	}
+
+	for tn, setup := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := newEgressTestExecutor(t, docker.NewMockClient(t), &common.DockerNetworkEgressConfig{})
+			setup(e)
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, e.createEgressProxy(), &buildErr)
+			assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+		})
+	}
+}
+
+func TestEgressAllowlist(t *testing.T) {
+	tests := map[string]struct {
+		allow            []string
+		allowedOverrides []string
+		overrides        string
+		cloneURL         string
+		variables        spec.Variables
+		expected         []string
+		expectedErr      string
+	}{
+		"policy and GitLab": {
+			allow:    []string{"*.example.org"},
+			expected: []string{"*.example.org", "gitlab.example.com:443"},
+		},
+		"clone URL": {
+			cloneURL: "http://gitlab.internal:8080",
+			expected: []string{"gitlab.example.com:443", "gitlab.internal:8080"},
+		},
+		"job-defined CI_SERVER_URL": {
+			variables: spec.Variables{{Key: "CI_SERVER_URL", Value: "https://attacker.example:443"}},
+			expected:  []string{"gitlab.example.com:443"},
+		},
+		"invalid policy destination": {
+			allow:       []string{"example.org:http"},
+			expectedErr: `network_egress: invalid destination "example.org:http": invalid port "http"`,
+		},
+		"allowed overrides": {
+			allowedOverrides: []string{"*.npmjs.org:443", "10.*/*"},
+			overrides:        "registry.npmjs.org:443, 10.1.0.0/16",
+			expected:         []string{"gitlab.example.com:443", "registry.npmjs.org:443", "10.1.0.0/16"},
+		},
+		"disallowed override": {
+			allowedOverrides: []string{"*.npmjs.org:443"},
+			overrides:        "evil.example.net",
+			expectedErr: `DOCKER_NETWORK_EGRESS_ALLOW: the "evil.example.net" destination is not present ` +
+				`on list of allowed network_egress overrides: *.npmjs.org:443`,
+		},
+		"overrides without allowed overrides": {
+			overrides: "registry.npmjs.org:443",
+			expectedErr: `DOCKER_NETWORK_EGRESS_ALLOW: the "registry.npmjs.org:443" destination is not present ` +
+				`on list of allowed network_egress overrides: `,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := newEgressTestExecutor(t, docker.NewMockClient(t), &common.DockerNetworkEgressConfig{
+				Allow:            tt.allow,
+				AllowedOverrides: tt.allowedOverrides,
+			})
+			e.Config.CloneURL = tt.cloneURL
+			e.Build.Variables = append(e.Build.Variables, tt.variables...)
+			if tt.overrides != "" {
+				e.Build.Variables = append(e.Build.Variables, spec.Variable{Key: networkEgressAllowVariable, Value: tt.overrides})
+			}
+
+			allow, err := e.egressAllowlist()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, allow)
+		})
+	}
+}
+
+func TestEgressProxyLogWriter(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	buildLogger := buildlogger.New(nil, logrus.NewEntry(logger), buildlogger.Options{})
+	w := &egressProxyLogWriter{logger: &buildLogger}
+
+	_, _ = w.Write([]byte("2026-01-01T00:00:00.000000000Z egress proxy listening on :8080\n2026-01-01T00:00:01.000000000Z egress den"))
+	_, _ = w.Write([]byte("ied: CONNECT evil.example.net:443\n"))
+	require.NoError(t, w.Close())
+
+	var warnings []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{"Network egress denied by the policy: CONNECT evil.example.net:443"}, warnings)
+}
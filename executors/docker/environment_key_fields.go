@@ -11,12 +11,14 @@ const (
 	envKeyBuildContainerIDField = "build-container-id"
 	envKeyHelperIDField         = "helper-id"
 	envKeyServiceIDsField       = "service-ids"
+	envKeyEgressProxyIDField    = "egress-proxy-id"
 )
 
 type envKeyFields struct {
 	buildContainerID    string
 	helperContainerID   string
 	serviceContainerIDs []string
+	egressProxyID       string
 }
 
 func (k envKeyFields) toValues() url.Values {
@@ -27,6 +29,9 @@ func (k envKeyFields) toValues() url.Values {
 	if len(k.serviceContainerIDs) > 0 {
 		v[envKeyServiceIDsField] = []string{strings.Join(k.serviceContainerIDs, ",")}
 	}
+	if k.egressProxyID != "" {
+		v[envKeyEgressProxyIDField] = []string{k.egressProxyID}
+	}
 	return v
 }
 
@@ -34,6 +39,7 @@ func parseEnvKeyFields(fields url.Values) (envKeyFields, error) {
 	k := envKeyFields{
 		buildContainerID:  fields.Get(envKeyBuildContainerIDField),
 		helperContainerID: fields.Get(envKeyHelperIDField),
+		egressProxyID:     fields.Get(envKeyEgressProxyIDField),
 	}
 	if k.buildContainerID == "" {
 		return envKeyFields{}, fmt.Errorf("%s is required", envKeyBuildContainerIDField)
@@ -35,6 +35,14 @@ func TestEnvKeyFields_roundtrip(t *testing.T) {
 				serviceContainerIDs: []string{"svc-a", "svc-b"},
 			},
 		},
+		{
+			name: "egress proxy",
+			in: envKeyFields{
+				buildContainerID:  "build-cid",
+				helperContainerID: "helper-cid",
+				egressProxyID:     "egress-proxy-cid",
+			},
+		},
 	}
 
 	for _, tt := range tests {
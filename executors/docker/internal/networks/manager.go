@@ -75,6 +75,7 @@ func (m *manager) Create(ctx context.Context, networkMode string, enableIPv6 boo
 			Labels:     m.labeler.Labels(map[string]string{}),
 			EnableIPv6: &enableIPv6,
 			Options:    networkOptionsFromConfig(m.build.Runner.Docker),
+			Internal:   isInternalFromConfig(m.build.Runner.Docker),
 		},
 	)
 	if err != nil {
@@ -102,6 +103,13 @@ func networkOptionsFromConfig(config *common.DockerConfig) map[string]string {
 	return networkOptions
 }
 
+// isInternalFromConfig returns whether the build network is internal, with no
+// route outside of it: with an egress policy, the containers can only reach
+// the outside through the egress proxy.
+func isInternalFromConfig(config *common.DockerConfig) bool {
+	return config != nil && config.NetworkEgress != nil
+}
+
 func (m *manager) Inspect(ctx context.Context) (network.Inspect, error) {
 	if !m.perBuild {
 		return network.Inspect{}, nil
@@ -3,6 +3,7 @@
 package networks
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"testing"
@@ -274,6 +275,46 @@ func TestCreateNetworkWithCustomMTU(t *testing.T) {
 	}
 }
 
+func TestCreateNetworkInternalWithEgressPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		egress           *common.DockerNetworkEgressConfig
+		expectedInternal bool
+	}{
+		"with egress policy": {
+			egress:           &common.DockerNetworkEgressConfig{Allow: []string{"gitlab.example.com"}},
+			expectedInternal: true,
+		},
+		"without egress policy": {},
+	}
+
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			m := newDefaultManager(t)
+			m.build.Runner.Docker = &common.DockerConfig{NetworkEgress: testCase.egress}
+			m.build.Variables = append(m.build.Variables, spec.Variable{
+				Key:   featureflags.NetworkPerBuild,
+				Value: "true",
+			})
+
+			client := addClient(t, m)
+			client.EXPECT().
+				NetworkCreate(mock.Anything, mock.Anything, mock.Anything).
+				Run(func(_ context.Context, _ string, options mobyclient.NetworkCreateOptions) {
+					assert.Equal(t, testCase.expectedInternal, options.Internal)
+				}).
+				Return(mobyclient.NetworkCreateResult{ID: "test-network"}, nil).
+				Once()
+			client.EXPECT().
+				NetworkInspect(mock.Anything, "test-network").
+				Return(network.Inspect{Network: network.Network{ID: "test-network", Name: "test-network"}}, nil).
+				Once()
+
+			_, err := m.Create(t.Context(), "", false)
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestInspectNetwork(t *testing.T) {
 	networkName := "test-network"
 	testError := errors.New("failure")
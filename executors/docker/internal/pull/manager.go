@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
 	cli "github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/hashicorp/go-version"
 	"github.com/moby/moby/api/types/image"
 	"github.com/moby/moby/client"
@@ -19,7 +21,9 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker/auth"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/pull_policies"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/retry"
 )
 
@@ -52,6 +56,23 @@ type ManagerConfig struct {
 	// APIVersion is the Docker daemon's API version, already known to the
 	// executor from connecting.
 	APIVersion *version.Version
+
+	// ImageVerifier verifies the images before they're used, when the
+	// runner has an image verification policy.
+	ImageVerifier ImageVerifier
+
+	// HelperImage is the reference of the helper image the runner provides,
+	// which it loads from its own archive when available. Loaded this way,
+	// the image has no registry digest, and isn't verified.
+	HelperImage string
+}
+
+// ImageVerifier verifies the signatures and attestations of an image, given
+// the digest of its manifest in the registry, in the repository of source
+// the image was pulled from.
+type ImageVerifier interface {
+	Matches(imageName string) bool
+	VerifyFrom(ctx context.Context, logger image_verification.Logger, imageName, source, digest string, auth authn.Authenticator) error
 }
 
 type pullLogger interface {
@@ -158,6 +179,10 @@ func (m *manager) GetDockerImage(
 			continue
 		}
 
+		if err := m.verifyImage(imageName, img); err != nil {
+			return nil, err
+		}
+
 		m.markImageAsUsed(imageName, img)
 
 		return img, nil
@@ -171,6 +196,120 @@ func (m *manager) GetDockerImage(
 	)
 }
 
+// verifyImage verifies the image against the image verification policy. The
+// image is verified by the digest the daemon has for it, so that what's
+// verified is what the containers run, whichever pull policy found it, with
+// the signatures of the registry or mirror it was pulled from. The helper
+// image the runner loaded from its own archive isn't verified.
+func (m *manager) verifyImage(imageName string, img *image.InspectResponse) error {
+	verifier := m.config.ImageVerifier
+	if verifier == nil || !verifier.Matches(imageName) {
+		return nil
+	}
+
+	source, digest := imageSource(imageName, img, m.config.DockerConfig.RegistryMirrors)
+	if digest == "" && m.isProvidedHelperImage(imageName, img) {
+		m.logger.Println(fmt.Sprintf("Not verifying image %s, the helper image provided by the runner", imageName))
+		return nil
+	}
+
+	registryInfo, err := auth.Resolver{}.ConfigForImage(
+		source,
+		m.config.AuthConfig,
+		m.config.ShellUser,
+		m.config.Credentials,
+		m.logger,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = verifier.VerifyFrom(m.context, m.logger, imageName, source, digest, image_verification.Authenticator(registryInfo))
+	if err == nil {
+		return nil
+	}
+
+	if cancelErr := contextCancellationBuildError(m.context); cancelErr != nil {
+		return cancelErr
+	}
+
+	var verificationErr *image_verification.VerificationError
+	if errors.As(err, &verificationErr) {
+		return &common.BuildError{Inner: err, FailureReason: common.ImageVerificationFailure}
+	}
+
+	return &common.BuildError{Inner: err, FailureReason: common.ClassifyImagePullFailure(err.Error())}
+}
+
+// isProvidedHelperImage returns whether the image is the helper image the
+// runner provides, loaded from its archive rather than pulled from a
+// registry.
+func (m *manager) isProvidedHelperImage(imageName string, img *image.InspectResponse) bool {
+	if m.config.HelperImage == "" || len(img.RepoDigests) > 0 {
+		return false
+	}
+
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false
+	}
+
+	helper, err := reference.ParseNormalizedNamed(m.config.HelperImage)
+	if err != nil {
+		return false
+	}
+
+	return named.String() == helper.String()
+}
+
+// imageSource returns the reference of the image in the repository it was
+// pulled from, the repository of the image name or of one of the mirrors of
+// its registry, and the digest of the image there. The repository of the
+// image name is preferred, and used when the image wasn't pulled from any of
+// them, with an empty digest.
+func imageSource(imageName string, img *image.InspectResponse, registryMirrors map[string][]string) (string, string) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return imageName, ""
+	}
+
+	candidates := []string{imageName}
+	for _, candidate := range registry_mirrors.Candidates(imageName, registryMirrors) {
+		if candidate.IsMirror() {
+			candidates = append(candidates, candidate.Image)
+		}
+	}
+
+	canonical, isCanonical := named.(reference.Canonical)
+
+	for _, candidate := range candidates {
+		candidateNamed, err := reference.ParseNormalizedNamed(candidate)
+		if err != nil {
+			continue
+		}
+
+		for _, rd := range img.RepoDigests {
+			repoNamed, err := reference.ParseNormalizedNamed(rd)
+			if err != nil || repoNamed.Name() != candidateNamed.Name() {
+				continue
+			}
+
+			repoCanonical, ok := repoNamed.(reference.Canonical)
+			if !ok || (isCanonical && repoCanonical.Digest() != canonical.Digest()) {
+				continue
+			}
+
+			return candidate, repoCanonical.Digest().String()
+		}
+	}
+
+	if isCanonical {
+		return imageName, canonical.Digest().String()
+	}
+
+	return imageName, ""
+}
+
 func (m *manager) wasImageUsed(imageName, imageID string) bool {
 	m.usedImagesLock.Lock()
 	defer m.usedImagesLock.Unlock()
@@ -294,12 +433,82 @@ func (m *manager) getImageUsingPullPolicy(
 		}
 	}
 
-	authConfig, err := m.resolveAuthConfigForImage(imageName)
+	return m.pullImage(imageName, options, platform)
+}
+
+// pullImage pulls the image from the mirrors of its registry in turn, and from
+// the registry, until one of them serves it. When all of them fail, a missing
+// or denied image is reported as is, and anything else as the mirrors failing.
+func (m *manager) pullImage(
+	imageName string, options spec.ImageDockerOptions, platform *ocispec.Platform,
+) (*image.InspectResponse, error) {
+	candidates := registry_mirrors.Candidates(imageName, m.config.DockerConfig.RegistryMirrors)
+
+	var errs []error
+	missing := true
+	for _, candidate := range candidates {
+		img, err := m.pullCandidate(imageName, candidate, options, platform)
+		if err != nil {
+			if cancelErr := contextCancellationBuildError(m.context); cancelErr != nil {
+				return nil, cancelErr
+			}
+		}
+
+		registry_mirrors.RecordPull(candidate, err)
+		if err == nil || len(candidates) == 1 {
+			return img, err
+		}
+
+		m.logger.Warningln(fmt.Sprintf("Failed to pull image %q from %s: %v", candidate.Image, candidate.Mirror, err))
+		errs = append(errs, err)
+
+		var buildErr *common.BuildError
+		if !errors.As(err, &buildErr) || buildErr.FailureReason != common.ConfigurationError {
+			missing = false
+		}
+	}
+
+	if missing {
+		return nil, errs[len(errs)-1]
+	}
+
+	err := &registry_mirrors.AllFailedError{Image: imageName, Errors: errs}
+	return nil, &common.BuildError{Inner: err, FailureReason: common.ClassifyImagePullFailure(err.Error())}
+}
+
+// pullCandidate pulls the image from the candidate, with the credentials of
+// its registry. An image pulled from a mirror is tagged with its own name, so
+// that the job and the next pulls find it.
+func (m *manager) pullCandidate(
+	imageName string, candidate registry_mirrors.Candidate, options spec.ImageDockerOptions, platform *ocispec.Platform,
+) (*image.InspectResponse, error) {
+	authConfig, err := m.resolveAuthConfigForImage(candidate.Image)
 	if err != nil {
 		return nil, err
 	}
 
-	return m.pullDockerImage(imageName, options, authConfig, platform)
+	img, err := m.pullDockerImage(candidate.Image, options, authConfig, platform)
+	if err != nil || !candidate.IsMirror() {
+		return img, err
+	}
+
+	m.logger.Println(fmt.Sprintf("Pulled image %s from registry mirror %s", imageName, candidate.Mirror))
+
+	// References with a digest can't be tagged, and are found by the digest
+	if named, err := reference.ParseNormalizedNamed(imageName); err == nil {
+		if _, ok := named.(reference.Canonical); ok {
+			return img, nil
+		}
+	}
+
+	if err := m.client.ImageTag(m.context, img.ID, imageName); err != nil {
+		return nil, &common.BuildError{
+			Inner:         fmt.Errorf("tagging image %q pulled from %s: %w", imageName, candidate.Mirror, err),
+			FailureReason: common.RunnerSystemFailure,
+		}
+	}
+
+	return img, nil
 }
 
 func (m *manager) resolveAuthConfigForImage(imageName string) (*cli.AuthConfig, error) {
@@ -24,6 +24,8 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
 )
 
 func TestNewDefaultManager(t *testing.T) {
@@ -1260,3 +1262,239 @@ func TestResolveAuthConfigForImageWarnsMissingCredentialHelper(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, authConfig)
 }
+
+func TestDockerImageVerification(t *testing.T) {
+	const digest = "sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1"
+
+	verificationErr := &image_verification.VerificationError{Image: "alpine:3.20", Inner: errors.New("no signature found")}
+
+	tests := map[string]struct {
+		imageName             string
+		helperImage           string
+		registryMirrors       map[string][]string
+		repoDigests           []string
+		matches               bool
+		expectedSource        string
+		expectedDigest        string
+		verifyErr             error
+		expectedFailureReason spec.JobFailureReason
+	}{
+		"image matching no rule": {
+			imageName:   "alpine:3.20",
+			repoDigests: []string{"alpine@" + digest},
+		},
+		"verified image": {
+			imageName:      "alpine:3.20",
+			repoDigests:    []string{"registry.example.com/alpine@" + digest, "alpine@" + digest},
+			matches:        true,
+			expectedDigest: digest,
+		},
+		"verified image by digest": {
+			imageName:      "registry.example.com/alpine@" + digest,
+			matches:        true,
+			expectedDigest: digest,
+		},
+		"verified image pulled from a mirror": {
+			imageName:       "alpine:3.20",
+			registryMirrors: map[string][]string{"docker.io": {"mirror.example.com/hub"}},
+			repoDigests:     []string{"mirror.example.com/hub/library/alpine@" + digest},
+			matches:         true,
+			expectedSource:  "mirror.example.com/hub/library/alpine:3.20",
+			expectedDigest:  digest,
+		},
+		"verified image pulled from its registry and a mirror": {
+			imageName:       "alpine:3.20",
+			registryMirrors: map[string][]string{"docker.io": {"mirror.example.com/hub"}},
+			repoDigests:     []string{"mirror.example.com/hub/library/alpine@" + digest, "alpine@" + digest},
+			matches:         true,
+			expectedDigest:  digest,
+		},
+		"verified image by digest pulled from a mirror": {
+			imageName:       "alpine@" + digest,
+			registryMirrors: map[string][]string{"docker.io": {"mirror.example.com/hub"}},
+			repoDigests:     []string{"mirror.example.com/hub/library/alpine@" + digest},
+			matches:         true,
+			expectedSource:  "mirror.example.com/hub/library/alpine@" + digest,
+			expectedDigest:  digest,
+		},
+		"helper image loaded by the runner": {
+			imageName:   "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-v18.0.0",
+			helperImage: "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-v18.0.0",
+			matches:     true,
+		},
+		"helper image pulled from its registry": {
+			imageName:      "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-v18.0.0",
+			helperImage:    "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-v18.0.0",
+			repoDigests:    []string{"registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper@" + digest},
+			matches:        true,
+			expectedDigest: digest,
+		},
+		"other image without repository digest than the helper image": {
+			imageName:      "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-latest",
+			helperImage:    "registry.gitlab.com/gitlab-org/gitlab-runner/gitlab-runner-helper:x86_64-v18.0.0",
+			matches:        true,
+			expectedDigest: "",
+			verifyErr:      verificationErr,
+
+			expectedFailureReason: common.ImageVerificationFailure,
+		},
+		"image without repository digest": {
+			imageName:      "alpine:3.20",
+			repoDigests:    []string{"registry.example.com/alpine@" + digest},
+			matches:        true,
+			expectedDigest: "",
+			verifyErr:      verificationErr,
+
+			expectedFailureReason: common.ImageVerificationFailure,
+		},
+		"verification failure": {
+			imageName:      "alpine:3.20",
+			repoDigests:    []string{"docker.io/library/alpine@" + digest},
+			matches:        true,
+			expectedDigest: digest,
+			verifyErr:      verificationErr,
+
+			expectedFailureReason: common.ImageVerificationFailure,
+		},
+		"registry failure": {
+			imageName:      "alpine:3.20",
+			repoDigests:    []string{"alpine@" + digest},
+			matches:        true,
+			expectedDigest: digest,
+			verifyErr:      errors.New("fetching signatures: unexpected status code 500 Internal Server Error"),
+
+			expectedFailureReason: common.ImagePullFailure,
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			m := newDefaultTestManager(t, c, &common.DockerConfig{
+				PullPolicy:      common.StringOrArray{common.PullPolicyIfNotPresent},
+				RegistryMirrors: tc.registryMirrors,
+			})
+
+			verifier := NewMockImageVerifier(t)
+			m.config.ImageVerifier = verifier
+			m.config.HelperImage = tc.helperImage
+
+			m.logger.(*mockPullLogger).
+				On("Println", "Using docker image", "image-id", "for", tc.imageName, "with digest", mock.Anything, "...").
+				Maybe()
+
+			c.On("ImageInspectWithRaw", m.context, tc.imageName).
+				Return(image.InspectResponse{ID: "image-id", RepoDigests: tc.repoDigests}, nil, nil).
+				Once()
+
+			verifier.On("Matches", tc.imageName).Return(tc.matches).Once()
+			// the helper image loaded by the runner isn't verified
+			helperLoaded := tc.helperImage == tc.imageName && len(tc.repoDigests) == 0
+			if tc.matches && !helperLoaded {
+				expectedSource := tc.expectedSource
+				if expectedSource == "" {
+					expectedSource = tc.imageName
+				}
+
+				verifier.On("VerifyFrom", m.context, m.logger, tc.imageName, expectedSource, tc.expectedDigest, mock.Anything).
+					Return(tc.verifyErr).
+					Once()
+			}
+
+			img, err := m.GetDockerImage(tc.imageName, spec.ImageDockerOptions{}, nil)
+			if tc.expectedFailureReason == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "image-id", img.ID)
+				assert.True(t, m.wasImageUsed(tc.imageName, "image-id"))
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, tc.expectedFailureReason, buildErr.FailureReason)
+			assert.ErrorIs(t, err, tc.verifyErr)
+			assert.False(t, m.wasImageUsed(tc.imageName, "image-id"))
+		})
+	}
+}
+
+func TestDockerPullFromRegistryMirrors(t *testing.T) {
+	const (
+		imageName   = "alpine:3.20"
+		mirrorImage = "mirror.example.com/hub/library/alpine:3.20"
+	)
+
+	errUnavailable := errors.New("received unexpected HTTP status: 503 Service Unavailable")
+	errNotFound := errors.New("manifest for alpine:3.20 not found: manifest unknown: manifest unknown")
+
+	tests := map[string]struct {
+		mirrorErr             error
+		registryErr           error
+		expectedRegistryPull  bool
+		expectedFailureReason spec.JobFailureReason
+		expectedAllFailed     bool
+	}{
+		"served by the mirror": {},
+		"served by the registry": {
+			mirrorErr:            errUnavailable,
+			expectedRegistryPull: true,
+		},
+		"missing image": {
+			mirrorErr:             errNotFound,
+			registryErr:           errNotFound,
+			expectedRegistryPull:  true,
+			expectedFailureReason: common.ConfigurationError,
+		},
+		"all mirrors failed": {
+			mirrorErr:             errNotFound,
+			registryErr:           errUnavailable,
+			expectedRegistryPull:  true,
+			expectedFailureReason: common.RunnerExternalDependencyFailure,
+			expectedAllFailed:     true,
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			m := newDefaultTestManager(t, c, &common.DockerConfig{
+				RegistryMirrors: map[string][]string{"docker.io": {"https://mirror.example.com/hub"}},
+			})
+
+			c.On("ImagePullBlocking", m.context, mirrorImage, mock.AnythingOfType("client.ImagePullOptions")).
+				Return(tc.mirrorErr).
+				Once()
+			if tc.mirrorErr == nil {
+				c.On("ImageInspectWithRaw", m.context, mirrorImage).
+					Return(image.InspectResponse{ID: "image-id"}, nil, nil).
+					Once()
+				c.On("ImageTag", m.context, "image-id", imageName).Return(nil).Once()
+			}
+
+			if tc.expectedRegistryPull {
+				c.On("ImagePullBlocking", m.context, imageName, mock.AnythingOfType("client.ImagePullOptions")).
+					Return(tc.registryErr).
+					Once()
+				if tc.registryErr == nil {
+					c.On("ImageInspectWithRaw", m.context, imageName).
+						Return(image.InspectResponse{ID: "image-id"}, nil, nil).
+						Once()
+				}
+			}
+
+			img, err := m.pullImage(imageName, spec.ImageDockerOptions{}, nil)
+			if tc.expectedFailureReason == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "image-id", img.ID)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, tc.expectedFailureReason, buildErr.FailureReason)
+
+			var allFailedErr *registry_mirrors.AllFailedError
+			assert.Equal(t, tc.expectedAllFailed, errors.As(err, &allFailedErr))
+		})
+	}
+}
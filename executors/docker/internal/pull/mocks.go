@@ -5,12 +5,175 @@
 package pull
 
 import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/moby/moby/api/types/image"
 	mock "github.com/stretchr/testify/mock"
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
 )
 
+// NewMockImageVerifier creates a new instance of MockImageVerifier. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockImageVerifier(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockImageVerifier {
+	mock := &MockImageVerifier{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockImageVerifier is an autogenerated mock type for the ImageVerifier type
+type MockImageVerifier struct {
+	mock.Mock
+}
+
+type MockImageVerifier_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockImageVerifier) EXPECT() *MockImageVerifier_Expecter {
+	return &MockImageVerifier_Expecter{mock: &_m.Mock}
+}
+
+// Matches provides a mock function for the type MockImageVerifier
+func (_mock *MockImageVerifier) Matches(imageName string) bool {
+	ret := _mock.Called(imageName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Matches")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = returnFunc(imageName)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// MockImageVerifier_Matches_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Matches'
+type MockImageVerifier_Matches_Call struct {
+	*mock.Call
+}
+
+// Matches is a helper method to define mock.On call
+//   - imageName string
+func (_e *MockImageVerifier_Expecter) Matches(imageName interface{}) *MockImageVerifier_Matches_Call {
+	return &MockImageVerifier_Matches_Call{Call: _e.mock.On("Matches", imageName)}
+}
+
+func (_c *MockImageVerifier_Matches_Call) Run(run func(imageName string)) *MockImageVerifier_Matches_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockImageVerifier_Matches_Call) Return(b bool) *MockImageVerifier_Matches_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *MockImageVerifier_Matches_Call) RunAndReturn(run func(imageName string) bool) *MockImageVerifier_Matches_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// VerifyFrom provides a mock function for the type MockImageVerifier
+func (_mock *MockImageVerifier) VerifyFrom(ctx context.Context, logger image_verification.Logger, imageName string, source string, digest string, auth authn.Authenticator) error {
+	ret := _mock.Called(ctx, logger, imageName, source, digest, auth)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyFrom")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, image_verification.Logger, string, string, string, authn.Authenticator) error); ok {
+		r0 = returnFunc(ctx, logger, imageName, source, digest, auth)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockImageVerifier_VerifyFrom_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyFrom'
+type MockImageVerifier_VerifyFrom_Call struct {
+	*mock.Call
+}
+
+// VerifyFrom is a helper method to define mock.On call
+//   - ctx context.Context
+//   - logger image_verification.Logger
+//   - imageName string
+//   - source string
+//   - digest string
+//   - auth authn.Authenticator
+func (_e *MockImageVerifier_Expecter) VerifyFrom(ctx interface{}, logger interface{}, imageName interface{}, source interface{}, digest interface{}, auth interface{}) *MockImageVerifier_VerifyFrom_Call {
+	return &MockImageVerifier_VerifyFrom_Call{Call: _e.mock.On("VerifyFrom", ctx, logger, imageName, source, digest, auth)}
+}
+
+func (_c *MockImageVerifier_VerifyFrom_Call) Run(run func(ctx context.Context, logger image_verification.Logger, imageName string, source string, digest string, auth authn.Authenticator)) *MockImageVerifier_VerifyFrom_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 image_verification.Logger
+		if args[1] != nil {
+			arg1 = args[1].(image_verification.Logger)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		var arg4 string
+		if args[4] != nil {
+			arg4 = args[4].(string)
+		}
+		var arg5 authn.Authenticator
+		if args[5] != nil {
+			arg5 = args[5].(authn.Authenticator)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+			arg5,
+		)
+	})
+	return _c
+}
+
+func (_c *MockImageVerifier_VerifyFrom_Call) Return(err error) *MockImageVerifier_VerifyFrom_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockImageVerifier_VerifyFrom_Call) RunAndReturn(run func(ctx context.Context, logger image_verification.Logger, imageName string, source string, digest string, auth authn.Authenticator) error) *MockImageVerifier_VerifyFrom_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockManager creates a new instance of MockManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockManager(t interface {
@@ -0,0 +1,56 @@
+package warmpool
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	resultHit  = "hit"
+	resultMiss = "miss"
+)
+
+// Metrics of the warm pools of the runners.
+type Metrics struct {
+	requests     *prometheus.CounterVec
+	idleSets     *prometheus.GaugeVec
+	createErrors *prometheus.CounterVec
+}
+
+// NewMetrics creates a new Metrics instance.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_docker_warm_pool_requests_total",
+				Help: "Total number of jobs that requested pre-created containers from the warm pool, by result (hit, miss).",
+			},
+			[]string{"runner", "result"},
+		),
+		idleSets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gitlab_runner_docker_warm_pool_idle_sets",
+				Help: "The current number of pre-created container sets waiting for a job.",
+			},
+			[]string{"runner"},
+		),
+		createErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gitlab_runner_docker_warm_pool_create_errors_total",
+				Help: "Total number of errors creating the container sets of the warm pool.",
+			},
+			[]string{"runner"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.idleSets.Describe(ch)
+	m.createErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.idleSets.Collect(ch)
+	m.createErrors.Collect(ch)
+}
@@ -0,0 +1,433 @@
+// Package warmpool keeps sets of pre-created, not started, build and helper
+// containers, which the jobs of the docker executor use instead of creating
+// their own.
+//
+// The configuration of a container can't change after it's created, so the
+// containers of a set only have the parts of the configuration that don't
+// depend on the job: there are no job variables in their environment, no job
+// labels, and their volumes are created with the set. The jobs register the
+// Template of the containers they need on a miss, and the pool creates the
+// sets of the templates in the background, until no job has used them for the
+// idle timeout.
+package warmpool
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes/permission"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+// LabelKey is the label of the containers and volumes of the warm pool, with
+// the short token of the runner as its value.
+const LabelKey = "com.gitlab.gitlab-runner.warm_pool"
+
+const reconcileInterval = time.Minute
+
+// Container is the configuration of a container of the set.
+type Container struct {
+	Config           *container.Config
+	HostConfig       *container.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+	Platform         *v1.Platform
+}
+
+// Template is the job-independent configuration of the containers of a set.
+// The jobs with the same template can use each other's sets.
+type Template struct {
+	BuildImageID  string
+	HelperImageID string
+
+	Build  Container
+	Helper Container
+
+	// Volumes are the destinations of the volumes created for each set and
+	// mounted in both of its containers, in addition to the binds of the host
+	// configurations.
+	Volumes          []string
+	VolumeDriver     string
+	VolumeDriverOpts map[string]string
+	VolumeLabels     map[string]string
+
+	// HelperImage is used to set the permissions of the volumes.
+	HelperImage *image.InspectResponse `json:"-"`
+}
+
+// Key returns the key of the template, which changes with any part of its
+// configuration, including the images.
+func (t Template) Key() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("encoding warm pool template: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Set is a pre-created set of containers.
+type Set struct {
+	BuildID  string
+	HelperID string
+	Volumes  []string
+}
+
+type Config struct {
+	// RunnerID is the short token of the runner.
+	RunnerID    string
+	Size        int
+	IdleTimeout time.Duration
+}
+
+type entry struct {
+	template Template
+	sets     []*Set
+	lastUsed time.Time
+}
+
+// Pool is the warm pool of a runner.
+type Pool struct {
+	client  docker.Client
+	config  Config
+	logger  logrus.FieldLogger
+	metrics *Metrics
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// For testing
+	now                 func() time.Time
+	newPermissionSetter func(c docker.Client, logger logrus.FieldLogger, helperImage *image.InspectResponse) permission.Setter
+}
+
+func New(c docker.Client, config Config, logger logrus.FieldLogger, metrics *Metrics) *Pool {
+	return &Pool{
+		client:              c,
+		config:              config,
+		logger:              logger,
+		metrics:             metrics,
+		entries:             make(map[string]*entry),
+		wake:                make(chan struct{}, 1),
+		done:                make(chan struct{}),
+		now:                 time.Now,
+		newPermissionSetter: permission.NewDockerLinuxSetter,
+	}
+}
+
+// Start removes the containers and volumes the runner's previous pool left
+// behind, and starts maintaining the sets in the background.
+func (p *Pool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	go func() {
+		defer close(p.done)
+
+		p.removeStale(ctx)
+
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+
+		for {
+			p.reconcile(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops maintaining the sets, and removes the ones no job took.
+func (p *Pool) Stop(ctx context.Context) {
+	if p.cancel != nil {
+		p.cancel()
+
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+		}
+	}
+
+	p.mu.Lock()
+	var sets []*Set
+	for key, e := range p.entries {
+		sets = append(sets, e.sets...)
+		delete(p.entries, key)
+	}
+	p.mu.Unlock()
+
+	for _, set := range sets {
+		p.Remove(ctx, set)
+	}
+	p.updateIdleSets()
+}
+
+// Take returns a set of the template, or nil when the pool has none, in which
+// case the pool starts creating sets of the template. The caller owns the
+// returned set, and removes its containers and volumes.
+func (p *Pool) Take(t Template) (*Set, error) {
+	key, err := t.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &entry{template: t}
+		p.entries[key] = e
+	}
+	e.lastUsed = p.now()
+
+	var set *Set
+	if len(e.sets) > 0 {
+		set = e.sets[0]
+		e.sets = e.sets[1:]
+	}
+	p.mu.Unlock()
+
+	result := resultMiss
+	if set != nil {
+		result = resultHit
+	}
+	p.metrics.requests.WithLabelValues(p.config.RunnerID, result).Inc()
+	p.updateIdleSets()
+
+	// replenish the pool
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	return set, nil
+}
+
+// Remove removes the containers and volumes of a set.
+func (p *Pool) Remove(ctx context.Context, set *Set) {
+	for _, id := range []string{set.BuildID, set.HelperID} {
+		if id != "" {
+			p.removeContainer(ctx, id)
+		}
+	}
+
+	for _, name := range set.Volumes {
+		p.removeVolume(ctx, name)
+	}
+}
+
+func (p *Pool) removeContainer(ctx context.Context, id string) {
+	err := p.client.ContainerRemove(ctx, id, client.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+	if err != nil && !docker.IsErrNotFound(err) {
+		p.logger.WithError(err).WithField("container", id).Warn("Failed to remove warm pool container")
+	}
+}
+
+func (p *Pool) removeVolume(ctx context.Context, name string) {
+	err := p.client.VolumeRemove(ctx, name, true)
+	if err != nil && !docker.IsErrNotFound(err) {
+		p.logger.WithError(err).WithField("volume", name).Warn("Failed to remove warm pool volume")
+	}
+}
+
+func (p *Pool) reconcile(ctx context.Context) {
+	now := p.now()
+
+	p.mu.Lock()
+	var expired []*Set
+	var keys []string
+	for key, e := range p.entries {
+		if now.Sub(e.lastUsed) > p.config.IdleTimeout {
+			expired = append(expired, e.sets...)
+			delete(p.entries, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	p.mu.Unlock()
+
+	for _, set := range expired {
+		p.Remove(ctx, set)
+	}
+
+	for _, key := range keys {
+		p.fill(ctx, key)
+	}
+
+	p.updateIdleSets()
+}
+
+// fill creates the sets of the template until the pool has the configured
+// number of them, or creating one fails.
+func (p *Pool) fill(ctx context.Context, key string) {
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		e, ok := p.entries[key]
+		if !ok || len(e.sets) >= p.config.Size {
+			p.mu.Unlock()
+			return
+		}
+		t := e.template
+		p.mu.Unlock()
+
+		set, err := p.create(ctx, t)
+		if err != nil {
+			p.metrics.createErrors.WithLabelValues(p.config.RunnerID).Inc()
+			p.logger.WithError(err).Warn("Failed to create warm pool containers")
+			return
+		}
+
+		p.mu.Lock()
+		// the template may have expired in the meantime
+		if current, ok := p.entries[key]; ok && current == e {
+			e.sets = append(e.sets, set)
+			set = nil
+		}
+		p.mu.Unlock()
+
+		if set != nil {
+			p.Remove(ctx, set)
+			return
+		}
+
+		p.updateIdleSets()
+	}
+}
+
+func (p *Pool) create(ctx context.Context, t Template) (*Set, error) {
+	suffix, err := helpers.GenerateRandomUUID(8)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("runner-%s-warm-%s", p.config.RunnerID, suffix)
+
+	set := &Set{}
+	var binds []string
+
+	for _, destination := range t.Volumes {
+		sum := md5.Sum([]byte(destination))
+		v, err := p.client.VolumeCreate(ctx, client.VolumeCreateOptions{
+			Name:       fmt.Sprintf("%s-cache-%x", name, sum),
+			Driver:     t.VolumeDriver,
+			DriverOpts: t.VolumeDriverOpts,
+			Labels:     p.labels(t.VolumeLabels),
+		})
+		if err != nil {
+			p.Remove(ctx, set)
+			return nil, fmt.Errorf("creating volume: %w", err)
+		}
+		set.Volumes = append(set.Volumes, v.Name)
+
+		if t.HelperImage != nil {
+			setter := p.newPermissionSetter(p.client, p.logger, t.HelperImage)
+			if err := setter.Set(ctx, v.Name, p.labels(t.VolumeLabels)); err != nil {
+				p.Remove(ctx, set)
+				return nil, fmt.Errorf("setting volume permissions: %w", err)
+			}
+		}
+
+		binds = append(binds, v.Name+":"+destination)
+	}
+
+	set.HelperID, err = p.createContainer(ctx, t.Helper, binds, name+"-predefined")
+	if err != nil {
+		p.Remove(ctx, set)
+		return nil, err
+	}
+
+	set.BuildID, err = p.createContainer(ctx, t.Build, binds, name+"-build")
+	if err != nil {
+		p.Remove(ctx, set)
+		return nil, err
+	}
+
+	p.logger.WithField("name", name).Debug("Created warm pool containers")
+
+	return set, nil
+}
+
+func (p *Pool) createContainer(ctx context.Context, c Container, binds []string, name string) (string, error) {
+	config := *c.Config
+	config.Labels = p.labels(c.Config.Labels)
+
+	hostConfig := *c.HostConfig
+	hostConfig.Binds = append(append([]string{}, c.HostConfig.Binds...), binds...)
+
+	resp, err := p.client.ContainerCreate(ctx, &config, &hostConfig, c.NetworkingConfig, c.Platform, name)
+	if err != nil {
+		return "", fmt.Errorf("creating container %s: %w", name, err)
+	}
+
+	return resp.ID, nil
+}
+
+func (p *Pool) labels(labels map[string]string) map[string]string {
+	l := maps.Clone(labels)
+	if l == nil {
+		l = make(map[string]string)
+	}
+	l[LabelKey] = p.config.RunnerID
+
+	return l
+}
+
+// removeStale removes the containers and volumes of the runner's pool that
+// a previous run of the runner didn't.
+func (p *Pool) removeStale(ctx context.Context) {
+	filters := make(client.Filters).Add("label", LabelKey+"="+p.config.RunnerID)
+
+	containers, err := p.client.ContainerList(ctx, client.ContainerListOptions{All: true, Filters: filters})
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to list stale warm pool containers")
+		return
+	}
+
+	for _, c := range containers {
+		p.removeContainer(ctx, c.ID)
+	}
+
+	volumes, err := p.client.VolumeList(ctx, client.VolumeListOptions{Filters: filters})
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to list stale warm pool volumes")
+		return
+	}
+
+	for _, v := range volumes.Items {
+		p.removeVolume(ctx, v.Name)
+	}
+}
+
+func (p *Pool) updateIdleSets() {
+	p.mu.Lock()
+	idle := 0
+	for _, e := range p.entries {
+		idle += len(e.sets)
+	}
+	p.mu.Unlock()
+
+	p.metrics.idleSets.WithLabelValues(p.config.RunnerID).Set(float64(idle))
+}
@@ -0,0 +1,210 @@
+//go:build !integration
+
+package warmpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/api/types/volume"
+	"github.com/moby/moby/client"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes/permission"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func newTestPool(t *testing.T, c *docker.MockClient, size int) *Pool {
+	p := New(c, Config{RunnerID: "abcd1234", Size: size, IdleTimeout: time.Hour}, logrus.New(), NewMetrics())
+	p.newPermissionSetter = func(docker.Client, logrus.FieldLogger, *image.InspectResponse) permission.Setter {
+		s := permission.NewMockSetter(t)
+		s.EXPECT().Set(mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		return s
+	}
+
+	return p
+}
+
+func newTestTemplate() Template {
+	return Template{
+		BuildImageID:  "sha256:build",
+		HelperImageID: "sha256:helper",
+		Build: Container{
+			Config:     &container.Config{Image: "alpine@sha256:build", Labels: map[string]string{"type": "build"}},
+			HostConfig: &container.HostConfig{Binds: []string{"/certs:/certs:ro"}},
+		},
+		Helper: Container{
+			Config:     &container.Config{Image: "sha256:helper", Labels: map[string]string{"type": "predefined"}},
+			HostConfig: &container.HostConfig{Binds: []string{"/certs:/certs:ro"}},
+		},
+		Volumes:     []string{"/builds"},
+		HelperImage: &image.InspectResponse{ID: "sha256:helper"},
+	}
+}
+
+func expectCreateSet(t *testing.T, c *docker.MockClient, n int) {
+	c.EXPECT().VolumeCreate(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, options client.VolumeCreateOptions) (volume.Volume, error) {
+			assert.Regexp(t, `^runner-abcd1234-warm-[0-9a-f]+-cache-[0-9a-f]{32}$`, options.Name)
+			assert.Equal(t, "abcd1234", options.Labels[LabelKey])
+			return volume.Volume{Name: options.Name}, nil
+		}).Times(n)
+
+	c.EXPECT().ContainerCreate(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(func(
+			_ context.Context,
+			config *container.Config,
+			hostConfig *container.HostConfig,
+			_ *network.NetworkingConfig,
+			_ *v1.Platform,
+			name string,
+		) (container.CreateResponse, error) {
+			assert.Equal(t, "abcd1234", config.Labels[LabelKey])
+			require.Len(t, hostConfig.Binds, 2)
+			assert.Equal(t, "/certs:/certs:ro", hostConfig.Binds[0])
+			assert.Regexp(t, `^runner-abcd1234-warm-.*:/builds$`, hostConfig.Binds[1])
+			return container.CreateResponse{ID: name}, nil
+		}).Times(2 * n)
+}
+
+func TestTemplateKey(t *testing.T) {
+	template := newTestTemplate()
+	key, err := template.Key()
+	require.NoError(t, err)
+
+	other := newTestTemplate()
+	other.HelperImage = &image.InspectResponse{ID: "sha256:other"}
+	otherKey, err := other.Key()
+	require.NoError(t, err)
+	assert.Equal(t, key, otherKey, "the helper image inspect isn't part of the key")
+
+	other.BuildImageID = "sha256:new-build"
+	otherKey, err = other.Key()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+}
+
+func TestPool_TakeAndReplenish(t *testing.T) {
+	c := docker.NewMockClient(t)
+	p := newTestPool(t, c, 2)
+	template := newTestTemplate()
+
+	set, err := p.Take(template)
+	require.NoError(t, err)
+	assert.Nil(t, set)
+
+	expectCreateSet(t, c, 2)
+	p.reconcile(t.Context())
+
+	set, err = p.Take(template)
+	require.NoError(t, err)
+	require.NotNil(t, set)
+	assert.Regexp(t, `-build$`, set.BuildID)
+	assert.Regexp(t, `-predefined$`, set.HelperID)
+	assert.Len(t, set.Volumes, 1)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.requests.WithLabelValues("abcd1234", resultMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.requests.WithLabelValues("abcd1234", resultHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.idleSets.WithLabelValues("abcd1234")))
+
+	other := newTestTemplate()
+	other.BuildImageID = "sha256:new-build"
+	set, err = p.Take(other)
+	require.NoError(t, err)
+	assert.Nil(t, set, "the sets of another template aren't used")
+}
+
+func TestPool_RemovesIdleTemplates(t *testing.T) {
+	c := docker.NewMockClient(t)
+	p := newTestPool(t, c, 1)
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	_, err := p.Take(newTestTemplate())
+	require.NoError(t, err)
+
+	expectCreateSet(t, c, 1)
+	p.reconcile(t.Context())
+
+	now = now.Add(2 * time.Hour)
+	c.EXPECT().ContainerRemove(mock.Anything, mock.Anything, client.ContainerRemoveOptions{RemoveVolumes: true, Force: true}).
+		Return(nil).Twice()
+	c.EXPECT().VolumeRemove(mock.Anything, mock.Anything, true).Return(nil).Once()
+	p.reconcile(t.Context())
+
+	assert.Empty(t, p.entries)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.metrics.idleSets.WithLabelValues("abcd1234")))
+}
+
+func TestPool_CreateFailureRemovesPartialSet(t *testing.T) {
+	c := docker.NewMockClient(t)
+	p := newTestPool(t, c, 1)
+
+	_, err := p.Take(newTestTemplate())
+	require.NoError(t, err)
+
+	c.EXPECT().VolumeCreate(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, options client.VolumeCreateOptions) (volume.Volume, error) {
+			return volume.Volume{Name: options.Name}, nil
+		}).Once()
+	c.EXPECT().ContainerCreate(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(container.CreateResponse{}, errors.New("no space left on device")).Once()
+	c.EXPECT().VolumeRemove(mock.Anything, mock.Anything, true).Return(nil).Once()
+
+	p.reconcile(t.Context())
+
+	assert.Empty(t, p.entries[mustKey(t, newTestTemplate())].sets)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.createErrors.WithLabelValues("abcd1234")))
+}
+
+func TestPool_Stop(t *testing.T) {
+	c := docker.NewMockClient(t)
+	p := newTestPool(t, c, 1)
+
+	_, err := p.Take(newTestTemplate())
+	require.NoError(t, err)
+
+	expectCreateSet(t, c, 1)
+	p.reconcile(t.Context())
+
+	c.EXPECT().ContainerRemove(mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	c.EXPECT().VolumeRemove(mock.Anything, mock.Anything, true).Return(nil).Once()
+
+	p.Stop(t.Context())
+
+	assert.Empty(t, p.entries)
+}
+
+func TestPool_RemoveStale(t *testing.T) {
+	c := docker.NewMockClient(t)
+	p := newTestPool(t, c, 1)
+
+	filters := make(client.Filters).Add("label", LabelKey+"=abcd1234")
+	c.EXPECT().ContainerList(mock.Anything, client.ContainerListOptions{All: true, Filters: filters}).
+		Return([]container.Summary{{ID: "stale-build"}, {ID: "stale-predefined"}}, nil).Once()
+	c.EXPECT().ContainerRemove(mock.Anything, "stale-build", mock.Anything).Return(nil).Once()
+	c.EXPECT().ContainerRemove(mock.Anything, "stale-predefined", mock.Anything).Return(nil).Once()
+	c.EXPECT().VolumeList(mock.Anything, client.VolumeListOptions{Filters: filters}).
+		Return(client.VolumeListResult{Items: []volume.Volume{{Name: "stale-volume"}}}, nil).Once()
+	c.EXPECT().VolumeRemove(mock.Anything, "stale-volume", true).Return(nil).Once()
+
+	p.removeStale(t.Context())
+}
+
+func mustKey(t *testing.T, template Template) string {
+	key, err := template.Key()
+	require.NoError(t, err)
+
+	return key
+}
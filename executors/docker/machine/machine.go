@@ -9,14 +9,16 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
 	"gitlab.com/gitlab-org/gitlab-runner/referees"
 	"gitlab.com/gitlab-org/gitlab-runner/session/terminal"
 	"gitlab.com/gitlab-org/gitlab-runner/steps"
 )
 
 var (
-	_ terminal.InteractiveTerminal = (*machineExecutor)(nil)
-	_ steps.Connector              = (*machineExecutor)(nil)
+	_ terminal.InteractiveTerminal    = (*machineExecutor)(nil)
+	_ steps.Connector                 = (*machineExecutor)(nil)
+	_ executors.ExecutorOptionsGetter = (*machineExecutor)(nil)
 )
 
 const (
@@ -63,6 +65,21 @@ func (e *machineExecutor) Shell() *common.ShellScriptInfo {
 	return e.executor.Shell()
 }
 
+// GetExecutorOptions returns the options of the executor the jobs run with
+// on the machines
+func (e *machineExecutor) GetExecutorOptions() executors.ExecutorOptions {
+	executor := e.executor
+	if executor == nil {
+		executor = e.provider.provider.Create()
+	}
+
+	if getter, ok := executor.(executors.ExecutorOptionsGetter); ok {
+		return getter.GetExecutorOptions()
+	}
+
+	return executors.ExecutorOptions{}
+}
+
 func (e *machineExecutor) Prepare(options common.ExecutorPrepareOptions) (err error) {
 	e.build = options.Build
 
@@ -170,6 +187,24 @@ func (e *machineExecutor) GetMetricsSelector() string {
 	return refereed.GetMetricsSelector()
 }
 
+func (e *machineExecutor) SampleResourceUsage(ctx context.Context) (map[string]referees.ResourceUsage, error) {
+	refereed, ok := e.executor.(referees.ResourceUsageExecutor)
+	if !ok {
+		return nil, errors.New("executor doesn't support resource usage sampling")
+	}
+
+	return refereed.SampleResourceUsage(ctx)
+}
+
+func (e *machineExecutor) MemoryPeakUsage(ctx context.Context) (map[string]uint64, error) {
+	refereed, ok := e.executor.(referees.ResourceUsageExecutor)
+	if !ok {
+		return nil, errors.New("executor doesn't support resource usage sampling")
+	}
+
+	return refereed.MemoryPeakUsage(ctx)
+}
+
 func (s *machineExecutor) Connect(ctx context.Context) (func() (io.ReadWriteCloser, error), error) {
 	if connector, ok := s.executor.(steps.Connector); ok {
 		return connector.Connect(ctx)
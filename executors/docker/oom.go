@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-units"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// diagnoseOOMKill explains, in the job log, a failure of the job caused by
+// the container being killed for running out of memory, and returns it with
+// the out of memory failure reason.
+func (e *executor) diagnoseOOMKill(id string, err error) error {
+	var buildErr *common.BuildError
+	if !errors.As(err, &buildErr) || buildErr.ExitCode == 0 {
+		return err
+	}
+
+	inspect, inspectErr := e.dockerConn.ContainerInspect(e.Context, id)
+	if inspectErr != nil {
+		e.BuildLogger.Debugln("Inspecting container to diagnose its termination:", inspectErr)
+		return err
+	}
+
+	if inspect.State == nil || !inspect.State.OOMKilled {
+		return err
+	}
+
+	name := strings.TrimPrefix(inspect.Name, "/")
+	summary := fmt.Sprintf("Container %s was killed because it ran out of memory (OOMKilled)", name)
+	if inspect.HostConfig != nil && inspect.HostConfig.Memory > 0 {
+		summary = fmt.Sprintf(
+			"Container %s was killed because it exceeded its memory limit of %s (OOMKilled)",
+			name, units.BytesSize(float64(inspect.HostConfig.Memory)),
+		)
+	}
+	e.BuildLogger.Errorln(summary)
+
+	return &common.BuildError{
+		Inner:         fmt.Errorf("%s: %w", summary, err),
+		ExitCode:      buildErr.ExitCode,
+		FailureReason: common.OutOfMemoryFailure,
+	}
+}
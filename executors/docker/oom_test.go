@@ -0,0 +1,98 @@
+//go:build !integration
+
+package docker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func TestDiagnoseOOMKill(t *testing.T) {
+	scriptErr := &common.BuildError{
+		Inner:         errors.New("exit code 137"),
+		ExitCode:      137,
+		FailureReason: common.ScriptFailure,
+	}
+
+	tests := map[string]struct {
+		err         error
+		inspect     *container.InspectResponse
+		inspectErr  error
+		wantReason  spec.JobFailureReason
+		wantMessage string
+	}{
+		"killed with memory limit": {
+			err: scriptErr,
+			inspect: &container.InspectResponse{
+				Name:       "/runner-abcd-build",
+				State:      &container.State{OOMKilled: true},
+				HostConfig: &container.HostConfig{Resources: container.Resources{Memory: 512 * 1024 * 1024}},
+			},
+			wantReason:  common.OutOfMemoryFailure,
+			wantMessage: "Container runner-abcd-build was killed because it exceeded its memory limit of 512MiB (OOMKilled)",
+		},
+		"killed without memory limit": {
+			err: scriptErr,
+			inspect: &container.InspectResponse{
+				Name:  "/runner-abcd-build",
+				State: &container.State{OOMKilled: true},
+			},
+			wantReason:  common.OutOfMemoryFailure,
+			wantMessage: "Container runner-abcd-build was killed because it ran out of memory (OOMKilled)",
+		},
+		"not killed": {
+			err: scriptErr,
+			inspect: &container.InspectResponse{
+				Name:  "/runner-abcd-build",
+				State: &container.State{ExitCode: 137},
+			},
+			wantReason: common.ScriptFailure,
+		},
+		"inspect fails": {
+			err:        scriptErr,
+			inspectErr: errors.New("no such container"),
+			wantReason: common.ScriptFailure,
+		},
+		"not a script failure": {
+			err: errors.New("attach failed"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			e := newTestExecutor(t, c)
+
+			if tt.inspect != nil || tt.inspectErr != nil {
+				var inspect container.InspectResponse
+				if tt.inspect != nil {
+					inspect = *tt.inspect
+				}
+				c.EXPECT().ContainerInspect(mock.Anything, "build-id").Return(inspect, tt.inspectErr).Once()
+			}
+
+			err := e.diagnoseOOMKill("build-id", tt.err)
+			require.ErrorIs(t, err, tt.err)
+
+			var buildErr *common.BuildError
+			if tt.wantReason == "" {
+				assert.False(t, errors.As(err, &buildErr))
+				return
+			}
+
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, tt.wantReason, buildErr.FailureReason)
+			assert.Equal(t, 137, buildErr.ExitCode)
+			assert.ErrorContains(t, err, tt.wantMessage)
+		})
+	}
+}
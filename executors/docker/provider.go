@@ -1,14 +1,21 @@
 package docker
 
 import (
+	"context"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/warmpool"
 )
 
 type executorData struct {
 	ContainerName string
+
+	// warmPool is the warm pool of the runner, when it has one
+	warmPool *warmpool.Pool
 }
 
 func (d *executorData) LogFields() map[string]string {
@@ -20,8 +27,32 @@ func (d *executorData) LogFields() map[string]string {
 
 type executorProvider struct {
 	executors.DefaultExecutorProvider
+
+	warmPools *warmPools
 }
 
 func (p executorProvider) Acquire(config *common.RunnerConfig) (common.ExecutorData, error) {
-	return &executorData{}, nil
+	return &executorData{warmPool: p.warmPools.get(config)}, nil
+}
+
+// Init implements ManagedExecutorProvider.
+func (p executorProvider) Init() {}
+
+// Shutdown implements ManagedExecutorProvider.
+func (p executorProvider) Shutdown(ctx context.Context, _ *common.Config) {
+	p.warmPools.shutdown(ctx)
+}
+
+// Describe implements prometheus.Collector.
+func (p executorProvider) Describe(ch chan<- *prometheus.Desc) {
+	if p.warmPools != nil {
+		p.warmPools.metrics.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (p executorProvider) Collect(ch chan<- prometheus.Metric) {
+	if p.warmPools != nil {
+		p.warmPools.metrics.Collect(ch)
+	}
 }
@@ -1,7 +1,9 @@
 package docker
 
 import (
+	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/pull"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
 )
 
 func newPullManagerConfig(e *executor) pull.ManagerConfig {
@@ -11,11 +13,22 @@ func newPullManagerConfig(e *executor) pull.ManagerConfig {
 		ShellUser:    e.Shell().User,
 		Credentials:  e.Build.Credentials,
 		APIVersion:   e.serverAPIVersion,
+		HelperImage:  e.helperImageInfo.String(),
 	}
 }
 
 var createPullManager = func(e *executor) (pull.Manager, error) {
-	pullManager := pull.NewManager(e.Context, &e.BuildLogger, newPullManagerConfig(e), e.dockerConn, func() {
+	config := newPullManagerConfig(e)
+
+	if policy := e.Config.Docker.ImageVerification; policy != nil {
+		verifier, err := image_verification.NewVerifier(policy)
+		if err != nil {
+			return nil, &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+		}
+		config.ImageVerifier = verifier
+	}
+
+	pullManager := pull.NewManager(e.Context, &e.BuildLogger, config, e.dockerConn, func() {
 		e.SetCurrentStage(ExecutorStagePullingImage)
 	})
 
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
+)
+
+// SampleResourceUsage returns the resource usage of the build, helper and
+// service containers of the job, keyed by the container name without the
+// project prefix.
+func (e *executor) SampleResourceUsage(ctx context.Context) (map[string]referees.ResourceUsage, error) {
+	containers, err := e.listJobContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]referees.ResourceUsage, len(containers))
+	for _, c := range containers {
+		stats, err := e.dockerConn.ContainerStats(ctx, c.ID)
+		if err != nil {
+			// the container may have just exited
+			e.BuildLogger.Debugln("Failed to read stats of container", c.ID, err)
+			continue
+		}
+
+		usage[e.resourceUsageName(c)] = dockerResourceUsage(stats)
+	}
+
+	return usage, nil
+}
+
+// MemoryPeakUsage returns the peak memory usage of the running containers of
+// the job, read from their cgroup. Docker only reports it with cgroup v1, in
+// the samples.
+func (e *executor) MemoryPeakUsage(ctx context.Context) (map[string]uint64, error) {
+	if e.info.OSType == "windows" || e.info.CgroupVersion == "1" {
+		return nil, nil
+	}
+
+	containers, err := e.listJobContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks := make(map[string]uint64, len(containers))
+	for _, c := range containers {
+		if c.State != container.StateRunning {
+			continue
+		}
+
+		if peak := e.readMemoryPeak(ctx, c.ID); peak > 0 {
+			peaks[e.resourceUsageName(c)] = peak
+		}
+	}
+
+	return peaks, nil
+}
+
+// resourceUsageName returns the name of the container without the project
+// prefix.
+func (e *executor) resourceUsageName(c container.Summary) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+
+	return strings.TrimPrefix(strings.TrimPrefix(c.Names[0], "/"), e.projectUniqRandomizedName+"-")
+}
+
+// listJobContainers returns the build, helper and service containers of the
+// job: the containers with the job labels, and the containers taken from the
+// warm pool, which have the labels of the runner only.
+func (e *executor) listJobContainers(ctx context.Context) ([]container.Summary, error) {
+	filters := make(client.Filters).
+		Add("label", e.labeler.LabelKey("runner.id")+"="+e.Build.Runner.ShortDescription())
+	if len(e.warmContainerIDs) == 0 {
+		filters = filters.Add("label", e.labeler.LabelKey("job.id")+"="+strconv.FormatInt(e.Build.ID, 10))
+	}
+
+	containers, err := e.dockerConn.ContainerList(ctx, client.ContainerListOptions{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	jobID := strconv.FormatInt(e.Build.ID, 10)
+
+	return slices.DeleteFunc(containers, func(c container.Summary) bool {
+		switch c.Labels[e.labeler.LabelKey("type")] {
+		case buildContainerType, predefinedContainerType, labelServiceType:
+		default:
+			return true
+		}
+
+		return c.Labels[e.labeler.LabelKey("job.id")] != jobID && !slices.Contains(e.warmContainerIDs, c.ID)
+	}), nil
+}
+
+// readMemoryPeak returns the highest memory usage recorded by the cgroup of
+// the container, or zero when it can't be read, for example when the image
+// has no shell.
+func (e *executor) readMemoryPeak(ctx context.Context, containerID string) uint64 {
+	exitCode, output, err := e.execInServiceContainer(ctx, containerID, referees.MemoryPeakCommand)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %d: %s", exitCode, output)
+	}
+
+	var peak uint64
+	if err == nil {
+		peak, err = referees.ParseMemoryPeak(output)
+	}
+	if err != nil {
+		e.BuildLogger.Debugln("Failed to read peak memory usage of container", containerID, err)
+	}
+
+	return peak
+}
+
+func dockerResourceUsage(stats container.StatsResponse) referees.ResourceUsage {
+	var usage referees.ResourceUsage
+
+	if stats.OSType == "windows" {
+		// Windows reports CPU time in 100ns units
+		usage.CPUSeconds = float64(stats.CPUStats.CPUUsage.TotalUsage) * 100 / float64(time.Second)
+		usage.MemoryBytes = stats.MemoryStats.PrivateWorkingSet
+		usage.BlockReadBytes = stats.StorageStats.ReadSizeBytes
+		usage.BlockWriteBytes = stats.StorageStats.WriteSizeBytes
+	} else {
+		usage.CPUSeconds = float64(stats.CPUStats.CPUUsage.TotalUsage) / float64(time.Second)
+		// cgroup v1 reports the rss, cgroup v2 the anonymous memory
+		usage.MemoryBytes = cmp.Or(stats.MemoryStats.Stats["rss"], stats.MemoryStats.Stats["anon"])
+		// only cgroup v1 reports the maximum usage
+		usage.MemoryPeakBytes = stats.MemoryStats.MaxUsage
+
+		for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+			switch strings.ToLower(entry.Op) {
+			case "read":
+				usage.BlockReadBytes += entry.Value
+			case "write":
+				usage.BlockWriteBytes += entry.Value
+			}
+		}
+	}
+
+	for _, network := range stats.Networks {
+		usage.NetworkReceiveBytes += network.RxBytes
+		usage.NetworkTransmitBytes += network.TxBytes
+	}
+
+	return usage
+}
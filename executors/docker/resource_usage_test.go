@@ -0,0 +1,182 @@
+//go:build !integration
+
+package docker
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
+)
+
+func TestDockerResourceUsage(t *testing.T) {
+	networks := map[string]container.NetworkStats{
+		"eth0": {RxBytes: 100, TxBytes: 10},
+		"eth1": {RxBytes: 200, TxBytes: 20},
+	}
+
+	tests := map[string]struct {
+		stats    container.StatsResponse
+		expected referees.ResourceUsage
+	}{
+		"cgroup v1": {
+			stats: container.StatsResponse{
+				CPUStats:    container.CPUStats{CPUUsage: container.CPUUsage{TotalUsage: 2_500_000_000}},
+				MemoryStats: container.MemoryStats{MaxUsage: 8192, Stats: map[string]uint64{"rss": 1024, "cache": 4096}},
+				BlkioStats: container.BlkioStats{IoServiceBytesRecursive: []container.BlkioStatEntry{
+					{Op: "Read", Value: 5},
+					{Op: "Write", Value: 7},
+					{Op: "read", Value: 1},
+					{Op: "total", Value: 13},
+				}},
+				Networks: networks,
+			},
+			expected: referees.ResourceUsage{
+				CPUSeconds:           2.5,
+				MemoryBytes:          1024,
+				MemoryPeakBytes:      8192,
+				BlockReadBytes:       6,
+				BlockWriteBytes:      7,
+				NetworkReceiveBytes:  300,
+				NetworkTransmitBytes: 30,
+			},
+		},
+		"cgroup v2": {
+			stats: container.StatsResponse{
+				CPUStats:    container.CPUStats{CPUUsage: container.CPUUsage{TotalUsage: 1_000_000_000}},
+				MemoryStats: container.MemoryStats{Stats: map[string]uint64{"anon": 2048, "file": 4096}},
+			},
+			expected: referees.ResourceUsage{
+				CPUSeconds:  1,
+				MemoryBytes: 2048,
+			},
+		},
+		"windows": {
+			stats: container.StatsResponse{
+				OSType:       "windows",
+				CPUStats:     container.CPUStats{CPUUsage: container.CPUUsage{TotalUsage: 30_000_000}},
+				MemoryStats:  container.MemoryStats{PrivateWorkingSet: 4096},
+				StorageStats: container.StorageStats{ReadSizeBytes: 3, WriteSizeBytes: 4},
+				Networks:     networks,
+			},
+			expected: referees.ResourceUsage{
+				CPUSeconds:           3,
+				MemoryBytes:          4096,
+				BlockReadBytes:       3,
+				BlockWriteBytes:      4,
+				NetworkReceiveBytes:  300,
+				NetworkTransmitBytes: 30,
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tt.expected, dockerResourceUsage(tt.stats))
+		})
+	}
+}
+
+func TestSampleResourceUsage(t *testing.T) {
+	const prefix = "runner-abc-project-1-concurrent-0"
+
+	tests := map[string]struct {
+		warmContainerIDs []string
+		expectedFilters  client.Filters
+		expected         []string
+	}{
+		"containers of the job": {
+			expectedFilters: client.Filters{"label": {
+				"com.gitlab.gitlab-runner.runner.id=": true,
+				"com.gitlab.gitlab-runner.job.id=42":  true,
+			}},
+			expected: []string{"svc-0"},
+		},
+		"containers taken from the warm pool": {
+			warmContainerIDs: []string{"warm-helper", "warm-build"},
+			expectedFilters: client.Filters{"label": {
+				"com.gitlab.gitlab-runner.runner.id=": true,
+			}},
+			expected: []string{"build", "predefined", "svc-0"},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			c := docker.NewMockClient(t)
+			e := newTestExecutor(t, c)
+			e.Build.ID = 42
+			e.projectUniqRandomizedName = prefix
+			e.warmContainerIDs = tt.warmContainerIDs
+
+			label := func(containerType, jobID string) map[string]string {
+				labels := map[string]string{"com.gitlab.gitlab-runner.type": containerType}
+				if jobID != "" {
+					labels["com.gitlab.gitlab-runner.job.id"] = jobID
+				}
+				return labels
+			}
+
+			containers := []container.Summary{
+				{ID: "warm-build", Names: []string{"/" + prefix + "-build"}, Labels: label(buildContainerType, "")},
+				{ID: "warm-helper", Names: []string{"/" + prefix + "-predefined"}, Labels: label(predefinedContainerType, "")},
+				{ID: "svc", Names: []string{"/" + prefix + "-svc-0"}, Labels: label(labelServiceType, "42")},
+				{ID: "other-build", Names: []string{"/runner-abc-project-1-concurrent-1-build"}, Labels: label(buildContainerType, "43")},
+				{ID: "other-warm", Names: []string{"/runner-abc-warm-build"}, Labels: label(buildContainerType, "")},
+			}
+
+			c.EXPECT().ContainerList(mock.Anything, mock.Anything).
+				RunAndReturn(func(_ context.Context, options client.ContainerListOptions) ([]container.Summary, error) {
+					assert.Equal(t, tt.expectedFilters, options.Filters)
+
+					if len(tt.warmContainerIDs) == 0 {
+						return containers[2:3], nil
+					}
+					return containers, nil
+				}).Once()
+			c.EXPECT().ContainerStats(mock.Anything, mock.Anything).
+				Return(container.StatsResponse{MemoryStats: container.MemoryStats{MaxUsage: 1024}}, nil)
+
+			usage, err := e.SampleResourceUsage(t.Context())
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tt.expected, slices.Collect(maps.Keys(usage)))
+		})
+	}
+}
+
+func TestMemoryPeakUsage(t *testing.T) {
+	t.Run("reported by the stats with cgroup v1", func(t *testing.T) {
+		e := newTestExecutor(t, docker.NewMockClient(t))
+		e.info.CgroupVersion = "1"
+
+		peaks, err := e.MemoryPeakUsage(t.Context())
+		require.NoError(t, err)
+		assert.Empty(t, peaks)
+	})
+
+	t.Run("containers that exited", func(t *testing.T) {
+		c := docker.NewMockClient(t)
+		e := newTestExecutor(t, c)
+		e.info.CgroupVersion = "2"
+
+		c.EXPECT().ContainerList(mock.Anything, mock.Anything).Return([]container.Summary{{
+			ID:     "build",
+			State:  container.StateExited,
+			Labels: map[string]string{"com.gitlab.gitlab-runner.type": buildContainerType, "com.gitlab.gitlab-runner.job.id": "0"},
+		}}, nil).Once()
+
+		peaks, err := e.MemoryPeakUsage(t.Context())
+		require.NoError(t, err)
+		assert.Empty(t, peaks, "no command runs in the containers that exited")
+	})
+}
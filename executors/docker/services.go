@@ -27,6 +27,11 @@ type serviceInfo struct {
 	Name  string
 	IP    []string
 	Ports []int
+
+	// Readiness defines when the service is ready.
+	Readiness services.Readiness
+	// Required is whether other services depend on the service.
+	Required bool
 }
 
 type tooManyServicesRequestedError struct {
@@ -56,17 +61,47 @@ func (e *executor) createServices() error {
 		return err
 	}
 
+	dependencies := make([]services.Dependencies, 0, len(servicesDefinitions))
+	for _, definition := range servicesDefinitions {
+		dependencies = append(
+			dependencies,
+			services.DependenciesFor(definition.Name, definition.Aliases(), definition.Variables.Get),
+		)
+	}
+
+	// Services are started in stages, and a stage only once the services of
+	// the previous ones, which it depends on, are ready.
+	stages, err := services.Stages(dependencies)
+	if err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+	required := services.Required(dependencies)
+
 	linksMap := make(map[string]*serviceInfo)
 
-	for index, serviceDefinition := range servicesDefinitions {
-		if err := e.createFromServiceDefinition(index, serviceDefinition, linksMap); err != nil {
-			return err
+	for _, stage := range stages {
+		var created []*serviceInfo
+
+		for _, index := range stage {
+			service, err := e.createFromServiceDefinition(index, servicesDefinitions[index], linksMap)
+			if err != nil {
+				return err
+			}
+			if service == nil {
+				continue
+			}
+
+			service.Readiness = services.ReadinessFor(servicesDefinitions[index].Variables.Get)
+			service.Required = required[index]
+			created = append(created, service)
 		}
-	}
 
-	e.captureContainersLogs(e.Context, linksMap)
+		e.captureContainersLogs(e.Context, created, linksMap)
 
-	e.waitForServices()
+		if err := e.waitForServices(created); err != nil {
+			return err
+		}
+	}
 
 	for linkName, linkee := range linksMap {
 		for _, ip := range linkee.IP {
@@ -125,7 +160,7 @@ func (e *executor) resumeServices(serviceIDs []string) error {
 	}
 
 	if len(serviceIDs) > 0 {
-		e.waitForServices()
+		_ = e.waitForServices(e.services)
 	}
 
 	// Track for cleanup only after all services started successfully.
@@ -137,32 +172,50 @@ func (e *executor) resumeServices(serviceIDs []string) error {
 	return nil
 }
 
-func (e *executor) waitForServices() {
+// waitForServices waits for the services to be ready. A service that isn't
+// is only reported, unless other services depend on it.
+func (e *executor) waitForServices(serviceInfos []*serviceInfo) error {
 	timeout := e.Config.Docker.WaitForServicesTimeout
 	if timeout == 0 {
 		timeout = common.DefaultWaitForServicesTimeout
 	}
 
 	// wait for all services to come up
-	if timeout > 0 && len(e.services) > 0 {
-		e.BuildLogger.Println("Waiting for services to be up and running (timeout", timeout, "seconds)...")
-		wg := sync.WaitGroup{}
-		for _, service := range e.services {
-			wg.Add(1)
-			go func(service *serviceInfo) {
-				e.waitForServiceContainer(service, time.Duration(timeout)*time.Second)
-				wg.Done()
-			}(service)
-		}
-		wg.Wait()
+	if timeout <= 0 || len(serviceInfos) == 0 {
+		return nil
+	}
+
+	e.BuildLogger.Println("Waiting for services to be up and running (timeout", timeout, "seconds)...")
+
+	errs := make([]error, len(serviceInfos))
+	wg := sync.WaitGroup{}
+	for i, service := range serviceInfos {
+		wg.Add(1)
+		go func(service *serviceInfo) {
+			defer wg.Done()
+
+			err := e.waitForServiceContainer(service, time.Duration(timeout)*time.Second)
+			if err != nil && service.Required {
+				errs[i] = fmt.Errorf("service %s, which other services depend on, isn't ready: %w", service.Name, err)
+			}
+		}(service)
 	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return &common.BuildError{Inner: err}
+	}
+
+	return nil
 }
 
+// createFromServiceDefinition creates the service of the definition, and
+// returns it, unless all its aliases are already in use by other services.
 func (e *executor) createFromServiceDefinition(
 	serviceIndex int,
 	serviceDefinition spec.Image,
 	linksMap map[string]*serviceInfo,
-) error {
+) (*serviceInfo, error) {
 	var container *serviceInfo
 
 	serviceMeta := services.SplitNameAndVersion(serviceDefinition.Name)
@@ -191,7 +244,7 @@ func (e *executor) createFromServiceDefinition(
 				serviceMeta.Aliases,
 			)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			e.BuildLogger.Debugln("Created service", serviceDefinition.Name, "as", container.ID)
@@ -203,12 +256,15 @@ func (e *executor) createFromServiceDefinition(
 		}
 		linksMap[linkName] = container
 	}
-	return nil
+	return container, nil
 }
 
 type serviceHealthCheckError struct {
 	Inner error
 	Logs  string
+	// Source is what the logs are from, the health check container by
+	// default.
+	Source string
 }
 
 func (e *serviceHealthCheckError) Error() string {
@@ -219,6 +275,14 @@ func (e *serviceHealthCheckError) Error() string {
 	return e.Inner.Error()
 }
 
+func (e *serviceHealthCheckError) logsTitle() string {
+	if e.Source == "" {
+		return "Health check container logs"
+	}
+
+	return e.Source
+}
+
 func (e *executor) runServiceHealthCheckContainer(service *serviceInfo, timeout time.Duration) error {
 	waitImage, err := e.getHelperImage()
 	if err != nil {
@@ -284,12 +348,14 @@ func (e *executor) createConfigForServiceHealthCheckContainer(
 	}
 }
 
-func (e *executor) waitForServiceContainer(service *serviceInfo, timeout time.Duration) {
+func (e *executor) waitForServiceContainer(service *serviceInfo, timeout time.Duration) error {
 	start := time.Now()
 
-	err := e.runServiceHealthCheckContainer(service, timeout)
+	endWait := e.Build.Timeline().StartEvent(common.TimelineEventServiceWait, map[string]string{"service": service.Name})
+	err := e.runServiceReadinessCheck(service, timeout)
+	endWait()
 	if err == nil {
-		return
+		return nil
 	}
 
 	var buffer bytes.Buffer
@@ -304,7 +370,7 @@ func (e *executor) waitForServiceContainer(service *serviceInfo, timeout time.Du
 
 	if healtCheckErr, ok := err.(*serviceHealthCheckError); ok {
 		buffer.WriteString("\n")
-		buffer.WriteString("Health check container logs:\n")
+		buffer.WriteString(healtCheckErr.logsTitle() + ":\n")
 		buffer.WriteString(healtCheckErr.Logs)
 		buffer.WriteString("\n")
 	}
@@ -328,19 +394,27 @@ func (e *executor) waitForServiceContainer(service *serviceInfo, timeout time.Du
 	defer wc.Close()
 
 	_, _ = wc.Write(buffer.Bytes())
+
+	return err
 }
 
-// captureContainersLogs initiates capturing logs for the specified containers
+// captureContainersLogs initiates capturing logs for the specified services
 // to a desired additional sink. The sink can be any io.Writer. Currently the
 // sink is the jobs main trace, which is wrapped in an inlineServiceLogWriter
-// instance to add additional context to logs. In the future this could be
-// separate file.
-func (e *executor) captureContainersLogs(ctx context.Context, linksMap map[string]*serviceInfo) {
-	if !e.Build.IsCIDebugServiceEnabled() {
+// instance to add additional context to logs, and the file of each service
+// the job_diagnostics artifact is made of.
+func (e *executor) captureContainersLogs(
+	ctx context.Context,
+	serviceInfos []*serviceInfo,
+	linksMap map[string]*serviceInfo,
+) {
+	debug := e.Build.IsCIDebugServiceEnabled()
+	serviceLogs := e.Build.ServiceLogs()
+	if !debug && serviceLogs == nil {
 		return
 	}
 
-	for _, service := range e.services {
+	for _, service := range serviceInfos {
 		aliases := []string{}
 
 		for alias, container := range linksMap {
@@ -355,14 +429,26 @@ func (e *executor) captureContainersLogs(ctx context.Context, linksMap map[strin
 			}
 		}
 
-		logger := e.BuildLogger.Stream(buildlogger.StreamStartingServiceLevel, buildlogger.Stdout)
-		defer logger.Close()
+		name := strings.Join(aliases, "-")
 
-		sink := service_helpers.NewInlineServiceLogWriter(strings.Join(aliases, "-"), logger)
+		var logger, sink io.WriteCloser
+		if debug {
+			logger = e.BuildLogger.Stream(buildlogger.StreamStartingServiceLevel, buildlogger.Stdout)
+			defer logger.Close()
+
+			sink = service_helpers.NewInlineServiceLogWriter(name, logger)
+		}
+
+		// the logs are also captured to a file, for the job_diagnostics artifact
+		sink = serviceLogs.Writer(name, sink)
 		if err := e.captureContainerLogs(ctx, service.ID, service.Name, sink); err != nil {
+			_ = sink.Close()
 			e.BuildLogger.Warningln(err.Error())
 		}
-		logger.Close()
+
+		if logger != nil {
+			logger.Close()
+		}
 	}
 }
 
@@ -0,0 +1,154 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/limitwriter"
+)
+
+// serviceReadinessCheckInterval is how long to wait between the checks of
+// the readiness of a service run by the runner.
+var serviceReadinessCheckInterval = time.Second
+
+// runServiceReadinessCheck waits for the service to be ready as its readiness
+// defines: its command to succeed, the HEALTHCHECK of its image to report it
+// healthy, or the health check container to reach its ports.
+func (e *executor) runServiceReadinessCheck(service *serviceInfo, timeout time.Duration) error {
+	switch {
+	case service.Readiness.Command != "":
+		return e.runServiceCommandHealthCheck(service, timeout)
+	case service.Readiness.UseImage:
+		return e.waitForServiceImageHealthCheck(service, timeout)
+	default:
+		return e.runServiceHealthCheckContainer(service, timeout)
+	}
+}
+
+// runServiceCommandHealthCheck runs the readiness command of the service inside
+// its container until it succeeds.
+func (e *executor) runServiceCommandHealthCheck(service *serviceInfo, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(e.Context, timeout)
+	defer cancel()
+
+	e.BuildLogger.Debugln(fmt.Sprintf("Running health check command in service container %s...", service.Name))
+
+	var output string
+	for {
+		exitCode, out, err := e.execInServiceContainer(ctx, service.ID, service.Readiness.Command)
+		if err == nil && exitCode == 0 {
+			return nil
+		}
+
+		output = out
+		if err != nil {
+			output = err.Error()
+		}
+
+		select {
+		case <-ctx.Done():
+			return &serviceHealthCheckError{
+				Inner:  serviceHealthCheckTimeoutError(ctx, service, "health check command"),
+				Logs:   output,
+				Source: "Health check command output",
+			}
+		case <-time.After(serviceReadinessCheckInterval):
+		}
+	}
+}
+
+// execInServiceContainer runs the command with sh -c inside the container,
+// and returns its exit code and output.
+func (e *executor) execInServiceContainer(ctx context.Context, containerID, command string) (int, string, error) {
+	exec, err := e.dockerConn.ContainerExecCreate(ctx, containerID, client.ExecCreateOptions{
+		AttachStderr: true,
+		AttachStdout: true,
+		Cmd:          []string{"sh", "-c", command},
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("exec create: %w", err)
+	}
+
+	resp, err := e.dockerConn.ContainerExecAttach(ctx, exec.ID, client.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("exec attach: %w", err)
+	}
+	defer resp.Close()
+
+	var buf bytes.Buffer
+	w := limitwriter.New(&buf, ServiceLogOutputLimit)
+	if _, err := stdcopy.StdCopy(w, w, resp.Reader); err != nil && !errors.Is(err, limitwriter.ErrWriteLimitExceeded) {
+		return 0, "", fmt.Errorf("reading exec output: %w", err)
+	}
+
+	inspect, err := e.dockerConn.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, "", fmt.Errorf("exec inspect: %w", err)
+	}
+
+	return inspect.ExitCode, strings.TrimSpace(buf.String()), nil
+}
+
+// waitForServiceImageHealthCheck waits for the HEALTHCHECK of the image of the
+// service to report its container healthy.
+func (e *executor) waitForServiceImageHealthCheck(service *serviceInfo, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(e.Context, timeout)
+	defer cancel()
+
+	e.BuildLogger.Debugln(fmt.Sprintf("Waiting for the image health check of service container %s...", service.Name))
+
+	var health *container.Health
+	for {
+		inspect, err := e.dockerConn.ContainerInspect(ctx, service.ID)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("inspecting service container: %w", err)
+		}
+
+		if err == nil {
+			if inspect.State == nil || inspect.State.Health == nil {
+				return fmt.Errorf("service %q image has no HEALTHCHECK", service.Name)
+			}
+
+			health = inspect.State.Health
+			if health.Status == container.Healthy {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &serviceHealthCheckError{
+				Inner:  serviceHealthCheckTimeoutError(ctx, service, "image health check"),
+				Logs:   imageHealthCheckOutput(health),
+				Source: "Image health check output",
+			}
+		case <-time.After(serviceReadinessCheckInterval):
+		}
+	}
+}
+
+func imageHealthCheckOutput(health *container.Health) string {
+	if health == nil || len(health.Log) == 0 {
+		return ""
+	}
+
+	last := health.Log[len(health.Log)-1]
+
+	return fmt.Sprintf("status: %s, exit code: %d\n%s", health.Status, last.ExitCode, strings.TrimSpace(last.Output))
+}
+
+func serviceHealthCheckTimeoutError(ctx context.Context, service *serviceInfo, check string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("service %q %s timeout", service.Name, check)
+	}
+
+	return fmt.Errorf("service %q %s: %w", service.Name, check, ctx.Err())
+}
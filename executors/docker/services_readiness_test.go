@@ -0,0 +1,191 @@
+//go:build !integration
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+// stdoutFrame multiplexes the output like the daemon does for containers
+// without a TTY.
+func stdoutFrame(output string) *bufio.Reader {
+	header := make([]byte, 8)
+	header[0] = 1
+	binary.BigEndian.PutUint32(header[4:], uint32(len(output)))
+
+	return bufio.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader([]byte(output))))
+}
+
+func newReadinessTestExecutor(t *testing.T) (*executor, *docker.MockClient, *bytes.Buffer) {
+	c := docker.NewMockClient(t)
+
+	logs := &bytes.Buffer{}
+	lentry := logrus.New()
+	lentry.Out = io.Discard
+
+	e := &executor{dockerConn: &dockerConnection{Client: c}}
+	e.Context = t.Context()
+	e.Build = &common.Build{}
+	e.Config.Docker = &common.DockerConfig{}
+	e.BuildLogger = buildlogger.New(&common.Trace{Writer: logs}, logrus.NewEntry(lentry), buildlogger.Options{})
+
+	serviceReadinessCheckInterval = time.Millisecond
+	t.Cleanup(func() { serviceReadinessCheckInterval = time.Second })
+
+	return e, c, logs
+}
+
+func TestRunServiceReadinessCheck_Command(t *testing.T) {
+	service := &serviceInfo{
+		ID:        "service-id",
+		Name:      "postgres",
+		Readiness: services.Readiness{Command: "pg_isready"},
+	}
+
+	expectExec := func(c *docker.MockClient, output string, exitCode int) {
+		c.On("ContainerExecCreate", mock.Anything, "service-id", client.ExecCreateOptions{
+			AttachStderr: true,
+			AttachStdout: true,
+			Cmd:          []string{"sh", "-c", "pg_isready"},
+		}).Return(container.ExecCreateResponse{ID: "exec-id"}, nil).Once()
+		c.On("ContainerExecAttach", mock.Anything, "exec-id", client.ExecAttachOptions{}).
+			Return(client.HijackedResponse{Conn: nopConn{}, Reader: stdoutFrame(output)}, nil).
+			Once()
+		c.On("ContainerExecInspect", mock.Anything, "exec-id").
+			Return(client.ExecInspectResult{ExitCode: exitCode}, nil).
+			Once()
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		e, c, _ := newReadinessTestExecutor(t)
+
+		expectExec(c, "no response", 2)
+		expectExec(c, "accepting connections", 0)
+
+		assert.NoError(t, e.runServiceReadinessCheck(service, time.Minute))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		e, c, _ := newReadinessTestExecutor(t)
+
+		c.On("ContainerExecCreate", mock.Anything, "service-id", mock.Anything).
+			Return(container.ExecCreateResponse{}, errors.New("container is restarting"))
+
+		err := e.runServiceReadinessCheck(service, 10*time.Millisecond)
+
+		var healthCheckErr *serviceHealthCheckError
+		require.ErrorAs(t, err, &healthCheckErr)
+		assert.EqualError(t, err, `service "postgres" health check command timeout`)
+		assert.Contains(t, healthCheckErr.Logs, "container is restarting")
+		assert.Equal(t, "Health check command output", healthCheckErr.logsTitle())
+	})
+}
+
+func TestRunServiceReadinessCheck_Image(t *testing.T) {
+	service := &serviceInfo{
+		ID:        "service-id",
+		Name:      "vault",
+		Readiness: services.Readiness{UseImage: true},
+	}
+
+	inspect := func(health *container.Health) container.InspectResponse {
+		return container.InspectResponse{ID: "service-id", State: &container.State{Health: health}}
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		e, c, _ := newReadinessTestExecutor(t)
+
+		c.On("ContainerInspect", mock.Anything, "service-id").
+			Return(inspect(&container.Health{Status: container.Starting}), nil).
+			Once()
+		c.On("ContainerInspect", mock.Anything, "service-id").
+			Return(inspect(&container.Health{Status: container.Healthy}), nil).
+			Once()
+
+		assert.NoError(t, e.runServiceReadinessCheck(service, time.Minute))
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		e, c, _ := newReadinessTestExecutor(t)
+
+		c.On("ContainerInspect", mock.Anything, "service-id").
+			Return(inspect(&container.Health{
+				Status: container.Unhealthy,
+				Log:    []*container.HealthcheckResult{{ExitCode: 1, Output: "sealed\n"}},
+			}), nil)
+
+		err := e.runServiceReadinessCheck(service, 10*time.Millisecond)
+
+		var healthCheckErr *serviceHealthCheckError
+		require.ErrorAs(t, err, &healthCheckErr)
+		assert.EqualError(t, err, `service "vault" image health check timeout`)
+		assert.Equal(t, "status: unhealthy, exit code: 1\nsealed", healthCheckErr.Logs)
+	})
+
+	t.Run("no HEALTHCHECK", func(t *testing.T) {
+		e, c, _ := newReadinessTestExecutor(t)
+
+		c.On("ContainerInspect", mock.Anything, "service-id").Return(inspect(nil), nil).Once()
+
+		assert.EqualError(t, e.runServiceReadinessCheck(service, time.Minute), `service "vault" image has no HEALTHCHECK`)
+	})
+}
+
+func TestWaitForServices_Required(t *testing.T) {
+	e, c, logs := newReadinessTestExecutor(t)
+	e.Config.Docker.WaitForServicesTimeout = 1
+
+	c.On("ContainerInspect", mock.Anything, mock.Anything).
+		Return(container.InspectResponse{State: &container.State{}}, nil).
+		Maybe()
+	c.On("ContainerLogs", mock.Anything, mock.Anything, mock.Anything).
+		Return(io.NopCloser(&bytes.Buffer{}), nil).
+		Maybe()
+
+	optional := &serviceInfo{ID: "optional-id", Name: "optional", Readiness: services.Readiness{UseImage: true}}
+	required := &serviceInfo{ID: "required-id", Name: "required", Readiness: services.Readiness{UseImage: true}, Required: true}
+
+	assert.NoError(t, e.waitForServices([]*serviceInfo{optional}))
+	assert.Contains(t, logs.String(), "Service optional probably didn't start properly.")
+
+	err := e.waitForServices([]*serviceInfo{optional, required})
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.ErrorContains(t, err, `service required, which other services depend on, isn't ready: service "required" image has no HEALTHCHECK`)
+	assert.NotContains(t, err.Error(), "optional")
+}
+
+func TestCreateServices_DependencyCycle(t *testing.T) {
+	e, _, _ := newReadinessTestExecutor(t)
+	e.Build.Services = spec.Services{
+		{Name: "postgres:16", Alias: "db", Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "app"}}},
+		{Name: "registry.example.com/app", Alias: "app", Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "db"}}},
+	}
+
+	err := e.createServices()
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+	assert.EqualError(t, err, `dependency cycle between services "postgres", "registry.example.com__app"`)
+}
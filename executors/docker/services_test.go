@@ -30,6 +30,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/pull"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
 	service_test "gitlab.com/gitlab-org/gitlab-runner/helpers/container/services/test"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
 	service_helpers "gitlab.com/gitlab-org/gitlab-runner/helpers/service"
@@ -151,8 +152,9 @@ func testServiceFromNamedImage(t *testing.T, description, imageName, serviceName
 	}
 
 	linksMap := make(map[string]*serviceInfo)
-	err = e.createFromServiceDefinition(0, imageConfig, linksMap)
+	service, err := e.createFromServiceDefinition(0, imageConfig, linksMap)
 	assert.NoError(t, err)
+	assert.NotNil(t, service)
 }
 
 func TestServiceFromNamedImage(t *testing.T) {
@@ -178,7 +180,9 @@ func TestCreateFromServiceDefinition_AliasCollisionWarning(t *testing.T) {
 	}
 
 	imageConfig := spec.Image{Name: "nginx:latest", Alias: "shared"}
-	require.NoError(t, e.createFromServiceDefinition(2, imageConfig, linksMap))
+	service, err := e.createFromServiceDefinition(2, imageConfig, linksMap)
+	require.NoError(t, err)
+	assert.Nil(t, service)
 
 	logOutput := logs.String()
 	assert.Contains(t, logOutput, `Skipping alias "nginx" for service "nginx:latest" (services[2]): alias is already in use by another service.`)
@@ -700,6 +704,7 @@ func TestGetServiceDefinitions(t *testing.T) {
 func TestAddServiceHealthCheck(t *testing.T) {
 	tests := map[string]struct {
 		networkMode            string
+		httpPath               string
 		dockerClientAssertions func(*docker.MockClient)
 		expectedEnvironment    []string
 		expectedExposePortsErr string
@@ -813,6 +818,26 @@ func TestAddServiceHealthCheck(t *testing.T) {
 				"WAIT_FOR_SERVICE_2000_TCP_PORT=2000",
 			},
 		},
+		"HTTP path": {
+			networkMode: "test",
+			httpPath:    "/healthz",
+			dockerClientAssertions: func(c *docker.MockClient) {
+				c.On("ContainerInspect", mock.Anything, mock.Anything).
+					Return(container.InspectResponse{
+						NetworkSettings: &container.NetworkSettings{},
+						ID:              "default", State: &container.State{Status: container.StateRunning},
+						Config: &container.Config{
+							ExposedPorts: network.PortSet{network.MustParsePort("8080/tcp"): {}},
+						},
+					}, nil).
+					Once()
+			},
+			expectedEnvironment: []string{
+				"WAIT_FOR_SERVICE_TCP_ADDR=000000000000",
+				"WAIT_FOR_SERVICE_8080_TCP_PORT=8080",
+				"WAIT_FOR_SERVICE_HTTP_PATH=/healthz",
+			},
+		},
 		"get port from container variable - case insensitive": {
 			networkMode: "test",
 			dockerClientAssertions: func(c *docker.MockClient) {
@@ -901,10 +926,11 @@ func TestAddServiceHealthCheck(t *testing.T) {
 			}
 
 			service := &serviceInfo{
-				ID:    "0000000000000000000000000000000000000000000000000000000000000000",
-				Name:  "default",
-				IP:    ip,
-				Ports: ports,
+				ID:        "0000000000000000000000000000000000000000000000000000000000000000",
+				Name:      "default",
+				IP:        ip,
+				Ports:     ports,
+				Readiness: services.Readiness{HTTPPath: test.httpPath},
 			}
 
 			environment, err := executor.addServiceHealthCheckEnvironment(service)
@@ -1152,9 +1178,10 @@ func Test_Executor_captureContainersLogs(t *testing.T) {
 	ctx := t.Context()
 
 	tests := map[string]struct {
-		debugServicePolicy string
-		expect             func()
-		assert             func(t *testing.T)
+		debugServicePolicy  string
+		serviceLogsArtifact string
+		expect              func()
+		assert              func(t *testing.T)
 	}{
 		"enabled": {
 			debugServicePolicy: "true",
@@ -1173,6 +1200,21 @@ func Test_Executor_captureContainersLogs(t *testing.T) {
 				}
 			},
 		},
+		"service logs artifact": {
+			debugServicePolicy:  "false",
+			serviceLogsArtifact: "true",
+			expect: func() {
+				for _, cont := range containers {
+					c.On("ContainerLogs", ctx, cont.ID, mock.Anything).Return(nil, stop).Once()
+				}
+			},
+			assert: func(t *testing.T) {
+				for _, c := range containers {
+					assert.Contains(t, logs.String(), "WARNING: failed to open log stream for container "+
+						c.Name+": "+stop.Error())
+				}
+			},
+		},
 		"disabled": {
 			debugServicePolicy: "false",
 			expect:             func() {},
@@ -1191,10 +1233,11 @@ func Test_Executor_captureContainersLogs(t *testing.T) {
 			e.Build = &common.Build{}
 			e.Build.Variables = spec.Variables{
 				{Key: "CI_DEBUG_SERVICES", Value: tt.debugServicePolicy, Public: true},
+				{Key: "CI_SERVICE_LOGS_ARTIFACT", Value: tt.serviceLogsArtifact, Public: true},
 			}
 
 			tt.expect()
-			e.captureContainersLogs(ctx, linksMap)
+			e.captureContainersLogs(ctx, containers, linksMap)
 			tt.assert(t)
 		})
 	}
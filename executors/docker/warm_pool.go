@@ -0,0 +1,443 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/moby/moby/api/types/image"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/warmpool"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+)
+
+// warmPools are the warm pools of the runners using the docker executor,
+// keyed by runner token.
+type warmPools struct {
+	mu      sync.Mutex
+	pools   map[string]*runnerWarmPool
+	metrics *warmpool.Metrics
+
+	// For testing
+	newClient func(c docker.Credentials) (docker.Client, error)
+}
+
+type runnerWarmPool struct {
+	pool           *warmpool.Pool
+	client         docker.Client
+	configLoadedAt string
+}
+
+func newWarmPools() *warmPools {
+	return &warmPools{
+		pools:   make(map[string]*runnerWarmPool),
+		metrics: warmpool.NewMetrics(),
+		newClient: func(c docker.Credentials) (docker.Client, error) {
+			return docker.New(c)
+		},
+	}
+}
+
+// get returns the warm pool of the runner, which is started on the first job
+// of the runner, and restarted when its configuration changes.
+func (w *warmPools) get(config *common.RunnerConfig) *warmpool.Pool {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	token := config.GetToken()
+	rp, exists := w.pools[token]
+
+	configKey := config.ConfigLoadedAt.String()
+	if exists && rp.configLoadedAt == configKey {
+		return rp.pool
+	}
+
+	if exists {
+		delete(w.pools, token)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), dockerCleanupTimeout)
+			defer cancel()
+
+			rp.stop(ctx)
+		}()
+	}
+
+	if config.Docker == nil || config.Docker.WarmPool == nil {
+		return nil
+	}
+
+	logger := logrus.WithField("runner", config.ShortDescription())
+
+	c, err := w.newClient(config.Docker.Credentials)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to start the warm pool")
+		return nil
+	}
+
+	pool := warmpool.New(c, warmpool.Config{
+		RunnerID:    config.ShortDescription(),
+		Size:        config.Docker.WarmPool.GetSize(),
+		IdleTimeout: config.Docker.WarmPool.GetIdleTimeout(),
+	}, logger, w.metrics)
+	pool.Start()
+
+	w.pools[token] = &runnerWarmPool{
+		pool:           pool,
+		client:         c,
+		configLoadedAt: configKey,
+	}
+
+	return pool
+}
+
+func (w *warmPools) shutdown(ctx context.Context) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for token, rp := range w.pools {
+		wg.Go(func() { rp.stop(ctx) })
+		delete(w.pools, token)
+	}
+	wg.Wait()
+}
+
+func (rp *runnerWarmPool) stop(ctx context.Context) {
+	rp.pool.Stop(ctx)
+	_ = rp.client.Close()
+}
+
+func (e *executor) warmPool() *warmpool.Pool {
+	data, ok := e.Build.ExecutorData.(*executorData)
+	if !ok {
+		return nil
+	}
+
+	return data.warmPool
+}
+
+// takeWarmContainers hands the job a set of pre-created build and helper
+// containers from the warm pool, when the job can use one. Otherwise, the
+// containers are created when the job needs them, as usual.
+func (e *executor) takeWarmContainers() error {
+	pool := e.warmPool()
+	if pool == nil {
+		return nil
+	}
+
+	e.SetCurrentStage(ExecutorStageTakingWarmContainers)
+
+	hostBinds, volumes, ok, err := e.warmPoolVolumes()
+	if err != nil || !ok {
+		return err
+	}
+
+	template, ok, err := e.warmPoolTemplate(hostBinds, volumes)
+	if err != nil || !ok {
+		return err
+	}
+
+	set, err := pool.Take(template)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		e.BuildLogger.Debugln("No pre-created containers in the warm pool, creating them...")
+		return nil
+	}
+
+	if err := e.adoptWarmContainers(set); err != nil {
+		e.BuildLogger.Warningln("Using the pre-created containers of the warm pool failed, creating them:", err)
+		pool.Remove(e.Context, set)
+		e.helperContainer, e.buildContainer, e.buildContainerID = nil, nil, ""
+		return nil
+	}
+
+	e.usesWarmContainers = true
+	e.warmContainerIDs = []string{set.HelperID, set.BuildID}
+	e.temporary = append(e.temporary, set.HelperID, set.BuildID)
+	e.BuildLogger.Println("Using pre-created containers from the warm pool")
+
+	// the volumes of the set are removed with the job's temporary volumes
+	return e.volumesManager.Adopt(e.Context, e.buildContainer.Mounts)
+}
+
+// adoptWarmContainers gives the containers of the set the names of the job's
+// containers.
+func (e *executor) adoptWarmContainers(set *warmpool.Set) error {
+	containers := []struct{ containerType, id string }{
+		{predefinedContainerType, set.HelperID},
+		{buildContainerType, set.BuildID},
+	}
+
+	for _, c := range containers {
+		containerType, id := c.containerType, c.id
+		if err := e.dockerConn.ContainerRename(e.Context, id, e.makeContainerName(containerType)); err != nil {
+			return fmt.Errorf("renaming %s container: %w", containerType, err)
+		}
+
+		inspect, err := e.dockerConn.ContainerInspect(e.Context, id)
+		if err != nil {
+			return fmt.Errorf("inspecting %s container: %w", containerType, err)
+		}
+
+		if containerType == buildContainerType {
+			e.buildContainer = &inspect
+			e.buildContainerID = inspect.ID
+		} else {
+			e.helperContainer = &inspect
+		}
+	}
+
+	if data, ok := e.Build.ExecutorData.(*executorData); ok {
+		data.ContainerName = e.helperContainer.Name
+	}
+
+	return nil
+}
+
+// canUseWarmPool returns whether the containers of the job can be
+// pre-created, which they can't when their configuration depends on more than
+// the job image and the runner configuration.
+func (e *executor) canUseWarmPool() (bool, error) {
+	if !e.isWarmPoolImage() {
+		return false, nil
+	}
+
+	reason := ""
+	switch {
+	case e.info.OSType == osTypeWindows:
+		reason = "Windows isn't supported"
+	case e.Build.UseNativeSteps():
+		reason = "native steps aren't supported"
+	case e.Build.IsFeatureFlagOn(featureflags.DisableUmaskForDockerExecutor):
+		reason = featureflags.DisableUmaskForDockerExecutor + " isn't supported"
+	case e.networkMode.UserDefined() != "":
+		reason = "user-defined networks aren't supported"
+	case e.Build.GetGitStrategy() == common.GitFetch:
+		reason = "the fetch Git strategy isn't supported"
+	case e.containerLabelsUseVariables():
+		reason = "container_labels with variables aren't supported"
+	}
+
+	if reason == "" {
+		services, err := e.getServicesDefinitions()
+		if err != nil {
+			return false, err
+		}
+		if len(services) > 0 {
+			reason = "services aren't supported"
+		}
+	}
+
+	if reason != "" {
+		e.BuildLogger.Debugln("Not using the warm pool:", reason)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (e *executor) isWarmPoolImage() bool {
+	images := e.Config.Docker.WarmPool.Images
+	if len(images) == 0 {
+		return true
+	}
+
+	imageName, err := e.expandImageName(e.Build.Image.Name, []string{})
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range images {
+		if ok, _ := doublestar.Match(pattern, imageName); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// warmPoolVolumes returns the host binds of the containers, and the
+// destinations of the volumes the warm pool creates for each set: the builds
+// directory, and the cache volumes when the cache is disabled. Cache volumes
+// that are kept between jobs depend on the project, and can't be pre-created.
+func (e *executor) warmPoolVolumes() ([]string, []string, bool, error) {
+	ok, err := e.canUseWarmPool()
+	if err != nil || !ok {
+		return nil, nil, false, err
+	}
+
+	var binds, volumes []string
+	destinations := map[string]bool{}
+
+	for _, definition := range e.Config.Docker.Volumes {
+		volume, err := e.volumeParser.ParseVolume(definition)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("parse volume: %w", err)
+		}
+
+		if volume.Len() == 1 && !e.Config.Docker.DisableCache {
+			e.BuildLogger.Debugln("Not using the warm pool: the volume", definition, "depends on the project")
+			return nil, nil, false, nil
+		}
+
+		if volume.Len() == 1 {
+			volumes = append(volumes, volume.Destination)
+		} else {
+			binds = append(binds, volume.Definition())
+		}
+		destinations[volume.Destination] = true
+	}
+
+	if !destinations[e.Build.RootDir] {
+		volumes = append(volumes, e.Build.RootDir)
+	}
+
+	return binds, volumes, true, nil
+}
+
+// containerLabelsUseVariables returns whether the values of the
+// container_labels depend on the job variables.
+func (e *executor) containerLabelsUseVariables() bool {
+	for _, v := range e.Config.Docker.ContainerLabels {
+		if strings.Contains(v, "$") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runsImageEntrypoint returns whether the build container runs an
+// entrypoint: the entrypoint of the image, unless the job overrides it.
+func (e *executor) runsImageEntrypoint(img *image.InspectResponse) bool {
+	entrypoint := e.Build.Image.Entrypoint
+	if len(entrypoint) == 0 || e.Config.Docker.DisableEntrypointOverwrite {
+		entrypoint = nil
+		if img.Config != nil {
+			entrypoint = img.Config.Entrypoint
+		}
+	}
+
+	return strings.Join(entrypoint, "") != ""
+}
+
+// warmPoolTemplate returns the configuration of the job's containers without
+// the parts that depend on the job: its variables, labels, hostname and
+// volumes. The containers of jobs running an entrypoint aren't pre-created:
+// the entrypoint would run without the job variables.
+func (e *executor) warmPoolTemplate(hostBinds, volumes []string) (warmpool.Template, bool, error) {
+	buildImage, err := e.getBuildImage()
+	if err != nil {
+		return warmpool.Template{}, false, err
+	}
+
+	if e.runsImageEntrypoint(buildImage) {
+		e.BuildLogger.Debugln("Not using the warm pool: the image entrypoint needs the job variables")
+		return warmpool.Template{}, false, nil
+	}
+
+	helperImage, err := e.getHelperImage()
+	if err != nil {
+		return warmpool.Template{}, false, err
+	}
+
+	build, err := e.warmPoolContainer(buildContainerType, e.Build.Image, buildImage, e.BuildShell.DockerCommand, hostBinds)
+	if err != nil {
+		return warmpool.Template{}, false, err
+	}
+
+	helperDefinition := spec.Image{Name: helperImage.ID}
+	helper, err := e.warmPoolContainer(predefinedContainerType, helperDefinition, helperImage, e.helperImageInfo.Cmd, hostBinds)
+	if err != nil {
+		return warmpool.Template{}, false, err
+	}
+
+	return warmpool.Template{
+		BuildImageID:     buildImage.ID,
+		HelperImageID:    helperImage.ID,
+		Build:            build,
+		Helper:           helper,
+		Volumes:          volumes,
+		VolumeDriver:     e.Config.Docker.VolumeDriver,
+		VolumeDriverOpts: e.Config.Docker.VolumeDriverOps,
+		VolumeLabels:     e.warmPoolLabels(map[string]string{"type": "cache", "reusable": "false"}),
+		HelperImage:      helperImage,
+	}, true, nil
+}
+
+func (e *executor) warmPoolContainer(
+	containerType string,
+	imageDefinition spec.Image,
+	img *image.InspectResponse,
+	cmd []string,
+	hostBinds []string,
+) (warmpool.Container, error) {
+	cfgTor := newDefaultContainerConfigurator(e, containerType, imageDefinition, cmd, []string{img.ID})
+
+	config, err := cfgTor.ContainerConfig(img)
+	if err != nil {
+		return warmpool.Container{}, fmt.Errorf("failed to create container configuration: %w", err)
+	}
+	config.Hostname = e.Config.Docker.Hostname
+	config.Env = nil
+	config.Labels = e.warmPoolLabels(map[string]string{"type": containerType})
+	// the container_labels don't depend on the job: their values have no
+	// variables
+	for k, v := range e.Config.Docker.ContainerLabels {
+		config.Labels[k] = v
+	}
+
+	hostConfig, err := cfgTor.HostConfig()
+	if err != nil {
+		return warmpool.Container{}, err
+	}
+	hostConfig.Binds = hostBinds
+
+	networkConfig, err := cfgTor.NetworkConfig(nil)
+	if err != nil {
+		return warmpool.Container{}, err
+	}
+
+	c := warmpool.Container{
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: networkConfig,
+	}
+	if containerType == buildContainerType {
+		c.Platform = platformForImage(img, imageDefinition.ExecutorOptions)
+	}
+
+	return c, nil
+}
+
+// warmPoolLabels returns the labels of the runner, without the labels of the
+// job.
+func (e *executor) warmPoolLabels(otherLabels map[string]string) map[string]string {
+	labels := map[string]string{
+		e.labeler.LabelKey("managed"):          "true",
+		e.labeler.LabelKey("runner.id"):        e.Build.Runner.ShortDescription(),
+		e.labeler.LabelKey("runner.system_id"): e.Build.Runner.SystemID,
+	}
+
+	for k, v := range otherLabels {
+		labels[e.labeler.LabelKey(k)] = v
+	}
+
+	return labels
+}
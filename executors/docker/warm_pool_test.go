@@ -0,0 +1,246 @@
+//go:build !integration
+
+package docker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/volumes/parser"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/docker/internal/warmpool"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker"
+)
+
+func newWarmPoolTestExecutor(t *testing.T, c *docker.MockClient) *executor {
+	e := newTestExecutor(t, c)
+	e.Config.Docker.WarmPool = &common.DockerWarmPoolConfig{}
+	e.Build.Image = spec.Image{Name: "golangci/golangci-lint:v2"}
+	e.Build.RootDir = "/builds"
+	e.volumeParser = parser.NewLinuxParser(e.ExpandValue)
+
+	return e
+}
+
+func TestWarmPoolVolumes(t *testing.T) {
+	tests := map[string]struct {
+		setup           func(e *executor)
+		expectedOK      bool
+		expectedBinds   []string
+		expectedVolumes []string
+	}{
+		"builds volume": {
+			expectedOK:      true,
+			expectedVolumes: []string{"/builds"},
+		},
+		"host binds": {
+			setup: func(e *executor) {
+				e.Config.Docker.Volumes = []string{"/certs:/certs:ro"}
+			},
+			expectedOK:      true,
+			expectedBinds:   []string{"/certs:/certs:ro"},
+			expectedVolumes: []string{"/builds"},
+		},
+		"builds directory on the host": {
+			setup: func(e *executor) {
+				e.Config.Docker.Volumes = []string{"/srv/builds:/builds"}
+			},
+			expectedOK:    true,
+			expectedBinds: []string{"/srv/builds:/builds"},
+		},
+		"cache volume with the cache disabled": {
+			setup: func(e *executor) {
+				e.Config.Docker.Volumes = []string{"/cache"}
+				e.Config.Docker.DisableCache = true
+			},
+			expectedOK:      true,
+			expectedVolumes: []string{"/cache", "/builds"},
+		},
+		"cache volume kept between jobs": {
+			setup: func(e *executor) {
+				e.Config.Docker.Volumes = []string{"/cache"}
+			},
+		},
+		"image not in the pool": {
+			setup: func(e *executor) {
+				e.Config.Docker.WarmPool.Images = []string{"alpine:*"}
+			},
+		},
+		"image in the pool": {
+			setup: func(e *executor) {
+				e.Config.Docker.WarmPool.Images = []string{"golangci/*"}
+			},
+			expectedOK:      true,
+			expectedVolumes: []string{"/builds"},
+		},
+		"services": {
+			setup: func(e *executor) {
+				e.Build.Services = spec.Services{{Name: "postgres:16"}}
+			},
+		},
+		"fetch strategy": {
+			setup: func(e *executor) {
+				e.Build.Variables = append(e.Build.Variables, spec.Variable{Key: "GIT_STRATEGY", Value: "fetch"})
+			},
+		},
+		"container labels": {
+			setup: func(e *executor) {
+				e.Config.Docker.ContainerLabels = map[string]string{"team": "lint"}
+			},
+			expectedOK:      true,
+			expectedVolumes: []string{"/builds"},
+		},
+		"container labels with variables": {
+			setup: func(e *executor) {
+				e.Config.Docker.ContainerLabels = map[string]string{"project": "$CI_PROJECT_PATH"}
+			},
+		},
+		"user-defined network": {
+			setup: func(e *executor) {
+				e.networkMode = "runner-abcd-build-network"
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := newWarmPoolTestExecutor(t, docker.NewMockClient(t))
+			if tc.setup != nil {
+				tc.setup(e)
+			}
+
+			binds, volumes, ok, err := e.warmPoolVolumes()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedBinds, binds)
+			assert.Equal(t, tc.expectedVolumes, volumes)
+		})
+	}
+}
+
+func TestRunsImageEntrypoint(t *testing.T) {
+	tests := map[string]struct {
+		imageEntrypoint  []string
+		jobEntrypoint    []string
+		disableOverwrite bool
+		expectEntrypoint bool
+	}{
+		"no entrypoint": {},
+		"image entrypoint": {
+			imageEntrypoint:  []string{"/docker-entrypoint.sh"},
+			expectEntrypoint: true,
+		},
+		"image entrypoint overridden by the job": {
+			imageEntrypoint: []string{"/docker-entrypoint.sh"},
+			jobEntrypoint:   []string{""},
+		},
+		"job entrypoint": {
+			jobEntrypoint:    []string{"/bin/sh", "-c"},
+			expectEntrypoint: true,
+		},
+		"image entrypoint override disabled": {
+			imageEntrypoint:  []string{"/docker-entrypoint.sh"},
+			jobEntrypoint:    []string{""},
+			disableOverwrite: true,
+			expectEntrypoint: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := newWarmPoolTestExecutor(t, docker.NewMockClient(t))
+			e.Build.Image.Entrypoint = tc.jobEntrypoint
+			e.Config.Docker.DisableEntrypointOverwrite = tc.disableOverwrite
+
+			img := &image.InspectResponse{Config: &dockerspec.DockerOCIImageConfig{}}
+			img.Config.Entrypoint = tc.imageEntrypoint
+
+			assert.Equal(t, tc.expectEntrypoint, e.runsImageEntrypoint(img))
+		})
+	}
+}
+
+func TestAdoptWarmContainers(t *testing.T) {
+	c := docker.NewMockClient(t)
+	e := newWarmPoolTestExecutor(t, c)
+	data := &executorData{}
+	e.Build.ExecutorData = data
+
+	set := &warmpool.Set{BuildID: "build-id", HelperID: "helper-id"}
+
+	c.EXPECT().ContainerRename(mock.Anything, "helper-id", e.makeContainerName(predefinedContainerType)).Return(nil).Once()
+	c.EXPECT().ContainerInspect(mock.Anything, "helper-id").
+		Return(container.InspectResponse{ID: "helper-id", Name: "/" + e.makeContainerName(predefinedContainerType)}, nil).Once()
+	c.EXPECT().ContainerRename(mock.Anything, "build-id", e.makeContainerName(buildContainerType)).Return(nil).Once()
+	c.EXPECT().ContainerInspect(mock.Anything, "build-id").
+		Return(container.InspectResponse{ID: "build-id"}, nil).Once()
+
+	require.NoError(t, e.adoptWarmContainers(set))
+
+	assert.Equal(t, "build-id", e.buildContainerID)
+	assert.Equal(t, "build-id", e.buildContainer.ID)
+	assert.Equal(t, "helper-id", e.helperContainer.ID)
+	assert.Equal(t, "/"+e.makeContainerName(predefinedContainerType), data.ContainerName)
+}
+
+func TestTakeWarmContainers_Miss(t *testing.T) {
+	c := docker.NewMockClient(t)
+	e := newWarmPoolTestExecutor(t, c)
+	e.Build.ExecutorData = &executorData{}
+
+	// no pool
+	require.NoError(t, e.takeWarmContainers())
+	assert.False(t, e.usesWarmContainers)
+}
+
+func TestWarmPools(t *testing.T) {
+	c := docker.NewMockClient(t)
+	c.EXPECT().ContainerList(mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	c.EXPECT().VolumeList(mock.Anything, mock.Anything).Return(client.VolumeListResult{}, nil).Maybe()
+	var closed atomic.Int32
+	c.EXPECT().Close().RunAndReturn(func() error {
+		closed.Add(1)
+		return nil
+	}).Twice()
+
+	pools := newWarmPools()
+	pools.newClient = func(docker.Credentials) (docker.Client, error) {
+		return c, nil
+	}
+
+	config := &common.RunnerConfig{
+		RunnerCredentials: common.RunnerCredentials{Token: "glrt-abcd1234"},
+		RunnerSettings: common.RunnerSettings{
+			Docker: &common.DockerConfig{WarmPool: &common.DockerWarmPoolConfig{}},
+		},
+		ConfigLoadedAt: time.Now(),
+	}
+
+	pool := pools.get(config)
+	require.NotNil(t, pool)
+	assert.Same(t, pool, pools.get(config))
+
+	// the pool is restarted when the configuration changes
+	reloaded := *config
+	reloaded.ConfigLoadedAt = config.ConfigLoadedAt.Add(time.Minute)
+	restarted := pools.get(&reloaded)
+	require.NotNil(t, restarted)
+	assert.NotSame(t, pool, restarted)
+
+	pools.shutdown(t.Context())
+	assert.Empty(t, pools.pools)
+	assert.Eventually(t, func() bool { return closed.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, (*warmPools)(nil).get(config))
+	assert.Nil(t, newWarmPools().get(&common.RunnerConfig{RunnerSettings: common.RunnerSettings{Docker: &common.DockerConfig{}}}))
+}
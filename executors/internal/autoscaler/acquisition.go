@@ -39,6 +39,11 @@ type acquisitionRef struct {
 
 	mapJobImageToVMImage bool
 
+	// preparedAt is when the preparation of the job started, for the
+	// predictive autoscaling to record how long the job held capacity. It's
+	// zero until the job is recorded.
+	preparedAt time.Time
+
 	// test hooks
 	dialAcquisitionInstance connector.DialFn
 	dialTunnel              connector.DialFn
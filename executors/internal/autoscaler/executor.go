@@ -13,14 +13,17 @@ import (
 	"gitlab.com/gitlab-org/fleeting/taskscaler"
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
 	"gitlab.com/gitlab-org/gitlab-runner/session/terminal"
 	"gitlab.com/gitlab-org/gitlab-runner/steps"
 )
 
 var (
-	_ terminal.InteractiveTerminal = (*executor)(nil)
-	_ steps.Connector              = (*executor)(nil)
-	_ common.SuspendableExecutor   = (*executor)(nil)
+	_ terminal.InteractiveTerminal    = (*executor)(nil)
+	_ steps.Connector                 = (*executor)(nil)
+	_ common.SuspendableExecutor      = (*executor)(nil)
+	_ executors.ExecutorOptionsGetter = (*executor)(nil)
 )
 
 type executor struct {
@@ -31,6 +34,16 @@ type executor struct {
 	config   common.RunnerConfig
 }
 
+// GetExecutorOptions returns the options of the executor the jobs run with
+// on the instances
+func (e *executor) GetExecutorOptions() executors.ExecutorOptions {
+	if getter, ok := e.Executor.(executors.ExecutorOptionsGetter); ok {
+		return getter.GetExecutorOptions()
+	}
+
+	return executors.ExecutorOptions{}
+}
+
 func (e *executor) Prepare(options common.ExecutorPrepareOptions) (err error) {
 	e.build = options.Build
 	e.config = *options.Config
@@ -42,6 +55,13 @@ func (e *executor) Prepare(options common.ExecutorPrepareOptions) (err error) {
 		return errors.New("no acquisition ref data")
 	}
 
+	// The preparation is retried with the same acquisition ref, the job is
+	// recorded once
+	if acqRef.preparedAt.IsZero() {
+		acqRef.preparedAt = time.Now()
+		e.provider.recordJobArrival(options.Config, options.Build.JobInfo.TimeInQueueSeconds)
+	}
+
 	// The acqTimeout defines how long we are willing to wait for an instance to be acquired.
 	// It defaults to 15 minutes, as cloud providers can take several minutes to provision instances,
 	// especially for certain operating systems like Windows. This value can be configured
@@ -178,6 +198,22 @@ func (e *executor) Connect(ctx context.Context) (func() (io.ReadWriteCloser, err
 	return nil, common.ErrExecutorStepRunnerConnectNotSupported
 }
 
+func (e *executor) SampleResourceUsage(ctx context.Context) (map[string]referees.ResourceUsage, error) {
+	if refereed, ok := e.Executor.(referees.ResourceUsageExecutor); ok {
+		return refereed.SampleResourceUsage(ctx)
+	}
+
+	return nil, errors.New("executor doesn't support resource usage sampling")
+}
+
+func (e *executor) MemoryPeakUsage(ctx context.Context) (map[string]uint64, error) {
+	if refereed, ok := e.Executor.(referees.ResourceUsageExecutor); ok {
+		return refereed.MemoryPeakUsage(ctx)
+	}
+
+	return nil, errors.New("executor doesn't support resource usage sampling")
+}
+
 func (e *executor) TerminalConnect() (terminal.Conn, error) {
 	if connector, ok := e.Executor.(terminal.InteractiveTerminal); ok {
 		return connector.TerminalConnect()
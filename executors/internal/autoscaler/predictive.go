@@ -0,0 +1,431 @@
+package autoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/fleeting/taskscaler"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	// arrivalBucketSize is the resolution of the job arrival history
+	arrivalBucketSize = 5 * time.Minute
+
+	predictorUpdateInterval = time.Minute
+
+	// jobDurationSmoothing is the number of jobs the average job duration is
+	// smoothed over
+	jobDurationSmoothing = 20
+)
+
+var _ prometheus.Collector = (*predictor)(nil)
+
+// arrivalHistory counts the job arrivals of a runner, per bucket of
+// arrivalBucketSize.
+type arrivalHistory struct {
+	// Since is when the recording started. The weeks before aren't part of
+	// the forecasts, rather than being counted as weeks without jobs.
+	Since    time.Time       `json:"since"`
+	Arrivals map[int64]int64 `json:"arrivals"`
+	// JobDuration is the moving average of how long the jobs hold capacity,
+	// zero until a job finished.
+	JobDuration time.Duration `json:"job_duration,omitempty"`
+}
+
+func newArrivalHistory(since time.Time) *arrivalHistory {
+	return &arrivalHistory{
+		Since:    since,
+		Arrivals: make(map[int64]int64),
+	}
+}
+
+func loadArrivalHistory(path string) (*arrivalHistory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var h arrivalHistory
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	if h.Arrivals == nil {
+		h.Arrivals = make(map[int64]int64)
+	}
+
+	return &h, nil
+}
+
+// save writes the history to a temporary file renamed to path, so that a
+// crash doesn't leave a truncated history behind
+func (h *arrivalHistory) save(path string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (h *arrivalHistory) record(t time.Time) {
+	h.Arrivals[t.Truncate(arrivalBucketSize).Unix()]++
+}
+
+func (h *arrivalHistory) observeDuration(d time.Duration) {
+	if h.JobDuration <= 0 {
+		h.JobDuration = d
+		return
+	}
+
+	h.JobDuration += (d - h.JobDuration) / jobDurationSmoothing
+}
+
+// prune removes the buckets older than the history used by the forecasts
+func (h *arrivalHistory) prune(now time.Time, weeks int) {
+	oldest := now.AddDate(0, 0, -7*weeks).Truncate(arrivalBucketSize).Unix()
+	for bucket := range h.Arrivals {
+		if bucket < oldest {
+			delete(h.Arrivals, bucket)
+		}
+	}
+}
+
+// count returns the number of arrivals in the buckets of [start, end)
+func (h *arrivalHistory) count(start, end time.Time) int64 {
+	var n int64
+	for b := start.Truncate(arrivalBucketSize); b.Before(end); b = b.Add(arrivalBucketSize) {
+		n += h.Arrivals[b.Unix()]
+	}
+
+	return n
+}
+
+// forecast returns the average number of arrivals in the interval starting
+// at now, on the same weekday and time of the previous weeks of the
+// history. It returns 0 when the history doesn't cover a week yet.
+func (h *arrivalHistory) forecast(now time.Time, interval time.Duration, weeks int) float64 {
+	var total, samples int64
+	for week := 1; week <= weeks; week++ {
+		start := now.AddDate(0, 0, -7*week)
+		if start.Before(h.Since) {
+			break
+		}
+
+		total += h.count(start, start.Add(interval))
+		samples++
+	}
+
+	if samples == 0 {
+		return 0
+	}
+
+	return float64(total) / float64(samples)
+}
+
+// concurrentJobs returns the average number of jobs running at once when the
+// arrivals are received over the interval: the arrival rate times the average
+// job duration. Until a job finished, the jobs are assumed to run for the
+// whole interval.
+func (h *arrivalHistory) concurrentJobs(arrivals float64, interval time.Duration) float64 {
+	if h.JobDuration <= 0 {
+		return arrivals
+	}
+
+	return arrivals * float64(h.JobDuration) / float64(interval)
+}
+
+// predictor sets the idle count of the taskscaler schedules from the number of
+// concurrent jobs forecast for the next interval, bounded by the minimum and
+// maximum idle counts of the predictive configuration.
+type predictor struct {
+	mu        sync.Mutex
+	ts        taskscaler.Taskscaler
+	config    common.AutoscalerPredictiveConfig
+	policies  []common.AutoscalerPolicyConfig
+	history   *arrivalHistory
+	dirty     bool
+	idleCount int
+	applied   bool
+
+	// path is where the history is persisted, empty when the state storage
+	// is disabled
+	path   string
+	logger logrus.FieldLogger
+
+	arrivals           prometheus.Counter
+	forecastArrivals   prometheus.Gauge
+	forecastJobs       prometheus.Gauge
+	predictedIdleCount prometheus.Gauge
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// For testing
+	now func() time.Time
+}
+
+func newPredictor(
+	ts taskscaler.Taskscaler,
+	config *common.AutoscalerConfig,
+	path string,
+	logger logrus.FieldLogger,
+	constLabels prometheus.Labels,
+) *predictor {
+	p := &predictor{
+		ts:       ts,
+		config:   *config.Predictive,
+		policies: config.Policy,
+		path:     path,
+		logger:   logger,
+		arrivals: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "gitlab_runner_autoscaler_predictive_job_arrivals_total",
+			Help:        "Total number of jobs received by the runner, recorded for the predictive autoscaling.",
+			ConstLabels: constLabels,
+		}),
+		forecastArrivals: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "gitlab_runner_autoscaler_predictive_forecast_job_arrivals",
+			Help:        "The number of jobs forecast to be received in the next predictive autoscaling interval.",
+			ConstLabels: constLabels,
+		}),
+		forecastJobs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "gitlab_runner_autoscaler_predictive_forecast_concurrent_jobs",
+			Help:        "The number of jobs forecast to run concurrently in the next predictive autoscaling interval.",
+			ConstLabels: constLabels,
+		}),
+		predictedIdleCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "gitlab_runner_autoscaler_predictive_idle_count",
+			Help:        "The idle count set by the predictive autoscaling.",
+			ConstLabels: constLabels,
+		}),
+		now: time.Now,
+	}
+
+	p.history = p.loadHistory()
+
+	return p
+}
+
+// loadHistory restores the persisted history, or starts a new one when there
+// is none or it can't be read
+func (p *predictor) loadHistory() *arrivalHistory {
+	if p.path != "" {
+		h, err := loadArrivalHistory(p.path)
+		if err == nil {
+			return h
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			p.logger.WithError(err).Warningln("Failed to load the job arrival history, starting a new one")
+		}
+	}
+
+	return newArrivalHistory(p.now())
+}
+
+// configure updates the predictive configuration and the policies the idle
+// count is applied to, and reconfigures the taskscaler schedules. The
+// predictive autoscaling is enabled in the configuration, the predictor being
+// dropped otherwise.
+func (p *predictor) configure(config *common.AutoscalerConfig) error {
+	p.mu.Lock()
+	p.config = *config.Predictive
+	p.policies = config.Policy
+	p.applied = false
+	p.mu.Unlock()
+
+	return p.update()
+}
+
+// recordArrival records a job received after waiting in the queue. The job
+// is recorded when it was queued rather than when it was received, as the
+// runner only requests jobs when it has capacity for them.
+func (p *predictor) recordArrival(queued time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.history.record(p.now().Add(-queued))
+	p.dirty = true
+
+	p.arrivals.Inc()
+}
+
+// recordDuration records how long a job held capacity
+func (p *predictor) recordDuration(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.history.observeDuration(d)
+	p.dirty = true
+}
+
+// update forecasts the concurrent jobs of the next interval, and reconfigures
+// the taskscaler schedules when the resulting idle count changed
+func (p *predictor) update() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	weeks := p.config.GetHistoryWeeks()
+
+	p.history.prune(now, weeks)
+
+	interval := p.config.GetInterval()
+	forecast := p.history.forecast(now, interval, weeks)
+	jobs := p.history.concurrentJobs(forecast, interval)
+	idleCount := p.config.ClampIdleCount(int(math.Ceil(jobs)))
+
+	p.forecastArrivals.Set(forecast)
+	p.forecastJobs.Set(jobs)
+	p.predictedIdleCount.Set(float64(idleCount))
+
+	if p.applied && idleCount == p.idleCount {
+		return nil
+	}
+
+	if err := p.ts.ConfigureSchedule(predictiveSchedules(p.policies, idleCount)...); err != nil {
+		return err
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"forecast":        forecast,
+		"concurrent_jobs": jobs,
+		"idle_count":      idleCount,
+	}).Debugln("Predictive autoscaling updated the idle count")
+
+	p.idleCount = idleCount
+	p.applied = true
+
+	return nil
+}
+
+func (p *predictor) saveHistory() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.path == "" || !p.dirty {
+		return
+	}
+
+	if err := p.history.save(p.path); err != nil {
+		p.logger.WithError(err).Warningln("Failed to save the job arrival history")
+		return
+	}
+
+	p.dirty = false
+}
+
+func (p *predictor) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Go(func() {
+		ticker := time.NewTicker(predictorUpdateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := p.update(); err != nil {
+				p.logger.WithError(err).Warningln("Failed to configure the taskscaler schedules")
+			}
+
+			p.saveHistory()
+		}
+	})
+}
+
+func (p *predictor) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	p.saveHistory()
+}
+
+func (p *predictor) Describe(ch chan<- *prometheus.Desc) {
+	p.arrivals.Describe(ch)
+	p.forecastArrivals.Describe(ch)
+	p.forecastJobs.Describe(ch)
+	p.predictedIdleCount.Describe(ch)
+}
+
+func (p *predictor) Collect(ch chan<- prometheus.Metric) {
+	p.arrivals.Collect(ch)
+	p.forecastArrivals.Collect(ch)
+	p.forecastJobs.Collect(ch)
+	p.predictedIdleCount.Collect(ch)
+}
+
+// policySchedules converts the autoscaler policies to taskscaler schedules
+func policySchedules(policies []common.AutoscalerPolicyConfig) []taskscaler.Schedule {
+	var schedules []taskscaler.Schedule
+	for _, schedule := range policies {
+		schedules = append(schedules, taskscaler.Schedule{
+			Periods:          schedule.Periods,
+			Timezone:         schedule.Timezone,
+			IdleCount:        schedule.IdleCount,
+			IdleTime:         schedule.IdleTime,
+			ScaleFactor:      schedule.ScaleFactor,
+			ScaleFactorLimit: schedule.ScaleFactorLimit,
+			PreemptiveMode:   schedule.PreemptiveModeEnabled(),
+		})
+	}
+
+	return schedules
+}
+
+// predictiveSchedules returns the schedules of the policies with the idle
+// count replaced by the predicted one, or a schedule for all periods with
+// the predicted idle count when there is no policy
+func predictiveSchedules(policies []common.AutoscalerPolicyConfig, idleCount int) []taskscaler.Schedule {
+	if len(policies) == 0 {
+		policies = []common.AutoscalerPolicyConfig{{}}
+	}
+
+	predicted := make([]common.AutoscalerPolicyConfig, 0, len(policies))
+	for _, policy := range policies {
+		policy.IdleCount = idleCount
+		predicted = append(predicted, policy)
+	}
+
+	return policySchedules(predicted)
+}
+
+// predictiveHistoryPath returns where the job arrival history of the runner
+// is persisted, next to the taskscaler state, or an empty path when the state
+// storage is disabled
+func predictiveHistoryPath(config *common.RunnerConfig) string {
+	if !config.Autoscaler.StateStorage.Enabled {
+		return ""
+	}
+
+	return stateStoragePath(config) + ".arrivals.json"
+}
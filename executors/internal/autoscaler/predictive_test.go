@@ -0,0 +1,241 @@
+//go:build !integration
+
+package autoscaler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/fleeting/taskscaler"
+	"gitlab.com/gitlab-org/fleeting/taskscaler/mocks"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+// monday is a Monday at 09:00
+var monday = time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+func TestArrivalHistory_Forecast(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	tests := map[string]struct {
+		since    time.Time
+		arrivals []time.Time
+		expected float64
+	}{
+		"history shorter than a week": {
+			since:    monday.Add(-24 * time.Hour),
+			arrivals: []time.Time{monday.Add(-time.Hour)},
+			expected: 0,
+		},
+		"average of the same interval of the previous weeks": {
+			since: monday.Add(-3 * week),
+			arrivals: []time.Time{
+				// a week ago, in the interval
+				monday.Add(-week),
+				monday.Add(-week + 5*time.Minute),
+				monday.Add(-week + 14*time.Minute),
+				// a week ago, after the interval
+				monday.Add(-week + 15*time.Minute),
+				// two weeks ago, before the interval
+				monday.Add(-2*week - time.Minute),
+				// three weeks ago, in the interval
+				monday.Add(-3*week + 10*time.Minute),
+				monday.Add(-3*week + 10*time.Minute),
+				monday.Add(-3*week + 12*time.Minute),
+				// the other days aren't part of the forecast
+				monday.Add(-24 * time.Hour),
+			},
+			expected: 2,
+		},
+		"only the weeks covered by the history": {
+			since: monday.Add(-week - time.Hour),
+			arrivals: []time.Time{
+				monday.Add(-week + time.Minute),
+			},
+			expected: 1,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			h := newArrivalHistory(tt.since)
+			for _, arrival := range tt.arrivals {
+				h.record(arrival)
+			}
+
+			assert.Equal(t, tt.expected, h.forecast(monday, 15*time.Minute, 4))
+		})
+	}
+}
+
+func TestArrivalHistory_Prune(t *testing.T) {
+	h := newArrivalHistory(monday.AddDate(0, 0, -28))
+	h.record(monday.AddDate(0, 0, -15))
+	h.record(monday.AddDate(0, 0, -13))
+	h.record(monday)
+
+	h.prune(monday, 2)
+
+	assert.Equal(t, map[int64]int64{
+		monday.AddDate(0, 0, -13).Unix(): 1,
+		monday.Unix():                    1,
+	}, h.Arrivals)
+}
+
+func TestArrivalHistory_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "token.arrivals.json")
+
+	h := newArrivalHistory(monday)
+	h.record(monday.Add(time.Minute))
+	h.record(monday.Add(2 * time.Minute))
+
+	h.observeDuration(10 * time.Minute)
+	require.NoError(t, h.save(path))
+
+	loaded, err := loadArrivalHistory(path)
+	require.NoError(t, err)
+	assert.True(t, h.Since.Equal(loaded.Since))
+	assert.Equal(t, h.Arrivals, loaded.Arrivals)
+	assert.Equal(t, 10*time.Minute, loaded.JobDuration)
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = loadArrivalHistory(path)
+	assert.Error(t, err)
+}
+
+func TestArrivalHistory_ConcurrentJobs(t *testing.T) {
+	h := newArrivalHistory(monday)
+
+	// without a job duration, the jobs are assumed to run the whole interval
+	assert.Equal(t, 6.0, h.concurrentJobs(6, 15*time.Minute))
+
+	h.observeDuration(5 * time.Minute)
+	assert.Equal(t, 5*time.Minute, h.JobDuration)
+	assert.Equal(t, 2.0, h.concurrentJobs(6, 15*time.Minute))
+
+	h.observeDuration(25 * time.Minute)
+	assert.Equal(t, 6*time.Minute, h.JobDuration)
+}
+
+func TestPredictiveSchedules(t *testing.T) {
+	assert.Equal(t, []taskscaler.Schedule{
+		{IdleCount: 3, PreemptiveMode: true},
+	}, predictiveSchedules(nil, 3))
+
+	assert.Equal(t, []taskscaler.Schedule{
+		{Periods: []string{"* * * * *"}, IdleCount: 2, IdleTime: time.Minute, PreemptiveMode: true},
+		{Periods: []string{"* 8-15 * * mon-fri"}, IdleCount: 2, ScaleFactor: 0.5, PreemptiveMode: false},
+	}, predictiveSchedules([]common.AutoscalerPolicyConfig{
+		{Periods: []string{"* * * * *"}, IdleCount: 0, IdleTime: time.Minute},
+		{Periods: []string{"* 8-15 * * mon-fri"}, IdleCount: 10, ScaleFactor: 0.5, PreemptiveMode: new(false)},
+	}, 2))
+}
+
+func TestPredictor(t *testing.T) {
+	ts := mocks.NewTaskscaler(t)
+
+	config := &common.AutoscalerConfig{
+		Policy:     []common.AutoscalerPolicyConfig{{IdleTime: time.Minute}},
+		Predictive: &common.AutoscalerPredictiveConfig{MinIdleCount: 1, MaxIdleCount: 4},
+		StateStorage: common.AutoscalerStateStorage{
+			Enabled: true,
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "token.arrivals.json")
+	history := newArrivalHistory(monday.AddDate(0, 0, -14))
+	for range 4 {
+		history.record(monday.AddDate(0, 0, -7))
+	}
+	for range 2 {
+		history.record(monday.AddDate(0, 0, -14))
+	}
+	require.NoError(t, history.save(path))
+
+	p := newPredictor(ts, config, path, logrus.New(), prometheus.Labels{"runner": "token"})
+	p.now = func() time.Time { return monday }
+
+	schedule := func(idleCount int) []taskscaler.Schedule {
+		return []taskscaler.Schedule{{IdleCount: idleCount, IdleTime: time.Minute, PreemptiveMode: true}}
+	}
+
+	// (4 + 2) / 2 arrivals are forecast
+	ts.EXPECT().ConfigureSchedule(schedule(3)).Return(nil).Once()
+	require.NoError(t, p.configure(config))
+	assert.Equal(t, 3.0, testutil.ToFloat64(p.forecastArrivals))
+	assert.Equal(t, 3.0, testutil.ToFloat64(p.predictedIdleCount))
+
+	// the schedules are only reconfigured when the idle count changes
+	require.NoError(t, p.update())
+
+	// the forecast is bounded by the maximum idle count
+	for range 6 {
+		p.history.record(monday.AddDate(0, 0, -14).Add(time.Minute))
+	}
+	ts.EXPECT().ConfigureSchedule(schedule(4)).Return(nil).Once()
+	require.NoError(t, p.update())
+	assert.Equal(t, 6.0, testutil.ToFloat64(p.forecastArrivals))
+
+	// the 6 jobs forecast over the 15 minutes interval run for 5 minutes,
+	// so 2 of them run concurrently
+	p.recordDuration(5 * time.Minute)
+	ts.EXPECT().ConfigureSchedule(schedule(2)).Return(nil).Once()
+	require.NoError(t, p.update())
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.forecastJobs))
+
+	// the jobs are recorded when they were queued
+	p.recordArrival(0)
+	p.recordArrival(10 * time.Minute)
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.arrivals))
+
+	p.stop()
+
+	saved, err := loadArrivalHistory(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), saved.Arrivals[monday.Unix()])
+	assert.Equal(t, int64(1), saved.Arrivals[monday.Add(-10*time.Minute).Unix()])
+	assert.Equal(t, 5*time.Minute, saved.JobDuration)
+}
+
+func TestProvider_UpdateRunnerPredictor(t *testing.T) {
+	p := New(nil, Config{}).(*provider)
+
+	config := &common.RunnerConfig{
+		RunnerCredentials: common.RunnerCredentials{Token: "token"},
+		RunnerSettings: common.RunnerSettings{
+			Autoscaler: &common.AutoscalerConfig{},
+		},
+	}
+	p.scalers[runnerScalerKey(config)] = scaler{}
+
+	pred, dropped := p.updateRunnerPredictor(config)
+	assert.Nil(t, pred)
+	assert.Nil(t, dropped)
+
+	// enabled by a reload
+	config.Autoscaler.Predictive = &common.AutoscalerPredictiveConfig{MaxIdleCount: 2}
+	pred, dropped = p.updateRunnerPredictor(config)
+	require.NotNil(t, pred)
+	assert.Nil(t, dropped)
+	assert.Same(t, pred, p.getRunnerPredictor(config))
+
+	current, _ := p.updateRunnerPredictor(config)
+	assert.Same(t, pred, current, "the predictor is kept across reloads")
+
+	// disabled by a reload
+	config.Autoscaler.Predictive = nil
+	current, dropped = p.updateRunnerPredictor(config)
+	assert.Nil(t, current)
+	assert.Same(t, pred, dropped)
+	assert.Nil(t, p.getRunnerPredictor(config))
+
+	dropped.stop()
+}
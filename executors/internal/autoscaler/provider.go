@@ -55,8 +55,10 @@ type provider struct {
 
 type scaler struct {
 	internal       taskscaler.Taskscaler
+	predictor      *predictor
 	shutdown       func(context.Context)
 	configLoadedAt time.Time
+	constLabels    prometheus.Labels
 }
 
 type Config struct {
@@ -104,6 +106,9 @@ func (p *provider) Shutdown(ctx context.Context, _ *common.Config) {
 		wg.Add(1)
 		go func(sc scaler) {
 			defer wg.Done()
+			if sc.predictor != nil {
+				sc.predictor.stop()
+			}
 			sc.shutdown(ctx)
 		}(s)
 
@@ -139,12 +144,7 @@ func (p *provider) init(config *common.RunnerConfig) (taskscaler.Taskscaler, boo
 
 	var store storage.Storage
 	if config.Autoscaler.StateStorage.Enabled {
-		dir := config.Autoscaler.StateStorage.Dir
-		if dir == "" {
-			dir = filepath.Join(config.ConfigDir, ".taskscaler")
-		}
-
-		store, err = storage.NewFileStorage(filepath.Join(dir, helpers.ShortenToken(config.Token)))
+		store, err = storage.NewFileStorage(stateStoragePath(config))
 		if err != nil {
 			return nil, false, fmt.Errorf("creating state storage: %w", err)
 		}
@@ -245,6 +245,7 @@ func (p *provider) init(config *common.RunnerConfig) (taskscaler.Taskscaler, boo
 		return nil, false, fmt.Errorf("creating taskscaler: %w", err)
 	}
 
+	// the predictor, if any, is created when the schedules are configured
 	s = scaler{
 		internal: ts,
 		shutdown: func(ctx context.Context) {
@@ -253,6 +254,7 @@ func (p *provider) init(config *common.RunnerConfig) (taskscaler.Taskscaler, boo
 			runner.Kill()
 		},
 		configLoadedAt: config.ConfigLoadedAt,
+		constLabels:    constLabels,
 	}
 
 	p.scalers[runnerScalerKey(config)] = s
@@ -269,19 +271,7 @@ func (p *provider) Acquire(config *common.RunnerConfig) (common.ExecutorData, er
 
 	// reconfigure policy if the config has been reloaded
 	if refresh {
-		var schedules []taskscaler.Schedule
-		for _, schedule := range config.Autoscaler.Policy {
-			schedules = append(schedules, taskscaler.Schedule{
-				Periods:          schedule.Periods,
-				Timezone:         schedule.Timezone,
-				IdleCount:        schedule.IdleCount,
-				IdleTime:         schedule.IdleTime,
-				ScaleFactor:      schedule.ScaleFactor,
-				ScaleFactorLimit: schedule.ScaleFactorLimit,
-				PreemptiveMode:   schedule.PreemptiveModeEnabled(),
-			})
-		}
-		if err := scaler.ConfigureSchedule(schedules...); err != nil {
+		if err := p.configureSchedules(config); err != nil {
 			return nil, fmt.Errorf("configuring taskscaler schedules: %w", err)
 		}
 	}
@@ -312,6 +302,10 @@ func (p *provider) Release(config *common.RunnerConfig, data common.ExecutorData
 		return
 	}
 
+	if !acqRef.preparedAt.IsZero() {
+		p.recordJobDuration(config, time.Since(acqRef.preparedAt))
+	}
+
 	if acqRef.acq != nil {
 		p.getRunnerTaskscaler(config).Release(acqRef.key)
 		logrus.WithField("key", acqRef.key).Trace("Released capacity...")
@@ -343,6 +337,81 @@ func (p *provider) getRunnerTaskscaler(config *common.RunnerConfig) taskscaler.T
 	return p.scalers[runnerScalerKey(config)].internal
 }
 
+func (p *provider) getRunnerPredictor(config *common.RunnerConfig) *predictor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.scalers[runnerScalerKey(config)].predictor
+}
+
+// configureSchedules applies the policies of the reloaded configuration to
+// the taskscaler of the runner, through its predictor when the predictive
+// autoscaling is enabled. The predictor is created, or stopped, when the
+// predictive autoscaling was turned on, or off, by the reload.
+func (p *provider) configureSchedules(config *common.RunnerConfig) error {
+	pred, dropped := p.updateRunnerPredictor(config)
+	if dropped != nil {
+		dropped.stop()
+	}
+
+	if pred != nil {
+		return pred.configure(config.Autoscaler)
+	}
+
+	return p.getRunnerTaskscaler(config).ConfigureSchedule(policySchedules(config.Autoscaler.Policy)...)
+}
+
+// updateRunnerPredictor returns the predictor of the runner, created when the
+// predictive autoscaling is enabled and it doesn't have one yet, and the
+// predictor it dropped, if the predictive autoscaling was disabled
+func (p *provider) updateRunnerPredictor(config *common.RunnerConfig) (*predictor, *predictor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := runnerScalerKey(config)
+	s := p.scalers[key]
+
+	var dropped *predictor
+	switch {
+	case config.Autoscaler.Predictive != nil && s.predictor == nil:
+		s.predictor = newPredictor(s.internal, config.Autoscaler, predictiveHistoryPath(config), config.Log(), s.constLabels)
+		s.predictor.start()
+	case config.Autoscaler.Predictive == nil && s.predictor != nil:
+		dropped, s.predictor = s.predictor, nil
+	}
+
+	p.scalers[key] = s
+
+	return s.predictor, dropped
+}
+
+// recordJobArrival records a job received by the runner, after the time it
+// was queued for, in the history of the predictive autoscaling, when it's
+// enabled
+func (p *provider) recordJobArrival(config *common.RunnerConfig, timeInQueueSeconds float64) {
+	if pred := p.getRunnerPredictor(config); pred != nil {
+		pred.recordArrival(time.Duration(timeInQueueSeconds * float64(time.Second)))
+	}
+}
+
+// recordJobDuration records how long a job of the runner held capacity, when
+// the predictive autoscaling is enabled
+func (p *provider) recordJobDuration(config *common.RunnerConfig, d time.Duration) {
+	if pred := p.getRunnerPredictor(config); pred != nil {
+		pred.recordDuration(d)
+	}
+}
+
+// stateStoragePath returns the directory of the taskscaler state of the runner
+func stateStoragePath(config *common.RunnerConfig) string {
+	dir := config.Autoscaler.StateStorage.Dir
+	if dir == "" {
+		dir = filepath.Join(config.ConfigDir, ".taskscaler")
+	}
+
+	return filepath.Join(dir, helpers.ShortenToken(config.Token))
+}
+
 // runnerScalerKey returns a stable key for the scalers map. The runner ID
 // survives token rotation, so keying by URL+ID keeps a rotated token pointing at
 // the existing scaler instead of spinning up a second one that prunes the
@@ -368,6 +437,10 @@ func (p *provider) Describe(ch chan<- *prometheus.Desc) {
 		if ok {
 			c.Describe(ch)
 		}
+
+		if scaler.predictor != nil {
+			scaler.predictor.Describe(ch)
+		}
 	}
 }
 
@@ -382,6 +455,10 @@ func (p *provider) Collect(ch chan<- prometheus.Metric) {
 		if ok {
 			c.Collect(ch)
 		}
+
+		if scaler.predictor != nil {
+			scaler.predictor.Collect(ch)
+		}
 	}
 }
 
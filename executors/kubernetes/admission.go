@@ -0,0 +1,146 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// kueueQueueNameLabel submits the pod to a Kueue LocalQueue. Kueue gates
+	// the pod, and removes the gate when its workload is admitted.
+	kueueQueueNameLabel = "kueue.x-k8s.io/queue-name"
+	// kueueJobUIDLabel is the label of a Kueue workload with the UID of the
+	// pod the workload was created for.
+	kueueJobUIDLabel = "kueue.x-k8s.io/job-uid"
+)
+
+var kueueWorkloadsResource = schema.GroupVersionResource{Group: "kueue.x-k8s.io", Version: "v1beta1", Resource: "workloads"}
+
+var errQueuedTimeout = errors.New("timed out waiting for pod to be admitted")
+
+func (s *executor) usesAdmission() bool {
+	return s.configurationOverwrites.queueName != "" || len(s.Config.Kubernetes.GetAdmissionSchedulingGates()) > 0
+}
+
+// setPodAdmission submits the pod to the queue of the job, and adds the
+// scheduling gates keeping the pod from being scheduled until it's admitted.
+func (s *executor) setPodAdmission(pod *api.Pod) {
+	if queueName := s.configurationOverwrites.queueName; queueName != "" {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[kueueQueueNameLabel] = queueName
+	}
+
+	for _, gate := range s.Config.Kubernetes.GetAdmissionSchedulingGates() {
+		pod.Spec.SchedulingGates = append(pod.Spec.SchedulingGates, api.PodSchedulingGate{Name: gate})
+	}
+}
+
+// waitForPodAdmission waits for the scheduling gates of the pod to be removed,
+// which happens when the pod is admitted, and writes why the pod is still
+// queued to the job log. It doesn't count towards the poll timeout, which
+// starts once the pod is admitted.
+func (s *executor) waitForPodAdmission(ctx context.Context, out io.Writer) error {
+	if !s.usesAdmission() {
+		return nil
+	}
+
+	timeout := s.Config.Kubernetes.GetAdmissionQueuedTimeout()
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errQueuedTimeout)
+	defer cancel()
+
+	interval := time.Duration(s.Config.Kubernetes.GetPollInterval()) * time.Second
+	started := time.Now()
+
+	var lastReason string
+	queuedErr := func(err error) error {
+		if errors.Is(context.Cause(ctx), errQueuedTimeout) {
+			return fmt.Errorf("%w after %s (%s)", errQueuedTimeout, timeout, lastReason)
+		}
+		return err
+	}
+
+	for {
+		// kubeAPI: pods, get
+		pod, err := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return queuedErr(fmt.Errorf("waiting for pod to be admitted: %w", err))
+		}
+
+		if len(pod.Spec.SchedulingGates) == 0 {
+			_, _ = fmt.Fprintf(out, "Pod %s/%s was admitted after %s\n", pod.Namespace, pod.Name, time.Since(started).Round(time.Second))
+			return nil
+		}
+
+		if reason := s.pendingAdmissionReason(ctx, pod); reason != lastReason {
+			lastReason = reason
+			_, _ = fmt.Fprintf(out, "Waiting for pod %s/%s to be admitted: %s\n", pod.Namespace, pod.Name, reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return queuedErr(ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pendingAdmissionReason returns why the pod isn't admitted yet: the message
+// of its Kueue workload, or the scheduling gates left when there's none.
+func (s *executor) pendingAdmissionReason(ctx context.Context, pod *api.Pod) string {
+	if s.dynamicClient != nil && pod.Labels[kueueQueueNameLabel] != "" {
+		// kubeAPI: kueue.x-k8s.io/workloads, list, kubernetes.admission.queue_name
+		workloads, err := s.dynamicClient.Resource(kueueWorkloadsResource).Namespace(pod.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: kueueJobUIDLabel + "=" + string(pod.UID),
+		})
+		if err != nil {
+			s.BuildLogger.Debugln("Listing Kueue workloads of the pod:", err)
+		}
+
+		if workloads != nil {
+			for _, workload := range workloads.Items {
+				if message := workloadPendingMessage(&workload); message != "" {
+					return fmt.Sprintf("queue %s: %s", pod.Labels[kueueQueueNameLabel], message)
+				}
+			}
+		}
+	}
+
+	gates := make([]string, 0, len(pod.Spec.SchedulingGates))
+	for _, gate := range pod.Spec.SchedulingGates {
+		gates = append(gates, gate.Name)
+	}
+
+	return "scheduling gates " + strings.Join(gates, ", ")
+}
+
+// workloadPendingMessage returns the message of the condition of the Kueue
+// workload explaining why it's not admitted, like the quota it's missing.
+func workloadPendingMessage(workload *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(workload.Object, "status", "conditions")
+
+	for _, conditionType := range []string{"QuotaReserved", "Admitted"} {
+		for _, c := range conditions {
+			condition, ok := c.(map[string]any)
+			if !ok || condition["type"] != conditionType || condition["status"] != string(metav1.ConditionFalse) {
+				continue
+			}
+
+			if message, _ := condition["message"].(string); message != "" {
+				return message
+			}
+		}
+	}
+
+	return ""
+}
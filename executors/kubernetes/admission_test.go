@@ -0,0 +1,166 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestSetPodAdmission(t *testing.T) {
+	e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{
+		Admission: &common.KubernetesAdmissionConfig{
+			SchedulingGates: []string{"example.com/quota"},
+		},
+	})
+	e.configurationOverwrites.queueName = "team-a"
+
+	pod := api.Pod{}
+	e.setPodAdmission(&pod)
+
+	assert.Equal(t, map[string]string{kueueQueueNameLabel: "team-a"}, pod.Labels)
+	assert.Equal(t, []api.PodSchedulingGate{{Name: "example.com/quota"}}, pod.Spec.SchedulingGates)
+}
+
+func newKueueTestWorkload(podUID string, conditions ...any) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "kueue.x-k8s.io/v1beta1",
+		"kind":       "Workload",
+		"metadata": map[string]any{
+			"name":      "pod-job-pod-1a2b3",
+			"namespace": "test-ns",
+			"labels":    map[string]any{kueueJobUIDLabel: podUID},
+		},
+		"status": map[string]any{"conditions": conditions},
+	}}
+}
+
+func TestWaitForPodAdmission(t *testing.T) {
+	gatedPod := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "job-pod",
+			Namespace: "test-ns",
+			UID:       "pod-uid",
+			Labels:    map[string]string{kueueQueueNameLabel: "team-a"},
+		},
+		Spec: api.PodSpec{
+			SchedulingGates: []api.PodSchedulingGate{{Name: "kueue.x-k8s.io/admission"}},
+		},
+	}
+
+	workload := newKueueTestWorkload("pod-uid", map[string]any{
+		"type":    "QuotaReserved",
+		"status":  "False",
+		"reason":  "Pending",
+		"message": "couldn't assign flavors to pod set main: insufficient unused quota for cpu in flavor default, 2 more needed",
+	})
+
+	newExecutor := func(t *testing.T, admittedAfter int) (*executor, *bytes.Buffer) {
+		e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{
+			PollInterval: 1,
+			Admission:    &common.KubernetesAdmissionConfig{QueuedTimeout: 2},
+		})
+		e.configurationOverwrites.queueName = "team-a"
+		e.pod = gatedPod
+
+		kubeClient := fake.NewClientset(gatedPod)
+		var gets int
+		kubeClient.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+			gets++
+			if admittedAfter == 0 || gets <= admittedAfter {
+				return true, gatedPod, nil
+			}
+
+			pod := gatedPod.DeepCopy()
+			pod.Spec.SchedulingGates = nil
+			return true, pod, nil
+		})
+		e.kubeClient = kubeClient
+
+		e.dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+			runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{kueueWorkloadsResource: "WorkloadList"},
+			workload,
+		)
+
+		return e, new(bytes.Buffer)
+	}
+
+	t.Run("admitted", func(t *testing.T) {
+		e, out := newExecutor(t, 1)
+
+		require.NoError(t, e.waitForPodAdmission(t.Context(), out))
+		assert.Contains(t, out.String(), "Waiting for pod test-ns/job-pod to be admitted: queue team-a: couldn't assign flavors")
+		assert.Contains(t, out.String(), "Pod test-ns/job-pod was admitted after")
+	})
+
+	t.Run("queued timeout", func(t *testing.T) {
+		e, out := newExecutor(t, 0)
+
+		err := e.waitForPodAdmission(t.Context(), out)
+		assert.ErrorIs(t, err, errQueuedTimeout)
+		assert.ErrorContains(t, err, "insufficient unused quota")
+		// the reason is only written when it changes
+		assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("Waiting for pod")))
+	})
+
+	t.Run("no admission", func(t *testing.T) {
+		e := newTestExecutorWithKubeClient(t, nil)
+		e.pod = gatedPod
+
+		require.NoError(t, e.waitForPodAdmission(t.Context(), new(bytes.Buffer)))
+	})
+}
+
+func TestPendingAdmissionReason(t *testing.T) {
+	pod := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "test-ns", UID: "pod-uid"},
+		Spec: api.PodSpec{
+			SchedulingGates: []api.PodSchedulingGate{{Name: "example.com/quota"}, {Name: "example.com/approval"}},
+		},
+	}
+
+	e := newTestExecutorWithKubeClient(t, nil)
+	assert.Equal(t, "scheduling gates example.com/quota, example.com/approval", e.pendingAdmissionReason(t.Context(), pod))
+}
+
+func TestWorkloadPendingMessage(t *testing.T) {
+	tests := map[string]struct {
+		conditions []any
+		want       string
+	}{
+		"no conditions": {},
+		"quota not reserved": {
+			conditions: []any{
+				map[string]any{"type": "QuotaReserved", "status": "False", "message": "insufficient quota"},
+			},
+			want: "insufficient quota",
+		},
+		"not admitted": {
+			conditions: []any{
+				map[string]any{"type": "QuotaReserved", "status": "True", "message": "Quota reserved in ClusterQueue team-a"},
+				map[string]any{"type": "Admitted", "status": "False", "message": "The workload has not all checks ready"},
+			},
+			want: "The workload has not all checks ready",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, workloadPendingMessage(newKueueTestWorkload("pod-uid", tt.conditions...)))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker/auth"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
+)
+
+type imageVerifier interface {
+	Matches(image string) bool
+	Resolve(ctx context.Context, image string, auth authn.Authenticator) (string, error)
+	Verify(ctx context.Context, logger image_verification.Logger, image, digest string, auth authn.Authenticator) error
+}
+
+func (s *executor) prepareImageVerifier() error {
+	policy := s.Config.Kubernetes.ImageVerification
+	if policy == nil {
+		return nil
+	}
+
+	verifier, err := image_verification.NewVerifier(policy)
+	if err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+	s.imageVerifier = verifier
+
+	return nil
+}
+
+// verifyImage verifies the image against the image verification policy, and
+// returns the image pinned to the verified digest, so that the kubelet pulls
+// what was verified even if the tag moves in the meantime. Images no rule
+// applies to are returned as is.
+func (s *executor) verifyImage(image string) (string, error) {
+	if s.imageVerifier == nil || !s.imageVerifier.Matches(image) {
+		return image, nil
+	}
+
+	registryInfo, err := auth.Resolver{}.ConfigForImage(
+		image,
+		s.Build.GetDockerAuthConfig(),
+		s.Shell().User,
+		s.Build.Credentials,
+		&s.BuildLogger,
+	)
+	if err != nil {
+		return "", &common.BuildError{Inner: err, FailureReason: common.ImagePullFailure}
+	}
+	registryAuth := image_verification.Authenticator(registryInfo)
+
+	digest, err := s.imageVerifier.Resolve(s.Context, image, registryAuth)
+	if err != nil {
+		return "", &common.BuildError{Inner: err, FailureReason: common.ImagePullFailure}
+	}
+
+	err = s.imageVerifier.Verify(s.Context, &s.BuildLogger, image, digest, registryAuth)
+	var verificationErr *image_verification.VerificationError
+	switch {
+	case errors.As(err, &verificationErr):
+		return "", &common.BuildError{Inner: err, FailureReason: common.ImageVerificationFailure}
+	case err != nil:
+		return "", &common.BuildError{Inner: err, FailureReason: common.ImagePullFailure}
+	}
+
+	return pinImage(image, digest), nil
+}
+
+// pinImage returns the image reference with the digest, unless the reference
+// already has one.
+func pinImage(image, digest string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+
+	if _, ok := named.(reference.Canonical); ok {
+		return image
+	}
+
+	return image + "@" + digest
+}
@@ -0,0 +1,125 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
+)
+
+func TestVerifyImage(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := map[string]struct {
+		image                 string
+		setup                 func(v *mockImageVerifier)
+		expectedImage         string
+		expectedFailureReason spec.JobFailureReason
+	}{
+		"no rule for the image": {
+			image: "alpine:3",
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine:3").Return(false)
+			},
+			expectedImage: "alpine:3",
+		},
+		"verified image is pinned to its digest": {
+			image: "alpine:3",
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine:3").Return(true)
+				v.EXPECT().Resolve(mock.Anything, "alpine:3", mock.Anything).Return(digest, nil)
+				v.EXPECT().Verify(mock.Anything, mock.Anything, "alpine:3", digest, mock.Anything).Return(nil)
+			},
+			expectedImage: "alpine:3@" + digest,
+		},
+		"image with digest is kept as is": {
+			image: "alpine@" + digest,
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine@" + digest).Return(true)
+				v.EXPECT().Resolve(mock.Anything, "alpine@"+digest, mock.Anything).Return(digest, nil)
+				v.EXPECT().Verify(mock.Anything, mock.Anything, "alpine@"+digest, digest, mock.Anything).Return(nil)
+			},
+			expectedImage: "alpine@" + digest,
+		},
+		"digest resolution failure": {
+			image: "alpine:3",
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine:3").Return(true)
+				v.EXPECT().Resolve(mock.Anything, "alpine:3", mock.Anything).Return("", errors.New("unauthorized"))
+			},
+			expectedFailureReason: common.ImagePullFailure,
+		},
+		"verification failure": {
+			image: "alpine:3",
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine:3").Return(true)
+				v.EXPECT().Resolve(mock.Anything, "alpine:3", mock.Anything).Return(digest, nil)
+				v.EXPECT().Verify(mock.Anything, mock.Anything, "alpine:3", digest, mock.Anything).
+					Return(&image_verification.VerificationError{Image: "alpine:3", Inner: errors.New("no signature found")})
+			},
+			expectedFailureReason: common.ImageVerificationFailure,
+		},
+		"registry failure while verifying": {
+			image: "alpine:3",
+			setup: func(v *mockImageVerifier) {
+				v.EXPECT().Matches("alpine:3").Return(true)
+				v.EXPECT().Resolve(mock.Anything, "alpine:3", mock.Anything).Return(digest, nil)
+				v.EXPECT().Verify(mock.Anything, mock.Anything, "alpine:3", digest, mock.Anything).
+					Return(errors.New("connection refused"))
+			},
+			expectedFailureReason: common.ImagePullFailure,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			verifier := newMockImageVerifier(t)
+			tt.setup(verifier)
+
+			e := newTestExecutorWithKubeClient(t, nil)
+			e.imageVerifier = verifier
+
+			image, err := e.verifyImage(tt.image)
+			if tt.expectedFailureReason != "" {
+				var buildErr *common.BuildError
+				require.ErrorAs(t, err, &buildErr)
+				assert.Equal(t, tt.expectedFailureReason, buildErr.FailureReason)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedImage, image)
+		})
+	}
+}
+
+func TestVerifyImageWithoutPolicy(t *testing.T) {
+	e := newTestExecutorWithKubeClient(t, nil)
+
+	require.NoError(t, e.prepareImageVerifier())
+	assert.Nil(t, e.imageVerifier)
+
+	image, err := e.verifyImage("alpine:3")
+	require.NoError(t, err)
+	assert.Equal(t, "alpine:3", image)
+}
+
+func TestPrepareImageVerifierInvalidPolicy(t *testing.T) {
+	e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{
+		ImageVerification: &common.ImageVerificationConfig{
+			Rules: []common.ImageVerificationRule{{Images: []string{"*"}}},
+		},
+	})
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, e.prepareImageVerifier(), &buildErr)
+	assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+}
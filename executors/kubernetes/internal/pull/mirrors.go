@@ -0,0 +1,143 @@
+package pull
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
+)
+
+// Mirrors keeps track of the registry mirror each container pulls its image
+// from, and switches the container to the next mirror when the pull fails.
+// The kubelet authenticates with the mirrors with the credentials of the
+// image pull secrets, which include those of all the configured registries.
+// A nil Mirrors uses the images as they are.
+type Mirrors struct {
+	registryMirrors map[string][]string
+	logger          pullLogger
+
+	mu         sync.Mutex
+	containers map[string]*containerMirrors
+}
+
+type containerMirrors struct {
+	image      string
+	candidates []registry_mirrors.Candidate
+	current    int
+	errs       []error
+	recorded   bool
+}
+
+func NewMirrors(registryMirrors map[string][]string, logger pullLogger) *Mirrors {
+	return &Mirrors{
+		registryMirrors: registryMirrors,
+		logger:          logger,
+		containers:      map[string]*containerMirrors{},
+	}
+}
+
+// ImageFor returns the reference of the image on the mirror the container
+// currently pulls it from.
+func (m *Mirrors) ImageFor(container, image string) string {
+	if m == nil || len(m.registryMirrors) == 0 {
+		return image
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[container]
+	if !ok || c.image != image {
+		c = &containerMirrors{image: image, candidates: registry_mirrors.Candidates(image, m.registryMirrors)}
+		m.containers[container] = c
+	}
+
+	return c.candidates[c.current].Image
+}
+
+// Next switches the container of the failed pull to the next mirror of its
+// image, and returns whether it has one. Once all the mirrors failed, the
+// container starts over from the first one, for the next pull policy, and the
+// error of the pulls is returned, unless all the mirrors agree the image is
+// missing or denied, which is reported as is.
+func (m *Mirrors) Next(attempt int, imagePullErr *ImagePullError) (bool, error) {
+	if m == nil {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[imagePullErr.Container]
+	if !ok || len(c.candidates) < 2 {
+		return false, nil
+	}
+
+	failed := c.candidates[c.current]
+	registry_mirrors.RecordPull(failed, imagePullErr)
+	m.logger.Warningln(fmt.Sprintf(
+		"Failed to pull image %q for container %q from %s: %v",
+		failed.Image,
+		imagePullErr.Container,
+		failed.Mirror,
+		imagePullErr.Message,
+	))
+
+	c.errs = append(c.errs, imagePullErr)
+	c.current++
+
+	if c.current < len(c.candidates) {
+		m.logger.Infoln(fmt.Sprintf(
+			"Attempt #%d: Trying %s for image %q for container %q",
+			attempt+1,
+			c.candidates[c.current].Mirror,
+			c.image,
+			imagePullErr.Container,
+		))
+		return true, nil
+	}
+
+	errs := c.errs
+	c.current, c.errs = 0, nil
+
+	for _, err := range errs {
+		var pullErr *ImagePullError
+		if !errors.As(err, &pullErr) || common.ClassifyImagePullFailure(pullErr.Message) != common.ConfigurationError {
+			return false, &registry_mirrors.AllFailedError{Image: c.image, Errors: errs}
+		}
+	}
+
+	return false, nil
+}
+
+// RecordPulls records the pulls of the images of the containers from their
+// current mirrors, once the pod is running.
+func (m *Mirrors) RecordPulls() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for container, c := range m.containers {
+		candidate := c.candidates[c.current]
+		if c.recorded || candidate.Registry == "" {
+			continue
+		}
+
+		c.recorded = true
+		registry_mirrors.RecordPull(candidate, nil)
+
+		if candidate.IsMirror() {
+			m.logger.Infoln(fmt.Sprintf(
+				"Pulled image %q for container %q from registry mirror %s",
+				c.image,
+				container,
+				candidate.Mirror,
+			))
+		}
+	}
+}
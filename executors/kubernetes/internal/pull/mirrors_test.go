@@ -0,0 +1,92 @@
+//go:build !integration
+
+package pull
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
+)
+
+func TestMirrors(t *testing.T) {
+	const (
+		image       = "alpine:3.20"
+		mirrorImage = "mirror.example.com/library/alpine:3.20"
+	)
+
+	registryMirrors := map[string][]string{"docker.io": {"mirror.example.com"}}
+
+	newMirrors := func(t *testing.T) *Mirrors {
+		l := newMockPullLogger(t)
+		l.On("Warningln", mock.Anything).Maybe()
+		l.On("Infoln", mock.Anything).Maybe()
+
+		return NewMirrors(registryMirrors, l)
+	}
+
+	t.Run("fails over to the registry", func(t *testing.T) {
+		m := newMirrors(t)
+
+		assert.Equal(t, mirrorImage, m.ImageFor(buildContainer, image))
+
+		next, err := m.Next(1, &ImagePullError{Container: buildContainer, Image: mirrorImage, Message: "503 Service Unavailable"})
+		require.NoError(t, err)
+		assert.True(t, next)
+		assert.Equal(t, image, m.ImageFor(buildContainer, image))
+
+		m.RecordPulls()
+	})
+
+	t.Run("all mirrors failed", func(t *testing.T) {
+		m := newMirrors(t)
+		m.ImageFor(buildContainer, image)
+
+		next, err := m.Next(1, &ImagePullError{Container: buildContainer, Image: mirrorImage, Message: "manifest unknown"})
+		require.NoError(t, err)
+		require.True(t, next)
+
+		next, err = m.Next(2, &ImagePullError{Container: buildContainer, Image: image, Message: "503 Service Unavailable"})
+		assert.False(t, next)
+		var allFailedErr *registry_mirrors.AllFailedError
+		require.ErrorAs(t, err, &allFailedErr)
+		assert.Len(t, allFailedErr.Errors, 2)
+
+		assert.Equal(t, mirrorImage, m.ImageFor(buildContainer, image), "the next pull policy starts over")
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		m := newMirrors(t)
+		m.ImageFor(buildContainer, image)
+
+		_, err := m.Next(1, &ImagePullError{Container: buildContainer, Image: mirrorImage, Message: "manifest unknown"})
+		require.NoError(t, err)
+
+		next, err := m.Next(2, &ImagePullError{Container: buildContainer, Image: image, Message: "not found"})
+		assert.False(t, next)
+		assert.NoError(t, err)
+	})
+
+	t.Run("image without mirrors", func(t *testing.T) {
+		m := newMirrors(t)
+		assert.Equal(t, "quay.io/prometheus/prometheus", m.ImageFor(buildContainer, "quay.io/prometheus/prometheus"))
+
+		next, err := m.Next(1, &ImagePullError{Container: buildContainer, Message: "503 Service Unavailable"})
+		assert.False(t, next)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var m *Mirrors
+		assert.Equal(t, image, m.ImageFor(buildContainer, image))
+
+		next, err := m.Next(1, &ImagePullError{Container: buildContainer})
+		assert.False(t, next)
+		assert.NoError(t, err)
+
+		m.RecordPulls()
+	})
+}
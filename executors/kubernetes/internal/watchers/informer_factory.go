@@ -61,6 +61,7 @@ func newScopedInformerFactory(ctx context.Context, kubeClient kubernetes.Interfa
 }
 
 func (f *selfManagedInformerFactory) Start() {
+	// kubeAPI: ignore
 	f.SharedInformerFactory.Start(f.ctx.Done())
 }
 
@@ -75,10 +76,12 @@ func (f *selfManagedInformerFactory) WaitForCacheSync() map[reflect.Type]bool {
 		defer cancel()
 	}
 
+	// kubeAPI: ignore
 	return f.SharedInformerFactory.WaitForCacheSync(ctx.Done())
 }
 
 func (f *selfManagedInformerFactory) Shutdown() {
 	f.cancel()
+	// kubeAPI: ignore
 	f.SharedInformerFactory.Shutdown()
 }
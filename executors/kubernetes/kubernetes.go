@@ -35,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Register all available authentication methods
 	restclient "k8s.io/client-go/rest"
@@ -47,6 +48,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/autoscaler"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/pull"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/watchers"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/runnerjob"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/helperimage"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/dns"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker/auth"
@@ -303,6 +305,14 @@ type executor struct {
 	getKubeConfig func(conf *common.KubernetesConfig, overwrites *overwrites) (*restclient.Config, error)
 	kubeConfig    *restclient.Config
 
+	newRunnerJobClient func(config *restclient.Config) (*runnerjob.Client, error)
+	runnerJobClient    *runnerjob.Client
+	runnerJob          *runnerjob.RunnerJob
+
+	newDynamicClient func(config *restclient.Config) (dynamic.Interface, error)
+	// dynamicClient reads the Kueue workloads of the job pod
+	dynamicClient dynamic.Interface
+
 	windowsKernelVersion func() string
 
 	pod                 *api.Pod
@@ -313,6 +323,8 @@ type executor struct {
 
 	configurationOverwrites *overwrites
 	pullManager             pull.Manager
+	registryMirrors         *pull.Mirrors
+	imageVerifier           imageVerifier
 
 	helperImageInfo helperimage.Info
 
@@ -337,6 +349,8 @@ type executor struct {
 
 	podEventState *podEventState
 
+	podMetrics *podMetricsSampler
+
 	// suspendRootfsPVCName is the name of the PVC used for suspending environment
 	// only used when FF_SUSPENDABLE_ENVIRONMENTS is enabled
 	suspendRootfsPVCName string
@@ -427,6 +441,26 @@ func (s *executor) prepareConnections(options common.ExecutorPrepareOptions) err
 		}
 	}
 
+	if s.usesRunnerJob() {
+		s.runnerJobClient, err = s.newRunnerJobClient(s.kubeConfig)
+		if err != nil {
+			return &common.BuildError{
+				Inner:         fmt.Errorf("creating RunnerJob client: %w", err),
+				FailureReason: common.ConfigurationError,
+			}
+		}
+	}
+
+	if s.configurationOverwrites.queueName != "" {
+		s.dynamicClient, err = s.newDynamicClient(s.kubeConfig)
+		if err != nil {
+			return &common.BuildError{
+				Inner:         fmt.Errorf("creating dynamic client: %w", err),
+				FailureReason: common.ConfigurationError,
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -435,6 +469,10 @@ func (s *executor) prepareInfrastructure(ctx context.Context) error {
 		return err
 	}
 
+	if err := s.guardRunnerJobCompatibility(); err != nil {
+		return err
+	}
+
 	var err error
 	s.helperImageInfo, err = s.prepareHelperImage()
 	if err != nil {
@@ -461,6 +499,11 @@ func (s *executor) prepareInfrastructure(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	s.registryMirrors = pull.NewMirrors(s.Config.Kubernetes.RegistryMirrors, &s.BuildLogger)
+
+	if err := s.prepareImageVerifier(); err != nil {
+		return err
+	}
 
 	if imageNameFromConfig := s.ExpandValue(s.Config.Kubernetes.HelperImage); imageNameFromConfig != "" {
 		s.BuildLogger.Debugln(fmt.Sprintf("Using helper image: %s (overridden, default would be %s:%s)", imageNameFromConfig, s.helperImageInfo.Name, s.helperImageInfo.Tag))
@@ -660,7 +703,7 @@ func (s *executor) retrieveHelperImageConfig() helperimage.Config {
 }
 
 func (s *executor) Run(cmd common.ExecutorCommand) error {
-	return s.withPullRetry(cmd.Context, func() error {
+	err := s.withPullRetry(cmd.Context, func() error {
 		if s.Build.IsFeatureFlagOn(featureflags.UseLegacyKubernetesExecutionStrategy) {
 			s.BuildLogger.Debugln("Starting Kubernetes command...")
 			return s.runWithExecLegacy(cmd)
@@ -668,6 +711,13 @@ func (s *executor) Run(cmd common.ExecutorCommand) error {
 		s.BuildLogger.Debugln("Starting Kubernetes command with attach...")
 		return s.runWithAttach(cmd)
 	})
+
+	containerName := buildContainerName
+	if cmd.Predefined {
+		containerName = helperContainerName
+	}
+
+	return s.diagnoseTermination(cmd.Context, containerName, err)
 }
 
 // withPullRetry runs dispatch and retries on *pull.ImagePullError,
@@ -684,7 +734,10 @@ func (s *executor) withPullRetry(ctx context.Context, dispatch func() error) err
 
 		var imagePullErr *pull.ImagePullError
 		if errors.As(err, &imagePullErr) {
-			if s.pullManager.UpdatePolicyForContainer(attempt, imagePullErr) {
+			// the mirrors of the image are tried in turn before the next
+			// pull policy
+			nextMirror, mirrorsErr := s.registryMirrors.Next(attempt, imagePullErr)
+			if nextMirror || s.pullManager.UpdatePolicyForContainer(attempt, imagePullErr) {
 				cleanupCtx, cancel := context.WithTimeout(
 					context.Background(), s.Config.Kubernetes.GetCleanupResourcesTimeout())
 				s.cleanupResources(cleanupCtx)
@@ -692,6 +745,13 @@ func (s *executor) withPullRetry(ctx context.Context, dispatch func() error) err
 				s.pod = nil
 				continue
 			}
+
+			if mirrorsErr != nil {
+				return &common.BuildError{
+					Inner:         mirrorsErr,
+					FailureReason: common.ClassifyImagePullFailure(mirrorsErr.Error()),
+				}
+			}
 		}
 		return err
 	}
@@ -888,6 +948,8 @@ func (s *executor) runWithExecLegacy(cmd common.ExecutorCommand) error {
 		return err
 	}
 
+	s.startPodMetricsSampler()
+
 	containerName := buildContainerName
 	containerCommand := s.BuildShell.DockerCommand
 	if cmd.Predefined {
@@ -989,6 +1051,8 @@ func (s *executor) runWithAttach(cmd common.ExecutorCommand) error {
 		return err
 	}
 
+	s.startPodMetricsSampler()
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -1143,7 +1207,7 @@ func (s *executor) ensurePodsConfigured(ctx context.Context) error {
 	go s.processLogs(ctx)
 
 	// This pulls the services containers logs directly from the kubeapi and pushes them into the buildlogger.
-	s.captureServiceContainersLogs(ctx, s.pod.Spec.Containers)
+	s.captureServiceContainersLogs(ctx, slices.Concat(s.pod.Spec.InitContainers, s.pod.Spec.Containers))
 
 	return nil
 }
@@ -1175,7 +1239,15 @@ func (s *executor) buildInitContainers() ([]api.Container, error) {
 }
 
 func (s *executor) waitForPod(ctx context.Context, writer io.WriteCloser) error {
+	if err := s.waitForPodAdmission(ctx, writer); err != nil {
+		return err
+	}
+
+	// The kubelet pulls the images of the pod while starting it, so the time
+	// spent pulling them is recorded as the time the pod takes to run.
+	endPull := s.Build.Timeline().StartEvent(common.TimelineEventImagePull, map[string]string{"pod": s.pod.Name})
 	status, err := waitForPodRunning(ctx, s.kubeClient, s.pod, writer, s.Config.Kubernetes, buildContainerName)
+	endPull()
 	if err != nil {
 		return fmt.Errorf("waiting for pod running: %w", err)
 	}
@@ -1184,6 +1256,8 @@ func (s *executor) waitForPod(ctx context.Context, writer io.WriteCloser) error
 		return fmt.Errorf("pod failed to enter running state: %s", status)
 	}
 
+	s.registryMirrors.RecordPulls()
+
 	if !s.Build.IsFeatureFlagOn(featureflags.WaitForPodReachable) {
 		return nil
 	}
@@ -1307,7 +1381,7 @@ func (s *executor) buildPermissionsInitContainer(os string) (api.Container, erro
 
 	container := api.Container{
 		Name:            initPermissionContainerName,
-		Image:           s.getHelperImage(),
+		Image:           s.registryMirrors.ImageFor(initPermissionContainerName, s.getHelperImage()),
 		VolumeMounts:    volumeMounts,
 		ImagePullPolicy: pullPolicy,
 		// let's use build container resources
@@ -1381,6 +1455,12 @@ func (s *executor) buildUiGidCollector(os string) (api.Container, error) {
 		return api.Container{}, err
 	}
 
+	image, err := s.verifyImage(opts.image)
+	if err != nil {
+		return api.Container{}, err
+	}
+	opts.image = s.registryMirrors.ImageFor(opts.name, image)
+
 	pullPolicy, err := s.pullManager.GetPullPolicyFor(opts.name)
 	if err != nil {
 		return api.Container{}, err
@@ -1563,6 +1643,11 @@ func (s *executor) Cleanup() {
 		s.eventsStream.Stop()
 	}
 
+	s.podMetrics.stop()
+	for _, usage := range s.podMetrics.summary() {
+		s.BuildLogger.Debugln("Peak resource usage of", usage)
+	}
+
 	if s.suspended {
 		s.BuildLogger.Infoln("Job environment suspended; retaining PVC",
 			s.suspendRootfsPVCName)
@@ -1589,7 +1674,9 @@ func (s *executor) Cleanup() {
 // This does not apply for services as they are created with the owner from the start
 // thus deletion of the pod automatically means deletion of the services if any
 func (s *executor) cleanupResources(ctx context.Context) {
-	if s.pod != nil {
+	if s.runnerJob != nil {
+		s.deleteRunnerJob(ctx)
+	} else if s.pod != nil {
 		kubeRequest := retry.WithFn(s, func() error {
 			// kubeAPI: pods, delete
 			return s.kubeClient.CoreV1().
@@ -1641,6 +1728,12 @@ func (s *executor) buildContainer(opts containerBuildOpts) (api.Container, error
 		return api.Container{}, err
 	}
 
+	opts.image, err = s.verifyImage(opts.image)
+	if err != nil {
+		return api.Container{}, err
+	}
+	opts.image = s.registryMirrors.ImageFor(opts.name, opts.image)
+
 	containerPorts := make([]api.ContainerPort, len(opts.imageDefinition.Ports))
 	proxyPorts := make([]proxy.Port, len(opts.imageDefinition.Ports))
 
@@ -2323,6 +2416,8 @@ func (s *executor) setupBuildPod(ctx context.Context, initContainers []api.Conta
 		return err
 	}
 
+	s.setPodAdmission(&podConfig)
+
 	s.BuildLogger.Debugln("Checking for ImagePullSecrets or ServiceAccount existence")
 	err = s.checkDependantResources(ctx)
 	if err != nil {
@@ -2342,6 +2437,10 @@ func (s *executor) setupBuildPod(ctx context.Context, initContainers []api.Conta
 	// if we need to retry on pull issues, we need to set the new pod name, so that we don't track terminating pods.
 	s.podWatcher.UpdatePodName(podConfig.GetName())
 
+	if s.usesRunnerJob() {
+		return s.setupRunnerJob(ctx, &podConfig)
+	}
+
 	s.BuildLogger.Debugln("Creating build pod")
 
 	s.pod, err = retry.WithValueFn(s, func() (*api.Pod, error) {
@@ -2467,6 +2566,11 @@ func (s *executor) createPodConfigPrepareOpts(initContainers []api.Container) (p
 		return podConfigPrepareOpts{}, err
 	}
 
+	podServices, sidecars, err := s.orderPodServices(podServices)
+	if err != nil {
+		return podConfigPrepareOpts{}, err
+	}
+
 	imagePullSecrets := s.prepareImagePullSecrets()
 	hostAliases, err := s.getHostAliases()
 	if err != nil {
@@ -2479,7 +2583,7 @@ func (s *executor) createPodConfigPrepareOpts(initContainers []api.Container) (p
 		services:         podServices,
 		imagePullSecrets: imagePullSecrets,
 		hostAliases:      hostAliases,
-		initContainers:   initContainers,
+		initContainers:   slices.Concat(initContainers, sidecars),
 	}, nil
 }
 
@@ -2577,6 +2681,8 @@ func (s *executor) preparePodServices() ([]api.Container, error) {
 		if s.usesSuspendResume() && hasOverlay {
 			s.overlayServiceContainer(&podServices[i], name, service)
 		}
+
+		podServices[i].ReadinessProbe = s.serviceReadinessProbe(name, service)
 	}
 
 	return podServices, nil
@@ -3215,16 +3321,7 @@ func (s *executor) makePodProxyServices(
 
 	for serviceName, serviceProxy := range s.ProxyPool {
 		serviceName = dns.MakeRFC1123Compatible(serviceName)
-		servicePorts := make([]api.ServicePort, len(serviceProxy.Settings.Ports))
-		for i, port := range serviceProxy.Settings.Ports {
-			// When there is more than one port Kubernetes requires a port name
-			portName := fmt.Sprintf("%s-%d", serviceName, port.Number)
-			servicePorts[i] = api.ServicePort{
-				Port:       int32(port.Number),
-				TargetPort: intstr.FromInt32(int32(port.Number)),
-				Name:       portName,
-			}
-		}
+		servicePorts := proxyServicePorts(serviceName, serviceProxy.Settings.Ports)
 
 		serviceConfig := s.prepareServiceConfig(serviceName, servicePorts, ownerReferences)
 		go s.createKubernetesService(ctx, &serviceConfig, serviceProxy.Settings, ch, &wg)
@@ -3250,6 +3347,21 @@ func (s *executor) makePodProxyServices(
 	return proxyServices, nil
 }
 
+func proxyServicePorts(serviceName string, ports []proxy.Port) []api.ServicePort {
+	servicePorts := make([]api.ServicePort, len(ports))
+	for i, port := range ports {
+		// When there is more than one port Kubernetes requires a port name
+		portName := fmt.Sprintf("%s-%d", serviceName, port.Number)
+		servicePorts[i] = api.ServicePort{
+			Port:       int32(port.Number),
+			TargetPort: intstr.FromInt32(int32(port.Number)),
+			Name:       portName,
+		}
+	}
+
+	return servicePorts
+}
+
 func (s *executor) prepareServiceConfig(
 	name string,
 	ports []api.ServicePort,
@@ -3659,28 +3771,44 @@ func (s *executor) checkDefaults() error {
 
 // captureServiceContainersLogs initiates capturing logs for the services containers to a desired additional sink. The
 // sink can be any io.Writer. Currently the sink is the jobs main trace, which is wrapped in an inlineServiceLogWriter
-// instance to add additional context to logs. In the future this could be separate file.
+// instance to add additional context to logs, and the file of each service the job_diagnostics artifact is made of.
 func (s *executor) captureServiceContainersLogs(ctx context.Context, containers []api.Container) {
-	if !s.Build.IsCIDebugServiceEnabled() {
+	debug := s.Build.IsCIDebugServiceEnabled()
+	serviceLogs := s.Build.ServiceLogs()
+	if !debug && serviceLogs == nil {
 		return
 	}
 
 	for _, name := range s.options.getSortedServiceNames() {
 		service := s.options.Services[name]
 		for _, container := range containers {
-			if service.Name != container.Image || isNotServiceContainerName(container.Name) {
+			// the image of the container may be pinned to a digest, or
+			// rewritten to a registry mirror
+			if (container.Name != name && service.Name != container.Image) || isNotServiceContainerName(container.Name) {
 				continue
 			}
 
-			logger := s.BuildLogger.Stream(buildlogger.StreamStartingServiceLevel, buildlogger.Stdout)
-			defer logger.Close()
+			aliases := append([]string{strings.Split(service.Name, ":")[0]}, service.Aliases()...)
+			serviceName := strings.Join(aliases, "-")
+
+			var logger, sink io.WriteCloser
+			if debug {
+				logger = s.BuildLogger.Stream(buildlogger.StreamStartingServiceLevel, buildlogger.Stdout)
+				defer logger.Close()
+
+				sink = service_helpers.NewInlineServiceLogWriter(serviceName, logger)
+			}
 
-			aliases := append([]string{strings.Split(container.Image, ":")[0]}, service.Aliases()...)
-			sink := service_helpers.NewInlineServiceLogWriter(strings.Join(aliases, "-"), logger)
+			// the logs are also captured to a file, for the job_diagnostics artifact
+			sink = serviceLogs.Writer(serviceName, sink)
 			if err := s.captureContainerLogs(ctx, container.Name, sink); err != nil {
+				_ = sink.Close()
 				s.BuildLogger.Warningln(err.Error())
 			}
-			logger.Close()
+
+			if logger != nil {
+				logger.Close()
+			}
 		}
 	}
 }
@@ -3774,6 +3902,14 @@ func IsKubernetesPodContainerError(err error) bool {
 	return errors.As(err, &podServiceError)
 }
 
+// startServiceWaitEvent records the wait for the services in the job timeline.
+// They're waited for together, so the event names all of them.
+func (s *executor) startServiceWaitEvent() func() {
+	return s.Build.Timeline().StartEvent(common.TimelineEventServiceWait, map[string]string{
+		"service": strings.Join(s.options.getSortedServiceNames(), ","),
+	})
+}
+
 // Use 'gitlab-runner check-health' to wait until any/all configured services are healthy.
 func (s *executor) waitForServices(ctx context.Context) error {
 	var portArgs strings.Builder
@@ -3785,7 +3921,7 @@ func (s *executor) waitForServices(ctx context.Context) error {
 		}
 		fmt.Fprintf(&portArgs, "--port '%s' ", port)
 	}
-	if portArgs.Len() == 0 {
+	if portArgs.Len() == 0 && !s.hasServiceReadinessProbes() {
 		return nil
 	}
 
@@ -3802,6 +3938,14 @@ func (s *executor) waitForServices(ctx context.Context) error {
 		return err
 	}
 
+	defer s.startServiceWaitEvent()()
+
+	// services with a readiness probe are only ready once it succeeds
+	if portArgs.Len() == 0 {
+		s.waitForServicesReady(ctx)
+		return nil
+	}
+
 	podStatusCh := s.watchPodStatus(ctx, &podContainerStatusChecker{})
 
 	stdout, stderr := s.getExecutorIoWriters()
@@ -3823,6 +3967,8 @@ func (s *executor) waitForServices(ctx context.Context) error {
 		return fmt.Errorf("health check aborted")
 	}
 
+	s.waitForServicesReady(ctx)
+
 	return nil
 }
 
@@ -3845,7 +3991,11 @@ func newExecutor() *executor {
 		newKubeClient: func(config *restclient.Config) (kubernetes.Interface, error) {
 			return kubernetes.NewForConfig(config)
 		},
+		newDynamicClient: func(config *restclient.Config) (dynamic.Interface, error) {
+			return dynamic.NewForConfig(config)
+		},
 		getKubeConfig:        getKubeClientConfig,
+		newRunnerJobClient:   runnerjob.NewForConfig,
 		windowsKernelVersion: os_helpers.LocalKernelVersion,
 	}
 
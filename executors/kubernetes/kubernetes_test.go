@@ -3886,12 +3886,15 @@ func TestPrepare(t *testing.T) {
 			e.kubeConfig = nil
 			e.featureChecker = nil
 			e.pullManager = nil
+			e.registryMirrors = nil
 			e.requireDefaultBuildsDirVolume = nil
 			e.requireSharedBuildsDir = nil
 			e.newLogProcessor = nil
 			e.remoteProcessTerminated = nil
 			e.getKubeConfig = nil
 			e.newKubeClient = nil
+			e.newRunnerJobClient = nil
+			e.newDynamicClient = nil
 			e.windowsKernelVersion = nil
 			e.options.Image.PullPolicies = nil
 			e.newPodWatcher = nil
@@ -8719,21 +8722,29 @@ func Test_Executor_captureServiceContainersLogs(t *testing.T) {
 
 	ctx := t.Context()
 
+	assertLogStreamsFailed := func(t *testing.T) {
+		for _, c := range containers {
+			if !strings.HasPrefix(c.Name, serviceContainerPrefix) {
+				continue
+			}
+			assert.Contains(t, logs.String(), "WARNING: failed to open log stream for container "+c.Name)
+			assert.Contains(t, logs.String(), stop.Error())
+		}
+	}
+
 	tests := map[string]struct {
-		debugServicePolicy string
-		assert             func(t *testing.T)
+		debugServicePolicy  string
+		serviceLogsArtifact string
+		assert              func(t *testing.T)
 	}{
 		"enabled": {
 			debugServicePolicy: "true",
-			assert: func(t *testing.T) {
-				for _, c := range containers {
-					if !strings.HasPrefix(c.Name, serviceContainerPrefix) {
-						continue
-					}
-					assert.Contains(t, logs.String(), "WARNING: failed to open log stream for container "+c.Name)
-					assert.Contains(t, logs.String(), stop.Error())
-				}
-			},
+			assert:             assertLogStreamsFailed,
+		},
+		"service logs artifact": {
+			debugServicePolicy:  "false",
+			serviceLogsArtifact: "true",
+			assert:              assertLogStreamsFailed,
 		},
 		"disabled": {
 			debugServicePolicy: "false",
@@ -8755,6 +8766,7 @@ func Test_Executor_captureServiceContainersLogs(t *testing.T) {
 			}
 			e.Build.Variables = spec.Variables{
 				{Key: "CI_DEBUG_SERVICES", Value: tt.debugServicePolicy, Public: true},
+				{Key: "CI_SERVICE_LOGS_ARTIFACT", Value: tt.serviceLogsArtifact, Public: true},
 			}
 
 			e.prepareOptions(e.Build)
@@ -10,7 +10,9 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	mock "github.com/stretchr/testify/mock"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/image_verification"
 	v10 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
@@ -300,6 +302,231 @@ func (_c *mockFeatureChecker_IsResourceVerbAllowed_Call) RunAndReturn(run func(c
 	return _c
 }
 
+// newMockImageVerifier creates a new instance of mockImageVerifier. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func newMockImageVerifier(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *mockImageVerifier {
+	mock := &mockImageVerifier{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// mockImageVerifier is an autogenerated mock type for the imageVerifier type
+type mockImageVerifier struct {
+	mock.Mock
+}
+
+type mockImageVerifier_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *mockImageVerifier) EXPECT() *mockImageVerifier_Expecter {
+	return &mockImageVerifier_Expecter{mock: &_m.Mock}
+}
+
+// Matches provides a mock function for the type mockImageVerifier
+func (_mock *mockImageVerifier) Matches(image string) bool {
+	ret := _mock.Called(image)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Matches")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = returnFunc(image)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// mockImageVerifier_Matches_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Matches'
+type mockImageVerifier_Matches_Call struct {
+	*mock.Call
+}
+
+// Matches is a helper method to define mock.On call
+//   - image string
+func (_e *mockImageVerifier_Expecter) Matches(image interface{}) *mockImageVerifier_Matches_Call {
+	return &mockImageVerifier_Matches_Call{Call: _e.mock.On("Matches", image)}
+}
+
+func (_c *mockImageVerifier_Matches_Call) Run(run func(image string)) *mockImageVerifier_Matches_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *mockImageVerifier_Matches_Call) Return(_a0 bool) *mockImageVerifier_Matches_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockImageVerifier_Matches_Call) RunAndReturn(run func(image string) bool) *mockImageVerifier_Matches_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Resolve provides a mock function for the type mockImageVerifier
+func (_mock *mockImageVerifier) Resolve(ctx context.Context, image string, auth authn.Authenticator) (string, error) {
+	ret := _mock.Called(ctx, image, auth)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Resolve")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, authn.Authenticator) (string, error)); ok {
+		return returnFunc(ctx, image, auth)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, authn.Authenticator) string); ok {
+		r0 = returnFunc(ctx, image, auth)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, authn.Authenticator) error); ok {
+		r1 = returnFunc(ctx, image, auth)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// mockImageVerifier_Resolve_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Resolve'
+type mockImageVerifier_Resolve_Call struct {
+	*mock.Call
+}
+
+// Resolve is a helper method to define mock.On call
+//   - ctx context.Context
+//   - image string
+//   - auth authn.Authenticator
+func (_e *mockImageVerifier_Expecter) Resolve(ctx interface{}, image interface{}, auth interface{}) *mockImageVerifier_Resolve_Call {
+	return &mockImageVerifier_Resolve_Call{Call: _e.mock.On("Resolve", ctx, image, auth)}
+}
+
+func (_c *mockImageVerifier_Resolve_Call) Run(run func(ctx context.Context, image string, auth authn.Authenticator)) *mockImageVerifier_Resolve_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 authn.Authenticator
+		if args[2] != nil {
+			arg2 = args[2].(authn.Authenticator)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *mockImageVerifier_Resolve_Call) Return(_a0 string, _a1 error) *mockImageVerifier_Resolve_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *mockImageVerifier_Resolve_Call) RunAndReturn(run func(ctx context.Context, image string, auth authn.Authenticator) (string, error)) *mockImageVerifier_Resolve_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Verify provides a mock function for the type mockImageVerifier
+func (_mock *mockImageVerifier) Verify(ctx context.Context, logger image_verification.Logger, image string, digest string, auth authn.Authenticator) error {
+	ret := _mock.Called(ctx, logger, image, digest, auth)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Verify")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, image_verification.Logger, string, string, authn.Authenticator) error); ok {
+		r0 = returnFunc(ctx, logger, image, digest, auth)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// mockImageVerifier_Verify_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Verify'
+type mockImageVerifier_Verify_Call struct {
+	*mock.Call
+}
+
+// Verify is a helper method to define mock.On call
+//   - ctx context.Context
+//   - logger image_verification.Logger
+//   - image string
+//   - digest string
+//   - auth authn.Authenticator
+func (_e *mockImageVerifier_Expecter) Verify(ctx interface{}, logger interface{}, image interface{}, digest interface{}, auth interface{}) *mockImageVerifier_Verify_Call {
+	return &mockImageVerifier_Verify_Call{Call: _e.mock.On("Verify", ctx, logger, image, digest, auth)}
+}
+
+func (_c *mockImageVerifier_Verify_Call) Run(run func(ctx context.Context, logger image_verification.Logger, image string, digest string, auth authn.Authenticator)) *mockImageVerifier_Verify_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 image_verification.Logger
+		if args[1] != nil {
+			arg1 = args[1].(image_verification.Logger)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		var arg4 authn.Authenticator
+		if args[4] != nil {
+			arg4 = args[4].(authn.Authenticator)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *mockImageVerifier_Verify_Call) Return(_a0 error) *mockImageVerifier_Verify_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *mockImageVerifier_Verify_Call) RunAndReturn(run func(ctx context.Context, logger image_verification.Logger, image string, digest string, auth authn.Authenticator) error) *mockImageVerifier_Verify_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // newMockPodWatcher creates a new instance of mockPodWatcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockPodWatcher(t interface {
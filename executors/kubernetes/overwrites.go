@@ -19,6 +19,8 @@ const (
 	NamespaceOverwriteVariableName = "KUBERNETES_NAMESPACE_OVERWRITE"
 	// ServiceAccountOverwriteVariableName is the key for the JobVariable containing user overwritten ServiceAccount
 	ServiceAccountOverwriteVariableName = "KUBERNETES_SERVICE_ACCOUNT_OVERWRITE"
+	// QueueNameOverwriteVariableName is the key for the JobVariable containing user overwritten Kueue queue name
+	QueueNameOverwriteVariableName = "KUBERNETES_QUEUE_NAME_OVERWRITE"
 	// BearerTokenOverwriteVariableValue is the key for the JobVariable containing user overwritten BearerToken
 	BearerTokenOverwriteVariableValue = "KUBERNETES_BEARER_TOKEN"
 	// PodLabelsOverwriteVariablePrefix is the prefix for all the JobVariable keys containing
@@ -126,6 +128,7 @@ func (m *malformedOverwriteError) Is(err error) bool {
 type overwrites struct {
 	namespace       string
 	serviceAccount  string
+	queueName       string
 	bearerToken     string
 	podLabels       map[string]string
 	podAnnotations  map[string]string
@@ -179,6 +182,20 @@ func createOverwrites(
 		return nil, err
 	}
 
+	if config.Admission != nil {
+		queueNameOverwrite := variables.Get(QueueNameOverwriteVariableName)
+		o.queueName, err = o.evaluateOverwrite(
+			"QueueName",
+			config.Admission.QueueName,
+			config.Admission.QueueNameOverwriteAllowed,
+			queueNameOverwrite,
+			logger,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	bearerTokenOverwrite := variables.Get(BearerTokenOverwriteVariableValue)
 	o.bearerToken, err = o.evaluateBoolControlledOverwrite(
 		"BearerToken",
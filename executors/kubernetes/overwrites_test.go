@@ -52,6 +52,7 @@ func TestOverwrites(t *testing.T) {
 		Config                               *common.KubernetesConfig
 		NamespaceOverwriteVariableValue      string
 		ServiceAccountOverwriteVariableValue string
+		QueueNameOverwriteVariableValue      string
 		BearerTokenOverwriteVariableValue    string
 		NodeSelectorOverwriteValues          map[string]string
 		NodeTolerationsOverwriteValues       map[string]string
@@ -412,6 +413,38 @@ func TestOverwrites(t *testing.T) {
 				podRequests:     api.ResourceList{},
 			},
 		},
+		{
+			Name: "QueueName overwrite",
+			Config: &common.KubernetesConfig{
+				Admission: &common.KubernetesAdmissionConfig{
+					QueueName:                 "shared",
+					QueueNameOverwriteAllowed: "^team-.*$",
+				},
+			},
+			QueueNameOverwriteVariableValue: "team-a",
+			Expected: &overwrites{
+				queueName:       "team-a",
+				buildLimits:     api.ResourceList{},
+				buildRequests:   api.ResourceList{},
+				serviceLimits:   api.ResourceList{},
+				serviceRequests: api.ResourceList{},
+				helperLimits:    api.ResourceList{},
+				helperRequests:  api.ResourceList{},
+				podLimits:       api.ResourceList{},
+				podRequests:     api.ResourceList{},
+			},
+		},
+		{
+			Name: "QueueName failure",
+			Config: &common.KubernetesConfig{
+				Admission: &common.KubernetesAdmissionConfig{
+					QueueName:                 "shared",
+					QueueNameOverwriteAllowed: "^team-.*$",
+				},
+			},
+			QueueNameOverwriteVariableValue: "other",
+			Error:                           new(malformedOverwriteError),
+		},
 		{
 			Name: "Namespace failure",
 			Config: &common.KubernetesConfig{
@@ -609,6 +642,7 @@ func TestOverwrites(t *testing.T) {
 				variableOverwrites{
 					NamespaceOverwriteVariableName:                       test.NamespaceOverwriteVariableValue,
 					ServiceAccountOverwriteVariableName:                  test.ServiceAccountOverwriteVariableValue,
+					QueueNameOverwriteVariableName:                       test.QueueNameOverwriteVariableValue,
 					BearerTokenOverwriteVariableValue:                    test.BearerTokenOverwriteVariableValue,
 					CPULimitOverwriteVariableValue:                       test.CPULimitOverwriteVariableValue,
 					CPURequestOverwriteVariableValue:                     test.CPURequestOverwriteVariableValue,
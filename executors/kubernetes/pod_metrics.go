@@ -0,0 +1,191 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+)
+
+// podMetricsInterval is the interval the metrics API is sampled at, which
+// matches the default resolution of the metrics server.
+const podMetricsInterval = 15 * time.Second
+
+var podMetricsGroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+
+// podMetrics is the subset of the PodMetrics of the metrics API read to
+// sample the resource usage of the job pod.
+type podMetrics struct {
+	Containers []struct {
+		Name  string           `json:"name"`
+		Usage api.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// podMetricsSampler records the peak CPU and memory usage of the containers
+// of the job pod, read from the metrics API while the job runs. Sampling
+// stops when the metrics API isn't available to the runner.
+type podMetricsSampler struct {
+	fetch    func(ctx context.Context) (*podMetrics, error)
+	interval time.Duration
+
+	mu   sync.Mutex
+	peak map[string]api.ResourceList
+
+	cancel func()
+	done   chan struct{}
+}
+
+func newPodMetricsSampler(fetch func(ctx context.Context) (*podMetrics, error), interval time.Duration) *podMetricsSampler {
+	return &podMetricsSampler{
+		fetch:    fetch,
+		interval: interval,
+		peak:     map[string]api.ResourceList{},
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *podMetricsSampler) start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	go func() {
+		defer close(p.done)
+
+		t := time.NewTicker(p.interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				err := p.sample(ctx)
+				if kubeerrors.IsForbidden(err) || kubeerrors.IsUnauthorized(err) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (p *podMetricsSampler) stop() {
+	if p == nil || p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	<-p.done
+}
+
+func (p *podMetricsSampler) sample(ctx context.Context) error {
+	metrics, err := p.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range metrics.Containers {
+		peak, ok := p.peak[c.Name]
+		if !ok {
+			peak = api.ResourceList{}
+			p.peak[c.Name] = peak
+		}
+
+		for _, name := range []api.ResourceName{api.ResourceCPU, api.ResourceMemory} {
+			usage, ok := c.Usage[name]
+			if !ok {
+				continue
+			}
+
+			if current, ok := peak[name]; !ok || usage.Cmp(current) > 0 {
+				peak[name] = usage
+			}
+		}
+	}
+
+	return nil
+}
+
+// summary describes the peak usage of the containers sampled.
+func (p *podMetricsSampler) summary() []string {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(p.peak)) {
+		var parts []string
+		for _, resource := range []api.ResourceName{api.ResourceCPU, api.ResourceMemory} {
+			if usage, ok := p.peak[name][resource]; ok {
+				parts = append(parts, fmt.Sprintf("%s %s", resource, usage.String()))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("container %q: %s", name, strings.Join(parts, ", ")))
+	}
+
+	return lines
+}
+
+// peakUsage returns the peak usage of the container sampled, which is empty
+// when the metrics API isn't available.
+func (p *podMetricsSampler) peakUsage(container string) api.ResourceList {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.peak[container].DeepCopy()
+}
+
+// startPodMetricsSampler starts sampling the resource usage of the job pod,
+// once it's created, until the executor is cleaned up.
+func (s *executor) startPodMetricsSampler() {
+	if s.podMetrics != nil || s.pod == nil || s.kubeConfig == nil {
+		return
+	}
+
+	config := restclient.CopyConfig(s.kubeConfig)
+	config.APIPath = "/apis"
+	config.GroupVersion = &podMetricsGroupVersion
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+
+	client, err := restclient.RESTClientFor(config)
+	if err != nil {
+		s.BuildLogger.Debugln("Creating metrics API client:", err)
+		return
+	}
+
+	namespace, name := s.pod.Namespace, s.pod.Name
+	s.podMetrics = newPodMetricsSampler(func(ctx context.Context) (*podMetrics, error) {
+		data, err := client.Get().Namespace(namespace).Resource("pods").Name(name).DoRaw(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var metrics podMetrics
+		if err := json.Unmarshal(data, &metrics); err != nil {
+			return nil, fmt.Errorf("decoding metrics of pod %s: %w", name, err)
+		}
+
+		return &metrics, nil
+	}, podMetricsInterval)
+
+	s.podMetrics.start(context.Background())
+}
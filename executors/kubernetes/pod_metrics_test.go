@@ -0,0 +1,74 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPodMetricsSampler(t *testing.T) {
+	samples := []string{
+		`{"containers": [{"name": "build", "usage": {"cpu": "100m", "memory": "200Mi"}}]}`,
+		`{"containers": [{"name": "build", "usage": {"cpu": "250m", "memory": "100Mi"}}, {"name": "helper", "usage": {"memory": "10Mi"}}]}`,
+	}
+
+	var i int
+	p := newPodMetricsSampler(func(context.Context) (*podMetrics, error) {
+		var metrics podMetrics
+		err := json.Unmarshal([]byte(samples[i]), &metrics)
+		i++
+		return &metrics, err
+	}, time.Second)
+
+	for range samples {
+		require.NoError(t, p.sample(t.Context()))
+	}
+
+	assert.Equal(t, api.ResourceList{
+		api.ResourceCPU:    resource.MustParse("250m"),
+		api.ResourceMemory: resource.MustParse("200Mi"),
+	}, p.peakUsage("build"))
+	assert.Empty(t, p.peakUsage("svc-0"))
+
+	assert.Equal(t, []string{
+		`container "build": cpu 250m, memory 200Mi`,
+		`container "helper": memory 10Mi`,
+	}, p.summary())
+}
+
+func TestPodMetricsSampler_StopsWhenForbidden(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	p := newPodMetricsSampler(func(context.Context) (*podMetrics, error) {
+		calls <- struct{}{}
+		return nil, kubeerrors.NewForbidden(schema.GroupResource{Group: "metrics.k8s.io", Resource: "pods"}, "job-pod", nil)
+	}, time.Millisecond)
+
+	p.start(t.Context())
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "sampling didn't stop")
+	}
+	p.stop()
+
+	assert.Len(t, calls, 1)
+}
+
+func TestPodMetricsSampler_Nil(t *testing.T) {
+	var p *podMetricsSampler
+
+	p.stop()
+	assert.Nil(t, p.peakUsage("build"))
+	assert.Nil(t, p.summary())
+}
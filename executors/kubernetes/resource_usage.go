@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/limitwriter"
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
+)
+
+// memoryPeakOutputLimit bounds the output read from MemoryPeakCommand
+const memoryPeakOutputLimit = 1024
+
+// podMetricsList is the subset of the PodMetricsList of the metrics API read
+// to sample the resource usage of the job pod.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		podMetrics
+	} `json:"items"`
+}
+
+// SampleResourceUsage returns the resource usage of the containers of the
+// job pod, read from the metrics API. The metrics API reports neither block
+// IO nor network usage, and reports the CPU usage rate instead of the CPU
+// time.
+func (s *executor) SampleResourceUsage(ctx context.Context) (map[string]referees.ResourceUsage, error) {
+	namespace := s.configurationOverwrites.namespace
+
+	// kubeAPI: metrics.k8s.io/pods, list, referees.resource_usage
+	data, err := s.kubeClient.CoreV1().RESTClient().Get().
+		AbsPath("/apis", podMetricsGroupVersion.Group, podMetricsGroupVersion.Version, "namespaces", namespace, "pods").
+		Param("labelSelector", labels.SelectorFromSet(map[string]string{
+			"job." + runnerLabelNamespace + "/pod":          sanitizeLabel(s.Build.ProjectUniqueName()),
+			"manager." + runnerLabelNamespace + "/id-short": sanitizeLabel(s.Config.ShortDescription()),
+		}).String()).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading metrics of job pods: %w", err)
+	}
+
+	var metrics podMetricsList
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("decoding metrics of job pods: %w", err)
+	}
+
+	usage := map[string]referees.ResourceUsage{}
+	for _, pod := range metrics.Items {
+		maps.Copy(usage, pod.resourceUsage())
+	}
+
+	return usage, nil
+}
+
+// MemoryPeakUsage returns the peak memory usage of the running containers of
+// the job pod, read from their cgroup, which the metrics API doesn't report.
+func (s *executor) MemoryPeakUsage(ctx context.Context) (map[string]uint64, error) {
+	if s.pod == nil {
+		return nil, nil
+	}
+
+	// kubeAPI: pods, get
+	pod, err := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reading job pod: %w", err)
+	}
+
+	peaks := map[string]uint64{}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil {
+			continue
+		}
+
+		if peak := s.readMemoryPeak(ctx, pod.Namespace, pod.Name, status.Name); peak > 0 {
+			peaks[status.Name] = peak
+		}
+	}
+
+	return peaks, nil
+}
+
+// readMemoryPeak returns the highest memory usage recorded by the cgroup of
+// the container, or zero when it can't be read, for example when the image
+// has no shell.
+func (s *executor) readMemoryPeak(ctx context.Context, namespace, pod, container string) uint64 {
+	var out bytes.Buffer
+	exec := ExecOptions{
+		Namespace:     namespace,
+		PodName:       pod,
+		ContainerName: container,
+		Command:       []string{"sh", "-c", referees.MemoryPeakCommand},
+		Out:           limitwriter.New(&out, memoryPeakOutputLimit),
+		Executor:      &DefaultRemoteExecutor{},
+		KubeClient:    s.kubeClient,
+		Config:        s.kubeConfig,
+		Context:       ctx,
+	}
+
+	err := exec.Run()
+	var peak uint64
+	if err == nil {
+		peak, err = referees.ParseMemoryPeak(out.String())
+	}
+	if err != nil {
+		s.BuildLogger.Debugln("Failed to read peak memory usage of container", container, err)
+	}
+
+	return peak
+}
+
+// resourceUsage returns the resource usage of the containers of the pod,
+// keyed by container name.
+func (m podMetrics) resourceUsage() map[string]referees.ResourceUsage {
+	usage := map[string]referees.ResourceUsage{}
+	for _, c := range m.Containers {
+		u := referees.ResourceUsage{}
+		if cpu, ok := c.Usage[api.ResourceCPU]; ok {
+			u.CPUCores = cpu.AsApproximateFloat64()
+		}
+		if memory, ok := c.Usage[api.ResourceMemory]; ok {
+			u.MemoryBytes = uint64(max(memory.Value(), 0))
+		}
+
+		usage[c.Name] = u
+	}
+
+	return usage
+}
@@ -0,0 +1,43 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/referees"
+)
+
+func TestPodMetricsResourceUsage(t *testing.T) {
+	data := `{
+		"kind": "PodMetricsList",
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"items": [
+			{
+				"metadata": {"name": "runner-job", "namespace": "ci"},
+				"window": "15s",
+				"containers": [
+					{"name": "build", "usage": {"cpu": "1500m", "memory": "1Ki"}},
+					{"name": "helper", "usage": {"cpu": "250000n", "memory": "512"}},
+					{"name": "svc-0", "usage": {"memory": "2Ki"}}
+				]
+			}
+		]
+	}`
+
+	var metrics podMetricsList
+	require.NoError(t, json.Unmarshal([]byte(data), &metrics))
+	require.Len(t, metrics.Items, 1)
+
+	pod := metrics.Items[0]
+	assert.Equal(t, "runner-job", pod.Metadata.Name)
+	assert.Equal(t, map[string]referees.ResourceUsage{
+		"build":  {CPUCores: 1.5, MemoryBytes: 1024},
+		"helper": {CPUCores: 0.00025, MemoryBytes: 512},
+		"svc-0":  {MemoryBytes: 2048},
+	}, pod.resourceUsage())
+}
@@ -0,0 +1,166 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/runnerjob"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/dns"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/retry"
+)
+
+// usesRunnerJob returns whether the resources of the job are created by the
+// k8s-controller, from the RunnerJob the executor creates.
+func (s *executor) usesRunnerJob() bool {
+	return s.Config.Kubernetes.RunnerJobResource
+}
+
+// guardRunnerJobCompatibility fails the job when it uses resources the
+// RunnerJob doesn't describe.
+func (s *executor) guardRunnerJobCompatibility() error {
+	if !s.usesRunnerJob() {
+		return nil
+	}
+
+	var err error
+	switch {
+	case s.Config.Kubernetes.NamespacePerJob:
+		err = errors.New("runner_job_resource is not supported with namespace_per_job")
+	case s.Config.Kubernetes.GetPodDisruptionBudget():
+		err = errors.New("runner_job_resource is not supported with pod_disruption_budget")
+	case s.usesSuspendResume():
+		err = errors.New("runner_job_resource is not supported with suspendable environments")
+	}
+
+	if err != nil {
+		return &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+
+	return nil
+}
+
+// setupRunnerJob creates the RunnerJob describing the pod and the proxy
+// services of the job, and waits for the k8s-controller to create them. The
+// credentials secret is created by the executor and only referenced by the
+// image pull secrets of the pod, for the RunnerJob not to contain them.
+func (s *executor) setupRunnerJob(ctx context.Context, pod *api.Pod) error {
+	namespace := s.configurationOverwrites.namespace
+
+	job := &runnerjob.RunnerJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: namespace,
+			Labels:    pod.Labels,
+		},
+		Spec: runnerjob.Spec{
+			Pod:      api.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec},
+			Services: s.runnerJobServices(),
+		},
+	}
+
+	s.BuildLogger.Debugln("Creating RunnerJob", job.Name)
+
+	var err error
+	s.runnerJob, err = retry.WithValueFn(s, func() (*runnerjob.RunnerJob, error) {
+		created, err := s.runnerJobClient.Create(ctx, job)
+		if kubeerrors.IsAlreadyExists(err) {
+			return s.runnerJobClient.Get(ctx, namespace, job.Name)
+		}
+		return created, err
+	}).Run()
+	if err != nil {
+		return fmt.Errorf("creating RunnerJob: %w", err)
+	}
+
+	s.pod, err = s.waitForRunnerJobPod(ctx)
+	if err != nil {
+		return err
+	}
+
+	if data, ok := s.Build.ExecutorData.(*executorData); ok {
+		data.PodName = s.pod.GetName()
+	}
+
+	s.services = job.Spec.Services
+
+	return nil
+}
+
+// runnerJobServices returns the proxy services of the job, which the
+// k8s-controller creates with their names.
+func (s *executor) runnerJobServices() []api.Service {
+	var services []api.Service
+	for serviceName, serviceProxy := range s.ProxyPool {
+		serviceName = dns.MakeRFC1123Compatible(serviceName)
+		service := s.prepareServiceConfig(serviceName, proxyServicePorts(serviceName, serviceProxy.Settings.Ports), nil)
+
+		serviceProxy.Settings.ServiceName = service.Name
+		services = append(services, service)
+	}
+
+	return services
+}
+
+// waitForRunnerJobPod waits for the k8s-controller to report the pod of the
+// RunnerJob created, and returns it.
+func (s *executor) waitForRunnerJobPod(ctx context.Context) (*api.Pod, error) {
+	timeout := time.Duration(s.Config.Kubernetes.GetPollTimeout()) * time.Second
+	interval := time.Duration(s.Config.Kubernetes.GetPollInterval()) * time.Second
+
+	s.BuildLogger.Println("Waiting for the controller to create the pod of RunnerJob", s.runnerJob.Name, "...")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var message string
+	for {
+		job, err := s.runnerJobClient.Get(ctx, s.runnerJob.Namespace, s.runnerJob.Name)
+		switch {
+		case err != nil:
+			message = err.Error()
+		case job.Status.Phase == runnerjob.PhaseFailed:
+			return nil, &common.BuildError{
+				Inner:         fmt.Errorf("RunnerJob %s failed: %s", job.Name, job.Status.Message),
+				FailureReason: common.ConfigurationError,
+			}
+		case job.Status.PodName != "":
+			// kubeAPI: pods, get
+			return s.kubeClient.CoreV1().Pods(job.Namespace).Get(ctx, job.Status.PodName, metav1.GetOptions{})
+		default:
+			message = job.Status.Message
+		}
+
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("timed out waiting for the controller to create the pod of RunnerJob %s", s.runnerJob.Name)
+			if message != "" {
+				err = fmt.Errorf("%w: %s", err, message)
+			}
+			return nil, &common.BuildError{Inner: err, FailureReason: common.RunnerSystemFailure}
+		case <-time.After(interval):
+		}
+	}
+}
+
+// deleteRunnerJob deletes the RunnerJob, and with it the resources the
+// k8s-controller created for it.
+func (s *executor) deleteRunnerJob(ctx context.Context) {
+	kubeRequest := retry.WithFn(s, func() error {
+		return s.runnerJobClient.Delete(ctx, s.runnerJob.Namespace, s.runnerJob.Name, metav1.DeleteOptions{
+			PropagationPolicy: &PropagationPolicy,
+		})
+	})
+
+	if err := kubeRequest.Run(); err != nil && !kubeerrors.IsNotFound(err) {
+		s.BuildLogger.Errorln(fmt.Sprintf("Error cleaning up RunnerJob: %s", err.Error()))
+	}
+
+	s.runnerJob = nil
+}
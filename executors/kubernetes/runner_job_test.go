@@ -0,0 +1,191 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/runnerjob"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+)
+
+func TestGuardRunnerJobCompatibility(t *testing.T) {
+	tests := map[string]struct {
+		config      common.KubernetesConfig
+		suspendable bool
+		wantErr     string
+	}{
+		"disabled": {
+			config: common.KubernetesConfig{NamespacePerJob: true},
+		},
+		"enabled": {
+			config: common.KubernetesConfig{RunnerJobResource: true},
+		},
+		"namespace per job": {
+			config:  common.KubernetesConfig{RunnerJobResource: true, NamespacePerJob: true},
+			wantErr: "namespace_per_job",
+		},
+		"pod disruption budget": {
+			config:  common.KubernetesConfig{RunnerJobResource: true, PodDisruptionBudget: new(true)},
+			wantErr: "pod_disruption_budget",
+		},
+		"suspendable environments": {
+			config:      common.KubernetesConfig{RunnerJobResource: true},
+			suspendable: true,
+			wantErr:     "suspendable environments",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := newTestExecutorWithKubeClient(t, &tt.config)
+			if tt.suspendable {
+				e.Build.Job.SuspendOptions = spec.SuspendOptions{SuspendOnSuccess: true}
+				e.Build.Job.Variables = spec.Variables{{Key: featureflags.SuspendableEnvironments, Value: "true"}}
+			}
+
+			err := e.guardRunnerJobCompatibility()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var buildErr *common.BuildError
+			require.ErrorAs(t, err, &buildErr)
+			assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func newTestRunnerJobExecutor(t *testing.T) (*executor, *runnerjob.Controller) {
+	t.Helper()
+
+	e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{
+		RunnerJobResource: true,
+		PollTimeout:       1,
+		PollInterval:      1,
+	})
+	e.Build.ExecutorData = &executorData{}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{runnerjob.GroupVersionResource: runnerjob.Kind + "List"},
+	)
+	e.runnerJobClient = runnerjob.NewClient(dynamicClient)
+
+	controller := runnerjob.NewController(e.kubeClient, dynamicClient, "", runnerjob.Policy{}, 0, logrus.New())
+
+	return e, controller
+}
+
+func newTestRunnerJobPod() *api.Pod {
+	return &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "runner-job-pod",
+			Namespace: "test-ns",
+			Labels: map[string]string{
+				runnerjob.RunnerLabel: "abcdef",
+				runnerjob.PodLabel:    "runner-job",
+			},
+		},
+		Spec: api.PodSpec{Containers: []api.Container{{Name: "build", Image: "alpine"}}},
+	}
+}
+
+func TestSetupRunnerJob(t *testing.T) {
+	t.Run("pod created by the controller", func(t *testing.T) {
+		e, controller := newTestRunnerJobExecutor(t)
+		e.credentials = &api.Secret{ObjectMeta: metav1.ObjectMeta{Name: "runner-job-creds"}}
+		pod := newTestRunnerJobPod()
+
+		// The RunnerJob was created, and reconciled, by a previous attempt.
+		_, err := e.runnerJobClient.Create(t.Context(), &runnerjob.RunnerJob{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: "test-ns"},
+			Spec:       runnerjob.Spec{Pod: api.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, controller.Reconcile(t.Context(), "test-ns", pod.Name))
+
+		require.NoError(t, e.setupRunnerJob(t.Context(), pod))
+
+		require.NotNil(t, e.pod)
+		assert.Equal(t, pod.Name, e.pod.Name)
+		assert.Equal(t, pod.Name, e.Build.ExecutorData.(*executorData).PodName)
+		require.NotNil(t, e.runnerJob)
+		assert.Equal(t, runnerjob.PhaseCreated, e.runnerJob.Status.Phase)
+	})
+
+	t.Run("RunnerJob failed", func(t *testing.T) {
+		e, controller := newTestRunnerJobExecutor(t)
+		e.kubeClient.(*fake.Clientset).PrependReactor("create", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, kubeerrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "runner-job-pod", nil)
+		})
+		pod := newTestRunnerJobPod()
+
+		_, err := e.runnerJobClient.Create(t.Context(), &runnerjob.RunnerJob{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: "test-ns"},
+			Spec:       runnerjob.Spec{Pod: api.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, controller.Reconcile(t.Context(), "test-ns", pod.Name))
+
+		err = e.setupRunnerJob(t.Context(), pod)
+
+		var buildErr *common.BuildError
+		require.ErrorAs(t, err, &buildErr)
+		assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+		assert.ErrorContains(t, err, "creating pod")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		e, _ := newTestRunnerJobExecutor(t)
+		pod := newTestRunnerJobPod()
+
+		err := e.setupRunnerJob(t.Context(), pod)
+
+		var buildErr *common.BuildError
+		require.ErrorAs(t, err, &buildErr)
+		assert.Equal(t, common.RunnerSystemFailure, buildErr.FailureReason)
+		assert.ErrorContains(t, err, "timed out waiting for the controller")
+
+		job, err := e.runnerJobClient.Get(t.Context(), "test-ns", pod.Name)
+		require.NoError(t, err)
+		assert.Equal(t, pod.Spec, job.Spec.Pod.Spec)
+	})
+}
+
+func TestDeleteRunnerJob(t *testing.T) {
+	e, _ := newTestRunnerJobExecutor(t)
+
+	job, err := e.runnerJobClient.Create(t.Context(), &runnerjob.RunnerJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-job", Namespace: "test-ns"},
+	})
+	require.NoError(t, err)
+
+	e.runnerJob = job
+	e.deleteRunnerJob(t.Context())
+	assert.Nil(t, e.runnerJob)
+
+	_, err = e.runnerJobClient.Get(t.Context(), "test-ns", "runner-job")
+	assert.True(t, kubeerrors.IsNotFound(err))
+
+	// Deleting a RunnerJob already deleted doesn't fail.
+	e.runnerJob = job
+	e.deleteRunnerJob(t.Context())
+	assert.Nil(t, e.runnerJob)
+}
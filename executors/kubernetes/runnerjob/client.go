@@ -0,0 +1,99 @@
+package runnerjob
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	restclient "k8s.io/client-go/rest"
+)
+
+// Client creates, gets and deletes RunnerJobs, and updates their status.
+type Client struct {
+	client dynamic.Interface
+}
+
+func NewClient(client dynamic.Interface) *Client {
+	return &Client{client: client}
+}
+
+func NewForConfig(config *restclient.Config) (*Client, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(client), nil
+}
+
+func (c *Client) Create(ctx context.Context, job *RunnerJob) (*RunnerJob, error) {
+	job = job.DeepCopy()
+	job.APIVersion = Group + "/" + Version
+	job.Kind = Kind
+
+	obj, err := toUnstructured(job)
+	if err != nil {
+		return nil, err
+	}
+
+	// kubeAPI: runner.gitlab.com/runnerjobs, create, runner_job_resource=true
+	created, err := c.client.Resource(GroupVersionResource).Namespace(job.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured(created)
+}
+
+func (c *Client) Get(ctx context.Context, namespace, name string) (*RunnerJob, error) {
+	// kubeAPI: runner.gitlab.com/runnerjobs, get, runner_job_resource=true
+	obj, err := c.client.Resource(GroupVersionResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured(obj)
+}
+
+func (c *Client) Delete(ctx context.Context, namespace, name string, options metav1.DeleteOptions) error {
+	// kubeAPI: runner.gitlab.com/runnerjobs, delete, runner_job_resource=true
+	return c.client.Resource(GroupVersionResource).Namespace(namespace).Delete(ctx, name, options)
+}
+
+// UpdateStatus updates the status of the RunnerJob, which only the controller
+// does.
+func (c *Client) UpdateStatus(ctx context.Context, job *RunnerJob) (*RunnerJob, error) {
+	obj, err := toUnstructured(job)
+	if err != nil {
+		return nil, err
+	}
+
+	// kubeAPI: runner.gitlab.com/runnerjobs/status, update, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+	updated, err := c.client.Resource(GroupVersionResource).Namespace(job.Namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured(updated)
+}
+
+func toUnstructured(job *RunnerJob) (*unstructured.Unstructured, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return nil, fmt.Errorf("converting runner job %s: %w", job.Name, err)
+	}
+
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+func fromUnstructured(obj *unstructured.Unstructured) (*RunnerJob, error) {
+	var job RunnerJob
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+		return nil, fmt.Errorf("converting runner job %s: %w", obj.GetName(), err)
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,271 @@
+package runnerjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller creates the pod and services of the RunnerJobs the policy allows,
+// owned by them, and reports the pod back in their status.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	jobs       *Client
+	namespace  string
+	policy     Policy
+	logger     logrus.FieldLogger
+
+	jobInformers dynamicinformer.DynamicSharedInformerFactory
+	podInformers informers.SharedInformerFactory
+}
+
+// NewController returns the controller of the RunnerJobs of the namespace, or
+// of all the namespaces when it's empty.
+func NewController(
+	kubeClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	namespace string,
+	policy Policy,
+	resync time.Duration,
+	logger logrus.FieldLogger,
+) *Controller {
+	return &Controller{
+		kubeClient:   kubeClient,
+		jobs:         NewClient(dynamicClient),
+		namespace:    namespace,
+		policy:       policy,
+		logger:       logger,
+		jobInformers: dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resync, namespace, nil),
+		podInformers: informers.NewSharedInformerFactoryWithOptions(kubeClient, resync, informers.WithNamespace(namespace)),
+	}
+}
+
+// Run reconciles the RunnerJobs, when they're created and when their pods
+// change, with the workers, until the context is done.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[cache.ObjectName]())
+	defer queue.ShutDown()
+
+	// kubeAPI: runner.gitlab.com/runnerjobs, list, watch, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+	jobInformer := c.jobInformers.ForResource(GroupVersionResource).Informer()
+	_, err := jobInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { enqueue(queue, obj) },
+		UpdateFunc: func(_, obj any) { enqueue(queue, obj) },
+	})
+	if err != nil {
+		return err
+	}
+
+	// kubeAPI: pods, list, watch, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+	podInformer := c.podInformers.Core().V1().Pods().Informer()
+	_, err = podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj any) { enqueueOwner(queue, obj) },
+		DeleteFunc: func(obj any) { enqueueOwner(queue, obj) },
+	})
+	if err != nil {
+		return err
+	}
+
+	// kubeAPI: ignore
+	c.jobInformers.Start(ctx.Done())
+	// kubeAPI: ignore
+	c.podInformers.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), jobInformer.HasSynced, podInformer.HasSynced) {
+		return errors.New("waiting for the caches to sync")
+	}
+
+	c.logger.WithField("namespace", c.namespace).Infoln("Reconciling runner jobs")
+
+	for range max(1, workers) {
+		go func() {
+			for c.processNext(ctx, queue) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (c *Controller) processNext(ctx context.Context, queue workqueue.TypedRateLimitingInterface[cache.ObjectName]) bool {
+	key, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(key)
+
+	if err := c.Reconcile(ctx, key.Namespace, key.Name); err != nil {
+		c.logger.WithError(err).WithField("runner-job", key.String()).Warningln("Reconciling runner job")
+		queue.AddRateLimited(key)
+		return true
+	}
+
+	queue.Forget(key)
+
+	return true
+}
+
+func enqueue(queue workqueue.TypedRateLimitingInterface[cache.ObjectName], obj any) {
+	key, err := cache.ObjectToName(obj)
+	if err == nil {
+		queue.Add(key)
+	}
+}
+
+func enqueueOwner(queue workqueue.TypedRateLimitingInterface[cache.ObjectName], obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	pod, ok := obj.(*api.Pod)
+	if !ok {
+		return
+	}
+
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == Kind && owner.APIVersion == Group+"/"+Version {
+		queue.Add(cache.NewObjectName(pod.Namespace, owner.Name))
+	}
+}
+
+// Reconcile creates the resources of the RunnerJob that don't exist yet, and
+// updates its status. An error it can't recover from, like an invalid pod or
+// one the policy doesn't allow, fails the RunnerJob, other errors are returned for the RunnerJob to be
+// reconciled again.
+func (c *Controller) Reconcile(ctx context.Context, namespace, name string) error {
+	job, err := c.jobs.Get(ctx, namespace, name)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if job.DeletionTimestamp != nil || job.Status.Phase == PhaseFailed {
+		return nil
+	}
+
+	if job.Status.Phase == PhaseCreated {
+		return c.reconcilePodPhase(ctx, job)
+	}
+
+	status := job.Status
+	pod, err := c.createResources(ctx, job)
+	switch {
+	case isPermanent(err):
+		status = Status{Phase: PhaseFailed, Message: err.Error()}
+	case err != nil:
+		status.Phase = PhasePending
+		status.Message = err.Error()
+	default:
+		status = Status{Phase: PhaseCreated, PodName: pod.Name, PodPhase: pod.Status.Phase}
+	}
+
+	if updateErr := c.updateStatus(ctx, job, status); updateErr != nil {
+		return errors.Join(err, updateErr)
+	}
+
+	if isPermanent(err) {
+		return nil
+	}
+
+	return err
+}
+
+// reconcilePodPhase reports the phase of the pod of the RunnerJob. The pod
+// isn't created again once deleted, as the job it ran is lost.
+func (c *Controller) reconcilePodPhase(ctx context.Context, job *RunnerJob) error {
+	// kubeAPI: pods, get, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+	pod, err := c.kubeClient.CoreV1().Pods(job.Namespace).Get(ctx, job.Status.PodName, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return c.updateStatus(ctx, job, Status{
+			Phase:   PhaseFailed,
+			PodName: job.Status.PodName,
+			Message: fmt.Sprintf("pod %s was deleted", job.Status.PodName),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	status := job.Status
+	status.PodPhase = pod.Status.Phase
+
+	return c.updateStatus(ctx, job, status)
+}
+
+func (c *Controller) updateStatus(ctx context.Context, job *RunnerJob, status Status) error {
+	if status == job.Status {
+		return nil
+	}
+
+	job.Status = status
+	if _, err := c.jobs.UpdateStatus(ctx, job); err != nil {
+		return fmt.Errorf("updating status: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Controller) createResources(ctx context.Context, job *RunnerJob) (*api.Pod, error) {
+	if err := c.policy.validate(job); err != nil {
+		return nil, err
+	}
+
+	owner := job.ownerReference()
+
+	pod := &api.Pod{ObjectMeta: *job.Spec.Pod.ObjectMeta.DeepCopy(), Spec: *job.Spec.Pod.Spec.DeepCopy()}
+	if pod.Name == "" {
+		pod.Name = job.Name
+	}
+	pod.Namespace = job.Namespace
+	pod.OwnerReferences = append(pod.OwnerReferences, owner)
+
+	// kubeAPI: pods, create, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+	created, err := c.kubeClient.CoreV1().Pods(job.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if kubeerrors.IsAlreadyExists(err) {
+		// kubeAPI: pods, get, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+		created, err = c.kubeClient.CoreV1().Pods(job.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		// the pod was created by an earlier reconciliation of the RunnerJob,
+		// and not by anything else
+		if err == nil && !metav1.IsControlledBy(created, job) {
+			err = fmt.Errorf("%w: pod %s exists and isn't owned by the runner job", errForbidden, pod.Name)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating pod %s: %w", pod.Name, err)
+	}
+
+	for i := range job.Spec.Services {
+		service := job.Spec.Services[i].DeepCopy()
+		service.Namespace = job.Namespace
+		service.OwnerReferences = append(service.OwnerReferences, owner)
+
+		// kubeAPI: services, create, running the k8s-controller=https://docs.gitlab.com/runner/executors/kubernetes/#create-job-pods-with-a-controller
+		_, err := c.kubeClient.CoreV1().Services(job.Namespace).Create(ctx, service, metav1.CreateOptions{})
+		if err != nil && !kubeerrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("creating service %s: %w", service.Name, err)
+		}
+	}
+
+	return created, nil
+}
+
+// isPermanent returns whether the error creating a resource is one retrying
+// doesn't fix.
+func isPermanent(err error) bool {
+	return errors.Is(err, errForbidden) || kubeerrors.IsInvalid(err) || kubeerrors.IsBadRequest(err)
+}
@@ -0,0 +1,448 @@
+//go:build !integration
+
+package runnerjob
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "ci"
+
+func newTestRunnerJob() *RunnerJob {
+	return &RunnerJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner-abc-project-1-concurrent-0", Namespace: testNamespace},
+		Spec: Spec{
+			Pod: api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "runner-abc-project-1-concurrent-0-xyz",
+					Labels: map[string]string{
+						RunnerLabel: "abcdef",
+						PodLabel:    "runner-abc-project-1-concurrent-0",
+					},
+				},
+				Spec: api.PodSpec{
+					Containers:       []api.Container{{Name: "build", Image: "alpine"}},
+					ImagePullSecrets: []api.LocalObjectReference{{Name: "runner-abc-project-1-concurrent-0-creds"}},
+				},
+			},
+			Services: []api.Service{{
+				ObjectMeta: metav1.ObjectMeta{Name: "proxy-db-abc"},
+				Spec: api.ServiceSpec{
+					Ports:    []api.ServicePort{{Port: 5432}},
+					Selector: map[string]string{PodLabel: "runner-abc-project-1-concurrent-0"},
+				},
+			}},
+		},
+	}
+}
+
+func newTestController(t *testing.T, kubeClient *fake.Clientset, runnerJob *RunnerJob, policy Policy) (*Controller, *Client) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GroupVersionResource: Kind + "List"},
+	)
+
+	c := NewController(kubeClient, dynamicClient, testNamespace, policy, 0, logrus.New())
+
+	job, err := c.jobs.Create(t.Context(), runnerJob)
+	require.NoError(t, err)
+	require.Equal(t, Group+"/"+Version, job.APIVersion)
+
+	return c, c.jobs
+}
+
+func TestController_Reconcile(t *testing.T) {
+	job := newTestRunnerJob()
+
+	t.Run("creates the resources", func(t *testing.T) {
+		kubeClient := fake.NewClientset()
+		c, jobs := newTestController(t, kubeClient, job, Policy{})
+
+		require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+		require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name), "reconciling again is a no-op")
+
+		reconciled, err := jobs.Get(t.Context(), testNamespace, job.Name)
+		require.NoError(t, err)
+		assert.Equal(t, Status{Phase: PhaseCreated, PodName: job.Spec.Pod.Name}, reconciled.Status)
+
+		pod, err := kubeClient.CoreV1().Pods(testNamespace).Get(t.Context(), job.Spec.Pod.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, job.Spec.Pod.Labels, pod.Labels)
+		require.Len(t, pod.OwnerReferences, 1)
+		assert.Equal(t, Kind, pod.OwnerReferences[0].Kind)
+		assert.Equal(t, job.Name, pod.OwnerReferences[0].Name)
+		assert.True(t, *pod.OwnerReferences[0].Controller)
+
+		service, err := kubeClient.CoreV1().Services(testNamespace).Get(t.Context(), "proxy-db-abc", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Len(t, service.OwnerReferences, 1)
+
+		pod.Status.Phase = api.PodRunning
+		_, err = kubeClient.CoreV1().Pods(testNamespace).UpdateStatus(t.Context(), pod, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+		reconciled, err = jobs.Get(t.Context(), testNamespace, job.Name)
+		require.NoError(t, err)
+		assert.Equal(t, api.PodRunning, reconciled.Status.PodPhase)
+
+		require.NoError(t, kubeClient.CoreV1().Pods(testNamespace).Delete(t.Context(), pod.Name, metav1.DeleteOptions{}))
+
+		require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+		reconciled, err = jobs.Get(t.Context(), testNamespace, job.Name)
+		require.NoError(t, err)
+		assert.Equal(t, PhaseFailed, reconciled.Status.Phase)
+		assert.Contains(t, reconciled.Status.Message, "was deleted")
+	})
+
+	t.Run("invalid pod", func(t *testing.T) {
+		kubeClient := fake.NewClientset()
+		kubeClient.PrependReactor("create", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, kubeerrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, job.Spec.Pod.Name, nil)
+		})
+		c, jobs := newTestController(t, kubeClient, job, Policy{})
+
+		require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+
+		reconciled, err := jobs.Get(t.Context(), testNamespace, job.Name)
+		require.NoError(t, err)
+		assert.Equal(t, PhaseFailed, reconciled.Status.Phase)
+		assert.Contains(t, reconciled.Status.Message, "creating pod")
+	})
+
+	t.Run("transient error", func(t *testing.T) {
+		errUnavailable := errors.New("etcdserver: request timed out")
+
+		kubeClient := fake.NewClientset()
+		kubeClient.PrependReactor("create", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errUnavailable
+		})
+		c, jobs := newTestController(t, kubeClient, job, Policy{})
+
+		err := c.Reconcile(t.Context(), testNamespace, job.Name)
+		assert.ErrorIs(t, err, errUnavailable)
+
+		reconciled, err := jobs.Get(t.Context(), testNamespace, job.Name)
+		require.NoError(t, err)
+		assert.Equal(t, PhasePending, reconciled.Status.Phase)
+		assert.Contains(t, reconciled.Status.Message, errUnavailable.Error())
+	})
+
+	t.Run("deleted runner job", func(t *testing.T) {
+		c, jobs := newTestController(t, fake.NewClientset(), job, Policy{})
+		require.NoError(t, jobs.Delete(t.Context(), testNamespace, job.Name, metav1.DeleteOptions{}))
+
+		assert.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+	})
+}
+
+func TestController_Reconcile_Policy(t *testing.T) {
+	tests := map[string]struct {
+		policy        Policy
+		modify        func(job *RunnerJob)
+		expectedError string
+	}{
+		"allowed": {
+			policy: Policy{ServiceAccounts: []string{"ci"}, Runners: []string{"abcdef"}},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.ServiceAccountName = "ci"
+			},
+		},
+		"default service account": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.ServiceAccountName = "default"
+			},
+		},
+		"forbidden service account": {
+			policy: Policy{ServiceAccounts: []string{"ci"}},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.ServiceAccountName = "cluster-admin"
+			},
+			expectedError: `service account "cluster-admin" isn't allowed`,
+		},
+		"forbidden runner": {
+			policy:        Policy{Runners: []string{"ghijkl"}},
+			expectedError: `runner "abcdef" isn't allowed`,
+		},
+		"pod without the runner label": {
+			modify: func(job *RunnerJob) {
+				delete(job.Spec.Pod.Labels, RunnerLabel)
+			},
+			expectedError: "pod without the " + RunnerLabel + " label",
+		},
+		"pod without the pod label": {
+			modify: func(job *RunnerJob) {
+				delete(job.Spec.Pod.Labels, PodLabel)
+			},
+			expectedError: "pod without the " + PodLabel + " label",
+		},
+		"service selecting other pods": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Services[0].Spec.Selector = map[string]string{"app": "database"}
+			},
+			expectedError: "service proxy-db-abc doesn't select the pod of the runner job",
+		},
+		"privileged container": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{Privileged: new(true)}
+			},
+			expectedError: "privileged container build",
+		},
+		"allowed privileged container": {
+			policy: Policy{AllowPrivileged: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{Privileged: new(true)}
+			},
+		},
+		"added capability": {
+			policy: Policy{Capabilities: []string{"NET_ADMIN"}},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.InitContainers = []api.Container{{
+					Name:            "init-permissions",
+					SecurityContext: &api.SecurityContext{Capabilities: &api.Capabilities{Add: []api.Capability{"NET_ADMIN", "SYS_ADMIN"}}},
+				}}
+			},
+			expectedError: "container init-permissions adding the SYS_ADMIN capability",
+		},
+		"allowed capability": {
+			policy: Policy{Capabilities: []string{"NET_ADMIN"}},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{Capabilities: &api.Capabilities{Add: []api.Capability{"NET_ADMIN"}}}
+			},
+		},
+		"privilege escalation": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{AllowPrivilegeEscalation: new(true)}
+			},
+			expectedError: "container build allowing privilege escalation",
+		},
+		"allowed privilege escalation": {
+			policy: Policy{AllowPrivileged: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{AllowPrivilegeEscalation: new(true)}
+			},
+		},
+		"no privilege escalation": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{AllowPrivilegeEscalation: new(false)}
+			},
+		},
+		"unmasked proc mount": {
+			policy: Policy{AllowPrivileged: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{ProcMount: new(api.UnmaskedProcMount)}
+			},
+			expectedError: "container build with the Unmasked /proc mount",
+		},
+		"unconfined container seccomp profile": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{
+					SeccompProfile: &api.SeccompProfile{Type: api.SeccompProfileTypeUnconfined},
+				}
+			},
+			expectedError: "container build without seccomp profile",
+		},
+		"unconfined pod seccomp profile": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{
+					SeccompProfile: &api.SeccompProfile{Type: api.SeccompProfileTypeUnconfined},
+				}
+			},
+			expectedError: "pod without seccomp profile",
+		},
+		"runtime default seccomp profile": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{
+					SeccompProfile: &api.SeccompProfile{Type: api.SeccompProfileTypeRuntimeDefault},
+				}
+			},
+		},
+		"unconfined container AppArmor profile": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{
+					AppArmorProfile: &api.AppArmorProfile{Type: api.AppArmorProfileTypeUnconfined},
+				}
+			},
+			expectedError: "container build without AppArmor profile",
+		},
+		"unconfined pod AppArmor profile": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{
+					AppArmorProfile: &api.AppArmorProfile{Type: api.AppArmorProfileTypeUnconfined},
+				}
+			},
+			expectedError: "pod without AppArmor profile",
+		},
+		"unconfined AppArmor annotation": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Annotations = map[string]string{"container.apparmor.security.beta.kubernetes.io/build": "unconfined"}
+			},
+			expectedError: "container build with the AppArmor profile unconfined",
+		},
+		"localhost AppArmor annotation": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Annotations = map[string]string{"container.apparmor.security.beta.kubernetes.io/build": "localhost/ci"}
+			},
+		},
+		"unsafe sysctl": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{
+					Sysctls: []api.Sysctl{{Name: "net.ipv4.tcp_syncookies", Value: "1"}, {Name: "kernel.msgmax", Value: "65536"}},
+				}
+			},
+			expectedError: "pod setting the unsafe sysctl kernel.msgmax",
+		},
+		"safe sysctl": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{
+					Sysctls: []api.Sysctl{{Name: "net.ipv4.ip_unprivileged_port_start", Value: "0"}},
+				}
+			},
+		},
+		"SELinux user": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{SELinuxOptions: &api.SELinuxOptions{User: "system_u"}}
+			},
+			expectedError: "pod setting the SELinux user or role",
+		},
+		"SELinux type": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{SELinuxOptions: &api.SELinuxOptions{Type: "spc_t"}}
+			},
+			expectedError: "container build with the SELinux type spc_t",
+		},
+		"SELinux level": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].SecurityContext = &api.SecurityContext{SELinuxOptions: &api.SELinuxOptions{Type: "container_t", Level: "s0:c123,c456"}}
+			},
+		},
+		"Windows host process": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.SecurityContext = &api.PodSecurityContext{WindowsOptions: &api.WindowsSecurityContextOptions{HostProcess: new(true)}}
+			},
+			expectedError: "pod with Windows host processes",
+		},
+		"host port": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].Ports = []api.ContainerPort{{ContainerPort: 8080, HostPort: 80}}
+			},
+			expectedError: "container build with the host port 80",
+		},
+		"allowed host port": {
+			policy: Policy{AllowHostNamespaces: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Containers[0].Ports = []api.ContainerPort{{ContainerPort: 8080, HostPort: 80}}
+			},
+		},
+		"host path volume": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Volumes = []api.Volume{{
+					Name:         "docker-sock",
+					VolumeSource: api.VolumeSource{HostPath: &api.HostPathVolumeSource{Path: "/var/run/docker.sock"}},
+				}}
+			},
+			expectedError: "hostPath volume docker-sock",
+		},
+		"allowed host path volume": {
+			policy: Policy{AllowHostPath: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.Volumes = []api.Volume{{
+					Name:         "docker-sock",
+					VolumeSource: api.VolumeSource{HostPath: &api.HostPathVolumeSource{Path: "/var/run/docker.sock"}},
+				}}
+			},
+		},
+		"host network": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.HostNetwork = true
+			},
+			expectedError: "pod using the namespaces of the host",
+		},
+		"host PID": {
+			policy: Policy{AllowPrivileged: true, AllowHostPath: true, AllowNodeName: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.HostPID = true
+			},
+			expectedError: "pod using the namespaces of the host",
+		},
+		"allowed host namespaces": {
+			policy: Policy{AllowHostNamespaces: true},
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.HostIPC = true
+			},
+		},
+		"node name": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Pod.Spec.NodeName = "control-plane"
+			},
+			expectedError: "pod with the node name control-plane",
+		},
+		"load balancer service": {
+			modify: func(job *RunnerJob) {
+				job.Spec.Services[0].Spec.Type = api.ServiceTypeLoadBalancer
+			},
+			expectedError: "service proxy-db-abc of type LoadBalancer",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			job := newTestRunnerJob()
+			if tt.modify != nil {
+				tt.modify(job)
+			}
+
+			kubeClient := fake.NewClientset()
+			c, jobs := newTestController(t, kubeClient, job, tt.policy)
+
+			require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+
+			reconciled, err := jobs.Get(t.Context(), testNamespace, job.Name)
+			require.NoError(t, err)
+
+			pods, err := kubeClient.CoreV1().Pods(testNamespace).List(t.Context(), metav1.ListOptions{})
+			require.NoError(t, err)
+
+			if tt.expectedError == "" {
+				assert.Equal(t, PhaseCreated, reconciled.Status.Phase)
+				assert.Len(t, pods.Items, 1)
+				return
+			}
+
+			assert.Equal(t, PhaseFailed, reconciled.Status.Phase)
+			assert.Contains(t, reconciled.Status.Message, tt.expectedError)
+			assert.Empty(t, pods.Items, "no resource is created")
+		})
+	}
+}
+
+func TestController_Reconcile_ExistingPod(t *testing.T) {
+	job := newTestRunnerJob()
+
+	kubeClient := fake.NewClientset(&api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: job.Spec.Pod.Name, Namespace: testNamespace},
+		Status:     api.PodStatus{Phase: api.PodRunning},
+	})
+	c, jobs := newTestController(t, kubeClient, job, Policy{})
+
+	require.NoError(t, c.Reconcile(t.Context(), testNamespace, job.Name))
+
+	reconciled, err := jobs.Get(t.Context(), testNamespace, job.Name)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseFailed, reconciled.Status.Phase)
+	assert.Empty(t, reconciled.Status.PodName, "the pod of something else isn't reported")
+	assert.Contains(t, reconciled.Status.Message, "isn't owned by the runner job")
+}
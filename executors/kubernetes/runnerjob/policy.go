@@ -0,0 +1,282 @@
+package runnerjob
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+)
+
+const (
+	// RunnerLabel is the label of the pods with the short ID of the runner
+	// that created their RunnerJob.
+	RunnerLabel = "manager." + Group + "/id-short"
+	// PodLabel is the label of the pods the services of their RunnerJob
+	// select.
+	PodLabel = "job." + Group + "/pod"
+)
+
+// errForbidden is the error of a RunnerJob the policy doesn't allow, which the
+// controller fails without creating any of its resources.
+var errForbidden = errors.New("forbidden by the controller policy")
+
+// The values the baseline Pod Security Standard allows, see
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/#baseline.
+var (
+	baselineSysctls = []string{
+		"kernel.shm_rmid_forced",
+		"net.ipv4.ip_local_port_range",
+		"net.ipv4.ip_local_reserved_ports",
+		"net.ipv4.ip_unprivileged_port_start",
+		"net.ipv4.ping_group_range",
+		"net.ipv4.tcp_fin_timeout",
+		"net.ipv4.tcp_keepalive_intvl",
+		"net.ipv4.tcp_keepalive_probes",
+		"net.ipv4.tcp_keepalive_time",
+		"net.ipv4.tcp_rmem",
+		"net.ipv4.tcp_syncookies",
+		"net.ipv4.tcp_wmem",
+	}
+	baselineSELinuxTypes = []string{"", "container_t", "container_init_t", "container_kvm_t", "container_engine_t"}
+)
+
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+// Policy restricts the resources the controller creates, with its own
+// permissions, from the RunnerJobs. By default, it enforces the baseline Pod
+// Security Standard, and the pods can't add capabilities.
+type Policy struct {
+	// ServiceAccounts are the service accounts the pods can run as, besides
+	// the default service account of the namespace.
+	ServiceAccounts []string
+	// Runners are the short IDs of the runners whose RunnerJobs are allowed,
+	// any runner when empty. The pods need the label of the runner either
+	// way.
+	Runners []string
+	// Capabilities are the Linux capabilities the containers can add.
+	Capabilities []string
+
+	// AllowPrivileged allows privileged containers, and containers allowing
+	// privilege escalation.
+	AllowPrivileged bool
+	// AllowHostPath allows hostPath volumes.
+	AllowHostPath bool
+	// AllowHostNamespaces allows pods using the network, PID or IPC
+	// namespace of their node, and containers with host ports.
+	AllowHostNamespaces bool
+	// AllowNodeName allows pods bypassing the scheduler with a node name.
+	AllowNodeName bool
+}
+
+// validate returns an error wrapping errForbidden if the RunnerJob describes
+// a pod, or services, the policy doesn't allow.
+func (p Policy) validate(job *RunnerJob) error {
+	pod := &job.Spec.Pod
+
+	runner := pod.Labels[RunnerLabel]
+	switch {
+	case runner == "":
+		return fmt.Errorf("%w: pod without the %s label", errForbidden, RunnerLabel)
+	case len(p.Runners) > 0 && !slices.Contains(p.Runners, runner):
+		return fmt.Errorf("%w: runner %q isn't allowed", errForbidden, runner)
+	}
+
+	if pod.Labels[PodLabel] == "" {
+		return fmt.Errorf("%w: pod without the %s label", errForbidden, PodLabel)
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount != "" && serviceAccount != "default" && !slices.Contains(p.ServiceAccounts, serviceAccount) {
+		return fmt.Errorf("%w: service account %q isn't allowed", errForbidden, serviceAccount)
+	}
+
+	if err := validatePodAnnotations(pod.Annotations); err != nil {
+		return err
+	}
+
+	if err := p.validatePodSpec(&pod.Spec); err != nil {
+		return err
+	}
+
+	for _, service := range job.Spec.Services {
+		if err := validateService(&service, pod.Labels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePodAnnotations returns an error wrapping errForbidden if the
+// annotations of the pod run a container without AppArmor profile.
+func validatePodAnnotations(annotations map[string]string) error {
+	for key, value := range annotations {
+		container, ok := strings.CutPrefix(key, appArmorAnnotationPrefix)
+		if !ok || value == "runtime/default" || strings.HasPrefix(value, "localhost/") {
+			continue
+		}
+
+		return fmt.Errorf("%w: container %s with the AppArmor profile %s", errForbidden, container, value)
+	}
+
+	return nil
+}
+
+// validatePodSpec returns an error wrapping errForbidden if the pod uses the
+// host, or containers with privileges, the policy doesn't allow.
+func (p Policy) validatePodSpec(spec *api.PodSpec) error {
+	switch {
+	case spec.NodeName != "" && !p.AllowNodeName:
+		return fmt.Errorf("%w: pod with the node name %s", errForbidden, spec.NodeName)
+	case (spec.HostNetwork || spec.HostPID || spec.HostIPC) && !p.AllowHostNamespaces:
+		return fmt.Errorf("%w: pod using the namespaces of the host", errForbidden)
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil && !p.AllowHostPath {
+			return fmt.Errorf("%w: hostPath volume %s", errForbidden, volume.Name)
+		}
+	}
+
+	if err := validatePodSecurityContext(spec.SecurityContext); err != nil {
+		return err
+	}
+
+	type container struct {
+		name  string
+		sc    *api.SecurityContext
+		ports []api.ContainerPort
+	}
+
+	var containers []container
+	for _, c := range slices.Concat(spec.InitContainers, spec.Containers) {
+		containers = append(containers, container{name: c.Name, sc: c.SecurityContext, ports: c.Ports})
+	}
+	for _, c := range spec.EphemeralContainers {
+		containers = append(containers, container{name: c.Name, sc: c.SecurityContext, ports: c.Ports})
+	}
+
+	for _, c := range containers {
+		for _, port := range c.ports {
+			if port.HostPort != 0 && !p.AllowHostNamespaces {
+				return fmt.Errorf("%w: container %s with the host port %d", errForbidden, c.name, port.HostPort)
+			}
+		}
+
+		if err := p.validateSecurityContext(c.name, c.sc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePodSecurityContext returns an error wrapping errForbidden if the
+// security context of the pod doesn't satisfy the baseline Pod Security
+// Standard.
+func validatePodSecurityContext(sc *api.PodSecurityContext) error {
+	if sc == nil {
+		return nil
+	}
+
+	if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+		return fmt.Errorf("%w: pod with Windows host processes", errForbidden)
+	}
+	if sc.SeccompProfile != nil && sc.SeccompProfile.Type == api.SeccompProfileTypeUnconfined {
+		return fmt.Errorf("%w: pod without seccomp profile", errForbidden)
+	}
+	if sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == api.AppArmorProfileTypeUnconfined {
+		return fmt.Errorf("%w: pod without AppArmor profile", errForbidden)
+	}
+	if err := validateSELinuxOptions(sc.SELinuxOptions); err != nil {
+		return fmt.Errorf("%w: pod %w", errForbidden, err)
+	}
+
+	for _, sysctl := range sc.Sysctls {
+		if !slices.Contains(baselineSysctls, sysctl.Name) {
+			return fmt.Errorf("%w: pod setting the unsafe sysctl %s", errForbidden, sysctl.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateSecurityContext returns an error wrapping errForbidden if the
+// security context of the container has privileges the policy doesn't allow,
+// or doesn't satisfy the baseline Pod Security Standard.
+func (p Policy) validateSecurityContext(name string, sc *api.SecurityContext) error {
+	if sc == nil {
+		return nil
+	}
+
+	switch {
+	case sc.Privileged != nil && *sc.Privileged && !p.AllowPrivileged:
+		return fmt.Errorf("%w: privileged container %s", errForbidden, name)
+	case sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation && !p.AllowPrivileged:
+		return fmt.Errorf("%w: container %s allowing privilege escalation", errForbidden, name)
+	case sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess:
+		return fmt.Errorf("%w: container %s running as a Windows host process", errForbidden, name)
+	case sc.ProcMount != nil && *sc.ProcMount != api.DefaultProcMount:
+		return fmt.Errorf("%w: container %s with the %s /proc mount", errForbidden, name, *sc.ProcMount)
+	case sc.SeccompProfile != nil && sc.SeccompProfile.Type == api.SeccompProfileTypeUnconfined:
+		return fmt.Errorf("%w: container %s without seccomp profile", errForbidden, name)
+	case sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == api.AppArmorProfileTypeUnconfined:
+		return fmt.Errorf("%w: container %s without AppArmor profile", errForbidden, name)
+	}
+
+	if err := validateSELinuxOptions(sc.SELinuxOptions); err != nil {
+		return fmt.Errorf("%w: container %s %w", errForbidden, name, err)
+	}
+
+	if sc.Capabilities == nil {
+		return nil
+	}
+	for _, capability := range sc.Capabilities.Add {
+		if !slices.Contains(p.Capabilities, string(capability)) {
+			return fmt.Errorf("%w: container %s adding the %s capability", errForbidden, name, capability)
+		}
+	}
+
+	return nil
+}
+
+// validateSELinuxOptions returns an error if the options set a SELinux user or
+// role, or a type the baseline Pod Security Standard doesn't allow.
+func validateSELinuxOptions(options *api.SELinuxOptions) error {
+	switch {
+	case options == nil:
+		return nil
+	case options.User != "" || options.Role != "":
+		return errors.New("setting the SELinux user or role")
+	case !slices.Contains(baselineSELinuxTypes, options.Type):
+		return fmt.Errorf("with the SELinux type %s", options.Type)
+	}
+
+	return nil
+}
+
+// validateService returns an error wrapping errForbidden if the service
+// isn't a cluster IP service selecting the pod of the RunnerJob.
+func validateService(service *api.Service, podLabels map[string]string) error {
+	if service.Spec.Type != "" && service.Spec.Type != api.ServiceTypeClusterIP {
+		return fmt.Errorf("%w: service %s of type %s", errForbidden, service.Name, service.Spec.Type)
+	}
+	if len(service.Spec.ExternalIPs) > 0 {
+		return fmt.Errorf("%w: service %s with external IPs", errForbidden, service.Name)
+	}
+
+	selector := service.Spec.Selector
+	if selector[PodLabel] != podLabels[PodLabel] {
+		return fmt.Errorf("%w: service %s doesn't select the pod of the runner job", errForbidden, service.Name)
+	}
+
+	for key, value := range selector {
+		if podLabels[key] != value {
+			return fmt.Errorf("%w: service %s doesn't select the pod of the runner job", errForbidden, service.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package runnerjob defines the RunnerJob custom resource, which describes the
+// pod and services of a job of the kubernetes executor, and the
+// controller creating them. With it, the runner manager only needs access to
+// the RunnerJobs, and to the pods it attaches to, instead of creating the
+// resources of the jobs itself.
+package runnerjob
+
+import (
+	_ "embed"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	Group    = "runner.gitlab.com"
+	Version  = "v1alpha1"
+	Kind     = "RunnerJob"
+	Resource = "runnerjobs"
+)
+
+// GroupVersionResource is the resource of the RunnerJobs.
+var GroupVersionResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: Resource}
+
+// CustomResourceDefinition is the manifest of the RunnerJob
+// CustomResourceDefinition, which is installed in the cluster once.
+//
+//go:embed crd.yaml
+var CustomResourceDefinition string
+
+// Phase is the phase of the resources of a RunnerJob.
+type Phase string
+
+const (
+	// PhasePending is the phase of a RunnerJob until its pod is created.
+	PhasePending Phase = "Pending"
+	// PhaseCreated is the phase of a RunnerJob once its pod is created.
+	PhaseCreated Phase = "Created"
+	// PhaseFailed is the phase of a RunnerJob whose resources can't be
+	// created, like when the pod is invalid.
+	PhaseFailed Phase = "Failed"
+)
+
+// RunnerJob describes the resources of a job, which the controller creates in
+// the namespace of the RunnerJob, owned by it, and deleted with it.
+type RunnerJob struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   Spec   `json:"spec"`
+	Status Status `json:"status,omitempty"`
+}
+
+// Spec is the pod of the job, and the services it uses. The secrets of the
+// pod, like its image pull secrets, are only referenced by it.
+type Spec struct {
+	// Pod is created with the name of its metadata, or else with the name of
+	// the RunnerJob.
+	Pod      api.PodTemplateSpec `json:"pod"`
+	Services []api.Service       `json:"services,omitempty"`
+}
+
+// Status is the status of the resources of a RunnerJob, reported by the
+// controller.
+type Status struct {
+	Phase    Phase        `json:"phase,omitempty"`
+	PodName  string       `json:"podName,omitempty"`
+	PodPhase api.PodPhase `json:"podPhase,omitempty"`
+	// Message is the error creating the resources, if any.
+	Message string `json:"message,omitempty"`
+}
+
+// ownerReference returns the reference to the RunnerJob the resources it
+// describes are created with, for them to be deleted with it.
+func (j *RunnerJob) ownerReference() metav1.OwnerReference {
+	controller := true
+	blockOwnerDeletion := true
+
+	return metav1.OwnerReference{
+		APIVersion:         Group + "/" + Version,
+		Kind:               Kind,
+		Name:               j.Name,
+		UID:                j.UID,
+		Controller:         &controller,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+}
+
+// DeepCopy returns a deep copy of the RunnerJob.
+func (j *RunnerJob) DeepCopy() *RunnerJob {
+	out := &RunnerJob{
+		TypeMeta: j.TypeMeta,
+		Spec: Spec{
+			Pod: *j.Spec.Pod.DeepCopy(),
+		},
+		Status: j.Status,
+	}
+	j.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	for _, service := range j.Spec.Services {
+		out.Spec.Services = append(out.Spec.Services, *service.DeepCopy())
+	}
+
+	return out
+}
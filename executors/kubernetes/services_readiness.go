@@ -0,0 +1,224 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
+)
+
+// serviceProbePeriodSeconds is how often the kubelet probes the readiness of
+// the services.
+const serviceProbePeriodSeconds = 2
+
+// serviceReadinessProbe returns the readiness probe the readiness of the
+// service defines, or nil when it only has its TCP health check port, which
+// waitForServices checks from the helper container.
+func (s *executor) serviceReadinessProbe(name string, service *spec.Image) *api.Probe {
+	readiness := services.ReadinessFor(service.Variables.Get)
+
+	var handler api.ProbeHandler
+	switch {
+	case readiness.Command != "":
+		handler.Exec = &api.ExecAction{Command: []string{"sh", "-c", readiness.Command}}
+	case readiness.HTTPPath != "":
+		port := serviceHealthCheckPort(service)
+		if port == 0 {
+			s.BuildLogger.Warningln(fmt.Sprintf(
+				"Service %q has no port for its HTTP health check, set HEALTHCHECK_TCP_PORT or its ports", name,
+			))
+			return nil
+		}
+		handler.HTTPGet = &api.HTTPGetAction{Path: readiness.HTTPPath, Port: intstr.FromInt32(port)}
+	case readiness.UseImage:
+		s.BuildLogger.Warningln(fmt.Sprintf(
+			"Service %q: the HEALTHCHECK of images isn't supported by the kubernetes executor, "+
+				"use HEALTHCHECK_COMMAND or HEALTHCHECK_HTTP_PATH instead", name,
+		))
+		return nil
+	default:
+		return nil
+	}
+
+	return &api.Probe{
+		ProbeHandler:  handler,
+		PeriodSeconds: serviceProbePeriodSeconds,
+	}
+}
+
+// serviceHealthCheckPort returns the HEALTHCHECK_TCP_PORT of the service, or
+// else its first port, or 0 when it has neither.
+func serviceHealthCheckPort(service *spec.Image) int32 {
+	if port, err := strconv.ParseInt(service.Variables.Get("HEALTHCHECK_TCP_PORT"), 10, 32); err == nil {
+		return int32(port)
+	}
+
+	if len(service.Ports) > 0 {
+		return int32(service.Ports[0].Number)
+	}
+
+	return 0
+}
+
+// orderPodServices returns the service containers the pod runs with the build,
+// and, in the order of their dependencies, the services that depend on others,
+// or that others depend on. Those are run as sidecars, restartable init
+// containers, which the kubelet starts one after the other, once the startup
+// probe of the previous one succeeds. A service others depend on must have a
+// startup probe, or the kubelet would start them right away.
+func (s *executor) orderPodServices(containers []api.Container) ([]api.Container, []api.Container, error) {
+	names := s.options.getSortedServiceNames()
+
+	dependencies := make([]services.Dependencies, len(names))
+	for i, name := range names {
+		service := s.options.Services[name]
+		dependencies[i] = services.DependenciesFor(service.Name, service.Aliases(), service.Variables.Get)
+	}
+
+	stages, err := services.Stages(dependencies)
+	if err != nil {
+		return nil, nil, &common.BuildError{Inner: err, FailureReason: common.ConfigurationError}
+	}
+
+	required := services.Required(dependencies)
+
+	var podServices, sidecars []api.Container
+	for _, stage := range stages {
+		for _, i := range stage {
+			if !required[i] && len(dependencies[i].DependsOn) == 0 {
+				continue
+			}
+
+			sidecar := s.serviceSidecar(containers[i], s.options.Services[names[i]])
+			if required[i] && sidecar.StartupProbe == nil {
+				return nil, nil, &common.BuildError{
+					Inner: fmt.Errorf(
+						"service %q has other services depending on it, but no readiness check they can wait for: "+
+							"set its HEALTHCHECK_COMMAND, HEALTHCHECK_HTTP_PATH or HEALTHCHECK_TCP_PORT variable, or its ports",
+						sidecar.Name,
+					),
+					FailureReason: common.ConfigurationError,
+				}
+			}
+
+			sidecars = append(sidecars, sidecar)
+		}
+	}
+
+	for i, c := range containers {
+		if required[i] || len(dependencies[i].DependsOn) > 0 {
+			continue
+		}
+
+		podServices = append(podServices, c)
+	}
+
+	return podServices, sidecars, nil
+}
+
+// serviceSidecar turns the service container into a sidecar, which is only
+// started once it's ready: its readiness probe succeeds, or else its health
+// check port accepts connections.
+func (s *executor) serviceSidecar(c api.Container, service *spec.Image) api.Container {
+	restartPolicy := api.ContainerRestartPolicyAlways
+	c.RestartPolicy = &restartPolicy
+
+	probe := c.ReadinessProbe
+	if probe == nil {
+		port := serviceHealthCheckPort(service)
+		if port == 0 {
+			return c
+		}
+
+		probe = &api.Probe{
+			ProbeHandler:  api.ProbeHandler{TCPSocket: &api.TCPSocketAction{Port: intstr.FromInt32(port)}},
+			PeriodSeconds: serviceProbePeriodSeconds,
+		}
+	}
+
+	// The kubelet restarts the sidecar once its startup probe fails this many
+	// times, by when the pod is about to time out anyway.
+	c.StartupProbe = probe.DeepCopy()
+	c.StartupProbe.FailureThreshold = int32(max(1, s.Config.Kubernetes.GetPollTimeout()/serviceProbePeriodSeconds))
+
+	return c
+}
+
+// hasServiceReadinessProbes returns whether any of the services has a
+// readiness probe.
+func (s *executor) hasServiceReadinessProbes() bool {
+	for _, service := range s.options.Services {
+		readiness := services.ReadinessFor(service.Variables.Get)
+		if readiness.Command != "" || readiness.HTTPPath != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitForServicesReady waits for the service containers with a readiness
+// probe to be ready. A service that isn't is only reported, like with the
+// docker executor, since the services other services depend on are sidecars
+// the build only starts after.
+func (s *executor) waitForServicesReady(ctx context.Context) {
+	var probed []string
+	for _, c := range s.pod.Spec.Containers {
+		if c.ReadinessProbe != nil && !isNotServiceContainerName(c.Name) {
+			probed = append(probed, c.Name)
+		}
+	}
+
+	if len(probed) == 0 {
+		return
+	}
+
+	timeout := time.Duration(s.Config.Kubernetes.GetPollTimeout()) * time.Second
+	s.BuildLogger.Println("Waiting for services to be ready (timeout", timeout, ")...")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interval := time.Duration(s.Config.Kubernetes.GetPollInterval()) * time.Second
+	for {
+		// kubeAPI: pods, get
+		pod, err := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+		if err == nil {
+			probed = slices.DeleteFunc(probed, func(name string) bool {
+				return isContainerReady(pod, name)
+			})
+		}
+
+		if len(probed) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, name := range probed {
+				s.BuildLogger.Warningln(fmt.Sprintf("Service %s probably didn't start properly: it isn't ready", name))
+			}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func isContainerReady(pod *api.Pod, name string) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == name {
+			return status.Ready
+		}
+	}
+
+	return false
+}
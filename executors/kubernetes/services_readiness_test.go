@@ -0,0 +1,222 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/buildlogger"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/pull"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/container/services"
+	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
+)
+
+func TestServiceReadinessProbe(t *testing.T) {
+	tests := map[string]struct {
+		service  spec.Image
+		expected *api.Probe
+	}{
+		"TCP port": {
+			service: spec.Image{Variables: spec.Variables{{Key: "HEALTHCHECK_TCP_PORT", Value: "5432"}}},
+		},
+		"command": {
+			service: spec.Image{Variables: spec.Variables{{Key: services.HealthcheckCommandVariable, Value: "pg_isready"}}},
+			expected: &api.Probe{
+				ProbeHandler:  api.ProbeHandler{Exec: &api.ExecAction{Command: []string{"sh", "-c", "pg_isready"}}},
+				PeriodSeconds: serviceProbePeriodSeconds,
+			},
+		},
+		"HTTP path on the health check port": {
+			service: spec.Image{
+				Ports: []spec.Port{{Number: 9000}},
+				Variables: spec.Variables{
+					{Key: services.HealthcheckHTTPPathVariable, Value: "/healthz"},
+					{Key: "HEALTHCHECK_TCP_PORT", Value: "8080"},
+				},
+			},
+			expected: &api.Probe{
+				ProbeHandler:  api.ProbeHandler{HTTPGet: &api.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt32(8080)}},
+				PeriodSeconds: serviceProbePeriodSeconds,
+			},
+		},
+		"HTTP path on the first port": {
+			service: spec.Image{
+				Ports:     []spec.Port{{Number: 9000}, {Number: 9001}},
+				Variables: spec.Variables{{Key: services.HealthcheckHTTPPathVariable, Value: "/minio/health/ready"}},
+			},
+			expected: &api.Probe{
+				ProbeHandler:  api.ProbeHandler{HTTPGet: &api.HTTPGetAction{Path: "/minio/health/ready", Port: intstr.FromInt32(9000)}},
+				PeriodSeconds: serviceProbePeriodSeconds,
+			},
+		},
+		"HTTP path without port": {
+			service: spec.Image{Variables: spec.Variables{{Key: services.HealthcheckHTTPPathVariable, Value: "/healthz"}}},
+		},
+		"image HEALTHCHECK": {
+			service: spec.Image{Variables: spec.Variables{{Key: services.HealthcheckUseImageVariable, Value: "true"}}},
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			e := newTestExecutorWithKubeClient(t, nil)
+
+			assert.Equal(t, tc.expected, e.serviceReadinessProbe("svc-0", &tc.service))
+		})
+	}
+}
+
+func newExecutorWithServices(t *testing.T, images ...spec.Image) *executor {
+	e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{Image: "test-image", PollTimeout: 60})
+	e.options = &kubernetesOptions{Services: map[string]*spec.Image{}}
+	e.ProxyPool = proxy.NewPool()
+
+	mockPM := pull.NewMockManager(t)
+	usedAliases := map[string]struct{}{}
+	idx := 0
+	for i := range images {
+		var name string
+		idx, name = e.getServiceDefinition(&images[i], usedAliases, idx)
+		e.options.Services[name] = &images[i]
+		mockPM.On("GetPullPolicyFor", name).Return(api.PullAlways, nil).Maybe()
+	}
+	e.pullManager = mockPM
+
+	return e
+}
+
+func TestOrderPodServices(t *testing.T) {
+	e := newExecutorWithServices(t,
+		spec.Image{
+			Name:      "registry.example.com/app:1.0",
+			Alias:     "app",
+			Ports:     []spec.Port{{Number: 8080}},
+			Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "db"}},
+		},
+		spec.Image{
+			Name:      "postgres:16",
+			Alias:     "db",
+			Variables: spec.Variables{{Key: services.HealthcheckCommandVariable, Value: "pg_isready"}},
+		},
+		spec.Image{Name: "redis:7", Alias: "cache"},
+		spec.Image{
+			Name:      "migrations:1.0",
+			Alias:     "aaa-migrations",
+			Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "postgres"}},
+		},
+	)
+
+	containers, err := e.preparePodServices()
+	require.NoError(t, err)
+
+	podServices, sidecars, err := e.orderPodServices(containers)
+	require.NoError(t, err)
+
+	require.Len(t, podServices, 1)
+	assert.Equal(t, "cache", podServices[0].Name)
+	assert.Nil(t, podServices[0].RestartPolicy)
+
+	require.Len(t, sidecars, 3)
+	assert.Equal(t, "db", sidecars[0].Name)
+	assert.Equal(t, "aaa-migrations", sidecars[1].Name)
+	assert.Equal(t, "app", sidecars[2].Name)
+
+	for _, sidecar := range sidecars {
+		require.NotNil(t, sidecar.RestartPolicy)
+		assert.Equal(t, api.ContainerRestartPolicyAlways, *sidecar.RestartPolicy)
+	}
+
+	assert.Equal(t, &api.Probe{
+		ProbeHandler:     api.ProbeHandler{Exec: &api.ExecAction{Command: []string{"sh", "-c", "pg_isready"}}},
+		PeriodSeconds:    serviceProbePeriodSeconds,
+		FailureThreshold: 30,
+	}, sidecars[0].StartupProbe)
+	assert.Nil(t, sidecars[1].StartupProbe, "a service without readiness nor port is started right away")
+	assert.Equal(t, &api.Probe{
+		ProbeHandler:     api.ProbeHandler{TCPSocket: &api.TCPSocketAction{Port: intstr.FromInt32(8080)}},
+		PeriodSeconds:    serviceProbePeriodSeconds,
+		FailureThreshold: 30,
+	}, sidecars[2].StartupProbe)
+}
+
+func TestOrderPodServices_UnknownDependency(t *testing.T) {
+	e := newExecutorWithServices(t, spec.Image{
+		Name:      "registry.example.com/app:1.0",
+		Alias:     "app",
+		Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "db"}},
+	})
+
+	containers, err := e.preparePodServices()
+	require.NoError(t, err)
+
+	_, _, err = e.orderPodServices(containers)
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+}
+
+func TestOrderPodServices_DependencyWithoutReadiness(t *testing.T) {
+	e := newExecutorWithServices(t,
+		spec.Image{
+			Name:      "registry.example.com/app:1.0",
+			Alias:     "app",
+			Ports:     []spec.Port{{Number: 8080}},
+			Variables: spec.Variables{{Key: services.DependsOnVariable, Value: "db"}},
+		},
+		spec.Image{Name: "postgres:16", Alias: "db"},
+	)
+
+	containers, err := e.preparePodServices()
+	require.NoError(t, err)
+
+	_, _, err = e.orderPodServices(containers)
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, common.ConfigurationError, buildErr.FailureReason)
+	assert.ErrorContains(t, err, `service "db" has other services depending on it, but no readiness check`)
+}
+
+func TestWaitForServicesReady(t *testing.T) {
+	pod := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-pod", Namespace: "test-ns"},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{Name: buildContainerName},
+				{Name: "db", ReadinessProbe: &api.Probe{}},
+				{Name: "cache"},
+				{Name: "minio", ReadinessProbe: &api.Probe{}},
+			},
+		},
+		Status: api.PodStatus{
+			ContainerStatuses: []api.ContainerStatus{
+				{Name: "db", Ready: true},
+				{Name: "minio", Ready: false},
+			},
+		},
+	}
+
+	e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{PollTimeout: 1, PollInterval: 1})
+	e.kubeClient = testclient.NewClientset(pod)
+	e.pod = pod
+
+	var logs bytes.Buffer
+	e.BuildLogger = buildlogger.New(&common.Trace{Writer: &logs}, logrus.NewEntry(logrus.New()), buildlogger.Options{})
+
+	e.waitForServicesReady(t.Context())
+
+	assert.Contains(t, logs.String(), "Service minio probably didn't start properly: it isn't ready")
+	assert.NotContains(t, logs.String(), "Service db")
+}
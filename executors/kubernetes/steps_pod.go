@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"slices"
 	"strings"
 
 	api "k8s.io/api/core/v1"
@@ -94,7 +95,7 @@ func (s *executor) ensureStepsPod(ctx context.Context) error {
 	// above still covers the early-return error paths.
 	out.Close()
 
-	s.captureServiceContainersLogs(ctx, s.pod.Spec.Containers)
+	s.captureServiceContainersLogs(ctx, slices.Concat(s.pod.Spec.InitContainers, s.pod.Spec.Containers))
 
 	return nil
 }
@@ -127,7 +128,7 @@ func (s *executor) buildStepsBootstrapInitContainer() (api.Container, error) {
 
 	return api.Container{
 		Name:            stepsBootstrapInitContainerName,
-		Image:           s.getHelperImage(),
+		Image:           s.registryMirrors.ImageFor(stepsBootstrapInitContainerName, s.getHelperImage()),
 		ImagePullPolicy: pullPolicy,
 		// Image is the helper image, which has gitlab-runner-helper on
 		// $PATH, so the bare basename suffices here. The build container
@@ -162,6 +163,11 @@ func (s *executor) setupStepsPod(ctx context.Context, initContainers []api.Conta
 		return err
 	}
 
+	services, sidecars, err := s.orderPodServices(services)
+	if err != nil {
+		return err
+	}
+
 	hostAliases, err := s.getHostAliases()
 	if err != nil {
 		return err
@@ -171,7 +177,7 @@ func (s *executor) setupStepsPod(ctx context.Context, initContainers []api.Conta
 		labels:           s.buildLabels(),
 		annotations:      s.buildPodAnnotations(),
 		services:         services,
-		initContainers:   initContainers,
+		initContainers:   slices.Concat(initContainers, sidecars),
 		imagePullSecrets: s.prepareImagePullSecrets(),
 		hostAliases:      hostAliases,
 	}
@@ -181,6 +187,8 @@ func (s *executor) setupStepsPod(ctx context.Context, initContainers []api.Conta
 		return err
 	}
 
+	s.setPodAdmission(&podConfig)
+
 	s.BuildLogger.Debugln("Checking for ImagePullSecrets or ServiceAccount existence")
 	if err := s.checkDependantResources(ctx); err != nil {
 		return err
@@ -198,6 +206,10 @@ func (s *executor) setupStepsPod(ctx context.Context, initContainers []api.Conta
 
 	s.podWatcher.UpdatePodName(podConfig.GetName())
 
+	if s.usesRunnerJob() {
+		return s.setupRunnerJob(ctx, &podConfig)
+	}
+
 	s.BuildLogger.Debugln("Creating steps pod")
 
 	s.pod, err = retry.WithValueFn(s, func() (*api.Pod, error) {
@@ -304,6 +316,12 @@ func (s *executor) stepsBuildContainer() (api.Container, error) {
 		return api.Container{}, err
 	}
 
+	image, err := s.verifyImage(s.options.Image.Name)
+	if err != nil {
+		return api.Container{}, err
+	}
+	image = s.registryMirrors.ImageFor(buildContainerName, image)
+
 	pullPolicy, err := s.pullManager.GetPullPolicyFor(buildContainerName)
 	if err != nil {
 		return api.Container{}, err
@@ -339,7 +357,7 @@ func (s *executor) stepsBuildContainer() (api.Container, error) {
 
 	return api.Container{
 		Name:            buildContainerName,
-		Image:           s.options.Image.Name,
+		Image:           image,
 		ImagePullPolicy: pullPolicy,
 		Command:         command,
 		Env:             nil,
@@ -377,6 +395,7 @@ func (s *executor) stepsServiceContainers() ([]api.Container, error) {
 		if err != nil {
 			return nil, err
 		}
+		c.ReadinessProbe = s.serviceReadinessProbe(name, service)
 		services[i] = c
 	}
 
@@ -400,6 +419,12 @@ func (s *executor) stepsServiceContainer(
 		return api.Container{}, err
 	}
 
+	image, err := s.verifyImage(service.Name)
+	if err != nil {
+		return api.Container{}, err
+	}
+	image = s.registryMirrors.ImageFor(name, image)
+
 	containerPorts := make([]api.ContainerPort, len(service.Ports))
 	proxyPorts := make([]proxy.Port, len(service.Ports))
 	for i, port := range service.Ports {
@@ -426,7 +451,7 @@ func (s *executor) stepsServiceContainer(
 
 	return api.Container{
 		Name:            name,
-		Image:           service.Name,
+		Image:           image,
 		ImagePullPolicy: pullPolicy,
 		Command:         command,
 		Args:            args,
@@ -495,7 +520,7 @@ func (s *executor) stepsWaitForServices(ctx context.Context) error {
 		}
 		fmt.Fprintf(&portArgs, "--port '%s' ", port)
 	}
-	if portArgs.Len() == 0 {
+	if portArgs.Len() == 0 && !s.hasServiceReadinessProbes() {
 		return nil
 	}
 
@@ -503,6 +528,14 @@ func (s *executor) stepsWaitForServices(ctx context.Context) error {
 		return err
 	}
 
+	defer s.startServiceWaitEvent()()
+
+	// services with a readiness probe are only ready once it succeeds
+	if portArgs.Len() == 0 {
+		s.waitForServicesReady(ctx)
+		return nil
+	}
+
 	command := s.stepsRunnerBinaryPath() + " health-check " + portArgs.String()
 
 	podStatusCh := s.watchPodStatus(ctx, &podContainerStatusChecker{})
@@ -525,6 +558,8 @@ func (s *executor) stepsWaitForServices(ctx context.Context) error {
 		return fmt.Errorf("health check aborted")
 	}
 
+	s.waitForServicesReady(ctx)
+
 	return nil
 }
 
@@ -22,6 +22,7 @@ import (
 	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/pull"
 	"gitlab.com/gitlab-org/gitlab-runner/executors/kubernetes/internal/watchers"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/featureflags"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/registry_mirrors"
 	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
 	"gitlab.com/gitlab-org/step-runner/schema/v1"
 )
@@ -205,6 +206,47 @@ func TestStepsBootstrapPullPolicy_CyclesAcrossRetries(t *testing.T) {
 		stepsBootstrapInitContainerName)
 }
 
+func TestStepsBootstrapRegistryMirrors_FailOverAcrossRetries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("UseNativeSteps returns false on Windows by design")
+	}
+
+	ex := newStepsTestExecutor(t)
+	ex.Build.ExecutorFeatures.NativeStepsIntegration = true
+	ex.Build.Job.Run = spec.Run{schema.Step{Name: new("step1")}}
+	ex.Config.Kubernetes.HelperImage = "registry.example.com/gitlab-runner-helper:v18"
+	ex.registryMirrors = pull.NewMirrors(
+		map[string][]string{"registry.example.com": {"mirror.example.com"}},
+		&ex.BuildLogger,
+	)
+
+	pm, err := ex.preparePullManager()
+	require.NoError(t, err)
+	ex.pullManager = pm
+
+	var seen []string
+	retryErr := ex.withPullRetry(t.Context(), func() error {
+		c, buildErr := ex.buildStepsBootstrapInitContainer()
+		require.NoError(t, buildErr)
+		seen = append(seen, c.Image)
+		return &pull.ImagePullError{
+			Container: stepsBootstrapInitContainerName,
+			Image:     c.Image,
+			Message:   "simulated pull failure",
+		}
+	})
+
+	assert.Equal(t,
+		[]string{"mirror.example.com/gitlab-runner-helper:v18", "registry.example.com/gitlab-runner-helper:v18"},
+		seen,
+	)
+
+	var buildErr *common.BuildError
+	require.ErrorAs(t, retryErr, &buildErr)
+	assert.Equal(t, common.RunnerExternalDependencyFailure, buildErr.FailureReason)
+	assert.ErrorAs(t, retryErr, new(*registry_mirrors.AllFailedError))
+}
+
 // In Concrete mode the build container's Command must be the
 // bootstrapped `steps serve` invocation, Env must be nil (step-runner
 // injects env vars over the protocol), and Stdin must be true so the
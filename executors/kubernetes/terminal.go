@@ -1,23 +1,48 @@
 package kubernetes
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"gitlab.com/gitlab-org/gitlab-runner/session/proxy"
 	terminalsession "gitlab.com/gitlab-org/gitlab-runner/session/terminal"
 	terminal "gitlab.com/gitlab-org/gitlab-terminal"
 	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
+const debugContainerPrefix = "debug-"
+
+// debugShellCommand runs bash if the image has it, and sh otherwise, without
+// printing anything. The shell replaces sh, so exiting it ends the session
+// instead of falling back to sh.
+const debugShellCommand = "command -v bash >/dev/null 2>&1 && exec bash || exec sh"
+
 func (s *executor) TerminalConnect() (terminalsession.Conn, error) {
 	settings, err := s.getTerminalSettings()
 	if err != nil {
 		return nil, err
 	}
 
+	if s.Config.Kubernetes.TerminalDebugImage != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Config.Kubernetes.GetPollTimeout())*time.Second)
+		defer cancel()
+
+		containerName, err := s.startDebugContainer(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("starting debug container: %w", err)
+		}
+
+		settings.Url = s.getDebugTerminalWebSocketURL(containerName).String()
+	}
+
 	return terminalConn{settings: settings}, nil
 }
 
@@ -89,3 +114,140 @@ func (s *executor) getTerminalWebSocketURL() *url.URL {
 	wsURL.Scheme = proxy.WebsocketProtocolFor(wsURL.Scheme)
 	return wsURL
 }
+
+// getDebugTerminalWebSocketURL returns the URL attaching to the shell the
+// debug container runs.
+func (s *executor) getDebugTerminalWebSocketURL(containerName string) *url.URL {
+	// kubeAPI: pods/attach, create, kubernetes.terminal_debug_image
+	wsURL := s.kubeClient.CoreV1().RESTClient().Post().
+		Namespace(s.pod.Namespace).
+		Resource("pods").
+		Name(s.pod.Name).
+		SubResource("attach").
+		VersionedParams(&api.PodAttachOptions{
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+			TTY:       true,
+			Container: containerName,
+		}, scheme.ParameterCodec).URL()
+
+	wsURL.Scheme = proxy.WebsocketProtocolFor(wsURL.Scheme)
+	return wsURL
+}
+
+// startDebugContainer adds an ephemeral container running a shell of the
+// debug image to the job pod, sharing the process namespace and the volumes of
+// the build container, and waits for it to run. The debug container runs with
+// the security context of the build container, so it doesn't get more
+// privileges than the job has. A debug container still running, from a
+// previous terminal session, is used again, as ephemeral containers can't be
+// removed from the pod.
+func (s *executor) startDebugContainer(ctx context.Context) (string, error) {
+	// kubeAPI: pods, get
+	pod, err := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if strings.HasPrefix(status.Name, debugContainerPrefix) && status.State.Running != nil {
+			return status.Name, nil
+		}
+	}
+
+	idx := slices.IndexFunc(pod.Spec.Containers, func(c api.Container) bool {
+		return c.Name == buildContainerName
+	})
+	if idx < 0 {
+		// Without the build container, there is no security context to run
+		// the debug container with, which could then run as root.
+		return "", fmt.Errorf("pod %s has no %s container to take the security context from", pod.Name, buildContainerName)
+	}
+	build := pod.Spec.Containers[idx]
+
+	container := api.EphemeralContainer{
+		EphemeralContainerCommon: api.EphemeralContainerCommon{
+			Name:            fmt.Sprintf("%s%d", debugContainerPrefix, len(pod.Spec.EphemeralContainers)),
+			Image:           s.Config.Kubernetes.TerminalDebugImage,
+			ImagePullPolicy: api.PullIfNotPresent,
+			Command:         []string{"sh", "-c", debugShellCommand},
+			VolumeMounts:    debugVolumeMounts(build.VolumeMounts),
+			SecurityContext: s.debugSecurityContext(build),
+			Stdin:           true,
+			TTY:             true,
+		},
+		TargetContainerName: buildContainerName,
+	}
+
+	s.BuildLogger.Debugln("Starting debug container", container.Name, "with image", container.Image)
+
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, container)
+
+	// kubeAPI: pods/ephemeralcontainers, update, kubernetes.terminal_debug_image
+	_, err = s.kubeClient.CoreV1().Pods(pod.Namespace).UpdateEphemeralContainers(ctx, pod.Name, pod, metav1.UpdateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return container.Name, s.waitForDebugContainer(ctx, container.Name)
+}
+
+// debugSecurityContext returns the security context of the debug container: the
+// one of the build container, or the configured build_container_security_context
+// when the build container has none.
+func (s *executor) debugSecurityContext(build api.Container) *api.SecurityContext {
+	if build.SecurityContext != nil {
+		return build.SecurityContext.DeepCopy()
+	}
+
+	return s.Config.Kubernetes.GetContainerSecurityContext(
+		s.Config.Kubernetes.BuildContainerSecurityContext,
+		s.defaultCapDrop()...,
+	)
+}
+
+// debugVolumeMounts returns the volume mounts of the build container the
+// debug container can use. The API rejects ephemeral containers with subPath
+// mounts, so those are left out.
+func debugVolumeMounts(mounts []api.VolumeMount) []api.VolumeMount {
+	return slices.DeleteFunc(slices.Clone(mounts), func(mount api.VolumeMount) bool {
+		return mount.SubPath != "" || mount.SubPathExpr != ""
+	})
+}
+
+func (s *executor) waitForDebugContainer(ctx context.Context, containerName string) error {
+	interval := time.Duration(s.Config.Kubernetes.GetPollInterval()) * time.Second
+
+	for {
+		// kubeAPI: pods, get
+		pod, err := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name != containerName {
+				continue
+			}
+
+			switch {
+			case status.State.Running != nil:
+				return nil
+			case status.State.Terminated != nil:
+				return fmt.Errorf("debug container %s terminated: %s", containerName, status.State.Terminated.Reason)
+			case status.State.Waiting != nil:
+				switch status.State.Waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError":
+					return fmt.Errorf("debug container %s: %s", containerName, status.State.Waiting.Message)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for debug container %s: %w", containerName, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
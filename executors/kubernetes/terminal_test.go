@@ -0,0 +1,173 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestStartDebugContainer(t *testing.T) {
+	volumeMounts := []api.VolumeMount{
+		{Name: "repo", MountPath: "/builds"},
+		{Name: "config", MountPath: "/etc/app.conf", SubPath: "app.conf"},
+		{Name: "logs", MountPath: "/var/log/job", SubPathExpr: "$(POD_NAME)"},
+		{Name: "scripts", MountPath: "/scripts"},
+	}
+
+	runAsUser := int64(1000)
+	buildSecurityContext := &api.SecurityContext{
+		RunAsUser:    &runAsUser,
+		Capabilities: &api.Capabilities{Drop: []api.Capability{"ALL"}},
+	}
+
+	tests := map[string]struct {
+		buildSecurityContext *api.SecurityContext
+		noBuildContainer     bool
+		statuses             []api.ContainerStatus
+		state                api.ContainerState
+		wantContainerName    string
+		wantSecurityContext  *api.SecurityContext
+		wantUpdate           bool
+		wantErr              string
+	}{
+		"starts a debug container": {
+			buildSecurityContext: buildSecurityContext,
+			state:                api.ContainerState{Running: &api.ContainerStateRunning{}},
+			wantContainerName:    "debug-1",
+			wantSecurityContext:  buildSecurityContext,
+			wantUpdate:           true,
+		},
+		"build container without security context": {
+			state:             api.ContainerState{Running: &api.ContainerStateRunning{}},
+			wantContainerName: "debug-1",
+			wantSecurityContext: &api.SecurityContext{
+				Capabilities: &api.Capabilities{Drop: []api.Capability{"NET_RAW"}},
+			},
+			wantUpdate: true,
+		},
+		"no build container": {
+			noBuildContainer: true,
+			wantErr:          "pod job-pod has no build container",
+		},
+		"reuses a running debug container": {
+			statuses: []api.ContainerStatus{
+				{Name: "debug-0", State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+			},
+			wantContainerName: "debug-0",
+		},
+		"image can't be pulled": {
+			state: api.ContainerState{Waiting: &api.ContainerStateWaiting{
+				Reason:  "ErrImagePull",
+				Message: "pull access denied",
+			}},
+			wantUpdate: true,
+			wantErr:    "pull access denied",
+		},
+		"security context can't be applied to the image": {
+			state: api.ContainerState{Waiting: &api.ContainerStateWaiting{
+				Reason:  "CreateContainerConfigError",
+				Message: "container has runAsNonRoot and image will run as root",
+			}},
+			wantUpdate: true,
+			wantErr:    "image will run as root",
+		},
+		"debug container terminated": {
+			state:      api.ContainerState{Terminated: &api.ContainerStateTerminated{Reason: "Error"}},
+			wantUpdate: true,
+			wantErr:    "terminated: Error",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := newTestExecutorWithKubeClient(t, &common.KubernetesConfig{
+				TerminalDebugImage: "busybox",
+				PollInterval:       1,
+			})
+
+			containers := []api.Container{{Name: helperContainerName}}
+			if !tt.noBuildContainer {
+				containers = append(containers, api.Container{
+					Name:            buildContainerName,
+					VolumeMounts:    volumeMounts,
+					SecurityContext: tt.buildSecurityContext,
+				})
+			}
+
+			e.pod = &api.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "test-ns"},
+				Spec: api.PodSpec{
+					Containers: containers,
+					// A debug container of a previous terminal session, which exited.
+					EphemeralContainers: []api.EphemeralContainer{
+						{EphemeralContainerCommon: api.EphemeralContainerCommon{Name: "debug-0"}},
+					},
+				},
+				Status: api.PodStatus{EphemeralContainerStatuses: tt.statuses},
+			}
+
+			kubeClient := fake.NewClientset(e.pod)
+			e.kubeClient = kubeClient
+
+			var updated *api.Pod
+			kubeClient.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "ephemeralcontainers" {
+					return false, nil, nil
+				}
+
+				updated = action.(k8stesting.UpdateAction).GetObject().(*api.Pod).DeepCopy()
+
+				pod := updated.DeepCopy()
+				last := pod.Spec.EphemeralContainers[len(pod.Spec.EphemeralContainers)-1]
+				pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, api.ContainerStatus{
+					Name:  last.Name,
+					State: tt.state,
+				})
+
+				return true, pod, kubeClient.Tracker().Update(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, pod, pod.Namespace)
+			})
+
+			containerName, err := e.startDebugContainer(t.Context())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantContainerName, containerName)
+			}
+
+			if !tt.wantUpdate {
+				assert.Nil(t, updated)
+				return
+			}
+
+			require.NotNil(t, updated)
+			require.Len(t, updated.Spec.EphemeralContainers, 2)
+
+			container := updated.Spec.EphemeralContainers[1]
+			assert.Equal(t, "debug-1", container.Name)
+			assert.Equal(t, "busybox", container.Image)
+			assert.Equal(t, buildContainerName, container.TargetContainerName)
+			assert.Equal(t, []api.VolumeMount{
+				{Name: "repo", MountPath: "/builds"},
+				{Name: "scripts", MountPath: "/scripts"},
+			}, container.VolumeMounts, "the subPath mounts are left out")
+			assert.Equal(t, []string{"sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}, container.Command)
+			if tt.wantSecurityContext != nil {
+				assert.Equal(t, tt.wantSecurityContext, container.SecurityContext)
+			}
+			assert.True(t, container.Stdin)
+			assert.True(t, container.TTY)
+		})
+	}
+}
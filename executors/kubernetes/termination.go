@@ -0,0 +1,177 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+const (
+	podReasonEvicted          = "Evicted"
+	podReasonDeadlineExceeded = "DeadlineExceeded"
+	containerReasonOOMKilled  = "OOMKilled"
+)
+
+// terminationDiagnosis explains why the job pod, or one of its containers,
+// was terminated by Kubernetes.
+type terminationDiagnosis struct {
+	reason  spec.JobFailureReason
+	summary string
+	// details compare the configured limits of the containers with their
+	// observed peak usage.
+	details []string
+}
+
+// diagnoseTermination explains, in the job log, a failure of the job caused
+// by Kubernetes terminating the job pod or the container the failed command
+// ran in, and returns it with the failure reason of the termination.
+func (s *executor) diagnoseTermination(ctx context.Context, containerName string, err error) error {
+	if err == nil || s.pod == nil || ctx.Err() != nil {
+		return err
+	}
+
+	// kubeAPI: pods, get
+	pod, getErr := s.kubeClient.CoreV1().Pods(s.pod.Namespace).Get(ctx, s.pod.Name, metav1.GetOptions{})
+	if getErr != nil {
+		s.BuildLogger.Debugln("Getting job pod to diagnose its termination:", getErr)
+		return err
+	}
+
+	diagnosis := diagnosePodTermination(pod, containerName, s.podMetrics.peakUsage)
+	if diagnosis == nil {
+		return err
+	}
+
+	s.BuildLogger.Errorln(diagnosis.summary)
+	for _, detail := range diagnosis.details {
+		s.BuildLogger.Errorln(" ", detail)
+	}
+
+	buildErr := &common.BuildError{
+		Inner:         fmt.Errorf("%s: %w", diagnosis.summary, err),
+		FailureReason: diagnosis.reason,
+	}
+
+	var inner *common.BuildError
+	if errors.As(err, &inner) {
+		buildErr.ExitCode = inner.ExitCode
+	}
+
+	return buildErr
+}
+
+// diagnosePodTermination returns the diagnosis of the termination of the pod
+// or of the container, or nil when Kubernetes didn't terminate them. Only the
+// current state of the container is considered: a previous termination, or
+// the termination of another container, didn't fail the command.
+func diagnosePodTermination(pod *api.Pod, containerName string, peakUsage func(container string) api.ResourceList) *terminationDiagnosis {
+	containers := slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers)
+
+	reason, message := pod.Status.Reason, pod.Status.Message
+	if reason == "" {
+		// the pod is only marked as evicted once its containers are stopped
+		for _, c := range pod.Status.Conditions {
+			if c.Type == api.DisruptionTarget && c.Status == api.ConditionTrue && c.Reason == "TerminationByKubelet" {
+				reason, message = podReasonEvicted, c.Message
+			}
+		}
+	}
+
+	switch reason {
+	case podReasonEvicted:
+		if strings.Contains(strings.ToLower(message), "ephemeral") {
+			return &terminationDiagnosis{
+				reason:  common.StorageLimitFailure,
+				summary: fmt.Sprintf("Job pod %s was evicted because it exceeded its ephemeral storage limit: %s", pod.Name, message),
+				details: containersUsage(containers, peakUsage, api.ResourceEphemeralStorage),
+			}
+		}
+
+		return &terminationDiagnosis{
+			reason:  common.EvictionFailure,
+			summary: fmt.Sprintf("Job pod %s was evicted from node %s: %s", pod.Name, pod.Spec.NodeName, message),
+			details: containersUsage(containers, peakUsage, api.ResourceMemory, api.ResourceEphemeralStorage),
+		}
+
+	case podReasonDeadlineExceeded:
+		summary := fmt.Sprintf("Job pod %s exceeded its active deadline", pod.Name)
+		if pod.Spec.ActiveDeadlineSeconds != nil {
+			summary = fmt.Sprintf("%s of %ds", summary, *pod.Spec.ActiveDeadlineSeconds)
+		}
+
+		return &terminationDiagnosis{
+			reason:  common.JobExecutionTimeout,
+			summary: summary,
+		}
+	}
+
+	for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+		if status.Name != containerName {
+			continue
+		}
+
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.Reason != containerReasonOOMKilled {
+			continue
+		}
+
+		i := slices.IndexFunc(containers, func(c api.Container) bool { return c.Name == status.Name })
+		if i < 0 {
+			continue
+		}
+
+		summary := fmt.Sprintf("Container %q was killed because it ran out of memory (%s)", status.Name, containerReasonOOMKilled)
+		if limit, ok := containers[i].Resources.Limits[api.ResourceMemory]; ok {
+			summary = fmt.Sprintf("Container %q was killed because it exceeded its memory limit of %s (%s)", status.Name, limit.String(), containerReasonOOMKilled)
+		}
+
+		return &terminationDiagnosis{
+			reason:  common.OutOfMemoryFailure,
+			summary: summary,
+			details: containersUsage(containers[i:i+1], peakUsage, api.ResourceMemory, api.ResourceCPU),
+		}
+	}
+
+	return nil
+}
+
+// containersUsage describes the requests and limits of the resources of the
+// containers, with their observed peak usage.
+func containersUsage(containers []api.Container, peakUsage func(container string) api.ResourceList, resources ...api.ResourceName) []string {
+	var lines []string
+	for _, c := range containers {
+		peak := peakUsage(c.Name)
+
+		var parts []string
+		for _, name := range resources {
+			var values []string
+			if request, ok := c.Resources.Requests[name]; ok {
+				values = append(values, "request "+request.String())
+			}
+			if limit, ok := c.Resources.Limits[name]; ok {
+				values = append(values, "limit "+limit.String())
+			}
+			if usage, ok := peak[name]; ok {
+				values = append(values, "peak usage "+usage.String())
+			}
+
+			if len(values) > 0 {
+				parts = append(parts, fmt.Sprintf("%s %s", name, strings.Join(values, ", ")))
+			}
+		}
+
+		if len(parts) > 0 {
+			lines = append(lines, fmt.Sprintf("container %q: %s", c.Name, strings.Join(parts, "; ")))
+		}
+	}
+
+	return lines
+}
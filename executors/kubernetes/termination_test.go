@@ -0,0 +1,228 @@
+//go:build !integration
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+func newTerminatedTestPod() *api.Pod {
+	return &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-pod", Namespace: "test-ns"},
+		Spec: api.PodSpec{
+			NodeName: "node-1",
+			Containers: []api.Container{
+				{
+					Name: buildContainerName,
+					Resources: api.ResourceRequirements{
+						Requests: api.ResourceList{api.ResourceMemory: resource.MustParse("512Mi")},
+						Limits: api.ResourceList{
+							api.ResourceMemory:           resource.MustParse("1Gi"),
+							api.ResourceEphemeralStorage: resource.MustParse("2Gi"),
+						},
+					},
+				},
+				{Name: helperContainerName},
+			},
+		},
+	}
+}
+
+func TestDiagnosePodTermination(t *testing.T) {
+	peakUsage := func(container string) api.ResourceList {
+		if container != buildContainerName {
+			return nil
+		}
+		return api.ResourceList{
+			api.ResourceMemory: resource.MustParse("1023Mi"),
+			api.ResourceCPU:    resource.MustParse("250m"),
+		}
+	}
+
+	tests := map[string]struct {
+		update    func(pod *api.Pod)
+		container string
+
+		wantReason  spec.JobFailureReason
+		wantSummary string
+		wantDetails []string
+	}{
+		"not terminated": {
+			update: func(pod *api.Pod) {
+				pod.Status.ContainerStatuses = []api.ContainerStatus{{
+					Name:  buildContainerName,
+					State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+				}}
+			},
+		},
+		"OOMKilled": {
+			update: func(pod *api.Pod) {
+				pod.Status.ContainerStatuses = []api.ContainerStatus{
+					{Name: helperContainerName, State: api.ContainerState{Running: &api.ContainerStateRunning{}}},
+					{
+						Name:  buildContainerName,
+						State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+					},
+				}
+			},
+			wantReason:  common.OutOfMemoryFailure,
+			wantSummary: `Container "build" was killed because it exceeded its memory limit of 1Gi (OOMKilled)`,
+			wantDetails: []string{`container "build": memory request 512Mi, limit 1Gi, peak usage 1023Mi; cpu peak usage 250m`},
+		},
+		"OOMKilled without limit": {
+			update: func(pod *api.Pod) {
+				pod.Status.ContainerStatuses = []api.ContainerStatus{{
+					Name:  helperContainerName,
+					State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+				}}
+			},
+			container:   helperContainerName,
+			wantReason:  common.OutOfMemoryFailure,
+			wantSummary: `Container "helper" was killed because it ran out of memory (OOMKilled)`,
+		},
+		"OOMKilled previously": {
+			update: func(pod *api.Pod) {
+				pod.Status.ContainerStatuses = []api.ContainerStatus{{
+					Name:                 buildContainerName,
+					State:                api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+					LastTerminationState: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+				}}
+			},
+		},
+		"other container OOMKilled": {
+			update: func(pod *api.Pod) {
+				pod.Status.ContainerStatuses = []api.ContainerStatus{
+					{
+						Name:  helperContainerName,
+						State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+					},
+					{
+						Name:  buildContainerName,
+						State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+					},
+				}
+			},
+		},
+		"evicted": {
+			update: func(pod *api.Pod) {
+				pod.Status.Reason = "Evicted"
+				pod.Status.Message = "The node was low on resource: memory."
+			},
+			wantReason:  common.EvictionFailure,
+			wantSummary: "Job pod job-pod was evicted from node node-1: The node was low on resource: memory.",
+			wantDetails: []string{`container "build": memory request 512Mi, limit 1Gi, peak usage 1023Mi; ephemeral-storage limit 2Gi`},
+		},
+		"evicted by the kubelet": {
+			update: func(pod *api.Pod) {
+				pod.Status.Conditions = []api.PodCondition{{
+					Type:    api.DisruptionTarget,
+					Status:  api.ConditionTrue,
+					Reason:  "TerminationByKubelet",
+					Message: "The node was low on resource: memory.",
+				}}
+			},
+			wantReason:  common.EvictionFailure,
+			wantSummary: "Job pod job-pod was evicted from node node-1: The node was low on resource: memory.",
+			wantDetails: []string{`container "build": memory request 512Mi, limit 1Gi, peak usage 1023Mi; ephemeral-storage limit 2Gi`},
+		},
+		"ephemeral storage exceeded": {
+			update: func(pod *api.Pod) {
+				pod.Status.Reason = "Evicted"
+				pod.Status.Message = `Container build exceeded its local ephemeral storage limit "2Gi". `
+			},
+			wantReason:  common.StorageLimitFailure,
+			wantSummary: `Job pod job-pod was evicted because it exceeded its ephemeral storage limit: Container build exceeded its local ephemeral storage limit "2Gi". `,
+			wantDetails: []string{`container "build": ephemeral-storage limit 2Gi`},
+		},
+		"deadline exceeded": {
+			update: func(pod *api.Pod) {
+				pod.Spec.ActiveDeadlineSeconds = new(int64(3600))
+				pod.Status.Reason = "DeadlineExceeded"
+			},
+			wantReason:  common.JobExecutionTimeout,
+			wantSummary: "Job pod job-pod exceeded its active deadline of 3600s",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pod := newTerminatedTestPod()
+			tt.update(pod)
+
+			container := tt.container
+			if container == "" {
+				container = buildContainerName
+			}
+
+			diagnosis := diagnosePodTermination(pod, container, peakUsage)
+			if tt.wantReason == "" {
+				assert.Nil(t, diagnosis)
+				return
+			}
+
+			require.NotNil(t, diagnosis)
+			assert.Equal(t, tt.wantReason, diagnosis.reason)
+			assert.Equal(t, tt.wantSummary, diagnosis.summary)
+			assert.Equal(t, tt.wantDetails, diagnosis.details)
+		})
+	}
+}
+
+func TestDiagnoseTermination(t *testing.T) {
+	scriptErr := &common.BuildError{
+		Inner:         errors.New("command terminated with exit code 137"),
+		ExitCode:      137,
+		FailureReason: common.ScriptFailure,
+	}
+
+	pod := newTerminatedTestPod()
+	pod.Status.ContainerStatuses = []api.ContainerStatus{{
+		Name:  buildContainerName,
+		State: api.ContainerState{Terminated: &api.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+	}}
+
+	t.Run("terminated container", func(t *testing.T) {
+		e := newTestExecutorWithKubeClient(t, nil)
+		e.kubeClient = fake.NewClientset(pod)
+		e.pod = pod
+
+		err := e.diagnoseTermination(t.Context(), buildContainerName, scriptErr)
+
+		var buildErr *common.BuildError
+		require.ErrorAs(t, err, &buildErr)
+		assert.Equal(t, common.OutOfMemoryFailure, buildErr.FailureReason)
+		assert.Equal(t, 137, buildErr.ExitCode)
+		assert.ErrorIs(t, err, scriptErr)
+		assert.ErrorContains(t, err, "exceeded its memory limit of 1Gi")
+	})
+
+	t.Run("job canceled", func(t *testing.T) {
+		e := newTestExecutorWithKubeClient(t, nil)
+		e.kubeClient = fake.NewClientset(pod)
+		e.pod = pod
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		assert.Equal(t, scriptErr, e.diagnoseTermination(ctx, buildContainerName, scriptErr))
+	})
+
+	t.Run("pod not found", func(t *testing.T) {
+		e := newTestExecutorWithKubeClient(t, nil)
+		e.pod = pod
+
+		assert.Equal(t, scriptErr, e.diagnoseTermination(t.Context(), buildContainerName, scriptErr))
+	})
+}
@@ -0,0 +1,365 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/ssh"
+)
+
+const healthCheckTimeout = 10 * time.Second
+
+// hostPools are the host pools of the runners using the SSH executor, keyed
+// by runner token.
+type hostPools struct {
+	mu    sync.Mutex
+	pools map[string]*hostPool
+
+	// For testing
+	check func(ctx context.Context, config common.SshConfig) error
+}
+
+func newHostPools() *hostPools {
+	return &hostPools{
+		pools: make(map[string]*hostPool),
+		check: checkHost,
+	}
+}
+
+// get returns the host pool of the runner, or nil when it doesn't configure
+// SSH hosts. A pool lives as long as the runner configures hosts: reloading
+// the configuration reconfigures it in place, so that the jobs running on its
+// hosts keep counting against their concurrency.
+func (p *hostPools) get(config *common.RunnerConfig) *hostPool {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	token := config.GetToken()
+	pool := p.pools[token]
+
+	if config.SSH == nil || len(config.SSH.Hosts) == 0 {
+		if pool != nil {
+			delete(p.pools, token)
+			go pool.stop()
+		}
+
+		return nil
+	}
+
+	if pool == nil {
+		pool = newHostPool(*config.SSH, logrus.WithField("runner", config.ShortDescription()), p.check)
+		pool.configLoadedAt = config.ConfigLoadedAt
+		pool.start()
+
+		p.pools[token] = pool
+
+		return pool
+	}
+
+	pool.reload(*config.SSH, config.ConfigLoadedAt)
+
+	return pool
+}
+
+func (p *hostPools) shutdown() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for token, pool := range p.pools {
+		wg.Go(pool.stop)
+		delete(p.pools, token)
+	}
+	wg.Wait()
+}
+
+// hostPool distributes the jobs of a runner to the hosts of its pool, and
+// quarantines the hosts failing the health checks.
+type hostPool struct {
+	mu    sync.Mutex
+	hosts []*poolHost
+	next  int
+
+	config         common.SshConfig
+	configLoadedAt time.Time
+	logger         logrus.FieldLogger
+	check          func(ctx context.Context, config common.SshConfig) error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type poolHost struct {
+	host        string
+	port        string
+	concurrency int
+
+	active      int
+	quarantined bool
+}
+
+func (h *poolHost) address() string {
+	return net.JoinHostPort(h.host, h.port)
+}
+
+func (h *poolHost) available() bool {
+	return !h.quarantined && h.active < h.concurrency
+}
+
+func (h *poolHost) load() float64 {
+	return float64(h.active) / float64(h.concurrency)
+}
+
+func newHostPool(
+	config common.SshConfig,
+	logger logrus.FieldLogger,
+	check func(ctx context.Context, config common.SshConfig) error,
+) *hostPool {
+	p := &hostPool{
+		logger: logger,
+		check:  check,
+	}
+	p.configure(config)
+
+	return p
+}
+
+// configure sets the configuration of the pool, with the lock held. The hosts
+// are matched to the current ones by address, and the ones still configured
+// keep their running jobs and health. The removed hosts are only released by
+// the jobs still running on them.
+func (p *hostPool) configure(config common.SshConfig) {
+	current := make(map[string]*poolHost, len(p.hosts))
+	for _, h := range p.hosts {
+		current[h.address()] = h
+	}
+
+	hosts := make([]*poolHost, 0, len(config.Hosts))
+	for _, h := range config.Hosts {
+		port := h.Port
+		if port == "" {
+			port = config.Port
+		}
+		if port == "" {
+			port = "22"
+		}
+
+		host := &poolHost{host: h.Host, port: port}
+		if existing, ok := current[host.address()]; ok {
+			// a host listed twice gets two entries
+			delete(current, host.address())
+			host = existing
+		}
+		host.concurrency = h.GetConcurrency()
+
+		hosts = append(hosts, host)
+	}
+
+	selection := config.GetHostSelection()
+	if selection != common.SshHostSelectionLeastLoaded && selection != common.SshHostSelectionRoundRobin {
+		p.logger.WithField("host_selection", selection).
+			Warningln("Unknown SSH host selection, using", common.SshHostSelectionLeastLoaded)
+	}
+
+	p.hosts = hosts
+	p.next = 0
+	p.config = config
+}
+
+// reload reconfigures the pool when the configuration was loaded again since
+// it was last configured, and restarts its health checks with it.
+func (p *hostPool) reload(config common.SshConfig, loadedAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if loadedAt.Equal(p.configLoadedAt) {
+		return
+	}
+
+	p.configure(config)
+	p.configLoadedAt = loadedAt
+	p.startHealthChecks()
+}
+
+// acquire reserves a slot on a healthy host with free capacity
+func (p *hostPool) acquire() (*poolHost, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var selected *poolHost
+
+	if p.config.GetHostSelection() == common.SshHostSelectionRoundRobin {
+		for i := range p.hosts {
+			idx := (p.next + i) % len(p.hosts)
+			if p.hosts[idx].available() {
+				selected = p.hosts[idx]
+				p.next = idx + 1
+				break
+			}
+		}
+	} else {
+		for _, h := range p.hosts {
+			if h.available() && (selected == nil || h.load() < selected.load()) {
+				selected = h
+			}
+		}
+	}
+
+	if selected == nil {
+		return nil, &common.NoFreeExecutorError{Message: "no SSH host with free capacity"}
+	}
+
+	selected.active++
+
+	return selected, nil
+}
+
+func (p *hostPool) release(h *poolHost) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if h.active > 0 {
+		h.active--
+	}
+}
+
+// setHealth quarantines the host when err is set, and puts it back in the
+// pool otherwise
+func (p *hostPool) setHealth(h *poolHost, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	logger := p.logger.WithField("ssh_host", h.address())
+
+	switch {
+	case err != nil && !h.quarantined:
+		logger.WithError(err).Warningln("SSH host is unreachable, quarantining it")
+	case err == nil && h.quarantined:
+		logger.Infoln("SSH host is reachable again")
+	}
+
+	h.quarantined = err != nil
+}
+
+// hostConfig returns the SSH configuration of the connections to the host
+func (p *hostPool) hostConfig(h *poolHost) common.SshConfig {
+	p.mu.Lock()
+	config := p.config
+	p.mu.Unlock()
+
+	config.Host = h.host
+	config.Port = h.port
+	config.Hosts = nil
+
+	return config
+}
+
+func (p *hostPool) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.startHealthChecks()
+}
+
+// startHealthChecks replaces the health checks of the pool with ones at the
+// interval of its configuration, with the lock held. The results of the
+// checks still running are discarded.
+func (p *hostPool) startHealthChecks() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	interval := p.config.GetHealthCheckInterval()
+	p.wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			p.checkHosts(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (p *hostPool) checkHosts(ctx context.Context) {
+	p.mu.Lock()
+	hosts := slices.Clone(p.hosts)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			err := p.check(ctx, p.hostConfig(h))
+
+			// the pool is stopping, or was reconfigured
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+
+			p.setHealth(h, err)
+		})
+	}
+	wg.Wait()
+}
+
+func (p *hostPool) stop() {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// checkHost connects to the host, through its jump hosts if any
+func checkHost(ctx context.Context, config common.SshConfig) error {
+	client := ssh.Client{
+		SshConfig:      config,
+		ConnectRetries: 1,
+		ConnectTimeout: healthCheckTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Connect()
+	}()
+
+	select {
+	case err := <-errCh:
+		client.Cleanup()
+		return err
+	case <-ctx.Done():
+		go func() {
+			<-errCh
+			client.Cleanup()
+		}()
+		return ctx.Err()
+	}
+}
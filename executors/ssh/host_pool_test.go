@@ -0,0 +1,219 @@
+//go:build !integration
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func newTestHostPool(selection string, hosts ...common.SshHost) *hostPool {
+	return newHostPool(common.SshConfig{
+		Port:          "2222",
+		Hosts:         hosts,
+		HostSelection: selection,
+	}, logrus.New(), nil)
+}
+
+func acquireAddresses(t *testing.T, pool *hostPool, n int) []string {
+	var addresses []string
+	for range n {
+		h, err := pool.acquire()
+		require.NoError(t, err)
+		addresses = append(addresses, h.address())
+	}
+
+	return addresses
+}
+
+func TestHostPool_Acquire(t *testing.T) {
+	hosts := []common.SshHost{
+		{Host: "a.example.com", Concurrency: 2},
+		{Host: "b.example.com", Port: "22", Concurrency: 1},
+		{Host: "c.example.com", Concurrency: 3},
+	}
+
+	t.Run("least loaded", func(t *testing.T) {
+		pool := newTestHostPool("", hosts...)
+
+		assert.Equal(t, []string{
+			"a.example.com:2222",
+			"b.example.com:22",
+			"c.example.com:2222",
+			"c.example.com:2222",
+			"a.example.com:2222",
+			"c.example.com:2222",
+		}, acquireAddresses(t, pool, 6))
+
+		_, err := pool.acquire()
+		var noFreeErr *common.NoFreeExecutorError
+		assert.ErrorAs(t, err, &noFreeErr)
+
+		pool.release(pool.hosts[1])
+		assert.Equal(t, []string{"b.example.com:22"}, acquireAddresses(t, pool, 1))
+	})
+
+	t.Run("round robin", func(t *testing.T) {
+		pool := newTestHostPool(common.SshHostSelectionRoundRobin, hosts...)
+
+		assert.Equal(t, []string{
+			"a.example.com:2222",
+			"b.example.com:22",
+			"c.example.com:2222",
+			"a.example.com:2222",
+			"c.example.com:2222",
+			"c.example.com:2222",
+		}, acquireAddresses(t, pool, 6))
+
+		_, err := pool.acquire()
+		var noFreeErr *common.NoFreeExecutorError
+		assert.ErrorAs(t, err, &noFreeErr)
+	})
+
+	t.Run("quarantined host", func(t *testing.T) {
+		pool := newTestHostPool("", hosts...)
+
+		pool.setHealth(pool.hosts[0], errors.New("connection refused"))
+		pool.setHealth(pool.hosts[2], errors.New("connection refused"))
+		assert.Equal(t, []string{"b.example.com:22"}, acquireAddresses(t, pool, 1))
+
+		_, err := pool.acquire()
+		var noFreeErr *common.NoFreeExecutorError
+		assert.ErrorAs(t, err, &noFreeErr)
+
+		pool.setHealth(pool.hosts[0], nil)
+		assert.Equal(t, []string{"a.example.com:2222"}, acquireAddresses(t, pool, 1))
+	})
+}
+
+// fakeHealthChecks reports the hosts with an error as unreachable
+type fakeHealthChecks struct {
+	mu      sync.Mutex
+	errs    map[string]error
+	checked chan string
+}
+
+func (f *fakeHealthChecks) check(_ context.Context, config common.SshConfig) error {
+	f.mu.Lock()
+	err := f.errs[config.Host]
+	f.mu.Unlock()
+
+	f.checked <- config.Host
+
+	return err
+}
+
+func TestHostPools_Get(t *testing.T) {
+	checks := &fakeHealthChecks{
+		errs:    map[string]error{"b.example.com": errors.New("connection refused")},
+		checked: make(chan string, 10),
+	}
+
+	pools := newHostPools()
+	pools.check = checks.check
+	defer pools.shutdown()
+
+	config := &common.RunnerConfig{
+		RunnerCredentials: common.RunnerCredentials{Token: "token"},
+		RunnerSettings: common.RunnerSettings{
+			SSH: &common.SshConfig{
+				Hosts: []common.SshHost{
+					{Host: "a.example.com"},
+					{Host: "b.example.com"},
+				},
+				HealthCheckInterval: time.Hour,
+			},
+		},
+		ConfigLoadedAt: time.Now(),
+	}
+
+	pool := pools.get(config)
+	require.NotNil(t, pool)
+	assert.Same(t, pool, pools.get(config))
+
+	// the hosts are checked when the pool starts
+	assert.ElementsMatch(t, []string{"a.example.com", "b.example.com"}, []string{<-checks.checked, <-checks.checked})
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.hosts[1].quarantined
+	}, time.Second, 10*time.Millisecond)
+
+	h, err := pool.acquire()
+	require.NoError(t, err)
+	assert.Equal(t, "a.example.com:22", h.address())
+
+	_, err = pool.acquire()
+	var noFreeErr *common.NoFreeExecutorError
+	assert.ErrorAs(t, err, &noFreeErr)
+
+	// reloading the configuration keeps the jobs running on the hosts
+	reloaded := *config
+	reloaded.SSH = &common.SshConfig{
+		Hosts: []common.SshHost{
+			{Host: "a.example.com", Concurrency: 2},
+			{Host: "c.example.com"},
+		},
+		HealthCheckInterval: time.Hour,
+	}
+	reloaded.ConfigLoadedAt = config.ConfigLoadedAt.Add(time.Minute)
+	assert.Same(t, pool, pools.get(&reloaded))
+	assert.ElementsMatch(t, []string{"a.example.com", "c.example.com"}, []string{<-checks.checked, <-checks.checked})
+
+	assert.Equal(t, []string{"c.example.com:22", "a.example.com:22"}, acquireAddresses(t, pool, 2))
+	_, err = pool.acquire()
+	assert.ErrorAs(t, err, &noFreeErr)
+
+	pool.release(h)
+	assert.Equal(t, []string{"a.example.com:22"}, acquireAddresses(t, pool, 1))
+
+	reloaded.SSH = &common.SshConfig{Host: "single.example.com"}
+	reloaded.ConfigLoadedAt = reloaded.ConfigLoadedAt.Add(time.Minute)
+	assert.Nil(t, pools.get(&reloaded))
+}
+
+func TestExecutorProvider_AcquireRelease(t *testing.T) {
+	provider := NewProvider().(executorProvider)
+	provider.hostPools.check = func(context.Context, common.SshConfig) error { return nil }
+	defer provider.Shutdown(t.Context(), nil)
+
+	t.Run("single host", func(t *testing.T) {
+		data, err := provider.Acquire(&common.RunnerConfig{
+			RunnerSettings: common.RunnerSettings{SSH: &common.SshConfig{Host: "example.com"}},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, data)
+	})
+
+	t.Run("host pool", func(t *testing.T) {
+		config := &common.RunnerConfig{
+			RunnerCredentials: common.RunnerCredentials{Token: "pool-token"},
+			RunnerSettings: common.RunnerSettings{
+				SSH: &common.SshConfig{Hosts: []common.SshHost{{Host: "a.example.com", Port: "2222"}}},
+			},
+		}
+
+		data, err := provider.Acquire(config)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"ssh_host": "a.example.com:2222"}, common.GetExecutorLogFields(data))
+
+		_, err = provider.Acquire(config)
+		var noFreeErr *common.NoFreeExecutorError
+		require.ErrorAs(t, err, &noFreeErr)
+
+		provider.Release(config, data)
+
+		data, err = provider.Acquire(config)
+		require.NoError(t, err)
+		provider.Release(config, data)
+	})
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"context"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/executors"
+)
+
+type executorData struct {
+	pool *hostPool
+	host *poolHost
+}
+
+func (d *executorData) LogFields() map[string]string {
+	return map[string]string{"ssh_host": d.host.address()}
+}
+
+type executorProvider struct {
+	executors.DefaultExecutorProvider
+
+	hostPools *hostPools
+}
+
+// Acquire reserves a slot on a host of the pool of the runner, when it has
+// one. The runner with a single host isn't limited by the provider.
+func (p executorProvider) Acquire(config *common.RunnerConfig) (common.ExecutorData, error) {
+	pool := p.hostPools.get(config)
+	if pool == nil {
+		return p.DefaultExecutorProvider.Acquire(config)
+	}
+
+	host, err := pool.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	return &executorData{pool: pool, host: host}, nil
+}
+
+func (p executorProvider) Release(_ *common.RunnerConfig, data common.ExecutorData) {
+	if d, ok := data.(*executorData); ok {
+		d.pool.release(d.host)
+	}
+}
+
+// Init implements ManagedExecutorProvider.
+func (p executorProvider) Init() {}
+
+// Shutdown implements ManagedExecutorProvider.
+func (p executorProvider) Shutdown(_ context.Context, _ *common.Config) {
+	p.hostPools.shutdown()
+}
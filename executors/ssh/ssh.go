@@ -43,9 +43,18 @@ func (s *executor) Prepare(options common.ExecutorPrepareOptions) error {
 		SshConfig: *s.Config.SSH,
 	}
 
+	data, pooled := s.Build.ExecutorData.(*executorData)
+	if pooled {
+		s.sshCommand.SshConfig = data.pool.hostConfig(data.host)
+		s.BuildLogger.Println("Using SSH host", data.host.address(), "from the pool")
+	}
+
 	s.BuildLogger.Debugln("Connecting to SSH server...")
 	err = s.sshCommand.Connect()
 	if err != nil {
+		if pooled {
+			data.pool.setHealth(data.host, err)
+		}
 		return fmt.Errorf("ssh command Connect() error: %w", err)
 	}
 
@@ -105,9 +114,12 @@ func NewProvider() common.ExecutorProvider {
 		features.Shared = true
 	}
 
-	return executors.DefaultExecutorProvider{
-		Creator:          creator,
-		FeaturesUpdater:  featuresUpdater,
-		DefaultShellName: options.Shell.Shell,
+	return executorProvider{
+		DefaultExecutorProvider: executors.DefaultExecutorProvider{
+			Creator:          creator,
+			FeaturesUpdater:  featuresUpdater,
+			DefaultShellName: options.Shell.Shell,
+		},
+		hostPools: newHostPools(),
 	}
 }
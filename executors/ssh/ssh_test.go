@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -108,6 +109,61 @@ func TestPrepare(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestPrepareWithHostPool(t *testing.T) {
+	disableHostChecking := true
+
+	server, err := sshHelpers.NewStubServer("user", "pass")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, server.Stop())
+	}()
+
+	runnerConfig := &common.RunnerConfig{
+		RunnerSettings: common.RunnerSettings{
+			Executor: "ssh",
+			SSH: &common.SshConfig{
+				User:                         "user",
+				Password:                     "pass",
+				DisableStrictHostKeyChecking: &disableHostChecking,
+				Hosts: []common.SshHost{
+					{Host: "127.0.0.1", Port: server.Port()},
+				},
+			},
+		},
+	}
+
+	pool := newHostPool(*runnerConfig.SSH, logrus.New(), nil)
+	host, err := pool.acquire()
+	require.NoError(t, err)
+
+	build := &common.Build{
+		Job: spec.Job{
+			GitInfo: spec.GitInfo{
+				Sha: "1234567890",
+			},
+		},
+		Runner:       &common.RunnerConfig{},
+		ExecutorData: &executorData{pool: pool, host: host},
+	}
+
+	e := &executor{
+		AbstractExecutor: executors.AbstractExecutor{
+			ExecutorOptions: executorOptions,
+		},
+	}
+
+	err = e.Prepare(common.ExecutorPrepareOptions{
+		Config:  runnerConfig,
+		Build:   build,
+		Context: t.Context(),
+	})
+	require.NoError(t, err)
+	defer e.Cleanup()
+
+	assert.Equal(t, "127.0.0.1", e.sshCommand.Host)
+	assert.Equal(t, server.Port(), e.sshCommand.Port)
+}
+
 func TestPrepareMissingSSHConfig(t *testing.T) {
 	runnerConfig := &common.RunnerConfig{
 		RunnerSettings: common.RunnerSettings{
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The variables of a service that define when it's ready, and the services it
+// depends on. Like HEALTHCHECK_TCP_PORT, they're set with the variables of the
+// service in .gitlab-ci.yml, or with its environment in config.toml.
+const (
+	HealthcheckCommandVariable  = "HEALTHCHECK_COMMAND"
+	HealthcheckHTTPPathVariable = "HEALTHCHECK_HTTP_PATH"
+	HealthcheckUseImageVariable = "HEALTHCHECK_USE_IMAGE"
+	DependsOnVariable           = "SERVICE_DEPENDS_ON"
+)
+
+// Readiness defines when a service is ready to be used. The zero value waits
+// for the exposed TCP ports of the service to accept connections.
+type Readiness struct {
+	// Command is run with sh -c inside the service container, which is ready
+	// once it exits with 0.
+	Command string
+	// HTTPPath is requested on the health check port of the service, which is
+	// ready once it answers with a 2xx or 3xx status.
+	HTTPPath string
+	// UseImage waits for the HEALTHCHECK of the image of the service to
+	// report it healthy.
+	UseImage bool
+}
+
+// ReadinessFor returns the readiness of a service from its variables, which
+// get returns by name.
+func ReadinessFor(get func(key string) string) Readiness {
+	r := Readiness{
+		Command:  strings.TrimSpace(get(HealthcheckCommandVariable)),
+		HTTPPath: strings.TrimSpace(get(HealthcheckHTTPPathVariable)),
+	}
+
+	if r.HTTPPath != "" && !strings.HasPrefix(r.HTTPPath, "/") {
+		r.HTTPPath = "/" + r.HTTPPath
+	}
+
+	r.UseImage, _ = strconv.ParseBool(get(HealthcheckUseImageVariable))
+
+	return r
+}
+
+// IsTCP returns whether the service is only waited for on its TCP ports.
+func (r Readiness) IsTCP() bool {
+	return r == Readiness{}
+}
+
+// DependsOn returns the aliases of the services a service depends on, from its
+// variables, which get returns by name. They're separated with commas or
+// spaces.
+func DependsOn(get func(key string) string) []string {
+	return strings.FieldsFunc(get(DependsOnVariable), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// Dependencies are the aliases of a service and those of the services it
+// depends on.
+type Dependencies struct {
+	Aliases   []string
+	DependsOn []string
+}
+
+// DependenciesFor returns the dependencies of the service of the image, with
+// the aliases of its name and the aliases it's given, and the variables, which
+// get returns by name.
+func DependenciesFor(image string, aliases []string, get func(key string) string) Dependencies {
+	return Dependencies{
+		Aliases:   append(SplitNameAndVersion(image).Aliases, aliases...),
+		DependsOn: DependsOn(get),
+	}
+}
+
+// Stages orders the services in stages, which are the indexes of services
+// that only depend on the services of the previous stages. The services keep
+// their order within a stage, and services without dependencies are all in
+// the first one. An unknown dependency or a cycle is an error.
+func Stages(services []Dependencies) ([][]int, error) {
+	byAlias := indexByAlias(services)
+
+	deps := make([][]int, len(services))
+	for i, s := range services {
+		for _, alias := range s.DependsOn {
+			dep, ok := byAlias[alias]
+			if !ok {
+				return nil, fmt.Errorf("service %q (services[%d]) depends on unknown service %q", firstAlias(s), i, alias)
+			}
+			if !slices.Contains(deps[i], dep) {
+				deps[i] = append(deps[i], dep)
+			}
+		}
+	}
+
+	stage := make([]int, len(services))
+	for i := range stage {
+		stage[i] = -1
+	}
+
+	var stages [][]int
+	for placed := 0; placed < len(services); {
+		var current []int
+		for i := range services {
+			if stage[i] >= 0 || !placedBefore(deps[i], stage, len(stages)) {
+				continue
+			}
+			current = append(current, i)
+		}
+
+		if len(current) == 0 {
+			return nil, cycleError(services, stage)
+		}
+
+		for _, i := range current {
+			stage[i] = len(stages)
+		}
+		stages = append(stages, current)
+		placed += len(current)
+	}
+
+	return stages, nil
+}
+
+// Required returns whether other services depend on each of the services.
+// Unknown dependencies are ignored.
+func Required(services []Dependencies) []bool {
+	byAlias := indexByAlias(services)
+
+	required := make([]bool, len(services))
+	for _, s := range services {
+		for _, alias := range s.DependsOn {
+			if dep, ok := byAlias[alias]; ok {
+				required[dep] = true
+			}
+		}
+	}
+
+	return required
+}
+
+// indexByAlias returns the index of the services by their aliases. An alias
+// used by several services is the one of the first.
+func indexByAlias(services []Dependencies) map[string]int {
+	byAlias := map[string]int{}
+	for i, s := range services {
+		for _, alias := range s.Aliases {
+			if _, ok := byAlias[alias]; !ok {
+				byAlias[alias] = i
+			}
+		}
+	}
+
+	return byAlias
+}
+
+// placedBefore returns whether all the dependencies are in stages before the
+// next one.
+func placedBefore(deps []int, stage []int, next int) bool {
+	for _, dep := range deps {
+		if stage[dep] < 0 || stage[dep] >= next {
+			return false
+		}
+	}
+
+	return true
+}
+
+func cycleError(services []Dependencies, stage []int) error {
+	var names []string
+	for i, s := range services {
+		if stage[i] < 0 {
+			names = append(names, strconv.Quote(firstAlias(s)))
+		}
+	}
+
+	return fmt.Errorf("dependency cycle between services %s", strings.Join(names, ", "))
+}
+
+func firstAlias(s Dependencies) string {
+	if len(s.Aliases) == 0 {
+		return ""
+	}
+
+	return s.Aliases[0]
+}
@@ -0,0 +1,126 @@
+//go:build !integration
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func variables(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestReadinessFor(t *testing.T) {
+	tests := map[string]struct {
+		vars     map[string]string
+		expected Readiness
+		isTCP    bool
+	}{
+		"default": {
+			isTCP: true,
+		},
+		"command": {
+			vars:     map[string]string{HealthcheckCommandVariable: " pg_isready -U postgres "},
+			expected: Readiness{Command: "pg_isready -U postgres"},
+		},
+		"HTTP path": {
+			vars:     map[string]string{HealthcheckHTTPPathVariable: "healthz"},
+			expected: Readiness{HTTPPath: "/healthz"},
+		},
+		"image": {
+			vars:     map[string]string{HealthcheckUseImageVariable: "true"},
+			expected: Readiness{UseImage: true},
+		},
+		"invalid image value": {
+			vars:  map[string]string{HealthcheckUseImageVariable: "yes please"},
+			isTCP: true,
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			r := ReadinessFor(variables(tc.vars))
+			assert.Equal(t, tc.expected, r)
+			assert.Equal(t, tc.isTCP, r.IsTCP())
+		})
+	}
+}
+
+func TestDependenciesFor(t *testing.T) {
+	deps := DependenciesFor(
+		"registry.example.com/group/postgres:16",
+		[]string{"db"},
+		variables(map[string]string{DependsOnVariable: "cache, vault  minio"}),
+	)
+
+	assert.Equal(t, Dependencies{
+		Aliases:   []string{"registry.example.com__group__postgres", "registry.example.com-group-postgres", "db"},
+		DependsOn: []string{"cache", "vault", "minio"},
+	}, deps)
+}
+
+func TestStages(t *testing.T) {
+	tests := map[string]struct {
+		services      []Dependencies
+		expected      [][]int
+		expectedError string
+	}{
+		"no services": {},
+		"no dependencies": {
+			services: []Dependencies{{Aliases: []string{"db"}}, {Aliases: []string{"cache"}}},
+			expected: [][]int{{0, 1}},
+		},
+		"dependencies": {
+			services: []Dependencies{
+				{Aliases: []string{"app"}, DependsOn: []string{"db", "cache"}},
+				{Aliases: []string{"db"}},
+				{Aliases: []string{"migrations"}, DependsOn: []string{"db"}},
+				{Aliases: []string{"cache"}},
+				{Aliases: []string{"proxy"}, DependsOn: []string{"app", "app"}},
+			},
+			expected: [][]int{{1, 3}, {0, 2}, {4}},
+		},
+		"unknown dependency": {
+			services:      []Dependencies{{Aliases: []string{"app"}, DependsOn: []string{"db"}}},
+			expectedError: `service "app" (services[0]) depends on unknown service "db"`,
+		},
+		"cycle": {
+			services: []Dependencies{
+				{Aliases: []string{"db"}},
+				{Aliases: []string{"app"}, DependsOn: []string{"worker"}},
+				{Aliases: []string{"worker"}, DependsOn: []string{"app", "db"}},
+			},
+			expectedError: `dependency cycle between services "app", "worker"`,
+		},
+		"self dependency": {
+			services:      []Dependencies{{Aliases: []string{"db"}, DependsOn: []string{"db"}}},
+			expectedError: `dependency cycle between services "db"`,
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			stages, err := Stages(tc.services)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, stages)
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	required := Required([]Dependencies{
+		{Aliases: []string{"app"}, DependsOn: []string{"db", "unknown"}},
+		{Aliases: []string{"db"}},
+		{Aliases: []string{"cache"}},
+	})
+
+	assert.Equal(t, []bool{false, true, false}, required)
+}
@@ -50,12 +50,14 @@ type Client interface {
 	ContainerKill(ctx context.Context, containerID, signal string) error
 	ContainerStop(ctx context.Context, containerID string, options client.ContainerStopOptions) error
 	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error)
 	ContainerAttach(
 		ctx context.Context,
 		container string,
 		options client.ContainerAttachOptions,
 	) (client.HijackedResponse, error)
 	ContainerRemove(ctx context.Context, containerID string, options client.ContainerRemoveOptions) error
+	ContainerRename(ctx context.Context, containerID, newName string) error
 	ContainerWait(
 		ctx context.Context,
 		containerID string,
@@ -64,6 +66,7 @@ type Client interface {
 	ContainerLogs(ctx context.Context, container string, options client.ContainerLogsOptions) (io.ReadCloser, error)
 	ContainerExecCreate(ctx context.Context, container string, config client.ExecCreateOptions) (container.ExecCreateResponse, error)
 	ContainerExecAttach(ctx context.Context, execID string, config client.ExecAttachOptions) (client.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (client.ExecInspectResult, error)
 
 	NetworkCreate(
 		ctx context.Context,
@@ -71,6 +74,7 @@ type Client interface {
 		options client.NetworkCreateOptions,
 	) (client.NetworkCreateResult, error)
 	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
 	NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
 	NetworkList(ctx context.Context, options client.NetworkListOptions) ([]network.Summary, error)
 	NetworkInspect(ctx context.Context, networkID string) (network.Inspect, error)
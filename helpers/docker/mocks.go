@@ -439,6 +439,72 @@ func (_c *MockClient_ContainerExecCreate_Call) RunAndReturn(run func(ctx context
 	return _c
 }
 
+// ContainerExecInspect provides a mock function for the type MockClient
+func (_mock *MockClient) ContainerExecInspect(ctx context.Context, execID string) (client.ExecInspectResult, error) {
+	ret := _mock.Called(ctx, execID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ContainerExecInspect")
+	}
+
+	var r0 client.ExecInspectResult
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (client.ExecInspectResult, error)); ok {
+		return returnFunc(ctx, execID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) client.ExecInspectResult); ok {
+		r0 = returnFunc(ctx, execID)
+	} else {
+		r0 = ret.Get(0).(client.ExecInspectResult)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, execID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockClient_ContainerExecInspect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ContainerExecInspect'
+type MockClient_ContainerExecInspect_Call struct {
+	*mock.Call
+}
+
+// ContainerExecInspect is a helper method to define mock.On call
+//   - ctx context.Context
+//   - execID string
+func (_e *MockClient_Expecter) ContainerExecInspect(ctx interface{}, execID interface{}) *MockClient_ContainerExecInspect_Call {
+	return &MockClient_ContainerExecInspect_Call{Call: _e.mock.On("ContainerExecInspect", ctx, execID)}
+}
+
+func (_c *MockClient_ContainerExecInspect_Call) Run(run func(ctx context.Context, execID string)) *MockClient_ContainerExecInspect_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockClient_ContainerExecInspect_Call) Return(execInspectResult client.ExecInspectResult, err error) *MockClient_ContainerExecInspect_Call {
+	_c.Call.Return(execInspectResult, err)
+	return _c
+}
+
+func (_c *MockClient_ContainerExecInspect_Call) RunAndReturn(run func(ctx context.Context, execID string) (client.ExecInspectResult, error)) *MockClient_ContainerExecInspect_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ContainerInspect provides a mock function for the type MockClient
 func (_mock *MockClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
 	ret := _mock.Called(ctx, containerID)
@@ -773,6 +839,69 @@ func (_c *MockClient_ContainerRemove_Call) RunAndReturn(run func(ctx context.Con
 	return _c
 }
 
+// ContainerRename provides a mock function for the type MockClient
+func (_mock *MockClient) ContainerRename(ctx context.Context, containerID string, newName string) error {
+	ret := _mock.Called(ctx, containerID, newName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ContainerRename")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, containerID, newName)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockClient_ContainerRename_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ContainerRename'
+type MockClient_ContainerRename_Call struct {
+	*mock.Call
+}
+
+// ContainerRename is a helper method to define mock.On call
+//   - ctx context.Context
+//   - containerID string
+//   - newName string
+func (_e *MockClient_Expecter) ContainerRename(ctx interface{}, containerID interface{}, newName interface{}) *MockClient_ContainerRename_Call {
+	return &MockClient_ContainerRename_Call{Call: _e.mock.On("ContainerRename", ctx, containerID, newName)}
+}
+
+func (_c *MockClient_ContainerRename_Call) Run(run func(ctx context.Context, containerID string, newName string)) *MockClient_ContainerRename_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockClient_ContainerRename_Call) Return(err error) *MockClient_ContainerRename_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockClient_ContainerRename_Call) RunAndReturn(run func(ctx context.Context, containerID string, newName string) error) *MockClient_ContainerRename_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ContainerStart provides a mock function for the type MockClient
 func (_mock *MockClient) ContainerStart(ctx context.Context, containerID string, options client.ContainerStartOptions) error {
 	ret := _mock.Called(ctx, containerID, options)
@@ -836,6 +965,72 @@ func (_c *MockClient_ContainerStart_Call) RunAndReturn(run func(ctx context.Cont
 	return _c
 }
 
+// ContainerStats provides a mock function for the type MockClient
+func (_mock *MockClient) ContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error) {
+	ret := _mock.Called(ctx, containerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ContainerStats")
+	}
+
+	var r0 container.StatsResponse
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (container.StatsResponse, error)); ok {
+		return returnFunc(ctx, containerID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) container.StatsResponse); ok {
+		r0 = returnFunc(ctx, containerID)
+	} else {
+		r0 = ret.Get(0).(container.StatsResponse)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, containerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockClient_ContainerStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ContainerStats'
+type MockClient_ContainerStats_Call struct {
+	*mock.Call
+}
+
+// ContainerStats is a helper method to define mock.On call
+//   - ctx context.Context
+//   - containerID string
+func (_e *MockClient_Expecter) ContainerStats(ctx interface{}, containerID interface{}) *MockClient_ContainerStats_Call {
+	return &MockClient_ContainerStats_Call{Call: _e.mock.On("ContainerStats", ctx, containerID)}
+}
+
+func (_c *MockClient_ContainerStats_Call) Run(run func(ctx context.Context, containerID string)) *MockClient_ContainerStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockClient_ContainerStats_Call) Return(statsResponse container.StatsResponse, err error) *MockClient_ContainerStats_Call {
+	_c.Call.Return(statsResponse, err)
+	return _c
+}
+
+func (_c *MockClient_ContainerStats_Call) RunAndReturn(run func(ctx context.Context, containerID string) (container.StatsResponse, error)) *MockClient_ContainerStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ContainerStop provides a mock function for the type MockClient
 func (_mock *MockClient) ContainerStop(ctx context.Context, containerID string, options client.ContainerStopOptions) error {
 	ret := _mock.Called(ctx, containerID, options)
@@ -1469,6 +1664,75 @@ func (_c *MockClient_NetworkCreate_Call) RunAndReturn(run func(ctx context.Conte
 	return _c
 }
 
+// NetworkConnect provides a mock function for the type MockClient
+func (_mock *MockClient) NetworkConnect(ctx context.Context, networkID string, containerID string, config *network.EndpointSettings) error {
+	ret := _mock.Called(ctx, networkID, containerID, config)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NetworkConnect")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, *network.EndpointSettings) error); ok {
+		r0 = returnFunc(ctx, networkID, containerID, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockClient_NetworkConnect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NetworkConnect'
+type MockClient_NetworkConnect_Call struct {
+	*mock.Call
+}
+
+// NetworkConnect is a helper method to define mock.On call
+//   - ctx context.Context
+//   - networkID string
+//   - containerID string
+//   - config *network.EndpointSettings
+func (_e *MockClient_Expecter) NetworkConnect(ctx interface{}, networkID interface{}, containerID interface{}, config interface{}) *MockClient_NetworkConnect_Call {
+	return &MockClient_NetworkConnect_Call{Call: _e.mock.On("NetworkConnect", ctx, networkID, containerID, config)}
+}
+
+func (_c *MockClient_NetworkConnect_Call) Run(run func(ctx context.Context, networkID string, containerID string, config *network.EndpointSettings)) *MockClient_NetworkConnect_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 *network.EndpointSettings
+		if args[3] != nil {
+			arg3 = args[3].(*network.EndpointSettings)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockClient_NetworkConnect_Call) Return(err error) *MockClient_NetworkConnect_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockClient_NetworkConnect_Call) RunAndReturn(run func(ctx context.Context, networkID string, containerID string, config *network.EndpointSettings) error) *MockClient_NetworkConnect_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NetworkDisconnect provides a mock function for the type MockClient
 func (_mock *MockClient) NetworkDisconnect(ctx context.Context, networkID string, containerID string, force bool) error {
 	ret := _mock.Called(ctx, networkID, containerID, force)
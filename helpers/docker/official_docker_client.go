@@ -3,6 +3,7 @@ package docker
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -170,6 +171,20 @@ func (c *officialDockerClient) ContainerInspect(ctx context.Context, containerID
 	return res.Container, wrapError("ContainerInspect", err, started)
 }
 
+// ContainerStats returns a single sample of the resource usage of the container.
+func (c *officialDockerClient) ContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error) {
+	started := time.Now()
+	res, err := c.client.ContainerStats(ctx, containerID, client.ContainerStatsOptions{})
+	if err != nil {
+		return container.StatsResponse{}, wrapError("ContainerStats", err, started)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var stats container.StatsResponse
+	err = json.NewDecoder(res.Body).Decode(&stats)
+	return stats, wrapError("ContainerStats", err, started)
+}
+
 func (c *officialDockerClient) ContainerAttach(
 	ctx context.Context,
 	containerID string,
@@ -190,6 +205,12 @@ func (c *officialDockerClient) ContainerRemove(
 	return wrapError("ContainerRemove", err, started)
 }
 
+func (c *officialDockerClient) ContainerRename(ctx context.Context, containerID, newName string) error {
+	started := time.Now()
+	_, err := c.client.ContainerRename(ctx, containerID, client.ContainerRenameOptions{NewName: newName})
+	return wrapError("ContainerRename", err, started)
+}
+
 func (c *officialDockerClient) ContainerWait(
 	ctx context.Context,
 	containerID string,
@@ -229,6 +250,12 @@ func (c *officialDockerClient) ContainerExecAttach(
 	return res.HijackedResponse, wrapError("ContainerExecAttach", err, started)
 }
 
+func (c *officialDockerClient) ContainerExecInspect(ctx context.Context, execID string) (client.ExecInspectResult, error) {
+	started := time.Now()
+	res, err := c.client.ExecInspect(ctx, execID, client.ExecInspectOptions{})
+	return res, wrapError("ContainerExecInspect", err, started)
+}
+
 func (c *officialDockerClient) NetworkCreate(
 	ctx context.Context,
 	networkName string,
@@ -245,6 +272,19 @@ func (c *officialDockerClient) NetworkRemove(ctx context.Context, networkID stri
 	return wrapError("NetworkRemove", err, started)
 }
 
+func (c *officialDockerClient) NetworkConnect(
+	ctx context.Context,
+	networkID, containerID string,
+	config *network.EndpointSettings,
+) error {
+	started := time.Now()
+	_, err := c.client.NetworkConnect(ctx, networkID, client.NetworkConnectOptions{
+		Container:      containerID,
+		EndpointConfig: config,
+	})
+	return wrapError("NetworkConnect", err, started)
+}
+
 func (c *officialDockerClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
 	started := time.Now()
 	_, err := c.client.NetworkDisconnect(ctx, networkID, client.NetworkDisconnectOptions{
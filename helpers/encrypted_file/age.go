@@ -0,0 +1,52 @@
+package encrypted_file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const ageIntro = "age-encryption.org/v1"
+
+var ErrNoMatchingIdentity = errors.New("no identity matches any of the recipients")
+
+// ParseIdentities parses the identities in the age identity file format: one
+// AGE-SECRET-KEY-1... identity per line, with empty lines and lines starting
+// with # ignored.
+func ParseIdentities(r io.Reader) ([]age.Identity, error) {
+	return age.ParseIdentities(r)
+}
+
+func isAge(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return bytes.HasPrefix(data, []byte(ageIntro+"\n")) || bytes.HasPrefix(data, []byte(armor.Header))
+}
+
+// DecryptAge decrypts the age encrypted file, binary or armored, with the
+// first identity that matches one of its recipients.
+func DecryptAge(data []byte, identities []age.Identity) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); bytes.HasPrefix(trimmed, []byte(armor.Header)) {
+		r = armor.NewReader(bytes.NewReader(trimmed))
+	}
+
+	plaintext, err := age.Decrypt(r, identities...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrNoMatchingIdentity
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypting age file: %w", err)
+	}
+
+	data, err = io.ReadAll(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting age file: %w", err)
+	}
+
+	return data, nil
+}
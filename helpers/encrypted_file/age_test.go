@@ -0,0 +1,135 @@
+//go:build !integration
+
+package encrypted_file
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	return identity
+}
+
+// encryptAge encrypts the plaintext for the recipients with age, armored or
+// not.
+func encryptAge(t *testing.T, plaintext []byte, armored bool, recipients ...age.Recipient) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	var out io.Writer = &buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(&buf)
+		out = armorWriter
+	}
+
+	w, err := age.Encrypt(out, recipients...)
+	require.NoError(t, err)
+	_, err = w.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	if armorWriter != nil {
+		require.NoError(t, armorWriter.Close())
+	}
+
+	return buf.Bytes()
+}
+
+func TestParseIdentities(t *testing.T) {
+	identity := newTestIdentity(t)
+
+	identities, err := ParseIdentities(strings.NewReader("# created: 2024-01-01\n\n" + identity.String() + "\n"))
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	assert.Equal(t, identity.String(), identities[0].(*age.X25519Identity).String())
+
+	_, err = ParseIdentities(strings.NewReader("# no keys\n"))
+	assert.Error(t, err)
+
+	_, err = ParseIdentities(strings.NewReader(identity.String()[:len(identity.String())-1] + "Q"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestDecryptAge(t *testing.T) {
+	identity := newTestIdentity(t)
+	other := newTestIdentity(t)
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), 64*1024/8+3)
+
+	tests := map[string]struct {
+		plaintext  []byte
+		armor      bool
+		recipients []age.Recipient
+	}{
+		"binary": {
+			plaintext:  []byte("password: secret\n"),
+			recipients: []age.Recipient{identity.Recipient()},
+		},
+		"armored": {
+			plaintext:  []byte("password: secret\n"),
+			armor:      true,
+			recipients: []age.Recipient{identity.Recipient()},
+		},
+		"multiple recipients": {
+			plaintext:  []byte("password: secret\n"),
+			recipients: []age.Recipient{other.Recipient(), identity.Recipient()},
+		},
+		"multiple chunks": {
+			plaintext:  large,
+			recipients: []age.Recipient{identity.Recipient()},
+		},
+		"empty": {
+			plaintext:  []byte{},
+			recipients: []age.Recipient{identity.Recipient()},
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			data := encryptAge(t, tc.plaintext, tc.armor, tc.recipients...)
+			assert.True(t, isAge(data))
+
+			plaintext, err := DecryptAge(data, []age.Identity{identity})
+			require.NoError(t, err)
+			assert.Equal(t, string(tc.plaintext), string(plaintext))
+		})
+	}
+
+	t.Run("no matching identity", func(t *testing.T) {
+		data := encryptAge(t, []byte("secret"), false, other.Recipient())
+
+		_, err := DecryptAge(data, []age.Identity{identity})
+		assert.ErrorIs(t, err, ErrNoMatchingIdentity)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		data := encryptAge(t, []byte("secret"), false, identity.Recipient())
+		data[len(data)-1] ^= 1
+
+		_, err := DecryptAge(data, []age.Identity{identity})
+		assert.ErrorContains(t, err, "decrypting age file")
+	})
+
+	t.Run("truncated payload", func(t *testing.T) {
+		data := encryptAge(t, large, false, identity.Recipient())
+		data = data[:len(data)-32*1024]
+
+		_, err := DecryptAge(data, []age.Identity{identity})
+		assert.ErrorContains(t, err, "decrypting age file")
+	})
+}
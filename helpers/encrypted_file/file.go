@@ -0,0 +1,127 @@
+package encrypted_file
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"go.yaml.in/yaml/v3"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// File is a decrypted age or SOPS encrypted file holding a YAML or JSON
+// document.
+type File struct {
+	root *yaml.Node
+}
+
+// Decrypt decrypts the file with the identities. The file is either an age
+// encrypted YAML or JSON document, or a SOPS YAML or JSON file with age
+// recipients.
+func Decrypt(data []byte, identities []age.Identity) (*File, error) {
+	var doc yaml.Node
+
+	if isAge(data) {
+		plaintext, err := DecryptAge(data, identities)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := yaml.Unmarshal(plaintext, &doc); err != nil {
+			return nil, fmt.Errorf("parsing the decrypted document: %w", err)
+		}
+
+		return &File{root: documentRoot(&doc)}, nil
+	}
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing the SOPS document: %w", err)
+	}
+
+	metadata, ok := sopsDocument(&doc)
+	if !ok {
+		return nil, errors.New("neither an age nor a SOPS encrypted file")
+	}
+
+	if err := decryptSOPS(&doc, metadata, identities); err != nil {
+		return nil, err
+	}
+
+	return &File{root: documentRoot(&doc)}, nil
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		return doc.Content[0]
+	}
+
+	return doc
+}
+
+// Lookup returns the scalar value of the key. The key is either a top-level
+// key of the document, or a path of keys and list indexes separated by dots,
+// like database.password or users.0.token.
+func (f *File) Lookup(key string) (string, error) {
+	if f.root == nil || f.root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%w: %s: the document isn't a mapping", ErrKeyNotFound, key)
+	}
+
+	node := mappingValue(f.root, key)
+	if node == nil {
+		node = f.root
+		for _, part := range strings.Split(key, ".") {
+			node = child(node, part)
+			if node == nil {
+				return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+			}
+		}
+	}
+
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%s isn't a scalar value", key)
+	}
+	if node.Tag == "!!null" {
+		return "", nil
+	}
+
+	return node.Value, nil
+}
+
+func child(node *yaml.Node, part string) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return mappingValue(node, part)
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(part)
+		if err != nil || i < 0 || i >= len(node.Content) {
+			return nil
+		}
+		return node.Content[i]
+	}
+
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+//go:build !integration
+
+package encrypted_file
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SOPS test files are encrypted by the sops CLI, version 3.9.4, for the
+// identity of testdata/age_key.txt:
+//
+//	sops encrypt --age <recipient> --unencrypted-suffix _unencrypted secrets.yaml > testdata/secrets.sops.yaml
+//	sops encrypt --age <recipient> --unencrypted-suffix _unencrypted secrets.json > testdata/secrets.sops.json
+var sopsTestFiles = []string{
+	"testdata/secrets.sops.yaml",
+	"testdata/secrets.sops.json",
+}
+
+func readTestFile(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+
+	return data
+}
+
+func sopsTestIdentities(t *testing.T) []age.Identity {
+	t.Helper()
+
+	f, err := os.Open("testdata/age_key.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	identities, err := ParseIdentities(f)
+	require.NoError(t, err)
+
+	return identities
+}
+
+func TestDecrypt(t *testing.T) {
+	identity := newTestIdentity(t)
+
+	plain := `
+database:
+  password: s3cr3t
+  port: 5432
+  host_unencrypted: db.example.com
+tokens: [first, second]
+API_KEY: key
+`
+
+	files := map[string][]byte{
+		"age YAML": encryptAge(t, []byte(plain), true, identity.Recipient()),
+		"age JSON": encryptAge(t, []byte(`{"database": {"password": "s3cr3t", "port": 5432, "host_unencrypted": "db.example.com"}, "tokens": ["first", "second"], "API_KEY": "key"}`), false, identity.Recipient()),
+	}
+
+	for tn, data := range files {
+		t.Run(tn, func(t *testing.T) {
+			file, err := Decrypt(data, []age.Identity{identity})
+			require.NoError(t, err)
+
+			for key, expected := range map[string]string{
+				"database.password":         "s3cr3t",
+				"database.port":             "5432",
+				"database.host_unencrypted": "db.example.com",
+				"tokens.1":                  "second",
+				"API_KEY":                   "key",
+			} {
+				value, err := file.Lookup(key)
+				require.NoError(t, err, key)
+				assert.Equal(t, expected, value, key)
+			}
+
+			_, err = file.Lookup("database.user")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			_, err = file.Lookup("tokens.2")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			_, err = file.Lookup("database")
+			assert.EqualError(t, err, "database isn't a scalar value")
+		})
+	}
+
+	t.Run("not encrypted", func(t *testing.T) {
+		_, err := Decrypt([]byte(plain), []age.Identity{identity})
+		assert.EqualError(t, err, "neither an age nor a SOPS encrypted file")
+	})
+}
+
+func TestDecrypt_SOPS(t *testing.T) {
+	identities := sopsTestIdentities(t)
+
+	for _, name := range sopsTestFiles {
+		t.Run(name, func(t *testing.T) {
+			data := readTestFile(t, name)
+
+			file, err := Decrypt(data, identities)
+			require.NoError(t, err)
+
+			for key, expected := range map[string]string{
+				"database.password":         "s3cr3t",
+				"database.port":             "5432",
+				"database.ratio":            "0.5",
+				"database.enabled":          "true",
+				"database.host_unencrypted": "db.example.com",
+				"tokens.0":                  "first",
+				"tokens.1":                  "second",
+				"servers.0.name":            "primary",
+				"servers.0.addresses.1":     "10.0.0.2",
+				"servers.1.port":            "5433",
+				"matrix.0.1":                "b",
+				"matrix.1.0":                "c",
+				"API_KEY":                   "key",
+			} {
+				value, err := file.Lookup(key)
+				require.NoError(t, err, key)
+				assert.Equal(t, expected, value, key)
+			}
+
+			_, err = file.Lookup("sops")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			_, err = file.Lookup("database")
+			assert.EqualError(t, err, "database isn't a scalar value")
+		})
+	}
+}
+
+func TestDecrypt_SOPSTampered(t *testing.T) {
+	identities := sopsTestIdentities(t)
+	data := readTestFile(t, "testdata/secrets.sops.yaml")
+
+	t.Run("without matching identity", func(t *testing.T) {
+		_, err := Decrypt(data, []age.Identity{newTestIdentity(t)})
+		assert.ErrorIs(t, err, ErrNoMatchingIdentity)
+	})
+
+	t.Run("value moved to another key", func(t *testing.T) {
+		tampered := strings.Replace(string(data), "API_KEY:", "OTHER_KEY:", 1)
+
+		_, err := Decrypt([]byte(tampered), identities)
+		assert.EqualError(t, err, "decrypting OTHER_KEY: authentication failed")
+	})
+
+	t.Run("value removed", func(t *testing.T) {
+		lines := strings.Split(string(data), "\n")
+		lines = slices.DeleteFunc(lines, func(line string) bool { return strings.HasPrefix(line, "API_KEY:") })
+
+		_, err := Decrypt([]byte(strings.Join(lines, "\n")), identities)
+		assert.EqualError(t, err, "SOPS MAC mismatch: the file was modified")
+	})
+
+	t.Run("list items swapped", func(t *testing.T) {
+		lines := strings.Split(string(data), "\n")
+		i := slices.Index(lines, "tokens:")
+		require.NotEqual(t, -1, i)
+		lines[i+1], lines[i+2] = lines[i+2], lines[i+1]
+
+		_, err := Decrypt([]byte(strings.Join(lines, "\n")), identities)
+		assert.EqualError(t, err, "SOPS MAC mismatch: the file was modified")
+	})
+
+	t.Run("unencrypted value changed", func(t *testing.T) {
+		tampered := strings.Replace(string(data), "host_unencrypted: db.example.com", "host_unencrypted: attacker.example.com", 1)
+
+		_, err := Decrypt([]byte(tampered), identities)
+		assert.EqualError(t, err, "SOPS MAC mismatch: the file was modified")
+	})
+
+	t.Run("value stored in clear", func(t *testing.T) {
+		tampered := regexp.MustCompile(`password: ENC\[[^\]]*\]`).ReplaceAll(data, []byte("password: injected"))
+
+		_, err := Decrypt(tampered, identities)
+		assert.EqualError(t, err, "database.password isn't encrypted")
+	})
+
+	t.Run("without MAC", func(t *testing.T) {
+		tampered := regexp.MustCompile(`(?m)^    mac: .*$`).ReplaceAll(data, nil)
+
+		_, err := Decrypt(tampered, identities)
+		assert.EqualError(t, err, "the SOPS file has no encrypted MAC")
+	})
+}
+
+func TestFile_LookupTopLevelKeyWithDots(t *testing.T) {
+	identity := newTestIdentity(t)
+
+	data := encryptAge(t, []byte("a.b: top-level\na:\n  b: nested\nempty: null\n"), false, identity.Recipient())
+
+	file, err := Decrypt(data, []age.Identity{identity})
+	require.NoError(t, err)
+
+	value, err := file.Lookup("a.b")
+	require.NoError(t, err)
+	assert.Equal(t, "top-level", value)
+
+	value, err = file.Lookup("empty")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+}
@@ -0,0 +1,372 @@
+package encrypted_file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"go.yaml.in/yaml/v3"
+)
+
+// SOPS files are YAML or JSON documents where each value is encrypted with
+// AES-GCM under a data key, itself encrypted for each recipient in the sops
+// metadata key. The metadata holds a MAC of all the values, encrypted with
+// the data key, so that values can't be removed, added or swapped. Only age
+// recipients are supported, and encrypted comments aren't.
+
+const sopsMetadataKey = "sops"
+
+var sopsValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+type sopsMetadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	KeyGroups []any `yaml:"key_groups"`
+
+	LastModified     string `yaml:"lastmodified"`
+	MAC              string `yaml:"mac"`
+	MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+
+	UnencryptedSuffix string `yaml:"unencrypted_suffix"`
+	EncryptedSuffix   string `yaml:"encrypted_suffix"`
+	UnencryptedRegex  string `yaml:"unencrypted_regex"`
+	EncryptedRegex    string `yaml:"encrypted_regex"`
+}
+
+// isEncrypted returns whether SOPS encrypts the value at the path, following
+// the rules of the metadata.
+func (m *sopsMetadata) isEncrypted(path []string) (bool, error) {
+	encrypted := true
+
+	if m.UnencryptedSuffix != "" {
+		for _, key := range path {
+			if strings.HasSuffix(key, m.UnencryptedSuffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+
+	if m.EncryptedSuffix != "" {
+		encrypted = false
+		for _, key := range path {
+			if strings.HasSuffix(key, m.EncryptedSuffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+
+	if m.UnencryptedRegex != "" {
+		re, err := regexp.Compile(m.UnencryptedRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid unencrypted_regex: %w", err)
+		}
+		for _, key := range path {
+			if re.MatchString(key) {
+				encrypted = false
+				break
+			}
+		}
+	}
+
+	if m.EncryptedRegex != "" {
+		re, err := regexp.Compile(m.EncryptedRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid encrypted_regex: %w", err)
+		}
+		encrypted = false
+		for _, key := range path {
+			if re.MatchString(key) {
+				encrypted = true
+				break
+			}
+		}
+	}
+
+	return encrypted, nil
+}
+
+// sopsDocument returns the metadata of the document when it's a SOPS file.
+func sopsDocument(doc *yaml.Node) (*sopsMetadata, bool) {
+	root := documentRoot(doc)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != sopsMetadataKey {
+			continue
+		}
+
+		var metadata sopsMetadata
+		if err := root.Content[i+1].Decode(&metadata); err != nil {
+			return nil, false
+		}
+
+		return &metadata, true
+	}
+
+	return nil, false
+}
+
+// decryptSOPS decrypts the values of the SOPS document in place, checks
+// them against the MAC of the document, and removes its metadata. Values
+// that the metadata requires to be encrypted but aren't are rejected.
+func decryptSOPS(doc *yaml.Node, metadata *sopsMetadata, identities []age.Identity) error {
+	dataKey, err := sopsDataKey(metadata, identities)
+	if err != nil {
+		return err
+	}
+
+	root := documentRoot(doc)
+
+	content := make([]*yaml.Node, 0, len(root.Content))
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != sopsMetadataKey {
+			content = append(content, root.Content[i], root.Content[i+1])
+		}
+	}
+	root.Content = content
+
+	d := &sopsDecrypter{metadata: metadata, dataKey: dataKey, mac: sha512.New()}
+	if err := d.decrypt(root, nil); err != nil {
+		return err
+	}
+
+	return d.verifyMAC()
+}
+
+func sopsDataKey(metadata *sopsMetadata, identities []age.Identity) ([]byte, error) {
+	if len(metadata.KeyGroups) > 0 {
+		return nil, errors.New("SOPS key groups aren't supported")
+	}
+
+	for _, recipient := range metadata.Age {
+		dataKey, err := DecryptAge([]byte(recipient.Enc), identities)
+		if errors.Is(err, ErrNoMatchingIdentity) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decrypting the SOPS data key for %s: %w", recipient.Recipient, err)
+		}
+
+		return dataKey, nil
+	}
+
+	return nil, fmt.Errorf("decrypting the SOPS data key: %w", ErrNoMatchingIdentity)
+}
+
+type sopsDecrypter struct {
+	metadata *sopsMetadata
+	dataKey  []byte
+	mac      hash.Hash
+}
+
+// decrypt decrypts the values under the node, and adds them to the MAC in
+// the order of the document. SOPS authenticates each value with the path of
+// its mapping keys, list indexes excluded.
+func (d *sopsDecrypter) decrypt(node *yaml.Node, path []string) error {
+	if strings.Contains(node.HeadComment+node.LineComment+node.FootComment, "ENC[") {
+		return errors.New("SOPS files with encrypted comments aren't supported")
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := append(path[:len(path):len(path)], node.Content[i].Value)
+			if err := d.decrypt(node.Content[i+1], keyPath); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := d.decrypt(item, path); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return d.decryptScalar(node, path)
+	case yaml.AliasNode:
+		return fmt.Errorf("%s: aliases aren't supported in SOPS files", strings.Join(path, "."))
+	}
+
+	return nil
+}
+
+func (d *sopsDecrypter) decryptScalar(node *yaml.Node, path []string) error {
+	encrypted, err := d.metadata.isEncrypted(path)
+	if err != nil {
+		return err
+	}
+
+	if !encrypted || node.Tag == "!!null" {
+		if !d.metadata.MACOnlyEncrypted {
+			d.mac.Write(sopsPlainMACBytes(node))
+		}
+		return nil
+	}
+
+	m := sopsValue.FindStringSubmatch(node.Value)
+	if m == nil {
+		return fmt.Errorf("%s isn't encrypted", strings.Join(path, "."))
+	}
+
+	value, err := decryptSOPSValue(m[1], m[2], m[3], strings.Join(path, ":")+":", d.dataKey)
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", strings.Join(path, "."), err)
+	}
+
+	macBytes, err := sopsMACBytes(value, m[4])
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", strings.Join(path, "."), err)
+	}
+	d.mac.Write(macBytes)
+
+	// SOPS encrypts booleans the way Python writes them
+	if m[4] == "bool" {
+		value = strings.ToLower(value)
+	}
+
+	node.Value = value
+	node.Tag = sopsValueTag(m[4])
+	node.Style = 0
+
+	return nil
+}
+
+// verifyMAC checks the MAC of the decrypted values against the MAC of the
+// metadata, which SOPS encrypts with the last modification time of the file.
+func (d *sopsDecrypter) verifyMAC() error {
+	m := sopsValue.FindStringSubmatch(d.metadata.MAC)
+	if m == nil {
+		return errors.New("the SOPS file has no encrypted MAC")
+	}
+
+	lastModified, err := time.Parse(time.RFC3339, d.metadata.LastModified)
+	if err != nil {
+		return fmt.Errorf("invalid SOPS lastmodified: %w", err)
+	}
+
+	expected, err := decryptSOPSValue(m[1], m[2], m[3], lastModified.Format(time.RFC3339), d.dataKey)
+	if err != nil {
+		return fmt.Errorf("decrypting the SOPS MAC: %w", err)
+	}
+
+	actual := fmt.Sprintf("%X", d.mac.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) != 1 {
+		return errors.New("SOPS MAC mismatch: the file was modified")
+	}
+
+	return nil
+}
+
+// sopsMACBytes returns the bytes SOPS adds to the MAC for a decrypted value
+// of the type.
+func sopsMACBytes(value, valueType string) ([]byte, error) {
+	switch valueType {
+	case "int":
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed int value: %w", err)
+		}
+		return []byte(strconv.Itoa(i)), nil
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed float value: %w", err)
+		}
+		return []byte(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bool value: %w", err)
+		}
+		return sopsBoolBytes(b), nil
+	default:
+		return []byte(value), nil
+	}
+}
+
+// sopsPlainMACBytes returns the bytes SOPS adds to the MAC for a value
+// stored in clear.
+func sopsPlainMACBytes(node *yaml.Node) []byte {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return []byte(node.Value)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int:
+		return []byte(strconv.Itoa(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		return sopsBoolBytes(v)
+	default:
+		return []byte(node.Value)
+	}
+}
+
+func sopsBoolBytes(b bool) []byte {
+	if b {
+		return []byte("True")
+	}
+
+	return []byte("False")
+}
+
+func decryptSOPSValue(data, iv, tag, additionalData string, dataKey []byte) (string, error) {
+	var decoded [3][]byte
+	for i, s := range []string{data, iv, tag} {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("malformed value: %w", err)
+		}
+		decoded[i] = b
+	}
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(decoded[1]))
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := gcm.Open(nil, decoded[1], append(decoded[0], decoded[2]...), []byte(additionalData))
+	if err != nil {
+		return "", errors.New("authentication failed")
+	}
+
+	return string(plaintext), nil
+}
+
+func sopsValueTag(valueType string) string {
+	switch valueType {
+	case "int":
+		return "!!int"
+	case "float":
+		return "!!float"
+	case "bool":
+		return "!!bool"
+	default:
+		return "!!str"
+	}
+}
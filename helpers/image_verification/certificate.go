@@ -0,0 +1,378 @@
+package image_verification
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+)
+
+var (
+	// Fulcio certificate extensions holding the OIDC issuer of the signer
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// trustRoot holds the certificate authorities issuing the signing
+// certificates of keyless signatures, and the keys of the transparency logs
+// recording them.
+type trustRoot struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	// rekorKeys are indexed by log ID: the hex SHA-256 of the DER public key
+	rekorKeys map[string]crypto.PublicKey
+	// rekor looks up the inclusion proofs of the log entries, when the
+	// transparency log can be reached
+	rekor *rekorClient
+}
+
+func newTrustRoot(fulcioRoots, rekorPublicKeys []string) (*trustRoot, error) {
+	t := &trustRoot{
+		roots:         x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+		rekorKeys:     map[string]crypto.PublicKey{},
+	}
+
+	for _, value := range fulcioRoots {
+		certs, err := loadCertificates(value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cert := range certs {
+			if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+				t.roots.AddCert(cert)
+			} else {
+				t.intermediates.AddCert(cert)
+			}
+		}
+	}
+
+	for _, value := range rekorPublicKeys {
+		key, err := loadPublicKey(value)
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		logID := sha256.Sum256(der)
+		t.rekorKeys[hex.EncodeToString(logID[:])] = key
+	}
+
+	return t, nil
+}
+
+// verifySignature verifies the signature of the data like cosign signs it:
+// over the SHA-256 digest of the data for ECDSA and RSA keys, and over the
+// data itself for Ed25519 keys.
+func verifySignature(key crypto.PublicKey, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, data, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return errors.New("invalid signature")
+}
+
+// signed is a signature, with the signing certificate and transparency log
+// entry of keyless signatures.
+type signed struct {
+	// data is the signed data
+	data []byte
+	// payload is the data recorded in the transparency log, which is the
+	// signed data itself unless it's a DSSE envelope
+	payload   []byte
+	signature []byte
+
+	certificate string
+	chain       string
+	bundle      string
+}
+
+// verify verifies the signature with the keys and identities of the rule,
+// and returns who signed it.
+func (s *signed) verify(ctx context.Context, r *rule, trust *trustRoot) (string, error) {
+	for _, key := range r.keys {
+		if verifySignature(key, s.data, s.signature) == nil {
+			return "trusted public key", nil
+		}
+	}
+
+	if s.certificate == "" || len(r.identities) == 0 {
+		return "", errors.New("not signed by a trusted public key")
+	}
+
+	return s.verifyCertificate(ctx, r, trust)
+}
+
+func (s *signed) verifyCertificate(ctx context.Context, r *rule, trust *trustRoot) (string, error) {
+	block, _ := pem.Decode([]byte(s.certificate))
+	if block == nil {
+		return "", errors.New("malformed signing certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("malformed signing certificate: %w", err)
+	}
+
+	if err := verifySignature(cert.PublicKey, s.data, s.signature); err != nil {
+		return "", fmt.Errorf("signing certificate: %w", err)
+	}
+
+	// keyless signing certificates are short-lived: they're checked at the
+	// time the signature was recorded in the transparency log
+	integratedTime, err := s.verifyBundle(ctx, cert, trust)
+	if err != nil {
+		return "", err
+	}
+
+	intermediates := trust.intermediates.Clone()
+	for rest := []byte(s.chain); ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil {
+			intermediates.AddCert(c)
+		}
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         trust.roots,
+		Intermediates: intermediates,
+		CurrentTime:   integratedTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return "", fmt.Errorf("untrusted signing certificate: %w", err)
+	}
+
+	issuer := certificateIssuer(cert)
+	subjects := certificateSubjects(cert)
+	for _, id := range r.identities {
+		if id.matches(issuer, subjects) {
+			return id.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("signing certificate identity %v (issuer %s) isn't trusted", subjects, issuer)
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV1) {
+			return string(ext.Value)
+		}
+	}
+
+	return ""
+}
+
+func certificateSubjects(cert *x509.Certificate) []string {
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+
+	return subjects
+}
+
+// rekorBundle is the transparency log entry of a signature, with the signed
+// entry timestamp: the promise of the log to include the entry.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	// Payload is kept raw: the timestamp is signed over its canonical JSON
+	Payload json.RawMessage `json:"Payload"`
+}
+
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// rekorEntry is the part of the hashedrekord and intoto entries of the
+// transparency log that binds them to the signature.
+type rekorEntry struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		// hashedrekord
+		Data struct {
+			Hash rekorHash `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content []byte `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+
+		// intoto
+		Content struct {
+			PayloadHash rekorHash `json:"payloadHash"`
+			Envelope    struct {
+				Signatures []struct {
+					PublicKey []byte `json:"publicKey"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+type rekorHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// verifyBundle verifies that the signature was recorded in a trusted
+// transparency log, and returns when. The inclusion proof of the entry is
+// verified when the log can be reached, otherwise only its signed entry
+// timestamp is.
+func (s *signed) verifyBundle(ctx context.Context, cert *x509.Certificate, trust *trustRoot) (time.Time, error) {
+	if s.bundle == "" {
+		return time.Time{}, errors.New("keyless signature without transparency log entry")
+	}
+
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(s.bundle), &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("malformed transparency log entry: %w", err)
+	}
+
+	var payload rekorPayload
+	if err := json.Unmarshal(bundle.Payload, &payload); err != nil {
+		return time.Time{}, fmt.Errorf("malformed transparency log entry: %w", err)
+	}
+
+	key, ok := trust.rekorKeys[payload.LogID]
+	if !ok {
+		return time.Time{}, fmt.Errorf("transparency log %s isn't trusted", payload.LogID)
+	}
+
+	canonical, err := jsoncanonicalizer.Transform(bundle.Payload)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed transparency log entry: %w", err)
+	}
+	if err := verifySignature(key, canonical, bundle.SignedEntryTimestamp); err != nil {
+		return time.Time{}, fmt.Errorf("transparency log entry: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed transparency log entry: %w", err)
+	}
+
+	var entry rekorEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("malformed transparency log entry: %w", err)
+	}
+
+	if err := s.matchEntry(entry, cert); err != nil {
+		return time.Time{}, fmt.Errorf("transparency log entry doesn't match the signature: %w", err)
+	}
+
+	if trust.rekor != nil {
+		if err := s.verifyLogEntry(ctx, trust.rekor, payload, body, key); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return time.Unix(payload.IntegratedTime, 0), nil
+}
+
+// verifyLogEntry looks up the entry of the bundle in the transparency log,
+// and verifies that the log includes it.
+func (s *signed) verifyLogEntry(ctx context.Context, rekor *rekorClient, payload rekorPayload, body []byte, key crypto.PublicKey) error {
+	entry, err := rekor.entry(ctx, payload.LogIndex)
+	if err != nil {
+		return fmt.Errorf("looking up the transparency log entry: %w", err)
+	}
+
+	if entry.Body != payload.Body || entry.IntegratedTime != payload.IntegratedTime || entry.LogID != payload.LogID {
+		return errors.New("transparency log entry differs from the signature bundle")
+	}
+
+	if err := verifyInclusion(entry, body, key); err != nil {
+		return fmt.Errorf("transparency log entry: %w", err)
+	}
+
+	return nil
+}
+
+func (s *signed) matchEntry(entry rekorEntry, cert *x509.Certificate) error {
+	var (
+		hash      rekorHash
+		publicKey [][]byte
+	)
+
+	switch entry.Kind {
+	case "hashedrekord":
+		if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(s.signature) {
+			return errors.New("different signature")
+		}
+		hash = entry.Spec.Data.Hash
+		publicKey = [][]byte{entry.Spec.Signature.PublicKey.Content}
+	case "intoto":
+		hash = entry.Spec.Content.PayloadHash
+		for _, signature := range entry.Spec.Content.Envelope.Signatures {
+			publicKey = append(publicKey, signature.PublicKey)
+		}
+	default:
+		return fmt.Errorf("unsupported entry kind %q", entry.Kind)
+	}
+
+	digest := sha256.Sum256(s.payload)
+	if hash.Algorithm != "sha256" || hash.Value != hex.EncodeToString(digest[:]) {
+		return errors.New("different payload")
+	}
+
+	for _, key := range publicKey {
+		if block, _ := pem.Decode(key); block != nil && bytes.Equal(block.Bytes, cert.Raw) {
+			return nil
+		}
+	}
+
+	return errors.New("different signing certificate")
+}
@@ -0,0 +1,227 @@
+package image_verification
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// cosign stores the signatures and attestations of an image in the image
+// repository, as images tagged with the digest of the image and the .sig and
+// .att suffixes. Each layer of these images is a signature or an
+// attestation, with the signature material in the layer annotations.
+
+const (
+	signatureTagSuffix   = ".sig"
+	attestationTagSuffix = ".att"
+
+	signatureAnnotation   = "dev.cosignproject.cosign/signature"
+	certificateAnnotation = "dev.sigstore.cosign/certificate"
+	chainAnnotation       = "dev.sigstore.cosign/chain"
+	bundleAnnotation      = "dev.sigstore.cosign/bundle"
+
+	simpleSigningType = "cosign container image signature"
+	inTotoPayloadType = "application/vnd.in-toto+json"
+
+	predicateTypeAnnotation = "predicateType"
+
+	// maxLayerSize bounds the size of the signature and attestation layers
+	// read from the registry
+	maxLayerSize = 4 * 1024 * 1024
+)
+
+var errNotFound = errors.New("not found")
+
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     []byte `json:"payload"`
+	Signatures  []struct {
+		Sig []byte `json:"sig"`
+	} `json:"signatures"`
+}
+
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// cosignLayer is a layer of a cosign signature or attestation image.
+type cosignLayer struct {
+	data        []byte
+	annotations map[string]string
+}
+
+// fetchCosignLayers returns the layers of the signature or attestation image
+// of the digest, or errNotFound when there's none.
+func fetchCosignLayers(repo name.Repository, digest v1.Hash, suffix string, options []remote.Option) ([]cosignLayer, error) {
+	tag := repo.Tag(fmt.Sprintf("%s-%s%s", digest.Algorithm, digest.Hex, suffix))
+
+	img, err := remote.Image(tag, options...)
+	if isNotFound(err) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", tag, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", tag, err)
+	}
+
+	layers := make([]cosignLayer, 0, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		if desc.Size > maxLayerSize {
+			return nil, fmt.Errorf("fetching %s: layer %s is too large", tag, desc.Digest)
+		}
+
+		data, err := readLayer(img, desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: layer %s: %w", tag, desc.Digest, err)
+		}
+
+		layers = append(layers, cosignLayer{data: data, annotations: desc.Annotations})
+	}
+
+	return layers, nil
+}
+
+func readLayer(img v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the layers are stored uncompressed: Compressed returns them as is,
+	// and verifies their digest
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxLayerSize))
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, diagnostic := range terr.Errors {
+		if diagnostic.Code == transport.ManifestUnknownErrorCode || diagnostic.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signature returns the signature of the layer of a signature image, and
+// checks that it's for the image digest.
+func (l cosignLayer) signature(digest v1.Hash) (*signed, error) {
+	signature, err := base64.StdEncoding.DecodeString(l.annotations[signatureAnnotation])
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("malformed signature")
+	}
+
+	var payload simpleSigning
+	if err := json.Unmarshal(l.data, &payload); err != nil {
+		return nil, fmt.Errorf("malformed signature payload: %w", err)
+	}
+	if payload.Critical.Type != simpleSigningType {
+		return nil, fmt.Errorf("unsupported signature type %q", payload.Critical.Type)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest.String() {
+		return nil, fmt.Errorf("signature of another image %s", payload.Critical.Image.DockerManifestDigest)
+	}
+
+	return l.signed(l.data, l.data, signature), nil
+}
+
+// attestations returns the signatures of the DSSE envelope of an attestation
+// image layer, and checks that the in-toto statement is about the image
+// digest.
+func (l cosignLayer) attestations(digest v1.Hash) (string, []*signed, error) {
+	// the predicate type is also in the layer annotations, to report
+	// malformed attestations
+	predicateType := l.annotations[predicateTypeAnnotation]
+
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(l.data, &envelope); err != nil {
+		return predicateType, nil, fmt.Errorf("malformed attestation: %w", err)
+	}
+	if envelope.PayloadType != inTotoPayloadType {
+		return predicateType, nil, fmt.Errorf("unsupported attestation payload type %q", envelope.PayloadType)
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(envelope.Payload, &statement); err != nil {
+		return predicateType, nil, fmt.Errorf("malformed attestation statement: %w", err)
+	}
+
+	subject := false
+	for _, s := range statement.Subject {
+		if s.Digest[digest.Algorithm] == digest.Hex {
+			subject = true
+			break
+		}
+	}
+	if !subject {
+		return statement.PredicateType, nil, fmt.Errorf("%s attestation of another image", statement.PredicateType)
+	}
+
+	pae := dssePAE(envelope.PayloadType, envelope.Payload)
+
+	signatures := make([]*signed, 0, len(envelope.Signatures))
+	for _, s := range envelope.Signatures {
+		signatures = append(signatures, l.signed(pae, envelope.Payload, s.Sig))
+	}
+
+	return statement.PredicateType, signatures, nil
+}
+
+func (l cosignLayer) signed(data, payload, signature []byte) *signed {
+	return &signed{
+		data:        data,
+		payload:     payload,
+		signature:   signature,
+		certificate: l.annotations[certificateAnnotation],
+		chain:       l.annotations[chainAnnotation],
+		bundle:      l.annotations[bundleAnnotation],
+	}
+}
+
+// dssePAE is the pre-authentication encoding of the DSSE envelope, which its
+// signatures are over.
+func dssePAE(payloadType string, payload []byte) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+
+	return []byte(b.String())
+}
@@ -0,0 +1,242 @@
+package image_verification
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/distribution/reference"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+type rule struct {
+	images       []string
+	mode         common.ImageVerificationMode
+	keys         []crypto.PublicKey
+	identities   []identity
+	attestations []string
+}
+
+type identity struct {
+	issuer        string
+	subject       string
+	subjectRegexp *regexp.Regexp
+}
+
+func (i identity) String() string {
+	subject := i.subject
+	if i.subjectRegexp != nil {
+		subject = "~" + i.subjectRegexp.String()
+	}
+
+	return fmt.Sprintf("%s (issuer %s)", subject, i.issuer)
+}
+
+func (i identity) matches(issuer string, subjects []string) bool {
+	if issuer != i.issuer {
+		return false
+	}
+
+	for _, subject := range subjects {
+		if i.subject != "" && subject == i.subject {
+			return true
+		}
+		if i.subjectRegexp != nil && i.subjectRegexp.MatchString(subject) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns whether a glob of the rule matches one of the names of the
+// image.
+func (r *rule) matches(names []string) bool {
+	for _, glob := range r.images {
+		for _, name := range names {
+			if ok, _ := doublestar.Match(glob, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// imageNames returns the names of the image the globs of the rules are
+// matched against: its full name, like docker.io/library/alpine:3.20, and its
+// familiar name, like alpine:3.20, whichever way the job spells it.
+func imageNames(image string) []string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return []string{image}
+	}
+
+	return []string{named.String(), reference.FamiliarString(named)}
+}
+
+// normalizeImageGlob returns the full name of the image when the glob has no
+// wildcards, like docker.io/library/alpine for library/alpine. In a pattern,
+// the index.docker.io registry is spelled docker.io, like in full names.
+func normalizeImageGlob(glob string) string {
+	if named, err := reference.ParseNormalizedNamed(glob); err == nil {
+		return named.String()
+	}
+
+	if rest, ok := strings.CutPrefix(glob, "index.docker.io/"); ok {
+		return "docker.io/" + rest
+	}
+
+	return glob
+}
+
+func newRule(config *common.ImageVerificationConfig, c common.ImageVerificationRule) (*rule, error) {
+	if len(c.Images) == 0 {
+		return nil, errors.New("no images")
+	}
+	images := make([]string, 0, len(c.Images))
+	for _, glob := range c.Images {
+		if !doublestar.ValidatePattern(glob) {
+			return nil, fmt.Errorf("invalid image glob %q", glob)
+		}
+		images = append(images, normalizeImageGlob(glob))
+	}
+
+	r := &rule{
+		images:       images,
+		mode:         c.GetMode(config),
+		attestations: c.Attestations,
+	}
+
+	switch r.mode {
+	case common.ImageVerificationModeEnforce, common.ImageVerificationModeWarn:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", r.mode)
+	}
+
+	for _, key := range c.PublicKeys {
+		pub, err := loadPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		r.keys = append(r.keys, pub)
+	}
+
+	for _, i := range c.Identities {
+		id, err := newIdentity(i)
+		if err != nil {
+			return nil, err
+		}
+		r.identities = append(r.identities, id)
+	}
+
+	if len(r.keys) == 0 && len(r.identities) == 0 {
+		return nil, errors.New("no public keys or identities to verify the signatures with")
+	}
+
+	return r, nil
+}
+
+func newIdentity(c common.ImageVerificationIdentity) (identity, error) {
+	id := identity{issuer: c.Issuer, subject: c.Subject}
+
+	if c.Issuer == "" {
+		return id, errors.New("identity without issuer")
+	}
+	if c.Subject == "" && c.SubjectRegexp == "" {
+		return id, fmt.Errorf("identity of issuer %s without subject", c.Issuer)
+	}
+
+	if c.SubjectRegexp != "" {
+		re, err := regexp.Compile(c.SubjectRegexp)
+		if err != nil {
+			return id, fmt.Errorf("invalid subject_regexp: %w", err)
+		}
+		id.subjectRegexp = re
+	}
+
+	return id, nil
+}
+
+// readPEM returns the PEM blocks of the value, which is either PEM data or
+// the path to a PEM file.
+func readPEM(value string) ([]*pem.Block, error) {
+	data := []byte(value)
+	if !strings.Contains(value, "-----BEGIN ") {
+		var err error
+		if data, err = os.ReadFile(value); err != nil {
+			return nil, err
+		}
+	}
+
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no PEM data in %s", pemSource(value))
+	}
+
+	return blocks, nil
+}
+
+func pemSource(value string) string {
+	if strings.Contains(value, "-----BEGIN ") {
+		return "inline PEM"
+	}
+
+	return value
+}
+
+func loadPublicKey(value string) (crypto.PublicKey, error) {
+	blocks, err := readPEM(value)
+	if err != nil {
+		return nil, fmt.Errorf("loading public key: %w", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(blocks[0].Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("loading public key from %s: %w", pemSource(value), err)
+	}
+
+	switch pub.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("loading public key from %s: unsupported key type %T", pemSource(value), pub)
+	}
+}
+
+func loadCertificates(value string) ([]*x509.Certificate, error) {
+	blocks, err := readPEM(value)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificates: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificates from %s: %w", pemSource(value), err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,175 @@
+package image_verification
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+const (
+	rekorTimeout = 30 * time.Second
+
+	// rekorResponseLimit bounds the size of the log entries read from the
+	// transparency log
+	rekorResponseLimit = 1 << 20
+)
+
+// rekorClient looks up the entries of the transparency log with their
+// inclusion proof.
+type rekorClient struct {
+	url    string
+	client *http.Client
+}
+
+func newRekorClient(rawURL string) (*rekorClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid rekor_url %q", rawURL)
+	}
+
+	return &rekorClient{
+		url:    strings.TrimSuffix(u.String(), "/"),
+		client: &http.Client{Timeout: rekorTimeout},
+	}, nil
+}
+
+// rekorLogEntry is a transparency log entry, as the log API returns it.
+type rekorLogEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		InclusionProof *rekorInclusionProof `json:"inclusionProof"`
+	} `json:"verification"`
+}
+
+type rekorInclusionProof struct {
+	Checkpoint string   `json:"checkpoint"`
+	Hashes     []string `json:"hashes"`
+	// LogIndex is the index of the entry in the tree of the log shard,
+	// which is different from the global index of sharded logs
+	LogIndex int64  `json:"logIndex"`
+	RootHash string `json:"rootHash"`
+	TreeSize int64  `json:"treeSize"`
+}
+
+func (c *rekorClient) entry(ctx context.Context, logIndex int64) (*rekorLogEntry, error) {
+	endpoint := c.url + "/api/v1/log/entries?logIndex=" + strconv.FormatInt(logIndex, 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+
+	// the entries are indexed by their UUID
+	var entries map[string]rekorLogEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, rekorResponseLimit)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decoding the log entry: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.LogIndex == logIndex {
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("log entry %d not found", logIndex)
+}
+
+// verifyInclusion verifies that the entry with the body is included in the
+// tree of the log, and that the log signed the checkpoint of the tree.
+func verifyInclusion(entry *rekorLogEntry, body []byte, key crypto.PublicKey) error {
+	p := entry.Verification.InclusionProof
+	if p == nil {
+		return errors.New("log entry without inclusion proof")
+	}
+
+	if p.LogIndex < 0 || p.TreeSize <= p.LogIndex {
+		return fmt.Errorf("malformed inclusion proof: index %d in a tree of size %d", p.LogIndex, p.TreeSize)
+	}
+
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil {
+		return fmt.Errorf("malformed inclusion proof: %w", err)
+	}
+
+	hashes := make([][]byte, 0, len(p.Hashes))
+	for _, h := range p.Hashes {
+		hash, err := hex.DecodeString(h)
+		if err != nil {
+			return fmt.Errorf("malformed inclusion proof: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	leaf := rfc6962.DefaultHasher.HashLeaf(body)
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, uint64(p.LogIndex), uint64(p.TreeSize), leaf, hashes, root); err != nil {
+		return fmt.Errorf("invalid inclusion proof: %w", err)
+	}
+
+	return verifyCheckpoint(p.Checkpoint, key, p.TreeSize, root)
+}
+
+// verifyCheckpoint verifies that the checkpoint, a signed note of the log
+// origin, tree size and root hash, is of the tree and signed by the log.
+func verifyCheckpoint(checkpoint string, key crypto.PublicKey, treeSize int64, root []byte) error {
+	text, signatures, ok := strings.Cut(checkpoint, "\n\n")
+	if !ok {
+		return errors.New("malformed checkpoint")
+	}
+	// the note signatures are over the text with its final newline
+	text += "\n"
+
+	lines := strings.Split(text, "\n")
+	if len(lines) < 4 {
+		return errors.New("malformed checkpoint")
+	}
+
+	if lines[1] != strconv.FormatInt(treeSize, 10) || lines[2] != base64.StdEncoding.EncodeToString(root) {
+		return errors.New("checkpoint isn't of the tree of the inclusion proof")
+	}
+
+	for _, line := range strings.Split(signatures, "\n") {
+		// — <key name> <base64 of the 4-byte key hash and the signature>
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "—" {
+			continue
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil || len(signature) <= 4 {
+			continue
+		}
+
+		if verifySignature(key, []byte(text), signature[4:]) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("checkpoint isn't signed by the transparency log")
+}
@@ -0,0 +1,312 @@
+package image_verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/docker/auth"
+)
+
+type Logger interface {
+	Println(args ...any)
+	Warningln(args ...any)
+}
+
+// VerificationError is returned when an image doesn't satisfy the rule of
+// the policy that applies to it.
+type VerificationError struct {
+	Image string
+	Inner error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("image %s failed verification: %v", e.Image, e.Inner)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Inner
+}
+
+// Verifier verifies the cosign signatures and attestations of the images in
+// their registry, according to the rules of an image verification policy.
+type Verifier struct {
+	rules   []*rule
+	trust   *trustRoot
+	options []remote.Option
+
+	mu       sync.Mutex
+	verified map[string]string
+}
+
+// NewVerifier returns the verifier of the policy. The options are used to
+// access the registries, in addition to the credentials of each image.
+func NewVerifier(config *common.ImageVerificationConfig, options ...remote.Option) (*Verifier, error) {
+	v := &Verifier{
+		options:  options,
+		verified: map[string]string{},
+	}
+
+	keyless := false
+	for i, c := range config.Rules {
+		r, err := newRule(config, c)
+		if err != nil {
+			return nil, fmt.Errorf("image verification rule #%d: %w", i+1, err)
+		}
+
+		keyless = keyless || len(r.identities) > 0
+		v.rules = append(v.rules, r)
+	}
+
+	if keyless && (len(config.FulcioRoots) == 0 || len(config.RekorPublicKeys) == 0) {
+		return nil, errors.New("image verification: rules with identities require fulcio_roots and rekor_public_keys")
+	}
+
+	trust, err := newTrustRoot(config.FulcioRoots, config.RekorPublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("image verification: %w", err)
+	}
+	v.trust = trust
+
+	if config.RekorURL != "" {
+		if trust.rekor, err = newRekorClient(config.RekorURL); err != nil {
+			return nil, fmt.Errorf("image verification: %w", err)
+		}
+	}
+
+	return v, nil
+}
+
+func (v *Verifier) ruleFor(image string) *rule {
+	names := imageNames(image)
+	for _, r := range v.rules {
+		if r.matches(names) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Matches returns whether a rule of the policy applies to the image.
+func (v *Verifier) Matches(image string) bool {
+	return v.ruleFor(image) != nil
+}
+
+// Resolve returns the digest of the manifest the image reference points to
+// in the registry.
+func (v *Verifier) Resolve(ctx context.Context, image string, auth authn.Authenticator) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("parsing image reference %q: %w", image, err)
+	}
+
+	if digest, ok := ref.(name.Digest); ok {
+		return digest.DigestStr(), nil
+	}
+
+	desc, err := remote.Head(ref, v.remoteOptions(ctx, auth)...)
+	if err != nil {
+		return "", fmt.Errorf("resolving the digest of image %s: %w", image, err)
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// Verify verifies the image, whose manifest has the digest in the registry,
+// against the rule of the policy that applies to it. Images no rule applies
+// to aren't verified.
+//
+// When the image doesn't satisfy a rule in enforce mode, it returns a
+// *VerificationError; in warn mode, the failure is only logged. Other errors
+// are failures to access the registry.
+func (v *Verifier) Verify(ctx context.Context, logger Logger, image, digest string, auth authn.Authenticator) error {
+	return v.VerifyFrom(ctx, logger, image, image, digest, auth)
+}
+
+// VerifyFrom verifies the image like Verify, with the signatures and
+// attestations of the repository of source, the reference the image was
+// pulled from, like a registry mirror. The rule of the policy that applies is
+// the one of the image, and auth the credentials of source.
+func (v *Verifier) VerifyFrom(ctx context.Context, logger Logger, image, source, digest string, auth authn.Authenticator) error {
+	r := v.ruleFor(image)
+	if r == nil {
+		return nil
+	}
+
+	signer, err := v.verify(ctx, r, image, source, digest, auth)
+	if err == nil {
+		logger.Println(fmt.Sprintf("Verified image %s@%s signed by %s", image, digest, signer))
+		return nil
+	}
+
+	if r.mode == common.ImageVerificationModeWarn {
+		logger.Warningln(fmt.Sprintf("Image verification (warn mode): %v", err))
+		return nil
+	}
+
+	return err
+}
+
+func (v *Verifier) verify(ctx context.Context, r *rule, image, source, digest string, auth authn.Authenticator) (string, error) {
+	if digest == "" {
+		return "", &VerificationError{Image: image, Inner: errors.New("the image has no registry digest")}
+	}
+
+	key := image + "@" + digest + " " + source
+
+	v.mu.Lock()
+	signer, ok := v.verified[key]
+	v.mu.Unlock()
+	if ok {
+		return signer, nil
+	}
+
+	ref, err := name.ParseReference(source)
+	if err != nil {
+		return "", &VerificationError{Image: image, Inner: err}
+	}
+
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		return "", &VerificationError{Image: image, Inner: err}
+	}
+
+	options := v.remoteOptions(ctx, auth)
+
+	signer, err = v.verifySignatures(ctx, r, ref.Context(), hash, options)
+	if err != nil {
+		return "", wrapVerificationError(image, err)
+	}
+
+	if err := v.verifyAttestations(ctx, r, ref.Context(), hash, options); err != nil {
+		return "", wrapVerificationError(image, err)
+	}
+
+	v.mu.Lock()
+	v.verified[key] = signer
+	v.mu.Unlock()
+
+	return signer, nil
+}
+
+// registryError is a failure to access the registry, as opposed to a
+// failure of the image to satisfy the rule.
+type registryError struct {
+	err error
+}
+
+func (e registryError) Error() string {
+	return e.err.Error()
+}
+
+func wrapVerificationError(image string, err error) error {
+	var rerr registryError
+	if errors.As(err, &rerr) {
+		return fmt.Errorf("verifying image %s: %w", image, rerr.err)
+	}
+
+	return &VerificationError{Image: image, Inner: err}
+}
+
+func (v *Verifier) verifySignatures(ctx context.Context, r *rule, repo name.Repository, digest v1.Hash, options []remote.Option) (string, error) {
+	layers, err := fetchCosignLayers(repo, digest, signatureTagSuffix, options)
+	if errors.Is(err, errNotFound) {
+		return "", errors.New("no signature found")
+	}
+	if err != nil {
+		return "", registryError{err}
+	}
+
+	var errs []string
+	for _, layer := range layers {
+		s, err := layer.signature(digest)
+		if err == nil {
+			var signer string
+			if signer, err = s.verify(ctx, r, v.trust); err == nil {
+				return signer, nil
+			}
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return "", fmt.Errorf("no valid signature: %s", strings.Join(errs, "; "))
+}
+
+func (v *Verifier) verifyAttestations(ctx context.Context, r *rule, repo name.Repository, digest v1.Hash, options []remote.Option) error {
+	if len(r.attestations) == 0 {
+		return nil
+	}
+
+	layers, err := fetchCosignLayers(repo, digest, attestationTagSuffix, options)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("no attestation found, %s required", strings.Join(r.attestations, ", "))
+	}
+	if err != nil {
+		return registryError{err}
+	}
+
+	verified := map[string]bool{}
+	errs := map[string][]string{}
+	for _, layer := range layers {
+		predicateType, signatures, err := layer.attestations(digest)
+		if err != nil {
+			errs[predicateType] = append(errs[predicateType], err.Error())
+			continue
+		}
+
+		for _, s := range signatures {
+			if _, err := s.verify(ctx, r, v.trust); err != nil {
+				errs[predicateType] = append(errs[predicateType], err.Error())
+				continue
+			}
+			verified[predicateType] = true
+		}
+	}
+
+	for _, predicateType := range r.attestations {
+		if verified[predicateType] {
+			continue
+		}
+
+		if len(errs[predicateType]) == 0 {
+			return fmt.Errorf("no %s attestation found", predicateType)
+		}
+
+		return fmt.Errorf("no valid %s attestation: %s", predicateType, strings.Join(errs[predicateType], "; "))
+	}
+
+	return nil
+}
+
+func (v *Verifier) remoteOptions(ctx context.Context, auth authn.Authenticator) []remote.Option {
+	if auth == nil {
+		auth = authn.Anonymous
+	}
+
+	return append([]remote.Option{remote.WithContext(ctx), remote.WithAuth(auth)}, v.options...)
+}
+
+// Authenticator returns the authenticator of the registry credentials that
+// the auth package resolved for an image, or nil without credentials.
+func Authenticator(info *auth.RegistryInfo) authn.Authenticator {
+	if info == nil {
+		return nil
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      info.AuthConfig.Username,
+		Password:      info.AuthConfig.Password,
+		Auth:          info.AuthConfig.Auth,
+		IdentityToken: info.AuthConfig.IdentityToken,
+		RegistryToken: info.AuthConfig.RegistryToken,
+	})
+}
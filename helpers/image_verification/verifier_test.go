@@ -0,0 +1,967 @@
+//go:build !integration
+
+package image_verification
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/transparency-dev/merkle/rfc6962"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+const (
+	testIssuer       = "https://gitlab.example.com"
+	testSubject      = "https://gitlab.example.com/group/project//.gitlab-ci.yml@refs/heads/main"
+	testProvenance   = "https://slsa.dev/provenance/v1"
+	testSBOMType     = "https://spdx.dev/Document"
+	simpleSigningMT  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	dsseEnvelopeMT   = "application/vnd.dsse.envelope.v1+json"
+	testImageRepo    = "group/project/app"
+	testOtherRepo    = "group/project/other"
+	testImageTag     = "1.0"
+	testImageAnyGlob = "**"
+)
+
+type testLogger struct {
+	messages []string
+	warnings []string
+}
+
+func (l *testLogger) Println(args ...any) {
+	l.messages = append(l.messages, fmt.Sprint(args...))
+}
+
+func (l *testLogger) Warningln(args ...any) {
+	l.warnings = append(l.warnings, fmt.Sprint(args...))
+}
+
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return u.Host
+}
+
+// pushTestImage pushes a random image, and returns its reference and digest.
+func pushTestImage(t *testing.T, host, repo string) (string, v1.Hash) {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	image := fmt.Sprintf("%s/%s:%s", host, repo, testImageTag)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	return image, digest
+}
+
+func pushCosignImage(t *testing.T, image string, digest v1.Hash, suffix string, mediaType types.MediaType, layers ...cosignLayer) {
+	t.Helper()
+
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+
+	var adds []mutate.Addendum
+	for _, l := range layers {
+		adds = append(adds, mutate.Addendum{
+			Layer:       static.NewLayer(l.data, mediaType),
+			Annotations: l.annotations,
+		})
+	}
+
+	img, err := mutate.Append(empty.Image, adds...)
+	require.NoError(t, err)
+
+	tag := ref.Context().Tag(fmt.Sprintf("%s-%s%s", digest.Algorithm, digest.Hex, suffix))
+	require.NoError(t, remote.Write(tag, img))
+}
+
+type testSigner struct {
+	key crypto.Signer
+
+	// keyless signing
+	cert      *x509.Certificate
+	rekorKey  *ecdsa.PrivateKey
+	rekorTime time.Time
+	// rekorLog records the entries of the signatures, when set
+	rekorLog *testRekorLog
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return key
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func certificatePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func (s *testSigner) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		signature, err := s.key.Sign(rand.Reader, data, crypto.Hash(0))
+		require.NoError(t, err)
+		return signature
+	}
+
+	digest := sha256.Sum256(data)
+	signature, err := s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+
+	return signature
+}
+
+// annotations returns the certificate and transparency log annotations of
+// keyless signatures.
+func (s *testSigner) annotations(t *testing.T, body map[string]any) map[string]string {
+	t.Helper()
+
+	if s.cert == nil {
+		return map[string]string{}
+	}
+
+	encodedBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(s.rekorKey.Public())
+	require.NoError(t, err)
+	logID := sha256.Sum256(der)
+
+	payload := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(encodedBody),
+		IntegratedTime: s.rekorTime.Unix(),
+		LogID:          hex.EncodeToString(logID[:]),
+		LogIndex:       42,
+	}
+	if s.rekorLog != nil {
+		payload.LogIndex = s.rekorLog.add(t, encodedBody, payload)
+	}
+
+	// the bundle payload isn't in canonical form: the timestamp is signed
+	// over the canonical JSON of it
+	var bundle rekorBundle
+	bundle.Payload, err = json.MarshalIndent(map[string]any{
+		"logIndex":       payload.LogIndex,
+		"logID":          payload.LogID,
+		"integratedTime": payload.IntegratedTime,
+		"body":           payload.Body,
+	}, "", "  ")
+	require.NoError(t, err)
+
+	canonical, err := jsoncanonicalizer.Transform(bundle.Payload)
+	require.NoError(t, err)
+	digest := sha256.Sum256(canonical)
+	bundle.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, s.rekorKey, digest[:])
+	require.NoError(t, err)
+
+	encodedBundle, err := json.Marshal(bundle)
+	require.NoError(t, err)
+
+	return map[string]string{
+		certificateAnnotation: certificatePEM(s.cert),
+		bundleAnnotation:      string(encodedBundle),
+	}
+}
+
+func (s *testSigner) signature(t *testing.T, digest v1.Hash) cosignLayer {
+	t.Helper()
+
+	payload := []byte(fmt.Sprintf(
+		`{"critical":{"identity":{"docker-reference":"example"},"image":{"docker-manifest-digest":%q},"type":%q},"optional":null}`,
+		digest.String(), simpleSigningType,
+	))
+	signature := s.sign(t, payload)
+
+	var certContent []byte
+	if s.cert != nil {
+		certContent = []byte(certificatePEM(s.cert))
+	}
+
+	payloadDigest := sha256.Sum256(payload)
+	annotations := s.annotations(t, map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data": map[string]any{
+				"hash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(payloadDigest[:])},
+			},
+			"signature": map[string]any{
+				"content":   base64.StdEncoding.EncodeToString(signature),
+				"publicKey": map[string]any{"content": certContent},
+			},
+		},
+	})
+	annotations[signatureAnnotation] = base64.StdEncoding.EncodeToString(signature)
+
+	return cosignLayer{data: payload, annotations: annotations}
+}
+
+func (s *testSigner) attestation(t *testing.T, digest v1.Hash, predicateType string) cosignLayer {
+	t.Helper()
+
+	statement, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"predicateType": predicateType,
+		"subject": []any{
+			map[string]any{"name": "image", "digest": map[string]string{digest.Algorithm: digest.Hex}},
+		},
+		"predicate": map[string]any{},
+	})
+	require.NoError(t, err)
+
+	signature := s.sign(t, dssePAE(inTotoPayloadType, statement))
+
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": inTotoPayloadType,
+		"payload":     statement,
+		"signatures":  []any{map[string]any{"keyid": "", "sig": signature}},
+	})
+	require.NoError(t, err)
+
+	var certContent []byte
+	if s.cert != nil {
+		certContent = []byte(certificatePEM(s.cert))
+	}
+
+	statementDigest := sha256.Sum256(statement)
+	annotations := s.annotations(t, map[string]any{
+		"apiVersion": "0.0.2",
+		"kind":       "intoto",
+		"spec": map[string]any{
+			"content": map[string]any{
+				"payloadHash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(statementDigest[:])},
+				"envelope": map[string]any{
+					"signatures": []any{map[string]any{"publicKey": certContent}},
+				},
+			},
+		},
+	})
+	annotations[predicateTypeAnnotation] = predicateType
+
+	return cosignLayer{data: envelope, annotations: annotations}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+// keylessSigner returns a signer with a short-lived certificate for the
+// subject, which expired since it was recorded in the transparency log.
+func (ca *testCA) keylessSigner(t *testing.T, issuer, subject string, rekorKey *ecdsa.PrivateKey) *testSigner {
+	t.Helper()
+
+	key := newTestKey(t)
+
+	issuerExt, err := asn1.MarshalWithParams(issuer, "utf8")
+	require.NoError(t, err)
+
+	subjectURI, err := url.Parse(subject)
+	require.NoError(t, err)
+
+	signedAt := time.Now().Add(-time.Hour)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-time.Minute),
+		NotAfter:        signedAt.Add(10 * time.Minute),
+		URIs:            []*url.URL{subjectURI},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerExt}},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testSigner{key: key, cert: cert, rekorKey: rekorKey, rekorTime: signedAt}
+}
+
+// testRekorLog is a transparency log recording each entry in a tree of its
+// own, after another leaf.
+type testRekorLog struct {
+	key *ecdsa.PrivateKey
+	// tamper modifies the entries the log returns
+	tamper func(entry *rekorLogEntry)
+
+	mu      sync.Mutex
+	entries map[int64]rekorLogEntry
+}
+
+func newTestRekorLog(t *testing.T, key *ecdsa.PrivateKey) (*testRekorLog, string) {
+	t.Helper()
+
+	l := &testRekorLog{key: key, entries: map[int64]rekorLogEntry{}}
+
+	server := httptest.NewServer(l)
+	t.Cleanup(server.Close)
+
+	return l, server.URL
+}
+
+func (l *testRekorLog) add(t *testing.T, body []byte, payload rekorPayload) int64 {
+	t.Helper()
+
+	sibling := rfc6962.DefaultHasher.HashLeaf([]byte("other entry"))
+	root := rfc6962.DefaultHasher.HashChildren(sibling, rfc6962.DefaultHasher.HashLeaf(body))
+
+	text := fmt.Sprintf("rekor.example.com - 1\n2\n%s\n", base64.StdEncoding.EncodeToString(root))
+	digest := sha256.Sum256([]byte(text))
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	require.NoError(t, err)
+	keyHint := []byte{0, 1, 2, 3}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := rekorLogEntry{
+		Body:           payload.Body,
+		IntegratedTime: payload.IntegratedTime,
+		LogID:          payload.LogID,
+		LogIndex:       int64(100 + len(l.entries)),
+	}
+	entry.Verification.InclusionProof = &rekorInclusionProof{
+		Checkpoint: text + "\n— rekor.example.com " + base64.StdEncoding.EncodeToString(append(keyHint, signature...)) + "\n",
+		Hashes:     []string{hex.EncodeToString(sibling)},
+		LogIndex:   1,
+		RootHash:   hex.EncodeToString(root),
+		TreeSize:   2,
+	}
+	l.entries[entry.LogIndex] = entry
+
+	return entry.LogIndex
+}
+
+func (l *testRekorLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logIndex, err := strconv.ParseInt(r.URL.Query().Get("logIndex"), 10, 64)
+	if r.URL.Path != "/api/v1/log/entries" || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	l.mu.Lock()
+	entry, ok := l.entries[logIndex]
+	l.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if l.tamper != nil {
+		p := *entry.Verification.InclusionProof
+		entry.Verification.InclusionProof = &p
+		l.tamper(&entry)
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]rekorLogEntry{"uuid": entry})
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	host := newTestRegistry(t)
+
+	trustedKey := newTestKey(t)
+	untrustedKey := newTestKey(t)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ca := newTestCA(t)
+	rekorKey := newTestKey(t)
+	untrustedRekorKey := newTestKey(t)
+
+	keyRule := func(mode common.ImageVerificationMode, attestations ...string) common.ImageVerificationRule {
+		return common.ImageVerificationRule{
+			Images:       []string{host + "/" + testImageRepo + ":*"},
+			Mode:         mode,
+			PublicKeys:   []string{publicKeyPEM(t, trustedKey.Public()), publicKeyPEM(t, ed25519Key.Public())},
+			Attestations: attestations,
+		}
+	}
+
+	identityRule := common.ImageVerificationRule{
+		Images: []string{testImageAnyGlob},
+		Identities: []common.ImageVerificationIdentity{
+			{Issuer: "https://other.example.com", Subject: testSubject},
+			{Issuer: testIssuer, SubjectRegexp: `^https://gitlab\.example\.com/group/project//`},
+		},
+	}
+
+	tests := map[string]struct {
+		image         string
+		rule          common.ImageVerificationRule
+		noDigest      bool
+		signatures    func(digest v1.Hash) []cosignLayer
+		attestations  func(digest v1.Hash) []cosignLayer
+		expectedError string
+		expectWarning bool
+	}{
+		"image matching no rule": {
+			image: testOtherRepo,
+			rule:  keyRule(""),
+		},
+		"signed with a trusted key": {
+			rule: keyRule(""),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{
+					(&testSigner{key: untrustedKey}).signature(t, digest),
+					(&testSigner{key: trustedKey}).signature(t, digest),
+				}
+			},
+		},
+		"signed with a trusted ed25519 key": {
+			rule: keyRule(""),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: ed25519Key}).signature(t, digest)}
+			},
+		},
+		"signed with an untrusted key": {
+			rule: keyRule(common.ImageVerificationModeEnforce),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: untrustedKey}).signature(t, digest)}
+			},
+			expectedError: "no valid signature: not signed by a trusted public key",
+		},
+		"signed with an untrusted key in warn mode": {
+			rule: keyRule(common.ImageVerificationModeWarn),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: untrustedKey}).signature(t, digest)}
+			},
+			expectWarning: true,
+		},
+		"unsigned": {
+			rule:          keyRule(""),
+			expectedError: "no signature found",
+		},
+		"no registry digest": {
+			rule:          keyRule(""),
+			noDigest:      true,
+			expectedError: "the image has no registry digest",
+		},
+		"signature of another image": {
+			rule: keyRule(""),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				other := digest
+				other.Hex = strings.Repeat("0", len(digest.Hex))
+
+				layer := (&testSigner{key: trustedKey}).signature(t, other)
+				return []cosignLayer{layer}
+			},
+			expectedError: "signature of another image",
+		},
+		"required attestation": {
+			rule: keyRule("", testProvenance),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: trustedKey}).signature(t, digest)}
+			},
+			attestations: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{
+					(&testSigner{key: trustedKey}).attestation(t, digest, testSBOMType),
+					(&testSigner{key: trustedKey}).attestation(t, digest, testProvenance),
+				}
+			},
+		},
+		"missing attestation": {
+			rule: keyRule("", testProvenance),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: trustedKey}).signature(t, digest)}
+			},
+			attestations: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: trustedKey}).attestation(t, digest, testSBOMType)}
+			},
+			expectedError: "no " + testProvenance + " attestation found",
+		},
+		"attestation signed with an untrusted key": {
+			rule: keyRule("", testProvenance),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: trustedKey}).signature(t, digest)}
+			},
+			attestations: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: untrustedKey}).attestation(t, digest, testProvenance)}
+			},
+			expectedError: "no valid " + testProvenance + " attestation: not signed by a trusted public key",
+		},
+		"no attestations": {
+			rule: keyRule("", testProvenance),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{(&testSigner{key: trustedKey}).signature(t, digest)}
+			},
+			expectedError: "no attestation found, " + testProvenance + " required",
+		},
+		"keyless signature of a trusted identity": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, testIssuer, testSubject, rekorKey).signature(t, digest)}
+			},
+		},
+		"keyless attestation of a trusted identity": {
+			rule: func() common.ImageVerificationRule {
+				r := identityRule
+				r.Attestations = []string{testProvenance}
+				return r
+			}(),
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, testIssuer, testSubject, rekorKey).signature(t, digest)}
+			},
+			attestations: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, testIssuer, testSubject, rekorKey).attestation(t, digest, testProvenance)}
+			},
+		},
+		"keyless signature of an untrusted issuer": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, "https://attacker.example.com", testSubject, rekorKey).signature(t, digest)}
+			},
+			expectedError: "isn't trusted",
+		},
+		"keyless signature of an untrusted subject": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, testIssuer, "https://gitlab.example.com/attacker/project", rekorKey).signature(t, digest)}
+			},
+			expectedError: "isn't trusted",
+		},
+		"keyless signature of an untrusted CA": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{newTestCA(t).keylessSigner(t, testIssuer, testSubject, rekorKey).signature(t, digest)}
+			},
+			expectedError: "untrusted signing certificate",
+		},
+		"keyless signature recorded in an untrusted log": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				return []cosignLayer{ca.keylessSigner(t, testIssuer, testSubject, untrustedRekorKey).signature(t, digest)}
+			},
+			expectedError: "isn't trusted",
+		},
+		"keyless signature with a log entry of another signature": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				signer := ca.keylessSigner(t, testIssuer, testSubject, rekorKey)
+				layer := signer.signature(t, digest)
+				layer.annotations[bundleAnnotation] = signer.signature(t, digest).annotations[bundleAnnotation]
+				return []cosignLayer{layer}
+			},
+			expectedError: "transparency log entry doesn't match the signature: different signature",
+		},
+		"keyless signature without log entry": {
+			rule: identityRule,
+			signatures: func(digest v1.Hash) []cosignLayer {
+				layer := ca.keylessSigner(t, testIssuer, testSubject, rekorKey).signature(t, digest)
+				delete(layer.annotations, bundleAnnotation)
+				return []cosignLayer{layer}
+			},
+			expectedError: "keyless signature without transparency log entry",
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			repo := strings.ToLower(strings.ReplaceAll(tn, " ", "-"))
+			image, digest := pushTestImage(t, host, testImageRepo+"/"+repo)
+			rule := tc.rule
+			rule.Images = []string{host + "/" + testImageRepo + "/" + repo + ":*"}
+			if tc.image != "" {
+				image, digest = pushTestImage(t, host, tc.image)
+			}
+
+			if tc.signatures != nil {
+				pushCosignImage(t, image, digest, signatureTagSuffix, simpleSigningMT, tc.signatures(digest)...)
+			}
+			if tc.attestations != nil {
+				pushCosignImage(t, image, digest, attestationTagSuffix, dsseEnvelopeMT, tc.attestations(digest)...)
+			}
+
+			v, err := NewVerifier(&common.ImageVerificationConfig{
+				FulcioRoots:     []string{certificatePEM(ca.cert)},
+				RekorPublicKeys: []string{publicKeyPEM(t, rekorKey.Public())},
+				Rules:           []common.ImageVerificationRule{rule},
+			})
+			require.NoError(t, err)
+
+			digestStr := digest.String()
+			if tc.noDigest {
+				digestStr = ""
+			}
+
+			logger := new(testLogger)
+			err = v.Verify(context.Background(), logger, image, digestStr, nil)
+
+			if tc.expectWarning {
+				assert.NoError(t, err)
+				require.Len(t, logger.warnings, 1)
+				assert.Contains(t, logger.warnings[0], "failed verification")
+				return
+			}
+
+			if tc.expectedError != "" {
+				var verr *VerificationError
+				require.ErrorAs(t, err, &verr)
+				assert.Equal(t, image, verr.Image)
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Empty(t, logger.warnings)
+		})
+	}
+}
+
+func TestVerifier_Verify_InclusionProof(t *testing.T) {
+	host := newTestRegistry(t)
+	ca := newTestCA(t)
+	rekorKey := newTestKey(t)
+
+	tests := map[string]struct {
+		unlogged      bool
+		tamper        func(entry *rekorLogEntry)
+		expectedError string
+	}{
+		"entry included in the log": {},
+		"entry not in the log": {
+			unlogged:      true,
+			expectedError: "looking up the transparency log entry: GET",
+		},
+		"entry differing from the bundle": {
+			tamper: func(entry *rekorLogEntry) {
+				entry.IntegratedTime++
+			},
+			expectedError: "transparency log entry differs from the signature bundle",
+		},
+		"entry without inclusion proof": {
+			tamper: func(entry *rekorLogEntry) {
+				entry.Verification.InclusionProof = nil
+			},
+			expectedError: "log entry without inclusion proof",
+		},
+		"inclusion proof of another tree": {
+			tamper: func(entry *rekorLogEntry) {
+				entry.Verification.InclusionProof.Hashes = []string{strings.Repeat("00", sha256.Size)}
+			},
+			expectedError: "invalid inclusion proof",
+		},
+		"checkpoint of another tree": {
+			tamper: func(entry *rekorLogEntry) {
+				entry.Verification.InclusionProof.Checkpoint = strings.Replace(entry.Verification.InclusionProof.Checkpoint, "\n2\n", "\n3\n", 1)
+			},
+			expectedError: "checkpoint isn't of the tree of the inclusion proof",
+		},
+		"checkpoint signed by another key": {
+			tamper: func(entry *rekorLogEntry) {
+				other := &testRekorLog{key: newTestKey(t), entries: map[int64]rekorLogEntry{}}
+				body, err := base64.StdEncoding.DecodeString(entry.Body)
+				require.NoError(t, err)
+				other.add(t, body, rekorPayload{})
+				entry.Verification.InclusionProof.Checkpoint = other.entries[100].Verification.InclusionProof.Checkpoint
+			},
+			expectedError: "checkpoint isn't signed by the transparency log",
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			repo := strings.ToLower(strings.ReplaceAll(tn, " ", "-"))
+			image, digest := pushTestImage(t, host, testImageRepo+"/"+repo)
+
+			log, rekorURL := newTestRekorLog(t, rekorKey)
+			log.tamper = tc.tamper
+
+			signer := ca.keylessSigner(t, testIssuer, testSubject, rekorKey)
+			if !tc.unlogged {
+				signer.rekorLog = log
+			}
+			pushCosignImage(t, image, digest, signatureTagSuffix, simpleSigningMT, signer.signature(t, digest))
+
+			v, err := NewVerifier(&common.ImageVerificationConfig{
+				FulcioRoots:     []string{certificatePEM(ca.cert)},
+				RekorPublicKeys: []string{publicKeyPEM(t, rekorKey.Public())},
+				RekorURL:        rekorURL + "/",
+				Rules: []common.ImageVerificationRule{{
+					Images:     []string{host + "/" + testImageRepo + "/" + repo + ":*"},
+					Identities: []common.ImageVerificationIdentity{{Issuer: testIssuer, Subject: testSubject}},
+				}},
+			})
+			require.NoError(t, err)
+
+			err = v.Verify(context.Background(), new(testLogger), image, digest.String(), nil)
+			if tc.expectedError != "" {
+				var verr *VerificationError
+				require.ErrorAs(t, err, &verr)
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifier_VerifyFrom(t *testing.T) {
+	host := newTestRegistry(t)
+	key := newTestKey(t)
+
+	// the mirror serves the image and its signatures, the registry of the
+	// image isn't reachable
+	const image = "registry.invalid/" + testImageRepo + ":" + testImageTag
+	mirrorImage, digest := pushTestImage(t, host, "mirror/"+testImageRepo)
+	pushCosignImage(t, mirrorImage, digest, signatureTagSuffix, simpleSigningMT, (&testSigner{key: key}).signature(t, digest))
+
+	v, err := NewVerifier(&common.ImageVerificationConfig{
+		Rules: []common.ImageVerificationRule{{
+			Images:     []string{"registry.invalid/" + testImageRepo + ":*"},
+			PublicKeys: []string{publicKeyPEM(t, key.Public())},
+		}},
+	})
+	require.NoError(t, err)
+
+	logger := new(testLogger)
+	require.NoError(t, v.VerifyFrom(context.Background(), logger, image, mirrorImage, digest.String(), nil))
+	assert.Empty(t, logger.warnings)
+	require.Len(t, logger.messages, 1)
+	assert.Contains(t, logger.messages[0], "Verified image "+image+"@"+digest.String())
+
+	unsignedImage, unsignedDigest := pushTestImage(t, host, "mirror/"+testOtherRepo)
+	err = v.VerifyFrom(context.Background(), logger, image, unsignedImage, unsignedDigest.String(), nil)
+	var verr *VerificationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, image, verr.Image)
+	assert.ErrorContains(t, err, "no signature found")
+}
+
+func TestVerifier_Resolve(t *testing.T) {
+	host := newTestRegistry(t)
+	image, digest := pushTestImage(t, host, testImageRepo)
+
+	v, err := NewVerifier(&common.ImageVerificationConfig{})
+	require.NoError(t, err)
+
+	resolved, err := v.Resolve(context.Background(), image, nil)
+	require.NoError(t, err)
+	assert.Equal(t, digest.String(), resolved)
+
+	resolved, err = v.Resolve(context.Background(), host+"/"+testImageRepo+"@"+digest.String(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, digest.String(), resolved)
+
+	_, err = v.Resolve(context.Background(), host+"/"+testOtherRepo+":latest", nil)
+	assert.Error(t, err)
+}
+
+func TestVerifier_Matches(t *testing.T) {
+	key := publicKeyPEM(t, newTestKey(t).Public())
+
+	tests := map[string]struct {
+		images   []string
+		matches  []string
+		excludes []string
+	}{
+		"short name": {
+			images:   []string{"alpine:3.20"},
+			matches:  []string{"alpine:3.20", "library/alpine:3.20", "docker.io/library/alpine:3.20", "index.docker.io/library/alpine:3.20"},
+			excludes: []string{"alpine:3.21", "registry.example.com/alpine:3.20", "docker.io/other/alpine:3.20"},
+		},
+		"library name": {
+			images:   []string{"library/alpine"},
+			matches:  []string{"alpine", "docker.io/library/alpine", "index.docker.io/library/alpine"},
+			excludes: []string{"alpine:3.20", "other/alpine"},
+		},
+		"full name pattern": {
+			images:   []string{"docker.io/library/*"},
+			matches:  []string{"alpine", "alpine:3.20", "library/ruby:3", "index.docker.io/library/alpine:3.20"},
+			excludes: []string{"bitnami/redis:7", "registry.example.com/library/alpine:3.20"},
+		},
+		"index.docker.io pattern": {
+			images:   []string{"index.docker.io/bitnami/**"},
+			matches:  []string{"bitnami/redis:7", "docker.io/bitnami/redis:7"},
+			excludes: []string{"redis:7"},
+		},
+		"short name pattern": {
+			images:   []string{"golang:*"},
+			matches:  []string{"golang:1.25", "docker.io/library/golang:1.25"},
+			excludes: []string{"registry.example.com/golang:1.25"},
+		},
+		"other registry": {
+			images:   []string{"registry.example.com:5000/group/**"},
+			matches:  []string{"registry.example.com:5000/group/app:1.0"},
+			excludes: []string{"group/app:1.0", "registry.example.com/group/app:1.0"},
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			v, err := NewVerifier(&common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{Images: tc.images, PublicKeys: []string{key}}},
+			})
+			require.NoError(t, err)
+
+			for _, image := range tc.matches {
+				assert.True(t, v.Matches(image), image)
+			}
+			for _, image := range tc.excludes {
+				assert.False(t, v.Matches(image), image)
+			}
+		})
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	key := publicKeyPEM(t, newTestKey(t).Public())
+
+	tests := map[string]struct {
+		config        common.ImageVerificationConfig
+		expectedError string
+	}{
+		"valid": {
+			config: common.ImageVerificationConfig{
+				Mode:  common.ImageVerificationModeWarn,
+				Rules: []common.ImageVerificationRule{{Images: []string{"registry.example.com/**"}, PublicKeys: []string{key}}},
+			},
+		},
+		"rule without images": {
+			config: common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{PublicKeys: []string{key}}},
+			},
+			expectedError: "image verification rule #1: no images",
+		},
+		"rule without keys or identities": {
+			config: common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{Images: []string{"*"}}},
+			},
+			expectedError: "image verification rule #1: no public keys or identities to verify the signatures with",
+		},
+		"unknown mode": {
+			config: common.ImageVerificationConfig{
+				Mode:  "audit",
+				Rules: []common.ImageVerificationRule{{Images: []string{"*"}, PublicKeys: []string{key}}},
+			},
+			expectedError: `image verification rule #1: unknown mode "audit"`,
+		},
+		"missing public key file": {
+			config: common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{Images: []string{"*"}, PublicKeys: []string{"/nonexistent/cosign.pub"}}},
+			},
+			expectedError: "image verification rule #1: loading public key: open /nonexistent/cosign.pub",
+		},
+		"identities without trust root": {
+			config: common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{
+					Images:     []string{"*"},
+					Identities: []common.ImageVerificationIdentity{{Issuer: testIssuer, Subject: testSubject}},
+				}},
+			},
+			expectedError: "image verification: rules with identities require fulcio_roots and rekor_public_keys",
+		},
+		"invalid rekor URL": {
+			config: common.ImageVerificationConfig{
+				RekorURL: "rekor.example.com",
+			},
+			expectedError: `image verification: invalid rekor_url "rekor.example.com"`,
+		},
+		"identity without subject": {
+			config: common.ImageVerificationConfig{
+				Rules: []common.ImageVerificationRule{{
+					Images:     []string{"*"},
+					Identities: []common.ImageVerificationIdentity{{Issuer: testIssuer}},
+				}},
+			},
+			expectedError: "image verification rule #1: identity of issuer " + testIssuer + " without subject",
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			_, err := NewVerifier(&tc.config)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package network_egress
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// destination is an allowed destination of the egress policy: a hostname,
+// which can start with a *. wildcard label, or an IP address or CIDR, with an
+// optional port or port range.
+type destination struct {
+	raw string
+
+	host   string
+	prefix netip.Prefix
+
+	fromPort int
+	toPort   int
+}
+
+func parseDestination(raw string) (destination, error) {
+	d := destination{raw: raw}
+
+	host, port, err := splitDestination(strings.TrimSpace(raw))
+	if err != nil {
+		return d, fmt.Errorf("invalid destination %q: %w", raw, err)
+	}
+
+	if err := d.parseHost(host); err != nil {
+		return d, fmt.Errorf("invalid destination %q: %w", raw, err)
+	}
+
+	if err := d.parsePorts(port); err != nil {
+		return d, fmt.Errorf("invalid destination %q: %w", raw, err)
+	}
+
+	return d, nil
+}
+
+// splitDestination splits the port from the destination. IPv6 addresses and
+// CIDRs have to be in brackets to have a port.
+func splitDestination(raw string) (string, string, error) {
+	if strings.HasPrefix(raw, "[") {
+		host, rest, ok := strings.Cut(raw[1:], "]")
+		if !ok {
+			return "", "", errors.New("missing ]")
+		}
+		if rest == "" {
+			return host, "", nil
+		}
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", errors.New("unexpected characters after ]")
+		}
+
+		return host, rest[1:], nil
+	}
+
+	if strings.Count(raw, ":") == 1 {
+		host, port, _ := strings.Cut(raw, ":")
+		return host, port, nil
+	}
+
+	return raw, "", nil
+}
+
+func (d *destination) parseHost(host string) error {
+	if host == "" {
+		return errors.New("empty host")
+	}
+
+	if strings.Contains(host, "/") {
+		prefix, err := netip.ParsePrefix(host)
+		if err != nil {
+			return err
+		}
+		d.prefix = prefix.Masked()
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		d.prefix = netip.PrefixFrom(addr, addr.BitLen())
+		return nil
+	}
+
+	host = normalizeHost(host)
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if label == "*" && i == 0 && len(labels) > 1 {
+			continue
+		}
+		if !isHostnameLabel(label) {
+			return fmt.Errorf("invalid hostname label %q", label)
+		}
+	}
+	d.host = host
+
+	return nil
+}
+
+func isHostnameLabel(label string) bool {
+	if label == "" || len(label) > 63 {
+		return false
+	}
+
+	for _, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *destination) parsePorts(port string) error {
+	if port == "" {
+		return nil
+	}
+
+	from, to, isRange := strings.Cut(port, "-")
+	if !isRange {
+		to = from
+	}
+
+	var err error
+	if d.fromPort, err = parsePort(from); err != nil {
+		return err
+	}
+	if d.toPort, err = parsePort(to); err != nil {
+		return err
+	}
+	if d.fromPort > d.toPort {
+		return fmt.Errorf("invalid port range %q", port)
+	}
+
+	return nil
+}
+
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+
+	return p, nil
+}
+
+func (d destination) isHostname() bool {
+	return d.host != ""
+}
+
+func (d destination) matchesPort(port int) bool {
+	return d.fromPort == 0 || (port >= d.fromPort && port <= d.toPort)
+}
+
+// matchesHost returns whether the normalized hostname matches the hostname
+// destination. A *. wildcard matches any number of labels, but at least one.
+func (d destination) matchesHost(host string) bool {
+	if suffix, ok := strings.CutPrefix(d.host, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+
+	return host == d.host
+}
+
+func (d destination) matchesAddr(addr netip.Addr) bool {
+	return d.prefix.IsValid() && d.prefix.Contains(addr.Unmap())
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,125 @@
+package network_egress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// DeniedError is returned when the egress policy doesn't allow a connection.
+type DeniedError struct {
+	Host string
+	Port int
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("connection to %s isn't allowed by the egress policy", net.JoinHostPort(e.Host, fmt.Sprint(e.Port)))
+}
+
+type resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Policy is the list of the destinations the containers of a job can connect
+// to.
+type Policy struct {
+	destinations []destination
+	resolver     resolver
+}
+
+// NewPolicy returns the policy allowing the destinations.
+func NewPolicy(allow []string) (*Policy, error) {
+	p := &Policy{resolver: net.DefaultResolver}
+
+	for _, raw := range allow {
+		d, err := parseDestination(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.destinations = append(p.destinations, d)
+	}
+
+	return p, nil
+}
+
+// ValidateDestination returns an error when the destination isn't valid.
+func ValidateDestination(raw string) error {
+	_, err := parseDestination(raw)
+	return err
+}
+
+// Addresses returns the addresses the connection to the host and port can
+// be made to, or a *DeniedError when the policy doesn't allow it.
+//
+// All the addresses of an allowed hostname are returned. Otherwise only the
+// addresses of the host that are in an allowed IP address or CIDR are, so
+// that a hostname can't be used to reach addresses that aren't allowed.
+func (p *Policy) Addresses(ctx context.Context, host string, port int) ([]netip.Addr, error) {
+	host = normalizeHost(host)
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if p.allowsAddr(addr, port) {
+			return []netip.Addr{addr.Unmap()}, nil
+		}
+
+		return nil, &DeniedError{Host: host, Port: port}
+	}
+
+	allowedHost := p.allowsHost(host, port)
+	if !allowedHost && !p.hasAddrDestinations(port) {
+		return nil, &DeniedError{Host: host, Port: port}
+	}
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowedHost {
+		return addrs, nil
+	}
+
+	var allowed []netip.Addr
+	for _, addr := range addrs {
+		if p.allowsAddr(addr, port) {
+			allowed = append(allowed, addr.Unmap())
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, &DeniedError{Host: host, Port: port}
+	}
+
+	return allowed, nil
+}
+
+func (p *Policy) allowsHost(host string, port int) bool {
+	for _, d := range p.destinations {
+		if d.isHostname() && d.matchesPort(port) && d.matchesHost(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) allowsAddr(addr netip.Addr, port int) bool {
+	for _, d := range p.destinations {
+		if d.matchesPort(port) && d.matchesAddr(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) hasAddrDestinations(port int) bool {
+	for _, d := range p.destinations {
+		if !d.isHostname() && d.matchesPort(port) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+//go:build !integration
+
+package network_egress
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	return r[host], nil
+}
+
+func TestValidateDestination(t *testing.T) {
+	tests := map[string]string{
+		"example.com":           "",
+		"*.example.com:443":     "",
+		"example.com:8000-9000": "",
+		"10.0.0.0/8":            "",
+		"10.1.2.3:22":           "",
+		"2001:db8::/32":         "",
+		"[2001:db8::1]:443":     "",
+		"[2001:db8::/32]:443":   "",
+		"":                      `invalid destination "": empty host`,
+		"*":                     `invalid destination "*": invalid hostname label "*"`,
+		"a.*.example.com":       `invalid destination "a.*.example.com": invalid hostname label "*"`,
+		"example.com:0":         `invalid destination "example.com:0": invalid port "0"`,
+		"example.com:9-8":       `invalid destination "example.com:9-8": invalid port range "9-8"`,
+		"10.0.0.0/33":           `invalid destination "10.0.0.0/33": netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+		"[2001:db8::1":          `invalid destination "[2001:db8::1": missing ]`,
+	}
+
+	for raw, expectedErr := range tests {
+		t.Run(raw, func(t *testing.T) {
+			err := ValidateDestination(raw)
+			if expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, expectedErr)
+		})
+	}
+}
+
+func TestPolicy_Addresses(t *testing.T) {
+	policy, err := NewPolicy([]string{
+		"gitlab.example.com:443",
+		"*.pkg.example.com",
+		"10.0.0.0/8:5432",
+		"192.0.2.1",
+	})
+	require.NoError(t, err)
+
+	policy.resolver = fakeResolver{
+		"gitlab.example.com":     {netip.MustParseAddr("203.0.113.1")},
+		"mirror.pkg.example.com": {netip.MustParseAddr("203.0.113.2")},
+		"db.internal":            {netip.MustParseAddr("10.1.1.1"), netip.MustParseAddr("203.0.113.3")},
+		"metadata.internal":      {netip.MustParseAddr("169.254.169.254")},
+	}
+
+	tests := map[string]struct {
+		host          string
+		port          int
+		expectedAddrs []string
+	}{
+		"allowed hostname and port": {
+			host:          "GitLab.example.com.",
+			port:          443,
+			expectedAddrs: []string{"203.0.113.1"},
+		},
+		"allowed hostname, other port": {
+			host: "gitlab.example.com",
+			port: 22,
+		},
+		"wildcard hostname": {
+			host:          "mirror.pkg.example.com",
+			port:          80,
+			expectedAddrs: []string{"203.0.113.2"},
+		},
+		"wildcard doesn't match the domain itself": {
+			host: "pkg.example.com",
+			port: 80,
+		},
+		"hostname resolving to an allowed CIDR": {
+			host:          "db.internal",
+			port:          5432,
+			expectedAddrs: []string{"10.1.1.1"},
+		},
+		"hostname resolving outside the allowed CIDRs": {
+			host: "metadata.internal",
+			port: 80,
+		},
+		"allowed IP address": {
+			host:          "192.0.2.1",
+			port:          8080,
+			expectedAddrs: []string{"192.0.2.1"},
+		},
+		"IPv4-mapped IPv6 address": {
+			host:          "::ffff:10.2.3.4",
+			port:          5432,
+			expectedAddrs: []string{"10.2.3.4"},
+		},
+		"denied IP address": {
+			host: "169.254.169.254",
+			port: 80,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			addrs, err := policy.Addresses(context.Background(), tt.host, tt.port)
+			if tt.expectedAddrs == nil {
+				var denied *DeniedError
+				assert.ErrorAs(t, err, &denied)
+				return
+			}
+
+			require.NoError(t, err)
+			var actual []string
+			for _, addr := range addrs {
+				actual = append(actual, addr.String())
+			}
+			assert.Equal(t, tt.expectedAddrs, actual)
+		})
+	}
+}
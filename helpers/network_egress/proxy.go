@@ -0,0 +1,206 @@
+package network_egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeniedLogPrefix starts the lines the proxy writes to its log for the
+// connections the policy denied, so that the runner can report them in the
+// job log.
+const DeniedLogPrefix = "egress denied: "
+
+const dialTimeout = 30 * time.Second
+
+// hopHeaders are the hop-by-hop headers, which aren't forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is an HTTP proxy, supporting CONNECT for TLS and other protocols,
+// which only connects to the destinations the policy allows.
+type Proxy struct {
+	policy    *Policy
+	dialer    *net.Dialer
+	transport *http.Transport
+
+	logMu sync.Mutex
+	log   io.Writer
+}
+
+// NewProxy returns the proxy enforcing the policy, which logs the denied
+// connections to log.
+func NewProxy(policy *Policy, log io.Writer) *Proxy {
+	p := &Proxy{
+		policy: policy,
+		dialer: &net.Dialer{Timeout: dialTimeout},
+		log:    log,
+	}
+
+	p.transport = &http.Transport{
+		DialContext:           p.dial,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Minute,
+	}
+
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must have an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	p.serveHTTP(w, r)
+}
+
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.error(w, r, r.Host, err)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	// the client can send data right after the request, which the server
+	// already read
+	if n := buf.Reader.Buffered(); n > 0 {
+		data, _ := buf.Reader.Peek(n)
+		if _, err := upstream.Write(data); err != nil {
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() { pipe(upstream, conn) })
+	pipe(conn, upstream)
+	wg.Wait()
+}
+
+// pipe copies from src to dst, and then closes the writing side of dst, so
+// that the other side sees the end of the stream.
+func pipe(dst, src net.Conn) {
+	_, _ = io.Copy(dst, src)
+
+	if c, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = c.CloseWrite()
+		return
+	}
+	_ = dst.Close()
+}
+
+func (p *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	removeHopHeaders(req.Header)
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		p.error(w, r, r.URL.Host, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+}
+
+func (p *Proxy) error(w http.ResponseWriter, r *http.Request, target string, err error) {
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		p.logDenied(r.Method, target)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func (p *Proxy) logDenied(method, target string) {
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+
+	_, _ = fmt.Fprintf(p.log, "%s%s %s\n", DeniedLogPrefix, method, target)
+}
+
+// dial connects to the address, if the policy allows it, trying each of the
+// allowed addresses of the host.
+func (p *Proxy) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		// plain HTTP requests can have no port
+		host, portStr = address, "80"
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	addrs, err := p.policy.Addresses(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = p.dialer.DialContext(ctx, network, netip.AddrPortFrom(addr, uint16(port)).String())
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,148 @@
+//go:build !integration
+
+package network_egress
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProxy(t *testing.T, allow ...string) (*httptest.Server, *bytes.Buffer) {
+	policy, err := NewPolicy(allow)
+	require.NoError(t, err)
+
+	log := new(bytes.Buffer)
+	proxy := httptest.NewServer(NewProxy(policy, log))
+	t.Cleanup(proxy.Close)
+
+	return proxy, log
+}
+
+func newTestUpstream(t *testing.T) (*httptest.Server, string) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Authorization"))
+		_, _ = fmt.Fprint(w, "hello from upstream")
+	}))
+	t.Cleanup(upstream.Close)
+
+	_, port, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	require.NoError(t, err)
+
+	return upstream, port
+}
+
+func TestProxy_HTTP(t *testing.T) {
+	upstream, port := newTestUpstream(t)
+
+	tests := map[string]struct {
+		allow          []string
+		expectedStatus int
+		expectedLog    string
+	}{
+		"allowed": {
+			allow:          []string{"127.0.0.1/32:" + port},
+			expectedStatus: http.StatusOK,
+		},
+		"denied": {
+			allow:          []string{"127.0.0.1/32:1"},
+			expectedStatus: http.StatusForbidden,
+			expectedLog:    DeniedLogPrefix + "GET 127.0.0.1:" + port + "\n",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			proxy, log := newTestProxy(t, tt.allow...)
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+			req, err := http.NewRequest(http.MethodGet, upstream.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Proxy-Authorization", "secret")
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus == http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, "hello from upstream", string(body))
+			}
+			assert.Equal(t, tt.expectedLog, log.String())
+		})
+	}
+}
+
+func TestProxy_Connect(t *testing.T) {
+	upstream, port := newTestUpstream(t)
+	target := upstream.Listener.Addr().String()
+
+	tests := map[string]struct {
+		allow          []string
+		expectedStatus int
+		expectedLog    string
+	}{
+		"allowed": {
+			allow:          []string{"127.0.0.1:" + port},
+			expectedStatus: http.StatusOK,
+		},
+		"denied": {
+			allow:          []string{"example.com"},
+			expectedStatus: http.StatusForbidden,
+			expectedLog:    DeniedLogPrefix + "CONNECT " + target + "\n",
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			proxy, log := newTestProxy(t, tt.allow...)
+
+			conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// the request through the tunnel is sent with the CONNECT request,
+			// before its response
+			_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+			require.NoError(t, err)
+			_, err = fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", target)
+			require.NoError(t, err)
+
+			reader := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(reader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedLog, log.String())
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			resp, err = http.ReadResponse(reader, nil)
+			require.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, "hello from upstream", string(body))
+		})
+	}
+}
+
+func TestProxy_RejectsRelativeRequests(t *testing.T) {
+	proxy, _ := newTestProxy(t, "0.0.0.0/0")
+
+	resp, err := http.Get(proxy.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,37 @@
+package registry_mirrors
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var pulls = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gitlab_runner_image_pull_mirror_requests_total",
+		Help: "Total number of image pulls from the registries and their mirrors, by registry, mirror and result (success, failure).",
+	},
+	[]string{"registry", "mirror", "result"},
+)
+
+// Collector returns the collector of the metrics of the pulls from the
+// registry mirrors.
+func Collector() prometheus.Collector {
+	return pulls
+}
+
+// RecordPull records the result of a pull of the candidate. Pulls of images
+// without mirrors aren't recorded.
+func RecordPull(c Candidate, err error) {
+	if c.Registry == "" {
+		return
+	}
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+
+	pulls.WithLabelValues(c.Registry, c.Mirror, result).Inc()
+}
@@ -0,0 +1,144 @@
+// Package registry_mirrors rewrites the references of images to the mirrors
+// of their registry, which the pull managers of the docker and kubernetes
+// executors try in turn.
+package registry_mirrors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// AllFailedMessage starts the message of AllFailedError, and is how
+// common.ClassifyImagePullFailure recognizes it.
+const AllFailedMessage = "all registry mirrors failed"
+
+const defaultRegistry = "docker.io"
+
+// registryAliases are the other names of the default registry.
+var registryAliases = map[string]string{
+	"index.docker.io":      defaultRegistry,
+	"registry-1.docker.io": defaultRegistry,
+}
+
+// Candidate is a reference an image can be pulled from.
+type Candidate struct {
+	// Registry is the registry of the image.
+	Registry string
+	// Mirror is the mirror the image is pulled from, or the registry itself.
+	Mirror string
+	// Image is the reference of the image on the mirror.
+	Image string
+}
+
+// IsMirror returns whether the image is pulled from a mirror rather than from
+// its registry.
+func (c Candidate) IsMirror() bool {
+	return c.Mirror != c.Registry
+}
+
+// Candidates returns the references the image is pulled from, in turn. The
+// registry of the image is tried after its mirrors, unless it's in their list.
+// An image without mirrors only has its own reference.
+func Candidates(image string, registryMirrors map[string][]string) []Candidate {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil || len(registryMirrors) == 0 {
+		return []Candidate{{Image: image}}
+	}
+
+	registry := normalizeHost(reference.Domain(named))
+
+	var mirrors []string
+	for host, m := range registryMirrors {
+		if normalizeHost(host) == registry {
+			mirrors = m
+			break
+		}
+	}
+
+	if len(mirrors) == 0 {
+		return []Candidate{{Image: image}}
+	}
+
+	upstream := Candidate{Registry: registry, Mirror: registry, Image: image}
+
+	candidates := make([]Candidate, 0, len(mirrors)+1)
+	hasUpstream := false
+	for _, mirror := range mirrors {
+		mirror = normalizeHost(mirror)
+		if mirror == "" {
+			continue
+		}
+
+		if mirror == registry {
+			if !hasUpstream {
+				candidates = append(candidates, upstream)
+				hasUpstream = true
+			}
+			continue
+		}
+
+		candidates = append(candidates, Candidate{
+			Registry: registry,
+			Mirror:   mirror,
+			Image:    rewrite(named, mirror),
+		})
+	}
+
+	if !hasUpstream {
+		candidates = append(candidates, upstream)
+	}
+
+	return candidates
+}
+
+// normalizeHost strips the scheme and trailing slashes the daemon's
+// registry-mirrors setting uses, and resolves the aliases of Docker Hub.
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host = strings.TrimRight(host, "/")
+
+	if alias, ok := registryAliases[host]; ok {
+		return alias
+	}
+
+	return host
+}
+
+// rewrite returns the reference of the image on the mirror, which may have a
+// path, like the projects of pull-through caches.
+func rewrite(named reference.Named, mirror string) string {
+	ref := mirror + "/" + reference.Path(named)
+
+	if tagged, ok := named.(reference.Tagged); ok {
+		ref += ":" + tagged.Tag()
+	}
+
+	if digested, ok := named.(reference.Digested); ok {
+		ref += "@" + digested.Digest().String()
+	}
+
+	return ref
+}
+
+// AllFailedError is the error of the pulls of an image from all the mirrors
+// of its registry, and from the registry.
+type AllFailedError struct {
+	Image  string
+	Errors []error
+}
+
+func (e *AllFailedError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("%s to pull image %q: %s", AllFailedMessage, e.Image, strings.Join(msgs, "; "))
+}
+
+func (e *AllFailedError) Unwrap() []error {
+	return e.Errors
+}
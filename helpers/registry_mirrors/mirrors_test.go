@@ -0,0 +1,92 @@
+//go:build !integration
+
+package registry_mirrors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandidates(t *testing.T) {
+	const digest = "sha256:4bcff63911fcb4448bd4fdacec207030997caf25e9bea4045fa6c8c44de311d1"
+
+	mirrors := map[string][]string{
+		"docker.io":            {"https://mirror.example.com/", "harbor.example.com/hub"},
+		"registry.example.com": {"registry.example.com", "backup.example.com"},
+	}
+
+	tests := map[string]struct {
+		image           string
+		registryMirrors map[string][]string
+		expected        []Candidate
+	}{
+		"no mirrors": {
+			image:    "alpine:3.20",
+			expected: []Candidate{{Image: "alpine:3.20"}},
+		},
+		"registry without mirrors": {
+			image:           "quay.io/prometheus/prometheus:v3",
+			registryMirrors: mirrors,
+			expected:        []Candidate{{Image: "quay.io/prometheus/prometheus:v3"}},
+		},
+		"Docker Hub image": {
+			image:           "alpine:3.20",
+			registryMirrors: mirrors,
+			expected: []Candidate{
+				{Registry: "docker.io", Mirror: "mirror.example.com", Image: "mirror.example.com/library/alpine:3.20"},
+				{Registry: "docker.io", Mirror: "harbor.example.com/hub", Image: "harbor.example.com/hub/library/alpine:3.20"},
+				{Registry: "docker.io", Mirror: "docker.io", Image: "alpine:3.20"},
+			},
+		},
+		"image with a digest": {
+			image:           "index.docker.io/library/alpine:3.20@" + digest,
+			registryMirrors: map[string][]string{"docker.io": {"mirror.example.com"}},
+			expected: []Candidate{
+				{Registry: "docker.io", Mirror: "mirror.example.com", Image: "mirror.example.com/library/alpine:3.20@" + digest},
+				{Registry: "docker.io", Mirror: "docker.io", Image: "index.docker.io/library/alpine:3.20@" + digest},
+			},
+		},
+		"registry in the list": {
+			image:           "registry.example.com/group/project",
+			registryMirrors: mirrors,
+			expected: []Candidate{
+				{Registry: "registry.example.com", Mirror: "registry.example.com", Image: "registry.example.com/group/project"},
+				{Registry: "registry.example.com", Mirror: "backup.example.com", Image: "backup.example.com/group/project"},
+			},
+		},
+		"invalid image": {
+			image:           "Invalid Image",
+			registryMirrors: mirrors,
+			expected:        []Candidate{{Image: "Invalid Image"}},
+		},
+	}
+
+	for tn, tc := range tests {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Candidates(tc.image, tc.registryMirrors))
+		})
+	}
+}
+
+func TestAllFailedError(t *testing.T) {
+	errUnavailable := errors.New("503 Service Unavailable")
+	err := &AllFailedError{Image: "alpine:3.20", Errors: []error{errors.New("manifest unknown"), errUnavailable}}
+
+	assert.EqualError(t, err, `all registry mirrors failed to pull image "alpine:3.20": manifest unknown; 503 Service Unavailable`)
+	assert.ErrorIs(t, err, errUnavailable)
+}
+
+func TestRecordPull(t *testing.T) {
+	candidate := Candidate{Registry: "docker.io", Mirror: "mirror.example.com", Image: "mirror.example.com/library/alpine"}
+
+	RecordPull(candidate, nil)
+	RecordPull(candidate, errors.New("503 Service Unavailable"))
+	RecordPull(Candidate{Image: "alpine"}, nil)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(pulls.WithLabelValues("docker.io", "mirror.example.com", resultSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(pulls.WithLabelValues("docker.io", "mirror.example.com", resultFailure)))
+	assert.Equal(t, 2, testutil.CollectAndCount(Collector()))
+}
@@ -0,0 +1,145 @@
+package encrypted_file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/encrypted_file"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/secrets"
+)
+
+const resolverName = "encrypted_file"
+
+type resolver struct {
+	secret  spec.Secret
+	config  *common.RunnerConfig
+	jobInfo spec.JobInfo
+}
+
+func newResolver(secret spec.Secret) common.SecretResolver {
+	return &resolver{
+		secret: secret,
+	}
+}
+
+func (r *resolver) Name() string {
+	return resolverName
+}
+
+func (r *resolver) IsSupported() bool {
+	return r.secret.EncryptedFile != nil
+}
+
+func (r *resolver) SetRunnerConfig(config *common.RunnerConfig) {
+	r.config = config
+}
+
+func (r *resolver) SetJobInfo(info spec.JobInfo) {
+	r.jobInfo = info
+}
+
+func (r *resolver) Resolve() (string, error) {
+	if !r.IsSupported() {
+		return "", secrets.NewResolvingUnsupportedSecretError(resolverName)
+	}
+
+	if r.config == nil || r.config.EncryptedSecrets == nil || r.config.EncryptedSecrets.Path == "" {
+		return "", secrets.NewResolvingConfigurationError(
+			errors.New("the runner has no encrypted secrets file configured in [runners.encrypted_secrets]"),
+		)
+	}
+
+	conf := r.config.EncryptedSecrets
+
+	key := r.secret.EncryptedFile.Key
+	if !conf.IsKeyAllowed(r.jobInfo.ProjectFullPath, key) {
+		return "", secrets.NewResolvingConfigurationError(
+			fmt.Errorf("project %q isn't allowed to read the key %q of the encrypted secrets file", r.jobInfo.ProjectFullPath, key),
+		)
+	}
+
+	identities, err := r.identities(conf)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(r.path(conf.Path))
+	if err != nil {
+		return "", fmt.Errorf("reading encrypted secrets file: %w", err)
+	}
+
+	file, err := encrypted_file.Decrypt(data, identities)
+	if err != nil {
+		return "", fmt.Errorf("decrypting encrypted secrets file: %w", err)
+	}
+
+	value, err := file.Lookup(key)
+	if errors.Is(err, encrypted_file.ErrKeyNotFound) {
+		return "", fmt.Errorf("%w: %w", common.ErrSecretNotFound, err)
+	}
+	if err != nil {
+		return "", secrets.NewResolvingConfigurationError(err)
+	}
+
+	return value, nil
+}
+
+// identities reads the age identities from the key file and from the
+// environment variable.
+func (r *resolver) identities(conf *common.EncryptedSecretsConfig) ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if conf.KeyFile != "" {
+		f, err := os.Open(r.path(conf.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading encrypted secrets key file: %w", err)
+		}
+		defer f.Close()
+
+		fileIdentities, err := encrypted_file.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing encrypted secrets key file: %w", err)
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	if conf.KeyEnv != "" {
+		value := os.Getenv(conf.KeyEnv)
+		if value == "" {
+			return nil, fmt.Errorf("encrypted secrets key variable %s is empty", conf.KeyEnv)
+		}
+
+		envIdentities, err := encrypted_file.ParseIdentities(strings.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("parsing encrypted secrets key variable %s: %w", conf.KeyEnv, err)
+		}
+		identities = append(identities, envIdentities...)
+	}
+
+	if len(identities) == 0 {
+		return nil, secrets.NewResolvingConfigurationError(
+			errors.New("no key configured for the encrypted secrets file: set key_file or key_env"),
+		)
+	}
+
+	return identities, nil
+}
+
+func (r *resolver) path(path string) string {
+	if filepath.IsAbs(path) || r.config.ConfigDir == "" {
+		return path
+	}
+
+	return filepath.Join(r.config.ConfigDir, path)
+}
+
+func init() {
+	common.GetSecretResolverRegistry().Register(newResolver)
+}
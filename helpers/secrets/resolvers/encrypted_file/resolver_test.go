@@ -0,0 +1,192 @@
+//go:build !integration
+
+package encrypted_file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/secrets"
+)
+
+func TestResolver_Name(t *testing.T) {
+	r := newResolver(spec.Secret{})
+	assert.Equal(t, resolverName, r.Name())
+}
+
+func TestResolver_IsSupported(t *testing.T) {
+	tests := map[string]struct {
+		secret            spec.Secret
+		expectedSupported bool
+	}{
+		"supported secret": {
+			secret: spec.Secret{
+				EncryptedFile: &spec.EncryptedFileSecret{},
+			},
+			expectedSupported: true,
+		},
+		"unsupported secret": {
+			secret:            spec.Secret{},
+			expectedSupported: false,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			r := newResolver(tt.secret)
+			assert.Equal(t, tt.expectedSupported, r.IsSupported())
+		})
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join("testdata", "key.txt"))
+	require.NoError(t, err)
+
+	t.Setenv("TEST_ENCRYPTED_SECRETS_KEY", string(key))
+	t.Setenv("TEST_ENCRYPTED_SECRETS_EMPTY_KEY", "")
+
+	secret := func(key string) spec.Secret {
+		return spec.Secret{EncryptedFile: &spec.EncryptedFileSecret{Key: key}}
+	}
+
+	allowed := map[string][]string{
+		"group/project": {"database", "database.*", "tls.ca"},
+	}
+
+	tests := map[string]struct {
+		secret        spec.Secret
+		config        *common.EncryptedSecretsConfig
+		projectPath   string
+		expectedValue string
+		assertError   func(t *testing.T, err error)
+	}{
+		"unsupported secret": {
+			secret: spec.Secret{},
+			assertError: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, secrets.NewResolvingUnsupportedSecretError(resolverName))
+			},
+		},
+		"no encrypted secrets file configured": {
+			secret: secret("database.password"),
+			assertError: func(t *testing.T, err error) {
+				var configErr *secrets.ResolvingConfigurationError
+				assert.ErrorAs(t, err, &configErr)
+			},
+		},
+		"no key configured": {
+			secret: secret("database.password"),
+			config: &common.EncryptedSecretsConfig{Path: "secrets.yml.age", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				var configErr *secrets.ResolvingConfigurationError
+				assert.ErrorAs(t, err, &configErr)
+			},
+		},
+		"empty key variable": {
+			secret: secret("database.password"),
+			config: &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyEnv: "TEST_ENCRYPTED_SECRETS_EMPTY_KEY", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "encrypted secrets key variable TEST_ENCRYPTED_SECRETS_EMPTY_KEY is empty")
+			},
+		},
+		"missing file": {
+			secret: secret("database.password"),
+			config: &common.EncryptedSecretsConfig{Path: "missing.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			},
+		},
+		"key from file": {
+			secret:        secret("database.password"),
+			config:        &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			expectedValue: "s3cr3t",
+		},
+		"key from variable": {
+			secret:        secret("database.password"),
+			config:        &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyEnv: "TEST_ENCRYPTED_SECRETS_KEY", AllowedKeys: allowed},
+			expectedValue: "s3cr3t",
+		},
+		"absolute path": {
+			secret:        secret("database.password"),
+			config:        &common.EncryptedSecretsConfig{Path: absPath(t, "secrets.yml.age"), KeyFile: absPath(t, "key.txt"), AllowedKeys: allowed},
+			expectedValue: "s3cr3t",
+		},
+		"multiline value": {
+			secret:        secret("tls.ca"),
+			config:        &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			expectedValue: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		},
+		"key not found": {
+			secret: secret("database.user"),
+			config: &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, common.ErrSecretNotFound)
+			},
+		},
+		"key not allowed": {
+			secret: secret("API_KEY"),
+			config: &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				var configErr *secrets.ResolvingConfigurationError
+				assert.ErrorAs(t, err, &configErr)
+				assert.ErrorContains(t, err, `project "group/project" isn't allowed to read the key "API_KEY"`)
+			},
+		},
+		"project not allowed": {
+			secret:      secret("database.password"),
+			config:      &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			projectPath: "other/project",
+			assertError: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, `project "other/project" isn't allowed`)
+			},
+		},
+		"not a scalar value": {
+			secret: secret("database"),
+			config: &common.EncryptedSecretsConfig{Path: "secrets.yml.age", KeyFile: "key.txt", AllowedKeys: allowed},
+			assertError: func(t *testing.T, err error) {
+				var configErr *secrets.ResolvingConfigurationError
+				assert.ErrorAs(t, err, &configErr)
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			r := newResolver(tt.secret)
+			r.(common.RunnerConfigSecretResolver).SetRunnerConfig(&common.RunnerConfig{
+				ConfigDir: "testdata",
+				RunnerSettings: common.RunnerSettings{
+					EncryptedSecrets: tt.config,
+				},
+			})
+
+			project := "group/project"
+			if tt.projectPath != "" {
+				project = tt.projectPath
+			}
+			r.(common.JobInfoSecretResolver).SetJobInfo(spec.JobInfo{ProjectFullPath: project})
+
+			value, err := r.Resolve()
+			if tt.assertError != nil {
+				tt.assertError(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
+
+func absPath(t *testing.T, name string) string {
+	path, err := filepath.Abs(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	return path
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
@@ -19,8 +21,11 @@ type Client struct {
 	common.SshConfig
 
 	ConnectRetries int
+	ConnectTimeout time.Duration
 
-	client *ssh.Client
+	client      *ssh.Client
+	jumpClients []*ssh.Client
+	agentConn   net.Conn
 }
 
 type Command struct {
@@ -58,6 +63,44 @@ func (s *Client) getSSHKey(identityFile string) (key ssh.Signer, err error) {
 	return key, err
 }
 
+// getCertSigner returns a signer presenting the certificate of the key,
+// signed by the CA trusted by the server
+func getCertSigner(key ssh.Signer, certificateFile string) (ssh.Signer, error) {
+	buf, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(buf)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SSH certificate", certificateFile)
+	}
+
+	return ssh.NewCertSigner(cert, key)
+}
+
+func (s *Client) getAgentAuthMethod() (ssh.AuthMethod, error) {
+	if s.agentConn == nil {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, errors.New("SSH_AUTH_SOCK is not set")
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to SSH agent: %w", err)
+		}
+		s.agentConn = conn
+	}
+
+	return ssh.PublicKeysCallback(agent.NewClient(s.agentConn).Signers), nil
+}
+
 func (s *Client) getSSHAuthMethods() ([]ssh.AuthMethod, error) {
 	var methods []ssh.AuthMethod
 	methods = append(methods, ssh.Password(s.Password))
@@ -67,9 +110,68 @@ func (s *Client) getSSHAuthMethods() ([]ssh.AuthMethod, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		if s.CertificateFile != "" {
+			key, err = getCertSigner(key, s.CertificateFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading certificate: %w", err)
+			}
+		}
+
 		methods = append(methods, ssh.PublicKeys(key))
 	}
 
+	if s.UseAgent {
+		method, err := s.getAgentAuthMethod()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	return methods, nil
+}
+
+// getJumpHostAuthMethods returns the authentication methods of the bastion
+// host: its own identity file or, without one, the certificate of the remote
+// host, and the SSH agent when enabled. The password and the key without
+// certificate of the remote host are never offered to a bastion host.
+func (s *Client) getJumpHostAuthMethods(jumpHost common.SshJumpHost) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	switch {
+	case jumpHost.IdentityFile != "":
+		key, err := s.getSSHKey(jumpHost.IdentityFile)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(key))
+
+	case s.IdentityFile != "" && s.CertificateFile != "":
+		key, err := s.getSSHKey(s.IdentityFile)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err = getCertSigner(key, s.CertificateFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificate: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(key))
+	}
+
+	if s.UseAgent {
+		method, err := s.getAgentAuthMethod()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no authentication method: set the identity_file of the jump host, a certificate_file or use_agent")
+	}
+
 	return methods, nil
 }
 
@@ -90,6 +192,12 @@ func getHostKeyCallback(config common.SshConfig) (ssh.HostKeyCallback, error) {
 	return knownhosts.New(config.KnownHostsFile)
 }
 
+// hop is a host of the chain of the connection
+type hop struct {
+	address string
+	config  *ssh.ClientConfig
+}
+
 func (s *Client) Connect() error {
 	if s.Host == "" {
 		s.Host = "localhost"
@@ -106,16 +214,47 @@ func (s *Client) Connect() error {
 		return fmt.Errorf("getting SSH authentication methods: %w", err)
 	}
 
-	config := &ssh.ClientConfig{
-		User: s.User,
-		Auth: methods,
-	}
-
 	hostKeyCallback, err := getHostKeyCallback(s.SshConfig)
 	if err != nil {
 		return fmt.Errorf("getting host key callback: %w", err)
 	}
-	config.HostKeyCallback = hostKeyCallback
+
+	hops := make([]hop, 0, len(s.JumpHosts)+1)
+	for _, jumpHost := range s.JumpHosts {
+		user := jumpHost.User
+		if user == "" {
+			user = s.User
+		}
+		port := jumpHost.Port
+		if port == "" {
+			port = "22"
+		}
+
+		jumpMethods, err := s.getJumpHostAuthMethods(jumpHost)
+		if err != nil {
+			return fmt.Errorf("getting SSH authentication methods of jump host %s: %w", jumpHost.Host, err)
+		}
+
+		hops = append(hops, hop{
+			address: net.JoinHostPort(jumpHost.Host, port),
+			config: &ssh.ClientConfig{
+				User:            user,
+				Auth:            jumpMethods,
+				HostKeyCallback: hostKeyCallback,
+				Timeout:         s.ConnectTimeout,
+			},
+		})
+	}
+
+	hops = append(hops, hop{
+		address: net.JoinHostPort(s.Host, s.Port),
+		config: &ssh.ClientConfig{
+			User:            s.User,
+			Auth:            methods,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         s.ConnectTimeout,
+		},
+	})
 
 	connectRetries := s.ConnectRetries
 	if connectRetries == 0 {
@@ -125,9 +264,8 @@ func (s *Client) Connect() error {
 	var finalError error
 
 	for i := 0; i < connectRetries; i++ {
-		client, err := ssh.Dial("tcp", s.Host+":"+s.Port, config)
+		err := s.dial(hops)
 		if err == nil {
-			s.client = client
 			return nil
 		}
 
@@ -138,6 +276,72 @@ func (s *Client) Connect() error {
 	return finalError
 }
 
+// dial connects to the first host of the chain, and to each next host
+// through the connection to the previous one
+func (s *Client) dial(hops []hop) error {
+	var clients []*ssh.Client
+	closeClients := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			_ = clients[i].Close()
+		}
+	}
+
+	for i, h := range hops {
+		var client *ssh.Client
+		var err error
+
+		if i == 0 {
+			client, err = ssh.Dial("tcp", h.address, h.config)
+		} else {
+			client, err = dialThrough(clients[i-1], h)
+		}
+		if err != nil {
+			closeClients()
+			if i < len(hops)-1 {
+				return fmt.Errorf("jump host %s: %w", h.address, err)
+			}
+			return err
+		}
+
+		clients = append(clients, client)
+	}
+
+	s.client = clients[len(clients)-1]
+	s.jumpClients = clients[:len(clients)-1]
+
+	return nil
+}
+
+// dialThrough connects to the host through a tunnel of the jump client. The
+// tunneled connection doesn't support deadlines, so the timeout of the
+// handshake is enforced by closing the connection.
+func dialThrough(jumpClient *ssh.Client, h hop) (*ssh.Client, error) {
+	conn, err := jumpClient.Dial("tcp", h.address)
+	if err != nil {
+		return nil, err
+	}
+
+	timedOut := func() bool { return false }
+	if h.config.Timeout > 0 {
+		timer := time.AfterFunc(h.config.Timeout, func() { _ = conn.Close() })
+		timedOut = func() bool { return !timer.Stop() }
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, h.address, h.config)
+	if timedOut() {
+		if err == nil {
+			_ = c.Close()
+		}
+		return nil, fmt.Errorf("ssh: handshake timed out after %s", h.config.Timeout)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
 func (s *Client) Run(ctx context.Context, cmd Command) error {
 	if s.client == nil {
 		return errors.New("not connected")
@@ -181,4 +385,10 @@ func (s *Client) Cleanup() {
 	if s.client != nil {
 		_ = s.client.Close()
 	}
+	for i := len(s.jumpClients) - 1; i >= 0; i-- {
+		_ = s.jumpClients[i].Close()
+	}
+	if s.agentConn != nil {
+		_ = s.agentConn.Close()
+	}
 }
@@ -3,14 +3,25 @@
 package ssh_test
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/gitlab-runner/common"
 	"gitlab.com/gitlab-org/gitlab-runner/helpers/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 func TestStrictHostCheckingWithKnownHostsFile(t *testing.T) {
@@ -88,6 +99,281 @@ func TestStrictHostCheckingWithKnownHostsFile(t *testing.T) {
 	}
 }
 
+func newStubServer(t *testing.T, opts ...ssh.Option) *ssh.StubSSHServer {
+	s, err := ssh.NewStubServer("testuser", "testpass", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, s.Stop())
+	})
+
+	return s
+}
+
+// newKeyFile writes a new private key to a file, and returns the file and
+// the public key
+func newKeyFile(t *testing.T) (string, cryptossh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := cryptossh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	block, err := cryptossh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+
+	return keyFile, signer.PublicKey()
+}
+
+func TestConnectThroughJumpHosts(t *testing.T) {
+	disableHostChecking := true
+
+	bastionKeyFile, bastionKey := newKeyFile(t)
+
+	target := newStubServer(t)
+	firstBastion := newStubServer(t, ssh.WithExecuteLocal(), ssh.WithPublicKeyCallback(acceptPublicKey(bastionKey)))
+	secondBastion := newStubServer(t, ssh.WithExecuteLocal(), ssh.WithPublicKeyCallback(acceptPublicKey(bastionKey)))
+
+	unusedListener, err := net.Listen("tcp", "127.0.0.1:")
+	require.NoError(t, err)
+	_, unusedPort, err := net.SplitHostPort(unusedListener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, unusedListener.Close())
+
+	testCases := map[string]struct {
+		jumpHosts   []common.SshJumpHost
+		expectedErr string
+	}{
+		"single jump host": {
+			jumpHosts: []common.SshJumpHost{{Host: "127.0.0.1", Port: firstBastion.Port(), IdentityFile: bastionKeyFile}},
+		},
+		"chain of jump hosts": {
+			jumpHosts: []common.SshJumpHost{
+				{Host: "127.0.0.1", Port: firstBastion.Port(), IdentityFile: bastionKeyFile},
+				{Host: "127.0.0.1", Port: secondBastion.Port(), User: "testuser", IdentityFile: bastionKeyFile},
+			},
+		},
+		"unreachable jump host": {
+			jumpHosts:   []common.SshJumpHost{{Host: "127.0.0.1", Port: unusedPort, IdentityFile: bastionKeyFile}},
+			expectedErr: "jump host 127.0.0.1:" + unusedPort,
+		},
+		// the bastion accepts the password of the remote host, which must
+		// not be offered to it
+		"jump host without credentials": {
+			jumpHosts:   []common.SshJumpHost{{Host: "127.0.0.1", Port: firstBastion.Port()}},
+			expectedErr: "getting SSH authentication methods of jump host 127.0.0.1: no authentication method",
+		},
+		"jump host rejecting its key": {
+			jumpHosts:   []common.SshJumpHost{{Host: "127.0.0.1", Port: target.Port(), IdentityFile: bastionKeyFile}},
+			expectedErr: "jump host 127.0.0.1:" + target.Port(),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			c := target.Client()
+			c.ConnectRetries = 1
+			c.DisableStrictHostKeyChecking = &disableHostChecking
+			c.JumpHosts = tc.jumpHosts
+
+			err := c.Connect()
+			defer c.Cleanup()
+
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConnectThroughJumpHostTimeout(t *testing.T) {
+	disableHostChecking := true
+
+	bastionKeyFile, bastionKey := newKeyFile(t)
+	bastion := newStubServer(t, ssh.WithExecuteLocal(), ssh.WithPublicKeyCallback(acceptPublicKey(bastionKey)))
+
+	// the target accepts the connections, but never starts the handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:")
+	require.NoError(t, err)
+	defer silent.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		conn, err := silent.Accept()
+		if err != nil {
+			return
+		}
+		go func() { _, _ = io.Copy(io.Discard, conn) }()
+
+		<-done
+		_ = conn.Close()
+	}()
+
+	host, port, err := net.SplitHostPort(silent.Addr().String())
+	require.NoError(t, err)
+
+	c := bastion.Client()
+	c.Host = host
+	c.Port = port
+	c.ConnectRetries = 1
+	c.ConnectTimeout = 100 * time.Millisecond
+	c.DisableStrictHostKeyChecking = &disableHostChecking
+	c.JumpHosts = []common.SshJumpHost{{Host: "127.0.0.1", Port: bastion.Port(), IdentityFile: bastionKeyFile}}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Connect()
+	}()
+
+	select {
+	case err := <-errCh:
+		assert.ErrorContains(t, err, "handshake timed out")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "connecting through the jump host didn't time out")
+	}
+	c.Cleanup()
+}
+
+func acceptPublicKey(expected cryptossh.PublicKey) func(cryptossh.ConnMetadata, cryptossh.PublicKey) (*cryptossh.Permissions, error) {
+	return func(_ cryptossh.ConnMetadata, key cryptossh.PublicKey) (*cryptossh.Permissions, error) {
+		if bytes.Equal(key.Marshal(), expected.Marshal()) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown public key")
+	}
+}
+
+func TestConnectWithCertificate(t *testing.T) {
+	disableHostChecking := true
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ca, err := cryptossh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	key, err := cryptossh.ParsePrivateKey([]byte(ssh.TestSSHKeyPair.PrivateKey))
+	require.NoError(t, err)
+
+	cert := &cryptossh.Certificate{
+		Key:             key.PublicKey(),
+		CertType:        cryptossh.UserCert,
+		KeyId:           "runner",
+		ValidPrincipals: []string{"testuser"},
+		ValidBefore:     cryptossh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+
+	certificateFile := filepath.Join(t.TempDir(), "id_rsa_test-cert.pub")
+	require.NoError(t, os.WriteFile(certificateFile, cryptossh.MarshalAuthorizedKey(cert), 0o600))
+
+	checker := &cryptossh.CertChecker{
+		IsUserAuthority: func(auth cryptossh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		},
+	}
+	s := newStubServer(t, ssh.WithPublicKeyCallback(checker.Authenticate))
+
+	bastion := newStubServer(t, ssh.WithExecuteLocal(), ssh.WithPublicKeyCallback(checker.Authenticate))
+	jumpHosts := []common.SshJumpHost{{Host: "127.0.0.1", Port: bastion.Port()}}
+
+	testCases := map[string]struct {
+		certificateFile string
+		jumpHosts       []common.SshJumpHost
+		expectErr       bool
+	}{
+		"key signed by the CA": {
+			certificateFile: certificateFile,
+		},
+		"key without certificate": {
+			expectErr: true,
+		},
+		"jump host with the certificate of the remote host": {
+			certificateFile: certificateFile,
+			jumpHosts:       jumpHosts,
+		},
+		"jump host without certificate": {
+			jumpHosts: jumpHosts,
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			c := s.Client()
+			c.ConnectRetries = 1
+			c.Password = "wrong"
+			c.DisableStrictHostKeyChecking = &disableHostChecking
+			c.CertificateFile = tc.certificateFile
+			c.JumpHosts = tc.jumpHosts
+
+			err := c.Connect()
+			defer c.Cleanup()
+
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConnectWithAgent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the SSH agent is served on a unix socket")
+	}
+
+	disableHostChecking := true
+
+	key, err := cryptossh.ParseRawPrivateKey([]byte(ssh.TestSSHKeyPair.PrivateKey))
+	require.NoError(t, err)
+	signer, err := cryptossh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	s := newStubServer(t, ssh.WithPublicKeyCallback(acceptPublicKey(signer.PublicKey())))
+
+	c := s.Client()
+	c.ConnectRetries = 1
+	c.Password = "wrong"
+	c.IdentityFile = ""
+	c.UseAgent = true
+	c.DisableStrictHostKeyChecking = &disableHostChecking
+
+	err = c.Connect()
+	defer c.Cleanup()
+
+	assert.NoError(t, err)
+}
+
 var knownHostsWithGitlabOnly = `gitlab.com ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCsj2bNKTBSpIYDEGk9KxsGh3mySTRgMtXL583qmBpzeQ+jqCMRgBqB98u3z++J1sKlXHWfM9dyhSevkMwSbhoR8XIq/U0tCNyokEi/ueaBMCvbcTHhO7FcwzY92WK4Yt0aGROY5qX2UKSeOvuP4D6TPqKF1onrSzH9bx9XUf2lEdWT/ia1NEKjunUqu1xOB/StKDHMoX4/OKyIzuS0q/T1zOATthvasJFoPrAjkohTyaDUz2LN5JoH839hViyEG82yB+MjcFV5MU3N1l1QL3cVUCh93xSaua1N85qivl+siMkPGbO5xR/En4iEY6K2XPASUEMaieWVNTRCtJ4S8H+9
 gitlab.com ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBFSMqzJeV9rUzU4kWitGjeR4PWSa29SPqJ1fVkhtj3Hw9xjLVXVYrU9QlYWrOLXBpQ6KWjbjTDTdDkoohFzgbEY=
 gitlab.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAfuCHKVTjquxvt6CM6tdG4SLp1Btn/nOeHHE5UOzRdf`
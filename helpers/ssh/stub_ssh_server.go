@@ -69,6 +69,7 @@ type Option func(*Options)
 type Options struct {
 	DontAcceptConnections bool
 	ExecuteLocal          bool
+	PublicKeyCallback     func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error)
 }
 
 func WithDontAcceptConnections() Option {
@@ -83,6 +84,14 @@ func WithExecuteLocal() Option {
 	}
 }
 
+// WithPublicKeyCallback makes the server accept the public keys the callback
+// accepts, besides the password
+func WithPublicKeyCallback(callback func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error)) Option {
+	return func(o *Options) {
+		o.PublicKeyCallback = callback
+	}
+}
+
 func NewStubServer(user, pass string, opts ...Option) (server *StubSSHServer, err error) {
 	tempDir, err := os.MkdirTemp("", "ssh-stub-server")
 	if err != nil {
@@ -105,6 +114,7 @@ func NewStubServer(user, pass string, opts ...Option) (server *StubSSHServer, er
 				}
 				return nil, fmt.Errorf("wrong password for %q", conn.User())
 			},
+			PublicKeyCallback: options.PublicKeyCallback,
 		},
 		stopped: make(chan struct{}),
 		tempDir: tempDir,
@@ -0,0 +1,49 @@
+package otlp
+
+import (
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/webhook"
+)
+
+type options struct {
+	// headers are added to every export request, for example to
+	// authenticate with the collector.
+	headers map[string]string
+
+	// resourceAttributes describe the runner producing the records, and are
+	// merged with the default service.name=gitlab-runner attribute.
+	resourceAttributes map[string]string
+
+	// deliveryOptions configure the batching, retries and spooling of the
+	// export requests.
+	deliveryOptions []webhook.Option
+}
+
+type Option func(*options)
+
+func setupOptions(o ...Option) options {
+	var opts options
+
+	for _, opt := range o {
+		opt(&opts)
+	}
+
+	return opts
+}
+
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		o.headers = headers
+	}
+}
+
+func WithResourceAttributes(attributes map[string]string) Option {
+	return func(o *options) {
+		o.resourceAttributes = attributes
+	}
+}
+
+func WithDeliveryOptions(deliveryOptions ...webhook.Option) Option {
+	return func(o *options) {
+		o.deliveryOptions = append(o.deliveryOptions, deliveryOptions...)
+	}
+}
@@ -0,0 +1,139 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/webhook"
+)
+
+const (
+	logsPath  = "/v1/logs"
+	eventName = "gitlab_runner.job_usage"
+	scopeName = "gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+)
+
+// New returns a Storage exporting the records as OTLP log records to an
+// OTLP/HTTP endpoint, for example an OpenTelemetry collector. The records
+// are delivered in batches, retried and spooled like the webhook Storage.
+//
+// The endpoint is the base URL of the collector; /v1/logs is appended when
+// it has no path.
+func New(log logrus.FieldLogger, endpoint string, o ...Option) (*webhook.Writer, error) {
+	opts := setupOptions(o...)
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = logsPath
+	}
+
+	resource := resourceAttributes(opts.resourceAttributes)
+
+	webhookOptions := append([]webhook.Option{
+		webhook.WithHeaders(opts.headers),
+		webhook.WithEncoder(func(records []usage_log.Record) ([]byte, string, error) {
+			body, err := proto.Marshal(exportRequest(resource, records, time.Now()))
+			return body, "application/x-protobuf", err
+		}),
+	}, opts.deliveryOptions...)
+
+	return webhook.New(log, u.String(), webhookOptions...)
+}
+
+func resourceAttributes(attributes map[string]string) []*commonpb.KeyValue {
+	attrs := map[string]string{"service.name": "gitlab-runner"}
+	maps.Copy(attrs, attributes)
+
+	var kvs []*commonpb.KeyValue
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		kvs = append(kvs, stringAttribute(k, attrs[k]))
+	}
+
+	return kvs
+}
+
+func exportRequest(resource []*commonpb.KeyValue, records []usage_log.Record, observed time.Time) *collogspb.ExportLogsServiceRequest {
+	logRecords := make([]*logspb.LogRecord, 0, len(records))
+	for _, record := range records {
+		logRecords = append(logRecords, logRecord(record, observed))
+	}
+
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: resource},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: scopeName},
+				LogRecords: logRecords,
+			}},
+		}},
+	}
+}
+
+// logRecord maps the record to a log record, with the record as JSON body
+// and its main fields as attributes, so that they can be filtered and
+// aggregated without parsing the body.
+func logRecord(record usage_log.Record, observed time.Time) *logspb.LogRecord {
+	body, _ := json.Marshal(record)
+
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("gitlab.usage.uuid", record.UUID),
+		stringAttribute("gitlab.runner.uuid", record.Runner.UUID),
+		stringAttribute("gitlab.runner.id", record.Runner.ID),
+		stringAttribute("gitlab.runner.name", record.Runner.Name),
+		stringAttribute("gitlab.runner.system_id", record.Runner.SystemID),
+		stringAttribute("gitlab.runner.executor", record.Runner.Executor),
+		intAttribute("gitlab.job.id", record.Job.ID),
+		intAttribute("gitlab.pipeline.id", record.Job.PipelineID),
+		stringAttribute("gitlab.job.ref", record.Job.Ref),
+		stringAttribute("gitlab.job.url", record.Job.URL),
+		stringAttribute("gitlab.job.status", record.Job.Status),
+		stringAttribute("gitlab.job.failure_reason", record.Job.FailureReason),
+		{
+			Key:   "gitlab.job.duration_seconds",
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: record.Job.DurationSeconds}},
+		},
+		intAttribute("gitlab.project.id", record.Job.Project.ID),
+		stringAttribute("gitlab.project.full_path", record.Job.Project.FullPath),
+		intAttribute("gitlab.namespace.id", record.Job.Namespace.ID),
+		intAttribute("gitlab.root_namespace.id", record.Job.RootNamespace.ID),
+		intAttribute("gitlab.organization.id", record.Job.Organization.ID),
+		stringAttribute("gitlab.instance.id", record.Job.Instance.ID),
+		intAttribute("gitlab.user.id", record.Job.User.ID),
+	}
+	for _, k := range slices.Sorted(maps.Keys(record.Labels)) {
+		attributes = append(attributes, stringAttribute("gitlab.label."+k, record.Labels[k]))
+	}
+
+	return &logspb.LogRecord{
+		TimeUnixNano:         uint64(record.Timestamp.UnixNano()),
+		ObservedTimeUnixNano: uint64(observed.UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		EventName:            eventName,
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(body)}},
+		Attributes:           attributes,
+	}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+}
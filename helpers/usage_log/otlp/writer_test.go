@@ -0,0 +1,131 @@
+//go:build !integration
+
+package otlp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log/webhook"
+)
+
+func attributes(kvs []*commonpb.KeyValue) map[string]any {
+	m := map[string]any{}
+	for _, kv := range kvs {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			m[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_IntValue:
+			m[kv.GetKey()] = v.IntValue
+		case *commonpb.AnyValue_DoubleValue:
+			m[kv.GetKey()] = v.DoubleValue
+		}
+	}
+
+	return m
+}
+
+func TestWriter_Export(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*collogspb.ExportLogsServiceRequest
+		headers  []http.Header
+		paths    []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		req := &collogspb.ExportLogsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, req))
+
+		mu.Lock()
+		requests = append(requests, req)
+		headers = append(headers, r.Header)
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	w, err := New(logger, server.URL,
+		WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+		WithResourceAttributes(map[string]string{"deployment.environment": "test"}),
+		WithDeliveryOptions(webhook.WithFlushInterval(time.Hour)),
+	)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := usage_log.Record{
+		UUID:      "record-uuid",
+		Timestamp: timestamp,
+		Runner:    usage_log.Runner{ID: "42", Name: "runner", Executor: "docker"},
+		Job: usage_log.Job{
+			ID:              1234,
+			Status:          "success",
+			DurationSeconds: 12.5,
+			Project:         usage_log.Project{ID: 7, FullPath: "group/project"},
+		},
+		Labels: map[string]string{"team": "ci"},
+	}
+	require.NoError(t, w.Store(record))
+	require.NoError(t, w.Close())
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/logs", paths[0])
+	assert.Equal(t, "application/x-protobuf", headers[0].Get("Content-Type"))
+	assert.Equal(t, "Bearer token", headers[0].Get("Authorization"))
+
+	resourceLogs := requests[0].GetResourceLogs()
+	require.Len(t, resourceLogs, 1)
+	assert.Equal(t, map[string]any{
+		"service.name":           "gitlab-runner",
+		"deployment.environment": "test",
+	}, attributes(resourceLogs[0].GetResource().GetAttributes()))
+
+	scopeLogs := resourceLogs[0].GetScopeLogs()
+	require.Len(t, scopeLogs, 1)
+	require.Len(t, scopeLogs[0].GetLogRecords(), 1)
+
+	logRecord := scopeLogs[0].GetLogRecords()[0]
+	assert.Equal(t, uint64(timestamp.UnixNano()), logRecord.GetTimeUnixNano())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, logRecord.GetSeverityNumber())
+	assert.Equal(t, "gitlab_runner.job_usage", logRecord.GetEventName())
+
+	attrs := attributes(logRecord.GetAttributes())
+	assert.Equal(t, "42", attrs["gitlab.runner.id"])
+	assert.Equal(t, int64(1234), attrs["gitlab.job.id"])
+	assert.Equal(t, "success", attrs["gitlab.job.status"])
+	assert.Equal(t, 12.5, attrs["gitlab.job.duration_seconds"])
+	assert.Equal(t, int64(7), attrs["gitlab.project.id"])
+	assert.Equal(t, "ci", attrs["gitlab.label.team"])
+
+	var body usage_log.Record
+	require.NoError(t, json.Unmarshal([]byte(logRecord.GetBody().GetStringValue()), &body))
+	assert.Equal(t, record.UUID, body.UUID)
+	assert.Equal(t, record.Job.ID, body.Job.ID)
+}
+
+func TestNew_Endpoint(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+
+	for _, endpoint := range []string{"", "collector:4318", "://invalid"} {
+		_, err := New(logger, endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
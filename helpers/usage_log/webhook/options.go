@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+)
+
+const (
+	defaultBatchSize       = 100
+	defaultFlushInterval   = 10 * time.Second
+	defaultMaxTries        = 5
+	defaultRetryMinBackoff = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+	defaultTimeout         = 30 * time.Second
+	defaultMaxSpoolFiles   = 1000
+)
+
+// Encoder encodes a batch of records into the body of a request, and
+// returns the content type of the body.
+type Encoder func(records []usage_log.Record) (body []byte, contentType string, err error)
+
+type options struct {
+	// headers are added to every request, for example to authenticate.
+	headers map[string]string
+
+	// batchSize is the number of records that triggers a delivery. Fewer
+	// records are delivered every flushInterval.
+	batchSize     int
+	flushInterval time.Duration
+
+	// maxTries is the number of delivery attempts of a batch before it's
+	// spooled.
+	maxTries        int
+	retryMinBackoff time.Duration
+	retryMaxBackoff time.Duration
+
+	// spoolDirectory stores the batches that couldn't be delivered, which
+	// are delivered again once the endpoint is back. Spooling is disabled
+	// when empty, and the batches are dropped.
+	spoolDirectory string
+	// maxSpoolFiles is the number of spooled batches kept. The oldest are
+	// dropped first.
+	maxSpoolFiles int
+
+	// labels are extra key-value pairs merged into every record.
+	labels map[string]string
+
+	encoder    Encoder
+	httpClient *http.Client
+}
+
+type Option func(*options)
+
+func setupOptions(o ...Option) options {
+	opts := options{
+		batchSize:       defaultBatchSize,
+		flushInterval:   defaultFlushInterval,
+		maxTries:        defaultMaxTries,
+		retryMinBackoff: defaultRetryMinBackoff,
+		retryMaxBackoff: defaultRetryMaxBackoff,
+		maxSpoolFiles:   defaultMaxSpoolFiles,
+		encoder:         encodeJSON,
+		httpClient:      &http.Client{Timeout: defaultTimeout},
+	}
+
+	for _, opt := range o {
+		opt(&opts)
+	}
+
+	return opts
+}
+
+// encodeJSON encodes the records as a JSON array.
+func encodeJSON(records []usage_log.Record) ([]byte, string, error) {
+	body, err := json.Marshal(records)
+	return body, "application/json", err
+}
+
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		o.headers = headers
+	}
+}
+
+func WithBatchSize(batchSize int) Option {
+	return func(o *options) {
+		if batchSize > 0 {
+			o.batchSize = batchSize
+		}
+	}
+}
+
+func WithFlushInterval(flushInterval time.Duration) Option {
+	return func(o *options) {
+		if flushInterval > 0 {
+			o.flushInterval = flushInterval
+		}
+	}
+}
+
+func WithMaxTries(maxTries int) Option {
+	return func(o *options) {
+		if maxTries > 0 {
+			o.maxTries = maxTries
+		}
+	}
+}
+
+func WithRetryBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		o.retryMinBackoff = min
+		o.retryMaxBackoff = max
+	}
+}
+
+func WithSpoolDirectory(dir string) Option {
+	return func(o *options) {
+		o.spoolDirectory = dir
+	}
+}
+
+func WithMaxSpoolFiles(maxSpoolFiles int) Option {
+	return func(o *options) {
+		if maxSpoolFiles > 0 {
+			o.maxSpoolFiles = maxSpoolFiles
+		}
+	}
+}
+
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) {
+		o.labels = labels
+	}
+}
+
+// WithEncoder replaces the default encoding of the batches, a JSON array of
+// records.
+func WithEncoder(encoder Encoder) Option {
+	return func(o *options) {
+		o.encoder = encoder
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
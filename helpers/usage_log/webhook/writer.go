@@ -0,0 +1,337 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/retry"
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+)
+
+const (
+	spoolFilePrefix = "batch-"
+	spoolFileExt    = ".json"
+)
+
+var (
+	ErrDelivery = errors.New("delivering records")
+
+	// errPermanent marks the delivery errors that retrying won't fix.
+	errPermanent = errors.New("permanent failure")
+)
+
+// Writer delivers the records in batches to an HTTP endpoint. The batches
+// are sent from a background goroutine, retried with backoff, and spooled
+// to disk when the endpoint stays unavailable.
+type Writer struct {
+	options options
+	url     string
+	log     logrus.FieldLogger
+
+	mu      sync.Mutex
+	pending []usage_log.Record
+	closed  bool
+
+	flush   chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	spoolSeq int
+}
+
+func New(log logrus.FieldLogger, url string, o ...Option) (*Writer, error) {
+	if url == "" {
+		return nil, errors.New("missing URL")
+	}
+
+	opts := setupOptions(o...)
+
+	if opts.spoolDirectory != "" {
+		if err := os.MkdirAll(opts.spoolDirectory, 0o700); err != nil {
+			return nil, fmt.Errorf("creating spool directory: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &Writer{
+		options: opts,
+		url:     url,
+		log:     log,
+		flush:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Store queues the record for delivery with the next batch.
+func (w *Writer) Store(record usage_log.Record) error {
+	if len(w.options.labels) > 0 {
+		labels := make(map[string]string, len(record.Labels)+len(w.options.labels))
+		maps.Copy(labels, record.Labels)
+		maps.Copy(labels, w.options.labels)
+		record.Labels = labels
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return usage_log.ErrStorageIsClosed
+	}
+
+	w.pending = append(w.pending, record)
+	if len(w.pending) >= w.options.batchSize {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close delivers the pending records, with a single attempt, spooling them
+// on failure, and stops the writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	w.cancel()
+	close(w.stop)
+	<-w.stopped
+
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.options.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			w.deliverPending()
+			return
+		case <-ticker.C:
+		case <-w.flush:
+		}
+
+		if w.deliverPending() {
+			w.deliverSpooled()
+		}
+	}
+}
+
+func (w *Writer) takeBatch() []usage_log.Record {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := min(len(w.pending), w.options.batchSize)
+	batch := w.pending[:n:n]
+	w.pending = w.pending[n:]
+
+	return batch
+}
+
+// deliverPending delivers the pending records, batch by batch, and returns
+// whether the endpoint accepted them all.
+func (w *Writer) deliverPending() bool {
+	ok := true
+	for batch := w.takeBatch(); len(batch) > 0; batch = w.takeBatch() {
+		err := w.deliver(batch)
+		if err == nil {
+			continue
+		}
+
+		ok = false
+		if errors.Is(err, errPermanent) {
+			w.log.WithError(err).WithField("records", len(batch)).Error("Dropping usage records rejected by the endpoint")
+			continue
+		}
+
+		if err := w.spool(batch); err != nil {
+			w.log.WithError(err).WithField("records", len(batch)).Error("Dropping usage records that couldn't be delivered")
+		}
+	}
+
+	return ok
+}
+
+// deliver sends the batch, retrying on temporary failures. Once the writer
+// is closing, a single attempt is made.
+func (w *Writer) deliver(batch []usage_log.Record) error {
+	body, contentType, err := w.options.encoder(batch)
+	if err != nil {
+		return fmt.Errorf("%w: encoding: %w: %w", ErrDelivery, errPermanent, err)
+	}
+
+	r := retry.New().
+		WithContext(w.ctx).
+		WithCheck(func(_ int, err error) bool {
+			return !errors.Is(err, errPermanent)
+		}).
+		WithMaxTries(w.options.maxTries).
+		WithBackoff(w.options.retryMinBackoff, w.options.retryMaxBackoff).
+		WithBackoffJitter()
+
+	err = retry.NewNoValue(r, func() error {
+		err := w.send(body, contentType)
+		if err != nil && !errors.Is(err, errPermanent) {
+			w.log.WithError(err).WithField("records", len(batch)).Warning("Failed to deliver usage records")
+		}
+		return err
+	}).Run()
+
+	if errors.Is(err, context.Canceled) {
+		// the writer is closing: make a last attempt
+		err = w.send(body, contentType)
+	}
+
+	return err
+}
+
+func (w *Writer) send(body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w: %w", ErrDelivery, errPermanent, err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range w.options.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.options.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDelivery, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return fmt.Errorf("%w: unexpected status %s", ErrDelivery, resp.Status)
+	default:
+		return fmt.Errorf("%w: %w: unexpected status %s", ErrDelivery, errPermanent, resp.Status)
+	}
+}
+
+// spool stores the batch in the spool directory, dropping the oldest
+// batches beyond the maximum.
+func (w *Writer) spool(batch []usage_log.Record) error {
+	if w.options.spoolDirectory == "" {
+		return errors.New("spooling disabled")
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("encoding spooled batch: %w", err)
+	}
+
+	w.spoolSeq++
+	name := fmt.Sprintf("%s%020d-%06d%s", spoolFilePrefix, time.Now().UnixNano(), w.spoolSeq, spoolFileExt)
+	if err := os.WriteFile(filepath.Join(w.options.spoolDirectory, name), data, 0o600); err != nil {
+		return fmt.Errorf("writing spooled batch: %w", err)
+	}
+
+	w.log.WithFields(logrus.Fields{"records": len(batch), "file": name}).Warning("Spooled usage records")
+
+	files := w.spooledFiles()
+	for _, file := range files[:max(0, len(files)-w.options.maxSpoolFiles)] {
+		w.log.WithField("file", file).Error("Dropping spooled usage records, too many batches spooled")
+		_ = os.Remove(filepath.Join(w.options.spoolDirectory, file))
+	}
+
+	return nil
+}
+
+// deliverSpooled delivers the spooled batches, oldest first, until one
+// fails.
+func (w *Writer) deliverSpooled() {
+	for _, file := range w.spooledFiles() {
+		path := filepath.Join(w.options.spoolDirectory, file)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			w.log.WithError(err).WithField("file", file).Error("Failed to read spooled usage records")
+			continue
+		}
+
+		var batch []usage_log.Record
+		if err := json.Unmarshal(data, &batch); err != nil {
+			w.log.WithError(err).WithField("file", file).Error("Dropping invalid spooled usage records")
+			_ = os.Remove(path)
+			continue
+		}
+
+		err = w.deliver(batch)
+		if err != nil && !errors.Is(err, errPermanent) {
+			return
+		}
+		if err != nil {
+			w.log.WithError(err).WithField("file", file).Error("Dropping spooled usage records rejected by the endpoint")
+		}
+
+		_ = os.Remove(path)
+	}
+}
+
+// spooledFiles returns the names of the spooled batches, oldest first.
+func (w *Writer) spooledFiles() []string {
+	if w.options.spoolDirectory == "" {
+		return nil
+	}
+
+	entries, _ := os.ReadDir(w.options.spoolDirectory)
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, spoolFilePrefix) || !strings.HasSuffix(name, spoolFileExt) {
+			continue
+		}
+		if _, err := strconv.ParseInt(strings.SplitN(strings.TrimPrefix(name, spoolFilePrefix), "-", 2)[0], 10, 64); err != nil {
+			continue
+		}
+		files = append(files, name)
+	}
+	slices.Sort(files)
+
+	return files
+}
@@ -0,0 +1,205 @@
+//go:build !integration
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/helpers/usage_log"
+)
+
+type endpoint struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	batches [][]usage_log.Record
+	headers []http.Header
+	status  atomic.Int32
+}
+
+func newEndpoint(t *testing.T) *endpoint {
+	e := &endpoint{}
+	e.status.Store(http.StatusOK)
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := int(e.status.Load())
+		if status == http.StatusOK {
+			var batch []usage_log.Record
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+
+			e.mu.Lock()
+			e.batches = append(e.batches, batch)
+			e.headers = append(e.headers, r.Header)
+			e.mu.Unlock()
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(e.Close)
+
+	return e
+}
+
+func (e *endpoint) received() [][]usage_log.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([][]usage_log.Record(nil), e.batches...)
+}
+
+func testLogger() logrus.FieldLogger {
+	logger, _ := test.NewNullLogger()
+	return logger
+}
+
+func testRecord(id int64) usage_log.Record {
+	return usage_log.Record{
+		UUID:      "uuid",
+		Timestamp: time.Now().UTC(),
+		Job:       usage_log.Job{ID: id, DurationSeconds: 1.5},
+	}
+}
+
+func TestWriter_BatchSize(t *testing.T) {
+	e := newEndpoint(t)
+
+	w, err := New(testLogger(), e.URL,
+		WithBatchSize(2),
+		WithFlushInterval(time.Hour),
+		WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+		WithLabels(map[string]string{"env": "test"}),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, w.Store(testRecord(1)))
+	require.NoError(t, w.Store(testRecord(2)))
+
+	require.Eventually(t, func() bool { return len(e.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	batch := e.received()[0]
+	require.Len(t, batch, 2)
+	assert.Equal(t, int64(1), batch[0].Job.ID)
+	assert.Equal(t, int64(2), batch[1].Job.ID)
+	assert.Equal(t, map[string]string{"env": "test"}, batch[0].Labels)
+	assert.Equal(t, "Bearer token", e.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", e.headers[0].Get("Content-Type"))
+
+	require.NoError(t, w.Store(testRecord(3)))
+	require.NoError(t, w.Close())
+
+	require.Len(t, e.received(), 2, "pending records are delivered on close")
+	assert.Equal(t, int64(3), e.received()[1][0].Job.ID)
+
+	assert.ErrorIs(t, w.Store(testRecord(4)), usage_log.ErrStorageIsClosed)
+	assert.NoError(t, w.Close())
+}
+
+func TestWriter_FlushInterval(t *testing.T) {
+	e := newEndpoint(t)
+
+	w, err := New(testLogger(), e.URL, WithFlushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.Store(testRecord(1)))
+	require.Eventually(t, func() bool { return len(e.received()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWriter_Spool(t *testing.T) {
+	e := newEndpoint(t)
+	e.status.Store(http.StatusServiceUnavailable)
+
+	dir := t.TempDir()
+	w, err := New(testLogger(), e.URL,
+		WithFlushInterval(10*time.Millisecond),
+		WithMaxTries(2),
+		WithRetryBackoff(time.Millisecond, time.Millisecond),
+		WithSpoolDirectory(dir),
+	)
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.Store(testRecord(1)))
+	require.Eventually(t, func() bool { return len(w.spooledFiles()) == 1 }, time.Second, 10*time.Millisecond)
+
+	e.status.Store(http.StatusOK)
+	require.Eventually(t, func() bool { return len(e.received()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), e.received()[0][0].Job.ID)
+	assert.Eventually(t, func() bool { return len(w.spooledFiles()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWriter_SpoolOnClose(t *testing.T) {
+	e := newEndpoint(t)
+	e.status.Store(http.StatusBadGateway)
+
+	dir := t.TempDir()
+	w, err := New(testLogger(), e.URL, WithFlushInterval(time.Hour), WithSpoolDirectory(dir))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Store(testRecord(1)))
+	require.NoError(t, w.Close())
+	require.Len(t, w.spooledFiles(), 1)
+
+	// a new writer delivers the records spooled by the previous one
+	e.status.Store(http.StatusOK)
+	w, err = New(testLogger(), e.URL, WithFlushInterval(10*time.Millisecond), WithSpoolDirectory(dir))
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.Eventually(t, func() bool { return len(e.received()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWriter_MaxSpoolFiles(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{
+		options: setupOptions(WithSpoolDirectory(dir), WithMaxSpoolFiles(2)),
+		log:     testLogger(),
+	}
+
+	for i := range 3 {
+		require.NoError(t, w.spool([]usage_log.Record{testRecord(int64(i))}))
+	}
+
+	files := w.spooledFiles()
+	require.Len(t, files, 2)
+
+	data, err := os.ReadFile(dir + "/" + files[0])
+	require.NoError(t, err)
+	var batch []usage_log.Record
+	require.NoError(t, json.Unmarshal(data, &batch))
+	assert.Equal(t, int64(1), batch[0].Job.ID, "the oldest batch is dropped")
+}
+
+func TestWriter_PermanentFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	w, err := New(testLogger(), server.URL, WithFlushInterval(time.Hour), WithSpoolDirectory(dir))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Store(testRecord(1)))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, int32(1), requests.Load(), "rejected batches aren't retried")
+	assert.Empty(t, w.spooledFiles(), "rejected batches aren't spooled")
+}
+
+func TestNew_MissingURL(t *testing.T) {
+	_, err := New(testLogger(), "")
+	assert.Error(t, err)
+}
@@ -20,8 +20,11 @@ import (
 
 var supportedKubernetesClientTypes = []string{
 	"kubernetes.Interface",
+	"dynamic.Interface",
 
 	"*selfManagedInformerFactory",
+	"dynamicinformer.DynamicSharedInformerFactory",
+	"informers.SharedInformerFactory",
 }
 
 type simplePosition struct {
@@ -130,6 +133,9 @@ var knownAPIGroups = []string{
 	"networking.k8s.io",
 	"policy",
 	"scheduling.k8s.io",
+	"runner.gitlab.com",
+	"kueue.x-k8s.io",
+	"metrics.k8s.io",
 }
 
 // ParseResourceKey parses a resource key from format "apiGroup/resource" or "resource".
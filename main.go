@@ -34,10 +34,12 @@ import (
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/azure"
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/gcs"
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/gcsv2"
+	_ "gitlab.com/gitlab-org/gitlab-runner/cache/local"
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/s3"
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/s3v2"
 	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/aws"
 	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/azure_key_vault"
+	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/encrypted_file"
 	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/gcp_secret_manager"
 	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/gitlab_secrets_manager"
 	_ "gitlab.com/gitlab-org/gitlab-runner/helpers/secrets/resolvers/vault"
@@ -102,9 +104,13 @@ func main() {
 
 func newCommands(n common.Network, apiRequestsCollector *network.APIRequestsCollector, executorProviders executors.Providers) []cli.Command {
 	cmds := []cli.Command{
+		commands.NewCacheCommand(),
+		commands.NewGenerateScriptCommand(executorProviders),
+		commands.NewKubernetesControllerCommand(),
 		commands.NewListCommand(),
 		commands.NewLintCommand(),
 		commands.NewRegisterCommand(n, executorProviders),
+		commands.NewReplayCommand(executorProviders),
 		commands.NewResetTokenCommand(n),
 		commands.NewRunCommand(n, apiRequestsCollector, executorProviders),
 		commands.NewRunSingleCommand(n, executorProviders),
@@ -117,6 +123,7 @@ func newCommands(n common.Network, apiRequestsCollector *network.APIRequestsColl
 		helpers.NewCacheArchiverCommand(),
 		helpers.NewCacheExtractorCommand(),
 		helpers.NewCacheInitCommand(),
+		helpers.NewEgressProxyCommand(),
 		helpers.NewHealthCheckCommand(),
 		helpers.NewProxyExecCommand(),
 		helpers.NewReadLogsCommand(),
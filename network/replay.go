@@ -0,0 +1,273 @@
+package network
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+	"gitlab.com/gitlab-org/gitlab-runner/common/spec"
+)
+
+var _ common.Network = (*ReplayClient)(nil)
+
+// ReplayClient is an in-process stand-in for GitLab, used to run a recorded
+// job without a GitLab instance. It writes the job log to a writer and
+// stores the artifacts uploaded by the runner, and by the job through
+// ArtifactsHandler, in a local directory.
+type ReplayClient struct {
+	trace        io.Writer
+	artifactsDir string
+
+	lock      sync.Mutex
+	sentTrace map[int64]int
+	states    map[int64]common.UpdateJobInfo
+	tokens    map[int64]string
+}
+
+func NewReplayClient(trace io.Writer, artifactsDir string) *ReplayClient {
+	return &ReplayClient{
+		trace:        trace,
+		artifactsDir: artifactsDir,
+		sentTrace:    map[int64]int{},
+		states:       map[int64]common.UpdateJobInfo{},
+		tokens:       map[int64]string{},
+	}
+}
+
+// JobInfo returns the last state reported for the job.
+func (n *ReplayClient) JobInfo(id int64) (common.UpdateJobInfo, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	info, ok := n.states[id]
+	return info, ok
+}
+
+func (n *ReplayClient) SetConnectionMaxAge(time.Duration) {}
+
+func (n *ReplayClient) RegisterRunner(common.RunnerConfig, common.RegisterRunnerParameters) *common.RegisterRunnerResponse {
+	return nil
+}
+
+func (n *ReplayClient) VerifyRunner(common.RunnerConfig, string) *common.VerifyRunnerResponse {
+	return nil
+}
+
+func (n *ReplayClient) UnregisterRunner(common.RunnerConfig) bool {
+	return false
+}
+
+func (n *ReplayClient) UnregisterRunnerManager(common.RunnerConfig, string) bool {
+	return false
+}
+
+func (n *ReplayClient) ResetToken(common.RunnerConfig, string) *common.ResetTokenResponse {
+	return nil
+}
+
+func (n *ReplayClient) ResetTokenWithPAT(common.RunnerConfig, string, string) *common.ResetTokenResponse {
+	return nil
+}
+
+// RequestJob never returns a job: replayed jobs are passed to the build
+// directly.
+func (n *ReplayClient) RequestJob(context.Context, common.RunnerConfig, *common.SessionInfo) (*spec.Job, bool) {
+	return nil, true
+}
+
+func (n *ReplayClient) UpdateJob(
+	config common.RunnerConfig,
+	jobCredentials *common.JobCredentials,
+	jobInfo common.UpdateJobInfo,
+) common.UpdateJobResult {
+	n.lock.Lock()
+	n.states[jobInfo.ID] = jobInfo
+	n.lock.Unlock()
+
+	config.Log().WithFields(logrus.Fields{
+		"job":            jobInfo.ID,
+		"state":          jobInfo.State,
+		"failure_reason": jobInfo.FailureReason,
+		"exit_code":      jobInfo.ExitCode,
+	}).Debugln("Updating job...")
+
+	return common.UpdateJobResult{State: common.UpdateSucceeded}
+}
+
+// PatchTrace writes the part of the patch that wasn't written yet.
+func (n *ReplayClient) PatchTrace(
+	_ common.RunnerConfig,
+	jobCredentials *common.JobCredentials,
+	content []byte,
+	startOffset int,
+	_ bool,
+) common.PatchTraceResult {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	sent := n.sentTrace[jobCredentials.ID]
+	if startOffset > sent {
+		return common.NewPatchTraceResult(sent, common.PatchRangeMismatch, 0)
+	}
+
+	if skip := sent - startOffset; skip < len(content) {
+		written, err := n.trace.Write(content[skip:])
+		sent += written
+		n.sentTrace[jobCredentials.ID] = sent
+		if err != nil {
+			logrus.WithError(err).WithField("job", jobCredentials.ID).Errorln("Appending trace...", "failed")
+			return common.NewPatchTraceResult(sent, common.PatchFailed, 0)
+		}
+	}
+
+	return common.NewPatchTraceResult(sent, common.PatchSucceeded, 0)
+}
+
+// DownloadArtifacts always reports the artifacts as missing, as only the
+// artifacts of the replayed job are stored.
+func (n *ReplayClient) DownloadArtifacts(common.JobCredentials, io.WriteCloser, *bool) common.DownloadState {
+	return common.DownloadNotFound
+}
+
+// UploadRawArtifacts stores the artifacts in the job's directory of the
+// artifacts directory.
+func (n *ReplayClient) UploadRawArtifacts(
+	config common.JobCredentials,
+	bodyProvider common.ContentProvider,
+	options common.ArtifactsOptions,
+) (common.UploadState, string, error) {
+	r, err := bodyProvider.GetReader()
+	if err != nil {
+		return common.UploadFailed, "", err
+	}
+	defer r.Close()
+
+	if _, err := n.storeArtifacts(config.ID, options.BaseName, options.Type, r); err != nil {
+		return common.UploadFailed, "", err
+	}
+
+	return common.UploadSucceeded, "", nil
+}
+
+// ArtifactsHandler serves the artifacts endpoints of the GitLab API used by
+// the job. Uploaded artifacts are stored like those of UploadRawArtifacts,
+// and downloads always report the artifacts as missing. Requests must hold
+// the token of a job processed by the client.
+func (n *ReplayClient) ArtifactsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v4/jobs/{id}/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := n.authorizeArtifactsRequest(w, r)
+		if !ok {
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		if _, err := n.storeArtifacts(id, header.Filename, r.URL.Query().Get("artifact_type"), file); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("GET /api/v4/jobs/{id}/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := n.authorizeArtifactsRequest(w, r); ok {
+			http.NotFound(w, r)
+		}
+	})
+
+	return mux
+}
+
+// authorizeArtifactsRequest returns the ID of the job of the request, after
+// checking that the request holds its token
+func (n *ReplayClient) authorizeArtifactsRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return 0, false
+	}
+
+	n.lock.Lock()
+	token, ok := n.tokens[id]
+	n.lock.Unlock()
+
+	if !ok || subtle.ConstantTimeCompare([]byte(r.Header.Get(common.JobToken)), []byte(token)) != 1 {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// storeArtifacts writes the artifacts to the job's directory of the
+// artifacts directory, and returns their path
+func (n *ReplayClient) storeArtifacts(id int64, baseName string, artifactType string, r io.Reader) (string, error) {
+	dir := filepath.Join(n.artifactsDir, strconv.FormatInt(id, 10))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, filepath.Base(baseName))
+	if err := writeArtifacts(path, r); err != nil {
+		return "", fmt.Errorf("storing artifacts: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":   id,
+		"type": artifactType,
+		"path": path,
+	}).Infoln("Uploading artifacts...", "stored")
+
+	return path, nil
+}
+
+func writeArtifacts(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (n *ReplayClient) ProcessJob(config common.RunnerConfig, jobCredentials *common.JobCredentials) (common.JobTrace, error) {
+	n.lock.Lock()
+	n.tokens[jobCredentials.ID] = jobCredentials.Token
+	n.lock.Unlock()
+
+	l := logrus.New().WithFields(logrus.Fields{
+		"runner":      config.ShortDescription(),
+		"runner_name": config.Name,
+		"job":         jobCredentials.ID,
+	})
+
+	trace, err := newJobTrace(n, config, jobCredentials, l)
+	if err != nil {
+		return nil, fmt.Errorf("create job trace: %w", err)
+	}
+
+	trace.start()
+	return trace, nil
+}
@@ -0,0 +1,132 @@
+//go:build !integration
+
+package network
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gitlab-org/gitlab-runner/common"
+)
+
+func TestReplayClientPatchTrace(t *testing.T) {
+	out := new(bytes.Buffer)
+	client := NewReplayClient(out, t.TempDir())
+	credentials := &common.JobCredentials{ID: 1}
+
+	result := client.PatchTrace(jobConfig, credentials, []byte("hello "), 0, false)
+	assert.Equal(t, common.PatchSucceeded, result.State)
+	assert.Equal(t, 6, result.SentOffset)
+
+	// a resent patch only writes what wasn't written yet
+	result = client.PatchTrace(jobConfig, credentials, []byte("lo world"), 3, false)
+	assert.Equal(t, common.PatchSucceeded, result.State)
+	assert.Equal(t, 11, result.SentOffset)
+
+	result = client.PatchTrace(jobConfig, credentials, []byte("!"), 20, false)
+	assert.Equal(t, common.PatchRangeMismatch, result.State)
+	assert.Equal(t, 11, result.SentOffset)
+
+	assert.Equal(t, "hello world", out.String())
+}
+
+func TestReplayClientUpdateJob(t *testing.T) {
+	client := NewReplayClient(new(bytes.Buffer), t.TempDir())
+
+	_, ok := client.JobInfo(1)
+	assert.False(t, ok)
+
+	result := client.UpdateJob(jobConfig, &common.JobCredentials{ID: 1}, common.UpdateJobInfo{
+		ID:       1,
+		State:    common.Failed,
+		ExitCode: 2,
+	})
+	assert.Equal(t, common.UpdateSucceeded, result.State)
+
+	info, ok := client.JobInfo(1)
+	require.True(t, ok)
+	assert.Equal(t, common.Failed, info.State)
+	assert.Equal(t, 2, info.ExitCode)
+}
+
+func TestReplayClientUploadRawArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	client := NewReplayClient(new(bytes.Buffer), dir)
+
+	state, _, err := client.UploadRawArtifacts(
+		common.JobCredentials{ID: 1},
+		common.BytesProvider{Data: []byte("metrics")},
+		common.ArtifactsOptions{BaseName: "metrics_referee.zip", Type: "metrics_referee"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, common.UploadSucceeded, state)
+
+	data, err := os.ReadFile(filepath.Join(dir, "1", "metrics_referee.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, "metrics", string(data))
+}
+
+func TestReplayClientArtifactsHandler(t *testing.T) {
+	dir := t.TempDir()
+	client := NewReplayClient(new(bytes.Buffer), dir)
+
+	_, err := client.ProcessJob(jobConfig, &common.JobCredentials{ID: 1, Token: "job-token"})
+	require.NoError(t, err)
+
+	upload := func(id string, token string) *http.Response {
+		body := new(bytes.Buffer)
+		form := multipart.NewWriter(body)
+		file, err := form.CreateFormFile("file", "../artifacts.zip")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("artifacts"))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v4/jobs/"+id+"/artifacts?artifact_type=archive", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set(common.JobToken, token)
+
+		rec := httptest.NewRecorder()
+		client.ArtifactsHandler().ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	assert.Equal(t, http.StatusForbidden, upload("1", "other-token").StatusCode)
+	assert.Equal(t, http.StatusForbidden, upload("2", "job-token").StatusCode)
+	assert.Equal(t, http.StatusCreated, upload("1", "job-token").StatusCode)
+
+	data, err := os.ReadFile(filepath.Join(dir, "1", "artifacts.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, "artifacts", string(data))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v4/jobs/1/artifacts", nil)
+	req.Header.Set(common.JobToken, "job-token")
+	rec := httptest.NewRecorder()
+	client.ArtifactsHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestReplayClientProcessJob(t *testing.T) {
+	out := new(bytes.Buffer)
+	client := NewReplayClient(out, t.TempDir())
+
+	trace, err := client.ProcessJob(jobConfig, &common.JobCredentials{ID: 1})
+	require.NoError(t, err)
+
+	_, err = trace.Write([]byte("job log\n"))
+	require.NoError(t, err)
+	require.NoError(t, trace.Success())
+
+	info, ok := client.JobInfo(1)
+	require.True(t, ok)
+	assert.Equal(t, common.Success, info.State)
+	assert.Equal(t, "job log\n", out.String())
+}
@@ -2029,3 +2029,427 @@ func (_c *MockReferee_Execute_Call) RunAndReturn(run func(ctx context.Context, s
 	_c.Call.Return(run)
 	return _c
 }
+
+// NewMockResourceUsageExecutor creates a new instance of MockResourceUsageExecutor. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockResourceUsageExecutor(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockResourceUsageExecutor {
+	mock := &MockResourceUsageExecutor{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockResourceUsageExecutor is an autogenerated mock type for the ResourceUsageExecutor type
+type MockResourceUsageExecutor struct {
+	mock.Mock
+}
+
+type MockResourceUsageExecutor_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockResourceUsageExecutor) EXPECT() *MockResourceUsageExecutor_Expecter {
+	return &MockResourceUsageExecutor_Expecter{mock: &_m.Mock}
+}
+
+// MemoryPeakUsage provides a mock function for the type MockResourceUsageExecutor
+func (_mock *MockResourceUsageExecutor) MemoryPeakUsage(ctx context.Context) (map[string]uint64, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MemoryPeakUsage")
+	}
+
+	var r0 map[string]uint64
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (map[string]uint64, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) map[string]uint64); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]uint64)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockResourceUsageExecutor_MemoryPeakUsage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MemoryPeakUsage'
+type MockResourceUsageExecutor_MemoryPeakUsage_Call struct {
+	*mock.Call
+}
+
+// MemoryPeakUsage is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockResourceUsageExecutor_Expecter) MemoryPeakUsage(ctx interface{}) *MockResourceUsageExecutor_MemoryPeakUsage_Call {
+	return &MockResourceUsageExecutor_MemoryPeakUsage_Call{Call: _e.mock.On("MemoryPeakUsage", ctx)}
+}
+
+func (_c *MockResourceUsageExecutor_MemoryPeakUsage_Call) Run(run func(ctx context.Context)) *MockResourceUsageExecutor_MemoryPeakUsage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockResourceUsageExecutor_MemoryPeakUsage_Call) Return(stringToUint64 map[string]uint64, err error) *MockResourceUsageExecutor_MemoryPeakUsage_Call {
+	_c.Call.Return(stringToUint64, err)
+	return _c
+}
+
+func (_c *MockResourceUsageExecutor_MemoryPeakUsage_Call) RunAndReturn(run func(ctx context.Context) (map[string]uint64, error)) *MockResourceUsageExecutor_MemoryPeakUsage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SampleResourceUsage provides a mock function for the type MockResourceUsageExecutor
+func (_mock *MockResourceUsageExecutor) SampleResourceUsage(ctx context.Context) (map[string]ResourceUsage, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SampleResourceUsage")
+	}
+
+	var r0 map[string]ResourceUsage
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (map[string]ResourceUsage, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) map[string]ResourceUsage); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]ResourceUsage)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockResourceUsageExecutor_SampleResourceUsage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SampleResourceUsage'
+type MockResourceUsageExecutor_SampleResourceUsage_Call struct {
+	*mock.Call
+}
+
+// SampleResourceUsage is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockResourceUsageExecutor_Expecter) SampleResourceUsage(ctx interface{}) *MockResourceUsageExecutor_SampleResourceUsage_Call {
+	return &MockResourceUsageExecutor_SampleResourceUsage_Call{Call: _e.mock.On("SampleResourceUsage", ctx)}
+}
+
+func (_c *MockResourceUsageExecutor_SampleResourceUsage_Call) Run(run func(ctx context.Context)) *MockResourceUsageExecutor_SampleResourceUsage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockResourceUsageExecutor_SampleResourceUsage_Call) Return(stringToResourceUsage map[string]ResourceUsage, err error) *MockResourceUsageExecutor_SampleResourceUsage_Call {
+	_c.Call.Return(stringToResourceUsage, err)
+	return _c
+}
+
+func (_c *MockResourceUsageExecutor_SampleResourceUsage_Call) RunAndReturn(run func(ctx context.Context) (map[string]ResourceUsage, error)) *MockResourceUsageExecutor_SampleResourceUsage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSamplingReferee creates a new instance of MockSamplingReferee. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSamplingReferee(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSamplingReferee {
+	mock := &MockSamplingReferee{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockSamplingReferee is an autogenerated mock type for the SamplingReferee type
+type MockSamplingReferee struct {
+	mock.Mock
+}
+
+type MockSamplingReferee_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSamplingReferee) EXPECT() *MockSamplingReferee_Expecter {
+	return &MockSamplingReferee_Expecter{mock: &_m.Mock}
+}
+
+// ArtifactBaseName provides a mock function for the type MockSamplingReferee
+func (_mock *MockSamplingReferee) ArtifactBaseName() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ArtifactBaseName")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockSamplingReferee_ArtifactBaseName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ArtifactBaseName'
+type MockSamplingReferee_ArtifactBaseName_Call struct {
+	*mock.Call
+}
+
+// ArtifactBaseName is a helper method to define mock.On call
+func (_e *MockSamplingReferee_Expecter) ArtifactBaseName() *MockSamplingReferee_ArtifactBaseName_Call {
+	return &MockSamplingReferee_ArtifactBaseName_Call{Call: _e.mock.On("ArtifactBaseName")}
+}
+
+func (_c *MockSamplingReferee_ArtifactBaseName_Call) Run(run func()) *MockSamplingReferee_ArtifactBaseName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactBaseName_Call) Return(s string) *MockSamplingReferee_ArtifactBaseName_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactBaseName_Call) RunAndReturn(run func() string) *MockSamplingReferee_ArtifactBaseName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ArtifactFormat provides a mock function for the type MockSamplingReferee
+func (_mock *MockSamplingReferee) ArtifactFormat() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ArtifactFormat")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockSamplingReferee_ArtifactFormat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ArtifactFormat'
+type MockSamplingReferee_ArtifactFormat_Call struct {
+	*mock.Call
+}
+
+// ArtifactFormat is a helper method to define mock.On call
+func (_e *MockSamplingReferee_Expecter) ArtifactFormat() *MockSamplingReferee_ArtifactFormat_Call {
+	return &MockSamplingReferee_ArtifactFormat_Call{Call: _e.mock.On("ArtifactFormat")}
+}
+
+func (_c *MockSamplingReferee_ArtifactFormat_Call) Run(run func()) *MockSamplingReferee_ArtifactFormat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactFormat_Call) Return(s string) *MockSamplingReferee_ArtifactFormat_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactFormat_Call) RunAndReturn(run func() string) *MockSamplingReferee_ArtifactFormat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ArtifactType provides a mock function for the type MockSamplingReferee
+func (_mock *MockSamplingReferee) ArtifactType() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ArtifactType")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockSamplingReferee_ArtifactType_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ArtifactType'
+type MockSamplingReferee_ArtifactType_Call struct {
+	*mock.Call
+}
+
+// ArtifactType is a helper method to define mock.On call
+func (_e *MockSamplingReferee_Expecter) ArtifactType() *MockSamplingReferee_ArtifactType_Call {
+	return &MockSamplingReferee_ArtifactType_Call{Call: _e.mock.On("ArtifactType")}
+}
+
+func (_c *MockSamplingReferee_ArtifactType_Call) Run(run func()) *MockSamplingReferee_ArtifactType_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactType_Call) Return(s string) *MockSamplingReferee_ArtifactType_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockSamplingReferee_ArtifactType_Call) RunAndReturn(run func() string) *MockSamplingReferee_ArtifactType_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Execute provides a mock function for the type MockSamplingReferee
+func (_mock *MockSamplingReferee) Execute(ctx context.Context, startTime time.Time, endTime time.Time) (*bytes.Reader, error) {
+	ret := _mock.Called(ctx, startTime, endTime)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 *bytes.Reader
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time, time.Time) (*bytes.Reader, error)); ok {
+		return returnFunc(ctx, startTime, endTime)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time, time.Time) *bytes.Reader); ok {
+		r0 = returnFunc(ctx, startTime, endTime)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bytes.Reader)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, time.Time, time.Time) error); ok {
+		r1 = returnFunc(ctx, startTime, endTime)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockSamplingReferee_Execute_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Execute'
+type MockSamplingReferee_Execute_Call struct {
+	*mock.Call
+}
+
+// Execute is a helper method to define mock.On call
+//   - ctx context.Context
+//   - startTime time.Time
+//   - endTime time.Time
+func (_e *MockSamplingReferee_Expecter) Execute(ctx interface{}, startTime interface{}, endTime interface{}) *MockSamplingReferee_Execute_Call {
+	return &MockSamplingReferee_Execute_Call{Call: _e.mock.On("Execute", ctx, startTime, endTime)}
+}
+
+func (_c *MockSamplingReferee_Execute_Call) Run(run func(ctx context.Context, startTime time.Time, endTime time.Time)) *MockSamplingReferee_Execute_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 time.Time
+		if args[1] != nil {
+			arg1 = args[1].(time.Time)
+		}
+		var arg2 time.Time
+		if args[2] != nil {
+			arg2 = args[2].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSamplingReferee_Execute_Call) Return(reader *bytes.Reader, err error) *MockSamplingReferee_Execute_Call {
+	_c.Call.Return(reader, err)
+	return _c
+}
+
+func (_c *MockSamplingReferee_Execute_Call) RunAndReturn(run func(ctx context.Context, startTime time.Time, endTime time.Time) (*bytes.Reader, error)) *MockSamplingReferee_Execute_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Sample provides a mock function for the type MockSamplingReferee
+func (_mock *MockSamplingReferee) Sample(ctx context.Context) {
+	_mock.Called(ctx)
+	return
+}
+
+// MockSamplingReferee_Sample_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sample'
+type MockSamplingReferee_Sample_Call struct {
+	*mock.Call
+}
+
+// Sample is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockSamplingReferee_Expecter) Sample(ctx interface{}) *MockSamplingReferee_Sample_Call {
+	return &MockSamplingReferee_Sample_Call{Call: _e.mock.On("Sample", ctx)}
+}
+
+func (_c *MockSamplingReferee_Sample_Call) Run(run func(ctx context.Context)) *MockSamplingReferee_Sample_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockSamplingReferee_Sample_Call) Return() *MockSamplingReferee_Sample_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockSamplingReferee_Sample_Call) RunAndReturn(run func(ctx context.Context)) *MockSamplingReferee_Sample_Call {
+	_c.Run(run)
+	return _c
+}
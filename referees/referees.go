@@ -3,6 +3,7 @@ package referees
 import (
 	"bytes"
 	"context"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,14 +20,24 @@ type Referee interface {
 	ArtifactFormat() string
 }
 
+// SamplingReferee is a Referee that collects its data while the job runs,
+// rather than querying it when the job finishes.
+type SamplingReferee interface {
+	Referee
+	// Sample collects data until ctx is done.
+	Sample(ctx context.Context)
+}
+
 type refereeFactory func(executor any, config *Config, log logrus.FieldLogger) Referee
 
 type Config struct {
-	Metrics *MetricsRefereeConfig `toml:"metrics,omitempty" json:"metrics" namespace:"metrics"`
+	Metrics       *MetricsRefereeConfig       `toml:"metrics,omitempty" json:"metrics" namespace:"metrics"`
+	ResourceUsage *ResourceUsageRefereeConfig `toml:"resource_usage,omitempty" json:"resource_usage" namespace:"resource_usage"`
 }
 
 var refereeFactories = []refereeFactory{
 	newMetricsReferee,
+	newResourceUsageReferee,
 }
 
 func CreateReferees(executor any, config *Config, log logrus.FieldLogger) []Referee {
@@ -45,3 +56,24 @@ func CreateReferees(executor any, config *Config, log logrus.FieldLogger) []Refe
 
 	return referees
 }
+
+// StartSampling starts the sampling referees. The returned function stops
+// them and waits for them to return.
+func StartSampling(ctx context.Context, referees []Referee) func() {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	for _, referee := range referees {
+		sampler, ok := referee.(SamplingReferee)
+		if !ok {
+			continue
+		}
+
+		wg.Go(func() { sampler.Sample(ctx) })
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -35,6 +35,13 @@ func Test_CreateReferees(t *testing.T) {
 			config:           &Config{Metrics: &MetricsRefereeConfig{QueryInterval: 0}},
 			expectedReferees: []Referee{&MetricsReferee{}},
 		},
+		"Executor supports resource usage referee": {
+			mockExecutor: func(t *testing.T) any {
+				return NewMockResourceUsageExecutor(t)
+			},
+			config:           &Config{ResourceUsage: &ResourceUsageRefereeConfig{}},
+			expectedReferees: []Referee{&ResourceUsageReferee{}},
+		},
 		"No config provided": {
 			mockExecutor:     mockMetricsExecutor,
 			config:           nil,
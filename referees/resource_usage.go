@@ -0,0 +1,334 @@
+package referees
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultResourceUsageSampleInterval = 10 * time.Second
+
+// ResourceUsage is the resource usage of a container. Counters are
+// cumulative since the container started.
+type ResourceUsage struct {
+	CPUSeconds float64
+	// CPUCores is the CPU usage rate, set instead of CPUSeconds by the
+	// runtimes that don't report the CPU time.
+	CPUCores float64
+	// MemoryBytes is the memory in use: the resident set size with Docker,
+	// the working set with Kubernetes.
+	MemoryBytes uint64
+	// MemoryPeakBytes is the highest memory usage the kernel recorded for
+	// the container, when the runtime reports it, like Docker with cgroup
+	// v1. It's zero when unknown.
+	MemoryPeakBytes      uint64
+	BlockReadBytes       uint64
+	BlockWriteBytes      uint64
+	NetworkReceiveBytes  uint64
+	NetworkTransmitBytes uint64
+}
+
+// MemoryPeakCommand is the shell command printing the highest memory usage
+// the kernel recorded for the cgroup of the container it runs in. Executors
+// run it once, at the end of the job, rather than at every sample.
+const MemoryPeakCommand = "cat /sys/fs/cgroup/memory.peak 2>/dev/null || cat /sys/fs/cgroup/memory/memory.max_usage_in_bytes"
+
+// ParseMemoryPeak parses the output of MemoryPeakCommand.
+func ParseMemoryPeak(output string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(output), 10, 64)
+}
+
+// ResourceUsageExecutor is implemented by executors that can sample the
+// resource usage of the containers of the job.
+type ResourceUsageExecutor interface {
+	// SampleResourceUsage returns the resource usage of the running
+	// containers of the job, keyed by container name.
+	SampleResourceUsage(ctx context.Context) (map[string]ResourceUsage, error)
+	// MemoryPeakUsage returns the highest memory usage the kernel recorded
+	// for the running containers of the job, when the samples don't report
+	// it, keyed by container name. It's called once, at the end of the job.
+	MemoryPeakUsage(ctx context.Context) (map[string]uint64, error)
+}
+
+type ResourceUsageRefereeConfig struct {
+	SampleInterval int `toml:"sample_interval,omitempty" json:"sample_interval" description:"Sample interval (in seconds)"`
+}
+
+type resourceUsageSample struct {
+	time  time.Time
+	usage ResourceUsage
+}
+
+// ResourceUsageReferee samples the resource usage of the containers of the
+// job while it runs.
+type ResourceUsageReferee struct {
+	executor ResourceUsageExecutor
+	interval time.Duration
+	logger   logrus.FieldLogger
+
+	mu      sync.Mutex
+	samples map[string][]resourceUsageSample
+}
+
+func (rr *ResourceUsageReferee) ArtifactBaseName() string {
+	return "resource_usage.gz"
+}
+
+func (rr *ResourceUsageReferee) ArtifactType() string {
+	return "metrics_referee"
+}
+
+func (rr *ResourceUsageReferee) ArtifactFormat() string {
+	return "gzip"
+}
+
+// Sample samples the resource usage of the containers of the job every
+// interval, until ctx is done.
+func (rr *ResourceUsageReferee) Sample(ctx context.Context) {
+	ticker := time.NewTicker(rr.interval)
+	defer ticker.Stop()
+
+	for {
+		rr.sample(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rr *ResourceUsageReferee) sample(ctx context.Context) {
+	usage, err := rr.executor.SampleResourceUsage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			rr.logger.WithError(err).Debug("Failed to sample resource usage")
+		}
+		return
+	}
+
+	now := time.Now()
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	for name, u := range usage {
+		rr.samples[name] = append(rr.samples[name], resourceUsageSample{time: now, usage: u})
+	}
+}
+
+// Execute samples the resource usage one last time, then returns the samples
+// taken between startTime and endTime as a JSON report, gzip compressed, as
+// the metrics_referee artifact only accepts gzip. The report embeds the
+// samples in the OpenMetrics text format too.
+func (rr *ResourceUsageReferee) Execute(ctx context.Context, startTime, endTime time.Time) (*bytes.Reader, error) {
+	rr.sample(ctx)
+
+	report := rr.report(startTime, endTime)
+	if len(report.Containers) == 0 {
+		return nil, fmt.Errorf("no resource usage sampled")
+	}
+
+	peaks, err := rr.executor.MemoryPeakUsage(ctx)
+	if err != nil {
+		rr.logger.WithError(err).Debug("Failed to read peak memory usage")
+	}
+	for name, peak := range peaks {
+		if c, ok := report.Containers[name]; ok {
+			c.PeakMemoryBytes = max(c.PeakMemoryBytes, peak)
+		}
+	}
+
+	openMetrics := new(strings.Builder)
+	if err := report.writeOpenMetrics(openMetrics); err != nil {
+		return nil, fmt.Errorf("writing OpenMetrics text: %w", err)
+	}
+	report.OpenMetrics = openMetrics.String()
+
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Name = "resource_usage.json"
+	gz.ModTime = endTime
+
+	if err := json.NewEncoder(gz).Encode(report); err != nil {
+		return nil, fmt.Errorf("writing resource usage report: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("compressing resource usage report: %w", err)
+	}
+
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// resourceUsageReport is the resource usage of the containers of the job.
+type resourceUsageReport struct {
+	Containers map[string]*containerUsageReport `json:"containers"`
+	// OpenMetrics holds the samples in the OpenMetrics text format.
+	OpenMetrics string `json:"open_metrics"`
+}
+
+// containerUsageReport summarizes the resource usage of a container and
+// holds its samples, in the format of the metrics referee.
+type containerUsageReport struct {
+	CPUSeconds   float64 `json:"cpu_seconds"`
+	PeakCPUCores float64 `json:"peak_cpu_cores"`
+	// PeakMemoryBytes is the highest memory usage recorded by the kernel
+	// or, when the runtime doesn't report it, the highest sampled.
+	PeakMemoryBytes      uint64 `json:"peak_memory_bytes"`
+	BlockReadBytes       uint64 `json:"block_read_bytes"`
+	BlockWriteBytes      uint64 `json:"block_write_bytes"`
+	NetworkReceiveBytes  uint64 `json:"network_receive_bytes"`
+	NetworkTransmitBytes uint64 `json:"network_transmit_bytes"`
+
+	Samples map[string][]model.SamplePair `json:"samples"`
+}
+
+var resourceUsageMetrics = []struct {
+	name  string
+	help  string
+	typ   string
+	unit  string
+	value func(ResourceUsage) float64
+}{
+	{"cpu_seconds", "CPU time consumed", "counter", "seconds", func(u ResourceUsage) float64 { return u.CPUSeconds }},
+	{"cpu_cores", "CPU usage rate", "gauge", "cores", func(u ResourceUsage) float64 { return u.CPUCores }},
+	{"memory_bytes", "Memory in use", "gauge", "bytes", func(u ResourceUsage) float64 { return float64(u.MemoryBytes) }},
+	{"memory_peak_bytes", "Highest memory usage recorded by the kernel", "gauge", "bytes", func(u ResourceUsage) float64 { return float64(u.MemoryPeakBytes) }},
+	{"block_read_bytes", "Bytes read from block devices", "counter", "bytes", func(u ResourceUsage) float64 { return float64(u.BlockReadBytes) }},
+	{"block_write_bytes", "Bytes written to block devices", "counter", "bytes", func(u ResourceUsage) float64 { return float64(u.BlockWriteBytes) }},
+	{"network_receive_bytes", "Bytes received over the network", "counter", "bytes", func(u ResourceUsage) float64 { return float64(u.NetworkReceiveBytes) }},
+	{"network_transmit_bytes", "Bytes transmitted over the network", "counter", "bytes", func(u ResourceUsage) float64 { return float64(u.NetworkTransmitBytes) }},
+}
+
+func (rr *ResourceUsageReferee) report(startTime, endTime time.Time) resourceUsageReport {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	report := resourceUsageReport{Containers: map[string]*containerUsageReport{}}
+	for name, samples := range rr.samples {
+		var c *containerUsageReport
+		var previous *resourceUsageSample
+
+		for _, s := range samples {
+			if s.time.Before(startTime) || s.time.After(endTime) {
+				continue
+			}
+
+			if c == nil {
+				c = &containerUsageReport{Samples: map[string][]model.SamplePair{}}
+				report.Containers[name] = c
+			}
+
+			for _, metric := range resourceUsageMetrics {
+				c.Samples[metric.name] = append(c.Samples[metric.name], model.SamplePair{
+					Timestamp: model.TimeFromUnixNano(s.time.UnixNano()),
+					Value:     model.SampleValue(metric.value(s.usage)),
+				})
+			}
+
+			c.PeakMemoryBytes = max(c.PeakMemoryBytes, s.usage.MemoryPeakBytes, s.usage.MemoryBytes)
+			c.BlockReadBytes = s.usage.BlockReadBytes
+			c.BlockWriteBytes = s.usage.BlockWriteBytes
+			c.NetworkReceiveBytes = s.usage.NetworkReceiveBytes
+			c.NetworkTransmitBytes = s.usage.NetworkTransmitBytes
+
+			cores := s.usage.CPUCores
+			if s.usage.CPUSeconds > 0 {
+				c.CPUSeconds = s.usage.CPUSeconds
+			}
+			if previous != nil && s.time.After(previous.time) {
+				elapsed := s.time.Sub(previous.time).Seconds()
+				if s.usage.CPUSeconds >= previous.usage.CPUSeconds {
+					cores = max(cores, (s.usage.CPUSeconds-previous.usage.CPUSeconds)/elapsed)
+				}
+				// without the CPU time, it's integrated from the usage rate
+				if s.usage.CPUSeconds == 0 {
+					c.CPUSeconds += s.usage.CPUCores * elapsed
+				}
+			}
+			c.PeakCPUCores = max(c.PeakCPUCores, cores)
+			previous = &s
+		}
+	}
+
+	return report
+}
+
+// writeOpenMetrics writes the samples in the OpenMetrics text format, one
+// metric family per resource with a container label.
+func (r resourceUsageReport) writeOpenMetrics(w io.Writer) error {
+	names := slices.Sorted(maps.Keys(r.Containers))
+
+	buf := new(bytes.Buffer)
+	for _, metric := range resourceUsageMetrics {
+		family := "gitlab_runner_job_container_" + metric.name
+		suffix := ""
+		if metric.typ == "counter" {
+			suffix = "_total"
+		}
+
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family, metric.typ)
+		fmt.Fprintf(buf, "# UNIT %s %s\n", family, metric.unit)
+		fmt.Fprintf(buf, "# HELP %s %s.\n", family, metric.help)
+
+		for _, name := range names {
+			for _, s := range r.Containers[name].Samples[metric.name] {
+				fmt.Fprintf(
+					buf, "%s%s{container=%s} %s %s\n",
+					family, suffix, strconv.Quote(name),
+					strconv.FormatFloat(float64(s.Value), 'g', -1, 64),
+					strconv.FormatFloat(float64(s.Timestamp)/1000, 'f', 3, 64),
+				)
+			}
+		}
+	}
+	buf.WriteString("# EOF\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func newResourceUsageReferee(executor any, config *Config, log logrus.FieldLogger) Referee {
+	logger := log.WithField("referee", "resource_usage")
+	if config.ResourceUsage == nil {
+		return nil
+	}
+
+	// both referees upload the metrics_referee artifact, of which GitLab
+	// keeps one per job
+	if config.Metrics != nil {
+		logger.Warning("metrics referee configured, skipping")
+		return nil
+	}
+
+	refereed, ok := executor.(ResourceUsageExecutor)
+	if !ok {
+		logger.Info("executor not supported")
+		return nil
+	}
+
+	interval := time.Duration(config.ResourceUsage.SampleInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultResourceUsageSampleInterval
+	}
+
+	return &ResourceUsageReferee{
+		executor: refereed,
+		interval: interval,
+		logger:   logger,
+		samples:  map[string][]resourceUsageSample{},
+	}
+}
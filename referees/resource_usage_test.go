@@ -0,0 +1,165 @@
+//go:build !integration
+
+package referees
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResourceUsageReferee(t *testing.T) {
+	log := logrus.WithField("test", 1)
+
+	t.Run("no config", func(t *testing.T) {
+		rr := newResourceUsageReferee(NewMockResourceUsageExecutor(t), &Config{}, log)
+		assert.Nil(t, rr)
+	})
+
+	t.Run("improper executor", func(t *testing.T) {
+		rr := newResourceUsageReferee(struct{}{}, &Config{ResourceUsage: &ResourceUsageRefereeConfig{}}, log)
+		assert.Nil(t, rr)
+	})
+
+	t.Run("metrics referee configured", func(t *testing.T) {
+		config := &Config{
+			Metrics:       &MetricsRefereeConfig{},
+			ResourceUsage: &ResourceUsageRefereeConfig{},
+		}
+		rr := newResourceUsageReferee(NewMockResourceUsageExecutor(t), config, log)
+		assert.Nil(t, rr)
+	})
+
+	t.Run("default sample interval", func(t *testing.T) {
+		rr := newResourceUsageReferee(NewMockResourceUsageExecutor(t), &Config{ResourceUsage: &ResourceUsageRefereeConfig{}}, log)
+		require.IsType(t, &ResourceUsageReferee{}, rr)
+		assert.Equal(t, defaultResourceUsageSampleInterval, rr.(*ResourceUsageReferee).interval)
+	})
+
+	t.Run("sample interval", func(t *testing.T) {
+		config := &Config{ResourceUsage: &ResourceUsageRefereeConfig{SampleInterval: 2}}
+		rr := newResourceUsageReferee(NewMockResourceUsageExecutor(t), config, log)
+		require.IsType(t, &ResourceUsageReferee{}, rr)
+		assert.Equal(t, 2*time.Second, rr.(*ResourceUsageReferee).interval)
+	})
+}
+
+func TestResourceUsageRefereeSample(t *testing.T) {
+	executor := NewMockResourceUsageExecutor(t)
+	executor.EXPECT().SampleResourceUsage(mock.Anything).
+		Return(map[string]ResourceUsage{"build": {CPUSeconds: 1}}, nil)
+
+	config := &Config{ResourceUsage: &ResourceUsageRefereeConfig{SampleInterval: 1}}
+	rr := newResourceUsageReferee(executor, config, logrus.WithField("test", 1)).(*ResourceUsageReferee)
+
+	stop := StartSampling(t.Context(), []Referee{rr})
+	assert.Eventually(t, func() bool {
+		rr.mu.Lock()
+		defer rr.mu.Unlock()
+		return len(rr.samples["build"]) > 0
+	}, time.Second, 10*time.Millisecond)
+	stop()
+}
+
+func TestResourceUsageRefereeExecute(t *testing.T) {
+	startTime := time.Unix(1700000000, 0)
+	endTime := startTime.Add(time.Minute)
+
+	executor := NewMockResourceUsageExecutor(t)
+	rr := newResourceUsageReferee(
+		executor,
+		&Config{ResourceUsage: &ResourceUsageRefereeConfig{}},
+		logrus.WithField("test", 1),
+	).(*ResourceUsageReferee)
+
+	t.Run("no samples", func(t *testing.T) {
+		executor.EXPECT().SampleResourceUsage(mock.Anything).Return(nil, errors.New("container gone")).Once()
+
+		_, err := rr.Execute(t.Context(), startTime, endTime)
+		assert.Error(t, err)
+	})
+
+	rr.samples = map[string][]resourceUsageSample{
+		"build": {
+			{time: startTime.Add(-time.Second), usage: ResourceUsage{MemoryBytes: 1 << 40}},
+			{time: startTime.Add(10 * time.Second), usage: ResourceUsage{CPUSeconds: 1, MemoryBytes: 300}},
+			{time: startTime.Add(20 * time.Second), usage: ResourceUsage{CPUSeconds: 16, MemoryBytes: 500}},
+			{time: startTime.Add(30 * time.Second), usage: ResourceUsage{
+				CPUSeconds:           21,
+				MemoryBytes:          400,
+				MemoryPeakBytes:      700,
+				BlockReadBytes:       10,
+				BlockWriteBytes:      20,
+				NetworkReceiveBytes:  30,
+				NetworkTransmitBytes: 40,
+			}},
+		},
+		// the usage rate is reported instead of the CPU time
+		"svc-0": {
+			{time: startTime.Add(10 * time.Second), usage: ResourceUsage{CPUCores: 0.5, MemoryBytes: 100}},
+			{time: startTime.Add(20 * time.Second), usage: ResourceUsage{CPUCores: 2, MemoryBytes: 200}},
+			{time: startTime.Add(30 * time.Second), usage: ResourceUsage{CPUCores: 1, MemoryBytes: 150}},
+		},
+	}
+
+	executor.EXPECT().SampleResourceUsage(mock.Anything).Return(nil, errors.New("container gone")).Once()
+	executor.EXPECT().MemoryPeakUsage(mock.Anything).Return(map[string]uint64{"build": 600, "svc-0": 250, "gone": 999}, nil).Once()
+
+	r, err := rr.Execute(t.Context(), startTime, endTime)
+	require.NoError(t, err)
+
+	assert.Equal(t, "metrics_referee", rr.ArtifactType())
+	assert.Equal(t, "gzip", rr.ArtifactFormat())
+
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	assert.Equal(t, "resource_usage.json", gz.Name)
+
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	var report resourceUsageReport
+	require.NoError(t, json.Unmarshal(data, &report), "the artifact gunzips to a single JSON document")
+	require.Contains(t, report.Containers, "build")
+
+	build := report.Containers["build"]
+	assert.Equal(t, 21.0, build.CPUSeconds)
+	assert.Equal(t, 1.5, build.PeakCPUCores)
+	assert.Equal(t, uint64(700), build.PeakMemoryBytes, "samples before the job start are ignored")
+	assert.Equal(t, uint64(10), build.BlockReadBytes)
+	assert.Equal(t, uint64(20), build.BlockWriteBytes)
+	assert.Equal(t, uint64(30), build.NetworkReceiveBytes)
+	assert.Equal(t, uint64(40), build.NetworkTransmitBytes)
+	assert.Len(t, build.Samples["memory_bytes"], 3)
+
+	svc := report.Containers["svc-0"]
+	assert.Equal(t, 30.0, svc.CPUSeconds)
+	assert.Equal(t, 2.0, svc.PeakCPUCores)
+	assert.Equal(t, uint64(250), svc.PeakMemoryBytes, "the peak read at the end of the job")
+	assert.NotContains(t, report.Containers, "gone")
+
+	openMetrics := report.OpenMetrics
+	assert.Contains(t, openMetrics, "# TYPE gitlab_runner_job_container_cpu_seconds counter\n")
+	assert.Contains(t, openMetrics, "# UNIT gitlab_runner_job_container_cpu_seconds seconds\n")
+	assert.Contains(t, openMetrics, `gitlab_runner_job_container_cpu_seconds_total{container="build"} 16 1700000020.000`+"\n")
+	assert.Contains(t, openMetrics, `gitlab_runner_job_container_memory_bytes{container="build"} 500 1700000020.000`+"\n")
+	assert.True(t, strings.HasSuffix(openMetrics, "# EOF\n"))
+}
+
+func TestParseMemoryPeak(t *testing.T) {
+	peak, err := ParseMemoryPeak("1048576\n")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1048576), peak)
+
+	_, err = ParseMemoryPeak("cat: can't open '/sys/fs/cgroup/memory.peak'")
+	assert.Error(t, err)
+}
@@ -135,6 +135,10 @@ func newCacheConfig(build *common.Build, userKey string, keyChecks ...func(strin
 		return nil, "", fmt.Errorf("unset cache directory")
 	}
 
+	if isChunkedCacheFormat(build) && !cache.SupportsChunkStore(build.Runner.Cache) {
+		return nil, "", cache.ErrChunkStoreUnsupported
+	}
+
 	rawKey := path.Join("/", build.JobInfo.Name, build.GitInfo.Ref)[1:]
 	if userKey != "" {
 		rawKey = build.GetAllVariables().ExpandValue(userKey)
@@ -424,6 +428,10 @@ func getAlternateCacheDownloadURL(ctx context.Context, build *common.Build, cach
 func getCacheDownloadURLAndEnv(ctx context.Context, build *common.Build, cacheKey string) ([]string, map[string]string, error) {
 	adapter := cache.GetAdapter(build.Runner.Cache, build.GetBuildTimeout(), build.Runner.ShortDescription(), fmt.Sprintf("%d", build.JobInfo.ProjectID), cacheKey, build.IsFeatureFlagOn(featureflags.HashCacheKeys))
 
+	if goCloudURL, prefix, err := getChunkStoreGoCloudURL(ctx, build, adapter, false); goCloudURL.URL != nil {
+		return []string{"--gocloud-url", goCloudURL.URL.String(), "--chunk-prefix", prefix}, goCloudURL.Environment, err
+	}
+
 	// Prefer Go Cloud URL if supported
 	goCloudURL, err := adapter.GetGoCloudURL(ctx, false)
 
@@ -442,11 +450,35 @@ func getCacheDownloadURLAndEnv(ctx context.Context, build *common.Build, cacheKe
 	return []string{}, nil, nil
 }
 
+// getChunkStoreGoCloudURL returns the GoCloud URL of the cache object, with
+// credentials also covering the project's chunk store, and the prefix of the
+// chunk store. The URL is nil unless the job uses the chunked cache format,
+// which newCacheConfig rejects when the cache can't store chunks.
+func getChunkStoreGoCloudURL(ctx context.Context, build *common.Build, adapter cache.Adapter, upload bool) (cache.GoCloudURL, string, error) {
+	if !isChunkedCacheFormat(build) {
+		return cache.GoCloudURL{}, "", nil
+	}
+
+	chunkStoreAdapter, ok := adapter.(cache.ChunkStoreAdapter)
+	if !ok {
+		return cache.GoCloudURL{}, "", nil
+	}
+
+	prefix := cache.ChunkPrefix(build.Runner.Cache, build.Runner.ShortDescription(), fmt.Sprintf("%d", build.JobInfo.ProjectID), build.IsProtected())
+	goCloudURL, err := chunkStoreAdapter.GetChunkStoreGoCloudURL(ctx, prefix, upload)
+
+	return goCloudURL, prefix, err
+}
+
+func isChunkedCacheFormat(build *common.Build) bool {
+	return strings.EqualFold(build.GetAllVariables().Value("CACHE_COMPRESSION_FORMAT"), cache.ChunkedFormat)
+}
+
 func (b *AbstractShell) downloadArtifacts(w ShellWriter, job spec.Dependency, info common.ShellScriptInfo) {
 	args := []string{
 		"artifacts-downloader",
 		"--url",
-		info.Build.Runner.URL,
+		info.Build.GetArtifactsURL(),
 		"--token",
 		job.Token,
 		"--id",
@@ -1662,6 +1694,10 @@ func (b *AbstractShell) addCacheUploadCommand(
 func getCacheUploadURLAndEnv(ctx context.Context, build *common.Build, cacheKey string, metadata map[string]string) ([]string, map[string]string, error) {
 	adapter := cache.GetAdapter(build.Runner.Cache, build.GetBuildTimeout(), build.Runner.ShortDescription(), fmt.Sprintf("%d", build.JobInfo.ProjectID), cacheKey, build.IsFeatureFlagOn(featureflags.HashCacheKeys))
 
+	if goCloudURL, prefix, err := getChunkStoreGoCloudURL(ctx, build, adapter, true); goCloudURL.URL != nil {
+		return []string{"--gocloud-url", goCloudURL.URL.String(), "--chunk-prefix", prefix}, goCloudURL.Environment, err
+	}
+
 	// Prefer Go Cloud URL if supported
 	goCloudURL, err := adapter.GetGoCloudURL(ctx, true)
 	if goCloudURL.URL != nil {
@@ -1693,7 +1729,7 @@ func (b *AbstractShell) writeUploadArtifact(w ShellWriter, info common.ShellScri
 	args := []string{
 		"artifacts-uploader",
 		"--url",
-		info.Build.Runner.URL,
+		info.Build.GetArtifactsURL(),
 		"--token",
 		info.Build.Token,
 		"--id",
@@ -1801,7 +1837,7 @@ func (b *AbstractShell) generateArtifactsMetadataArgs(info common.ShellScriptInf
 }
 
 func (b *AbstractShell) writeUploadArtifacts(w ShellWriter, info common.ShellScriptInfo, onSuccess bool) error {
-	if info.Build.Runner.URL == "" {
+	if info.Build.GetArtifactsURL() == "" {
 		return common.ErrSkipBuildStage
 	}
 
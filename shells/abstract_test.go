@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"gitlab.com/gitlab-org/gitlab-runner/cache"
 	"gitlab.com/gitlab-org/gitlab-runner/cache/cacheconfig"
 	_ "gitlab.com/gitlab-org/gitlab-runner/cache/test"
 	"gitlab.com/gitlab-org/gitlab-runner/common"
@@ -4780,3 +4781,50 @@ func TestAbstractShell_writeExports_GitConfigGlobal(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCacheConfig_ChunkedFormat(t *testing.T) {
+	tests := map[string]struct {
+		format        string
+		cache         *cacheconfig.Config
+		expectedError error
+	}{
+		"chunked format not requested": {
+			cache: &cacheconfig.Config{Type: "gcs"},
+		},
+		"chunked format with a chunk store": {
+			format: "chunked",
+			cache:  &cacheconfig.Config{Type: "s3", S3: &cacheconfig.CacheS3Config{RoleARN: "arn"}},
+		},
+		"chunked format with the runner's local cache only": {
+			format: "chunked",
+		},
+		"chunked format without RoleARN": {
+			format:        "Chunked",
+			cache:         &cacheconfig.Config{Type: "s3", S3: &cacheconfig.CacheS3Config{}},
+			expectedError: cache.ErrChunkStoreUnsupported,
+		},
+		"chunked format with another cache type": {
+			format:        "chunked",
+			cache:         &cacheconfig.Config{Type: "local", Local: &cacheconfig.CacheLocalConfig{}},
+			expectedError: cache.ErrChunkStoreUnsupported,
+		},
+	}
+
+	for tn, tt := range tests {
+		t.Run(tn, func(t *testing.T) {
+			build := &common.Build{
+				Job: spec.Job{
+					Variables: spec.Variables{
+						{Key: "CACHE_COMPRESSION_FORMAT", Value: tt.format},
+					},
+				},
+				Runner:   &common.RunnerConfig{RunnerSettings: common.RunnerSettings{Cache: tt.cache}},
+				CacheDir: "/cache",
+				BuildDir: "/build",
+			}
+
+			_, _, err := newCacheConfig(build, "key")
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}